@@ -0,0 +1,140 @@
+// Command defornicate-server serves a documents tree over a read-only REST
+// API (the document listing, per-document metadata, extracted text and
+// search) and a browser UI at /. Flags default to $DEFORNICATOR_ADDR (or
+// $PORT on all interfaces), $DEFORNICATOR_ROOT, $DEFORNICATOR_PDF_CACHE_MB and
+// $DEFORNICATOR_EXTRACT_ON_DEMAND and $DEFORNICATOR_API_KEYS, so the server can
+// be configured from a container's environment.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/server"
+)
+
+// shutdownTimeout is how long in-flight requests get to finish on SIGTERM
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run parses flags and serves until interrupted
+func run(args []string) int {
+	flags := flag.NewFlagSet("defornicate-server", flag.ContinueOnError)
+	addr := flags.String("addr", defaultAddr(), "address to listen on (default: $DEFORNICATOR_ADDR, or :$PORT)")
+	root := flags.String("root", envOr("DEFORNICATOR_ROOT", downloader.DefaultDocumentsDir), "documents tree to serve (default: $DEFORNICATOR_ROOT)")
+	cacheMB := flags.Int64("pdf-cache", envInt("DEFORNICATOR_PDF_CACHE_MB", 256), "memory in MB for keeping parsed PDFs between requests (default: $DEFORNICATOR_PDF_CACHE_MB)")
+	onDemand := flags.Bool("extract-on-demand", envBool("DEFORNICATOR_EXTRACT_ON_DEMAND"), "extract documents without an extraction output when their text is requested, saving the output (default: $DEFORNICATOR_EXTRACT_ON_DEMAND)")
+	keysFile := flags.String("api-keys", os.Getenv("DEFORNICATOR_API_KEYS"), "JSON file of API keys required on API requests, each with its own rate limit (default: $DEFORNICATOR_API_KEYS)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+
+	cat, err := catalog.Load(*root, pathutil.Permissions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+	// Outputs are found in any compression, so the default format and
+	// compression will do
+	ext := extractor.NewWithOptions(extractor.Options{Readers: extractor.NewReaderCache(*cacheMB << 20)})
+
+	var keys []server.APIKey
+	if *keysFile != "" {
+		if keys, err = server.LoadAPIKeys(*keysFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	handler := server.NewWithOptions(*root, cat, ext, server.Options{ExtractOnDemand: *onDemand, APIKeys: keys})
+	srv := &http.Server{Addr: *addr, Handler: handler}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s\n", *root, *addr)
+	if len(keys) > 0 {
+		fmt.Fprintf(os.Stderr, "API requires one of %d key(s) from %s\n", len(keys), *keysFile)
+	} else if !isLoopback(*addr) {
+		fmt.Fprintf(os.Stderr, "Warning: the API is open to anyone who can reach %s; set --api-keys to require keys\n", *addr)
+	}
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultAddr returns the listen address from the environment:
+// $DEFORNICATOR_ADDR, all interfaces on $PORT as set by container platforms,
+// or localhost:8080
+func defaultAddr() string {
+	if addr := os.Getenv("DEFORNICATOR_ADDR"); addr != "" {
+		return addr
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+	return "localhost:8080"
+}
+
+// envOr returns the environment variable key, or fallback if it is unset or
+// empty
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// envInt returns the environment variable key as an integer, or fallback if
+// it is unset or not a number
+func envInt(key string, fallback int64) int64 {
+	if n, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// envBool returns the environment variable key as a boolean ("true", "1"),
+// or false if it is unset or not a boolean
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// isLoopback reports whether addr only listens on the local machine
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
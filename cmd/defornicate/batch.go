@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"defornicate-epstein-files/internal/budget"
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/hooks"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/pipeline"
+	"defornicate-epstein-files/internal/provenance"
+	"defornicate-epstein-files/internal/sink"
+	"defornicate-epstein-files/internal/source"
+)
+
+// batchOptions selects which documents under the documents directory a batch
+// extraction run operates on
+type batchOptions struct {
+	pendingOnly bool   // only documents without an extraction output
+	match       string // glob matched against the document filename
+	tag         string // tag the document's meta.yaml must list
+	class       string // class the document's meta.yaml must record
+	concurrency int
+	debugDump   bool // also dump raw page objects for debugging
+	split       bool // also save one output per detected sub-document
+	sinks       []sink.Sink
+	hooks       *hooks.Runner   // commands run on extract-complete and failure events
+	provenance  *provenance.Log // records each extraction, when the log is kept
+	budget      *budget.Budget  // bounds the documents being extracted at once
+	shard       source.Shard    // only documents in this shard (all when unset)
+	perms       pathutil.Permissions
+}
+
+// Rough memory needed to extract a document, as a multiple of its file size
+// (the parsed page objects plus the extracted and rendered text), and the
+// least reserved for any document
+const (
+	extractMemoryFactor = 4
+	minExtractMemory    = 1 << 20
+)
+
+// extractMemory estimates the memory needed to extract the document at path
+func extractMemory(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return minExtractMemory
+	}
+	return max(info.Size()*extractMemoryFactor, minExtractMemory)
+}
+
+// runBatch extracts documents already stored under documentsDir, selected by
+// opts, using a pool of workers
+func runBatch(ext *extractor.Extractor, cat *catalog.Catalog, documentsDir string, opts batchOptions, stop *shutdown) int {
+	var candidates []string
+	var err error
+	if opts.pendingOnly {
+		candidates, err = ext.FindPending(documentsDir)
+	} else {
+		candidates, err = ext.FindExtractable(documentsDir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding documents: %v\n", err)
+		return 1
+	}
+
+	pending, err := filterByGlob(candidates, opts.match)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --match pattern: %v\n", err)
+		return 1
+	}
+	pending = filterByMeta(pending, opts.tag, opts.class)
+	if opts.shard.Count > 1 {
+		pending = shardDocuments(pending, documentsDir, opts.shard)
+	}
+	concurrency := opts.concurrency
+	if len(pending) == 0 {
+		fmt.Fprintf(os.Stderr, "No matching documents found in %s\n", documentsDir)
+		return 0
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	notef("Found %d document(s), extracting with %d worker(s)\n", len(pending), concurrency)
+
+	steps := []pipeline.Step{pipeline.UnpackStep(ext, cat), pipeline.CurateStep(cat, opts.perms), pipeline.ExtractStep(ext), pipeline.AnalyzeStep(pipeline.CoverSheetAnalyzer(cat), pipeline.ReviewAnalyzer(cat), pipeline.EXIFAnalyzer(cat)), pipeline.ExportStep(ext, cat, opts.sinks...)}
+	if opts.split {
+		steps = append(steps, pipeline.SplitStep(ext, cat, opts.sinks...))
+	}
+	p := pipeline.New(steps...)
+	if opts.hooks != nil {
+		p.After(opts.hooks.AfterHook(warnHook))
+	}
+	if opts.provenance != nil {
+		p.After(opts.provenance.AfterHook(documentsDir, warnHook))
+	}
+	if opts.debugDump {
+		p.Before(func(step string, doc *pipeline.Document) {
+			if step == pipeline.StepExtract {
+				writeDebugDump(ext, doc.Path)
+			}
+		})
+	}
+
+	queued := make(map[string]bool, len(pending))
+	for _, path := range pending {
+		queued[path] = true
+	}
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var done, errorCount int
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				// A document handed out as shutdown was requested is left
+				// for the next run
+				if stop.Requested() {
+					continue
+				}
+				doc := &pipeline.Document{Item: source.Item{Input: filePath, Path: filePath}, Path: filePath}
+				// Wait for room in the memory budget so large documents are
+				// not all held at once
+				reserved := opts.budget.Acquire(extractMemory(filePath))
+				err := p.Run(doc)
+				opts.budget.Release(reserved)
+
+				// Report progress as each document completes
+				mu.Lock()
+				done++
+				if err != nil {
+					errorCount++
+					fmt.Fprintf(os.Stderr, "[%d/%d] Error extracting %s: %v\n", done, len(pending), filePath, errors.Unwrap(err))
+				} else {
+					notef("[%d/%d] Extracted text saved to: %s\n", done, len(pending), doc.OutputPath)
+					if len(doc.Parts) > 0 {
+						notef("[%d/%d] Split %s into %d logical document(s)\n", done, len(pending), filePath, len(doc.Parts))
+					}
+				}
+				mu.Unlock()
+
+				// Documents unpacked from a container by an earlier run were
+				// found like any other; the rest are extracted by the worker
+				// that unpacked them
+				doc.Embedded = slices.DeleteFunc(doc.Embedded, func(path string) bool {
+					return queued[path] || (opts.pendingOnly && ext.FindOutput(path) != "")
+				})
+				runEmbedded(p, doc, func(embedded *pipeline.Document, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						errorCount++
+						fmt.Fprintf(os.Stderr, "Error extracting %s: %v\n", embedded.Path, errors.Unwrap(err))
+					} else {
+						notef("Extracted text saved to: %s\n", embedded.OutputPath)
+					}
+				})
+			}
+		}()
+	}
+
+	for _, filePath := range pending {
+		// Stop handing out work once a shutdown has been requested; workers
+		// finish the documents they already hold
+		if stop.Requested() {
+			break
+		}
+		jobs <- filePath
+	}
+	close(jobs)
+	wg.Wait()
+	if err := cat.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	notef("\n--- Summary ---\n")
+	notef("Total processed: %d\n", done)
+	notef("Successful: %d\n", done-errorCount)
+	if done < len(pending) {
+		fmt.Fprintf(os.Stderr, "Shutdown requested, %d document(s) not processed\n", len(pending)-done)
+	}
+	if errorCount > 0 {
+		fmt.Fprintf(os.Stderr, "Errors: %d\n", errorCount)
+		return 1
+	}
+	return 0
+}
+
+// filterByGlob returns the paths whose base filename matches glob; an empty
+// glob matches everything
+func filterByGlob(paths []string, glob string) ([]string, error) {
+	if glob == "" {
+		return paths, nil
+	}
+	// Validate the pattern up front so a typo isn't reported as "no matches"
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, path := range paths {
+		if ok, _ := filepath.Match(glob, filepath.Base(path)); ok {
+			matched = append(matched, path)
+		}
+	}
+	return matched, nil
+}
+
+// filterByMeta returns the paths of documents whose meta.yaml lists tag and
+// records class, either of which may be empty to match every document
+func filterByMeta(paths []string, tag, class string) []string {
+	if tag == "" && class == "" {
+		return paths
+	}
+	var matched []string
+	for _, path := range paths {
+		curated, err := meta.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if (tag == "" || curated.HasTag(tag)) && (class == "" || curated.HasClass(class)) {
+			matched = append(matched, path)
+		}
+	}
+	return matched
+}
+
+// shardDocuments keeps the documents in shard, keyed by their path relative
+// to documentsDir so machines sharing a tree agree on the split
+func shardDocuments(paths []string, documentsDir string, shard source.Shard) []string {
+	var kept []string
+	for _, path := range paths {
+		key, err := filepath.Rel(documentsDir, path)
+		if err != nil {
+			key = path
+		}
+		if shard.Contains(filepath.ToSlash(key)) {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// writeDebugDump writes the raw page objects of filePath for debugging,
+// reporting (but otherwise ignoring) failures
+func writeDebugDump(ext *extractor.Extractor, filePath string) {
+	dir, err := ext.DumpDebug(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing debug dump for %s: %v\n", filePath, err)
+		return
+	}
+	notef("Debug dump written to: %s\n", dir)
+}
+
+// warnHook reports a failed hook command; hooks never fail a document
+func warnHook(err error) {
+	fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+}
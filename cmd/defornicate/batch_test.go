@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilterByGlob(t *testing.T) {
+	paths := []string{
+		filepath.Join("documents", "pdf", "EFTA00010724", "EFTA00010724.pdf"),
+		filepath.Join("documents", "pdf", "EFTA00010725", "EFTA00010725.pdf"),
+		filepath.Join("documents", "eml", "flight-log", "flight-log.eml"),
+	}
+	tests := []struct {
+		name    string
+		glob    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty glob", glob: "", want: paths},
+		{name: "prefix", glob: "EFTA*", want: paths[:2]},
+		{name: "single character", glob: "EFTA0001072?.pdf", want: paths[:2]},
+		{name: "class", glob: "EFTA0001072[5-9].pdf", want: paths[1:2]},
+		{name: "extension", glob: "*.eml", want: paths[2:]},
+		// Only the filename is matched, not the directories above it
+		{name: "directory", glob: "pdf*"},
+		{name: "no match", glob: "DOJ*"},
+		{name: "invalid", glob: "EFTA[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterByGlob(paths, tt.glob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterByGlob(%q) error = %v, wantErr %v", tt.glob, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterByGlob(%q) = %v, want %v", tt.glob, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByMeta(t *testing.T) {
+	root := t.TempDir()
+	var paths []string
+	for _, doc := range []struct{ name, metaYAML string }{
+		{"EFTA00010724", "tags: [flight-logs, needs-ocr]\nclass: exhibit\n"},
+		{"EFTA00010725", "tags: [flight-logs]\nclass: deposition\n"},
+		{"EFTA00010726", ""}, // never curated
+		{"EFTA00010727", "tags: [flight-logs\n"},
+	} {
+		dir := filepath.Join(root, "pdf", doc.name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, doc.name+".pdf")
+		if err := os.WriteFile(path, []byte("%PDF-1.4"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if doc.metaYAML != "" {
+			if err := os.WriteFile(filepath.Join(dir, "meta.yaml"), []byte(doc.metaYAML), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	tests := []struct {
+		name       string
+		tag, class string
+		want       []string
+	}{
+		{name: "no filter", want: paths},
+		{name: "tag", tag: "flight-logs", want: paths[:2]},
+		{name: "rarer tag", tag: "needs-ocr", want: paths[:1]},
+		{name: "class", class: "deposition", want: paths[1:2]},
+		{name: "tag and class", tag: "flight-logs", class: "exhibit", want: paths[:1]},
+		{name: "tag and other class", tag: "needs-ocr", class: "deposition"},
+		{name: "unknown tag", tag: "redacted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			// An unreadable meta.yaml is warned about and never matches
+			captureOutput(t, func() int {
+				got = filterByMeta(paths, tt.tag, tt.class)
+				return 0
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterByMeta(%q, %q) = %v, want %v", tt.tag, tt.class, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/lock"
+)
+
+// runCompactCatalog compacts the append-only catalog log of a tree, or
+// converts its catalog to the other format
+func runCompactCatalog(args []string) int {
+	flags := flag.NewFlagSet("compact-catalog", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree whose catalog to compact")
+	format := flags.String("format", "", "convert the catalog to this format: jsonl (append-only log) or json")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s compact-catalog [--from DIR] [--format jsonl|json]\n", os.Args[0])
+		return 1
+	}
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	// Compacting rewrites the log, so no run may append to it meanwhile
+	treeLock, err := lock.Acquire(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+	cat, err := catalog.Load(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+
+	if *format != "" && *format != cat.Format() {
+		if err := cat.SetFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := cat.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving catalog: %v\n", err)
+			return 1
+		}
+		notef("Converted the catalog to %s\n", cat.Path())
+		return 0
+	}
+	if cat.Format() != catalog.FormatJSONL {
+		notef("%s is rewritten on each save and needs no compaction\n", cat.Path())
+		return 0
+	}
+	before, after, err := cat.Compact()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error compacting catalog: %v\n", err)
+		return 1
+	}
+	notef("Compacted %s from %d to %d byte(s)\n", cat.Path(), before, after)
+	return 0
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/lock"
+)
+
+// runMerge merges another documents tree into ours, skipping documents whose
+// content we already have
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	into := flags.String("into", documentsDir, "documents tree to merge into")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s merge SOURCE-TREE [--into DIR]\n", os.Args[0])
+		return 1
+	}
+
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	treeLock, err := lock.Acquire(*into, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+
+	fmt.Fprintf(os.Stderr, "Merging %s into %s\n", flags.Arg(0), *into)
+	result, err := corpus.Merge(flags.Arg(0), *into, perms)
+	return reportCorpusResult(result, err)
+}
+
+// runSubset copies the documents selected by filename glob, tag, class or
+// Bates range into a new, self-contained documents tree
+func runSubset(args []string) int {
+	flags := flag.NewFlagSet("subset", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to copy from")
+	out := flags.String("out", "", "directory for the new documents tree (required)")
+	match := flags.String("match", "", "glob matched against document filenames, e.g. 'EFTA0001*'")
+	tag := flags.String("tag", "", "only copy documents whose meta.yaml lists this tag")
+	class := flags.String("class", "", "only copy documents whose meta.yaml records this class")
+	bates := flags.String("bates", "", "only copy documents named by a Bates number in FROM-TO, e.g. EFTA00010000-EFTA00019999")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if *out == "" || (*match == "" && *tag == "" && *class == "" && *bates == "") {
+		fmt.Fprintf(os.Stderr, "Usage: %s subset (--match GLOB | --tag TAG | --class CLASS | --bates FROM-TO)... --out DIR [--from DIR]\n", os.Args[0])
+		return 1
+	}
+	selected, err := selectSubset(*from, *match, *tag, *class, *bates)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	treeLock, err := lock.Acquire(*out, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+
+	fmt.Fprintf(os.Stderr, "Copying %d selected document(s) from %s to %s\n", len(selected), *from, *out)
+	result, err := corpus.Subset(*from, *out, func(rel string) bool {
+		return selected[rel]
+	}, perms)
+	return reportCorpusResult(result, err)
+}
+
+// selectSubset returns the documents under from (relative to it) whose
+// filename matches glob, whose meta.yaml lists tag and records class, and
+// whose filename is a Bates number within bates; each selector may be empty
+// to match every document
+func selectSubset(from, glob, tag, class, bates string) (map[string]bool, error) {
+	docs, err := corpus.Documents(from)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(docs))
+	for i, rel := range docs {
+		paths[i] = filepath.Join(from, rel)
+	}
+	if paths, err = filterByGlob(paths, glob); err != nil {
+		return nil, fmt.Errorf("invalid --match pattern: %w", err)
+	}
+	if bates != "" {
+		r, err := legal.ParseBatesRange(bates)
+		if err != nil {
+			return nil, err
+		}
+		paths = filterByBates(paths, r)
+	}
+	paths = filterByMeta(paths, tag, class)
+
+	selected := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return nil, err
+		}
+		selected[rel] = true
+	}
+	return selected, nil
+}
+
+// filterByBates returns the paths of documents whose filename, without its
+// extension, is a Bates number within r, such as EFTA00010724.pdf
+func filterByBates(paths []string, r legal.BatesRange) []string {
+	var matched []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if b, ok := legal.ParseBates(strings.TrimSuffix(name, filepath.Ext(name))); ok && r.Contains(b) {
+			matched = append(matched, path)
+		}
+	}
+	return matched
+}
+
+// reportCorpusResult prints what a merge or subset did and returns the exit code
+func reportCorpusResult(result *corpus.Result, err error) int {
+	if result != nil {
+		for _, rel := range result.Duplicates {
+			fmt.Fprintf(os.Stderr, "Skipped duplicate (same checksum already present): %s\n", rel)
+		}
+		for _, rel := range result.Conflicts {
+			fmt.Fprintf(os.Stderr, "Skipped conflict (different file already at this path): %s\n", rel)
+		}
+		fmt.Fprintf(os.Stderr, "\n--- Summary ---\n")
+		fmt.Fprintf(os.Stderr, "Copied: %d\n", len(result.Copied))
+		fmt.Fprintf(os.Stderr, "Duplicates skipped: %d\n", len(result.Duplicates))
+		if len(result.Conflicts) > 0 {
+			fmt.Fprintf(os.Stderr, "Conflicts: %d\n", len(result.Conflicts))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(result.Conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSelectSubset(t *testing.T) {
+	root := t.TempDir()
+	for rel, metaYAML := range map[string]string{
+		"pdf/EFTA00010724/EFTA00010724.pdf": "tags: [flight-logs]\nclass: exhibit\n",
+		"pdf/EFTA00010725/EFTA00010725.pdf": "tags: [flight-logs]\n",
+		"pdf/EFTA00020001/EFTA00020001.pdf": "class: deposition\n",
+		"pdf/flight-log/flight-log.pdf":     "tags: [flight-logs]\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(filepath.Dir(path), "meta.yaml"), []byte(metaYAML), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name                    string
+		glob, tag, class, bates string
+		want                    []string
+	}{
+		{name: "glob", glob: "EFTA0001*", want: []string{"pdf/EFTA00010724/EFTA00010724.pdf", "pdf/EFTA00010725/EFTA00010725.pdf"}},
+		{name: "tag", tag: "flight-logs", want: []string{"pdf/EFTA00010724/EFTA00010724.pdf", "pdf/EFTA00010725/EFTA00010725.pdf", "pdf/flight-log/flight-log.pdf"}},
+		{name: "class", class: "deposition", want: []string{"pdf/EFTA00020001/EFTA00020001.pdf"}},
+		{name: "bates", bates: "EFTA00010725-EFTA00029999", want: []string{"pdf/EFTA00010725/EFTA00010725.pdf", "pdf/EFTA00020001/EFTA00020001.pdf"}},
+		{name: "combined", tag: "flight-logs", bates: "EFTA00010000-EFTA00010724", want: []string{"pdf/EFTA00010724/EFTA00010724.pdf"}},
+		{name: "no match", glob: "DOJ*", tag: "flight-logs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectSubset(root, tt.glob, tt.tag, tt.class, tt.bates)
+			if err != nil {
+				t.Fatalf("selectSubset() error = %v", err)
+			}
+			var got []string
+			for rel := range selected {
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectSubset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []struct{ glob, bates string }{{glob: "["}, {bates: "EFTA00010725"}} {
+		if _, err := selectSubset(root, bad.glob, "", "", bad.bates); err == nil {
+			t.Errorf("selectSubset(%q, %q) accepted an invalid selector", bad.glob, bad.bates)
+		}
+	}
+}
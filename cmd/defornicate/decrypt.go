@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+
+	"defornicate-epstein-files/internal/config"
+)
+
+// ageExtension is appended to the names of encrypted exports
+const ageExtension = ".age"
+
+// runDecrypt decrypts exports that were encrypted at rest, using an age
+// identity file
+func runDecrypt(args []string) int {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	identityFile := flags.String("identity", os.Getenv("DEFORNICATOR_IDENTITY"), "age identity file holding the private key(s) (default: $DEFORNICATOR_IDENTITY)")
+	out := flags.String("out", "", "write the plaintext here, or - for stdout (default: the file name without .age; only with one file)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() == 0 || *identityFile == "" || (*out != "" && flags.NArg() > 1) {
+		fmt.Fprintf(os.Stderr, "Usage: %s decrypt --identity FILE [--out FILE|-] FILE.age...\n", os.Args[0])
+		return 1
+	}
+
+	file, err := os.Open(*identityFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	identities, err := age.ParseIdentities(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in %s: %v\n", *identityFile, err)
+		return 1
+	}
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, path := range flags.Args() {
+		dest := *out
+		if dest == "" {
+			if !strings.HasSuffix(path, ageExtension) {
+				fmt.Fprintf(os.Stderr, "Error: %s does not end in %s; name the output with --out\n", path, ageExtension)
+				failed++
+				continue
+			}
+			dest = strings.TrimSuffix(path, ageExtension)
+		}
+		plaintext, err := decryptFile(path, identities)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error decrypting %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		if dest == "-" {
+			os.Stdout.Write(plaintext)
+			continue
+		}
+		if err := perms.WriteFile(dest, plaintext); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", dest, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Decrypted %s to %s\n", path, dest)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// decryptFile reads and decrypts an age file. The whole plaintext is read
+// before anything is written, so a corrupted file never leaves a partial
+// output behind.
+func decryptFile(path string, identities []age.Identity) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := age.Decrypt(file, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// sealOutput encrypts an export of the given kind if the config asks for it,
+// returning the path and content to write: path with ".age" appended and the
+// ciphertext, or both unchanged
+func sealOutput(cfg *config.Config, kind, path string, content []byte) (string, []byte, error) {
+	recipients, err := cfg.EncryptionRecipients(kind)
+	if err != nil || recipients == nil {
+		return path, content, err
+	}
+	var sealed bytes.Buffer
+	w, err := age.Encrypt(&sealed, recipients...)
+	if err == nil {
+		if _, err = w.Write(content); err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	return path + ageExtension, sealed.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/entities"
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// runEntities exports every entity mention found in the JSON extraction
+// outputs of a documents tree as CSV
+func runEntities(args []string) int {
+	flags := flag.NewFlagSet("entities", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to read extraction outputs from")
+	out := flags.String("out", "", "write the CSV to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+
+	var mentions []entities.Mention
+	scanned, skipped, err := readExtractions(*from, func(rel string, extracted *extractor.ExtractedText) {
+		for _, page := range extracted.Content.Pages {
+			for _, m := range entities.Find(page.LineText(), page.PageNumber) {
+				m.Document = rel
+				mentions = append(mentions, m)
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding documents: %v\n", err)
+		return 1
+	}
+
+	var csv bytes.Buffer
+	if err := entities.WriteCSV(&csv, mentions); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		return 1
+	}
+	if *out == "" {
+		os.Stdout.Write(csv.Bytes())
+	} else {
+		cfg, _ := loadConfig()
+		perms, err := cfg.Permissions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			return 1
+		}
+		// Mentions list names, emails and phone numbers, so the CSV is one of
+		// the exports that can be encrypted at rest
+		path, content, err := sealOutput(cfg, config.OutputEntities, *out, csv.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := perms.WriteFile(path, content); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", path, err)
+			return 1
+		}
+		if path != *out {
+			fmt.Fprintf(os.Stderr, "Encrypted CSV saved to: %s\n", path)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d mention(s) from %d document(s)", len(mentions), scanned)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d without a JSON extraction output skipped)", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	return 0
+}
+
+// readExtractions calls visit with the JSON extraction output of every
+// document under from that has one, and the document's slash-separated path
+// relative to from, returning how many documents were read and how many were
+// skipped for want of a readable JSON output. Mentions and quotes are cited
+// by page, which only the JSON output records.
+func readExtractions(from string, visit func(rel string, extracted *extractor.ExtractedText)) (scanned, skipped int, err error) {
+	docs, err := extractor.FindDocuments(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	jsonExt := extractor.New()
+	for _, docPath := range docs {
+		outputPath := jsonExt.FindOutput(docPath)
+		if outputPath == "" {
+			skipped++
+			continue
+		}
+		extracted, err := extractor.ReadExtracted(outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", outputPath, err)
+			skipped++
+			continue
+		}
+		rel, err := filepath.Rel(from, docPath)
+		if err != nil {
+			rel = docPath
+		}
+		visit(filepath.ToSlash(rel), extracted)
+		scanned++
+	}
+	return scanned, skipped, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/evidence"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// runEvidenceExport packages one document with its extraction outputs,
+// checksums and provenance into a zip
+func runEvidenceExport(args []string) int {
+	flags := flag.NewFlagSet("evidence-export", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree the document belongs to")
+	out := flags.String("out", "", "zip file to write (default: {name}-evidence.zip in the current directory)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s evidence-export [--from DIR] [--out FILE] DOCUMENT\n", os.Args[0])
+		return 1
+	}
+
+	docPath := pathutil.ResolveDocumentPathIn(*from, flags.Arg(0))
+	if _, err := os.Stat(docPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	cat, err := catalog.Load(*from, pathutil.Permissions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+	rel, err := filepath.Rel(*from, docPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(docPath)
+	}
+	entry, _ := cat.Get(docPath)
+	attempts := make(map[string][]catalog.Attempt)
+	if entry != nil {
+		for _, url := range entry.URLs {
+			if history := cat.History(url); len(history) > 0 {
+				attempts[url] = history
+			}
+		}
+	}
+
+	zipPath := *out
+	if zipPath == "" {
+		base := filepath.Base(docPath)
+		zipPath = strings.TrimSuffix(base, filepath.Ext(base)) + "-evidence.zip"
+	}
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	// Build the zip in memory so a failure never leaves a partial package
+	var buf bytes.Buffer
+	files, err := evidence.Export(&buf, docPath, rel, entry, attempts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	zipPath, content, err := sealOutput(cfg, config.OutputEvidence, zipPath, buf.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := perms.WriteFile(zipPath, content); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write %s: %v\n", zipPath, err)
+		return 1
+	}
+	if entry == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not in %s, so the package has no source URL or download history\n", docPath, cat.Path())
+	}
+	fmt.Fprintf(os.Stderr, "Evidence package saved to: %s (%d files)\n", zipPath, len(files))
+	return 0
+}
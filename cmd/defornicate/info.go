@@ -0,0 +1,394 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/entities"
+	"defornicate-epstein-files/internal/exif"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/table"
+)
+
+// runInfo prints what is known about a URL or a document. For a URL that is
+// the document it was downloaded to and the history of fetch attempts, so
+// chronically failing sources can be spotted and reported; for a document
+// (a path, filename or ID such as a Bates number) its catalog entry,
+// checksum, curated metadata and extraction outputs.
+func runInfo(args []string) int {
+	flags := flag.NewFlagSet("info", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree whose catalog to read")
+	asJSON := flags.Bool("json", false, "print everything known as JSON")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s info [--from DIR] [--json] URL|FILE|ID\n", os.Args[0])
+		return 1
+	}
+
+	cat, err := catalog.Load(*from, pathutil.Permissions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+	if !strings.Contains(flags.Arg(0), "://") {
+		docPath, err := findDocument(*from, flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return printDocumentInfo(cat, docPath, *asJSON)
+	}
+
+	url := downloader.CanonicalURL(flags.Arg(0))
+	doc, downloaded := cat.ByURL(url)
+	attempts := cat.History(url)
+	if !downloaded && len(attempts) == 0 {
+		fmt.Fprintf(os.Stderr, "%s is not in %s\n", url, cat.Path())
+		return 1
+	}
+	if *asJSON {
+		out := struct {
+			URL      string            `json:"url"`
+			Document *catalog.Document `json:"document,omitempty"`
+			Attempts []catalog.Attempt `json:"attempts"`
+		}{URL: url, Attempts: attempts}
+		if downloaded {
+			out.Document = doc
+		}
+		if out.Attempts == nil {
+			out.Attempts = []catalog.Attempt{}
+		}
+		return printJSON(out)
+	}
+
+	p := stdoutPainter()
+	fmt.Printf("URL:          %s\n", url)
+	if downloaded {
+		fmt.Printf("Document:     %s\n", filepath.Join(*from, filepath.FromSlash(doc.Path)))
+		fmt.Printf("SHA256:       %s\n", doc.SHA256)
+		if doc.Pages > 0 {
+			fmt.Printf("Pages:        %d\n", doc.Pages)
+		}
+		fmt.Printf("Downloaded:   %s\n", doc.DownloadedAt.Local().Format(time.RFC3339))
+	} else {
+		fmt.Printf("Document:     %s\n", p.Paint("not downloaded", table.Yellow))
+	}
+	if len(attempts) == 0 {
+		return 0
+	}
+
+	var failed int
+	var bytes int64
+	var total time.Duration
+	for _, a := range attempts {
+		if a.Failed() {
+			failed++
+		}
+		bytes += a.Bytes
+		total += time.Duration(a.DurationMS) * time.Millisecond
+	}
+	fmt.Printf("Attempts:     %d (%d failed, %.0f%% success)\n", len(attempts), failed, 100*float64(len(attempts)-failed)/float64(len(attempts)))
+	fmt.Printf("Average time: %s\n", (total / time.Duration(len(attempts))).Round(time.Millisecond))
+	fmt.Printf("Bytes:        %d\n", bytes)
+	if headers := cat.LastHeaders(url); headers != nil {
+		fmt.Printf("\nResponse headers (last successful fetch):\n")
+		for _, name := range downloader.ResponseHeaders {
+			if value, ok := headers[name]; ok {
+				fmt.Printf("  %-15s %s\n", name+":", value)
+			}
+		}
+	}
+	fmt.Printf("\nHistory:\n")
+	t := table.New("TIME", "STATUS", "BYTES", "DURATION", "RESULT").AlignRight(1, 2, 3)
+	for _, a := range attempts {
+		status := "-"
+		if a.Status != 0 {
+			status = fmt.Sprintf("%d", a.Status)
+		}
+		result := table.Cell{Text: "ok", Style: table.Green}
+		if a.Failed() {
+			result = table.Cell{Text: a.Error, Style: table.Red}
+		}
+		if a.TransferBytes != 0 {
+			result.Text += fmt.Sprintf(" (%d bytes transferred)", a.TransferBytes)
+		}
+		if a.FallbackUserAgent != "" {
+			result.Text += " (after a 403, with a browser User-Agent)"
+		}
+		t.AddCells(table.Cell{Text: a.At.Local().Format(time.RFC3339)}, table.Cell{Text: status}, table.Cell{Text: fmt.Sprint(a.Bytes)},
+			table.Cell{Text: (time.Duration(a.DurationMS) * time.Millisecond).String()}, result)
+	}
+	printTable(t)
+	return 0
+}
+
+// findDocument resolves a document given as a path, a filename under root or
+// an ID: a filename without its extension, such as a Bates number
+func findDocument(root, arg string) (string, error) {
+	if path := pathutil.ResolveDocumentPathIn(root, arg); fileExists(path) {
+		return path, nil
+	}
+	found, err := corpus.Find(root, arg)
+	if err != nil {
+		return "", err
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no document %q in %s", arg, root)
+	case 1:
+		return filepath.Join(root, filepath.FromSlash(found[0])), nil
+	default:
+		return "", fmt.Errorf("%q names %d documents (%s); give the path of one", arg, len(found), strings.Join(found, ", "))
+	}
+}
+
+// documentInfo is everything known about a document, as printed by info
+type documentInfo struct {
+	Path       string            `json:"path"`
+	Size       int64             `json:"size"`
+	SHA256     string            `json:"sha256"`
+	Modified   bool              `json:"modified,omitempty"` // checksum differs from the one catalogued at download
+	Catalog    *catalog.Document `json:"catalog,omitempty"`
+	Attempts   int               `json:"attempts,omitempty"` // fetches of its URLs
+	Failed     int               `json:"failed_attempts,omitempty"`
+	Curated    *meta.Meta        `json:"curated,omitempty"`
+	EXIF       *exif.EXIF        `json:"exif,omitempty"`
+	Extraction *extractionInfo   `json:"extraction,omitempty"`
+	Outputs    []string          `json:"outputs"` // every extraction output, versions, parts and shards included
+}
+
+// extractionInfo summarizes the latest extraction output of a document
+type extractionInfo struct {
+	Output         string            `json:"output"`
+	Format         string            `json:"format"`
+	ExtractedAt    *time.Time        `json:"extracted_at,omitempty"`
+	TotalPages     int               `json:"total_pages,omitempty"`
+	PagesExtracted int               `json:"pages_extracted,omitempty"`
+	BlankPages     int               `json:"blank_pages,omitempty"`
+	Words          int               `json:"words,omitempty"`
+	Tokens         int               `json:"tokens,omitempty"`
+	FirstBates     string            `json:"first_bates,omitempty"`
+	LastBates      string            `json:"last_bates,omitempty"`
+	Case           *legal.CaseInfo   `json:"case,omitempty"`
+	CoverSheet     *legal.CoverSheet `json:"cover_sheet,omitempty"`
+}
+
+// printDocumentInfo prints what is known about the document at docPath
+func printDocumentInfo(cat *catalog.Catalog, docPath string, asJSON bool) int {
+	info, err := documentDetails(cat, docPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if asJSON {
+		return printJSON(info)
+	}
+
+	p := stdoutPainter()
+	fmt.Printf("Document:     %s\n", info.Path)
+	fmt.Printf("Size:         %d bytes\n", info.Size)
+	if info.Modified {
+		fmt.Printf("SHA256:       %s %s\n", info.SHA256, p.Paint("(changed since download: catalogued as "+info.Catalog.SHA256+")", table.Red))
+	} else {
+		fmt.Printf("SHA256:       %s\n", info.SHA256)
+	}
+	if doc := info.Catalog; doc != nil {
+		for _, url := range doc.URLs {
+			fmt.Printf("URL:          %s\n", url)
+		}
+		fmt.Printf("Downloaded:   %s\n", doc.DownloadedAt.Local().Format(time.RFC3339))
+		if doc.Pages > 0 {
+			fmt.Printf("Pages:        %d\n", doc.Pages)
+		}
+		if info.Attempts > 0 {
+			fmt.Printf("Fetches:      %d (%d failed; see info URL for the history)\n", info.Attempts, info.Failed)
+		}
+		if doc.Parent != "" {
+			fmt.Printf("Container:    %s\n", doc.Parent)
+		}
+		for _, child := range doc.Embedded {
+			fmt.Printf("Embedded:     %s\n", child)
+		}
+	} else {
+		fmt.Printf("Catalog:      %s\n", p.Paint("not catalogued (not downloaded into this tree)", table.Yellow))
+	}
+	if m := info.Curated; m != nil {
+		if m.Title != "" {
+			fmt.Printf("Title:        %s\n", m.Title)
+		}
+		if len(m.Tags) > 0 {
+			fmt.Printf("Tags:         %s\n", strings.Join(m.Tags, ", "))
+		}
+		if m.Notes != "" {
+			fmt.Printf("Notes:        %s\n", strings.ReplaceAll(strings.TrimSpace(m.Notes), "\n", "\n              "))
+		}
+	}
+	if x := info.EXIF; x != nil {
+		if camera := strings.TrimSpace(x.Make + " " + x.Model); camera != "" {
+			fmt.Printf("Camera:       %s\n", camera)
+		}
+		if x.Taken != "" {
+			fmt.Printf("Taken:        %s\n", x.Taken)
+		}
+		if x.GPS != nil {
+			fmt.Printf("GPS:          %.7f, %.7f\n", x.GPS.Latitude, x.GPS.Longitude)
+		}
+	}
+
+	ext := info.Extraction
+	if ext == nil {
+		fmt.Printf("Extraction:   %s\n", p.Paint("not extracted", table.Yellow))
+		return 0
+	}
+	fmt.Printf("Extraction:   %s (%s)\n", ext.Output, ext.Format)
+	if ext.ExtractedAt != nil {
+		fmt.Printf("Extracted:    %s\n", ext.ExtractedAt.Local().Format(time.RFC3339))
+	}
+	if ext.TotalPages > 0 {
+		fmt.Printf("Text pages:   %d of %d (%d blank), %d words (%d tokens)\n", ext.PagesExtracted, ext.TotalPages, ext.BlankPages, ext.Words, ext.Tokens)
+	}
+	if ext.FirstBates != "" {
+		fmt.Printf("Bates range:  %s – %s\n", ext.FirstBates, ext.LastBates)
+	}
+	if c := ext.Case; c != nil {
+		if len(c.CaseNumbers) > 0 {
+			fmt.Printf("Case:         %s\n", strings.Join(c.CaseNumbers, ", "))
+		}
+		if c.Caption != "" {
+			fmt.Printf("Caption:      %s\n", c.Caption)
+		}
+	}
+	if c := ext.CoverSheet; c != nil {
+		fmt.Printf("Cover sheet:  %s\n", strings.Join(nonEmpty(c.ProducingParty, c.Date, c.Designation), ", "))
+	}
+	if len(info.Outputs) > 1 {
+		fmt.Printf("Outputs:\n")
+		for _, output := range info.Outputs {
+			fmt.Printf("  %s\n", output)
+		}
+	}
+	return 0
+}
+
+// documentDetails gathers what the tree records about the document at
+// docPath: its catalog entry, meta.yaml and extraction outputs
+func documentDetails(cat *catalog.Catalog, docPath string) (*documentInfo, error) {
+	stat, err := os.Stat(docPath)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := corpus.FileChecksum(docPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", docPath, err)
+	}
+	info := &documentInfo{Path: docPath, Size: stat.Size(), SHA256: hex.EncodeToString(sum[:]), Outputs: []string{}}
+	if doc, ok := cat.Get(docPath); ok {
+		info.Catalog = doc
+		info.Modified = doc.SHA256 != info.SHA256
+		for _, url := range doc.URLs {
+			for _, a := range cat.History(url) {
+				info.Attempts++
+				if a.Failed() {
+					info.Failed++
+				}
+			}
+		}
+	}
+	if info.Curated, err = meta.Load(docPath); err != nil {
+		return nil, err
+	}
+	if exif.IsImage(docPath) {
+		info.EXIF, _ = exif.Read(docPath)
+	}
+	if outputs, err := corpus.Outputs(docPath); err == nil {
+		info.Outputs = append(info.Outputs, outputs...)
+	}
+
+	for _, format := range []string{"json", "markdown", "plain"} {
+		output := extractor.NewWithFormat(format).FindOutput(docPath)
+		if output == "" {
+			continue
+		}
+		info.Extraction = &extractionInfo{Output: output, Format: format}
+		if format == "json" {
+			extracted, err := extractor.ReadExtracted(output)
+			if err != nil {
+				return nil, err
+			}
+			summarizeExtraction(info.Extraction, extracted)
+		}
+		break
+	}
+	return info, nil
+}
+
+// summarizeExtraction fills in the page counts, Bates range and case details
+// of a JSON extraction output
+func summarizeExtraction(info *extractionInfo, extracted *extractor.ExtractedText) {
+	md := extracted.Metadata
+	info.ExtractedAt = &md.ExtractedAt
+	info.TotalPages = md.TotalPages
+	info.PagesExtracted = md.PagesExtracted
+	info.BlankPages = md.BlankPages
+	info.Case = md.Case
+	info.CoverSheet = md.CoverSheet
+	for _, page := range extracted.Content.Pages {
+		info.Words += page.WordCount
+		info.Tokens += page.TokenCount
+		for _, mention := range entities.Find(page.Text, page.PageNumber) {
+			if mention.Type != entities.TypeBates {
+				continue
+			}
+			if info.FirstBates == "" {
+				info.FirstBates = mention.Entity
+			}
+			info.LastBates = mention.Entity
+		}
+	}
+}
+
+// nonEmpty returns the values that are not empty
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != "" {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// catalogAttempt converts a downloader fetch attempt to its catalog record
+func catalogAttempt(a downloader.Attempt) catalog.Attempt {
+	attempt := catalog.Attempt{
+		At:         a.Start.UTC(),
+		Status:     a.Status,
+		Bytes:      a.Bytes,
+		DurationMS: a.Duration.Milliseconds(),
+	}
+	if a.TransferBytes != a.Bytes {
+		attempt.TransferBytes = a.TransferBytes
+	}
+	if a.Err != nil {
+		attempt.Error = a.Err.Error()
+	}
+	attempt.FallbackUserAgent = a.FallbackUserAgent
+	attempt.Headers = a.Headers
+	return attempt
+}
@@ -0,0 +1,939 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/budget"
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/crawl"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/hooks"
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/pipeline"
+	"defornicate-epstein-files/internal/scratch"
+	"defornicate-epstein-files/internal/sink"
+	"defornicate-epstein-files/internal/source"
+	"defornicate-epstein-files/internal/table"
+)
+
+const (
+	configFile = "epstein-files-urls.json"
+	// defaultConcurrency is the default number of parallel extraction workers
+	defaultConcurrency = 4
+)
+
+// documentsDir is the documents tree commands work on: "documents", or the
+// corpus root chosen with --root or $DEFORNICATOR_ROOT
+var documentsDir = downloader.DefaultDocumentsDir
+
+// rootChosen reports whether documentsDir was chosen rather than defaulted
+var rootChosen bool
+
+// findConfigFile searches for the config file in multiple locations:
+// 1. The corpus root, when one was chosen with --root
+// 2. Current working directory
+// 3. Directory where the executable is located
+// 4. Parent directory of the executable (for bin/ structure)
+func findConfigFile() string {
+	// A corpus root keeps its own sources and settings
+	if rootChosen {
+		if path := filepath.Join(documentsDir, configFile); fileExists(path) {
+			return path
+		}
+	}
+
+	// Try current working directory next
+	if _, err := os.Stat(configFile); err == nil {
+		return configFile
+	}
+
+	// Get the executable's directory
+	execPath, err := os.Executable()
+	if err == nil {
+		execDir := filepath.Dir(execPath)
+		
+		// Try in executable's directory
+		configPath := filepath.Join(execDir, configFile)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath
+		}
+
+		// Try parent directory (for bin/ structure)
+		parentDir := filepath.Dir(execDir)
+		configPath = filepath.Join(parentDir, configFile)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath
+		}
+	}
+
+	// Fall back to current directory (will fail gracefully if not found)
+	return configFile
+}
+
+// loadConfig loads the config file if one can be found. The returned config
+// is never nil (an empty config is returned alongside any error) so callers
+// can always read settings from it.
+func loadConfig() (*config.Config, error) {
+	configPath := findConfigFile()
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return &config.Config{}, err
+	}
+
+	// Loading is lenient; point at strict validation if anything looks off
+	if issues, err := config.Validate(configPath); err == nil && len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s has %d problem(s); run '%s config validate' for details\n", configPath, len(issues), os.Args[0])
+	}
+	return cfg, nil
+}
+
+// stepErrorPrefix is the message printed before a pipeline step's error
+var stepErrorPrefix = map[string]string{
+	pipeline.StepDownload: "Error downloading document",
+	pipeline.StepVerify:   "Error",
+	pipeline.StepUnpack:   "Error unpacking document",
+	pipeline.StepExtract:  "Error extracting text",
+	pipeline.StepAnalyze:  "Error analyzing document",
+	pipeline.StepExport:   "Error saving extracted text",
+	pipeline.StepSplit:    "Error splitting document",
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run is the main application logic, separated for testing
+func run() int {
+	args, err := parseRoot(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "extract":
+			// "extract" is the default command; accept it explicitly as well
+			return runExtract(args[1:])
+		case "merge":
+			return runMerge(args[1:])
+		case "subset":
+			return runSubset(args[1:])
+		case "sources":
+			return runSources(args[1:])
+		case "config":
+			return runConfig(args[1:])
+		case "entities":
+			return runEntities(args[1:])
+		case "quotes":
+			return runQuotes(args[1:])
+		case "xrefs":
+			return runXrefs(args[1:])
+		case "search":
+			return runSearch(args[1:])
+		case "sync":
+			// sync is extract limited to the inputs that are new to the catalog
+			return runExtract(append([]string{"--new"}, args[1:]...))
+		case "speech":
+			return runSpeech(args[1:])
+		case "info":
+			return runInfo(args[1:])
+		case "verify":
+			return runVerify(args[1:])
+		case "verify-log":
+			return runVerifyLog(args[1:])
+		case "snapshot":
+			return runSnapshot(args[1:])
+		case "evidence-export":
+			return runEvidenceExport(args[1:])
+		case "decrypt":
+			return runDecrypt(args[1:])
+		case "compact-catalog":
+			return runCompactCatalog(args[1:])
+		case "vacuum":
+			return runVacuum(args[1:])
+		case "revalidate":
+			return runRevalidate(args[1:])
+		}
+	}
+	return runExtract(args)
+}
+
+// parseRoot takes a leading --root DIR (or --root=DIR) off the command line,
+// falling back to $DEFORNICATOR_ROOT, and points every command at that
+// corpus root. Each root has its own catalog, lock, snapshots and crawl
+// schedule, and may hold its own epstein-files-urls.json.
+func parseRoot(args []string) ([]string, error) {
+	root := os.Getenv("DEFORNICATOR_ROOT")
+	if len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--root" || arg == "-root":
+			if len(args) < 2 || args[1] == "" {
+				return nil, fmt.Errorf("--root needs a directory")
+			}
+			root, args = args[1], args[2:]
+		case strings.HasPrefix(arg, "--root=") || strings.HasPrefix(arg, "-root="):
+			root, args = arg[strings.Index(arg, "=")+1:], args[1:]
+			if root == "" {
+				return nil, fmt.Errorf("--root needs a directory")
+			}
+		}
+	}
+	if root != "" {
+		documentsDir, rootChosen = filepath.Clean(root), true
+	}
+	return args, nil
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// applyProfile applies the download profile named with --profile to cfg,
+// replacing its download settings, or else fills the settings the config
+// leaves unset from its own profile. It reports an unknown profile and
+// returns false.
+func applyProfile(cfg *config.Config, name string) bool {
+	if name != "" {
+		if err := cfg.ApplyProfile(name, true); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --profile: %v\n", err)
+			return false
+		}
+	} else if err := cfg.ApplyProfile(cfg.Profile, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// downloaderOptions returns the downloader options the config sets, shared
+// by every command making requests: identification, request spacing,
+// retries, the skip list and the circuit breaker
+func downloaderOptions(cfg *config.Config, perms pathutil.Permissions) (downloader.Options, error) {
+	skip, err := downloader.NewSkipList(cfg.Skip.URLs, cfg.Skip.Checksums)
+	if err != nil {
+		return downloader.Options{}, err
+	}
+	failures, cooldown, err := cfg.CircuitBreaker()
+	if err != nil {
+		return downloader.Options{}, err
+	}
+	var breaker *downloader.Breaker
+	if failures > 0 {
+		breaker = downloader.NewBreaker(failures, cooldown)
+	}
+	requestInterval, err := cfg.RequestSpacing()
+	if err != nil {
+		return downloader.Options{}, err
+	}
+	retries, err := cfg.Retries()
+	if err != nil {
+		return downloader.Options{}, err
+	}
+	return downloader.Options{
+		DNSServer:      cfg.DNSServer,
+		IPPreference:   cfg.IPPreference,
+		Permissions:    perms,
+		SFTPKeyFile:    cfg.SFTPKeyFile,
+		SFTPKnownHosts: cfg.SFTPKnownHosts,
+		UserAgent:      cfg.UserAgent,
+		Contact:        cfg.Contact,
+		From:           cfg.From,
+		RetryForbidden: cfg.RetryForbiddenEnabled(),
+
+		ChecksumManifests: cfg.ChecksumManifests,
+		Skip:              skip,
+		Breaker:           breaker,
+		RequestInterval:   requestInterval,
+		Retry: downloader.RetryPolicy{
+			MaxAttempts: retries.Attempts,
+			BaseDelay:   retries.Delay,
+			Jitter:      retries.Jitter,
+			Statuses:    retries.Statuses,
+		},
+		OnRetry: func(url string, attempt int, wait time.Duration, err error) {
+			notef("Retrying %s in %s (attempt %d failed: %v)\n", url, wait.Round(100*time.Millisecond), attempt, err)
+		},
+	}, nil
+}
+
+// runExtract downloads (if needed) and extracts text from the given inputs,
+// falling back to the config file when no inputs are given
+func runExtract(args []string) int {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	flags.Usage = func() { printUsage(nil) }
+	allPending := flags.Bool("all-pending", false, "extract every downloaded document that has no extraction output yet")
+	match := flags.String("match", "", "only extract documents under the documents directory whose filename matches this glob (e.g. 'EFTA*')")
+	tag := flags.String("tag", "", "only extract documents under the documents directory whose meta.yaml lists this tag (e.g. needs-ocr)")
+	class := flags.String("class", "", "only extract documents under the documents directory whose meta.yaml records this class (e.g. deposition)")
+	concurrency := flags.Int("concurrency", defaultConcurrency, "number of documents to extract in parallel (with --all-pending, --match, --tag or --class)")
+	debugDump := flags.Bool("debug-dump", false, "also write each PDF page's raw content stream and font map to {name}.debug/")
+	split := flags.Bool("split", false, "detect concatenated documents (Bates resets, cover pages, blank separators) and also save one output per part")
+	gracePeriod := flags.Duration("grace-period", defaultGracePeriod, "time allowed to finish the current document after SIGTERM/SIGINT")
+	shardFlag := flags.String("shard", "", "only process the inputs in shard i of n (e.g. 2/8), to split a run across machines")
+	sample := flags.Int("sample", 0, "extract only N evenly spaced pages of each document given and estimate quality and time for all of them, writing nothing")
+	newOnly := flags.Bool("new", false, "only fetch and extract the inputs not in the catalog yet, reporting which are new (what sync does)")
+	dryRun := flags.Bool("dry-run", false, "with --new, only report the new inputs")
+	concat := flags.Bool("concat", false, "with several inputs, also write the text of every document to stdout, one after another (a single input's text always is)")
+	flags.BoolVar(&quiet, "quiet", false, "only report errors and warnings, not progress")
+	reproducible := flags.Bool("reproducible", false, "stamp outputs with the document's modification time (or $SOURCE_DATE_EPOCH) and checksum instead of the current time, so re-extracting gives identical bytes")
+	compactJSON := flags.Bool("compact-json", false, "write JSON outputs without indentation, for smaller files")
+	ocr := flags.Bool("ocr", false, "OCR PDF pages without a text layer (scans) with tesseract, rendering them with pdftoppm")
+	profile := flags.String("profile", "", "download profile bundling concurrency, request spacing, retries and User-Agent: "+strings.Join(config.ProfileNames(), ", ")+" (default: profile from the config)")
+	downloadConcurrency := flags.Int("download-concurrency", 0, "download up to N remote inputs in parallel before extracting them one at a time (default: download_concurrency from the config, or 1)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	var shard source.Shard
+	if *shardFlag != "" {
+		var err error
+		if shard, err = source.ParseShard(*shardFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --shard: %v\n", err)
+			return 1
+		}
+	}
+
+	stop := watchShutdown(*gracePeriod)
+
+	// Try to load config file first; settings fall back to defaults without it
+	cfg, cfgErr := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	if !applyProfile(cfg, *profile) {
+		return 1
+	}
+	memoryLimit, err := cfg.MemoryLimit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	// Shared by downloads and batch extraction; nil (unlimited) when unset
+	memory := budget.New(memoryLimit)
+	// Temporary files live in a directory of their own, removed on the way
+	// out (or by the next run, if this one dies)
+	tmp, err := scratch.New(cfg.ScratchDir, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer tmp.Remove()
+	stop.AtExit(func() { tmp.Remove() })
+	maxOutputSize, err := cfg.OutputSizeLimit()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	if !extractor.ValidCompression(cfg.OutputCompression) {
+		fmt.Fprintf(os.Stderr, "Error in config: invalid output_compression %q (expected \"gzip\" or \"zstd\")\n", cfg.OutputCompression)
+		return 1
+	}
+	// Drawn only while documents are extracted one at a time
+	progress := newProgressBar()
+	var fallbacks []extractor.Fallback
+	for _, fc := range cfg.ExtractionFallbacks {
+		if !extractor.ValidFallback(fc.Backend) {
+			fmt.Fprintf(os.Stderr, "Error in config: unknown extraction backend %q (expected \"pdftotext\" or \"tesseract\")\n", fc.Backend)
+			return 1
+		}
+		fallbacks = append(fallbacks, extractor.Fallback{Backend: fc.Backend, MinWords: fc.MinWords})
+	}
+	// Scanned pages without a text layer are OCRed last, after any
+	// configured fallback had its chance
+	if *ocr {
+		if err := extractor.CheckPageOCR(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --ocr: %v\n", err)
+			return 1
+		}
+		if !slices.ContainsFunc(fallbacks, func(f extractor.Fallback) bool { return f.Backend == extractor.BackendTesseract }) {
+			fallbacks = append(fallbacks, extractor.Fallback{Backend: extractor.BackendTesseract})
+		}
+	}
+	if cfg.OCRImages {
+		if err := extractor.CheckImageOCR(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: ocr_images: %v\n", err)
+			return 1
+		}
+	}
+	ext := extractor.NewWithOptions(extractor.Options{
+		Compression:      cfg.OutputCompression,
+		Permissions:      perms,
+		Fallbacks:        fallbacks,
+		ImageOCR:         cfg.OCRImages,
+		MergeOCR:         cfg.MergeOCR,
+		StripLineNumbers: cfg.StripLineNumbers,
+		StripBoilerplate: cfg.StripBoilerplate,
+		FindCitations:    cfg.FindCitations,
+		MaxOutputSize:    maxOutputSize,
+		WriteOnce:        cfg.WriteOnce,
+		Reproducible:     *reproducible || cfg.Reproducible,
+		CompactJSON:      *compactJSON || cfg.CompactJSON,
+		TikaURL:          cfg.TikaURL,
+		Scratch:          tmp,
+		// Extract, export and split each open the document
+		Readers:  extractor.NewReaderCache(extractor.DefaultReaderCacheSize),
+		Progress: progress.Update,
+		Warn:     progress.Warn,
+	})
+	// A dry run writes nothing, so it needs neither the lock nor the catalog
+	if *sample > 0 {
+		return runSample(ext, flags.Args(), *sample)
+	}
+
+	// Keep a second run from writing to the same tree and catalog
+	treeLock, err := lock.Acquire(documentsDir, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+	cat, err := catalog.Load(documentsDir, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+	// An existing catalog is converted on its next save
+	if cfg.CatalogFormat != "" {
+		if err := cat.SetFormat(cfg.CatalogFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			return 1
+		}
+	}
+	sinks, err := sink.FromConfig(cfg.Sinks, perms, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	// A stdout sink replaces the plain text otherwise printed to stdout
+	printText := !sink.HasStdout(cfg.Sinks)
+	// Hook commands write to stderr, keeping stdout for the extracted text
+	eventHooks, err := hooks.FromConfig(cfg.Hooks, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	provenanceLog, err := openProvenance(cfg, documentsDir, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *allPending || *match != "" || *tag != "" || *class != "" {
+		return runBatch(ext, cat, documentsDir, batchOptions{
+			pendingOnly: *allPending,
+			match:       *match,
+			tag:         *tag,
+			class:       *class,
+			concurrency: *concurrency,
+			debugDump:   *debugDump,
+			split:       *split,
+			sinks:       sinks,
+			hooks:       eventHooks,
+			provenance:  provenanceLog,
+			budget:      memory,
+			shard:       shard,
+			perms:       perms,
+		}, stop)
+	}
+
+	// Initialize components
+	opts, err := downloaderOptions(cfg, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	opts.Budget, opts.Scratch = memory, tmp
+	opts.KnownChecksum = func(url string) string {
+		if doc, ok := cat.ByURL(downloader.CanonicalURL(url)); ok {
+			return doc.SHA256
+		}
+		return ""
+	}
+	opts.StoredURLs = func(path string) []string {
+		if doc, ok := cat.Get(path); ok {
+			return doc.URLs
+		}
+		return nil
+	}
+	opts.OnAttempt = func(a downloader.Attempt) {
+		if a.FallbackUserAgent != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s refused our User-Agent (403 Forbidden); retried with the browser User-Agent %q", a.URL, a.FallbackUserAgent)
+			if a.Err != nil {
+				fmt.Fprintf(os.Stderr, ", which failed too")
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		cat.RecordAttempt(downloader.CanonicalURL(a.URL), catalogAttempt(a))
+	}
+	dl, err := downloader.NewWithOptions(documentsDir, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring downloader: %v\n", err)
+		return 1
+	}
+
+	var items []source.Item
+
+	// Check for a source preset or pattern first (both expand to multiple URLs)
+	patternStr := cfg.Pattern
+	if patternStr == "" && cfg.PDFPattern != "" {
+		patternStr = cfg.PDFPattern // Legacy support
+	}
+	if cfg.Source != "" {
+		preset, ok := config.LookupPreset(cfg.Source)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown source preset %q (run '%s sources' to list presets)\n", cfg.Source, os.Args[0])
+			return 1
+		}
+		items, err = source.Preset(dl, preset).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error expanding preset pattern: %v\n", err)
+			return 1
+		}
+		notef("Using source preset %q from epstein-files-urls.json, expanded to %d URL(s)\n", preset.Name, len(items))
+	} else if dockets := cfg.CourtListener.Dockets; len(dockets) > 0 {
+		items, err = source.CourtListener(dl, source.CourtListenerOptions{
+			Dockets:   dockets,
+			Court:     cfg.CourtListener.Court,
+			Token:     cfg.CourtListenerToken(),
+			UserAgent: downloader.UserAgent(cfg.UserAgent, cfg.Contact),
+		}).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing CourtListener dockets: %v\n", err)
+			return 1
+		}
+		notef("Using %d RECAP document(s) from %d CourtListener docket(s) in epstein-files-urls.json\n", len(items), len(dockets))
+	} else if pages := cfg.CrawlPages; len(pages) > 0 {
+		items, err = source.Crawl(dl, pages, cfg.DirectoryExtensions).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		notef("Using %d document(s) linked from %d index page(s) in epstein-files-urls.json\n", len(items), len(pages))
+	} else if patternStr != "" {
+		items, err = source.Pattern(dl, patternStr).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error expanding pattern: %v\n", err)
+			return 1
+		}
+		notef("Using document pattern from epstein-files-urls.json, expanded to %d URL(s)\n", len(items))
+	} else if urls := cfg.GetInputs(); len(urls) > 0 {
+		items, err = source.InputsWithExtensions(dl, urls, cfg.DirectoryExtensions).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		notef("Using %d document URL(s) from epstein-files-urls.json\n", len(items))
+	}
+
+	// Fall back to command-line arguments if no config URLs
+	if len(items) == 0 {
+		if flags.NArg() == 0 {
+			printUsage(cfgErr)
+			return 1
+		}
+		items, err = source.InputsWithExtensions(dl, flags.Args(), cfg.DirectoryExtensions).Resolve()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	// The same document can be listed more than once (in urls and a pattern,
+	// or twice on the command line); fetch and extract it only once
+	items, duplicates := source.Dedupe(items)
+	if duplicates > 0 {
+		notef("Skipping %d duplicate input(s)\n", duplicates)
+	}
+	// A grown range is compared against the catalog, so only the inputs that
+	// appeared since the last run are walked
+	if *newOnly {
+		delta := source.Diff(items, func(item source.Item) bool {
+			if item.Remote() {
+				_, _, ok := cat.Lookup(item.URL)
+				return ok
+			}
+			_, ok := cat.Get(item.Path)
+			return ok
+		})
+		printDelta(delta, items)
+		if *dryRun || len(delta.New) == 0 {
+			return 0
+		}
+		items = delta.New
+	}
+	if shard.Count > 1 {
+		total := len(items)
+		items = shard.Items(items)
+		notef("Shard %s: processing %d of %d input(s)\n", shard, len(items), total)
+	}
+
+	// Pace very large crawls over the configured window, resuming the
+	// schedule saved by an interrupted run
+	window, interval, err := cfg.CrawlSchedule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	var plan *crawl.Plan
+	if window > 0 {
+		inputs := make([]string, len(items))
+		for i, item := range items {
+			inputs[i] = item.Input
+		}
+		plan, err = crawl.Load(documentsDir, inputs, window, interval, perms)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if plan.Resumed() {
+			notef("Resuming crawl: %d of %d input(s) already processed\n", plan.Completed(), len(items))
+		}
+		items = items[plan.Completed():]
+		notef("Pacing %d download(s) to finish by %s (one every %s, at least %s apart)\n",
+			len(items), plan.Deadline().Local().Format(time.RFC1123), plan.Interval(time.Now()).Round(time.Second), interval)
+	}
+
+	// Download the remote inputs in parallel ahead of the pipeline, which
+	// then takes their results instead of fetching them one at a time.
+	// Paced crawls keep downloading one at a time.
+	workers := *downloadConcurrency
+	if workers == 0 {
+		workers = cfg.DownloadConcurrency
+	}
+	prefetched := make(map[string]*downloader.Result)
+	if workers > 1 && plan != nil {
+		fmt.Fprintf(os.Stderr, "Warning: download concurrency has no effect when crawl_window paces the downloads\n")
+	} else if workers > 1 {
+		var urls []string
+		seen := make(map[string]bool)
+		for _, item := range items {
+			if !item.Remote() || seen[item.Input] || dl.Skipped(item.Input) != nil {
+				continue
+			}
+			seen[item.Input] = true
+			if _, _, ok := cat.Lookup(item.URL); !ok {
+				urls = append(urls, item.Input)
+			}
+		}
+		if len(urls) > 1 {
+			notef("Downloading %d document(s), up to %d at a time\n", len(urls), workers)
+			done := 0
+			results := dl.DownloadAll(urls, workers, stop.Requested, func(r downloader.Result) {
+				done++
+				if r.Saved() {
+					notef("[%d/%d] Downloaded: %s\n", done, len(urls), r.URL)
+				} else {
+					notef("[%d/%d] Failed (reported below): %s\n", done, len(urls), r.URL)
+				}
+			})
+			for i := range results {
+				if !errors.Is(results[i].Err, downloader.ErrCanceled) {
+					prefetched[results[i].URL] = &results[i]
+				}
+			}
+		}
+	}
+
+	// Build the processing pipeline and report progress from its hooks
+	steps := []pipeline.Step{
+		pipeline.DownloadStep(dl, cat),
+		pipeline.VerifyStep(cat),
+		pipeline.UnpackStep(ext, cat),
+		pipeline.CurateStep(cat, perms),
+		pipeline.ExtractStep(ext),
+		pipeline.AnalyzeStep(pipeline.CoverSheetAnalyzer(cat), pipeline.ReviewAnalyzer(cat), pipeline.EXIFAnalyzer(cat)),
+		pipeline.ExportStep(ext, cat, sinks...),
+	}
+	if *split {
+		steps = append(steps, pipeline.SplitStep(ext, cat, sinks...))
+	}
+	p := pipeline.New(steps...)
+	p.Before(func(step string, doc *pipeline.Document) {
+		switch step {
+		case pipeline.StepDownload:
+			if !doc.Item.Remote() || doc.Prefetched != nil || dl.Skipped(doc.Item.Input) != nil {
+				break
+			}
+			if _, _, ok := cat.Lookup(doc.Item.URL); !ok {
+				notef("Downloading document from URL: %s\n", doc.Item.Input)
+			}
+		case pipeline.StepExtract:
+			// Dump raw page objects first, so they are available even when
+			// extraction fails
+			if *debugDump {
+				writeDebugDump(ext, doc.Path)
+			}
+		}
+	})
+	p.After(func(step string, doc *pipeline.Document, err error) {
+		progress.Clear()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", stepErrorPrefix[step], err)
+			return
+		}
+		switch step {
+		case pipeline.StepDownload:
+			if doc.Skipped != "" {
+				notef("Skipping: %s\n", doc.Skipped)
+			} else if doc.Cached {
+				notef("Already downloaded by an earlier run (see %s), skipping download: %s\n", cat.Path(), doc.Path)
+			} else if doc.Unchanged {
+				notef("Document already exists with same checksum, skipping download: %s\n", doc.Path)
+			} else if doc.Downloaded {
+				notef("Document saved to: %s\n", doc.Path)
+			}
+		case pipeline.StepVerify:
+			if doc.Downloaded && doc.PageCount > 0 {
+				notef("Download verified: %d page(s)\n", doc.PageCount)
+			}
+		case pipeline.StepUnpack:
+			if len(doc.Embedded) > 0 {
+				notef("Unpacked %d document(s) into %s\n", len(doc.Embedded), extractor.UnpackDir(doc.Path))
+			}
+		case pipeline.StepExport:
+			for _, output := range doc.Outputs {
+				notef("Extracted text saved to: %s\n", output)
+			}
+		case pipeline.StepSplit:
+			if len(doc.Parts) > 0 {
+				notef("Split into %d logical document(s):\n", len(doc.Parts))
+				for _, part := range doc.Parts {
+					notef("  %s\n", part)
+				}
+			}
+		}
+	})
+	if eventHooks != nil {
+		p.After(eventHooks.AfterHook(warnHook))
+	}
+	if provenanceLog != nil {
+		p.After(provenanceLog.AfterHook(documentsDir, warnHook))
+	}
+
+	// Process each input
+	if !quiet {
+		progress.Start()
+	}
+	var hasErrors bool
+	var successCount, errorCount, skippedCount, suspendedCount, processedCount int
+	for i, item := range items {
+		// Stop between documents once a shutdown has been requested
+		if stop.Requested() {
+			break
+		}
+		// Only real fetches are paced; local and catalogued inputs, and those
+		// of hosts the circuit breaker suspended, cost nothing
+		if plan != nil && item.Remote() && dl.Skipped(item.Input) == nil && dl.Suspended(item.Input) == nil {
+			if _, _, ok := cat.Lookup(item.URL); !ok {
+				if !stop.Sleep(plan.Wait(time.Now())) {
+					break
+				}
+				plan.Requested(time.Now())
+			}
+		}
+		processedCount++
+		if len(items) > 1 {
+			notef("\n--- Processing %d of %d ---\n", i+1, len(items))
+		}
+
+		doc := &pipeline.Document{Item: item, Prefetched: prefetched[item.Input]}
+		runErr := p.Run(doc)
+		if err := cat.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if plan != nil {
+			if err := plan.Advance(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if runErr != nil {
+			hasErrors = true
+			errorCount++
+			var suspended *downloader.CircuitOpenError
+			if errors.As(runErr, &suspended) {
+				suspendedCount++
+			}
+			continue
+		}
+		if doc.Skipped != "" {
+			skippedCount++
+			continue
+		}
+		successCount++
+		// Errors were reported by the hooks
+		runEmbedded(p, doc, func(_ *pipeline.Document, err error) {
+			if err != nil {
+				hasErrors = true
+				errorCount++
+			}
+		})
+
+		// Also output the extracted text to stdout; the texts of several
+		// documents only run together when asked to
+		if !printText || (len(items) > 1 && !*concat) {
+			continue
+		}
+		if len(items) > 1 {
+			notef("--- Text from %s ---\n", doc.Path)
+		}
+		fmt.Print(doc.Text)
+		if len(items) > 1 && i < len(items)-1 {
+			fmt.Print("\n\n")
+		}
+	}
+
+	// Print summary if processing multiple files
+	if len(items) > 1 {
+		notef("\n--- Summary ---\n")
+		notef("Total processed: %d\n", processedCount)
+		notef("Successful: %d\n", successCount)
+		if skippedCount > 0 {
+			notef("Skipped (on the skip list): %d\n", skippedCount)
+		}
+		if errorCount > 0 {
+			fmt.Fprintf(os.Stderr, "Errors: %d\n", errorCount)
+		}
+		if suspendedCount > 0 {
+			fmt.Fprintf(os.Stderr, "  of which not requested (host suspended after repeated failures): %d\n", suspendedCount)
+		}
+		if stats := dl.RetryStats(); stats.Retries > 0 {
+			notef("Retries: %d (%d download(s) recovered, %d still failing after %d attempts)\n", stats.Retries, stats.Recovered, stats.Exhausted, opts.Retry.MaxAttempts)
+		}
+	}
+	if stop.Requested() && processedCount < len(items) {
+		fmt.Fprintf(os.Stderr, "Shutdown requested, %d input(s) not processed\n", len(items)-processedCount)
+	}
+
+	if hasErrors {
+		return 1
+	}
+	return 0
+}
+
+// runSources lists the built-in source presets
+func runSources(args []string) int {
+	flags := flag.NewFlagSet("sources", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the presets as JSON")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if *asJSON {
+		type preset struct {
+			Name        string   `json:"name"`
+			Description string   `json:"description"`
+			Patterns    []string `json:"patterns"`
+			Source      string   `json:"source"`
+		}
+		presets := []preset{}
+		for _, p := range config.Presets() {
+			presets = append(presets, preset{p.Name, p.Description, p.Patterns, p.Source})
+		}
+		return printJSON(presets)
+	}
+	if len(config.Presets()) == 0 {
+		fmt.Fprintln(os.Stderr, `No source presets are built in yet; download releases with "pattern" or "urls" in the config file`)
+		return 0
+	}
+	t := table.New("NAME", "DESCRIPTION", "SOURCE").StyleColumn(0, table.Cyan)
+	for _, preset := range config.Presets() {
+		t.Add(preset.Name, preset.Description, preset.Source)
+	}
+	printTable(t)
+	return 0
+}
+
+// runConfig handles config subcommands (currently only "validate")
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" || len(args) > 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s config validate [CONFIG-FILE]\n", os.Args[0])
+		return 1
+	}
+	configPath := findConfigFile()
+	if len(args) == 2 {
+		configPath = args[1]
+	}
+
+	issues, err := config.Validate(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", configPath, issue)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(issues))
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s is valid\n", configPath)
+	return 0
+}
+
+func printUsage(configErr error) {
+	fmt.Fprintf(os.Stderr, "Usage: %s [--root DIR] [extract] [document-file-path-or-url ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s extract [--all-pending] [--match GLOB] [--tag TAG] [--class CLASS] [--concurrency N] [--shard I/N]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s extract --sample N DOCUMENT...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s sync [--dry-run] [document-file-path-or-url ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s merge SOURCE-TREE [--into DIR]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s subset [--match GLOB] [--tag TAG] [--class CLASS] [--bates FROM-TO] --out DIR [--from DIR]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s entities [--from DIR] [--out FILE]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s quotes [--from DIR] [--out FILE] [--speaker NAME]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s xrefs [--from DIR] [--out FILE | --json] [--document PATH]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s search [--from DIR] [--limit N] [--ignore-case] [--fold] [--stem] [--highlight DIR] [--export FILE.md|FILE.csv] [--server URL] [--json] QUERY\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s speech [--from DIR] [--match GLOB] [--stdout] [DOCUMENT ...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s info [--from DIR] [--json] URL|FILE|ID\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s verify [--from DIR] [--workers N] [--rate BYTES] [--every DURATION] [--notify]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s verify-log [--from DIR] [--public-key FILE]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s snapshot create|list|diff [--from DIR] ...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s evidence-export [--from DIR] [--out FILE] DOCUMENT\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s decrypt --identity FILE [--out FILE|-] FILE.age...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s compact-catalog [--from DIR] [--format jsonl|json]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s vacuum [--from DIR] [--dry-run]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s revalidate [--from DIR] [--workers N] [--profile NAME]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s sources [--json]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s config validate [CONFIG-FILE]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  --root DIR (before the command, or $DEFORNICATOR_ROOT) works on the corpus in DIR instead of %s/\n", downloader.DefaultDocumentsDir)
+	fmt.Fprintf(os.Stderr, "  If no argument is provided, will use urls (or url) from epstein-files-urls.json\n")
+	fmt.Fprintf(os.Stderr, "  If epstein-files-urls.json doesn't exist or has no URLs, argument(s) are required\n")
+	fmt.Fprintf(os.Stderr, "  --all-pending extracts every document under %s/ that has no extraction output yet\n", documentsDir)
+	fmt.Fprintf(os.Stderr, "  --debug-dump writes each PDF page's raw content stream and font map to {name}.debug/\n")
+	fmt.Fprintf(os.Stderr, "  --match extracts documents under %s/ whose filename matches the glob\n", documentsDir)
+	fmt.Fprintf(os.Stderr, "  --split also saves one output per logical document found in a concatenated file\n")
+	fmt.Fprintf(os.Stderr, "  --sample N extracts only N evenly spaced pages and estimates text quality and time for the whole document, writing nothing\n")
+	fmt.Fprintf(os.Stderr, "  sync (or extract --new) fetches only the inputs not in the catalog yet, reporting the delta; --dry-run only reports it\n")
+	fmt.Fprintf(os.Stderr, "  --reproducible stamps outputs from the document instead of the clock, so re-extracting gives identical bytes\n")
+	fmt.Fprintf(os.Stderr, "  --compact-json writes JSON outputs without indentation\n")
+	fmt.Fprintf(os.Stderr, "  --ocr OCRs PDF pages without a text layer with tesseract (needs pdftoppm and tesseract)\n")
+	fmt.Fprintf(os.Stderr, "  --download-concurrency N downloads up to N remote inputs in parallel before extracting them\n")
+	fmt.Fprintf(os.Stderr, "  --profile NAME selects a download profile: %s\n", strings.Join(config.ProfileNames(), ", "))
+	fmt.Fprintf(os.Stderr, "  --concat writes the text of every document to stdout when several are given; by default only a single document's is\n")
+	fmt.Fprintf(os.Stderr, "  --quiet reports only errors and warnings on stderr; stdout carries nothing but extracted text\n")
+	fmt.Fprintf(os.Stderr, "  --shard I/N processes only the inputs in shard I of N, so N machines can split a run and merge their trees afterwards\n")
+	fmt.Fprintf(os.Stderr, "\nExample: %s document.pdf\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Example: %s https://example.com/document.pdf\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Example: %s sftp://user@archive.example.org/exports/document.pdf\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Example: %s doc1.pdf doc2.docx file.txt\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Example: %s extract --all-pending --match 'EFTA*'\n", os.Args[0])
+	if configErr != nil {
+		fmt.Fprintf(os.Stderr, "\nConfig file error: %v\n", configErr)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"defornicate-epstein-files/internal/table"
+)
+
+// quiet suppresses the progress and status messages written with notef;
+// errors and warnings are written regardless
+var quiet bool
+
+// notef writes a progress or status message to stderr, unless --quiet was
+// given. Stdout only ever carries extracted text or the output asked for.
+func notef(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// stdoutPainter colors output to stdout when it is a terminal and NO_COLOR
+// is unset
+func stdoutPainter() table.Painter {
+	return table.Painter{Color: table.ColorEnabled(os.Stdout)}
+}
+
+// printTable writes t to stdout, colored when stdout is a terminal
+func printTable(t *table.Table) {
+	t.Render(os.Stdout, stdoutPainter())
+}
+
+// printJSON writes v to stdout as indented JSON, for --json output
+func printJSON(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected, and returns
+// what it wrote to each and its exit code
+func captureOutput(t *testing.T, fn func() int) (stdout, stderr string, code int) {
+	t.Helper()
+	dir := t.TempDir()
+	outFile, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	code = fn()
+	os.Stdout, os.Stderr = savedOut, savedErr
+	outFile.Close()
+	errFile.Close()
+
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	return string(out), string(errOut), code
+}
+
+// useTree points the commands at a fresh documents tree, from a working
+// directory without a config file
+func useTree(t *testing.T) string {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	saved := documentsDir
+	documentsDir = filepath.Join(t.TempDir(), "documents")
+	t.Cleanup(func() { documentsDir = saved })
+	if err := os.MkdirAll(documentsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return documentsDir
+}
+
+func TestExtractOutputStreams(t *testing.T) {
+	root := useTree(t)
+	var inputs []string
+	for _, body := range []string{"Flight schedule for March", "Passenger list attached"} {
+		path := filepath.Join(root, strings.Fields(body)[0]+".eml")
+		msg := "From: a@example.com\r\nSubject: " + body + "\r\nContent-Type: text/plain\r\n\r\n" + body + "\r\n"
+		if err := os.WriteFile(path, []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, path)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout []string // texts on stdout, in order; none means stdout stays empty
+		quiet      bool
+	}{
+		{"single input", []string{inputs[0]}, []string{"Flight schedule"}, false},
+		{"several inputs", inputs, nil, false},
+		{"concat", append([]string{"--concat"}, inputs...), []string{"Flight schedule", "Passenger list"}, false},
+		{"quiet", append([]string{"--quiet"}, inputs...), nil, true},
+		{"quiet concat", append([]string{"--quiet", "--concat"}, inputs...), []string{"Flight schedule", "Passenger list"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := captureOutput(t, func() int { return runExtract(tt.args) })
+			if code != 0 {
+				t.Fatalf("runExtract() = %d, stderr:\n%s", code, stderr)
+			}
+			rest := stdout
+			for _, want := range tt.wantStdout {
+				i := strings.Index(rest, want)
+				if i < 0 {
+					t.Fatalf("stdout = %q, want %q in order", stdout, tt.wantStdout)
+				}
+				rest = rest[i+len(want):]
+			}
+			if len(tt.wantStdout) == 0 && stdout != "" {
+				t.Errorf("stdout = %q, want nothing", stdout)
+			}
+			// Progress, headers and the summary never reach stdout
+			for _, status := range []string{"---", "Summary", "Total processed"} {
+				if strings.Contains(stdout, status) {
+					t.Errorf("stdout carries status message %q: %q", status, stdout)
+				}
+			}
+			if tt.quiet && stderr != "" {
+				t.Errorf("stderr = %q, want nothing with --quiet", stderr)
+			}
+			if !tt.quiet && stderr == "" {
+				t.Error("stderr is empty, want progress messages")
+			}
+		})
+	}
+}
+
+func TestStatusMessagesGoToStderr(t *testing.T) {
+	quiet = false
+	useTree(t)
+	for name, run := range map[string]func() int{
+		"compact-catalog": func() int { return runCompactCatalog(nil) },
+		"verify-log":      func() int { return runVerifyLog(nil) },
+	} {
+		stdout, stderr, code := captureOutput(t, run)
+		if code != 0 {
+			t.Fatalf("%s = %d, stderr:\n%s", name, code, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("%s wrote %q to stdout, want nothing", name, stdout)
+		}
+		if stderr == "" {
+			t.Errorf("%s reported nothing on stderr", name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressWidth is the width of the bar in characters
+	progressWidth = 30
+	// progressInterval is the least time between redraws
+	progressInterval = 100 * time.Millisecond
+)
+
+// progressBar draws the page progress of the document being extracted on a
+// single stderr line, fed by the extractor's ProgressFunc. It draws nothing
+// unless stderr is a terminal, and only between Start and Clear, so
+// extractions running in parallel (where lines would interleave) can share
+// the extractor without one.
+type progressBar struct {
+	mu       sync.Mutex
+	terminal bool
+	active   bool
+	drawn    bool      // a line is on screen
+	last     time.Time // when it was drawn
+}
+
+// newProgressBar returns a progress bar for stderr
+func newProgressBar() *progressBar {
+	info, err := os.Stderr.Stat()
+	terminal := err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+	return &progressBar{terminal: terminal}
+}
+
+// Start lets the bar draw
+func (b *progressBar) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active = b.terminal
+}
+
+// Update redraws the bar for done of total pages of filePath; it implements
+// extractor.ProgressFunc. Redraws are throttled, except for the last page.
+func (b *progressBar) Update(filePath string, done, total int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.active || total <= 0 || (done < total && time.Since(b.last) < progressInterval) {
+		return
+	}
+	filled := progressWidth * done / total
+	bar := strings.Repeat("#", filled) + strings.Repeat(" ", progressWidth-filled)
+	fmt.Fprintf(os.Stderr, "\r\x1b[KExtracting %s [%s] %d/%d page(s)", filepath.Base(filePath), bar, done, total)
+	b.drawn = true
+	b.last = time.Now()
+}
+
+// Warn reports a problem that did not stop the extraction of filePath on a
+// line of its own; it implements extractor.WarnFunc
+func (b *progressBar) Warn(filePath string, err error) {
+	b.Clear()
+	fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", filePath, err)
+}
+
+// Clear removes the bar from the screen, so other messages start on a clean
+// line
+func (b *progressBar) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.drawn {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		b.drawn = false
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/entities"
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// runQuotes exports the quotes attributed to named speakers in the JSON
+// extraction outputs of a documents tree as CSV
+func runQuotes(args []string) int {
+	flags := flag.NewFlagSet("quotes", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to read extraction outputs from")
+	out := flags.String("out", "", "write the CSV to this file instead of stdout")
+	speaker := flags.String("speaker", "", "only export quotes of speakers whose name contains this (case-insensitive)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s quotes [--from DIR] [--out FILE] [--speaker NAME]\n", os.Args[0])
+		return 1
+	}
+	want := strings.ToLower(*speaker)
+
+	var quotes []entities.Quote
+	scanned, skipped, err := readExtractions(*from, func(rel string, extracted *extractor.ExtractedText) {
+		for _, page := range extracted.Content.Pages {
+			for _, q := range entities.FindQuotes(page.LineText(), page.PageNumber) {
+				if want != "" && !strings.Contains(strings.ToLower(q.Speaker), want) && !strings.Contains(strings.ToLower(q.Canonical), want) {
+					continue
+				}
+				q.Document = rel
+				quotes = append(quotes, q)
+			}
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding documents: %v\n", err)
+		return 1
+	}
+
+	var csv bytes.Buffer
+	if err := entities.WriteQuotesCSV(&csv, quotes); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		return 1
+	}
+	if *out == "" {
+		os.Stdout.Write(csv.Bytes())
+	} else {
+		cfg, _ := loadConfig()
+		perms, err := cfg.Permissions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			return 1
+		}
+		// Quotes put words in named people's mouths, so like mentions they
+		// can be encrypted at rest
+		path, content, err := sealOutput(cfg, config.OutputQuotes, *out, csv.Bytes())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := perms.WriteFile(path, content); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", path, err)
+			return 1
+		}
+		if path != *out {
+			fmt.Fprintf(os.Stderr, "Encrypted CSV saved to: %s\n", path)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d quote(s) from %d document(s)", len(quotes), scanned)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d without a JSON extraction output skipped)", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	return 0
+}
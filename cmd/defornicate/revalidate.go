@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/table"
+)
+
+// runRevalidate sends a conditional request for every catalogued source URL
+// and reports which documents changed upstream, downloading nothing
+func runRevalidate(args []string) int {
+	flags := flag.NewFlagSet("revalidate", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree whose source URLs to revalidate")
+	workers := flags.Int("workers", 4, "number of requests in flight at once")
+	profile := flags.String("profile", "", "download profile setting request spacing and the circuit breaker: "+strings.Join(config.ProfileNames(), ", ")+" (default: profile from the config)")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 0 || *workers < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s revalidate [--from DIR] [--workers N] [--profile NAME]\n", os.Args[0])
+		return 1
+	}
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	if !applyProfile(cfg, *profile) {
+		return 1
+	}
+	// Requests are spaced by request_interval and suspended by the breaker
+	// like downloads, however many workers are in flight
+	opts, err := downloaderOptions(cfg, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	dl, err := downloader.NewWithOptions(*from, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring downloader: %v\n", err)
+		return 1
+	}
+	cat, err := catalog.Load(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+
+	urls := cat.URLs()
+	queue := make(chan string)
+	go func() {
+		for _, url := range urls {
+			queue <- url
+		}
+		close(queue)
+	}()
+
+	p := stdoutPainter()
+	statusStyle := map[string]table.Style{
+		downloader.RevalidateUnchanged: table.Green,
+		downloader.RevalidateChanged:   table.Red,
+		downloader.RevalidateGone:      table.Red,
+		downloader.RevalidateUnknown:   table.Yellow,
+		"skipped":                      table.Yellow,
+		"error":                        table.Red,
+	}
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	report := func(url, status, detail string) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[status]++
+		line := fmt.Sprintf("%s %s", p.Paint(fmt.Sprintf("%-10s", status), statusStyle[status]), url)
+		if detail != "" {
+			line += " (" + detail + ")"
+		}
+		fmt.Println(line)
+	}
+	var wg sync.WaitGroup
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range queue {
+				result, err := dl.Revalidate(url, cat.LastHeaders(url))
+				var skipped *downloader.SkipError
+				if errors.As(err, &skipped) {
+					report(url, "skipped", "")
+					continue
+				}
+				if err != nil {
+					report(url, "error", err.Error())
+					continue
+				}
+				var detail string
+				if doc, ok := cat.ByURL(url); ok && result.Status != downloader.RevalidateUnchanged {
+					detail = doc.Path
+					if result.Status == downloader.RevalidateChanged && result.Headers["ETag"] != "" {
+						detail += ", now ETag " + result.Headers["ETag"]
+					}
+				}
+				report(url, result.Status, detail)
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Fprintf(os.Stderr, "\n--- Summary ---\n")
+	fmt.Fprintf(os.Stderr, "Revalidated %d URL(s)\n", len(urls))
+	for _, status := range []string{downloader.RevalidateUnchanged, downloader.RevalidateChanged, downloader.RevalidateGone, downloader.RevalidateUnknown, "skipped", "error"} {
+		if counts[status] > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d\n", status, counts[status])
+		}
+	}
+	if counts[downloader.RevalidateChanged]+counts[downloader.RevalidateGone]+counts["error"] > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/table"
+)
+
+// runSample extracts n evenly spaced pages of each document and reports the
+// quality of their text and how long extracting every page would take, so
+// scans that need OCR can be spotted before hours go into extracting them
+func runSample(ext *extractor.Extractor, args []string, n int) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s extract --sample N DOCUMENT...\n", os.Args[0])
+		return 1
+	}
+	p := stdoutPainter()
+	status := 0
+	for i, arg := range args {
+		docPath, err := findDocument(documentsDir, arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			status = 1
+			continue
+		}
+		report, err := ext.Sample(docPath, n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error sampling %s: %v\n", docPath, err)
+			status = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s: sampled %d of %d page(s) in %s\n", p.Paint(docPath, table.Cyan), len(report.Pages), report.TotalPages, msDuration(report.ElapsedMS))
+		t := table.New("PAGE", "WORDS", "QUALITY", "BACKEND", "NOTE").AlignRight(0).AlignRight(1).AlignRight(2)
+		for _, page := range report.Pages {
+			note := table.Cell{}
+			switch {
+			case page.Blank:
+				note = table.Cell{Text: "blank", Style: table.Dim}
+			case page.Words == 0:
+				note = table.Cell{Text: "no text", Style: table.Red}
+			case page.NeedsOCR:
+				note = table.Cell{Text: "poor text", Style: table.Yellow}
+			}
+			t.AddCells(table.Cell{Text: strconv.Itoa(page.PageNumber)}, table.Cell{Text: strconv.Itoa(page.Words)},
+				table.Cell{Text: fmt.Sprintf("%.0f%%", page.Quality*100)}, table.Cell{Text: page.Backend}, note)
+		}
+		printTable(t)
+		fmt.Printf("Estimated quality: %.0f%% (%d sampled page(s) need OCR)\n", report.Quality*100, report.NeedsOCR)
+		fmt.Printf("Estimated time for all %d page(s): %s\n", report.TotalPages, msDuration(report.EstimatedMS))
+		if report.ScanLikely() {
+			fmt.Println(p.Paint("Most sampled pages have no usable text: the document likely needs OCR (ocr_images, or an extraction fallback)", table.Yellow))
+		}
+	}
+	return status
+}
+
+// msDuration formats a duration in milliseconds for people
+func msDuration(ms int64) time.Duration {
+	d := time.Duration(ms) * time.Millisecond
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/highlight"
+	"defornicate-epstein-files/internal/search"
+	"defornicate-epstein-files/internal/table"
+)
+
+// runSearch prints the pages of extracted documents that match a query
+func runSearch(args []string) int {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to search")
+	limit := flags.Int("limit", 0, "stop after this many hits (0 for no limit)")
+	ignoreCase := flags.Bool("ignore-case", false, "match words regardless of case (\"Dubin\" finds \"DUBIN\")")
+	fold := flags.Bool("fold", false, "match words regardless of diacritics (\"Medecin\" finds \"Médecin\")")
+	stem := flags.Bool("stem", false, "match English inflections of words (\"flight\" finds \"flights\")")
+	highlightDir := flags.String("highlight", "", "also write copies of the matched PDFs with the hits highlighted into this directory")
+	asJSON := flags.Bool("json", false, "print the hits as JSON")
+	export := flags.String("export", "", "also write the hits, with links to their pages, as a report to this .md or .csv file")
+	serverURL := flags.String("server", "", "link each hit to its page on a defornicate-server serving the tree at this URL, e.g. http://localhost:8080")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s search [--from DIR] [--limit N] [--ignore-case] [--fold] [--stem] [--highlight DIR] [--export FILE.md|FILE.csv] [--server URL] [--json] QUERY\n", os.Args[0])
+		return 1
+	}
+
+	var reportFormat string
+	if *export != "" {
+		var err error
+		if reportFormat, err = search.ReportFormat(*export); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --export: %v\n", err)
+			return 1
+		}
+	}
+
+	analyzer := search.Analyzer{Lowercase: *ignoreCase, Fold: *fold, Stem: *stem}
+	query, err := search.ParseWithAnalyzer(strings.Join(flags.Args(), " "), analyzer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in query: %v\n", err)
+		return 1
+	}
+	result, err := search.Tree(*from, query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching %s: %v\n", *from, err)
+		return 1
+	}
+
+	hits := result.Hits
+	if *limit > 0 && len(hits) > *limit {
+		hits = hits[:*limit]
+	}
+	// Each hit links to its page: on the server if one is given, otherwise
+	// at the document's path from the current directory
+	link := func(hit search.Hit) string {
+		if *serverURL != "" {
+			return hit.URL(*serverURL)
+		}
+		return filepath.ToSlash(filepath.Join(*from, filepath.FromSlash(hit.Anchor())))
+	}
+	if *asJSON {
+		type jsonHit struct {
+			Document string `json:"document"`
+			Page     int    `json:"page"`
+			Snippet  string `json:"snippet"`
+			Link     string `json:"link"`
+		}
+		out := make([]jsonHit, len(hits))
+		for i, hit := range hits {
+			out[i] = jsonHit{hit.Document, hit.Page, hit.Snippet, link(hit)}
+		}
+		printJSON(out)
+	} else if len(hits) > 0 {
+		t := table.New("LINK", "SNIPPET").StyleColumn(0, table.Cyan)
+		for _, hit := range hits {
+			t.Add(link(hit), hit.Snippet)
+		}
+		printTable(t)
+	}
+
+	if *export != "" {
+		report := &search.Report{Query: strings.Join(flags.Args(), " "), Root: *from, Generated: time.Now(), Searched: result.Searched}
+		if err := writeReport(*export, reportFormat, report, hits); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if *highlightDir != "" {
+		if err := writeHighlights(*from, *highlightDir, hits, query, strings.Join(flags.Args(), " ")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d matching page(s) in %d searched document(s)", len(result.Hits), result.Searched)
+	if result.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d without a JSON extraction output skipped)", result.Skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	if len(result.Hits) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeHighlights writes a copy of each PDF with hits into dir, at the same
+// path relative to dir as the document's relative to root, with the query's
+// matches on the hit pages highlighted
+func writeHighlights(root, dir string, hits []search.Hit, query *search.Query, note string) error {
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Hits come in document order, so each document's pages are adjacent
+	var docs []string
+	pages := make(map[string][]int)
+	for _, hit := range hits {
+		if len(pages[hit.Document]) == 0 {
+			docs = append(docs, hit.Document)
+		}
+		pages[hit.Document] = append(pages[hit.Document], hit.Page)
+	}
+
+	jsonExt := extractor.New()
+	var written int
+	for _, rel := range docs {
+		docPath := filepath.Join(root, filepath.FromSlash(rel))
+		if downloader.GetFileType(docPath) != "pdf" {
+			continue
+		}
+		extracted, err := extractor.ReadExtracted(jsonExt.FindOutput(docPath))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not highlighting %s: %v\n", rel, err)
+			continue
+		}
+		marks := highlight.Find(extracted, pages[rel], query)
+		if len(marks) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: not highlighting %s: its extraction output has no line positions (re-extract it)\n", rel)
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := perms.MkdirAll(filepath.Dir(dst)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+		}
+		if err := highlight.WriteFile(docPath, dst, marks, note, perms); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not highlighting %s: %v\n", rel, err)
+			continue
+		}
+		written++
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d highlighted PDF(s) to %s\n", written, dir)
+	return nil
+}
+
+// writeReport writes the hits as a report to path, linking each hit to its
+// page: at the document's source URL if the catalog knows it, otherwise at
+// the document's path relative to the report
+func writeReport(path, format string, report *search.Report, hits []search.Hit) error {
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	cat, err := catalog.Load(report.Root, perms)
+	if err != nil {
+		return fmt.Errorf("failed to load catalog: %w", err)
+	}
+	reportDir, _ := filepath.Abs(filepath.Dir(path))
+	for _, hit := range hits {
+		docPath := filepath.Join(report.Root, filepath.FromSlash(hit.Document))
+		link := ""
+		if doc, ok := cat.Get(docPath); ok && len(doc.URLs) > 0 {
+			link = doc.URLs[0]
+		} else if abs, err := filepath.Abs(docPath); err == nil {
+			if rel, err := filepath.Rel(reportDir, abs); err == nil {
+				link = filepath.ToSlash(rel)
+			}
+		}
+		if link != "" && downloader.GetFileType(docPath) == "pdf" {
+			link += fmt.Sprintf("#page=%d", hit.Page)
+		}
+		report.Hits = append(report.Hits, search.ReportHit{Hit: hit, Link: link})
+	}
+
+	var out bytes.Buffer
+	if format == search.ReportCSV {
+		err = search.WriteReportCSV(&out, report)
+	} else {
+		err = search.WriteReportMarkdown(&out, report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := perms.WriteFile(path, out.Bytes()); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Report of %d hit(s) saved to: %s\n", len(report.Hits), path)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultGracePeriod is how long the current document may take to finish
+	// after SIGTERM/SIGINT before the process exits anyway
+	defaultGracePeriod = 30 * time.Second
+	// exitInterrupted is the exit code used when the grace period expires
+	exitInterrupted = 130
+)
+
+// exit ends the process when the grace period runs out; tests replace it
+var exit = os.Exit
+
+// shutdown tracks termination requests so processing loops can stop between
+// documents instead of dying mid-write
+type shutdown struct {
+	requested atomic.Bool
+	done      chan struct{} // closed when shutdown is requested
+	mu        sync.Mutex
+	atExit    []func() // run before a forced exit
+}
+
+// watchShutdown installs SIGTERM/SIGINT handlers. The first signal marks
+// shutdown as requested; the process is then force-exited when the grace
+// period expires or a second signal arrives.
+func watchShutdown(grace time.Duration) *shutdown {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
+	return watchSignals(sigCh, grace)
+}
+
+// watchSignals handles the termination signals received on sigCh
+func watchSignals(sigCh <-chan os.Signal, grace time.Duration) *shutdown {
+	s := &shutdown{done: make(chan struct{})}
+	go func() {
+		sig := <-sigCh
+		s.requested.Store(true)
+		close(s.done)
+		fmt.Fprintf(os.Stderr, "\nReceived %s, finishing current document before exiting (grace period %s)\n", sig, grace)
+
+		select {
+		case <-time.After(grace):
+			fmt.Fprintf(os.Stderr, "Grace period expired, exiting without finishing current document\n")
+		case sig = <-sigCh:
+			fmt.Fprintf(os.Stderr, "Received second %s, exiting immediately\n", sig)
+		}
+		s.mu.Lock()
+		for _, fn := range s.atExit {
+			fn()
+		}
+		exit(exitInterrupted)
+	}()
+
+	return s
+}
+
+// Requested reports whether a termination signal has been received
+func (s *shutdown) Requested() bool {
+	return s != nil && s.requested.Load()
+}
+
+// AtExit registers fn to run if the process is force-exited, where deferred
+// calls do not run
+func (s *shutdown) AtExit(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.atExit = append(s.atExit, fn)
+}
+
+// Sleep waits for d, returning early (and false) if shutdown is requested
+func (s *shutdown) Sleep(d time.Duration) bool {
+	if s == nil {
+		time.Sleep(d)
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.done:
+		return false
+	}
+}
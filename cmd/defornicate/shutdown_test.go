@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/sink"
+)
+
+// stubExit replaces exit for the test, and returns a channel receiving the
+// codes it is called with
+func stubExit(t *testing.T) <-chan int {
+	t.Helper()
+	codes := make(chan int, 1)
+	exit = func(code int) { codes <- code }
+	t.Cleanup(func() { exit = os.Exit })
+	return codes
+}
+
+func TestShutdownSleep(t *testing.T) {
+	var none *shutdown
+	if !none.Sleep(time.Millisecond) || none.Requested() {
+		t.Error("a nil shutdown was requested")
+	}
+
+	sigCh := make(chan os.Signal, 2)
+	s := watchSignals(sigCh, time.Hour)
+	if !s.Sleep(time.Millisecond) {
+		t.Error("Sleep() returned early without a signal")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sigCh <- syscall.SIGTERM
+	}()
+	start := time.Now()
+	if s.Sleep(time.Hour) {
+		t.Error("Sleep() = true after a signal, want false")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Sleep() returned %v after the signal", elapsed)
+	}
+	if !s.Requested() {
+		t.Error("Requested() = false after a signal")
+	}
+	// Once requested, sleeping returns at once
+	if s.Sleep(time.Hour) {
+		t.Error("Sleep() after shutdown was requested = true")
+	}
+}
+
+func TestShutdownForcedExit(t *testing.T) {
+	tests := []struct {
+		name    string
+		grace   time.Duration
+		signals int
+	}{
+		{"grace period expired", 10 * time.Millisecond, 1},
+		{"second signal", time.Hour, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := stubExit(t)
+			sigCh := make(chan os.Signal, 2)
+			s := watchSignals(sigCh, tt.grace)
+			var cleaned []string
+			s.AtExit(func() { cleaned = append(cleaned, "scratch") })
+			s.AtExit(func() { cleaned = append(cleaned, "lock") })
+
+			for range tt.signals {
+				sigCh <- os.Interrupt
+			}
+			select {
+			case code := <-codes:
+				if code != exitInterrupted {
+					t.Errorf("exited with %d, want %d", code, exitInterrupted)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("the process was not exited")
+			}
+			if len(cleaned) != 2 || cleaned[0] != "scratch" || cleaned[1] != "lock" {
+				t.Errorf("AtExit functions run: %v, want both in order", cleaned)
+			}
+		})
+	}
+}
+
+// signalingSink requests shutdown when the first output is written, as if
+// SIGTERM arrived while that document was being extracted
+type signalingSink struct {
+	sigCh chan<- os.Signal
+	stop  *shutdown
+
+	mu      sync.Mutex
+	written []string
+}
+
+func (s *signalingSink) Write(out *extractor.Output) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, out.Document)
+	if len(s.written) == 1 {
+		s.sigCh <- syscall.SIGTERM
+		for !s.stop.Requested() {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return out.Document, nil
+}
+
+func TestBatchStopsBetweenDocuments(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		dir := filepath.Join(root, "eml", name)
+		os.MkdirAll(dir, 0755)
+		msg := "From: a@example.com\r\nSubject: " + name + "\r\nContent-Type: text/plain\r\n\r\nFlight schedule\r\n"
+		if err := os.WriteFile(filepath.Join(dir, name+".eml"), []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cat, err := catalog.Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigCh := make(chan os.Signal, 2)
+	stop := watchSignals(sigCh, time.Hour)
+	out := &signalingSink{sigCh: sigCh, stop: stop}
+
+	var code int
+	_, stderr, _ := captureOutput(t, func() int {
+		code = runBatch(extractor.New(), cat, root, batchOptions{concurrency: 1, sinks: []sink.Sink{out}}, stop)
+		return code
+	})
+	if code != 0 {
+		t.Fatalf("runBatch() = %d, stderr:\n%s", code, stderr)
+	}
+	if len(out.written) != 1 {
+		t.Errorf("%d document(s) extracted after shutdown was requested during the first, want only that one: %v", len(out.written), out.written)
+	}
+	if want := "Shutdown requested, 2 document(s) not processed"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/table"
+)
+
+// snapshotUsage lists the snapshot subcommands
+const snapshotUsage = `Usage: %[1]s snapshot create [--from DIR] NAME
+       %[1]s snapshot list [--from DIR]
+       %[1]s snapshot diff [--from DIR] [--json] OLD [NEW]
+`
+
+// runSnapshot records named snapshots of a documents tree and compares them
+func runSnapshot(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, snapshotUsage, os.Args[0])
+		return 1
+	}
+	switch args[0] {
+	case "create":
+		return runSnapshotCreate(args[1:])
+	case "list":
+		return runSnapshotList(args[1:])
+	case "diff":
+		return runSnapshotDiff(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, snapshotUsage, os.Args[0])
+		return 1
+	}
+}
+
+// runSnapshotCreate records the current state of the tree under a name
+func runSnapshotCreate(args []string) int {
+	flags := flag.NewFlagSet("snapshot create", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to snapshot")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, snapshotUsage, os.Args[0])
+		return 1
+	}
+
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	// A run changing the tree midway would leave a state that never existed
+	treeLock, err := lock.Acquire(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+
+	cat, err := catalog.Load(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+	snap, err := corpus.TakeSnapshot(*from, flags.Arg(0), cat)
+	if err == nil {
+		err = corpus.SaveSnapshot(*from, snap, perms)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	extractions := 0
+	for _, doc := range snap.Documents {
+		extractions += len(doc.Extractions)
+	}
+	fmt.Fprintf(os.Stderr, "Snapshot %q: %d document(s), %d extraction output(s)\n", snap.Name, len(snap.Documents), extractions)
+	return 0
+}
+
+// runSnapshotList prints the snapshots of a tree, oldest first
+func runSnapshotList(args []string) int {
+	flags := flag.NewFlagSet("snapshot list", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree whose snapshots to list")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, snapshotUsage, os.Args[0])
+		return 1
+	}
+
+	snaps, err := corpus.ListSnapshots(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(snaps) == 0 {
+		fmt.Fprintf(os.Stderr, "No snapshots of %s\n", *from)
+		return 0
+	}
+	t := table.New("NAME", "CREATED", "DOCUMENTS").AlignRight(2).StyleColumn(0, table.Cyan)
+	for _, snap := range snaps {
+		t.Add(snap.Name, snap.CreatedAt.Local().Format(time.RFC3339), fmt.Sprint(len(snap.Documents)))
+	}
+	printTable(t)
+	return 0
+}
+
+// runSnapshotDiff prints the documents and extractions added, changed or
+// removed between two snapshots, or between a snapshot and the tree as it is
+// now
+func runSnapshotDiff(args []string) int {
+	flags := flag.NewFlagSet("snapshot diff", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree whose snapshots to compare")
+	asJSON := flags.Bool("json", false, "print the changes as JSON")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 1 && flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, snapshotUsage, os.Args[0])
+		return 1
+	}
+
+	old, err := corpus.LoadSnapshot(*from, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	var current *corpus.Snapshot
+	if flags.NArg() == 2 {
+		current, err = corpus.LoadSnapshot(*from, flags.Arg(1))
+	} else {
+		var cat *catalog.Catalog
+		if cat, err = catalog.Load(*from, pathutil.Permissions{}); err == nil {
+			current, err = corpus.TakeSnapshot(*from, "current", cat)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	changes := corpus.DiffSnapshots(old, current)
+	if *asJSON {
+		if changes == nil {
+			changes = []corpus.Change{}
+		}
+		return printJSON(map[string]any{"from": old.Name, "to": current.Name, "changes": changes})
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(os.Stderr, "No changes between %s and %s\n", old.Name, current.Name)
+		return 0
+	}
+	actionStyle := map[string]table.Style{
+		corpus.ChangeAdded:   table.Green,
+		corpus.ChangeChanged: table.Yellow,
+		corpus.ChangeRemoved: table.Red,
+	}
+	t := table.New("ACTION", "KIND", "PATH")
+	for _, change := range changes {
+		t.AddCells(
+			table.Cell{Text: change.Action, Style: actionStyle[change.Action]},
+			table.Cell{Text: change.Kind},
+			table.Cell{Text: change.Path},
+		)
+	}
+	printTable(t)
+	return 0
+}
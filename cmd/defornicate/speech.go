@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/speech"
+)
+
+// speechSuffix replaces the extension of a document in the name of its
+// speech export; it contains ".extracted." so the export is never taken for
+// a document
+const speechSuffix = ".extracted.speech.txt"
+
+// runSpeech writes a text-to-speech friendly export of extracted documents
+// next to each one
+func runSpeech(args []string) int {
+	flags := flag.NewFlagSet("speech", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to export")
+	match := flags.String("match", "", "only export documents whose filename matches this glob (e.g. 'EFTA*')")
+	stdout := flags.Bool("stdout", false, "print the exports instead of writing them next to the documents")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+
+	docs := make([]string, 0, flags.NArg())
+	for _, arg := range flags.Args() {
+		docs = append(docs, pathutil.ResolveDocumentPathIn(*from, arg))
+	}
+	if len(docs) == 0 {
+		var err error
+		if docs, err = extractor.FindDocuments(*from); err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding documents: %v\n", err)
+			return 1
+		}
+	}
+	docs, err := filterByGlob(docs, *match)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --match pattern: %v\n", err)
+		return 1
+	}
+
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+	if !*stdout {
+		treeLock, err := lock.Acquire(*from, perms)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer treeLock.Release()
+	}
+
+	// Exports are paginated, which only the JSON output records
+	jsonExt := extractor.New()
+	var exported, skipped, failed int
+	for _, docPath := range docs {
+		outputPath := jsonExt.FindOutput(docPath)
+		if outputPath == "" {
+			skipped++
+			continue
+		}
+		extracted, err := extractor.ReadExtracted(outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", outputPath, err)
+			failed++
+			continue
+		}
+		content := speech.Format(extracted)
+		if *stdout {
+			os.Stdout.Write(content)
+			exported++
+			continue
+		}
+		plainPath := strings.TrimSuffix(docPath, filepath.Ext(docPath)) + speechSuffix
+		speechPath, content, err := sealOutput(cfg, config.OutputSpeech, plainPath, content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if err := perms.WriteFile(speechPath, content); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", speechPath, err)
+			failed++
+			continue
+		}
+		if speechPath != plainPath {
+			os.Remove(plainPath) // an earlier export written in the clear
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", speechPath)
+		exported++
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d document(s) for speech", exported)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d without a JSON extraction output skipped)", skipped)
+	}
+	fmt.Fprintln(os.Stderr)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
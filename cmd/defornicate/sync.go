@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"defornicate-epstein-files/internal/source"
+)
+
+// maxDeltaRuns is how many runs of new inputs printDelta lists before
+// summarizing the rest
+const maxDeltaRuns = 20
+
+// printDelta reports how the resolved inputs compare against the catalog:
+// how many are already known, and the runs of new ones that will be fetched
+func printDelta(delta source.Delta, items []source.Item) {
+	fmt.Fprintf(os.Stderr, "%d input(s): %d already in the catalog, %d new\n", len(items), len(delta.Known), len(delta.New))
+	runs := delta.Runs(items)
+	for i, run := range runs {
+		if i == maxDeltaRuns {
+			fmt.Fprintf(os.Stderr, "  ... and %d more run(s) of new inputs\n", len(runs)-i)
+			break
+		}
+		if run.Count == 1 {
+			fmt.Fprintf(os.Stderr, "  new: %s\n", run.First)
+		} else {
+			fmt.Fprintf(os.Stderr, "  new: %s .. %s (%d)\n", run.First, run.Last, run.Count)
+		}
+	}
+	if len(delta.New) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing new to fetch")
+	}
+}
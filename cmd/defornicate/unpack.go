@@ -0,0 +1,23 @@
+package main
+
+import (
+	"defornicate-epstein-files/internal/pipeline"
+	"defornicate-epstein-files/internal/source"
+)
+
+// runEmbedded runs the documents unpacked from a PDF portfolio, PST archive
+// or email message through p, each as a document of its own (and the
+// documents unpacked from any of them in turn, such as the attachments of a
+// PST archive's messages), calling report after each with its error, if
+// any. Their text is saved like any output but not printed.
+func runEmbedded(p *pipeline.Pipeline, parent *pipeline.Document, report func(doc *pipeline.Document, err error)) {
+	for _, path := range parent.Embedded {
+		notef("Processing unpacked document: %s\n", path)
+		doc := &pipeline.Document{Item: source.Item{Input: path, Path: path}, Path: path}
+		err := p.Run(doc)
+		report(doc, err)
+		if err == nil {
+			runEmbedded(p, doc, report)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/lock"
+)
+
+// runVacuum removes what deleted and re-extracted documents leave behind in
+// a tree: orphaned outputs, stale temporary files and empty directories on
+// disk, and catalog entries and references to files no longer there
+func runVacuum(args []string) int {
+	flags := flag.NewFlagSet("vacuum", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to vacuum")
+	dryRun := flags.Bool("dry-run", false, "only report what would be removed")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s vacuum [--from DIR] [--dry-run]\n", os.Args[0])
+		return 1
+	}
+	cfg, _ := loadConfig()
+	perms, err := cfg.Permissions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+		return 1
+	}
+
+	// A run extracting meanwhile would write outputs vacuum takes for orphans
+	treeLock, err := lock.Acquire(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer treeLock.Release()
+	cat, err := catalog.Load(*from, perms)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+
+	result, err := corpus.Vacuum(*from, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	entries, references := cat.Prune()
+
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+		defer fmt.Println("(dry run: nothing was removed)")
+	}
+	for _, path := range result.Outputs {
+		fmt.Printf("%s orphaned output: %s\n", verb, path)
+	}
+	for _, path := range result.TempFiles {
+		fmt.Printf("%s temporary file: %s\n", verb, path)
+	}
+	for _, path := range result.EmptyDirs {
+		fmt.Printf("%s empty directory: %s\n", verb, path)
+	}
+	for _, path := range entries {
+		fmt.Printf("%s catalog entry of missing document: %s\n", verb, path)
+	}
+
+	reclaimed := result.Bytes
+	if !*dryRun {
+		before := fileSize(cat.Path())
+		if cat.Format() == catalog.FormatJSONL {
+			// Compacting also drops the superseded records of the log
+			_, _, err = cat.Compact()
+		} else {
+			err = cat.Save()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving catalog: %v\n", err)
+			return 1
+		}
+		reclaimed += before - fileSize(cat.Path())
+	}
+
+	found := len(result.Outputs) + len(result.TempFiles) + len(result.EmptyDirs) + len(entries) + references
+	if found == 0 && reclaimed <= 0 {
+		fmt.Println("Nothing to vacuum")
+		return 0
+	}
+	fmt.Printf("\n--- Summary ---\n")
+	fmt.Printf("Orphaned outputs: %d\n", len(result.Outputs))
+	fmt.Printf("Temporary files: %d\n", len(result.TempFiles))
+	fmt.Printf("Empty directories: %d\n", len(result.EmptyDirs))
+	fmt.Printf("Catalog entries of missing documents: %d\n", len(entries))
+	fmt.Printf("Catalog references to missing outputs: %d\n", references)
+	if reclaimed > 0 {
+		fmt.Printf("Reclaimed: %d byte(s)\n", reclaimed)
+	}
+	return 0
+}
+
+// fileSize returns the size of the file at path, or 0 if it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
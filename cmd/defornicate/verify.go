@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/notify"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/table"
+)
+
+// runVerify recomputes the checksum of every document in a tree in parallel
+// and compares it with the catalog, printing each result as it completes.
+// With --every it verifies the tree again at that interval until
+// interrupted, and with --notify it sends each report to the destinations of
+// the notify config.
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to verify")
+	workers := flags.Int("workers", defaultConcurrency, "number of files to checksum in parallel")
+	rate := flags.String("rate", "", "maximum total read rate, e.g. 200M or 1.5G (bytes per second; default unlimited)")
+	every := flags.Duration("every", 0, "verify again at this interval until interrupted, e.g. 24h (default: verify once)")
+	notifyReport := flags.Bool("notify", false, "send the report to the webhook and email recipients of the notify config")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() != 0 || *every < 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s verify [--from DIR] [--workers N] [--rate BYTES] [--every DURATION] [--notify]\n", os.Args[0])
+		return 1
+	}
+	rateLimit, err := config.ParseSize(*rate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --rate: %v\n", err)
+		return 1
+	}
+	var notifier *notify.Notifier
+	if *notifyReport {
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --notify needs a config file: %v\n", err)
+			return 1
+		}
+		if notifier, err = notify.FromConfig(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			return 1
+		}
+		if notifier == nil {
+			fmt.Fprintf(os.Stderr, "Error: --notify needs a webhook or email recipients in the notify config\n")
+			return 1
+		}
+	}
+	opts := corpus.VerifyOptions{Workers: *workers, RateLimit: rateLimit}
+	if *every == 0 {
+		return verifyTree(*from, opts, notifier)
+	}
+
+	// Between runs the process only sleeps, so a signal ends it at once;
+	// during a run it gets the usual grace period
+	stop := watchShutdown(defaultGracePeriod)
+	for {
+		status := verifyTree(*from, opts, notifier)
+		next := time.Now().Add(*every)
+		fmt.Fprintf(os.Stderr, "Next verification at %s\n", next.Format(time.DateTime))
+		if !stop.Sleep(time.Until(next)) {
+			return status
+		}
+	}
+}
+
+// verifyTree verifies a tree once against its catalog, loaded afresh so
+// documents added since a previous run are expected, printing each result
+// and a summary and sending the report through notifier if not nil. It
+// returns the exit status of the run.
+func verifyTree(from string, opts corpus.VerifyOptions, notifier *notify.Notifier) int {
+	cat, err := catalog.Load(from, pathutil.Permissions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading catalog: %v\n", err)
+		return 1
+	}
+
+	// Results are printed as they complete, so the status column is padded
+	// rather than laid out as a table
+	p := stdoutPainter()
+	statusStyle := map[string]table.Style{
+		corpus.VerifyOK:         table.Green,
+		corpus.VerifyMismatch:   table.Red,
+		corpus.VerifyMissing:    table.Red,
+		corpus.VerifyUnrecorded: table.Yellow,
+		corpus.VerifyError:      table.Red,
+	}
+	report := notify.NewReport(from)
+	err = corpus.Verify(from, cat.Checksums(), opts, func(check corpus.Check) {
+		report.Add(check)
+		status := p.Paint(fmt.Sprintf("%-10s", check.Status), statusStyle[check.Status])
+		switch check.Status {
+		case corpus.VerifyMismatch:
+			fmt.Printf("%s %s (catalog %s, file %s)\n", status, check.Path, check.Expected, check.Actual)
+		case corpus.VerifyError:
+			fmt.Printf("%s %s: %v\n", status, check.Path, check.Err)
+		default:
+			fmt.Printf("%s %s\n", status, check.Path)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	report.Finish()
+	elapsed := report.Finished.Sub(report.Started)
+	fmt.Fprintf(os.Stderr, "\n--- Summary ---\n")
+	fmt.Fprintf(os.Stderr, "Verified %d byte(s) in %s (%.1f MB/s)\n", report.Bytes, elapsed.Round(time.Millisecond), float64(report.Bytes)/1e6/elapsed.Seconds())
+	for _, status := range []string{corpus.VerifyOK, corpus.VerifyMismatch, corpus.VerifyMissing, corpus.VerifyUnrecorded, corpus.VerifyError} {
+		if report.Counts[status] > 0 {
+			fmt.Fprintf(os.Stderr, "%s: %d\n", status, report.Counts[status])
+		}
+	}
+	if err := notifier.Send(report); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if !report.Clean() {
+		return 1
+	}
+	return 0
+}
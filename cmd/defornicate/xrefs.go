@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/xref"
+)
+
+// runXrefs links the exhibits mentioned in the extracted documents of a tree
+// to the documents labeled as those exhibits, as CSV or JSON
+func runXrefs(args []string) int {
+	flags := flag.NewFlagSet("xrefs", flag.ContinueOnError)
+	from := flags.String("from", documentsDir, "documents tree to read extraction outputs from")
+	out := flags.String("out", "", "write the CSV to this file instead of stdout")
+	asJSON := flags.Bool("json", false, "print the links as JSON instead of CSV")
+	document := flags.String("document", "", "only list the links from or to this document")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if flags.NArg() > 0 || (*asJSON && *out != "") {
+		fmt.Fprintf(os.Stderr, "Usage: %s xrefs [--from DIR] [--out FILE | --json] [--document PATH]\n", os.Args[0])
+		return 1
+	}
+
+	result, err := xref.Tree(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding documents: %v\n", err)
+		return 1
+	}
+	links := result.Links
+	if *document != "" {
+		rel, err := filepath.Rel(*from, pathutil.ResolveDocumentPathIn(*from, *document))
+		if err != nil {
+			rel = *document
+		}
+		rel = filepath.ToSlash(rel)
+		links = nil
+		for _, link := range result.Links {
+			if link.Document == rel || link.Target == rel {
+				links = append(links, link)
+			}
+		}
+	}
+
+	if *asJSON {
+		if links == nil {
+			links = []xref.Link{}
+		}
+		return printJSON(map[string]any{"links": links, "unresolved": result.Unresolved})
+	}
+	var csv bytes.Buffer
+	if err := xref.WriteCSV(&csv, links); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+		return 1
+	}
+	if *out == "" {
+		os.Stdout.Write(csv.Bytes())
+	} else {
+		cfg, _ := loadConfig()
+		perms, err := cfg.Permissions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in config: %v\n", err)
+			return 1
+		}
+		if err := perms.WriteFile(*out, csv.Bytes()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *out, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d cross-reference(s) in %d extracted document(s)", len(links), result.Scanned)
+	if result.Unresolved > 0 {
+		fmt.Fprintf(os.Stderr, "; %d exhibit mention(s) match no document", result.Unresolved)
+	}
+	fmt.Fprintln(os.Stderr)
+	return 0
+}
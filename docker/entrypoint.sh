@@ -0,0 +1,66 @@
+#!/bin/sh
+# Container entrypoint: downloads and extracts the configured sources into
+# the data directory, optionally re-syncing them on an interval, and serves
+# the documents tree. Settings come from the environment:
+#
+#   DEFORNICATOR_DATA           data directory holding the config and documents/ (default: /data)
+#   DEFORNICATOR_SOURCE         built-in source preset (see "defornicate sources")
+#   DEFORNICATOR_PATTERN        URL pattern, e.g. https://example.org/EFTA{00010724-00010730}.pdf
+#   DEFORNICATOR_URLS           document URLs, separated by spaces or commas
+#   DEFORNICATOR_SYNC_INTERVAL  seconds between syncs; unset or 0 syncs once at startup
+#   DEFORNICATOR_SKIP_SYNC      set to 1 to serve the data directory as it is
+#   PORT                        port to listen on (default: 8080)
+#
+# The source variables write epstein-files-urls.json only when the data
+# directory has none, so a mounted config file takes precedence. Arguments,
+# if any, are passed to defornicate-server.
+set -eu
+
+data="${DEFORNICATOR_DATA:-/data}"
+config="$data/epstein-files-urls.json"
+mkdir -p "$data/documents"
+cd "$data"
+
+# json_string quotes its argument as a JSON string
+json_string() {
+	printf '"%s"' "$(printf '%s' "$1" | sed 's/\\/\\\\/g; s/"/\\"/g')"
+}
+
+if [ ! -f "$config" ]; then
+	if [ -n "${DEFORNICATOR_SOURCE:-}" ]; then
+		printf '{\n  "source": %s\n}\n' "$(json_string "$DEFORNICATOR_SOURCE")" >"$config"
+	elif [ -n "${DEFORNICATOR_PATTERN:-}" ]; then
+		printf '{\n  "pattern": %s\n}\n' "$(json_string "$DEFORNICATOR_PATTERN")" >"$config"
+	elif [ -n "${DEFORNICATOR_URLS:-}" ]; then
+		urls=""
+		for url in $(printf '%s' "$DEFORNICATOR_URLS" | tr ',' ' '); do
+			urls="${urls:+$urls, }$(json_string "$url")"
+		done
+		printf '{\n  "urls": [%s]\n}\n' "$urls" >"$config"
+	fi
+fi
+
+# sync downloads and extracts the configured sources; documents already in
+# the catalog are not fetched again
+sync() {
+	if [ ! -f "$config" ]; then
+		echo "No $config and no DEFORNICATOR_SOURCE, DEFORNICATOR_PATTERN or DEFORNICATOR_URLS; serving $data/documents as it is" >&2
+		return
+	fi
+	epstein-files-defornicator || echo "Sync failed; serving what has been downloaded so far" >&2
+}
+
+if [ "${DEFORNICATOR_SKIP_SYNC:-0}" != 1 ]; then
+	# Sync in the background so the server answers while documents arrive;
+	# it reloads the catalog as the sync saves it
+	interval="${DEFORNICATOR_SYNC_INTERVAL:-0}"
+	(
+		sync
+		while [ "$interval" -gt 0 ]; do
+			sleep "$interval"
+			sync
+		done
+	) &
+fi
+
+exec defornicate-server --addr "0.0.0.0:${PORT:-8080}" --root "$data/documents" "$@"
@@ -4,4 +4,15 @@ go 1.25
 
 toolchain go1.25.5
 
-require github.com/ledongthuc/pdf v0.0.0-20250511090121-5959a4027728
+require (
+	filippo.io/age v1.2.1
+	github.com/jlaffaye/ftp v0.2.4
+	github.com/klauspost/compress v1.18.0
+	github.com/ledongthuc/pdf v0.0.0-20250511090121-5959a4027728
+	github.com/pkg/sftp v1.13.10
+	go.yaml.in/yaml/v3 v3.0.5
+	golang.org/x/crypto v0.45.0
+	golang.org/x/sys v0.38.0
+)
+
+require github.com/kr/fs v0.1.0 // indirect
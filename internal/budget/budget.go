@@ -0,0 +1,79 @@
+// Package budget provides a shared memory budget so concurrent downloads and
+// extractions on small machines hold a bounded amount of data in memory.
+package budget
+
+import "sync"
+
+// Budget tracks bytes reserved against a fixed limit. A nil *Budget is
+// unlimited: every reservation succeeds immediately.
+type Budget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// New creates a Budget of limit bytes. A limit of zero or less returns nil,
+// meaning unlimited.
+func New(limit int64) *Budget {
+	if limit <= 0 {
+		return nil
+	}
+	b := &Budget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Limit returns the budget in bytes, or 0 when unlimited
+func (b *Budget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Acquire reserves n bytes, waiting until they are available, and returns the
+// amount reserved, which must be passed to Release. Requests larger than the
+// whole budget reserve all of it, so one oversized document runs alone rather
+// than waiting forever.
+func (b *Budget) Acquire(n int64) int64 {
+	if b == nil || n <= 0 {
+		return 0
+	}
+	if n > b.limit {
+		n = b.limit
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	b.used += n
+	return n
+}
+
+// TryAcquire reserves n bytes if they are available right now and reports
+// whether it did
+func (b *Budget) TryAcquire(n int64) bool {
+	if b == nil || n <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Release returns n previously reserved bytes to the budget
+func (b *Budget) Release(n int64) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
@@ -0,0 +1,58 @@
+package budget
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNilBudgetIsUnlimited(t *testing.T) {
+	var b *Budget = New(0)
+	if b != nil {
+		t.Fatalf("New(0) = %v, want nil", b)
+	}
+	if got := b.Acquire(1 << 40); got != 0 {
+		t.Errorf("Acquire() = %d, want 0", got)
+	}
+	if !b.TryAcquire(1 << 40) {
+		t.Error("TryAcquire() = false, want true")
+	}
+	b.Release(1 << 40)
+}
+
+func TestAcquireBlocksUntilReleased(t *testing.T) {
+	b := New(100)
+	first := b.Acquire(80)
+
+	acquired := make(chan int64)
+	go func() { acquired <- b.Acquire(40) }()
+
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() returned while the budget was exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.Release(first)
+	select {
+	case got := <-acquired:
+		if got != 40 {
+			t.Errorf("Acquire() = %d, want 40", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() still blocked after Release()")
+	}
+}
+
+func TestAcquireClampsToLimit(t *testing.T) {
+	b := New(100)
+	if got := b.Acquire(500); got != 100 {
+		t.Errorf("Acquire(500) = %d, want 100", got)
+	}
+	if b.TryAcquire(1) {
+		t.Error("TryAcquire() succeeded on an exhausted budget")
+	}
+	b.Release(100)
+	if !b.TryAcquire(100) {
+		t.Error("TryAcquire() failed after Release()")
+	}
+}
@@ -0,0 +1,718 @@
+// Package catalog maintains an index of the documents tree: which URLs each
+// document was downloaded from, its checksum, and the outputs derived from
+// it. It lets repeat URLs short-circuit across runs instead of being fetched
+// again.
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"defornicate-epstein-files/internal/exif"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// FileName is the catalog's filename at the root of the documents tree
+const FileName = "catalog.json"
+
+// Catalog formats: a single JSON file rewritten on each save, or an
+// append-only JSON Lines log (see log.go)
+const (
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// IsCatalogFile reports whether rel, relative to the root of a documents
+// tree, is the tree's catalog in either format
+func IsCatalogFile(rel string) bool {
+	return rel == FileName || rel == LogFileName
+}
+
+// Document is the catalog entry for one stored document
+type Document struct {
+	Path         string            `json:"path"`            // relative to the documents directory
+	SHA256       string            `json:"sha256"`          // hex checksum of the stored file
+	Pages        int               `json:"pages,omitempty"` // page count checked after download
+	URLs         []string          `json:"urls,omitempty"`
+	DownloadedAt time.Time         `json:"downloaded_at"`
+	Parts        []Part            `json:"parts,omitempty"`      // logical sub-documents, if split
+	Output       string            `json:"output,omitempty"`     // latest extraction output, relative to the documents directory when in it
+	Meta         *meta.Meta        `json:"meta,omitempty"`       // curated metadata from the document's meta.yaml
+	Cover        *legal.CoverSheet `json:"cover,omitempty"`      // producing party, date and designation from its cover sheet
+	NeedsOCR     int               `json:"needs_ocr,omitempty"`  // pages with ink but too little readable text
+	Redactions   int               `json:"redactions,omitempty"` // redaction marks in the extracted text
+	EXIF         *exif.EXIF        `json:"exif,omitempty"`       // camera, timestamps and GPS position of an image
+	Parent       string            `json:"parent,omitempty"`     // the PDF portfolio, PST archive or email message this document was unpacked from
+	Embedded     []string          `json:"embedded,omitempty"`   // documents unpacked from this portfolio, archive or message
+}
+
+// Part is a logical sub-document of a split document
+type Part struct {
+	Output    string `json:"output"` // extraction output path, relative to the documents directory
+	StartPage int    `json:"start_page"`
+	EndPage   int    `json:"end_page"`
+}
+
+// Attempt is one fetch of a URL, successful or not
+type Attempt struct {
+	At            time.Time `json:"at"`
+	Status        int       `json:"status,omitempty"` // HTTP status code, if a response arrived
+	Bytes         int64     `json:"bytes"`
+	TransferBytes int64     `json:"transfer_bytes,omitempty"` // size on the wire, if the response was gzip or deflate encoded
+	DurationMS    int64     `json:"duration_ms"`
+	Error         string    `json:"error,omitempty"`
+	// FallbackUserAgent is the browser User-Agent used after a 403, if any
+	FallbackUserAgent string `json:"fallback_user_agent,omitempty"`
+	// Headers are the response's Content-Length, Last-Modified, ETag,
+	// Server and Date, as sent
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Failed reports whether the attempt failed
+func (a Attempt) Failed() bool {
+	return a.Error != ""
+}
+
+// maxAttempts is how many attempts are kept per URL; older ones are dropped
+const maxAttempts = 50
+
+// Catalog is the in-memory catalog of a documents tree. It is safe for
+// concurrent use.
+type Catalog struct {
+	root  string
+	perms pathutil.Permissions
+
+	mu      sync.Mutex
+	docs    map[string]*Document // by relative path
+	byURL   map[string]*Document
+	history map[string][]Attempt // by URL, oldest first
+	dirty   bool                 // changed since loaded or last saved
+
+	// Append-only log mode: what to append on the next save
+	format   string
+	rewrite  bool                 // write the whole log, e.g. after converting
+	changed  map[string]bool      // documents changed or forgotten, by relative path
+	appended map[string][]Attempt // attempts recorded, by URL
+	replaced map[string]bool      // URLs whose whole history changed
+}
+
+// file is the on-disk representation of the catalog
+type file struct {
+	Documents []*Document          `json:"documents"`
+	History   map[string][]Attempt `json:"history,omitempty"` // fetch attempts by URL
+}
+
+// Load reads the catalog of the documents tree at root, from its append-only
+// log if it has one and from catalog.json otherwise. A missing catalog is
+// not an error; it yields an empty catalog that is created on Save.
+func Load(root string, perms pathutil.Permissions) (*Catalog, error) {
+	c := newCatalog(root, perms)
+	if _, err := os.Stat(filepath.Join(root, LogFileName)); err == nil {
+		c.format = FormatJSONL
+		if err := c.readLog(); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	data, err := os.ReadFile(c.Path())
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog %s: %w", c.Path(), err)
+	}
+	for _, doc := range f.Documents {
+		c.add(doc)
+	}
+	for url, attempts := range f.History {
+		c.history[url] = attempts
+	}
+	return c, nil
+}
+
+// newCatalog returns an empty catalog of the tree at root
+func newCatalog(root string, perms pathutil.Permissions) *Catalog {
+	return &Catalog{
+		root:     root,
+		perms:    perms,
+		docs:     make(map[string]*Document),
+		byURL:    make(map[string]*Document),
+		history:  make(map[string][]Attempt),
+		format:   FormatJSON,
+		changed:  make(map[string]bool),
+		appended: make(map[string][]Attempt),
+		replaced: make(map[string]bool),
+	}
+}
+
+// Path returns the catalog file's path
+func (c *Catalog) Path() string {
+	if c.format == FormatJSONL {
+		return filepath.Join(c.root, LogFileName)
+	}
+	return filepath.Join(c.root, FileName)
+}
+
+// Format returns the catalog's on-disk format, FormatJSON or FormatJSONL
+func (c *Catalog) Format() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.format
+}
+
+// SetFormat switches the catalog to another on-disk format. The next Save
+// writes the whole catalog in the new format and removes the file in the old
+// one.
+func (c *Catalog) SetFormat(format string) error {
+	if format != FormatJSON && format != FormatJSONL {
+		return fmt.Errorf("unknown catalog format %q (expected %s or %s)", format, FormatJSON, FormatJSONL)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if format != c.format {
+		c.format = format
+		c.rewrite = true
+		c.dirty = true
+	}
+	return nil
+}
+
+// touch marks the document at rel as changed since the last save
+func (c *Catalog) touch(rel string) {
+	c.changed[rel] = true
+	c.dirty = true
+}
+
+// Lookup returns the document a URL was downloaded to, if the URL is known
+// and the document is still on disk, along with its path
+func (c *Catalog) Lookup(url string) (*Document, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.byURL[url]
+	if !ok {
+		return nil, "", false
+	}
+	path := filepath.Join(c.root, filepath.FromSlash(doc.Path))
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", false
+	}
+	return doc, path, true
+}
+
+// ByURL returns the document a URL was downloaded to, whether or not it is
+// still on disk
+func (c *Catalog) ByURL(url string) (*Document, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.byURL[url]
+	return doc, ok
+}
+
+// URLs returns every URL a catalogued document was downloaded from, sorted
+func (c *Catalog) URLs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sortedKeys(c.byURL)
+}
+
+// Checksums returns the recorded hex checksum of every catalogued document,
+// by slash-separated path relative to the root
+func (c *Catalog) Checksums() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sums := make(map[string]string, len(c.docs))
+	for rel, doc := range c.docs {
+		if doc.SHA256 != "" {
+			sums[rel] = doc.SHA256
+		}
+	}
+	return sums
+}
+
+// RecordAttempt appends a fetch attempt to the history of url, keeping only
+// the most recent attempts
+func (c *Catalog) RecordAttempt(url string, attempt Attempt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	attempts := append(c.history[url], attempt)
+	if len(attempts) > maxAttempts {
+		attempts = attempts[len(attempts)-maxAttempts:]
+	}
+	c.history[url] = attempts
+	if !c.replaced[url] {
+		c.appended[url] = append(c.appended[url], attempt)
+	}
+	c.dirty = true
+}
+
+// History returns the recorded fetch attempts for url, oldest first
+func (c *Catalog) History(url string) []Attempt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Attempt(nil), c.history[url]...)
+}
+
+// LastHeaders returns the response headers of the most recent successful
+// fetch of url that recorded any, for revalidating the document; nil if
+// there is none
+func (c *Catalog) LastHeaders(url string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	attempts := c.history[url]
+	for i := len(attempts) - 1; i >= 0; i-- {
+		if !attempts[i].Failed() && len(attempts[i].Headers) > 0 {
+			return attempts[i].Headers
+		}
+	}
+	return nil
+}
+
+// Get returns the entry for a document path under the tree, if any
+func (c *Catalog) Get(path string) (*Document, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return nil, false
+	}
+	doc, ok := c.docs[rel]
+	return doc, ok
+}
+
+// RecordDownload records that url was downloaded to path (under the tree)
+// with the given checksum. A URL previously mapped to a different document
+// is moved to this one.
+func (c *Catalog) RecordDownload(url, path string, sum [32]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, err := c.entry(path)
+	if err != nil {
+		return err
+	}
+	if prev, ok := c.byURL[url]; ok && prev != doc {
+		prev.URLs = remove(prev.URLs, url)
+		c.touch(prev.Path)
+	}
+	if !contains(doc.URLs, url) {
+		doc.URLs = append(doc.URLs, url)
+	}
+	doc.SHA256 = fmt.Sprintf("%x", sum)
+	doc.DownloadedAt = time.Now().UTC()
+	c.byURL[url] = doc
+	c.touch(doc.Path)
+	return nil
+}
+
+// RecordPages records the page count of a catalogued document, as checked
+// after download. Documents not in the catalog are ignored.
+func (c *Catalog) RecordPages(path string, pages int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	if doc, ok := c.docs[rel]; ok && doc.Pages != pages {
+		doc.Pages = pages
+		c.touch(rel)
+	}
+}
+
+// RecordMeta records the curated metadata of a catalogued document (nil when
+// it has none). Documents not in the catalog are ignored.
+func (c *Catalog) RecordMeta(path string, m *meta.Meta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	// Compare as stored: values read back from catalog.json have JSON types
+	before, _ := json.Marshal(doc.Meta)
+	after, _ := json.Marshal(m)
+	if !bytes.Equal(before, after) {
+		doc.Meta = m
+		c.touch(rel)
+	}
+}
+
+// RecordOutput records the latest extraction output of a catalogued
+// document. Documents not in the catalog are ignored.
+func (c *Catalog) RecordOutput(path, output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	if outputRel, ok := c.rel(output); ok {
+		output = outputRel
+	}
+	if doc.Output != output {
+		doc.Output = output
+		c.touch(rel)
+	}
+}
+
+// RecordCover records the cover sheet details of a catalogued document (nil
+// when it has none). Documents not in the catalog are ignored.
+func (c *Catalog) RecordCover(path string, sheet *legal.CoverSheet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	if (doc.Cover == nil) != (sheet == nil) || (sheet != nil && *doc.Cover != *sheet) {
+		doc.Cover = sheet
+		c.touch(rel)
+	}
+}
+
+// RecordReview records how many pages of a catalogued document need OCR
+// and how many redaction marks its text carries. Documents not in the
+// catalog are ignored.
+func (c *Catalog) RecordReview(path string, needsOCR, redactions int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	if doc.NeedsOCR != needsOCR || doc.Redactions != redactions {
+		doc.NeedsOCR, doc.Redactions = needsOCR, redactions
+		c.touch(rel)
+	}
+}
+
+// RecordEXIF records the EXIF metadata of a catalogued image (nil when it
+// has none). Documents not in the catalog are ignored.
+func (c *Catalog) RecordEXIF(path string, x *exif.EXIF) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	before, _ := json.Marshal(doc.EXIF)
+	after, _ := json.Marshal(x)
+	if !bytes.Equal(before, after) {
+		doc.EXIF = x
+		c.touch(rel)
+	}
+}
+
+// Forget removes a document and its URLs from the catalog, so the URLs are
+// fetched again by the next run
+func (c *Catalog) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rel, ok := c.rel(path)
+	if !ok {
+		return
+	}
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	for _, url := range doc.URLs {
+		delete(c.byURL, url)
+	}
+	delete(c.docs, rel)
+	c.touch(rel)
+}
+
+// Prune drops the entries of documents no longer on disk, and the output,
+// part and embedded document references of the remaining entries to files no
+// longer on disk. It
+// returns the relative paths of the entries dropped and the number of
+// references dropped. The fetch histories of the dropped entries' URLs are
+// kept.
+func (c *Catalog) Prune() (entries []string, references int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rel := range sortedKeys(c.docs) {
+		doc := c.docs[rel]
+		if !c.exists(rel) {
+			for _, url := range doc.URLs {
+				delete(c.byURL, url)
+			}
+			delete(c.docs, rel)
+			c.touch(rel)
+			entries = append(entries, rel)
+			continue
+		}
+		if doc.Output != "" && !c.exists(doc.Output) {
+			doc.Output = ""
+			references++
+			c.touch(rel)
+		}
+		var parts []Part
+		for _, part := range doc.Parts {
+			if c.exists(part.Output) {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) != len(doc.Parts) {
+			references += len(doc.Parts) - len(parts)
+			doc.Parts = parts
+			c.touch(rel)
+		}
+		var embedded []string
+		for _, child := range doc.Embedded {
+			if c.exists(child) {
+				embedded = append(embedded, child)
+			}
+		}
+		if len(embedded) != len(doc.Embedded) {
+			references += len(doc.Embedded) - len(embedded)
+			doc.Embedded = embedded
+			c.touch(rel)
+		}
+	}
+	return entries, references
+}
+
+// exists reports whether a path recorded in the catalog, relative to the
+// root or absolute, is on disk
+func (c *Catalog) exists(path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.root, filepath.FromSlash(path))
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// RecordEmbedded records the document at path, with the given checksum, as
+// unpacked from the PDF portfolio, PST archive or email message at parent,
+// linking each to the other. Both must be under the tree.
+func (c *Catalog) RecordEmbedded(parent, path string, sum [32]byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	container, err := c.entry(parent)
+	if err != nil {
+		return err
+	}
+	doc, err := c.entry(path)
+	if err != nil {
+		return err
+	}
+	doc.SHA256 = fmt.Sprintf("%x", sum)
+	doc.Parent = container.Path
+	// It arrived with its container
+	doc.DownloadedAt = container.DownloadedAt
+	if !contains(container.Embedded, doc.Path) {
+		container.Embedded = append(container.Embedded, doc.Path)
+		c.touch(container.Path)
+	}
+	c.touch(doc.Path)
+	return nil
+}
+
+// RecordParts records the logical sub-documents a document was split into
+func (c *Catalog) RecordParts(path string, parts []Part) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, err := c.entry(path)
+	if err != nil {
+		return err
+	}
+	for i := range parts {
+		if rel, ok := c.rel(parts[i].Output); ok {
+			parts[i].Output = rel
+		}
+	}
+	doc.Parts = parts
+	c.touch(doc.Path)
+	return nil
+}
+
+// Import records the copy at path of a document catalogued in another tree
+// (at fromPath in from), e.g. when merging trees downloaded on different
+// machines. What is already recorded for path is kept; the other entry's
+// URLs, with their fetch history, are added to it. Documents not in from are
+// ignored.
+func (c *Catalog) Import(path string, from *Catalog, fromPath string) error {
+	src, ok := from.Get(fromPath)
+	if !ok {
+		return nil
+	}
+	history := make(map[string][]Attempt, len(src.URLs))
+	for _, url := range src.URLs {
+		history[url] = from.History(url)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, err := c.entry(path)
+	if err != nil {
+		return err
+	}
+	if doc.SHA256 == "" {
+		doc.SHA256 = src.SHA256
+		doc.Pages = src.Pages
+		doc.DownloadedAt = src.DownloadedAt
+		doc.Parts = src.Parts
+		doc.Meta = src.Meta
+		doc.Cover = src.Cover
+		doc.NeedsOCR, doc.Redactions = src.NeedsOCR, src.Redactions
+		doc.Parent = src.Parent
+		doc.Embedded = src.Embedded
+	}
+	for _, url := range src.URLs {
+		if prev, ok := c.byURL[url]; ok && prev != doc {
+			continue // already downloaded here to another document
+		}
+		if !contains(doc.URLs, url) {
+			doc.URLs = append(doc.URLs, url)
+		}
+		c.byURL[url] = doc
+		c.history[url] = mergeAttempts(c.history[url], history[url])
+		c.replaced[url] = true
+		delete(c.appended, url)
+	}
+	c.touch(doc.Path)
+	return nil
+}
+
+// mergeAttempts combines two fetch histories of a URL in time order, keeping
+// the newest maxAttempts
+func mergeAttempts(a, b []Attempt) []Attempt {
+	merged := append(append([]Attempt(nil), a...), b...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].At.Before(merged[j].At) })
+	// Trees merged more than once share history
+	merged = slices.CompactFunc(merged, func(x, y Attempt) bool {
+		return x.At.Equal(y.At) && x.Status == y.Status && x.Bytes == y.Bytes && x.TransferBytes == y.TransferBytes && x.Error == y.Error
+	})
+	if len(merged) > maxAttempts {
+		merged = merged[len(merged)-maxAttempts:]
+	}
+	return merged
+}
+
+// Save writes the catalog to the root of the documents tree if it has
+// changed
+func (c *Catalog) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	if c.format == FormatJSONL {
+		return c.saveLog()
+	}
+
+	f := file{Documents: make([]*Document, 0, len(c.docs)), History: c.history}
+	for _, doc := range c.docs {
+		f.Documents = append(f.Documents, doc)
+	}
+	// Sort so the file diffs cleanly between runs
+	sort.Slice(f.Documents, func(i, j int) bool {
+		return f.Documents[i].Path < f.Documents[j].Path
+	})
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+	if err := c.perms.MkdirAll(c.root); err != nil {
+		return fmt.Errorf("failed to create documents directory: %w", err)
+	}
+	if err := c.perms.WriteFile(c.Path(), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+	if c.rewrite {
+		// Converted from the log, which would otherwise take precedence
+		if err := os.Remove(filepath.Join(c.root, LogFileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove catalog log: %w", err)
+		}
+	}
+	c.saved()
+	return nil
+}
+
+// entry returns the entry for path, creating it if needed
+func (c *Catalog) entry(path string) (*Document, error) {
+	rel, ok := c.rel(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is outside the documents directory %s", path, c.root)
+	}
+	if doc, ok := c.docs[rel]; ok {
+		return doc, nil
+	}
+	doc := &Document{Path: rel}
+	c.add(doc)
+	return doc, nil
+}
+
+// add indexes doc by path and by each of its URLs
+func (c *Catalog) add(doc *Document) {
+	c.docs[doc.Path] = doc
+	for _, url := range doc.URLs {
+		c.byURL[url] = doc
+	}
+}
+
+// rel converts a path under the tree to a slash-separated path relative to
+// the root
+func (c *Catalog) rel(path string) (string, bool) {
+	rel, err := filepath.Rel(c.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	var kept []string
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
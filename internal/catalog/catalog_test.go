@@ -0,0 +1,173 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestRecordDownloadRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	docPath := filepath.Join(root, "pdf", "a", "a.pdf")
+	if err := os.MkdirAll(filepath.Dir(docPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(docPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := cat.RecordDownload("https://example.com/a.pdf", docPath, [32]byte{1}); err != nil {
+		t.Fatalf("RecordDownload() error = %v", err)
+	}
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	doc, path, ok := reloaded.Lookup("https://example.com/a.pdf")
+	if !ok {
+		t.Fatal("Lookup() found nothing after reload")
+	}
+	if path != docPath || doc.Path != "pdf/a/a.pdf" {
+		t.Errorf("Lookup() = %q (entry %q), want %q (entry pdf/a/a.pdf)", path, doc.Path, docPath)
+	}
+
+	// A catalogued URL whose document has been removed is not a hit
+	os.Remove(docPath)
+	if _, _, ok := reloaded.Lookup("https://example.com/a.pdf"); ok {
+		t.Error("Lookup() hit for a document that no longer exists")
+	}
+}
+
+func TestRecordDownloadOutsideTree(t *testing.T) {
+	cat, err := Load(t.TempDir(), pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.RecordDownload("https://example.com/b.pdf", "/elsewhere/b.pdf", [32]byte{}); err == nil {
+		t.Error("RecordDownload() outside the tree succeeded, want error")
+	}
+}
+
+func TestRecordAttemptHistory(t *testing.T) {
+	root := t.TempDir()
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	url := "https://example.com/a.pdf"
+	for i := 0; i < maxAttempts+5; i++ {
+		cat.RecordAttempt(url, Attempt{Status: 503, Bytes: int64(i), Error: "bad status: 503 Service Unavailable"})
+	}
+	cat.RecordAttempt(url, Attempt{Status: 200, Bytes: 100, Headers: map[string]string{"ETag": `"abc123"`}})
+	cat.RecordAttempt(url, Attempt{Status: 304, Headers: map[string]string{"ETag": `"def456"`}, Error: "bad status: 304 Not Modified"})
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	history := reloaded.History(url)
+	if len(history) != maxAttempts {
+		t.Fatalf("History() has %d attempts, want %d", len(history), maxAttempts)
+	}
+	if last := history[len(history)-2]; last.Failed() || last.Bytes != 100 {
+		t.Errorf("last successful attempt = %+v, want the 200", last)
+	}
+	if headers := reloaded.LastHeaders(url); headers["ETag"] != `"abc123"` {
+		t.Errorf("LastHeaders() = %v, want those of the last successful fetch", headers)
+	}
+	if first := history[0]; first.Bytes != 7 {
+		t.Errorf("oldest kept attempt = %+v, want the oldest ones dropped", first)
+	}
+	if _, ok := reloaded.ByURL(url); ok {
+		t.Error("ByURL() found a document for a URL that was never downloaded")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string) string {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	kept, gone := write("kept.pdf"), filepath.Join(root, "gone.pdf")
+	write("kept.extracted.txt")
+	cat.RecordDownload("https://example.com/kept.pdf", kept, [32]byte{1})
+	cat.RecordDownload("https://example.com/gone.pdf", gone, [32]byte{2})
+	cat.RecordOutput(kept, filepath.Join(root, "kept.extracted.txt"))
+	if err := cat.RecordParts(kept, []Part{
+		{Output: filepath.Join(root, "kept.extracted.txt"), StartPage: 1, EndPage: 2},
+		{Output: filepath.Join(root, "kept.part2.extracted.txt"), StartPage: 3, EndPage: 4},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, references := cat.Prune()
+	if len(entries) != 1 || entries[0] != "gone.pdf" {
+		t.Errorf("Prune() entries = %v, want [gone.pdf]", entries)
+	}
+	if references != 1 {
+		t.Errorf("Prune() references = %d, want 1 (the missing part)", references)
+	}
+	if _, ok := cat.ByURL("https://example.com/gone.pdf"); ok {
+		t.Error("ByURL() found the URL of a pruned document")
+	}
+	doc, ok := cat.Get(kept)
+	if !ok || doc.Output == "" || len(doc.Parts) != 1 {
+		t.Errorf("Get(kept.pdf) = %+v, %v; want its output and one part kept", doc, ok)
+	}
+}
+
+func TestRecordEmbedded(t *testing.T) {
+	root := t.TempDir()
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	portfolio := filepath.Join(root, "bundle.pdf")
+	child := filepath.Join(root, "bundle.portfolio", "Exhibit A.pdf")
+	cat.RecordDownload("https://example.com/bundle.pdf", portfolio, [32]byte{1})
+	for range 2 {
+		if err := cat.RecordEmbedded(portfolio, child, [32]byte{2}); err != nil {
+			t.Fatalf("RecordEmbedded() error = %v", err)
+		}
+	}
+	if err := cat.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, _ := reloaded.Get(portfolio)
+	if len(parent.Embedded) != 1 || parent.Embedded[0] != "bundle.portfolio/Exhibit A.pdf" {
+		t.Errorf("portfolio Embedded = %v, want the one child", parent.Embedded)
+	}
+	doc, ok := reloaded.Get(child)
+	if !ok || doc.Parent != "bundle.pdf" || doc.SHA256 == "" || len(doc.URLs) != 0 {
+		t.Errorf("Get(child) = %+v, %v; want it linked to bundle.pdf, without URLs", doc, ok)
+	}
+	if err := cat.RecordEmbedded(portfolio, filepath.Join(t.TempDir(), "x.pdf"), [32]byte{3}); err == nil {
+		t.Error("RecordEmbedded() outside the tree succeeded, want error")
+	}
+}
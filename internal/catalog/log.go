@@ -0,0 +1,247 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// LogFileName is the filename of the catalog kept as an append-only log at
+// the root of the documents tree. A tree has either this or catalog.json;
+// the log wins if both exist.
+//
+// Each line is a JSON record replacing what an earlier line said about one
+// document or URL, so a save appends only what changed in one write instead
+// of rewriting the file. This suits network filesystems, where rewriting and
+// renaming a shared file races with readers and locking is unreliable. Later
+// records win; Compact drops the records they superseded.
+const LogFileName = "catalog.jsonl"
+
+// record is one line of the catalog log. It holds exactly one of: the whole
+// entry of a document, the path of a document forgotten, a fetch attempt of
+// a URL, or the whole fetch history of a URL.
+type record struct {
+	Document *Document `json:"document,omitempty"`
+	Forget   string    `json:"forget,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Attempt  *Attempt  `json:"attempt,omitempty"`
+	History  []Attempt `json:"history,omitempty"`
+}
+
+// readLog replays the catalog log into c. A last line without a newline is
+// a save cut short and is ignored.
+func (c *Catalog) readLog() error {
+	file, err := os.Open(c.Path())
+	if err != nil {
+		return fmt.Errorf("failed to read catalog: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil // a torn or empty last line
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read catalog: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("failed to parse catalog %s line %d: %w", c.Path(), number, err)
+		}
+		c.apply(rec)
+	}
+}
+
+// apply replays one log record
+func (c *Catalog) apply(rec record) {
+	switch {
+	case rec.Document != nil:
+		c.drop(rec.Document.Path)
+		c.add(rec.Document)
+	case rec.Forget != "":
+		c.drop(rec.Forget)
+	case rec.URL != "" && rec.Attempt != nil:
+		attempts := append(c.history[rec.URL], *rec.Attempt)
+		if len(attempts) > maxAttempts {
+			attempts = attempts[len(attempts)-maxAttempts:]
+		}
+		c.history[rec.URL] = attempts
+	case rec.URL != "":
+		c.history[rec.URL] = rec.History
+	}
+}
+
+// drop removes the document at rel and the URLs still pointing to it
+func (c *Catalog) drop(rel string) {
+	doc, ok := c.docs[rel]
+	if !ok {
+		return
+	}
+	for _, url := range doc.URLs {
+		if c.byURL[url] == doc {
+			delete(c.byURL, url)
+		}
+	}
+	delete(c.docs, rel)
+}
+
+// saveLog appends the changes since the last save to the log in a single
+// write, or rewrites the log after a format change
+func (c *Catalog) saveLog() error {
+	if c.rewrite {
+		if err := c.writeLog(); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(c.root, FileName)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove catalog.json: %w", err)
+		}
+		c.saved()
+		return nil
+	}
+
+	var records []record
+	for _, rel := range sortedKeys(c.changed) {
+		if doc, ok := c.docs[rel]; ok {
+			records = append(records, record{Document: doc})
+		} else {
+			records = append(records, record{Forget: rel})
+		}
+	}
+	for _, url := range sortedKeys(c.replaced) {
+		records = append(records, record{URL: url, History: c.history[url]})
+	}
+	for _, url := range sortedKeys(c.appended) {
+		for _, attempt := range c.appended[url] {
+			records = append(records, record{URL: url, Attempt: &attempt})
+		}
+	}
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+
+	if err := c.perms.MkdirAll(c.root); err != nil {
+		return fmt.Errorf("failed to create documents directory: %w", err)
+	}
+	file, err := os.OpenFile(c.Path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, c.perms.FileMode())
+	if err != nil {
+		return fmt.Errorf("failed to open catalog: %w", err)
+	}
+	// One write, so saves from other processes are not interleaved within it
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to catalog: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to append to catalog: %w", err)
+	}
+	c.saved()
+	return nil
+}
+
+// writeLog atomically replaces the log with one record per document and per
+// URL with a fetch history
+func (c *Catalog) writeLog() error {
+	records := make([]record, 0, len(c.docs)+len(c.history))
+	for _, rel := range sortedKeys(c.docs) {
+		records = append(records, record{Document: c.docs[rel]})
+	}
+	for _, url := range sortedKeys(c.history) {
+		if len(c.history[url]) > 0 {
+			records = append(records, record{URL: url, History: c.history[url]})
+		}
+	}
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	if err := c.perms.MkdirAll(c.root); err != nil {
+		return fmt.Errorf("failed to create documents directory: %w", err)
+	}
+	if err := c.perms.WriteFile(c.Path(), data); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+	return nil
+}
+
+// Compact rewrites the catalog log with one record per document and URL,
+// dropping the records later ones superseded, and returns its size before
+// and after. Unsaved changes are saved first, and the log is read again so
+// records appended by other processes since Load are kept; records appended
+// while it is being rewritten are lost, so compact when no run is writing.
+func (c *Catalog) Compact() (before, after int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.format != FormatJSONL {
+		return 0, 0, errors.New("the catalog is not an append-only log")
+	}
+	if c.dirty {
+		if err := c.saveLog(); err != nil {
+			return 0, 0, err
+		}
+	}
+	info, err := os.Stat(c.Path())
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	fresh := newCatalog(c.root, c.perms)
+	fresh.format = FormatJSONL
+	if err := fresh.readLog(); err != nil {
+		return 0, 0, err
+	}
+	c.docs, c.byURL, c.history = fresh.docs, fresh.byURL, fresh.history
+	if err := c.writeLog(); err != nil {
+		return 0, 0, err
+	}
+	compacted, err := os.Stat(c.Path())
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read catalog: %w", err)
+	}
+	return info.Size(), compacted.Size(), nil
+}
+
+// saved clears what is pending for the next save
+func (c *Catalog) saved() {
+	c.dirty = false
+	c.rewrite = false
+	clear(c.changed)
+	clear(c.appended)
+	clear(c.replaced)
+}
+
+// encodeRecords encodes records as JSON Lines
+func encodeRecords(records []record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return nil, fmt.Errorf("failed to encode catalog: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// sortedKeys returns the keys of m in order, so the log diffs cleanly
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
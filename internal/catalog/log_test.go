@@ -0,0 +1,218 @@
+package catalog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// logCatalog returns the empty catalog of a new tree kept as a log
+func logCatalog(t *testing.T) (*Catalog, string) {
+	t.Helper()
+	root := t.TempDir()
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.SetFormat(FormatJSONL); err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	return cat, root
+}
+
+func TestLogAppendsChanges(t *testing.T) {
+	cat, root := logCatalog(t)
+	a, b := filepath.Join(root, "a.pdf"), filepath.Join(root, "b.pdf")
+	cat.RecordDownload("https://example.com/a.pdf", a, [32]byte{1})
+	cat.RecordAttempt("https://example.com/a.pdf", Attempt{At: time.Unix(1, 0), Status: 200})
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	before, _ := os.ReadFile(cat.Path())
+
+	// A second save only appends what changed since the first
+	cat.RecordDownload("https://example.com/b.pdf", b, [32]byte{2})
+	cat.RecordPages(a, 7)
+	cat.Forget(b)
+	if err := cat.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	after, _ := os.ReadFile(cat.Path())
+	if !bytes.HasPrefix(after, before) {
+		t.Fatal("Save() rewrote the log instead of appending to it")
+	}
+	if got := bytes.Count(after[len(before):], []byte("\n")); got != 2 {
+		t.Errorf("second Save() appended %d record(s), want 2 (a.pdf and forgetting b.pdf)", got)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if reloaded.Format() != FormatJSONL {
+		t.Errorf("Format() = %q, want %q", reloaded.Format(), FormatJSONL)
+	}
+	doc, ok := reloaded.Get(a)
+	if !ok || doc.Pages != 7 {
+		t.Errorf("Get(a.pdf) = %+v, %v; want 7 pages", doc, ok)
+	}
+	if _, ok := reloaded.Get(b); ok {
+		t.Error("Get(b.pdf) found a forgotten document")
+	}
+	if _, ok := reloaded.ByURL("https://example.com/b.pdf"); ok {
+		t.Error("ByURL() found the URL of a forgotten document")
+	}
+	if got := reloaded.History("https://example.com/a.pdf"); len(got) != 1 || got[0].Status != 200 {
+		t.Errorf("History() = %+v, want the one attempt", got)
+	}
+}
+
+func TestLogConcurrentWriters(t *testing.T) {
+	_, root := logCatalog(t)
+	// Two runs load the same log and each append their own documents
+	first, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.RecordDownload("https://example.com/a.pdf", filepath.Join(root, "a.pdf"), [32]byte{1})
+	second.RecordDownload("https://example.com/b.pdf", filepath.Join(root, "b.pdf"), [32]byte{2})
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(reloaded.Checksums()); got != 2 {
+		t.Errorf("reloaded catalog has %d document(s), want both runs' 2", got)
+	}
+}
+
+func TestLogIgnoresTornLastLine(t *testing.T) {
+	cat, root := logCatalog(t)
+	cat.RecordDownload("https://example.com/a.pdf", filepath.Join(root, "a.pdf"), [32]byte{1})
+	if err := cat.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// A save cut short leaves a partial line
+	f, err := os.OpenFile(cat.Path(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"document":{"path":"b.p`)
+	f.Close()
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := len(reloaded.Checksums()); got != 1 {
+		t.Errorf("reloaded catalog has %d document(s), want 1", got)
+	}
+
+	// A malformed complete line is an error
+	os.WriteFile(cat.Path(), []byte("{\"document\":\n"), 0644)
+	if _, err := Load(root, pathutil.Permissions{}); err == nil {
+		t.Error("Load() of a corrupt log succeeded, want error")
+	}
+}
+
+func TestLogCompact(t *testing.T) {
+	cat, root := logCatalog(t)
+	a := filepath.Join(root, "a.pdf")
+	cat.RecordDownload("https://example.com/a.pdf", a, [32]byte{1})
+	for pages := 1; pages <= 5; pages++ {
+		cat.RecordPages(a, pages)
+		cat.RecordAttempt("https://example.com/a.pdf", Attempt{At: time.Unix(int64(pages), 0)})
+		if err := cat.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Appended by another run after this one loaded
+	other, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.RecordDownload("https://example.com/b.pdf", filepath.Join(root, "b.pdf"), [32]byte{2})
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	before, after, err := cat.Compact()
+	if err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	if after >= before {
+		t.Errorf("Compact() went from %d to %d byte(s), want smaller", before, after)
+	}
+	data, _ := os.ReadFile(cat.Path())
+	if got := bytes.Count(data, []byte("\n")); got != 3 {
+		t.Errorf("compacted log has %d record(s), want 3 (two documents, one history)\n%s", got, data)
+	}
+
+	reloaded, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, ok := reloaded.Get(a); !ok || doc.Pages != 5 {
+		t.Errorf("Get(a.pdf) = %+v, %v; want 5 pages", doc, ok)
+	}
+	if _, ok := reloaded.ByURL("https://example.com/b.pdf"); !ok {
+		t.Error("Compact() dropped the other run's document")
+	}
+	if got := len(reloaded.History("https://example.com/a.pdf")); got != 5 {
+		t.Errorf("History() has %d attempt(s), want 5", got)
+	}
+}
+
+func TestSetFormatConverts(t *testing.T) {
+	root := t.TempDir()
+	cat, err := Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat.RecordDownload("https://example.com/a.pdf", filepath.Join(root, "a.pdf"), [32]byte{1})
+	if err := cat.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatJSONL, FormatJSON} {
+		if err := cat.SetFormat(format); err != nil {
+			t.Fatal(err)
+		}
+		if err := cat.Save(); err != nil {
+			t.Fatalf("Save() as %s error = %v", format, err)
+		}
+		reloaded, err := Load(root, pathutil.Permissions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.Format() != format {
+			t.Errorf("after converting, Format() = %q, want %q", reloaded.Format(), format)
+		}
+		if _, ok := reloaded.ByURL("https://example.com/a.pdf"); !ok {
+			t.Errorf("converting to %s lost the document", format)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, LogFileName)); !os.IsNotExist(err) {
+		t.Errorf("converting back to %s left %s behind", FormatJSON, LogFileName)
+	}
+	if err := cat.SetFormat("sqlite"); err == nil {
+		t.Error("SetFormat(sqlite) succeeded, want error")
+	}
+}
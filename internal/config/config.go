@@ -5,21 +5,238 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+
+	"defornicate-epstein-files/internal/pathutil"
 )
 
 // Config represents the application configuration.
 // It supports multiple ways to specify document sources:
+// - Source: Name of a built-in preset for a known public release
 // - URL: Single URL (for backward compatibility)
 // - URLs: Multiple URLs
 // - Pattern: Sequential pattern with {start-end} or {start:end}
 type Config struct {
-	URL     string   `json:"url"`      // Single URL (for backward compatibility, also accepts pdf_url)
-	URLs    []string `json:"urls"`     // Multiple URLs (also accepts pdf_urls)
-	Pattern string   `json:"pattern"`  // Pattern with {start-end} or {start:end} (also accepts pdf_pattern)
+	Source  string   `json:"source,omitempty"` // Built-in source preset name, see Presets
+	URL     string   `json:"url"`              // Single URL (for backward compatibility, also accepts pdf_url)
+	URLs    []string `json:"urls"`             // Multiple URLs (also accepts pdf_urls)
+	Pattern string   `json:"pattern"`          // Pattern with {start-end} or {start:end} (also accepts pdf_pattern)
+	// Filings pulled from the CourtListener/RECAP archive by docket number
+	CourtListener CourtListenerConfig `json:"courtlistener,omitempty"`
+	// Index pages whose links to documents are followed, one level deep
+	CrawlPages []string `json:"crawl_pages,omitempty"`
 	// Legacy fields for backward compatibility
-	PDFURL    string   `json:"pdf_url,omitempty"`
-	PDFURLs   []string `json:"pdf_urls,omitempty"`
-	PDFPattern string  `json:"pdf_pattern,omitempty"`
+	PDFURL     string   `json:"pdf_url,omitempty"`
+	PDFURLs    []string `json:"pdf_urls,omitempty"`
+	PDFPattern string   `json:"pdf_pattern,omitempty"`
+	// Network settings
+	DNSServer      string `json:"dns_server,omitempty"`       // Custom DNS server, e.g. "1.1.1.1" or "1.1.1.1:53"
+	IPPreference   string `json:"ip_preference,omitempty"`    // "ipv4" or "ipv6" to try that family first (default: system dual-stack)
+	SFTPKeyFile    string `json:"sftp_key_file,omitempty"`    // Private key for sftp:// URLs
+	SFTPKnownHosts string `json:"sftp_known_hosts,omitempty"` // known_hosts file for sftp:// URLs (default: ~/.ssh/known_hosts)
+	// Crawler identification
+	UserAgent string `json:"user_agent,omitempty"` // User-Agent for HTTP requests (default: a browser User-Agent)
+	Contact   string `json:"contact,omitempty"`    // URL or email appended to the User-Agent as "(+contact)"
+	From      string `json:"from,omitempty"`       // Email address sent in the From header
+	// Retry a download refused with 403 Forbidden once with a browser
+	// User-Agent, for hosts that block unknown agents (nil when unset, so an
+	// explicit false overrides the profile's)
+	RetryForbidden *bool `json:"retry_403_with_browser_agent,omitempty"`
+	// Extensions of the files taken from directories given as inputs, e.g.
+	// [".pdf"] (default: every supported document)
+	DirectoryExtensions []string `json:"directory_extensions,omitempty"`
+	// Checksum manifests (SHA256SUMS files, by URL or local path) that
+	// downloads are verified against; mismatches are quarantined
+	ChecksumManifests []string `json:"checksum_manifests,omitempty"`
+	// URLs and content checksums never downloaded: known-bad files,
+	// duplicates and documents out of scope
+	Skip SkipConfig `json:"skip,omitempty"`
+	// Crawl pacing for very large input lists
+	CrawlWindow   string `json:"crawl_window,omitempty"`   // Spread downloads over this duration, e.g. "24h" (default: as fast as possible)
+	CrawlInterval string `json:"crawl_interval,omitempty"` // Minimum gap between downloads when crawl_window is set, e.g. "2s" (default: 1s)
+	// Circuit breaker: stop requesting from a host after this many
+	// consecutive failures (network errors, 5xx, 429) for breaker_cooldown
+	BreakerFailures int    `json:"breaker_failures,omitempty"` // Consecutive failures that suspend a host (default: 5, -1 to disable)
+	BreakerCooldown string `json:"breaker_cooldown,omitempty"` // How long a failing host is suspended, e.g. "10m" (default: 5m)
+	// Documents downloaded in parallel ahead of extraction when several
+	// inputs are given (default: 1, one at a time); ignored when crawl_window
+	// paces the downloads
+	DownloadConcurrency int `json:"download_concurrency,omitempty"`
+	// Retries of downloads that fail transiently (network errors, transfers
+	// cut short, retry_statuses), waiting retry_delay before the first retry
+	// and twice as long before each one after
+	RetryAttempts int     `json:"retry_attempts,omitempty"` // Attempts per download, the first included (default: 3, 1 never retries)
+	RetryDelay    string  `json:"retry_delay,omitempty"`    // Wait before the first retry, e.g. "2s" (default: 1s)
+	RetryJitter   float64 `json:"retry_jitter,omitempty"`   // Random spread of each wait as a fraction of it (default: 0.2, -1 for none)
+	RetryStatuses []int   `json:"retry_statuses,omitempty"` // HTTP statuses retried (default: 429, 500, 502, 503, 504)
+	// Minimum gap between the starts of any two requests, e.g. "500ms"
+	// (default: none)
+	RequestInterval string `json:"request_interval,omitempty"`
+	// Named bundle of the download settings above ("aggressive", "normal"
+	// or "archival-polite"); settings given explicitly take precedence
+	Profile string `json:"profile,omitempty"`
+	// Storage settings
+	OutputCompression string `json:"output_compression,omitempty"` // "gzip" or "zstd" to compress extraction outputs (default: none)
+	MaxOutputSize     string `json:"max_output_size,omitempty"`    // Split JSON outputs larger than this into shards, e.g. "50M" (default: no limit)
+	FilePerm          string `json:"file_perm,omitempty"`          // Octal mode for written files, e.g. "0664" (default: 0644 filtered by umask)
+	DirPerm           string `json:"dir_perm,omitempty"`           // Octal mode for created directories, e.g. "0775" (default: 0755 filtered by umask)
+	// Never replace extraction outputs: write changed extractions as new
+	// versions (name.extracted.v2.json, ...) and readers use the latest
+	WriteOnce bool `json:"write_once,omitempty"`
+	// "jsonl" keeps the catalog as an append-only log (catalog.jsonl) that
+	// each run appends its changes to, for trees on network filesystems;
+	// "json" (the default) rewrites catalog.json
+	CatalogFormat string `json:"catalog_format,omitempty"`
+	// Stamp extraction outputs from the document (its modification time or
+	// $SOURCE_DATE_EPOCH, and its checksum) instead of the clock, so the same
+	// file always extracts to the same bytes
+	Reproducible bool `json:"reproducible,omitempty"`
+	// Write JSON extraction outputs without indentation or line breaks
+	CompactJSON bool `json:"compact_json,omitempty"`
+	// Apache Tika server (e.g. "http://localhost:9998") extracting the
+	// formats without a native extractor, and PDFs that yield no text
+	// (default: none, everything is extracted offline)
+	TikaURL string `json:"tika_url,omitempty"`
+	// Resource limits
+	MemoryBudget string `json:"memory_budget,omitempty"` // Cap on buffered document data, e.g. "1G"; larger downloads spill to disk (default: unlimited)
+	ScratchDir   string `json:"scratch_dir,omitempty"`   // Where temporary files (spilled downloads, OCR images) go, e.g. a fast local disk (default: $TMPDIR)
+	// Text extraction fallbacks, tried in order on pages the built-in
+	// extractor did poorly on
+	ExtractionFallbacks []FallbackConfig `json:"extraction_fallbacks,omitempty"`
+	// OCR photos and scans embedded in text pages into each page's
+	// image_text (needs pdfimages and tesseract)
+	OCRImages bool `json:"ocr_images,omitempty"`
+	// Merge the OCR text of embedded images into the page text line by
+	// line, keeping whichever source reads better (needs ocr_images)
+	MergeOCR bool `json:"merge_ocr,omitempty"`
+	// Remove the margin line numbers of deposition and hearing transcripts
+	// from the text, keeping each as the line's line_number
+	StripLineNumbers bool `json:"strip_line_numbers,omitempty"`
+	// Remove headers, footers and stamps repeated at the top or bottom of
+	// most pages from the text, listing them in metadata.boilerplate
+	StripBoilerplate bool `json:"strip_boilerplate,omitempty"`
+	// List the case reporter, Westlaw, Lexis and U.S. Code citations of each
+	// document, normalized, in metadata.citations of JSON outputs
+	FindCitations bool `json:"find_citations,omitempty"`
+	// Output destinations (default: a file next to each document)
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+	// Commands run on lifecycle events, with the event as JSON on stdin
+	Hooks []HookConfig `json:"hooks,omitempty"`
+	// Where verify --notify sends its report
+	Notify NotifyConfig `json:"notify,omitempty"`
+	// Encryption of sensitive exports at rest
+	Encryption EncryptionConfig `json:"encryption,omitempty"`
+	// Record each download and extraction, hash-chained, in .provenance.jsonl
+	// at the root of the documents tree
+	ProvenanceLog bool   `json:"provenance_log,omitempty"`
+	ProvenanceKey string `json:"provenance_key,omitempty"` // ed25519 private key (PEM) signing provenance entries (default: unsigned)
+}
+
+// FallbackConfig declares a text extraction backend to fall back to
+type FallbackConfig struct {
+	Backend  string `json:"backend"`             // "pdftotext"
+	MinWords int    `json:"min_words,omitempty"` // use it on pages with fewer words than this (default: pages with no text)
+}
+
+// SinkConfig declares one destination for extraction outputs
+type SinkConfig struct {
+	Type   string `json:"type"`             // "filesystem", "stdout", "elasticsearch", "s3" or "gcs"
+	Dir    string `json:"dir,omitempty"`    // filesystem: directory to write into (default: next to the document)
+	Name   string `json:"name,omitempty"`   // filesystem: output name template, e.g. "EFTA-{efta_number}" (default: the document's name)
+	URL    string `json:"url,omitempty"`    // elasticsearch: base URL, e.g. "http://localhost:9200"; s3: endpoint of an S3-compatible store (default: AWS)
+	Index  string `json:"index,omitempty"`  // elasticsearch: index name
+	Bucket string `json:"bucket,omitempty"` // s3, gcs: bucket to upload into, with credentials from the environment
+	Prefix string `json:"prefix,omitempty"` // s3, gcs: key prefix, e.g. "extracted/" (default: the bucket's root)
+	Region string `json:"region,omitempty"` // s3: region signed for (default: us-east-1)
+}
+
+// HookConfig declares a command run on a lifecycle event
+type HookConfig struct {
+	Event   string   `json:"event"`             // "download-complete", "extract-complete" or "failure"
+	Command []string `json:"command"`           // program and arguments, run without a shell
+	Timeout string   `json:"timeout,omitempty"` // kill the command after this long, e.g. "30s" (default: 1m)
+}
+
+// NotifyConfig declares where the reports of verification runs are sent
+type NotifyConfig struct {
+	Webhook  string   `json:"webhook,omitempty"`  // URL the report is POSTed to as JSON
+	SMTP     string   `json:"smtp,omitempty"`     // mail server as host:port, e.g. "smtp.example.org:587"
+	Username string   `json:"username,omitempty"` // SMTP login (default: send without authenticating)
+	Password string   `json:"password,omitempty"` // SMTP password (default: $DEFORNICATOR_SMTP_PASSWORD)
+	From     string   `json:"from,omitempty"`     // sender of report emails
+	To       []string `json:"to,omitempty"`       // recipients of report emails
+	Always   bool     `json:"always,omitempty"`   // also report runs that found nothing wrong (default: only problems)
+}
+
+// CourtListenerConfig selects dockets whose RECAP documents are downloaded
+type CourtListenerConfig struct {
+	Dockets []string `json:"dockets,omitempty"` // docket numbers, e.g. "1:15-cv-07433"
+	Court   string   `json:"court,omitempty"`   // CourtListener court ID, e.g. "nysd" (default: any court)
+	Token   string   `json:"token,omitempty"`   // API token (default: $COURTLISTENER_TOKEN)
+}
+
+// SkipConfig lists the inputs the downloader refuses
+type SkipConfig struct {
+	URLs      []string `json:"urls,omitempty"`      // URLs, or URL prefixes ending in "*"
+	Checksums []string `json:"checksums,omitempty"` // SHA-256 checksums of content, in hex
+}
+
+// Exports that can be encrypted at rest, for EncryptionConfig.Outputs
+const (
+	OutputEntities = "entities" // entities CSV
+	OutputQuotes   = "quotes"   // quotes CSV
+	OutputSpeech   = "speech"   // speech exports
+	OutputEvidence = "evidence" // evidence-export packages
+)
+
+// EncryptionConfig selects exports to encrypt with age, and to whom
+type EncryptionConfig struct {
+	Recipients []string `json:"recipients,omitempty"` // age public keys ("age1...") that can decrypt
+	Outputs    []string `json:"outputs,omitempty"`    // exports to encrypt: "entities", "quotes", "speech" and/or "evidence"
+}
+
+// EncryptionRecipients returns the recipients an export of the given kind is
+// encrypted to, or nil if it is written in the clear
+func (c *Config) EncryptionRecipients(output string) ([]age.Recipient, error) {
+	if !contains(c.Encryption.Outputs, output) {
+		return nil, nil
+	}
+	if len(c.Encryption.Recipients) == 0 {
+		return nil, fmt.Errorf("encryption: %s is to be encrypted but no recipients are configured", output)
+	}
+	var recipients []age.Recipient
+	for _, s := range c.Encryption.Recipients {
+		r, err := age.ParseX25519Recipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: %w", err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// CourtListenerToken returns the configured API token, falling back to the
+// COURTLISTENER_TOKEN environment variable so the token can stay out of the
+// config file
+func (c *Config) CourtListenerToken() string {
+	if c.CourtListener.Token != "" {
+		return c.CourtListener.Token
+	}
+	return os.Getenv("COURTLISTENER_TOKEN")
+}
+
+// SMTPPassword returns the configured SMTP password, falling back to the
+// DEFORNICATOR_SMTP_PASSWORD environment variable so the password can stay
+// out of the config file
+func (c *Config) SMTPPassword() string {
+	if c.Notify.Password != "" {
+		return c.Notify.Password
+	}
+	return os.Getenv("DEFORNICATOR_SMTP_PASSWORD")
 }
 
 // Load reads and parses the configuration file
@@ -53,7 +270,10 @@ func (c *Config) GetInputs() []string {
 		c.URL = c.PDFURL
 	}
 
-	// Priority: Pattern > URLs > URL
+	// Priority: Source > Pattern > URLs > URL
+	if c.Source != "" {
+		return []string{} // Will be expanded from the preset's patterns
+	}
 	if c.Pattern != "" {
 		return []string{} // Will be expanded by pattern expander
 	}
@@ -66,3 +286,189 @@ func (c *Config) GetInputs() []string {
 	return []string{}
 }
 
+// defaultCrawlInterval is the minimum gap between paced downloads when
+// crawl_interval is not set
+const defaultCrawlInterval = time.Second
+
+// CrawlSchedule returns the crawl window and minimum request interval. A
+// zero window means downloads are not paced.
+func (c *Config) CrawlSchedule() (window, interval time.Duration, err error) {
+	if c.CrawlWindow == "" {
+		return 0, 0, nil
+	}
+	window, err = parsePositiveDuration(c.CrawlWindow)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid crawl_window: %w", err)
+	}
+	interval = defaultCrawlInterval
+	if c.CrawlInterval != "" {
+		interval, err = parsePositiveDuration(c.CrawlInterval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid crawl_interval: %w", err)
+		}
+	}
+	return window, interval, nil
+}
+
+// Circuit breaker defaults when breaker_failures or breaker_cooldown is not
+// set
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 5 * time.Minute
+)
+
+// CircuitBreaker returns how many consecutive failures suspend a host and for
+// how long. Zero failures means the circuit breaker is disabled.
+func (c *Config) CircuitBreaker() (failures int, cooldown time.Duration, err error) {
+	if c.BreakerFailures < 0 {
+		return 0, 0, nil
+	}
+	failures = defaultBreakerFailures
+	if c.BreakerFailures > 0 {
+		failures = c.BreakerFailures
+	}
+	cooldown = defaultBreakerCooldown
+	if c.BreakerCooldown != "" {
+		cooldown, err = parsePositiveDuration(c.BreakerCooldown)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid breaker_cooldown: %w", err)
+		}
+	}
+	return failures, cooldown, nil
+}
+
+// Retry defaults when retry_attempts, retry_delay or retry_jitter is not set
+const (
+	defaultRetryAttempts = 3
+	defaultRetryDelay    = time.Second
+	defaultRetryJitter   = 0.2
+)
+
+// RetryPolicy is how downloads that fail transiently are retried
+type RetryPolicy struct {
+	Attempts int           // attempts per download, the first included
+	Delay    time.Duration // wait before the first retry, doubled before each one after
+	Jitter   float64       // random spread of each wait, as a fraction of it
+	Statuses []int         // HTTP statuses retried, nil for the downloader's defaults
+}
+
+// Retries returns the retry policy of downloads
+func (c *Config) Retries() (RetryPolicy, error) {
+	policy := RetryPolicy{Attempts: defaultRetryAttempts, Delay: defaultRetryDelay, Jitter: defaultRetryJitter, Statuses: c.RetryStatuses}
+	if c.RetryAttempts > 0 {
+		policy.Attempts = c.RetryAttempts
+	}
+	if c.RetryDelay != "" {
+		delay, err := parsePositiveDuration(c.RetryDelay)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("invalid retry_delay: %w", err)
+		}
+		policy.Delay = delay
+	}
+	switch {
+	case c.RetryJitter < 0:
+		policy.Jitter = 0
+	case c.RetryJitter > 0:
+		policy.Jitter = c.RetryJitter
+	}
+	return policy, nil
+}
+
+// RequestSpacing returns the minimum gap between requests, 0 when unset
+func (c *Config) RequestSpacing() (time.Duration, error) {
+	if c.RequestInterval == "" {
+		return 0, nil
+	}
+	interval, err := parsePositiveDuration(c.RequestInterval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid request_interval: %w", err)
+	}
+	return interval, nil
+}
+
+// parsePositiveDuration parses a Go duration such as "24h" or "2s"
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration (e.g. \"24h\", \"90m\", \"2s\")", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q must be positive", s)
+	}
+	return d, nil
+}
+
+// MemoryLimit returns the configured memory budget in bytes, or 0 when
+// unlimited
+func (c *Config) MemoryLimit() (int64, error) {
+	limit, err := ParseSize(c.MemoryBudget)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory_budget: %w", err)
+	}
+	return limit, nil
+}
+
+// OutputSizeLimit returns the size above which JSON outputs are sharded, in
+// bytes, or 0 when unlimited
+func (c *Config) OutputSizeLimit() (int64, error) {
+	limit, err := ParseSize(c.MaxOutputSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_output_size: %w", err)
+	}
+	return limit, nil
+}
+
+// ParseSize parses a byte count with an optional K, M or G suffix (powers of
+// 1024). An empty string is 0.
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	multiplier := 1.0
+	switch suffix := strings.ToUpper(s[len(s)-1:]); suffix {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	number := s
+	if multiplier != 1 {
+		number = s[:len(s)-1]
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size (e.g. 500K, 200M, 1.5G)", s)
+	}
+	return int64(value * multiplier), nil
+}
+
+// Permissions parses the configured file and directory modes. Unset modes are
+// left zero so the defaults (filtered by the umask) apply.
+func (c *Config) Permissions() (pathutil.Permissions, error) {
+	var perms pathutil.Permissions
+	fileMode, err := parseMode(c.FilePerm)
+	if err != nil {
+		return perms, fmt.Errorf("invalid file_perm: %w", err)
+	}
+	dirMode, err := parseMode(c.DirPerm)
+	if err != nil {
+		return perms, fmt.Errorf("invalid dir_perm: %w", err)
+	}
+	perms.File = fileMode
+	perms.Dir = dirMode
+	return perms, nil
+}
+
+// parseMode parses an octal permission string such as "0664"
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission between 0001 and 0777", s)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import "sort"
+
+// Preset is a built-in document source describing the URL patterns of a
+// known public release, selectable with "source" in the config file
+type Preset struct {
+	Name        string
+	Description string
+	Patterns    []string // sequential patterns, see pattern.ExpandPattern
+	Source      string   // URL of the published index the patterns were checked against
+}
+
+// presets holds the built-in sources. A release belongs here only once its
+// full ranges have been checked against the index the publisher lists them
+// in, recorded as the preset's Source; a partial range would have users
+// believe they pulled a complete set. None has been checked yet, so until
+// then releases are downloaded with "pattern" or "urls".
+var presets = map[string]Preset{}
+
+// LookupPreset returns the built-in source preset with the given name
+func LookupPreset(name string) (Preset, bool) {
+	preset, ok := presets[name]
+	return preset, ok
+}
+
+// Presets returns all built-in source presets sorted by name
+func Presets() []Preset {
+	list := make([]Preset, 0, len(presets))
+	for _, preset := range presets {
+		list = append(list, preset)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"defornicate-epstein-files/internal/pattern"
+)
+
+func TestPresets(t *testing.T) {
+	for name, preset := range presets {
+		if preset.Name != name {
+			t.Errorf("preset %q is named %q", name, preset.Name)
+		}
+		if preset.Description == "" {
+			t.Errorf("preset %q has no description", name)
+		}
+		if u, err := url.Parse(preset.Source); err != nil || u.Scheme != "https" {
+			t.Errorf("preset %q source = %q, want the https URL of the index its ranges were checked against", name, preset.Source)
+		}
+		if len(preset.Patterns) == 0 {
+			t.Errorf("preset %q has no patterns", name)
+		}
+		for _, p := range preset.Patterns {
+			urls, err := pattern.ExpandPattern(p)
+			if err != nil || len(urls) == 0 {
+				t.Errorf("preset %q pattern %q expands to %d URL(s), %v", name, p, len(urls), err)
+			}
+		}
+	}
+}
+
+func TestLookupPresetUnknown(t *testing.T) {
+	if _, ok := LookupPreset("house-oversight-2024"); ok {
+		t.Error("LookupPreset() found an unchecked release")
+	}
+	issues := validateValues(&Config{Source: "house-oversight-2024"}, nil)
+	if len(issues) != 1 || issues[0].Field != "source" {
+		t.Errorf("validateValues() = %+v, want an unknown source", issues)
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile is a named bundle of download settings, selectable with "profile"
+// in the config file or --profile, so the pace of a crawl is chosen in one
+// word instead of tuned knob by knob
+type Profile struct {
+	Name        string
+	Description string
+
+	DownloadConcurrency int    // see Config.DownloadConcurrency
+	RequestInterval     string // see Config.RequestInterval
+	BreakerFailures     int    // see Config.BreakerFailures
+	BreakerCooldown     string // see Config.BreakerCooldown
+	RetryForbidden      bool   // see Config.RetryForbidden
+	RetryAttempts       int    // see Config.RetryAttempts
+	RetryDelay          string // see Config.RetryDelay
+	UserAgent           string // see Config.UserAgent; "" keeps the browser User-Agent
+}
+
+// PoliteUserAgent identifies the tool honestly instead of as a browser, for
+// crawls of archives that ask crawlers to say who they are
+const PoliteUserAgent = "defornicate-epstein-files (archival crawler)"
+
+// profiles holds the built-in download profiles
+var profiles = map[string]Profile{
+	"aggressive": {
+		Name:                "aggressive",
+		Description:         "8 downloads at a time, no spacing, 5 quick attempts, retry 403s with a browser User-Agent, back off from a failing host only briefly",
+		DownloadConcurrency: 8,
+		BreakerFailures:     10,
+		BreakerCooldown:     "1m",
+		RetryForbidden:      true,
+		RetryAttempts:       5,
+		RetryDelay:          "500ms",
+	},
+	"normal": {
+		Name:                "normal",
+		Description:         "4 downloads at a time, no spacing, 3 attempts from 1s apart, suspend a host after 5 failures for 5 minutes",
+		DownloadConcurrency: 4,
+		BreakerFailures:     5,
+		BreakerCooldown:     "5m",
+		RetryAttempts:       3,
+		RetryDelay:          "1s",
+	},
+	"archival-polite": {
+		Name:                "archival-polite",
+		Description:         "one download at a time, 5s apart, 5 attempts from 10s apart, identify as a crawler (set contact too), suspend a host after 3 failures for 30 minutes",
+		DownloadConcurrency: 1,
+		RequestInterval:     "5s",
+		BreakerFailures:     3,
+		BreakerCooldown:     "30m",
+		RetryAttempts:       5,
+		RetryDelay:          "10s",
+		UserAgent:           PoliteUserAgent,
+	},
+}
+
+// LookupProfile returns the built-in download profile with the given name
+func LookupProfile(name string) (Profile, bool) {
+	profile, ok := profiles[name]
+	return profile, ok
+}
+
+// Profiles returns all built-in download profiles from the fastest to the
+// most polite
+func Profiles() []Profile {
+	list := make([]Profile, 0, len(profiles))
+	for _, profile := range profiles {
+		list = append(list, profile)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DownloadConcurrency > list[j].DownloadConcurrency })
+	return list
+}
+
+// ProfileNames returns the names of the built-in download profiles, in the
+// order of Profiles
+func ProfileNames() []string {
+	var names []string
+	for _, profile := range Profiles() {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// ApplyProfile applies the download settings of the named profile. The
+// profile named in the config file only fills the settings the file leaves
+// unset, so settings given next to it refine it. With override, as for
+// --profile, the profile's settings replace the file's instead, since a
+// profile chosen for one run is meant to set its pace; a configured
+// User-Agent is only replaced by a profile that has its own. An empty name
+// applies nothing.
+func (c *Config) ApplyProfile(name string, override bool) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := LookupProfile(name)
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	// Paced crawls download one at a time whatever the profile
+	if (override || c.DownloadConcurrency == 0) && c.CrawlWindow == "" {
+		c.DownloadConcurrency = profile.DownloadConcurrency
+	}
+	if override || c.RequestInterval == "" {
+		c.RequestInterval = profile.RequestInterval
+	}
+	if override || c.BreakerFailures == 0 {
+		c.BreakerFailures = profile.BreakerFailures
+	}
+	if override || (c.BreakerCooldown == "" && c.BreakerFailures != -1) {
+		c.BreakerCooldown = profile.BreakerCooldown
+	}
+	if override || c.RetryAttempts == 0 {
+		c.RetryAttempts = profile.RetryAttempts
+	}
+	if override || c.RetryDelay == "" {
+		c.RetryDelay = profile.RetryDelay
+	}
+	if override || c.RetryForbidden == nil {
+		retry := profile.RetryForbidden
+		c.RetryForbidden = &retry
+	}
+	if (override && profile.UserAgent != "") || c.UserAgent == "" {
+		c.UserAgent = profile.UserAgent
+	}
+	c.Profile = name
+	return nil
+}
+
+// RetryForbiddenEnabled reports whether downloads refused with 403 Forbidden
+// are retried with a browser User-Agent
+func (c *Config) RetryForbiddenEnabled() bool {
+	return c.RetryForbidden != nil && *c.RetryForbidden
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	cfg := &Config{BreakerFailures: -1, UserAgent: "MyCrawler/1.0"}
+	if err := cfg.ApplyProfile("archival-polite", false); err != nil {
+		t.Fatal(err)
+	}
+	// Unset settings come from the profile, explicit ones are kept
+	if cfg.DownloadConcurrency != 1 || cfg.RequestInterval != "5s" {
+		t.Errorf("concurrency %d, request_interval %q; want the profile's 1 and \"5s\"", cfg.DownloadConcurrency, cfg.RequestInterval)
+	}
+	if cfg.BreakerFailures != -1 || cfg.BreakerCooldown != "" {
+		t.Errorf("breaker %d/%q, want the disabled breaker kept", cfg.BreakerFailures, cfg.BreakerCooldown)
+	}
+	if cfg.UserAgent != "MyCrawler/1.0" {
+		t.Errorf("user_agent %q, want the configured one kept", cfg.UserAgent)
+	}
+
+	paced := &Config{CrawlWindow: "24h"}
+	if err := paced.ApplyProfile("aggressive", false); err != nil {
+		t.Fatal(err)
+	}
+	if paced.DownloadConcurrency != 0 || !paced.RetryForbiddenEnabled() {
+		t.Errorf("paced crawl: concurrency %d, retry %v; want 0 and true", paced.DownloadConcurrency, paced.RetryForbiddenEnabled())
+	}
+
+	if err := (&Config{}).ApplyProfile("reckless", false); err == nil {
+		t.Error("unknown profile accepted")
+	}
+	if names := ProfileNames(); len(names) != 3 || names[0] != "aggressive" || names[2] != "archival-polite" {
+		t.Errorf("ProfileNames() = %v", names)
+	}
+}
+
+func TestApplyProfileExplicitFalse(t *testing.T) {
+	// Turning the 403 retry off in the file refines "aggressive"
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"profile": "aggressive", "retry_403_with_browser_agent": false}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyProfile(cfg.Profile, false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RetryForbiddenEnabled() || cfg.RetryAttempts != 5 {
+		t.Errorf("retry 403 %v, attempts %d; want false and the profile's 5", cfg.RetryForbiddenEnabled(), cfg.RetryAttempts)
+	}
+}
+
+func TestApplyProfileOverride(t *testing.T) {
+	// --profile replaces the pace set in the file
+	cfg := &Config{Profile: "aggressive", DownloadConcurrency: 16, RetryAttempts: 8, UserAgent: "MyCrawler/1.0"}
+	if err := cfg.ApplyProfile("archival-polite", true); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DownloadConcurrency != 1 || cfg.RetryAttempts != 5 || cfg.RequestInterval != "5s" || cfg.Profile != "archival-polite" {
+		t.Errorf("concurrency %d, attempts %d, request_interval %q, profile %q; want archival-polite's", cfg.DownloadConcurrency, cfg.RetryAttempts, cfg.RequestInterval, cfg.Profile)
+	}
+	if cfg.UserAgent != PoliteUserAgent {
+		t.Errorf("user_agent %q, want the profile's", cfg.UserAgent)
+	}
+
+	// A profile without a User-Agent of its own keeps the configured one
+	cfg = &Config{UserAgent: "MyCrawler/1.0", RequestInterval: "2s"}
+	if err := cfg.ApplyProfile("normal", true); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.UserAgent != "MyCrawler/1.0" || cfg.RequestInterval != "" {
+		t.Errorf("user_agent %q, request_interval %q; want the configured agent and normal's no spacing", cfg.UserAgent, cfg.RequestInterval)
+	}
+}
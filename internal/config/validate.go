@@ -0,0 +1,451 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	"filippo.io/age"
+
+	"defornicate-epstein-files/internal/pattern"
+)
+
+// Issue is a problem found while validating a config file
+type Issue struct {
+	Line    int    // 1-based line number, 0 if not tied to a line
+	Field   string // JSON key the issue refers to, if any
+	Message string
+}
+
+// String formats the issue as "line N: field: message"
+func (i Issue) String() string {
+	var parts []string
+	if i.Line > 0 {
+		parts = append(parts, fmt.Sprintf("line %d", i.Line))
+	}
+	if i.Field != "" {
+		parts = append(parts, i.Field)
+	}
+	parts = append(parts, i.Message)
+	return strings.Join(parts, ": ")
+}
+
+// Allowed values for enumerated settings
+var (
+	validIPPreferences  = []string{"", "ipv4", "ipv6"}
+	validCompressions   = []string{"", "gzip", "zstd"}
+	validCatalogFormats = []string{"", "json", "jsonl"}
+	validSinkTypes      = []string{"filesystem", "stdout", "elasticsearch", "s3", "gcs"}
+	validBackends       = []string{"pdftotext", "tesseract"}
+	validOutputs        = []string{OutputEntities, OutputQuotes, OutputSpeech, OutputEvidence}
+	validHookEvents     = []string{"download-complete", "extract-complete", "failure"}
+)
+
+// conflictingFields lists pairs of fields that should not be set together,
+// since only one of them takes effect
+var conflictingFields = [][2]string{
+	{"source", "pattern"},
+	{"source", "urls"},
+	{"source", "url"},
+	{"pattern", "urls"},
+	{"pattern", "url"},
+	{"urls", "url"},
+	{"source", "courtlistener"},
+	{"pattern", "courtlistener"},
+	{"urls", "courtlistener"},
+	{"url", "courtlistener"},
+	{"source", "crawl_pages"},
+	{"pattern", "crawl_pages"},
+	{"urls", "crawl_pages"},
+	{"url", "crawl_pages"},
+	{"courtlistener", "crawl_pages"},
+	{"pattern", "pdf_pattern"},
+	{"urls", "pdf_urls"},
+	{"url", "pdf_url"},
+}
+
+// Validate strictly checks a config file and reports unknown fields, type
+// mismatches, conflicting options and invalid values with line numbers.
+// The returned error is only for failures to read the file.
+func Validate(configPath string) ([]Issue, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	return ValidateBytes(data), nil
+}
+
+// ValidateBytes is Validate for config content already in memory
+func ValidateBytes(data []byte) []Issue {
+	keys, lines, err := topLevelKeys(data)
+	if err != nil {
+		return []Issue{syntaxIssue(data, err)}
+	}
+
+	var issues []Issue
+	fields := configFields()
+	var cfg Config
+	cfgValue := reflect.ValueOf(&cfg).Elem()
+
+	for _, key := range keys.order {
+		index, ok := fields[key]
+		if !ok {
+			issues = append(issues, Issue{Line: lines[key], Field: key, Message: "unknown field" + suggestField(key, fields)})
+			continue
+		}
+		field := cfgValue.Field(index)
+		if err := json.Unmarshal(keys.values[key], field.Addr().Interface()); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				issues = append(issues, Issue{Line: lines[key], Field: key, Message: fmt.Sprintf("expected %s, got %s", typeName(field.Type()), typeErr.Value)})
+			} else {
+				issues = append(issues, Issue{Line: lines[key], Field: key, Message: err.Error()})
+			}
+		}
+	}
+
+	set := func(key string) bool {
+		_, ok := keys.values[key]
+		return ok
+	}
+	for _, pair := range conflictingFields {
+		if set(pair[0]) && set(pair[1]) {
+			issues = append(issues, Issue{Line: lines[pair[1]], Field: pair[1], Message: fmt.Sprintf("conflicts with %q (only one is used)", pair[0])})
+		}
+	}
+
+	issues = append(issues, validateValues(&cfg, lines)...)
+	return issues
+}
+
+// validateValues checks the values of fields that decoded successfully
+func validateValues(cfg *Config, lines map[string]int) []Issue {
+	var issues []Issue
+	invalid := func(key, message string) {
+		issues = append(issues, Issue{Line: lines[key], Field: key, Message: message})
+	}
+
+	if cfg.Source != "" {
+		if _, ok := LookupPreset(cfg.Source); !ok {
+			var names []string
+			for _, preset := range Presets() {
+				names = append(names, preset.Name)
+			}
+			if len(names) == 0 {
+				invalid("source", fmt.Sprintf("unknown preset %q (no presets are built in; use \"pattern\" or \"urls\")", cfg.Source))
+			} else {
+				invalid("source", fmt.Sprintf("unknown preset %q (available: %s)", cfg.Source, strings.Join(names, ", ")))
+			}
+		}
+	}
+	if cfg.Profile != "" {
+		if _, ok := LookupProfile(cfg.Profile); !ok {
+			invalid("profile", fmt.Sprintf("unknown profile %q (available: %s)", cfg.Profile, strings.Join(ProfileNames(), ", ")))
+		}
+	}
+	for _, field := range []struct{ key, value string }{{"pattern", cfg.Pattern}, {"pdf_pattern", cfg.PDFPattern}} {
+		if field.value != "" {
+			if _, err := pattern.ExpandPattern(field.value); err != nil {
+				invalid(field.key, err.Error())
+			}
+		}
+	}
+	if _, ok := lines["courtlistener"]; ok && len(cfg.CourtListener.Dockets) == 0 {
+		invalid("courtlistener", "needs at least one docket number in \"dockets\"")
+	}
+	for _, page := range cfg.CrawlPages {
+		if u, err := url.Parse(page); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("crawl_pages", fmt.Sprintf("%q is not an http(s) URL", page))
+		}
+	}
+	for _, url := range cfg.Skip.URLs {
+		if strings.TrimSpace(url) == "" || strings.TrimSpace(url) == "*" {
+			invalid("skip", fmt.Sprintf("invalid URL %q in \"urls\"", url))
+		}
+	}
+	for _, sum := range cfg.Skip.Checksums {
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+			invalid("skip", fmt.Sprintf("invalid checksum %q in \"checksums\" (expected 64 hex digits of SHA-256)", sum))
+		}
+	}
+	if !contains(validIPPreferences, cfg.IPPreference) {
+		invalid("ip_preference", fmt.Sprintf("invalid value %q (expected \"ipv4\" or \"ipv6\")", cfg.IPPreference))
+	}
+	if !contains(validCompressions, cfg.OutputCompression) {
+		invalid("output_compression", fmt.Sprintf("invalid value %q (expected \"gzip\" or \"zstd\")", cfg.OutputCompression))
+	}
+	if !contains(validCatalogFormats, cfg.CatalogFormat) {
+		invalid("catalog_format", fmt.Sprintf("invalid value %q (expected \"json\" or \"jsonl\")", cfg.CatalogFormat))
+	}
+	for _, field := range []struct{ key, value string }{{"crawl_window", cfg.CrawlWindow}, {"crawl_interval", cfg.CrawlInterval}, {"breaker_cooldown", cfg.BreakerCooldown}, {"request_interval", cfg.RequestInterval}, {"retry_delay", cfg.RetryDelay}} {
+		if field.value != "" {
+			if _, err := parsePositiveDuration(field.value); err != nil {
+				invalid(field.key, err.Error())
+			}
+		}
+	}
+	if cfg.CrawlInterval != "" && cfg.CrawlWindow == "" {
+		invalid("crawl_interval", "has no effect without crawl_window")
+	}
+	if cfg.BreakerFailures < -1 {
+		invalid("breaker_failures", fmt.Sprintf("invalid value %d (expected a positive number, or -1 to disable)", cfg.BreakerFailures))
+	}
+	if cfg.BreakerCooldown != "" && cfg.BreakerFailures == -1 {
+		invalid("breaker_cooldown", "has no effect when breaker_failures is -1")
+	}
+	if cfg.RetryAttempts < 0 {
+		invalid("retry_attempts", fmt.Sprintf("invalid value %d (expected a positive number)", cfg.RetryAttempts))
+	}
+	if cfg.RetryJitter > 1 || (cfg.RetryJitter < 0 && cfg.RetryJitter != -1) {
+		invalid("retry_jitter", fmt.Sprintf("invalid value %g (expected a fraction between 0 and 1, or -1 for none)", cfg.RetryJitter))
+	}
+	for _, status := range cfg.RetryStatuses {
+		if status < 400 || status > 599 {
+			invalid("retry_statuses", fmt.Sprintf("invalid status %d (expected an HTTP error status, 400-599)", status))
+		}
+	}
+	if cfg.DownloadConcurrency < 0 {
+		invalid("download_concurrency", fmt.Sprintf("invalid value %d (expected a positive number)", cfg.DownloadConcurrency))
+	} else if cfg.DownloadConcurrency > 1 && cfg.CrawlWindow != "" {
+		invalid("download_concurrency", "has no effect when crawl_window is set")
+	}
+	if cfg.From != "" {
+		if addr, err := mail.ParseAddress(cfg.From); err != nil || addr.Name != "" {
+			invalid("from", fmt.Sprintf("%q is not a plain email address", cfg.From))
+		}
+	}
+	if cfg.Contact != "" && !isContact(cfg.Contact) {
+		invalid("contact", fmt.Sprintf("%q is not an http(s) URL or email address", cfg.Contact))
+	}
+	if strings.ContainsAny(cfg.UserAgent, "\r\n") {
+		invalid("user_agent", "must be a single line")
+	}
+	for _, ext := range cfg.DirectoryExtensions {
+		if strings.TrimPrefix(ext, ".") == "" || strings.ContainsAny(ext, `/\*?`) {
+			invalid("directory_extensions", fmt.Sprintf("%q is not a file extension such as \".pdf\"", ext))
+		}
+	}
+	for i, fc := range cfg.ExtractionFallbacks {
+		switch {
+		case !contains(validBackends, fc.Backend):
+			invalid("extraction_fallbacks", fmt.Sprintf("fallback %d: invalid backend %q (expected one of %s)", i+1, fc.Backend, strings.Join(validBackends, ", ")))
+		case fc.MinWords < 0:
+			invalid("extraction_fallbacks", fmt.Sprintf("fallback %d: min_words must not be negative", i+1))
+		}
+	}
+	if cfg.TikaURL != "" {
+		if u, err := url.Parse(cfg.TikaURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("tika_url", fmt.Sprintf("%q is not an http(s) URL", cfg.TikaURL))
+		}
+	}
+	if cfg.MergeOCR && !cfg.OCRImages {
+		invalid("merge_ocr", "has no effect without ocr_images")
+	}
+	for i, sc := range cfg.Sinks {
+		switch {
+		case !contains(validSinkTypes, sc.Type):
+			invalid("sinks", fmt.Sprintf("sink %d: invalid type %q (expected one of %s)", i+1, sc.Type, strings.Join(validSinkTypes, ", ")))
+		case sc.Type == "elasticsearch" && (sc.URL == "" || sc.Index == ""):
+			invalid("sinks", fmt.Sprintf("sink %d: elasticsearch needs \"url\" and \"index\"", i+1))
+		case (sc.Type == "s3" || sc.Type == "gcs") && sc.Bucket == "":
+			invalid("sinks", fmt.Sprintf("sink %d: %s needs \"bucket\"", i+1, sc.Type))
+		case sc.Type == "gcs" && (sc.URL != "" || sc.Region != ""):
+			invalid("sinks", fmt.Sprintf("sink %d: gcs takes no \"url\" or \"region\"", i+1))
+		case sc.Name != "" && sc.Type != "filesystem":
+			invalid("sinks", fmt.Sprintf("sink %d: \"name\" only applies to filesystem sinks", i+1))
+		}
+	}
+	for i, hc := range cfg.Hooks {
+		switch {
+		case !contains(validHookEvents, hc.Event):
+			invalid("hooks", fmt.Sprintf("hook %d: invalid event %q (expected one of %s)", i+1, hc.Event, strings.Join(validHookEvents, ", ")))
+		case len(hc.Command) == 0 || hc.Command[0] == "":
+			invalid("hooks", fmt.Sprintf("hook %d: needs a \"command\"", i+1))
+		case hc.Timeout != "":
+			if _, err := parsePositiveDuration(hc.Timeout); err != nil {
+				invalid("hooks", fmt.Sprintf("hook %d: timeout %v", i+1, err))
+			}
+		}
+	}
+	if cfg.Notify.Webhook != "" {
+		if u, err := url.Parse(cfg.Notify.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("notify", fmt.Sprintf("webhook %q is not an http(s) URL", cfg.Notify.Webhook))
+		}
+	}
+	if cfg.Notify.SMTP != "" {
+		if host, port, err := net.SplitHostPort(cfg.Notify.SMTP); err != nil || host == "" || port == "" {
+			invalid("notify", fmt.Sprintf("smtp %q is not a host:port address", cfg.Notify.SMTP))
+		}
+	}
+	for _, addr := range append([]string{cfg.Notify.From}, cfg.Notify.To...) {
+		if a, err := mail.ParseAddress(addr); addr != "" && (err != nil || a.Name != "") {
+			invalid("notify", fmt.Sprintf("%q is not a plain email address", addr))
+		}
+	}
+	if len(cfg.Notify.To) > 0 && (cfg.Notify.SMTP == "" || cfg.Notify.From == "") {
+		invalid("notify", "sending email needs \"smtp\" and \"from\"")
+	}
+	for _, output := range cfg.Encryption.Outputs {
+		if !contains(validOutputs, output) {
+			invalid("encryption", fmt.Sprintf("invalid output %q (expected one of %s)", output, strings.Join(validOutputs, ", ")))
+		}
+	}
+	for _, r := range cfg.Encryption.Recipients {
+		if _, err := age.ParseX25519Recipient(r); err != nil {
+			invalid("encryption", err.Error())
+		}
+	}
+	if len(cfg.Encryption.Outputs) > 0 && len(cfg.Encryption.Recipients) == 0 {
+		invalid("encryption", "needs at least one age recipient in \"recipients\"")
+	}
+	if cfg.ProvenanceKey != "" && !cfg.ProvenanceLog {
+		invalid("provenance_key", "has no effect without \"provenance_log\"")
+	}
+	if _, err := ParseSize(cfg.MemoryBudget); err != nil {
+		invalid("memory_budget", err.Error())
+	}
+	if _, err := ParseSize(cfg.MaxOutputSize); err != nil {
+		invalid("max_output_size", err.Error())
+	}
+	if _, err := parseMode(cfg.FilePerm); err != nil {
+		invalid("file_perm", err.Error())
+	}
+	if _, err := parseMode(cfg.DirPerm); err != nil {
+		invalid("dir_perm", err.Error())
+	}
+	return issues
+}
+
+// rawObject holds the top-level keys of a JSON object in file order
+type rawObject struct {
+	order  []string
+	values map[string]json.RawMessage
+}
+
+// topLevelKeys decodes the top-level object of data, recording the line each
+// key appears on
+func topLevelKeys(data []byte) (rawObject, map[string]int, error) {
+	obj := rawObject{values: make(map[string]json.RawMessage)}
+	lines := make(map[string]int)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return obj, lines, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return obj, lines, fmt.Errorf("config must be a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return obj, lines, err
+		}
+		key := tok.(string)
+		lines[key] = lineAt(data, dec.InputOffset())
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return obj, lines, err
+		}
+		if _, dup := obj.values[key]; !dup {
+			obj.order = append(obj.order, key)
+		}
+		obj.values[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return obj, lines, err
+	}
+	return obj, lines, nil
+}
+
+// syntaxIssue converts a JSON decoding error into an issue with a line number
+func syntaxIssue(data []byte, err error) Issue {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return Issue{Line: lineAt(data, syntaxErr.Offset), Message: "invalid JSON: " + syntaxErr.Error()}
+	}
+	return Issue{Message: "invalid JSON: " + err.Error()}
+}
+
+// configFields maps JSON keys to Config struct field indexes
+func configFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+// suggestField returns a "did you mean" hint for a misspelled key
+func suggestField(key string, fields map[string]int) string {
+	normalized := strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	// Prefer an exact match so "URL" suggests "url" rather than "urls"
+	if _, ok := fields[normalized]; ok {
+		return fmt.Sprintf(" (did you mean %q?)", normalized)
+	}
+	for name := range fields {
+		if name == normalized+"s" || name+"s" == normalized {
+			return fmt.Sprintf(" (did you mean %q?)", name)
+		}
+	}
+	return ""
+}
+
+// typeName describes a Go type in JSON terms for error messages
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		elem := typeName(t.Elem())
+		return "a list of " + strings.TrimPrefix(strings.TrimPrefix(elem, "a "), "an ") + "s"
+	case reflect.Struct:
+		return "an object"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	default:
+		return t.String()
+	}
+}
+
+// lineAt returns the 1-based line number of a byte offset in data
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// isContact reports whether s is an http(s) URL or an email address
+func isContact(s string) bool {
+	if u, err := url.Parse(s); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return true
+	}
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Name == ""
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []Issue
+	}{
+		{
+			name:   "valid config",
+			config: "{\n  \"pattern\": \"EFTA{1-3}.pdf\",\n  \"file_perm\": \"0664\"\n}",
+			want:   nil,
+		},
+		{
+			name:   "unknown field with suggestion",
+			config: "{\n  \"url\": \"a.pdf\",\n  \"URL\": \"b.pdf\",\n  \"timeout\": 5\n}",
+			want: []Issue{
+				{Line: 3, Field: "URL", Message: `unknown field (did you mean "url"?)`},
+				{Line: 4, Field: "timeout", Message: "unknown field"},
+			},
+		},
+		{
+			name:   "type mismatch",
+			config: "{\n  \"urls\": \"a.pdf\"\n}",
+			want: []Issue{
+				{Line: 2, Field: "urls", Message: "expected a list of strings, got string"},
+			},
+		},
+		{
+			name:   "conflicting sources",
+			config: "{\n  \"pattern\": \"EFTA{1-3}.pdf\",\n  \"urls\": [\"a.pdf\"]\n}",
+			want: []Issue{
+				{Line: 3, Field: "urls", Message: `conflicts with "pattern" (only one is used)`},
+			},
+		},
+		{
+			name:   "invalid values",
+			config: "{\n  \"pattern\": \"EFTA{9-3}.pdf\",\n  \"ip_preference\": \"v4\",\n  \"dir_perm\": \"rwx\"\n}",
+			want: []Issue{
+				{Line: 2, Field: "pattern", Message: "start number (9) must be <= end number (3)"},
+				{Line: 3, Field: "ip_preference", Message: `invalid value "v4" (expected "ipv4" or "ipv6")`},
+				{Line: 4, Field: "dir_perm", Message: `"rwx" is not an octal permission between 0001 and 0777`},
+			},
+		},
+		{
+			name:   "crawler identification",
+			config: "{\n  \"contact\": \"ftp://example.org\",\n  \"from\": \"Archive Bot <bot@example.org>\"\n}",
+			want: []Issue{
+				{Line: 3, Field: "from", Message: `"Archive Bot <bot@example.org>" is not a plain email address`},
+				{Line: 2, Field: "contact", Message: `"ftp://example.org" is not an http(s) URL or email address`},
+			},
+		},
+		{
+			name:   "invalid extraction fallbacks",
+			config: "{\n  \"extraction_fallbacks\": [{\"backend\": \"pdftotext\", \"min_words\": 50}, {\"backend\": \"ocr\"}]\n}",
+			want: []Issue{
+				{Line: 2, Field: "extraction_fallbacks", Message: `fallback 2: invalid backend "ocr" (expected one of pdftotext, tesseract)`},
+			},
+		},
+		{
+			name:   "invalid notify",
+			config: "{\n  \"notify\": {\"webhook\": \"ftp://example.org\", \"smtp\": \"smtp.example.org\", \"to\": [\"Curator <curator@example.org>\"]}\n}",
+			want: []Issue{
+				{Line: 2, Field: "notify", Message: `webhook "ftp://example.org" is not an http(s) URL`},
+				{Line: 2, Field: "notify", Message: `smtp "smtp.example.org" is not a host:port address`},
+				{Line: 2, Field: "notify", Message: `"Curator <curator@example.org>" is not a plain email address`},
+				{Line: 2, Field: "notify", Message: `sending email needs "smtp" and "from"`},
+			},
+		},
+		{
+			name:   "invalid sinks",
+			config: "{\n  \"sinks\": [{\"type\": \"sqlite\"}, {\"type\": \"elasticsearch\", \"index\": \"docs\"}, {\"type\": \"stdout\", \"name\": \"{efta_number}\"}, {\"type\": \"s3\", \"prefix\": \"extracted/\"}, {\"type\": \"gcs\", \"bucket\": \"docs\", \"region\": \"eu\"}]\n}",
+			want: []Issue{
+				{Line: 2, Field: "sinks", Message: `sink 1: invalid type "sqlite" (expected one of filesystem, stdout, elasticsearch, s3, gcs)`},
+				{Line: 2, Field: "sinks", Message: `sink 2: elasticsearch needs "url" and "index"`},
+				{Line: 2, Field: "sinks", Message: `sink 3: "name" only applies to filesystem sinks`},
+				{Line: 2, Field: "sinks", Message: `sink 4: s3 needs "bucket"`},
+				{Line: 2, Field: "sinks", Message: `sink 5: gcs takes no "url" or "region"`},
+			},
+		},
+		{
+			name:   "courtlistener without dockets",
+			config: "{\n  \"urls\": [\"a.pdf\"],\n  \"courtlistener\": {\"court\": \"nysd\"}\n}",
+			want: []Issue{
+				{Line: 3, Field: "courtlistener", Message: `conflicts with "urls" (only one is used)`},
+				{Line: 3, Field: "courtlistener", Message: `needs at least one docket number in "dockets"`},
+			},
+		},
+		{
+			name:   "crawl pages",
+			config: "{\n  \"pattern\": \"EFTA{1-2}.pdf\",\n  \"crawl_pages\": [\"https://www.justice.gov/epstein/files/\", \"files/index.html\"]\n}",
+			want: []Issue{
+				{Line: 3, Field: "crawl_pages", Message: `conflicts with "pattern" (only one is used)`},
+				{Line: 3, Field: "crawl_pages", Message: `"files/index.html" is not an http(s) URL`},
+			},
+		},
+		{
+			name:   "encryption recipients",
+			config: "{\n  \"encryption\": {\"outputs\": [\"quotes\"], \"recipients\": [\"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p\", \"age1notakey\"]}\n}",
+			want: []Issue{
+				{Line: 2, Field: "encryption", Message: `malformed recipient "age1notakey": invalid character data part: s[1]=111`},
+			},
+		},
+		{
+			name:   "invalid encryption",
+			config: "{\n  \"encryption\": {\"outputs\": [\"entities\", \"extracted\"]}\n}",
+			want: []Issue{
+				{Line: 2, Field: "encryption", Message: `invalid output "extracted" (expected one of entities, quotes, speech, evidence)`},
+				{Line: 2, Field: "encryption", Message: `needs at least one age recipient in "recipients"`},
+			},
+		},
+		{
+			name:   "invalid circuit breaker",
+			config: "{\n  \"breaker_failures\": -2,\n  \"breaker_cooldown\": \"soon\"\n}",
+			want: []Issue{
+				{Line: 3, Field: "breaker_cooldown", Message: `"soon" is not a duration (e.g. "24h", "90m", "2s")`},
+				{Line: 2, Field: "breaker_failures", Message: "invalid value -2 (expected a positive number, or -1 to disable)"},
+			},
+		},
+		{
+			name:   "invalid retry policy",
+			config: "{\n  \"retry_attempts\": -1,\n  \"retry_jitter\": 1.5,\n  \"retry_statuses\": [503, 200]\n}",
+			want: []Issue{
+				{Line: 2, Field: "retry_attempts", Message: "invalid value -1 (expected a positive number)"},
+				{Line: 3, Field: "retry_jitter", Message: "invalid value 1.5 (expected a fraction between 0 and 1, or -1 for none)"},
+				{Line: 4, Field: "retry_statuses", Message: "invalid status 200 (expected an HTTP error status, 400-599)"},
+			},
+		},
+		{
+			name:   "download concurrency with crawl pacing",
+			config: "{\n  \"crawl_window\": \"24h\",\n  \"download_concurrency\": 4\n}",
+			want: []Issue{
+				{Line: 3, Field: "download_concurrency", Message: "has no effect when crawl_window is set"},
+			},
+		},
+		{
+			name:   "invalid memory budget",
+			config: "{\n  \"memory_budget\": \"2 GB\"\n}",
+			want: []Issue{
+				{Line: 2, Field: "memory_budget", Message: `"2 GB" is not a positive size (e.g. 500K, 200M, 1.5G)`},
+			},
+		},
+		{
+			name:   "sinks not a list",
+			config: "{\n  \"sinks\": \"stdout\"\n}",
+			want: []Issue{
+				{Line: 2, Field: "sinks", Message: "expected a list of objects, got string"},
+			},
+		},
+		{
+			name:   "syntax error",
+			config: "{\n  \"url\": \"a.pdf\",\n}",
+			want: []Issue{
+				{Line: 2, Message: "invalid JSON: invalid character ',' looking for beginning of value"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateBytes([]byte(tt.config))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateBytes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Package corpus provides operations on whole documents trees, such as
+// merging two trees and copying out a subset of documents.
+package corpus
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// Result summarizes a merge or subset operation
+type Result struct {
+	Copied     []string // documents copied into the destination (relative paths)
+	Duplicates []string // documents skipped because identical content already exists
+	Conflicts  []string // documents skipped because a different file exists at the same path
+}
+
+// Merge copies every document under src (with its extraction outputs) into
+// dst, preserving the {type}/{name}/ layout. Documents whose content already
+// exists anywhere in dst are skipped, as are documents that would overwrite a
+// different file at the same path. The catalog entries of copied documents
+// are merged into dst's catalog, and the URLs of skipped duplicates are added
+// to the entry of the copy dst already has.
+func Merge(src, dst string, perms pathutil.Permissions) (*Result, error) {
+	return Subset(src, dst, nil, perms)
+}
+
+// Subset copies the documents under src selected by match (all documents when
+// match is nil) into dst, with the same deduplication rules as Merge. match is
+// called with the document path relative to src.
+func Subset(src, dst string, match func(rel string) bool, perms pathutil.Permissions) (*Result, error) {
+	docs, err := Documents(src)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := checksumIndex(dst)
+	if err != nil {
+		return nil, err
+	}
+	srcCat, err := catalog.Load(src, perms)
+	if err != nil {
+		return nil, err
+	}
+	dstCat, err := catalog.Load(dst, perms)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, rel := range docs {
+		if match != nil && !match(rel) {
+			continue
+		}
+		srcPath := filepath.Join(src, rel)
+		dstPath := filepath.Join(dst, rel)
+
+		sum, err := FileChecksum(srcPath)
+		if err != nil {
+			return result, fmt.Errorf("failed to checksum %s: %w", srcPath, err)
+		}
+		if have, ok := existing[sum]; ok {
+			if err := dstCat.Import(filepath.Join(dst, have), srcCat, srcPath); err != nil {
+				return result, err
+			}
+			result.Duplicates = append(result.Duplicates, rel)
+			continue
+		}
+		if _, err := os.Stat(dstPath); err == nil {
+			result.Conflicts = append(result.Conflicts, rel)
+			continue
+		}
+
+		if err := copyDocument(srcPath, dstPath, perms); err != nil {
+			return result, err
+		}
+		if err := dstCat.Import(dstPath, srcCat, srcPath); err != nil {
+			return result, err
+		}
+		existing[sum] = rel
+		result.Copied = append(result.Copied, rel)
+	}
+	if err := dstCat.Save(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// Documents returns the paths (relative to root) of every source document in
+// a documents tree, skipping extraction outputs and temporary files
+func Documents(root string) ([]string, error) {
+	var docs []string
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return docs, nil
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Debug dumps are diagnostics and hidden directories (such as
+			// snapshots) bookkeeping, not part of the corpus
+			if path != root && (strings.HasSuffix(d.Name(), ".debug") || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsDocument(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if catalog.IsCatalogFile(filepath.ToSlash(rel)) {
+			return nil // the tree's index, not a document
+		}
+		docs = append(docs, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return docs, nil
+}
+
+// Find returns the documents under root (relative to it) named id: their
+// filename, or their filename without its extension, such as a Bates number
+// ("EFTA00010724"), matched regardless of case
+func Find(root, id string) ([]string, error) {
+	docs, err := Documents(root)
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, rel := range docs {
+		name := filepath.Base(rel)
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if strings.EqualFold(name, id) || strings.EqualFold(stem, id) {
+			found = append(found, rel)
+		}
+	}
+	return found, nil
+}
+
+// FileChecksum calculates the SHA256 checksum of a file
+func FileChecksum(path string) ([32]byte, error) {
+	var sum [32]byte
+	file, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], hasher.Sum(nil))
+	return sum, nil
+}
+
+// IsDocument reports whether a filename in the tree is a source document
+// rather than an extraction output, curation sidecar or hidden/temporary file
+func IsDocument(name string) bool {
+	return !strings.HasPrefix(name, ".") && !strings.Contains(name, ".extracted.") && name != meta.FileName
+}
+
+// checksumIndex maps the checksum of every document under root to its path
+func checksumIndex(root string) (map[[32]byte]string, error) {
+	index := make(map[[32]byte]string)
+	docs, err := Documents(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range docs {
+		sum, err := FileChecksum(filepath.Join(root, rel))
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", rel, err)
+		}
+		index[sum] = rel
+	}
+	return index, nil
+}
+
+// copyDocument copies a document, its extraction outputs (siblings named
+// {name}.extracted.*) and the curation sidecar of its directory into place
+func copyDocument(srcPath, dstPath string, perms pathutil.Permissions) error {
+	if err := perms.MkdirAll(filepath.Dir(dstPath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", dstPath, err)
+	}
+	if err := copyFile(srcPath, dstPath, perms); err != nil {
+		return err
+	}
+
+	outputs, err := Outputs(srcPath)
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if err := copyFile(output, filepath.Join(filepath.Dir(dstPath), filepath.Base(output)), perms); err != nil {
+			return err
+		}
+	}
+
+	// Keep notes already made in the destination
+	if _, err := os.Stat(meta.Path(srcPath)); err == nil {
+		if _, err := os.Stat(meta.Path(dstPath)); os.IsNotExist(err) {
+			return copyFile(meta.Path(srcPath), meta.Path(dstPath), perms)
+		}
+	}
+	return nil
+}
+
+// Outputs returns the extraction outputs stored next to a document (siblings
+// named {name}.extracted.*), in name order
+func Outputs(docPath string) ([]string, error) {
+	ext := filepath.Ext(docPath)
+	prefix := strings.TrimSuffix(filepath.Base(docPath), ext) + ".extracted."
+	entries, err := os.ReadDir(filepath.Dir(docPath))
+	if err != nil {
+		return nil, err
+	}
+	var outputs []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		outputs = append(outputs, filepath.Join(filepath.Dir(docPath), entry.Name()))
+	}
+	return outputs, nil
+}
+
+// copyFile copies a single file, writing the destination atomically
+func copyFile(srcPath, dstPath string, perms pathutil.Permissions) error {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", srcPath, err)
+	}
+	if err := perms.WriteFile(dstPath, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dstPath, err)
+	}
+	return nil
+}
+
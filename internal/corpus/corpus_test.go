@@ -0,0 +1,95 @@
+package corpus
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// shardTree builds a documents tree as a sharded run would leave it: each
+// document downloaded from url and recorded in the tree's catalog
+func shardTree(t *testing.T, docs map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	cat, err := catalog.Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, rel := range docs {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		content := []byte("content of " + rel)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := cat.RecordDownload(url, path, sha256.Sum256(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cat.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestMergeCombinesCatalogs(t *testing.T) {
+	shard1 := shardTree(t, map[string]string{
+		"https://example.com/a.pdf": "pdf/a/a.pdf",
+	})
+	shard2 := shardTree(t, map[string]string{
+		"https://example.com/b.pdf":        "pdf/b/b.pdf",
+		"https://mirror.example.com/a.pdf": "pdf/a/a.pdf", // same document from a mirror
+	})
+
+	dst := t.TempDir()
+	for _, src := range []string{shard1, shard2} {
+		if _, err := Merge(src, dst, pathutil.Permissions{}); err != nil {
+			t.Fatalf("Merge(%s) error = %v", src, err)
+		}
+	}
+
+	cat, err := catalog.Load(dst, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, rel := range map[string]string{
+		"https://example.com/a.pdf":        "pdf/a/a.pdf",
+		"https://mirror.example.com/a.pdf": "pdf/a/a.pdf",
+		"https://example.com/b.pdf":        "pdf/b/b.pdf",
+	} {
+		doc, path, ok := cat.Lookup(url)
+		if !ok || doc.Path != rel || path != filepath.Join(dst, filepath.FromSlash(rel)) {
+			t.Errorf("Lookup(%s) = %v, %q, %v, want %s", url, doc, path, ok, rel)
+		}
+	}
+	if doc, _ := cat.Get(filepath.Join(dst, "pdf", "a", "a.pdf")); doc.SHA256 == "" {
+		t.Error("merged entry lost its checksum")
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := shardTree(t, map[string]string{
+		"https://example.com/EFTA00010724.pdf": "pdf/EFTA00010724/EFTA00010724.pdf",
+		"https://example.com/EFTA00010724.jpg": "other/EFTA00010724/EFTA00010724.jpg",
+		"https://example.com/EFTA00010725.pdf": "pdf/EFTA00010725/EFTA00010725.pdf",
+	})
+	if err := os.WriteFile(filepath.Join(root, "pdf/EFTA00010725/EFTA00010725.extracted.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]int{"efta00010725": 1, "EFTA00010725.pdf": 1, "EFTA00010724": 2, "EFTA0001072": 0} {
+		found, err := Find(root, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != want {
+			t.Errorf("Find(%q) = %q, want %d document(s)", id, found, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package corpus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// SnapshotsDir is the directory under the tree root holding snapshots. It is
+// hidden, so the snapshots are not taken for documents.
+const SnapshotsDir = ".snapshots"
+
+// snapshotNameRe matches a valid snapshot name, e.g. "2024-01-release-v1"
+var snapshotNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot records the state of a documents tree at one point: the checksum
+// of every document and of each of its extraction outputs
+type Snapshot struct {
+	Name      string                      `json:"name"`
+	CreatedAt time.Time                   `json:"created_at"`
+	Documents map[string]SnapshotDocument `json:"documents"` // by slash-separated path relative to the root
+}
+
+// SnapshotDocument is the state of one document in a snapshot
+type SnapshotDocument struct {
+	SHA256      string            `json:"sha256"`
+	Pages       int               `json:"pages,omitempty"`
+	URLs        []string          `json:"urls,omitempty"`
+	Extractions map[string]string `json:"extractions,omitempty"` // hex checksum by output filename
+}
+
+// Snapshot change kinds and actions
+const (
+	ChangeDocument   = "document"
+	ChangeExtraction = "extraction"
+
+	ChangeAdded   = "added"
+	ChangeChanged = "changed"
+	ChangeRemoved = "removed"
+)
+
+// Change is one difference between two snapshots
+type Change struct {
+	Path   string `json:"path"`   // the document, or for an extraction its output, relative to the root
+	Kind   string `json:"kind"`   // ChangeDocument or ChangeExtraction
+	Action string `json:"action"` // ChangeAdded, ChangeChanged or ChangeRemoved
+}
+
+// TakeSnapshot records the current state of the tree at root. Document
+// checksums are taken from the catalog, and computed for documents it does
+// not record; extraction outputs are always checksummed.
+func TakeSnapshot(root, name string, cat *catalog.Catalog) (*Snapshot, error) {
+	if !snapshotNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	docs, err := Documents(root)
+	if err != nil {
+		return nil, err
+	}
+	recorded := cat.Checksums()
+	snap := &Snapshot{Name: name, CreatedAt: time.Now().UTC(), Documents: make(map[string]SnapshotDocument, len(docs))}
+	for _, rel := range docs {
+		path := filepath.Join(root, rel)
+		key := filepath.ToSlash(rel)
+		doc := SnapshotDocument{SHA256: recorded[key]}
+		if entry, ok := cat.Get(path); ok {
+			doc.Pages = entry.Pages
+			doc.URLs = entry.URLs
+		}
+		if doc.SHA256 == "" {
+			sum, err := FileChecksum(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to checksum %s: %w", rel, err)
+			}
+			doc.SHA256 = fmt.Sprintf("%x", sum)
+		}
+		outputs, err := Outputs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list outputs of %s: %w", rel, err)
+		}
+		for _, output := range outputs {
+			sum, err := FileChecksum(output)
+			if err != nil {
+				return nil, fmt.Errorf("failed to checksum %s: %w", output, err)
+			}
+			if doc.Extractions == nil {
+				doc.Extractions = make(map[string]string, len(outputs))
+			}
+			doc.Extractions[filepath.Base(output)] = fmt.Sprintf("%x", sum)
+		}
+		snap.Documents[key] = doc
+	}
+	return snap, nil
+}
+
+// SaveSnapshot stores a snapshot under the tree at root. Snapshots are
+// never overwritten: saving under a name already taken is an error.
+func SaveSnapshot(root string, snap *Snapshot, perms pathutil.Permissions) error {
+	path := snapshotPath(root, snap.Name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot %q already exists", snap.Name)
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := perms.MkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+	if err := perms.WriteFile(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads the named snapshot of the tree at root
+func LoadSnapshot(root, name string) (*Snapshot, error) {
+	if !snapshotNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q", name)
+	}
+	data, err := os.ReadFile(snapshotPath(root, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot named %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %q: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// ListSnapshots returns the snapshots of the tree at root, oldest first
+func ListSnapshots(root string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(root, SnapshotsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	var snaps []*Snapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		snap, err := LoadSnapshot(root, name)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].CreatedAt.Before(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// DiffSnapshots lists the documents and extraction outputs added, changed or
+// removed between two snapshots, in path order. A removed document's
+// extractions are not listed separately.
+func DiffSnapshots(from, to *Snapshot) []Change {
+	var changes []Change
+	for path, old := range from.Documents {
+		doc, ok := to.Documents[path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Path: path, Kind: ChangeDocument, Action: ChangeRemoved})
+			continue
+		case doc.SHA256 != old.SHA256:
+			changes = append(changes, Change{Path: path, Kind: ChangeDocument, Action: ChangeChanged})
+		}
+		changes = append(changes, diffExtractions(path, old.Extractions, doc.Extractions)...)
+	}
+	for path, doc := range to.Documents {
+		if _, ok := from.Documents[path]; ok {
+			continue
+		}
+		changes = append(changes, Change{Path: path, Kind: ChangeDocument, Action: ChangeAdded})
+		changes = append(changes, diffExtractions(path, nil, doc.Extractions)...)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffExtractions compares the extraction outputs of the document at path
+func diffExtractions(path string, from, to map[string]string) []Change {
+	dir := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir = path[:i+1]
+	}
+	var changes []Change
+	for name, sum := range from {
+		switch newSum, ok := to[name]; {
+		case !ok:
+			changes = append(changes, Change{Path: dir + name, Kind: ChangeExtraction, Action: ChangeRemoved})
+		case newSum != sum:
+			changes = append(changes, Change{Path: dir + name, Kind: ChangeExtraction, Action: ChangeChanged})
+		}
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			changes = append(changes, Change{Path: dir + name, Kind: ChangeExtraction, Action: ChangeAdded})
+		}
+	}
+	return changes
+}
+
+// snapshotPath returns where the named snapshot of the tree at root is kept
+func snapshotPath(root, name string) string {
+	return filepath.Join(root, SnapshotsDir, name+".json")
+}
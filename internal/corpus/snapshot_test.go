@@ -0,0 +1,82 @@
+package corpus
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	root := shardTree(t, map[string]string{
+		"https://example.com/a.pdf": "pdf/a/a.pdf",
+		"https://example.com/b.pdf": "pdf/b/b.pdf",
+	})
+	write := func(rel, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(rel)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	take := func(name string) *Snapshot {
+		t.Helper()
+		cat, err := catalog.Load(root, pathutil.Permissions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap, err := TakeSnapshot(root, name, cat)
+		if err != nil {
+			t.Fatalf("TakeSnapshot() error = %v", err)
+		}
+		if err := SaveSnapshot(root, snap, pathutil.Permissions{}); err != nil {
+			t.Fatalf("SaveSnapshot() error = %v", err)
+		}
+		return snap
+	}
+
+	write("pdf/a/a.extracted.json", "{}")
+	write("pdf/b/b.extracted.json", "{}")
+	v1 := take("2024-01-release-v1")
+	if len(v1.Documents) != 2 {
+		t.Fatalf("snapshot documents = %v, want 2 (snapshots are not documents)", v1.Documents)
+	}
+
+	write("pdf/a/a.extracted.json", `{"changed":true}`)
+	write("pdf/a/a.extracted.md", "# a")
+	if err := os.RemoveAll(filepath.Join(root, "pdf", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "pdf", "c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write("pdf/c/c.pdf", "new document")
+	take("2024-02-release-v2")
+
+	loaded, err := LoadSnapshot(root, "2024-02-release-v2")
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	want := []Change{
+		{Path: "pdf/a/a.extracted.json", Kind: ChangeExtraction, Action: ChangeChanged},
+		{Path: "pdf/a/a.extracted.md", Kind: ChangeExtraction, Action: ChangeAdded},
+		{Path: "pdf/b/b.pdf", Kind: ChangeDocument, Action: ChangeRemoved},
+		{Path: "pdf/c/c.pdf", Kind: ChangeDocument, Action: ChangeAdded},
+	}
+	if got := DiffSnapshots(v1, loaded); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffSnapshots() = %+v, want %+v", got, want)
+	}
+
+	snaps, err := ListSnapshots(root)
+	if err != nil || len(snaps) != 2 || snaps[0].Name != "2024-01-release-v1" {
+		t.Errorf("ListSnapshots() = %v, %v, want v1 then v2", snaps, err)
+	}
+	if err := SaveSnapshot(root, v1, pathutil.Permissions{}); err == nil {
+		t.Error("SaveSnapshot() overwrote an existing snapshot")
+	}
+	if _, err := TakeSnapshot(root, "../escape", nil); err == nil {
+		t.Error("TakeSnapshot() accepted a name with a path separator")
+	}
+}
@@ -0,0 +1,185 @@
+package corpus
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// staleTempAge is how old a temporary file must be before Vacuum removes it.
+// The lock keeps extraction runs out, but the server's on-demand extraction
+// writes without it.
+const staleTempAge = time.Hour
+
+// tempFileRe matches the temporary files of atomic writes (see
+// pathutil.CreateAtomic), left behind when a write is interrupted
+var tempFileRe = regexp.MustCompile(`^\..+\.[0-9]+\.tmp$`)
+
+// VacuumResult reports what Vacuum removed, or would remove in a dry run.
+// Paths are relative to the tree root.
+type VacuumResult struct {
+	Outputs   []string // extraction outputs and debug dumps whose document is gone
+	TempFiles []string // temporary files of interrupted writes
+	EmptyDirs []string // directories left empty
+	Bytes     int64    // size of the files removed
+}
+
+// Vacuum removes from the documents tree at root what deleted and
+// re-extracted documents leave behind: extraction outputs (with their
+// shards, versions and diff reports) and {name}.debug dumps of documents no
+// longer there, stale temporary files of interrupted writes, and the
+// directories this leaves empty. Hidden directories are left alone. With
+// dryRun nothing is removed.
+func Vacuum(root string, dryRun bool) (*VacuumResult, error) {
+	result := &VacuumResult{}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return result, nil
+	}
+	// gone holds what is removed, or would be in a dry run
+	gone := make(map[string]bool)
+	remove := func(path string, size int64) error {
+		gone[path] = true
+		result.Bytes += size
+		if dryRun {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	rel := func(path string) string {
+		r, _ := filepath.Rel(root, path)
+		return r
+	}
+
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if path != root && strings.HasSuffix(d.Name(), ".debug") {
+			return filepath.SkipDir // handled with its parent
+		}
+		dirs = append(dirs, path)
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		stems := documentStems(entries)
+		for _, entry := range entries {
+			name := entry.Name()
+			full := filepath.Join(path, name)
+			var orphan bool
+			switch {
+			case entry.IsDir():
+				stem, ok := strings.CutSuffix(name, ".debug")
+				orphan = ok && !strings.HasPrefix(name, ".") && !stems[stem]
+			case tempFileRe.MatchString(name):
+				info, err := entry.Info()
+				if err != nil || time.Since(info.ModTime()) < staleTempAge {
+					continue
+				}
+				result.TempFiles = append(result.TempFiles, rel(full))
+				if err := remove(full, info.Size()); err != nil {
+					return err
+				}
+				continue
+			default:
+				stem, _, ok := strings.Cut(name, ".extracted.")
+				orphan = ok && !stems[stem]
+			}
+			if !orphan {
+				continue
+			}
+			size, err := treeSize(full)
+			if err != nil {
+				return err
+			}
+			result.Outputs = append(result.Outputs, rel(full))
+			if err := remove(full, size); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to vacuum %s: %w", root, err)
+	}
+
+	// Deepest first, so directories emptied by removing their children go
+	// too
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+	for _, dir := range dirs {
+		if dir == root {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to vacuum %s: %w", root, err)
+		}
+		empty := true
+		for _, entry := range entries {
+			if !gone[filepath.Join(dir, entry.Name())] {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+		result.EmptyDirs = append(result.EmptyDirs, rel(dir))
+		if err := remove(dir, 0); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(result.EmptyDirs)
+	return result, nil
+}
+
+// documentStems returns the names without extension of the documents among
+// a directory's entries, which their outputs are named after
+func documentStems(entries []fs.DirEntry) map[string]bool {
+	stems := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !IsDocument(name) {
+			continue
+		}
+		ext := filepath.Ext(name)
+		stems[strings.TrimSuffix(name, ext)] = true
+	}
+	return stems
+}
+
+// treeSize returns the total size of the files at path, a file or a
+// directory
+func treeSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
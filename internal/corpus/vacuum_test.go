@@ -0,0 +1,69 @@
+package corpus
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVacuum(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string) string {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("a/kept.pdf")
+	write("a/kept.extracted.txt")
+	write("a/kept.debug/page-1.txt")
+	write("a/gone.extracted.txt")
+	write("a/gone.extracted.2.txt")
+	write("a/gone.debug/page-1.txt")
+	write("b/only.extracted.md")
+	stale := write("a/.kept.extracted.txt.123.tmp")
+	old := time.Now().Add(-2 * staleTempAge)
+	os.Chtimes(stale, old, old)
+	write("a/.kept.extracted.md.456.tmp") // fresh, may still be written
+	write(".hidden/gone.extracted.txt")
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := Vacuum(root, dryRun)
+		if err != nil {
+			t.Fatalf("Vacuum(dryRun=%v) error = %v", dryRun, err)
+		}
+		wantOutputs := []string{
+			filepath.FromSlash("a/gone.debug"),
+			filepath.FromSlash("a/gone.extracted.2.txt"),
+			filepath.FromSlash("a/gone.extracted.txt"),
+			filepath.FromSlash("b/only.extracted.md"),
+		}
+		if !reflect.DeepEqual(result.Outputs, wantOutputs) {
+			t.Errorf("Vacuum(dryRun=%v) Outputs = %v, want %v", dryRun, result.Outputs, wantOutputs)
+		}
+		if want := []string{filepath.FromSlash("a/.kept.extracted.txt.123.tmp")}; !reflect.DeepEqual(result.TempFiles, want) {
+			t.Errorf("Vacuum(dryRun=%v) TempFiles = %v, want %v", dryRun, result.TempFiles, want)
+		}
+		if want := []string{"b"}; !reflect.DeepEqual(result.EmptyDirs, want) {
+			t.Errorf("Vacuum(dryRun=%v) EmptyDirs = %v, want %v", dryRun, result.EmptyDirs, want)
+		}
+		if result.Bytes != 5*4 {
+			t.Errorf("Vacuum(dryRun=%v) Bytes = %d, want 20", dryRun, result.Bytes)
+		}
+		_, err = os.Stat(filepath.Join(root, "b"))
+		if removed := os.IsNotExist(err); removed == dryRun {
+			t.Errorf("Vacuum(dryRun=%v) removed b/ = %v", dryRun, removed)
+		}
+	}
+	for _, rel := range []string{"a/kept.extracted.txt", "a/kept.debug/page-1.txt", "a/.kept.extracted.md.456.tmp", ".hidden/gone.extracted.txt"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("Vacuum() removed %s: %v", rel, err)
+		}
+	}
+}
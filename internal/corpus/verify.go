@@ -0,0 +1,173 @@
+package corpus
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Verification outcomes
+const (
+	VerifyOK         = "ok"         // checksum matches the recorded one
+	VerifyMismatch   = "mismatch"   // checksum differs from the recorded one
+	VerifyMissing    = "missing"    // recorded, but no longer on disk
+	VerifyUnrecorded = "unrecorded" // on disk, but no checksum is recorded for it
+	VerifyError      = "error"      // the file could not be read
+)
+
+// Check is the verification result for one document
+type Check struct {
+	Path     string // relative to the tree root
+	Status   string
+	Expected string // recorded hex checksum, "" if unrecorded
+	Actual   string // computed hex checksum, "" if not computed
+	Bytes    int64  // bytes hashed
+	Err      error  // set when Status is VerifyError
+}
+
+// VerifyOptions tunes a verification run
+type VerifyOptions struct {
+	Workers   int   // parallel checksum workers (default 1)
+	RateLimit int64 // total bytes per second read across workers, 0 for no limit
+}
+
+// Verify recomputes the SHA256 of every document under root in parallel and
+// compares it with expected (hex checksums by slash-separated relative path).
+// report is called once per document as its result completes, never
+// concurrently. Documents in expected but not on disk are reported missing.
+func Verify(root string, expected map[string]string, opts VerifyOptions, report func(Check)) error {
+	docs, err := Documents(root)
+	if err != nil {
+		return err
+	}
+	onDisk := make(map[string]bool, len(docs))
+	for _, rel := range docs {
+		onDisk[filepath.ToSlash(rel)] = true
+	}
+	var missing []string
+	for rel := range expected {
+		if !onDisk[rel] {
+			missing = append(missing, rel)
+		}
+	}
+	sort.Strings(missing)
+	for _, rel := range missing {
+		report(Check{Path: rel, Status: VerifyMissing, Expected: expected[rel]})
+	}
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var limiter *rateLimiter
+	if opts.RateLimit > 0 {
+		limiter = &rateLimiter{bytesPerSec: opts.RateLimit}
+	}
+
+	jobs := make(chan string)
+	results := make(chan Check)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				results <- verifyFile(root, rel, expected[rel], limiter)
+			}
+		}()
+	}
+	go func() {
+		for _, rel := range docs {
+			jobs <- filepath.ToSlash(rel)
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Report from this goroutine only, in completion order
+	for check := range results {
+		report(check)
+	}
+	return nil
+}
+
+// verifyFile checksums one document and compares it with want
+func verifyFile(root, rel, want string, limiter *rateLimiter) Check {
+	check := Check{Path: rel, Expected: want}
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		check.Status, check.Err = VerifyError, err
+		return check
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if limiter != nil {
+		r = &limitedReader{r: file, limiter: limiter}
+	}
+	hasher := sha256.New()
+	check.Bytes, err = io.Copy(hasher, r)
+	if err != nil {
+		check.Status, check.Err = VerifyError, fmt.Errorf("failed to read: %w", err)
+		return check
+	}
+	check.Actual = fmt.Sprintf("%x", hasher.Sum(nil))
+
+	switch {
+	case want == "":
+		check.Status = VerifyUnrecorded
+	case want == check.Actual:
+		check.Status = VerifyOK
+	default:
+		check.Status = VerifyMismatch
+	}
+	return check
+}
+
+// rateLimiter spaces reads so that all workers together stay under a byte
+// rate
+type rateLimiter struct {
+	bytesPerSec int64
+
+	mu   sync.Mutex
+	next time.Time // when the budget spent so far is paid off
+}
+
+// wait blocks until n more bytes may be read
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	until := l.next
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	l.mu.Unlock()
+	time.Sleep(time.Until(until))
+}
+
+// limitedReader reads through a rateLimiter
+type limitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+// readChunk caps single reads so the limiter can interleave workers
+const readChunk = 256 << 10
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > readChunk {
+		p = p[:readChunk]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		lr.limiter.wait(n)
+	}
+	return n, err
+}
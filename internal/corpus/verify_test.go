@@ -0,0 +1,54 @@
+package corpus
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"pdf/a/a.pdf": "first",
+		"pdf/b/b.pdf": "second",
+		"pdf/c/c.pdf": "third",
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{
+		"pdf/a/a.pdf": fmt.Sprintf("%x", sha256.Sum256([]byte("first"))),
+		"pdf/b/b.pdf": fmt.Sprintf("%x", sha256.Sum256([]byte("changed"))),
+		"pdf/d/d.pdf": fmt.Sprintf("%x", sha256.Sum256([]byte("gone"))),
+	}
+
+	got := make(map[string]string)
+	err := Verify(root, expected, VerifyOptions{Workers: 3, RateLimit: 1 << 20}, func(check Check) {
+		got[check.Path] = check.Status
+	})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	want := map[string]string{
+		"pdf/a/a.pdf": VerifyOK,
+		"pdf/b/b.pdf": VerifyMismatch,
+		"pdf/c/c.pdf": VerifyUnrecorded,
+		"pdf/d/d.pdf": VerifyMissing,
+	}
+	for rel, status := range want {
+		if got[rel] != status {
+			t.Errorf("%s: status %q, want %q", rel, got[rel], status)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Verify() reported %d documents, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,171 @@
+// Package crawl paces very large download runs. A Plan spreads requests
+// evenly over a time window (never faster than a minimum interval) and
+// persists its progress, so a crawl of tens of thousands of documents can
+// be stopped and resumed across restarts while still finishing on schedule.
+package crawl
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// FileName is the plan's filename at the root of the documents tree. It is
+// hidden so whole-tree operations don't treat it as a document.
+const FileName = ".crawl-plan.json"
+
+// Plan is the persisted schedule of a crawl
+type Plan struct {
+	path  string
+	perms pathutil.Permissions
+	// inputs is the full ordered input list the plan was made for
+	inputs      []string
+	minInterval time.Duration
+	lastRequest time.Time
+	resumed     bool
+
+	state state
+}
+
+// state is the on-disk form of a plan
+type state struct {
+	ID          string    `json:"id"` // checksum of the input list
+	Total       int       `json:"total"`
+	Next        int       `json:"next"` // index of the next input to process
+	StartedAt   time.Time `json:"started_at"`
+	Deadline    time.Time `json:"deadline"`
+	MinInterval string    `json:"min_interval"`
+}
+
+// Load returns the plan for crawling inputs within window, resuming the plan
+// saved under root if it was made for the same inputs. Requests are spaced
+// at least minInterval apart even if that misses the deadline.
+func Load(root string, inputs []string, window, minInterval time.Duration, perms pathutil.Permissions) (*Plan, error) {
+	p := &Plan{
+		path:        filepath.Join(root, FileName),
+		perms:       perms,
+		inputs:      inputs,
+		minInterval: minInterval,
+	}
+	id := inputsID(inputs)
+
+	data, err := os.ReadFile(p.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &p.state); err != nil {
+			return nil, fmt.Errorf("failed to parse crawl plan %s: %w", p.path, err)
+		}
+		if p.state.ID == id && p.state.Next < len(inputs) {
+			p.resumed = true
+			return p, nil
+		}
+		// A plan for a different input list; start over
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to read crawl plan: %w", err)
+	}
+
+	now := time.Now().UTC()
+	p.state = state{
+		ID:          id,
+		Total:       len(inputs),
+		StartedAt:   now,
+		Deadline:    now.Add(window),
+		MinInterval: minInterval.String(),
+	}
+	return p, p.save()
+}
+
+// Resumed reports whether the plan continues one saved by an earlier run
+func (p *Plan) Resumed() bool {
+	return p.resumed
+}
+
+// Completed returns how many inputs earlier runs and this one have processed
+func (p *Plan) Completed() int {
+	return p.state.Next
+}
+
+// Remaining returns the inputs still to be processed, in order
+func (p *Plan) Remaining() []string {
+	return p.inputs[p.state.Next:]
+}
+
+// Deadline returns when the crawl is scheduled to finish
+func (p *Plan) Deadline() time.Time {
+	return p.state.Deadline
+}
+
+// Interval returns the current spacing between requests: the time left
+// divided evenly among the remaining inputs, but never below the minimum
+func (p *Plan) Interval(now time.Time) time.Duration {
+	remaining := len(p.inputs) - p.state.Next
+	if remaining <= 0 {
+		return p.minInterval
+	}
+	interval := p.state.Deadline.Sub(now) / time.Duration(remaining)
+	if interval < p.minInterval {
+		return p.minInterval
+	}
+	return interval
+}
+
+// Wait returns how long to wait before the next request
+func (p *Plan) Wait(now time.Time) time.Duration {
+	if p.lastRequest.IsZero() {
+		return 0 // first request of this run
+	}
+	wait := p.lastRequest.Add(p.Interval(now)).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// Requested records that a request is being made now
+func (p *Plan) Requested(now time.Time) {
+	p.lastRequest = now
+}
+
+// Advance marks the next input as processed and saves the plan. The plan file
+// is removed once every input has been processed.
+func (p *Plan) Advance() error {
+	p.state.Next++
+	if p.state.Next >= len(p.inputs) {
+		if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove finished crawl plan: %w", err)
+		}
+		return nil
+	}
+	return p.save()
+}
+
+// save writes the plan under the documents tree
+func (p *Plan) save() error {
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode crawl plan: %w", err)
+	}
+	if err := p.perms.MkdirAll(filepath.Dir(p.path)); err != nil {
+		return fmt.Errorf("failed to create documents directory: %w", err)
+	}
+	if err := p.perms.WriteFile(p.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write crawl plan: %w", err)
+	}
+	return nil
+}
+
+// inputsID identifies an input list so a saved plan is only resumed for the
+// same crawl
+func inputsID(inputs []string) string {
+	h := sha256.New()
+	for _, input := range inputs {
+		h.Write([]byte(input))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
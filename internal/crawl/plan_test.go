@@ -0,0 +1,68 @@
+package crawl
+
+import (
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestPlanResumesSameInputs(t *testing.T) {
+	root := t.TempDir()
+	inputs := []string{"a", "b", "c", "d"}
+
+	plan, err := Load(root, inputs, 4*time.Hour, time.Second, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if plan.Resumed() {
+		t.Error("new plan reports Resumed()")
+	}
+	if err := plan.Advance(); err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+
+	resumed, err := Load(root, inputs, 4*time.Hour, time.Second, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !resumed.Resumed() || len(resumed.Remaining()) != 3 {
+		t.Errorf("resumed plan: Resumed() = %v, Remaining() = %v, want true and 3 inputs", resumed.Resumed(), resumed.Remaining())
+	}
+	if !resumed.Deadline().Equal(plan.Deadline()) {
+		t.Errorf("resumed deadline = %v, want original %v", resumed.Deadline(), plan.Deadline())
+	}
+
+	// A different input list starts a fresh plan
+	other, err := Load(root, []string{"x"}, time.Hour, time.Second, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if other.Resumed() || other.Completed() != 0 {
+		t.Error("plan for different inputs was resumed")
+	}
+}
+
+func TestPlanInterval(t *testing.T) {
+	plan, err := Load(t.TempDir(), []string{"a", "b", "c", "d"}, 4*time.Hour, time.Second, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := plan.state.StartedAt
+	if got := plan.Interval(start); got != time.Hour {
+		t.Errorf("Interval() = %v, want 1h", got)
+	}
+	// Past the deadline the minimum interval applies
+	if got := plan.Interval(start.Add(5 * time.Hour)); got != time.Second {
+		t.Errorf("Interval() after deadline = %v, want 1s", got)
+	}
+
+	if got := plan.Wait(start); got != 0 {
+		t.Errorf("Wait() before first request = %v, want 0", got)
+	}
+	plan.Requested(start)
+	// 3h50m left for 4 inputs is one every 57m30s, 10m of which have passed
+	if got := plan.Wait(start.Add(10 * time.Minute)); got != 47*time.Minute+30*time.Second {
+		t.Errorf("Wait() = %v, want 47m30s", got)
+	}
+}
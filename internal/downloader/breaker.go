@@ -0,0 +1,166 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Breaker suspends requests to a host after it fails several times in a row,
+// so a batch stops hammering a server that is down or throttling it while
+// documents from other hosts continue. Once the cooldown has passed, one
+// request is let through: if it succeeds the host is resumed, if it fails the
+// host is suspended for another cooldown.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time // time.Now, replaced in tests
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState is the failure history of one host
+type hostState struct {
+	failures  int       // consecutive failures
+	openUntil time.Time // requests are refused before this time
+	trial     bool      // a request is being let through after the cooldown
+}
+
+// CircuitOpenError reports a request refused because its host failed too
+// many times in a row
+type CircuitOpenError struct {
+	Host     string
+	Failures int
+	Until    time.Time // when a request will be tried again
+}
+
+// Error implements error
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("requests to %s suspended until %s after %d consecutive failures", e.Host, e.Until.Format("15:04:05"), e.Failures)
+}
+
+// NewBreaker creates a Breaker suspending a host for cooldown after threshold
+// consecutive failures
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// Allow returns a *CircuitOpenError if requests to the host of rawURL are
+// suspended. After the cooldown it lets a single trial request through, whose
+// outcome must be passed to Record. A nil Breaker allows everything.
+func (b *Breaker) Allow(rawURL string) error {
+	if b == nil {
+		return nil
+	}
+	host := breakerHost(rawURL)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.open(host); err != nil {
+		return err
+	}
+	if state := b.hosts[host]; state != nil && state.failures >= b.threshold {
+		state.trial = true
+	}
+	return nil
+}
+
+// Record records the outcome of a request to rawURL, suspending its host
+// once it has failed threshold times in a row. Only errors that mean the host
+// is in trouble count as failures (see hostFailed).
+func (b *Breaker) Record(rawURL string, err error) {
+	if b == nil {
+		return
+	}
+	host := breakerHost(rawURL)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !hostFailed(err) {
+		delete(b.hosts, host)
+		return
+	}
+	state := b.hosts[host]
+	if state == nil {
+		state = &hostState{}
+		b.hosts[host] = state
+	}
+	state.failures++
+	state.trial = false
+	if state.failures >= b.threshold {
+		state.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Suspended returns a *CircuitOpenError if requests to the host of rawURL are
+// currently suspended. Unlike Allow it never starts a trial request.
+func (b *Breaker) Suspended(rawURL string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.open(breakerHost(rawURL)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// open returns a *CircuitOpenError if host is suspended: it is within its
+// cooldown, or its trial request has not finished yet. b.mu must be held.
+func (b *Breaker) open(host string) *CircuitOpenError {
+	state := b.hosts[host]
+	if state == nil || state.failures < b.threshold {
+		return nil
+	}
+	if b.now().Before(state.openUntil) || state.trial {
+		return &CircuitOpenError{Host: host, Failures: state.failures, Until: state.openUntil}
+	}
+	return nil
+}
+
+// breakerHost returns the host requests to rawURL are counted against: the
+// host name for http(s), ftp and sftp, and the mirror's host for s3:// and
+// ia:// URLs
+func breakerHost(rawURL string) string {
+	if scheme := urlScheme(rawURL); scheme == "s3" || scheme == "ia" {
+		if mirrored, err := mirrorURL(rawURL); err == nil {
+			rawURL = mirrored
+		}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// hostFailed reports whether err means the host is in trouble rather than
+// the document: network errors, server errors (5xx) and 429 Too Many
+// Requests. Missing or refused documents (404, 403, ...) are answers from a
+// healthy server and do not count.
+func hostFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500 || status.Code == http.StatusTooManyRequests
+	}
+	var skip *SkipError
+	return !errors.As(err, &skip)
+}
+
+// Suspended returns a *CircuitOpenError if the downloader's Breaker is
+// currently refusing requests to the host of url. It makes no request.
+func (d *Downloader) Suspended(url string) error {
+	return d.breaker.Suspended(url)
+}
@@ -3,15 +3,27 @@
 package downloader
 
 import (
+	"bufio"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"defornicate-epstein-files/internal/budget"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/scratch"
 )
 
 const (
@@ -20,19 +32,80 @@ const (
 	// DefaultUserAgent is the user agent string for HTTP requests
 	// Using a browser-like User-Agent to avoid being blocked by servers
 	DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	// AlternateUserAgent is the browser User-Agent a 403 is retried with when
+	// DefaultUserAgent was the one refused
+	AlternateUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:128.0) Gecko/20100101 Firefox/128.0"
 	// DefaultDocumentsDir is the default parent directory for storing documents
 	DefaultDocumentsDir = "documents"
 	// DefaultFilePerm is the default file permission (0644)
-	DefaultFilePerm = 0644
+	DefaultFilePerm = pathutil.DefaultFilePerm
 	// DefaultDirPerm is the default directory permission (0755)
-	DefaultDirPerm = 0755
+	DefaultDirPerm = pathutil.DefaultDirPerm
 )
 
+// ResponseHeaders are the HTTP response headers kept with each attempt: what
+// the server claimed about a file is evidence in itself, and ETag and
+// Last-Modified allow conditional revalidation
+var ResponseHeaders = []string{"Content-Length", "Last-Modified", "ETag", "Server", "Date"}
+
 // Downloader handles document downloads with checksum verification
 type Downloader struct {
 	client    *http.Client
+	dial      dialFunc // dials FTP and SFTP connections as the client's transport does HTTP ones
 	documentsDir string
 	userAgent string
+	from      string
+	perms     pathutil.Permissions
+	sftpKeyFile    string
+	sftpKnownHosts string
+	onAttempt      func(Attempt)
+	budget         *budget.Budget
+	scratch        *scratch.Dir
+	retryForbidden bool
+	skip           *SkipList
+	breaker        *Breaker
+	pacer          *pacer
+	retry          RetryPolicy
+	onRetry        func(url string, attempt int, wait time.Duration, err error)
+	retries        retryCounter
+	knownChecksum  func(url string) string
+	storedURLs     func(path string) []string
+
+	// manifestURLs are checksum manifests downloads are verified against,
+	// fetched into checksums on first use
+	manifestURLs []string
+	manifestOnce sync.Once
+	checksums    Manifest
+	manifestErr  error
+}
+
+// Attempt describes one fetch of a URL, for per-URL download history
+type Attempt struct {
+	URL           string
+	Start         time.Time
+	Duration      time.Duration
+	Status        int   // HTTP status code, 0 for other schemes or when no response arrived
+	Bytes         int64 // bytes received, after undoing any content encoding
+	TransferBytes int64 // bytes on the wire, fewer than Bytes for gzip or deflate encoded responses
+	Err           error // nil on success
+	// FallbackUserAgent is the browser User-Agent the fetch was retried with
+	// after the configured one was refused with 403, if it was
+	FallbackUserAgent string
+	// Headers holds the ResponseHeaders the server sent, under their names in
+	// ResponseHeaders; nil for other schemes or when no response arrived
+	Headers map[string]string
+}
+
+// StatusError reports an HTTP response other than 200 OK
+type StatusError struct {
+	Code       int
+	Status     string
+	RetryAfter time.Duration // how long the server asked to wait with Retry-After, 0 if it did not
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.Status)
 }
 
 // New creates a new Downloader instance
@@ -41,59 +114,130 @@ func New(documentsDir string) *Downloader {
 		client: &http.Client{
 			Timeout: DefaultTimeout,
 		},
+		dial:         (&net.Dialer{Timeout: DefaultTimeout}).DialContext,
 		documentsDir: documentsDir,
 		userAgent:    DefaultUserAgent,
 	}
 }
 
-// Download downloads a document from a URL, checking checksums to avoid duplicates
+// NewWithOptions creates a new Downloader instance with custom connection options
+func NewWithOptions(documentsDir string, opts Options) (*Downloader, error) {
+	dial, err := newDialer(opts)
+	if err != nil {
+		return nil, err
+	}
+	d := New(documentsDir)
+	d.client.Transport = newTransport(dial)
+	d.dial = dial
+	d.perms = opts.Permissions
+	d.sftpKeyFile = opts.SFTPKeyFile
+	d.sftpKnownHosts = opts.SFTPKnownHosts
+	d.onAttempt = opts.OnAttempt
+	d.userAgent = UserAgent(opts.UserAgent, opts.Contact)
+	d.from = opts.From
+	d.budget = opts.Budget
+	d.scratch = opts.Scratch
+	d.retryForbidden = opts.RetryForbidden
+	d.manifestURLs = opts.ChecksumManifests
+	d.skip = opts.Skip
+	d.breaker = opts.Breaker
+	d.retry = opts.Retry
+	d.onRetry = opts.OnRetry
+	if opts.RequestInterval > 0 {
+		d.pacer = &pacer{interval: opts.RequestInterval}
+	}
+	d.knownChecksum = opts.KnownChecksum
+	d.storedURLs = opts.StoredURLs
+	return d, nil
+}
+
+// UserAgent builds the User-Agent header from a base string (DefaultUserAgent
+// if empty) and an optional contact URL or email address
+func UserAgent(base, contact string) string {
+	if base == "" {
+		base = DefaultUserAgent
+	}
+	if contact == "" {
+		return base
+	}
+	return fmt.Sprintf("%s (+%s)", base, contact)
+}
+
+// DocumentsDir returns the documents tree the downloader stores documents in
+func (d *Downloader) DocumentsDir() string {
+	return d.documentsDir
+}
+
+// Download downloads a document from a URL, checking checksums to avoid duplicates.
+// http(s), ftp, sftp, s3 (public buckets) and ia (Internet Archive) URLs are
+// supported.
 func (d *Downloader) Download(url string) (string, error) {
-	// Create request with browser-like headers to avoid being blocked
-	req, err := http.NewRequest("GET", url, nil)
+	body, err := d.Open(url)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
-	req.Header.Set("User-Agent", d.userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Upgrade-Insecure-Requests", "1")
-	req.Header.Set("Sec-Fetch-Dest", "document")
-	req.Header.Set("Sec-Fetch-Mode", "navigate")
-	req.Header.Set("Sec-Fetch-Site", "none")
-	req.Header.Set("Sec-Fetch-User", "?1")
+	defer body.Close()
+	return d.Store(url, body)
+}
 
-	// Make request
-	resp, err := d.client.Do(req)
+// Open fetches the document at url using the URL's scheme and returns its
+// content. The content is held in memory within the downloader's budget and
+// in a temporary file beyond it; closing the reader frees either. A URL the
+// skip list refuses (see Skipped) is not requested and a *SkipError is
+// returned.
+func (d *Downloader) Open(url string) (io.ReadCloser, error) {
+	if err := d.Skipped(url); err != nil {
+		return nil, err
+	}
+	body, err := d.fetch(url)
 	if err != nil {
-		return "", fmt.Errorf("failed to download: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return body.Reader()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+// Store saves a fetched document under the documents directory, named after
+// url, and returns its path. If an identical file is already stored it is
+// left alone and ErrFileExists is returned with its path. With checksum
+// manifests configured, a document whose checksum differs from the one they
+// list for its name is moved to QuarantineDir instead and a *ChecksumError
+// is returned. Content whose checksum is on the skip list is discarded and a
+// *SkipError is returned. Different content under the name of a document
+// downloaded from other URLs (see Options.StoredURLs) does not replace it:
+// it is stored as {name}.{checksum prefix}{ext} in a directory of its own.
+func (d *Downloader) Store(url string, r io.Reader) (string, error) {
+	manifest, err := d.manifest()
+	if err != nil {
+		return "", err
 	}
 
 	// Extract filename from URL or generate one
-	filename := extractFilenameFromURL(url)
+	listedName := extractFilenameFromURL(url)
+	filename := listedName
 	if filename == "" {
 		filename = "downloaded"
 	}
 
+	// A name without an extension gets the one its content calls for, which
+	// picks the file type directory and the extractor
+	if filepath.Ext(filename) == "" {
+		br := bufio.NewReaderSize(r, sniffWindow)
+		head, err := br.Peek(sniffWindow)
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read document: %w", err)
+		}
+		filename += SniffExtension(head)
+		r = br
+	}
+
 	// Determine file type from filename
 	fileType := GetFileType(filename)
-	if filename == "downloaded" {
-		// If no extension detected, default to pdf for now
-		fileType = "pdf"
-		filename = "downloaded.pdf"
-	}
 
 	// Get the documents directory for this file type
 	typeDir := GetDocumentsDir(d.documentsDir, fileType)
 	
 	// Create documents directory structure if it doesn't exist
-	if err := os.MkdirAll(typeDir, DefaultDirPerm); err != nil {
+	if err := d.perms.MkdirAll(typeDir); err != nil {
 		return "", fmt.Errorf("failed to create documents directory: %w", err)
 	}
 
@@ -104,21 +248,39 @@ func (d *Downloader) Download(url string) (string, error) {
 	
 	// Create subdirectory for this document
 	docSubDir := filepath.Join(typeDir, baseName)
-	if err := os.MkdirAll(docSubDir, DefaultDirPerm); err != nil {
+	if err := d.perms.MkdirAll(docSubDir); err != nil {
 		return "", fmt.Errorf("failed to create document subdirectory: %w", err)
 	}
 
 	// Store document in its own subdirectory
 	filePath := filepath.Join(docSubDir, filename)
 
-	// Read the response body into memory to compute checksum
-	bodyBytes, err := io.ReadAll(resp.Body)
+	// Stream the content to a temporary file next to its destination
+	// (renamed into place only if it differs, so an interrupted run never
+	// leaves a truncated document behind), computing its checksum on the way
+	tmp, err := d.perms.CreateAtomic(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hasher), r); err != nil {
+		tmp.Abort()
+		return "", fmt.Errorf("failed to read document: %w", err)
 	}
+	var downloadedHash [32]byte
+	copy(downloadedHash[:], hasher.Sum(nil))
 
-	// Compute checksum of the downloaded content
-	downloadedHash := sha256.Sum256(bodyBytes)
+	if sum := fmt.Sprintf("%x", downloadedHash); d.skip.SkipsChecksum(sum) {
+		tmp.Abort()
+		os.Remove(docSubDir) // only if the download would have been its first file
+		return "", &SkipError{URL: url, Checksum: sum}
+	}
+
+	if ok, listed := manifest.Check(listedName, downloadedHash); listed && !ok {
+		err := d.quarantine(tmp, url, filename, downloadedHash, manifest[listedName])
+		os.Remove(docSubDir) // only if the download would have been its first file
+		return "", err
+	}
 
 	// Check if file already exists
 	if _, err := os.Stat(filePath); err == nil {
@@ -126,31 +288,285 @@ func (d *Downloader) Download(url string) (string, error) {
 		existingHash, err := computeFileChecksum(filePath)
 		if err != nil {
 			// If we can't read the existing file, replace it
+			tmp.Abort()
 			return filePath, fmt.Errorf("warning: failed to compute checksum of existing file, will replace: %w", err)
 		}
 
 		// Compare checksums
 		if downloadedHash == existingHash {
+			tmp.Abort()
 			return filePath, ErrFileExists
 		}
-		// Checksums don't match, will replace the file
+		if d.collides(url, filePath) {
+			return d.storeBeside(tmp, typeDir, filename, downloadedHash)
+		}
+		// Checksums don't match: a new version of the document, will
+		// replace the file
 	}
 
 	// Create or replace the file
-	outFile, err := os.Create(filePath)
+	if err := tmp.Commit(); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// collides reports whether the document stored at path was downloaded from
+// URLs other than url, so content from url is a different document that
+// happens to share its name
+func (d *Downloader) collides(url, path string) bool {
+	if d.storedURLs == nil {
+		return false
+	}
+	urls := d.storedURLs(path)
+	return len(urls) > 0 && !slices.Contains(urls, CanonicalURL(url))
+}
+
+// storeBeside commits a download whose name is taken by a different
+// document under the name suffixed with the start of its checksum, in a
+// directory of its own like any document, and returns its path. A file
+// already stored there is left alone and ErrFileExists is returned.
+func (d *Downloader) storeBeside(tmp *pathutil.AtomicFile, typeDir, filename string, sum [32]byte) (string, error) {
+	ext := filepath.Ext(filename)
+	baseName := fmt.Sprintf("%s.%x", strings.TrimSuffix(filename, ext), sum[:6])
+	docSubDir := filepath.Join(typeDir, baseName)
+	if err := d.perms.MkdirAll(docSubDir); err != nil {
+		tmp.Abort()
+		return "", fmt.Errorf("failed to create document subdirectory: %w", err)
+	}
+	filePath := filepath.Join(docSubDir, baseName+ext)
+	if _, err := os.Stat(filePath); err == nil {
+		tmp.Abort()
+		return filePath, ErrFileExists
+	}
+	if err := tmp.CommitTo(filePath); err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	return filePath, nil
+}
+
+// quarantine moves a download that failed checksum verification into
+// QuarantineDir, named after the document and the start of its checksum, and
+// returns the *ChecksumError reporting it
+func (d *Downloader) quarantine(tmp *pathutil.AtomicFile, url, filename string, sum [32]byte, expected []string) error {
+	dir := filepath.Join(d.documentsDir, QuarantineDir)
+	if err := d.perms.MkdirAll(dir); err != nil {
+		tmp.Abort()
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	ext := filepath.Ext(filename)
+	path := filepath.Join(dir, fmt.Sprintf("%s.%x%s", strings.TrimSuffix(filename, ext), sum[:6], ext))
+	if err := tmp.CommitTo(path); err != nil {
+		return fmt.Errorf("failed to quarantine %s: %w", filename, err)
+	}
+	return &ChecksumError{URL: url, Expected: expected, Actual: fmt.Sprintf("%x", sum), Quarantined: path}
+}
+
+// fetchOnce retrieves the document at rawURL into a spool, reporting the
+// attempt to the OnAttempt callback. An HTTP transfer cut short is kept in
+// PartialDir, if its response carried a strong ETag or a Last-Modified date,
+// and resumed by the next fetch of the URL with a Range request conditional
+// on it (If-Range); the whole document is fetched again if the server does
+// not support ranges, the document changed in between, or the response had
+// no validator.
+func (d *Downloader) fetchOnce(rawURL string) (*spool, error) {
+	if err := d.breaker.Allow(rawURL); err != nil {
+		return nil, err
+	}
+	d.pacer.wait()
+	start := time.Now()
+	body := &spool{budget: d.budget, scratch: d.scratch}
+	headers := make(map[string]string)
+	resume := d.loadPartial(rawURL)
+	transferred, err := d.fetchScheme(rawURL, body, d.userAgent, headers, resume)
+	if resume != nil && errors.Is(err, errCannotResume) {
+		d.dropPartial(rawURL)
+		resume = nil
+		clear(headers)
+		transferred, err = d.fetchScheme(rawURL, body, d.userAgent, headers, nil)
+	}
+	var fallback string
+	var forbidden *StatusError
+	if d.retryForbidden && errors.As(err, &forbidden) && forbidden.Code == http.StatusForbidden {
+		// Some hosts refuse User-Agents they don't recognize as a browser
+		fallback = browserUserAgent(d.userAgent)
+		clear(headers)
+		transferred, err = d.fetchScheme(rawURL, body, fallback, headers, resume)
+	}
+	if d.onAttempt != nil {
+		attempt := Attempt{URL: rawURL, Start: start, Duration: time.Since(start), Bytes: body.Len(), TransferBytes: transferred, Err: err, FallbackUserAgent: fallback}
+		if len(headers) > 0 {
+			attempt.Headers = headers
+		}
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			attempt.Status = statusErr.Code
+		} else if scheme := urlScheme(rawURL); err == nil && scheme != "ftp" && scheme != "sftp" {
+			attempt.Status = http.StatusOK // everything else is fetched over HTTP
+		}
+		d.onAttempt(attempt)
+	}
+	d.breaker.Record(rawURL, err)
+	var interrupted *interruptedError
+	if errors.As(err, &interrupted) {
+		d.savePartial(rawURL, body, headers)
+	} else if err == nil && resume != nil {
+		d.dropPartial(rawURL)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		body.Close()
+		return nil, err
+	}
+	return body, nil
+}
+
+// browserUserAgent returns the browser User-Agent to retry a refused request
+// with: DefaultUserAgent, or AlternateUserAgent if that was refused
+func browserUserAgent(refused string) string {
+	if refused == DefaultUserAgent {
+		return AlternateUserAgent
 	}
-	defer outFile.Close()
+	return DefaultUserAgent
+}
 
-	// Write the downloaded content to file
-	_, err = outFile.Write(bodyBytes)
+// fetchScheme retrieves the document at rawURL into w with the client for its
+// scheme, returning the number of bytes transferred. userAgent is sent with
+// HTTP requests, and the ResponseHeaders of HTTP responses are stored in
+// headers. HTTP transfers continue resume if it is not nil.
+func (d *Downloader) fetchScheme(rawURL string, w io.Writer, userAgent string, headers map[string]string, resume *partial) (int64, error) {
+	counted := &countingWriter{w: w}
+	switch urlScheme(rawURL) {
+	case "s3", "ia":
+		httpURL, err := mirrorURL(rawURL)
+		if err != nil {
+			return 0, err
+		}
+		return d.fetchHTTP(httpURL, w, userAgent, headers, resume)
+	case "ftp":
+		err := d.fetchFTP(rawURL, counted)
+		return counted.n, err
+	case "sftp":
+		err := d.fetchSFTP(rawURL, counted)
+		return counted.n, err
+	default:
+		return d.fetchHTTP(rawURL, w, userAgent, headers, resume)
+	}
+}
+
+// fetchHTTP downloads a document over HTTP(S), undoing any content encoding,
+// and returns the number of bytes transferred. The ResponseHeaders of the
+// response, whatever its status, are stored in headers. With resume, the
+// rest of the document is requested with a Range request and written to w
+// after the content of the .part file; a server answering with the whole
+// document (200) is fine, one that cannot continue where the file ends gives
+// errCannotResume. A transfer cut short with no content encoding returns an
+// *interruptedError.
+func (d *Downloader) fetchHTTP(url string, w io.Writer, userAgent string, headers map[string]string, resume *partial) (int64, error) {
+	// Create request with browser-like headers to avoid being blocked
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		os.Remove(filePath)
-		return "", fmt.Errorf("failed to save file: %w", err)
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if d.from != "" {
+		req.Header.Set("From", d.from)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("Upgrade-Insecure-Requests", "1")
+	req.Header.Set("Sec-Fetch-Dest", "document")
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	req.Header.Set("Sec-Fetch-Site", "none")
+	req.Header.Set("Sec-Fetch-User", "?1")
+	if resume != nil {
+		// Byte ranges are of the encoded content, so ask for none
+		req.Header.Set("Accept-Encoding", "identity")
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", resume.offset))
+		req.Header.Set("If-Range", resume.ifRange)
 	}
 
-	return filePath, nil
+	// Make request
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	keepHeaders(resp, headers)
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	resumed := resume != nil && resp.StatusCode == http.StatusPartialContent
+	switch {
+	case resume != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		return 0, fmt.Errorf("%w: %s", errCannotResume, resp.Status)
+	case resumed:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != resume.offset || (encoding != "" && encoding != "identity") {
+			return 0, fmt.Errorf("%w: server sent Content-Range %q for a range from byte %d", errCannotResume, resp.Header.Get("Content-Range"), resume.offset)
+		}
+	case resp.StatusCode != http.StatusOK:
+		return 0, &StatusError{Code: resp.StatusCode, Status: resp.Status, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	transfer := &countingReader{r: resp.Body}
+	body, err := decodeBody(transfer, resp.Header.Get("Content-Encoding"))
+	if err == nil && !resumed {
+		body, err = checkMagic(body, GetFileType(extractFilenameFromURL(url)))
+	}
+	if err != nil {
+		return transfer.n, err
+	}
+	if resumed {
+		// The start of the document was checked when it was first fetched
+		if err := copyFile(w, resume.path); err != nil {
+			return transfer.n, err
+		}
+	}
+	// Only a transfer with no content encoding can be resumed from where
+	// it stopped
+	interrupted := func(err error) error {
+		if encoding == "" || encoding == "identity" {
+			return &interruptedError{err: err}
+		}
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return transfer.n, interrupted(fmt.Errorf("failed to read response body: %w", err))
+	}
+	// Drain what the decoder left (e.g. padding after a gzip trailer) so the
+	// length check below sees the whole transfer
+	io.Copy(io.Discard, transfer)
+	// Catch transfers cut short without an error from the connection
+	if resp.ContentLength >= 0 && !resp.Uncompressed && transfer.n != resp.ContentLength {
+		return transfer.n, interrupted(fmt.Errorf("truncated response: got %d of %d bytes", transfer.n, resp.ContentLength))
+	}
+	return transfer.n, nil
+}
+
+// copyFile writes the content of the file at path to w
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open partial download: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("failed to read partial download: %w", err)
+	}
+	return nil
+}
+
+// keepHeaders stores the ResponseHeaders of resp in headers
+func keepHeaders(resp *http.Response, headers map[string]string) {
+	for _, name := range ResponseHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	if _, ok := headers["Content-Length"]; !ok && resp.ContentLength >= 0 {
+		headers["Content-Length"] = strconv.FormatInt(resp.ContentLength, 10) // moved out of Header by net/http
+	}
 }
 
 // computeFileChecksum calculates the SHA256 checksum of a file
@@ -181,31 +597,18 @@ func extractFilenameFromURL(rawURL string) string {
 		return extractFilenameFromURLFallback(rawURL)
 	}
 
-	// Extract the last part of the URL path
-	path := parsedURL.Path
+	// Take the last segment of the escaped path, so an encoded slash (%2F)
+	// stays part of the name instead of splitting it
+	path := parsedURL.EscapedPath()
 	if path == "" || path == "/" {
 		return ""
 	}
-
-	// Get the last segment of the path
 	parts := strings.Split(strings.Trim(path, "/"), "/")
 	if len(parts) == 0 {
 		return ""
 	}
 
-	filename := parts[len(parts)-1]
-	
-	// URL decode the filename
-	decoded, err := url.QueryUnescape(filename)
-	if err == nil {
-		filename = decoded
-	}
-
-	// Remove any invalid characters for filenames
-	invalidChars := []string{"<", ">", ":", "\"", "|", "?", "*", "\\", "/"}
-	for _, char := range invalidChars {
-		filename = strings.ReplaceAll(filename, char, "_")
-	}
+	filename := sanitizeFilename(unescapeSegment(parts[len(parts)-1]))
 
 	// Ensure filename is not empty
 	if filename == "" {
@@ -217,29 +620,81 @@ func extractFilenameFromURL(rawURL string) string {
 
 // extractFilenameFromURLFallback provides a fallback when URL parsing fails
 func extractFilenameFromURLFallback(rawURL string) string {
-	// Remove query parameters
-	if idx := strings.Index(rawURL, "?"); idx != -1 {
+	// Remove query parameters and fragment
+	if idx := strings.IndexAny(rawURL, "?#"); idx != -1 {
 		rawURL = rawURL[:idx]
 	}
 
 	// Extract the last part of the URL path
 	parts := strings.Split(rawURL, "/")
-	if len(parts) > 0 {
-		filename := parts[len(parts)-1]
-		// Basic URL decode
-		filename = strings.ReplaceAll(filename, "%20", "_")
-		filename = strings.ReplaceAll(filename, "%2F", "_")
-		filename = strings.ReplaceAll(filename, "%", "_")
-		// Remove any invalid characters for filenames
-		invalidChars := []string{"<", ">", ":", "\"", "|", "?", "*", "\\"}
-		for _, char := range invalidChars {
-			filename = strings.ReplaceAll(filename, char, "_")
+	return sanitizeFilename(unescapeSegment(parts[len(parts)-1]))
+}
+
+// unescapeSegment decodes the percent-escapes of one path segment. Unlike
+// url.PathUnescape it keeps going past malformed escapes, leaving them as
+// they are, and "+" is left alone since it only means space in queries.
+func unescapeSegment(segment string) string {
+	if decoded, err := url.PathUnescape(segment); err == nil {
+		return decoded
+	}
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		if segment[i] == '%' && i+2 < len(segment) && isHex(segment[i+1]) && isHex(segment[i+2]) {
+			v, _ := strconv.ParseUint(segment[i+1:i+3], 16, 8)
+			b.WriteByte(byte(v))
+			i += 2
+			continue
+		}
+		b.WriteByte(segment[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// maxFilenameBytes is the longest filename most filesystems accept
+const maxFilenameBytes = 255
+
+// sanitizeFilename makes a decoded filename safe to store while keeping its
+// Unicode characters. Characters that are invalid in filenames and control
+// characters become "_"; bytes that are not valid UTF-8 (e.g. a Latin-1
+// %E9) are kept as their %XX escape, so distinct names stay distinct.
+// Names that are only dots are rejected, and long names are shortened at a
+// character boundary, keeping the extension.
+func sanitizeFilename(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&b, "%%%02X", name[i])
+		case strings.ContainsRune(`<>:"|?*\/`, r), unicode.IsControl(r):
+			b.WriteByte('_')
+		default:
+			b.WriteString(name[i : i+size])
 		}
-		if filename != "" {
-			return filename
+		i += size
+	}
+	filename := strings.TrimSpace(b.String())
+	if strings.Trim(filename, ".") == "" {
+		return ""
+	}
+
+	if len(filename) > maxFilenameBytes {
+		ext := filepath.Ext(filename)
+		if len(ext) > maxFilenameBytes/4 {
+			ext = ""
 		}
+		stem := filename[:len(filename)-len(ext)]
+		cut := maxFilenameBytes - len(ext)
+		for cut > 0 && !utf8.RuneStart(stem[cut]) {
+			cut--
+		}
+		filename = stem[:cut] + ext
 	}
-	return ""
+	return filename
 }
 
 // ErrFileExists is returned when a file with the same checksum already exists
@@ -0,0 +1,992 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/budget"
+)
+
+func TestExtractFilenameFromURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/files/EFTA00010724.pdf", "EFTA00010724.pdf"},
+		{"https://example.com/DataSet%208/Deposition%20Transcript.pdf", "Deposition Transcript.pdf"},
+		{"https://example.com/docs/Gr%C3%BC%C3%9Fe%20%E2%80%94%20M%C3%A9moire.pdf", "Grüße — Mémoire.pdf"},
+		{"https://example.com/docs/%E6%96%87%E4%BB%B6.pdf", "文件.pdf"},
+		{"https://example.com/docs/a%2Fb.pdf", "a_b.pdf"},
+		{"https://example.com/docs/caf%E9.pdf", "caf%E9.pdf"},
+		{"https://example.com/docs/a+b.pdf", "a+b.pdf"},
+		{"https://example.com/docs/100%25.pdf", "100%.pdf"},
+		{"https://example.com/docs/tab%09name.pdf", "tab_name.pdf"},
+		{"https://example.com/docs/%2E%2E", "downloaded"},
+		{"https://example.com/", ""},
+	}
+	for _, tt := range tests {
+		if got := extractFilenameFromURL(tt.url); got != tt.want {
+			t.Errorf("extractFilenameFromURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestExtractFilenameFromURLKeepsEncodedNamesDistinct(t *testing.T) {
+	seen := make(map[string]string)
+	for _, url := range []string{
+		"https://example.com/%D0%94%D0%BE%D0%BA%D1%83%D0%BC%D0%B5%D0%BD%D1%82.pdf",
+		"https://example.com/%D0%9F%D0%B8%D1%81%D1%8C%D0%BC%D0%BE.pdf",
+		"https://example.com/caf%E9.pdf",
+		"https://example.com/caf%E8.pdf",
+	} {
+		name := extractFilenameFromURL(url)
+		if other, ok := seen[name]; ok {
+			t.Errorf("%s and %s both map to %q", other, url, name)
+		}
+		seen[name] = url
+	}
+}
+
+func TestExtractFilenameFromURLFallback(t *testing.T) {
+	// A malformed escape makes url.Parse fail; valid escapes are still decoded
+	got := extractFilenameFromURL("https://example.com/%zz/R%C3%A9sum%C3%A9%2.pdf?x=1")
+	if got != "Résumé%2.pdf" {
+		t.Errorf("extractFilenameFromURL() = %q, want %q", got, "Résumé%2.pdf")
+	}
+}
+
+func TestSanitizeFilenameTruncatesAtRuneBoundary(t *testing.T) {
+	name := sanitizeFilename(strings.Repeat("é", 200) + ".pdf")
+	if len(name) > maxFilenameBytes || !strings.HasSuffix(name, ".pdf") {
+		t.Fatalf("sanitizeFilename() = %d bytes ending %q", len(name), name[len(name)-4:])
+	}
+	if !strings.HasPrefix(name, "é") || strings.ContainsRune(name, '�') {
+		t.Errorf("sanitizeFilename() split a character: %q", name)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	if got := UserAgent("", ""); got != DefaultUserAgent {
+		t.Errorf("UserAgent() = %q, want the default", got)
+	}
+	if got := UserAgent("defornicator/1.0", "https://example.org/crawl"); got != "defornicator/1.0 (+https://example.org/crawl)" {
+		t.Errorf("UserAgent() = %q", got)
+	}
+}
+
+func TestIdentificationHeaders(t *testing.T) {
+	var userAgent, from string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, from = r.Header.Get("User-Agent"), r.Header.Get("From")
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	d, err := NewWithOptions(t.TempDir(), Options{UserAgent: "defornicator/1.0", Contact: "ops@example.org", From: "ops@example.org"})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	body, err := d.Open(server.URL + "/a.pdf")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	body.Close()
+	if userAgent != "defornicator/1.0 (+ops@example.org)" || from != "ops@example.org" {
+		t.Errorf("sent User-Agent %q and From %q", userAgent, from)
+	}
+}
+
+func TestRetryForbidden(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "Mozilla/") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	for _, retry := range []bool{false, true} {
+		agents = nil
+		var attempt Attempt
+		d, _ := NewWithOptions(t.TempDir(), Options{UserAgent: "defornicator/1.0", RetryForbidden: retry, OnAttempt: func(a Attempt) { attempt = a }})
+		body, err := d.Open(server.URL + "/a.pdf")
+		if !retry {
+			if err == nil || len(agents) != 1 || attempt.Status != http.StatusForbidden {
+				t.Errorf("without RetryForbidden: error = %v after %d request(s), want one 403", err, len(agents))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		body.Close()
+		if len(agents) != 2 || agents[1] != DefaultUserAgent || attempt.FallbackUserAgent != DefaultUserAgent || attempt.Status != http.StatusOK {
+			t.Errorf("sent %q, attempt %+v; want a retry with DefaultUserAgent", agents, attempt)
+		}
+	}
+}
+
+func TestAttemptHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Last-Modified", "Tue, 03 Dec 2024 17:00:00 GMT")
+		w.Header().Set("Server", "Apache")
+		w.Header().Set("X-Powered-By", "PHP")
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	var attempt Attempt
+	d, _ := NewWithOptions(t.TempDir(), Options{OnAttempt: func(a Attempt) { attempt = a }})
+	body, err := d.Open(server.URL + "/a.pdf")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	body.Close()
+	want := map[string]string{"ETag": `"abc123"`, "Last-Modified": "Tue, 03 Dec 2024 17:00:00 GMT", "Server": "Apache", "Content-Length": "8"}
+	for name, value := range want {
+		if attempt.Headers[name] != value {
+			t.Errorf("Headers[%s] = %q, want %q", name, attempt.Headers[name], value)
+		}
+	}
+	if attempt.Headers["Date"] == "" || len(attempt.Headers) != 5 {
+		t.Errorf("Headers = %v, want the five ResponseHeaders", attempt.Headers)
+	}
+}
+
+func TestOpenSpillsOverBudget(t *testing.T) {
+	content := strings.Repeat("%PDF-1.4 ", 4096)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	memory := budget.New(1024)
+	dir := t.TempDir()
+	d, err := NewWithOptions(dir, Options{Budget: memory})
+	if err != nil {
+		t.Fatalf("NewWithOptions() error = %v", err)
+	}
+	body, err := d.Open(server.URL + "/large.pdf")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !memory.TryAcquire(1024) {
+		t.Fatal("spilled download still holds memory budget")
+	}
+	memory.Release(1024)
+
+	path, err := d.Store(server.URL+"/large.pdf", body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read stored file: %v", err)
+	}
+	if string(stored) != content {
+		t.Errorf("stored %d bytes, want %d", len(stored), len(content))
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("document directory has %d entries, want only the document", len(entries))
+	}
+}
+
+func TestOpenDecodesContentEncoding(t *testing.T) {
+	content := "%PDF-1.4\n" + strings.Repeat("stream data ", 200) + "\n%%EOF\n"
+	var gzipped, deflated bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write([]byte(content))
+	gw.Close()
+	zw := zlib.NewWriter(&deflated)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"identity", "", []byte(content)},
+		{"gzip", "gzip", gzipped.Bytes()},
+		{"deflate", "deflate", deflated.Bytes()},
+		{"gzip without header", "", gzipped.Bytes()}, // a .pdf.gz served as the PDF
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			var attempt Attempt
+			d, err := NewWithOptions(t.TempDir(), Options{OnAttempt: func(a Attempt) { attempt = a }})
+			if err != nil {
+				t.Fatalf("NewWithOptions() error = %v", err)
+			}
+			body, err := d.Open(server.URL + "/a.pdf")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer body.Close()
+			got := new(bytes.Buffer)
+			got.ReadFrom(body)
+			if got.String() != content {
+				t.Errorf("Open() returned %d bytes starting %q, want the decoded PDF", got.Len(), got.Bytes()[:min(got.Len(), 8)])
+			}
+			if attempt.Bytes != int64(len(content)) || attempt.TransferBytes != int64(len(tt.body)) {
+				t.Errorf("attempt recorded %d bytes (%d transferred), want %d (%d)", attempt.Bytes, attempt.TransferBytes, len(content), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestOpenRejectsWrongMagic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Access denied</body></html>"))
+	}))
+	defer server.Close()
+
+	d := New(t.TempDir())
+	if body, err := d.Open(server.URL + "/a.pdf"); err == nil {
+		body.Close()
+		t.Fatal("Open() of an HTML page as a PDF succeeded")
+	}
+	body, err := d.Open(server.URL + "/page")
+	if err != nil {
+		t.Fatalf("Open() of an untyped URL error = %v", err)
+	}
+	body.Close()
+}
+
+func TestParseManifest(t *testing.T) {
+	const sum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	m, err := ParseManifest(strings.NewReader("# DataSet 8\n" +
+		sum + "  VOL00008/IMAGES/EFTA00010724.pdf\n" +
+		strings.ToUpper(sum) + " *EFTA00010725.pdf\n" +
+		"SHA256 (EFTA00010726.pdf) = " + sum + "\n"))
+	if err != nil {
+		t.Fatalf("ParseManifest() error = %v", err)
+	}
+	for _, name := range []string{"EFTA00010724.pdf", "EFTA00010725.pdf", "EFTA00010726.pdf"} {
+		if got := m[name]; len(got) != 1 || got[0] != sum {
+			t.Errorf("m[%q] = %q, want [%s]", name, got, sum)
+		}
+	}
+
+	if _, err := ParseManifest(strings.NewReader("<html>Not Found</html>\n")); err == nil {
+		t.Error("ParseManifest() accepted an error page")
+	}
+}
+
+func TestStoreVerifiesChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+	good := []byte("%PDF-1.4 flight log")
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	content := fmt.Sprintf("%x  EFTA00010724.pdf\n%x  EFTA00010725.pdf\n", sha256.Sum256(good), sha256.Sum256(good))
+	if err := os.WriteFile(manifest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "documents")
+	d, err := NewWithOptions(root, Options{ChecksumManifests: []string{manifest}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Store("https://example.com/EFTA00010724.pdf", bytes.NewReader(good)); err != nil {
+		t.Errorf("Store() of a matching file error = %v", err)
+	}
+	if _, err := d.Store("https://example.com/EFTA00010726.pdf", bytes.NewReader(good)); err != nil {
+		t.Errorf("Store() of an unlisted file error = %v", err)
+	}
+
+	_, err = d.Store("https://example.com/EFTA00010725.pdf", strings.NewReader("%PDF-1.4 tampered"))
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Store() of a mismatching file error = %v, want a ChecksumError", err)
+	}
+	if data, err := os.ReadFile(mismatch.Quarantined); err != nil || string(data) != "%PDF-1.4 tampered" {
+		t.Errorf("quarantined file = %q, %v", data, err)
+	}
+	if filepath.Dir(mismatch.Quarantined) != filepath.Join(root, QuarantineDir) {
+		t.Errorf("quarantined at %s, want under %s", mismatch.Quarantined, QuarantineDir)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pdf", "EFTA00010725")); !os.IsNotExist(err) {
+		t.Errorf("mismatching file left a document directory behind: %v", err)
+	}
+
+	// An unreachable manifest stops every download
+	d, _ = NewWithOptions(root, Options{ChecksumManifests: []string{filepath.Join(dir, "missing")}})
+	if _, err := d.Store("https://example.com/EFTA00010724.pdf", bytes.NewReader(good)); err == nil {
+		t.Error("Store() without its manifest succeeded")
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	bad := []byte("%PDF-1.4 known bad")
+	badSum := fmt.Sprintf("%x", sha256.Sum256(bad))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(bad)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	if err := os.WriteFile(manifest, []byte(badSum+"  listed.pdf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	skip, err := NewSkipList([]string{server.URL + "/blocked.pdf", server.URL + "/out-of-scope/*"}, []string{strings.ToUpper(badSum)})
+	if err != nil {
+		t.Fatalf("NewSkipList() error = %v", err)
+	}
+	d, err := NewWithOptions(filepath.Join(dir, "documents"), Options{
+		Skip:              skip,
+		ChecksumManifests: []string{manifest},
+		KnownChecksum: func(url string) string {
+			if strings.HasSuffix(url, "/seen.pdf") {
+				return badSum
+			}
+			return ""
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refused without a request: listed URLs and prefixes, and content known
+	// to be listed from an earlier download or the manifest
+	for _, name := range []string{"/blocked.pdf", "/out-of-scope/a.pdf", "/seen.pdf", "/listed.pdf"} {
+		_, err := d.Open(server.URL + name)
+		var skipErr *SkipError
+		if !errors.As(err, &skipErr) {
+			t.Errorf("Open(%s) error = %v, want a SkipError", name, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("skipped URLs made %d request(s), want none", requests)
+	}
+
+	// Content only found to be listed once fetched is not stored
+	path, err := d.Download(server.URL + "/other.pdf")
+	var skipErr *SkipError
+	if !errors.As(err, &skipErr) || skipErr.Checksum != badSum {
+		t.Fatalf("Download() = %q, %v; want a SkipError for checksum %s", path, err, badSum)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "documents", "pdf", "other")); !os.IsNotExist(err) {
+		t.Errorf("skipped download left a document directory behind: %v", err)
+	}
+
+	if _, err := NewSkipList(nil, []string{"abc"}); err == nil {
+		t.Error("NewSkipList() accepted a short checksum")
+	}
+}
+
+func TestSniffExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"pdf", "%PDF-1.7\n%\xe2\xe3\xcf\xd3", ".pdf"},
+		{"pdf after junk", "\r\n\r\n%PDF-1.4", ".pdf"},
+		{"word 97", "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00", ".doc"},
+		{"docx", "PK\x03\x04\x14\x00\x06\x00\x08\x00[Content_Types].xml", ".docx"},
+		{"odt", "PK\x03\x04\x14\x00\x00\x08\x00\x00mimetypeapplication/vnd.oasis.opendocument.text", ".odt"},
+		{"other zip", "PK\x03\x04\x14\x00\x00\x00photo.jpg", ""},
+		{"rtf", `{\rtf1\ansi\deff0`, ".rtf"},
+		{"pst", "!BDN\x00\x00\x00\x00SM\x17\x00", ".pst"},
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", ".jpg"},
+		{"tiff", "II*\x00\x08\x00\x00\x00", ".tif"},
+		{"html", "\n<!DOCTYPE html><html><body>Not found</body></html>", ".html"},
+		{"text", "UNITED STATES DISTRICT COURT\r\nSOUTHERN DISTRICT OF NEW YORK\n", ".txt"},
+		{"utf-8 text with bom", "\xef\xbb\xbfDéposition", ".txt"},
+		{"binary", "\x00\x01\x02\x03garbage", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := SniffExtension([]byte(tt.head)); got != tt.want {
+			t.Errorf("SniffExtension(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStoreSniffsExtensionlessDownloads(t *testing.T) {
+	root := t.TempDir()
+	d := New(root)
+	tests := []struct {
+		url     string
+		content string
+		want    string
+	}{
+		{"https://example.com/files/EFTA00010724", "%PDF-1.4 flight log", filepath.Join("pdf", "EFTA00010724", "EFTA00010724.pdf")},
+		{"https://example.com/view/memo", `{\rtf1 memo}`, filepath.Join("rtf", "memo", "memo.rtf")},
+		{"https://example.com/", "Plain transcript text\n", filepath.Join("txt", "downloaded", "downloaded.txt")},
+		{"https://example.com/blob/x1", "\x00\x01binary", filepath.Join("other", "x1", "x1")},
+		// An extension in the URL is kept, whatever the content
+		{"https://example.com/notes.txt", "%PDF-1.4 misnamed", filepath.Join("txt", "notes", "notes.txt")},
+	}
+	for _, tt := range tests {
+		path, err := d.Store(tt.url, strings.NewReader(tt.content))
+		if err != nil {
+			t.Errorf("Store(%s) error = %v", tt.url, err)
+			continue
+		}
+		if want := filepath.Join(root, tt.want); path != want {
+			t.Errorf("Store(%s) = %s, want %s", tt.url, path, want)
+		}
+		if data, _ := os.ReadFile(path); string(data) != tt.content {
+			t.Errorf("Store(%s) saved %q, want %q", tt.url, data, tt.content)
+		}
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same.pdf":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/ignores.pdf":
+			// Ignores the conditions but sends the same validator
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		case "/changed.pdf":
+			w.Header().Set("ETag", `"v2"`)
+		case "/broken.pdf":
+			http.Error(w, "oops", http.StatusInternalServerError)
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	d := New(t.TempDir())
+	tests := []struct {
+		path     string
+		recorded map[string]string
+		want     string
+	}{
+		{"/same.pdf", map[string]string{"ETag": `"v1"`}, RevalidateUnchanged},
+		{"/ignores.pdf", map[string]string{"Last-Modified": "Mon, 02 Jan 2006 15:04:05 GMT"}, RevalidateUnchanged},
+		{"/changed.pdf", map[string]string{"ETag": `"v1"`}, RevalidateChanged},
+		{"/missing.pdf", map[string]string{"ETag": `"v1"`}, RevalidateGone},
+		{"/same.pdf", nil, RevalidateUnknown},
+	}
+	for _, tt := range tests {
+		got, err := d.Revalidate(server.URL+tt.path, tt.recorded)
+		if err != nil {
+			t.Errorf("Revalidate(%s) error = %v", tt.path, err)
+			continue
+		}
+		if got.Status != tt.want {
+			t.Errorf("Revalidate(%s, %v) = %s, want %s", tt.path, tt.recorded, got.Status, tt.want)
+		}
+	}
+	if _, err := d.Revalidate(server.URL+"/broken.pdf", map[string]string{"ETag": `"v1"`}); err == nil {
+		t.Error("Revalidate() of a failing server succeeded, want error")
+	}
+
+	// The skip list and the breaker apply as they do to downloads
+	skip, err := NewSkipList([]string{server.URL + "/same.pdf"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = NewWithOptions(t.TempDir(), Options{Skip: skip, Breaker: NewBreaker(1, time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skipped *SkipError
+	if _, err := d.Revalidate(server.URL+"/same.pdf", map[string]string{"ETag": `"v1"`}); !errors.As(err, &skipped) {
+		t.Errorf("Revalidate() of a skipped URL = %v, want a *SkipError", err)
+	}
+	d.Revalidate(server.URL+"/broken.pdf", map[string]string{"ETag": `"v1"`})
+	var suspended *CircuitOpenError
+	if _, err := d.Revalidate(server.URL+"/changed.pdf", map[string]string{"ETag": `"v1"`}); !errors.As(err, &suspended) {
+		t.Errorf("Revalidate() after the host failed = %v, want a *CircuitOpenError", err)
+	}
+}
+
+func TestStoreKeepsDocumentsSharingAName(t *testing.T) {
+	root := t.TempDir()
+	stored := make(map[string][]string)
+	d, err := NewWithOptions(root, Options{StoredURLs: func(path string) []string { return stored[path] }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := func(url, content string) (string, error) {
+		path, err := d.Store(url, strings.NewReader(content))
+		if err == nil || err == ErrFileExists {
+			stored[path] = append(stored[path], CanonicalURL(url))
+		}
+		return path, err
+	}
+
+	first, err := store("https://a.example.com/exhibit1.pdf", "%PDF-1.4 first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Another source's exhibit1.pdf is a different document
+	second, err := store("https://b.example.com/exhibit1.pdf", "%PDF-1.4 second")
+	if err != nil {
+		t.Fatalf("Store() of a colliding name error = %v", err)
+	}
+	sum := sha256.Sum256([]byte("%PDF-1.4 second"))
+	want := filepath.Join(root, "pdf", fmt.Sprintf("exhibit1.%x", sum[:6]), fmt.Sprintf("exhibit1.%x.pdf", sum[:6]))
+	if second != want {
+		t.Errorf("Store() = %s, want %s", second, want)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "%PDF-1.4 first" {
+		t.Errorf("the first exhibit1.pdf was replaced with %q", data)
+	}
+	if path, err := store("https://b.example.com/exhibit1.pdf", "%PDF-1.4 second"); err != ErrFileExists || path != second {
+		t.Errorf("Store() again = %s, %v; want %s, ErrFileExists", path, err, second)
+	}
+
+	// Identical content from another URL is the same document
+	if path, err := store("https://c.example.com/exhibit1.pdf", "%PDF-1.4 first"); err != ErrFileExists || path != first {
+		t.Errorf("Store() of identical content = %s, %v; want %s, ErrFileExists", path, err, first)
+	}
+	// New content from a URL the document came from is a new version of it
+	if path, err := store("https://a.example.com/exhibit1.pdf", "%PDF-1.4 revised"); err != nil || path != first {
+		t.Errorf("Store() of a new version = %s, %v; want %s", path, err, first)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var requests int
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/missing.pdf":
+			http.NotFound(w, r)
+		case failing:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("%PDF-1.4"))
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	breaker := NewBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+	d, _ := NewWithOptions(t.TempDir(), Options{Breaker: breaker})
+	open := func(name string) error {
+		body, err := d.Open(server.URL + "/" + name)
+		if err == nil {
+			body.Close()
+		}
+		return err
+	}
+
+	// A missing document is an answer from a healthy server
+	open("missing.pdf")
+	open("a.pdf")
+	if err := d.Suspended(server.URL + "/b.pdf"); err != nil {
+		t.Fatalf("Suspended() after one failure = %v, want nil", err)
+	}
+	open("b.pdf")
+	var suspended *CircuitOpenError
+	if err := open("c.pdf"); !errors.As(err, &suspended) || requests != 3 {
+		t.Fatalf("Open() after two failures = %v after %d request(s), want a CircuitOpenError without a request", err, requests)
+	}
+	if d.Suspended(server.URL+"/d.pdf") == nil {
+		t.Error("Suspended() = nil, want the host suspended")
+	}
+	// Other hosts are not affected
+	if err := breaker.Allow("https://other.example.com/a.pdf"); err != nil {
+		t.Errorf("Allow() for another host = %v", err)
+	}
+
+	// After the cooldown a failed trial suspends the host again
+	now = now.Add(time.Minute)
+	if err := open("c.pdf"); errors.As(err, &suspended) || requests != 4 {
+		t.Fatalf("Open() after the cooldown = %v after %d request(s), want a trial request", err, requests)
+	}
+	if err := open("c.pdf"); !errors.As(err, &suspended) {
+		t.Fatalf("Open() after a failed trial = %v, want a CircuitOpenError", err)
+	}
+
+	// A successful trial resumes the host
+	now = now.Add(time.Minute)
+	failing = false
+	if err := open("c.pdf"); err != nil {
+		t.Fatalf("Open() of a recovered host error = %v", err)
+	}
+	if err := d.Suspended(server.URL + "/d.pdf"); err != nil {
+		t.Errorf("Suspended() after a successful trial = %v, want nil", err)
+	}
+}
+
+func TestDownloadAll(t *testing.T) {
+	// Every request waits until three are in flight at once, so the test
+	// only finishes if they really are downloaded in parallel
+	var arrived sync.WaitGroup
+	arrived.Add(3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		arrived.Done()
+		arrived.Wait()
+		fmt.Fprintf(w, "%%PDF-1.4 %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	d, err := NewWithOptions(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{server.URL + "/EFTA01.pdf", server.URL + "/missing.pdf", server.URL + "/EFTA02.pdf", server.URL + "/EFTA03.pdf"}
+	var reported []string
+	results := d.DownloadAll(urls, 4, nil, func(r Result) { reported = append(reported, r.URL) })
+
+	if len(reported) != len(urls) {
+		t.Errorf("reported %d results, want %d", len(reported), len(urls))
+	}
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("results[%d].URL = %s, want %s", i, r.URL, urls[i])
+		}
+	}
+	var status *StatusError
+	if !errors.As(results[1].Err, &status) || status.Code != http.StatusNotFound || results[1].Saved() {
+		t.Errorf("missing.pdf: err = %v, want a 404 StatusError", results[1].Err)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if !results[i].Saved() {
+			t.Fatalf("%s: %v", urls[i], results[i].Err)
+		}
+		if want := filepath.Join(dir, "pdf", strings.TrimSuffix(filepath.Base(urls[i]), ".pdf"), filepath.Base(urls[i])); results[i].Path != want {
+			t.Errorf("%s saved to %s, want %s", urls[i], results[i].Path, want)
+		}
+	}
+
+	// Downloading again finds the same files
+	arrived.Add(3)
+	for _, r := range d.DownloadAll(urls, 4, nil, nil) {
+		if r.URL != urls[1] && !errors.Is(r.Err, ErrFileExists) {
+			t.Errorf("%s downloaded again: err = %v, want ErrFileExists", r.URL, r.Err)
+		}
+	}
+}
+
+func TestDownloadAllStop(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprintf(w, "%%PDF-1.4 %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	d, err := NewWithOptions(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for i := range 10 {
+		urls = append(urls, fmt.Sprintf("%s/EFTA%02d.pdf", server.URL, i))
+	}
+	// Stop once the first two downloads are done
+	var done atomic.Int32
+	results := d.DownloadAll(urls, 2, func() bool { return done.Load() >= 2 }, func(r Result) { done.Add(1) })
+
+	if n := requests.Load(); n < 2 || n > 3 {
+		t.Errorf("%d URL(s) requested after stopping at 2, want at most one more in flight", n)
+	}
+	canceled := 0
+	for i, r := range results {
+		if r.URL != urls[i] {
+			t.Errorf("results[%d].URL = %s, want %s", i, r.URL, urls[i])
+		}
+		if errors.Is(r.Err, ErrCanceled) {
+			canceled++
+		} else if !r.Saved() {
+			t.Errorf("%s: %v", r.URL, r.Err)
+		}
+	}
+	if want := len(urls) - int(requests.Load()); canceled != want {
+		t.Errorf("%d result(s) canceled, want the %d never requested", canceled, want)
+	}
+}
+
+func TestRequestInterval(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		fmt.Fprintf(w, "%%PDF-1.4 %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	const interval = 50 * time.Millisecond
+	d, err := NewWithOptions(t.TempDir(), Options{RequestInterval: interval})
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{server.URL + "/1.pdf", server.URL + "/2.pdf", server.URL + "/3.pdf"}
+	for _, r := range d.DownloadAll(urls, 3, nil, nil) {
+		if r.Err != nil {
+			t.Fatalf("%s: %v", r.URL, r.Err)
+		}
+	}
+	// Parallel downloads still start one interval apart
+	if got := starts[len(starts)-1].Sub(starts[0]); got < 2*interval-5*time.Millisecond {
+		t.Errorf("3 requests started within %s, want at least %s", got, 2*interval)
+	}
+}
+
+func TestResumeInterruptedDownload(t *testing.T) {
+	content := []byte("%PDF-1.4 " + strings.Repeat("scanned page ", 2000))
+	tests := []struct {
+		name       string
+		ranges     bool   // the server honors Range requests
+		etag       string // served after the interruption
+		wantResume bool   // the second request continues from the .part file
+	}{
+		{name: "resumed", ranges: true, etag: `"v1"`, wantResume: true},
+		{name: "ranges not supported", ranges: false, etag: `"v1"`},
+		{name: "document changed", ranges: true, etag: `"v2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			var gotRange string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					// Promise the whole document, send half and hang up
+					w.Header().Set("ETag", `"v1"`)
+					w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+					w.WriteHeader(http.StatusOK)
+					w.Write(content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+					return
+				}
+				gotRange = r.Header.Get("Range")
+				if !tt.ranges {
+					w.Write(content)
+					return
+				}
+				w.Header().Set("ETag", tt.etag)
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			var transferred int64
+			d, err := NewWithOptions(t.TempDir(), Options{OnAttempt: func(a Attempt) { transferred = a.TransferBytes }})
+			if err != nil {
+				t.Fatal(err)
+			}
+			url := server.URL + "/EFTA00039025.pdf"
+			if _, err := d.Download(url); err == nil {
+				t.Fatal("interrupted download succeeded")
+			}
+			part := d.partialPath(url)
+			if info, err := os.Stat(part); err != nil || info.Size() != int64(len(content)/2) {
+				t.Fatalf("partial download not kept: %v", err)
+			}
+
+			path, err := d.Download(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, content) {
+				t.Errorf("stored %d bytes, want the %d of the document", len(got), len(content))
+			}
+			if want := fmt.Sprintf("bytes=%d-", len(content)/2); gotRange != want {
+				t.Errorf("Range = %q, want %q", gotRange, want)
+			}
+			want := int64(len(content))
+			if tt.wantResume {
+				want -= int64(len(content) / 2)
+			}
+			if transferred != want {
+				t.Errorf("transferred %d bytes, want %d", transferred, want)
+			}
+			if _, err := os.Stat(part); !os.IsNotExist(err) {
+				t.Errorf("partial download left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestInterruptedDownloadWithoutValidator(t *testing.T) {
+	v1 := []byte("%PDF-1.4 " + strings.Repeat("first version ", 2000))
+	v2 := []byte("%PDF-1.4 " + strings.Repeat("second version ", 2000))
+	var requests int
+	var gotRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// Neither an ETag nor a Last-Modified date: half of v1, then hang up
+			w.Header().Set("Content-Length", fmt.Sprint(len(v1)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(v1[:len(v1)/2])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		// The document changed, and the server would blindly continue a
+		// range into the new version
+		gotRange = r.Header.Get("Range")
+		var start int
+		if gotRange != "" {
+			fmt.Sscanf(gotRange, "bytes=%d-", &start)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(v2)-1, len(v2)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write(v2[start:])
+	}))
+	defer server.Close()
+
+	d := New(t.TempDir())
+	url := server.URL + "/EFTA00039025.pdf"
+	if _, err := d.Download(url); err == nil {
+		t.Fatal("interrupted download succeeded")
+	}
+	if _, err := os.Stat(d.partialPath(url)); !os.IsNotExist(err) {
+		t.Errorf("partial download without a validator kept: %v", err)
+	}
+	path, err := d.Download(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotRange != "" {
+		t.Errorf("Range = %q, want the document from byte 0", gotRange)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, v2) {
+		t.Errorf("stored %d bytes mixing versions, want the %d of the new version", len(got), len(v2))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/missing.pdf":
+			http.NotFound(w, r)
+		case r.URL.Path == "/flaky.pdf" && n <= 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/down.pdf":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			fmt.Fprint(w, "%PDF-1.4 document")
+		}
+	}))
+	defer server.Close()
+
+	var retried []int
+	d, err := NewWithOptions(t.TempDir(), Options{
+		Retry:   RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5},
+		OnRetry: func(url string, attempt int, wait time.Duration, err error) { retried = append(retried, attempt) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Download(server.URL + "/flaky.pdf"); err != nil {
+		t.Fatalf("flaky.pdf: %v", err)
+	}
+	if !slices.Equal(retried, []int{1, 2}) {
+		t.Errorf("retried after attempts %v, want [1 2]", retried)
+	}
+	var status *StatusError
+	if _, err := d.Download(server.URL + "/down.pdf"); !errors.As(err, &status) || status.Code != http.StatusBadGateway {
+		t.Errorf("down.pdf: err = %v, want 502", err)
+	}
+	if _, err := d.Download(server.URL + "/missing.pdf"); err == nil {
+		t.Error("missing.pdf downloaded")
+	}
+	if requests["/down.pdf"] != 3 || requests["/missing.pdf"] != 1 {
+		t.Errorf("requests = %v, want 3 for down.pdf and 1 for missing.pdf (404 is not retried)", requests)
+	}
+	if got, want := d.RetryStats(), (RetryStats{Retries: 4, Recovered: 1, Exhausted: 1}); got != want {
+		t.Errorf("RetryStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", &net.DNSError{Err: "i/o timeout", Name: "example.org", IsTimeout: true}, true},
+		{"reset", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"cut short", fmt.Errorf("failed to read body: %w", io.ErrUnexpectedEOF), true},
+		{"not found", &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, false},
+		{"404", &StatusError{Code: http.StatusNotFound}, false},
+		{"503", &StatusError{Code: http.StatusServiceUnavailable}, true},
+	}
+	for _, tt := range tests {
+		if got := policy.retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 10 * time.Second} {
+		if got := policy.delay(attempt, nil); got != want {
+			t.Errorf("delay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	throttled := &StatusError{Code: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+	if got := policy.delay(1, throttled); got != 7*time.Second {
+		t.Errorf("delay with Retry-After 7s = %s", got)
+	}
+	policy.Jitter = 0.2
+	for range 100 {
+		if got := policy.delay(1, nil); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("delay with 20%% jitter = %s, want within 0.8s-1.2s", got)
+		}
+	}
+}
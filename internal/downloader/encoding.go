@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// acceptEncoding lists the content codings decodeBody understands. Setting
+// Accept-Encoding by hand turns off net/http's transparent gzip handling, so
+// every coding offered here must be decoded here.
+const acceptEncoding = "gzip, deflate"
+
+// magicWindow is how far into a body its signature is looked for (PDF
+// readers accept junk before "%PDF-" within the first KiB)
+const magicWindow = 1024
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// fileMagic is the signature each file type starts with
+var fileMagic = map[string][]byte{
+	"pdf":  []byte("%PDF-"),
+	"doc":  {0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1},
+	"docx": []byte("PK\x03\x04"),
+	"odt":  []byte("PK\x03\x04"),
+	"rtf":  []byte(`{\rtf`),
+	"pst":  []byte("!BDN"),
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody undoes the content codings of a response body, listed in the
+// order they were applied
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		switch coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode gzip response: %w", err)
+			}
+			body = zr
+		case "deflate":
+			inflated, err := inflate(body)
+			if err != nil {
+				return nil, err
+			}
+			body = inflated
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", coding)
+		}
+	}
+	return body, nil
+}
+
+// inflate decodes a "deflate" body, which should be zlib-wrapped but which
+// some servers send as a raw deflate stream
+func inflate(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode deflate response: %w", err)
+	}
+	// A zlib header is CMF FLG with CM 8 and a checksum divisible by 31
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode deflate response: %w", err)
+		}
+		return zr, nil
+	}
+	return flate.NewReader(br), nil
+}
+
+// checkMagic makes sure a decoded body of the given file type starts with
+// that type's signature. A body that is still gzip data (a compressed file
+// served without Content-Encoding) is unwrapped first, so it is not saved
+// verbatim as a broken document.
+func checkMagic(body io.Reader, fileType string) (io.Reader, error) {
+	magic, ok := fileMagic[fileType]
+	if !ok {
+		return body, nil // no known signature to check
+	}
+	br := bufio.NewReaderSize(body, magicWindow)
+	head, err := br.Peek(magicWindow)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if bytes.HasPrefix(head, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+		br = bufio.NewReaderSize(zr, magicWindow)
+		if head, err = br.Peek(magicWindow); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+	}
+	if fileType == "pdf" && bytes.Contains(head, magic) || bytes.HasPrefix(head, magic) {
+		return br, nil
+	}
+	prefix := head[:min(len(head), 16)]
+	return nil, fmt.Errorf("response is not a %s file (starts with %q)", fileType, prefix)
+}
@@ -17,6 +17,8 @@ func GetFileType(filename string) string {
 		".rtf":  "rtf",
 		".txt":  "txt",
 		".odt":  "odt",
+		".eml":  "eml",
+		".pst":  "pst",
 	}
 	
 	if fileType, ok := extMap[ext]; ok {
@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// QuarantineDir is where downloads whose checksum does not match a
+// checksum manifest are moved, under the documents directory. Being hidden,
+// it is not scanned for documents.
+const QuarantineDir = ".quarantine"
+
+// Manifest holds the SHA-256 checksums a publisher lists for its files, by
+// file name. A name listed more than once (the same file in several
+// volumes) may have several checksums.
+type Manifest map[string][]string
+
+// ChecksumError reports a download whose checksum is not the one its
+// checksum manifest lists
+type ChecksumError struct {
+	URL         string
+	Expected    []string // checksums the manifest lists for the file name
+	Actual      string
+	Quarantined string // where the download was moved
+}
+
+// Error implements error
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: manifest lists %s, downloaded file is %s (quarantined at %s)",
+		e.URL, strings.Join(e.Expected, " or "), e.Actual, e.Quarantined)
+}
+
+var (
+	// gnuChecksumLine matches sha256sum output: "<hex>  name", or
+	// "<hex> *name" for files read in binary mode
+	gnuChecksumLine = regexp.MustCompile(`^\\?([0-9a-fA-F]{64}) [ *](.+)$`)
+	// bsdChecksumLine matches the BSD tag format: "SHA256 (name) = <hex>"
+	bsdChecksumLine = regexp.MustCompile(`^\\?SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+)
+
+// ParseManifest reads a checksum manifest in the format of sha256sum (GNU or
+// BSD style). Names are reduced to their last path element, since the
+// publisher's directory layout is not the documents tree's. Any other line
+// is an error: a manifest that fails to parse is more likely an error page
+// than a manifest.
+func ParseManifest(r io.Reader) (Manifest, error) {
+	m := make(Manifest)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var name, sum string
+		if match := gnuChecksumLine.FindStringSubmatch(line); match != nil {
+			sum, name = match[1], match[2]
+		} else if match := bsdChecksumLine.FindStringSubmatch(line); match != nil {
+			name, sum = match[1], match[2]
+		} else {
+			return nil, fmt.Errorf("line %d is not a SHA-256 checksum line", lineNumber)
+		}
+		name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+		m.add(name, strings.ToLower(sum))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksum manifest: %w", err)
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("checksum manifest lists no files")
+	}
+	return m, nil
+}
+
+// add records a checksum for name, once
+func (m Manifest) add(name, sum string) {
+	for _, existing := range m[name] {
+		if existing == sum {
+			return
+		}
+	}
+	m[name] = append(m[name], sum)
+}
+
+// Check reports whether sum is a checksum the manifest lists for name, and
+// whether it lists name at all
+func (m Manifest) Check(name string, sum [32]byte) (ok, listed bool) {
+	sums, listed := m[name]
+	hexSum := hex.EncodeToString(sum[:])
+	for _, s := range sums {
+		if s == hexSum {
+			return true, true
+		}
+	}
+	return false, listed
+}
+
+// manifest returns the downloader's checksum manifests, merged, fetching
+// them on first use so runs that download nothing never fetch them. It
+// returns nil if none are configured.
+func (d *Downloader) manifest() (Manifest, error) {
+	d.manifestOnce.Do(func() {
+		for _, location := range d.manifestURLs {
+			m, err := d.loadManifest(location)
+			if err != nil {
+				d.manifestErr = fmt.Errorf("failed to load checksum manifest %s: %w", location, err)
+				return
+			}
+			if d.checksums == nil {
+				d.checksums = make(Manifest)
+			}
+			for name, sums := range m {
+				for _, sum := range sums {
+					d.checksums.add(name, sum)
+				}
+			}
+		}
+	})
+	return d.checksums, d.manifestErr
+}
+
+// loadManifest reads the checksum manifest at location, a URL or a local
+// file
+func (d *Downloader) loadManifest(location string) (Manifest, error) {
+	var body io.ReadCloser
+	var err error
+	if strings.Contains(location, "://") {
+		var s *spool
+		if s, err = d.fetch(location); err == nil {
+			body, err = s.Reader()
+		}
+	} else {
+		body, err = os.Open(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ParseManifest(body)
+}
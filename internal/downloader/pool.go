@@ -0,0 +1,103 @@
+package downloader
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of downloading one URL of a batch
+type Result struct {
+	URL  string
+	Path string // where the document is stored, also set when Err is ErrFileExists
+	Err  error  // nil if the document was saved, ErrFileExists if it was already
+}
+
+// ErrCanceled is the Result.Err of a URL that DownloadAll never started
+// because it was stopped
+var ErrCanceled = errors.New("download canceled before it started")
+
+// Saved reports whether the document is on disk, newly saved or unchanged
+func (r Result) Saved() bool {
+	return r.Err == nil || errors.Is(r.Err, ErrFileExists)
+}
+
+// DownloadAll downloads urls with up to concurrency of them in flight at
+// once, as Download does each, and returns their results in the order of
+// urls. report, if not nil, is called with each result as it completes, from
+// one goroutine at a time. Fetches run in parallel; storing is serialized so
+// that two URLs naming the same document never write it at the same time.
+// Once stop, if not nil, returns true no further URL is started: downloads
+// in flight finish and the rest are reported with ErrCanceled.
+func (d *Downloader) DownloadAll(urls []string, concurrency int, stop func() bool, report func(Result)) []Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]Result, len(urls))
+	for i := range urls {
+		results[i] = Result{URL: urls[i], Err: ErrCanceled}
+	}
+	jobs := make(chan int)
+	var storeMu, reportMu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(concurrency, len(urls)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if stop != nil && stop() {
+					continue
+				}
+				result := Result{URL: urls[i]}
+				if body, err := d.Open(urls[i]); err != nil {
+					result.Err = err
+				} else {
+					storeMu.Lock()
+					result.Path, result.Err = d.Store(urls[i], body)
+					storeMu.Unlock()
+					body.Close()
+				}
+				results[i] = result
+				if report != nil {
+					reportMu.Lock()
+					report(result)
+					reportMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range urls {
+		if stop != nil && stop() {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// pacer spaces the starts of requests at least interval apart, however many
+// goroutines make them
+type pacer struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next request may start
+}
+
+// wait blocks until the next request may start and books its slot. A nil
+// pacer never waits.
+func (p *pacer) wait() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	start := time.Now()
+	if p.next.After(start) {
+		start = p.next
+	}
+	p.next = start.Add(p.interval)
+	p.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
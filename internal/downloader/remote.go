@@ -0,0 +1,246 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// supportedSchemes lists the URL schemes Download can fetch
+var supportedSchemes = []string{"http", "https", "ftp", "sftp", "s3", "ia"}
+
+// IsURL reports whether input is a URL with a scheme the downloader supports
+func IsURL(input string) bool {
+	for _, scheme := range supportedSchemes {
+		if strings.HasPrefix(strings.ToLower(input), scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPorts maps each supported scheme to its default port
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ftp": "21", "sftp": "22"}
+
+// CanonicalURL normalizes rawURL so the same document referenced in different
+// ways compares equal: the scheme and host are lower-cased and default ports
+// and fragments are dropped. Unparseable input is returned unchanged.
+func CanonicalURL(rawURL string) string {
+	u, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// mirrorURL maps URL schemes that are served over HTTPS to their HTTPS URL:
+// s3://bucket/key to the bucket's public endpoint and ia://identifier/file
+// to the Internet Archive download URL
+func mirrorURL(rawURL string) (string, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("invalid %s URL %q (expected %s://%s/path)", u.Scheme, rawURL, u.Scheme, map[string]string{"s3": "bucket", "ia": "identifier"}[u.Scheme])
+	}
+	mirror := &neturl.URL{Scheme: "https", RawQuery: u.RawQuery}
+	switch u.Scheme {
+	case "s3":
+		// Anonymous access only, so this works for public buckets
+		mirror.Host = u.Host + ".s3.amazonaws.com"
+		mirror.Path = u.Path
+	case "ia":
+		mirror.Host = "archive.org"
+		mirror.Path = "/download/" + u.Host + u.Path
+	default:
+		return "", fmt.Errorf("no HTTPS mirror for %s URLs", u.Scheme)
+	}
+	return mirror.String(), nil
+}
+
+// urlScheme returns the lower-cased scheme of rawURL
+func urlScheme(rawURL string) string {
+	if idx := strings.Index(rawURL, "://"); idx != -1 {
+		return strings.ToLower(rawURL[:idx])
+	}
+	return ""
+}
+
+// fetchFTP downloads a document over FTP, logging in anonymously unless the
+// URL carries credentials
+func (d *Downloader) fetchFTP(rawURL string, w io.Writer) error {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid FTP URL: %w", err)
+	}
+	// The control and data connections are both dialed like HTTP ones
+	dial := func(network, address string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		return d.dial(ctx, network, address)
+	}
+	conn, err := ftp.Dial(hostWithPort(u, "21"), ftp.DialWithDialFunc(dial))
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Quit()
+
+	user, password := "anonymous", "anonymous"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	if err := conn.Login(user, password); err != nil {
+		return fmt.Errorf("failed to log in as %s: %w", user, err)
+	}
+
+	resp, err := conn.Retr(u.Path)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Close()
+
+	body, err := checkMagic(resp, GetFileType(extractFilenameFromURL(rawURL)))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	return nil
+}
+
+// fetchSFTP downloads a document over SFTP, authenticating with the
+// configured private key (and a password from the URL, if any)
+func (d *Downloader) fetchSFTP(rawURL string, w io.Writer) error {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid SFTP URL: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return fmt.Errorf("SFTP URL must include a user name (sftp://user@host/path)")
+	}
+
+	sshConfig, err := d.sshClientConfig(u.User)
+	if err != nil {
+		return err
+	}
+	client, err := d.dialSSH(hostWithPort(u, "22"), sshConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer client.Close()
+
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	defer sftpClient.Close()
+
+	file, err := sftpClient.Open(u.Path)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer file.Close()
+
+	body, err := checkMagic(file, GetFileType(extractFilenameFromURL(rawURL)))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to read remote file: %w", err)
+	}
+	return nil
+}
+
+// dialSSH opens an SSH connection to addr, dialed like HTTP ones. As with
+// ssh.Dial, the handshake must finish within the configured timeout.
+func (d *Downloader) dialSSH(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+	conn, err := d.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// sshClientConfig builds the SSH configuration for an SFTP download. Server
+// host keys are always verified against a known_hosts file.
+func (d *Downloader) sshClientConfig(user *neturl.Userinfo) (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if d.sftpKeyFile != "" {
+		keyBytes, err := os.ReadFile(d.sftpKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SFTP key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(keyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SFTP key %s: %w", d.sftpKeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if password, ok := user.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("no SFTP credentials: set sftp_key_file in the config or include a password in the URL")
+	}
+
+	knownHostsFile := d.sftpKnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts %s: %w", knownHostsFile, err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            user.Username(),
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         DefaultTimeout,
+	}, nil
+}
+
+// hostWithPort returns the URL's host:port, using defaultPort if none is given
+func hostWithPort(u *neturl.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
@@ -0,0 +1,327 @@
+package downloader
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// countDials makes d count the connections it dials
+func countDials(d *Downloader) *atomic.Int32 {
+	var n atomic.Int32
+	dial := d.dial
+	d.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		n.Add(1)
+		return dial(ctx, network, address)
+	}
+	return &n
+}
+
+// ftpServer serves files over FTP on a local port: just enough of the
+// protocol (login, extended passive mode, RETR) for fetchFTP
+func ftpServer(t *testing.T, files map[string]string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFTP(conn, files)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFTP(conn net.Conn, files map[string]string) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 ready")
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch cmd {
+		case "USER":
+			tp.PrintfLine("331 password required")
+		case "PASS":
+			tp.PrintfLine("230 logged in")
+		case "TYPE":
+			tp.PrintfLine("200 type set")
+		case "EPSV":
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				tp.PrintfLine("425 cannot listen")
+				continue
+			}
+			tp.PrintfLine("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "RETR":
+			content, ok := files[arg]
+			if !ok || data == nil {
+				tp.PrintfLine("550 %s: no such file", arg)
+				continue
+			}
+			dc, err := data.Accept()
+			if err != nil {
+				return
+			}
+			tp.PrintfLine("150 opening data connection")
+			io.WriteString(dc, content)
+			dc.Close()
+			data.Close()
+			data = nil
+			tp.PrintfLine("226 transfer complete")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 %s not implemented", cmd)
+		}
+	}
+}
+
+func TestFetchFTP(t *testing.T) {
+	addr := ftpServer(t, map[string]string{
+		"/docs/EFTA00010724.pdf": "%PDF-1.4 flight log",
+		"/docs/EFTA00010725.pdf": "<html>Access denied</html>",
+	})
+	d := New(t.TempDir())
+	dials := countDials(d)
+
+	body, err := d.Open("ftp://" + addr + "/docs/EFTA00010724.pdf")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, _ := io.ReadAll(body)
+	body.Close()
+	if string(content) != "%PDF-1.4 flight log" {
+		t.Errorf("Open() read %q", content)
+	}
+	// The control and the data connection
+	if n := dials.Load(); n != 2 {
+		t.Errorf("%d connection(s) dialed through the downloader's dialer, want 2", n)
+	}
+
+	// A file that is not what its name says is refused, as over HTTP
+	if body, err := d.Open("ftp://" + addr + "/docs/EFTA00010725.pdf"); err == nil {
+		body.Close()
+		t.Error("Open() of an HTML page as a PDF over FTP succeeded")
+	} else if !strings.Contains(err.Error(), "not a pdf file") {
+		t.Errorf("Open() of an HTML page as a PDF over FTP error = %v, want the signature check's", err)
+	}
+	if body, err := d.Open("ftp://" + addr + "/docs/missing.pdf"); err == nil {
+		body.Close()
+		t.Error("Open() of a missing file over FTP succeeded")
+	}
+}
+
+// sshServer serves the local filesystem over SFTP on a local port to user
+// with password, and returns its address and host key
+func sshServer(t *testing.T, user, password string) (string, ssh.PublicKey) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, given []byte) (*ssh.Permissions, error) {
+			if conn.User() == user && string(given) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("wrong password for %s", conn.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(conn, config)
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func serveSFTP(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session channels only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err == nil {
+						server.Serve()
+					}
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+// writeKnownHosts writes a known_hosts file listing key for addr
+func writeKnownHosts(t *testing.T, addr string, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, key)
+	if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetchSFTP(t *testing.T) {
+	addr, hostKey := sshServer(t, "archivist", "secret")
+	dir := t.TempDir()
+	pdf := filepath.Join(dir, "EFTA00010724.pdf")
+	html := filepath.Join(dir, "EFTA00010725.pdf")
+	os.WriteFile(pdf, []byte("%PDF-1.4 flight log"), 0644)
+	os.WriteFile(html, []byte("<html>Access denied</html>"), 0644)
+
+	d, err := NewWithOptions(t.TempDir(), Options{SFTPKnownHosts: writeKnownHosts(t, addr, hostKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dials := countDials(d)
+
+	body, err := d.Open("sftp://archivist:secret@" + addr + filepath.ToSlash(pdf))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, _ := io.ReadAll(body)
+	body.Close()
+	if string(content) != "%PDF-1.4 flight log" {
+		t.Errorf("Open() read %q", content)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Errorf("%d connection(s) dialed through the downloader's dialer, want 1", n)
+	}
+
+	if body, err := d.Open("sftp://archivist:secret@" + addr + filepath.ToSlash(html)); err == nil {
+		body.Close()
+		t.Error("Open() of an HTML page as a PDF over SFTP succeeded")
+	} else if !strings.Contains(err.Error(), "not a pdf file") {
+		t.Errorf("Open() of an HTML page as a PDF over SFTP error = %v, want the signature check's", err)
+	}
+	if body, err := d.Open("sftp://archivist:wrong@" + addr + filepath.ToSlash(pdf)); err == nil {
+		body.Close()
+		t.Error("Open() with a wrong password succeeded")
+	}
+
+	// A server whose host key is not the known one is never authenticated to
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublic, _ := ssh.NewPublicKey(otherKey.Public())
+	d.sftpKnownHosts = writeKnownHosts(t, addr, otherPublic)
+	if body, err := d.Open("sftp://archivist:secret@" + addr + filepath.ToSlash(pdf)); err == nil {
+		body.Close()
+		t.Error("Open() from a server with an unknown host key succeeded")
+	} else if !strings.Contains(err.Error(), "key mismatch") {
+		t.Errorf("Open() from a server with an unknown host key error = %v, want a key mismatch", err)
+	}
+}
+
+func TestSSHClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	os.WriteFile(knownHosts, nil, 0600)
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+	badKeyFile := filepath.Join(dir, "id_bad")
+	os.WriteFile(badKeyFile, []byte("not a key"), 0600)
+
+	tests := []struct {
+		name       string
+		keyFile    string
+		knownHosts string
+		user       *neturl.Userinfo
+		wantErr    string
+		wantAuth   int
+	}{
+		{"password", "", knownHosts, neturl.UserPassword("archivist", "secret"), "", 1},
+		{"key", keyFile, knownHosts, neturl.User("archivist"), "", 1},
+		{"key and password", keyFile, knownHosts, neturl.UserPassword("archivist", "secret"), "", 2},
+		{"no credentials", "", knownHosts, neturl.User("archivist"), "no SFTP credentials", 0},
+		{"missing key", filepath.Join(dir, "missing"), knownHosts, neturl.User("archivist"), "failed to read SFTP key", 0},
+		{"invalid key", badKeyFile, knownHosts, neturl.User("archivist"), "failed to parse SFTP key", 0},
+		{"missing known_hosts", keyFile, filepath.Join(dir, "missing_hosts"), neturl.User("archivist"), "failed to load known_hosts", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(t.TempDir())
+			d.sftpKeyFile, d.sftpKnownHosts = tt.keyFile, tt.knownHosts
+			config, err := d.sshClientConfig(tt.user)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("sshClientConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sshClientConfig() error = %v", err)
+			}
+			if config.User != "archivist" || len(config.Auth) != tt.wantAuth || config.HostKeyCallback == nil {
+				t.Errorf("sshClientConfig() = user %q, %d auth method(s), want archivist and %d", config.User, len(config.Auth), tt.wantAuth)
+			}
+		})
+	}
+}
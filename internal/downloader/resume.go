@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PartialDir is where interrupted downloads are kept, under the documents
+// directory, until a later fetch of the same URL resumes them with a Range
+// request. Being hidden, it is not scanned for documents.
+const PartialDir = ".partial"
+
+// partial is the interrupted download of a URL, held in a .part file
+type partial struct {
+	path    string // the .part file
+	offset  int64  // bytes it holds, where the transfer resumes
+	ifRange string // strong ETag or Last-Modified of the response it came from
+}
+
+// interruptedError reports a transfer cut short after part of the body
+// arrived, which a Range request can continue
+type interruptedError struct {
+	err error
+}
+
+// Error implements error
+func (e *interruptedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
+// errCannotResume reports a resumed transfer the server did not continue
+// where the .part file ends, which must start over
+var errCannotResume = errors.New("cannot resume the interrupted download")
+
+// partialPath returns where the interrupted download of rawURL is kept:
+// named after the document, prefixed with the start of the URL's checksum
+// so URLs sharing a file name do not mix their parts
+func (d *Downloader) partialPath(rawURL string) string {
+	name := extractFilenameFromURL(rawURL)
+	if name == "" {
+		name = "downloaded"
+	}
+	sum := sha256.Sum256([]byte(CanonicalURL(rawURL)))
+	return filepath.Join(d.documentsDir, PartialDir, fmt.Sprintf("%x-%s.part", sum[:6], name))
+}
+
+// loadPartial returns the interrupted download of rawURL, or nil if there is
+// none or it was not fetched over HTTP. A .part file without a validator is
+// dropped: nothing would tell whether the document changed since, and
+// appending the rest of a different version to it would corrupt the
+// download.
+func (d *Downloader) loadPartial(rawURL string) *partial {
+	if scheme := urlScheme(rawURL); scheme == "ftp" || scheme == "sftp" {
+		return nil
+	}
+	path := d.partialPath(rawURL)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+	validator, err := os.ReadFile(path + ".validator")
+	if err != nil || strings.TrimSpace(string(validator)) == "" {
+		d.dropPartial(rawURL)
+		return nil
+	}
+	return &partial{path: path, offset: info.Size(), ifRange: strings.TrimSpace(string(validator))}
+}
+
+// savePartial keeps what body received of rawURL before the transfer was cut
+// short, with the validator of the response it came from, for the next fetch
+// to resume. A response with neither a strong ETag nor a Last-Modified date
+// cannot be resumed safely, so its transfer is dropped and the next fetch
+// starts from byte 0. Failing to keep it only means starting over, so errors
+// are ignored.
+func (d *Downloader) savePartial(rawURL string, body *spool, headers map[string]string) {
+	// If-Range takes a strong ETag or a date; a weak ETag never matches
+	validator := headers["ETag"]
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = headers["Last-Modified"]
+	}
+	if body.Len() == 0 || validator == "" {
+		d.dropPartial(rawURL)
+		return
+	}
+	path := d.partialPath(rawURL)
+	if err := d.perms.MkdirAll(filepath.Dir(path)); err != nil {
+		return
+	}
+	r, err := body.Reader()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	tmp, err := d.perms.CreateAtomic(path)
+	if err != nil {
+		return
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Abort()
+		return
+	}
+	if err := tmp.Commit(); err != nil {
+		return
+	}
+	if err := d.perms.WriteFile(path+".validator", []byte(validator+"\n")); err != nil {
+		d.dropPartial(rawURL)
+	}
+}
+
+// dropPartial removes the interrupted download of rawURL, once completed or
+// found not to be resumable
+func (d *Downloader) dropPartial(rawURL string) {
+	path := d.partialPath(rawURL)
+	os.Remove(path)
+	os.Remove(path + ".validator")
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 1000-4999/5000"
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	return start, err == nil
+}
@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryMaxDelay caps the wait before a retry when RetryPolicy.MaxDelay
+// is not set, including waits a server asks for with Retry-After
+const DefaultRetryMaxDelay = 2 * time.Minute
+
+// DefaultRetryStatuses are the HTTP statuses retried when
+// RetryPolicy.Statuses is not set: throttling and the errors of overloaded
+// or restarting servers
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy decides how a fetch that failed transiently is tried again.
+// Timeouts, connections reset or refused, transfers cut short (which resume
+// where they stopped, see PartialDir) and the HTTP statuses in Statuses are
+// retried; hosts that do not resolve, missing or refused documents, skipped
+// URLs and hosts suspended by the Breaker are not. The zero RetryPolicy never retries.
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per fetch, the first included; 1 or less never retries
+	BaseDelay   time.Duration // wait before the first retry, doubled before each one after
+	MaxDelay    time.Duration // longest wait (default: DefaultRetryMaxDelay)
+	Jitter      float64       // random spread of each wait, as a fraction of it: 0.2 waits 80% to 120%
+	Statuses    []int         // HTTP statuses retried (default: DefaultRetryStatuses)
+}
+
+// RetryStats counts the retries a downloader made
+type RetryStats struct {
+	Retries   int // fetches made again after a transient failure
+	Recovered int // URLs fetched after one or more retries
+	Exhausted int // URLs still failing after the last attempt
+}
+
+// retryCounter accumulates RetryStats across concurrent fetches
+type retryCounter struct {
+	mu    sync.Mutex
+	stats RetryStats
+}
+
+// retryable reports whether err is a transient failure worth another attempt
+func (p RetryPolicy) retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		statuses := p.Statuses
+		if statuses == nil {
+			statuses = DefaultRetryStatuses
+		}
+		return slices.Contains(statuses, status.Code)
+	}
+	// Only failures a later attempt can get past: timeouts, connections
+	// reset or refused by a restarting server and transfers cut short. A
+	// host that does not resolve or a TLS error fails the same way again.
+	var interrupted *interruptedError
+	var netErr net.Error
+	return errors.As(err, &interrupted) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// delay returns how long to wait after the given failed attempt (1 for the
+// first): the base delay doubled for each attempt before, or what the server
+// asked for with Retry-After if longer, capped and spread by the jitter
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	wait := p.BaseDelay
+	for i := 1; i < attempt && wait < maxDelay; i++ {
+		wait *= 2
+	}
+	var status *StatusError
+	if errors.As(err, &status) && status.RetryAfter > wait {
+		wait = status.RetryAfter
+	}
+	wait = min(wait, maxDelay)
+	if p.Jitter > 0 {
+		wait = time.Duration(float64(wait) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date, 0 if
+// absent or invalid
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// fetch retrieves the document at rawURL into a spool like fetchOnce,
+// trying again as the retry policy allows when it fails transiently
+func (d *Downloader) fetch(rawURL string) (*spool, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := d.fetchOnce(rawURL)
+		if err == nil || attempt >= d.retry.MaxAttempts || !d.retry.retryable(err) {
+			d.retries.mu.Lock()
+			switch {
+			case attempt == 1:
+			case err == nil:
+				d.retries.stats.Recovered++
+			default:
+				d.retries.stats.Exhausted++
+			}
+			d.retries.mu.Unlock()
+			return body, err
+		}
+		wait := d.retry.delay(attempt, err)
+		if d.onRetry != nil {
+			d.onRetry(rawURL, attempt, wait, err)
+		}
+		d.retries.mu.Lock()
+		d.retries.stats.Retries++
+		d.retries.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// RetryStats returns the retries the downloader made so far
+func (d *Downloader) RetryStats() RetryStats {
+	d.retries.mu.Lock()
+	defer d.retries.mu.Unlock()
+	return d.retries.stats
+}
@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Revalidation outcomes
+const (
+	RevalidateUnchanged = "unchanged" // the server confirmed the recorded version
+	RevalidateChanged   = "changed"   // the server has different content
+	RevalidateGone      = "gone"      // 404 Not Found or 410 Gone
+	RevalidateUnknown   = "unknown"   // no ETag or Last-Modified recorded, or a scheme without them
+)
+
+// Revalidation is the result of revalidating a URL
+type Revalidation struct {
+	URL    string
+	Status string // one of the Revalidate* outcomes
+	Code   int    // HTTP status code, 0 if no request was made
+	// Headers holds the ResponseHeaders the server sent
+	Headers map[string]string
+}
+
+// Revalidate asks the server whether the document at url still matches the
+// ETag and Last-Modified of recorded (ResponseHeaders of an earlier fetch),
+// with a conditional request: If-None-Match and If-Modified-Since. The body of
+// a changed document is not read. Servers that ignore the conditions are
+// judged by the validators they send back. Like a download, the request
+// waits its turn (Options.RequestInterval) and counts towards the Breaker;
+// a URL the skip list refuses is not requested and a *SkipError is returned.
+func (d *Downloader) Revalidate(url string, recorded map[string]string) (*Revalidation, error) {
+	if err := d.Skipped(url); err != nil {
+		return nil, err
+	}
+	result := &Revalidation{URL: url, Status: RevalidateUnknown}
+	etag, modified := recorded["ETag"], recorded["Last-Modified"]
+	if etag == "" && modified == "" {
+		return result, nil
+	}
+	httpURL := url
+	switch urlScheme(url) {
+	case "ftp", "sftp":
+		return result, nil
+	case "s3", "ia":
+		var err error
+		if httpURL, err = mirrorURL(url); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.breaker.Allow(url); err != nil {
+		return nil, err
+	}
+	d.pacer.wait()
+	err := d.revalidate(httpURL, etag, modified, result)
+	d.breaker.Record(url, err)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// revalidate sends the conditional request of Revalidate to httpURL and
+// fills in result from the response
+func (d *Downloader) revalidate(httpURL, etag, modified string, result *Revalidation) error {
+	req, err := http.NewRequest("GET", httpURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", d.userAgent)
+	if d.from != "" {
+		req.Header.Set("From", d.from)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		req.Header.Set("If-Modified-Since", modified)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revalidate %s: %w", result.URL, err)
+	}
+	// Closing without reading abandons the body of a changed document
+	resp.Body.Close()
+	result.Code = resp.StatusCode
+	result.Headers = make(map[string]string)
+	keepHeaders(resp, result.Headers)
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		result.Status = RevalidateUnchanged
+	case http.StatusNotFound, http.StatusGone:
+		result.Status = RevalidateGone
+	case http.StatusOK:
+		result.Status = RevalidateChanged
+		if sent := result.Headers["ETag"]; etag != "" && sent != "" {
+			if sent == etag {
+				result.Status = RevalidateUnchanged
+			}
+		} else if modified != "" && result.Headers["Last-Modified"] == modified {
+			result.Status = RevalidateUnchanged
+		}
+	default:
+		return &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// SkipList holds the URLs and content checksums that are never downloaded:
+// known-bad files, duplicates and documents out of scope
+type SkipList struct {
+	urls      map[string]bool // canonical URLs
+	prefixes  []string        // canonical URL prefixes, from entries ending in "*"
+	checksums map[string]bool // lowercase hex SHA-256
+}
+
+// SkipError reports an input refused because it is on the skip list
+type SkipError struct {
+	URL      string
+	Checksum string // the listed checksum, if the content was refused rather than the URL
+}
+
+// Error implements error
+func (e *SkipError) Error() string {
+	if e.Checksum != "" {
+		return fmt.Sprintf("%s has checksum %s, which is on the skip list", e.URL, e.Checksum)
+	}
+	return fmt.Sprintf("%s is on the skip list", e.URL)
+}
+
+// NewSkipList builds a skip list from URLs, where an entry ending in "*"
+// skips every URL starting with the rest, and hex SHA-256 checksums
+func NewSkipList(urls, checksums []string) (*SkipList, error) {
+	s := &SkipList{urls: make(map[string]bool), checksums: make(map[string]bool)}
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" || url == "*" {
+			return nil, fmt.Errorf("invalid skip list URL %q", url)
+		}
+		if prefix, ok := strings.CutSuffix(url, "*"); ok {
+			s.prefixes = append(s.prefixes, CanonicalURL(prefix))
+		} else {
+			s.urls[CanonicalURL(url)] = true
+		}
+	}
+	for _, sum := range checksums {
+		sum = strings.ToLower(strings.TrimSpace(sum))
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+			return nil, fmt.Errorf("invalid skip list checksum %q (expected 64 hex digits of SHA-256)", sum)
+		}
+		s.checksums[sum] = true
+	}
+	return s, nil
+}
+
+// SkipsURL reports whether url is listed, directly or under a prefix
+func (s *SkipList) SkipsURL(url string) bool {
+	if s == nil {
+		return false
+	}
+	url = CanonicalURL(url)
+	if s.urls[url] {
+		return true
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipsChecksum reports whether the hex SHA-256 checksum sum is listed
+func (s *SkipList) SkipsChecksum(sum string) bool {
+	return s != nil && s.checksums[strings.ToLower(sum)]
+}
+
+// Skipped returns a *SkipError if url must not be downloaded: when it is on
+// the skip list, or when its content is known in advance to have a listed
+// checksum, from an earlier download (Options.KnownChecksum) or from every
+// checksum the checksum manifests list for its file name. It makes no
+// request.
+func (d *Downloader) Skipped(url string) error {
+	if d.skip == nil {
+		return nil
+	}
+	if d.skip.SkipsURL(url) {
+		return &SkipError{URL: url}
+	}
+	if d.knownChecksum != nil {
+		if sum := d.knownChecksum(url); sum != "" && d.skip.SkipsChecksum(sum) {
+			return &SkipError{URL: url, Checksum: sum}
+		}
+	}
+	manifest, err := d.manifest()
+	if err != nil {
+		return err
+	}
+	if listed := manifest[extractFilenameFromURL(url)]; len(listed) > 0 {
+		for _, sum := range listed {
+			if !d.skip.SkipsChecksum(sum) {
+				return nil
+			}
+		}
+		return &SkipError{URL: url, Checksum: listed[0]}
+	}
+	return nil
+}
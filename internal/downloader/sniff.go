@@ -0,0 +1,74 @@
+package downloader
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// sniffWindow is how much of a download is looked at to tell its type
+const sniffWindow = 4096
+
+// SniffExtension returns the file extension (with its dot) of the content
+// starting with head, for downloads whose URL names no extension: ".pdf",
+// ".doc", ".docx", ".odt", ".rtf", ".pst", ".jpg", ".tif", ".html" or
+// ".txt". It returns "" for content it does not recognize.
+func SniffExtension(head []byte) string {
+	switch {
+	case bytes.Contains(head[:min(len(head), magicWindow)], fileMagic["pdf"]):
+		return ".pdf"
+	case bytes.HasPrefix(head, fileMagic["doc"]):
+		return ".doc"
+	case bytes.HasPrefix(head, fileMagic["rtf"]):
+		return ".rtf"
+	case bytes.HasPrefix(head, fileMagic["pst"]):
+		return ".pst"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return sniffZip(head)
+	case bytes.HasPrefix(head, []byte{0xff, 0xd8, 0xff}):
+		return ".jpg"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return ".tif"
+	}
+	text := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	if !looksLikeText(text) {
+		return ""
+	}
+	start := bytes.ToLower(bytes.TrimSpace(text[:min(len(text), 512)]))
+	if bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html")) {
+		return ".html"
+	}
+	return ".txt"
+}
+
+// sniffZip tells the zip-based document formats apart by their first
+// entries: an OpenDocument file starts with its uncompressed mimetype, and a
+// Word document with the [Content_Types].xml of Office Open XML
+func sniffZip(head []byte) string {
+	switch {
+	case bytes.Contains(head, []byte("mimetypeapplication/vnd.oasis.opendocument.text")):
+		return ".odt"
+	case bytes.Contains(head, []byte("[Content_Types].xml")), bytes.Contains(head, []byte("word/")):
+		return ".docx"
+	}
+	return ""
+}
+
+// looksLikeText reports whether head is text: UTF-8 (a rune cut off at the
+// end of the window aside) without NUL bytes or other control characters
+// besides whitespace
+func looksLikeText(head []byte) bool {
+	if len(bytes.TrimSpace(head)) == 0 {
+		return false
+	}
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size <= 1 {
+			return len(head) < utf8.UTFMax && !utf8.FullRune(head)
+		}
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' && r != '\f' || r == 0x7f {
+			return false
+		}
+		head = head[size:]
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"defornicate-epstein-files/internal/budget"
+	"defornicate-epstein-files/internal/scratch"
+)
+
+// spool buffers a download in memory while the memory budget allows and
+// spills it to a temporary file once it does not
+type spool struct {
+	budget   *budget.Budget
+	scratch  *scratch.Dir // where to spill
+	buf      bytes.Buffer
+	reserved int64
+	file     *os.File
+	size     int64
+}
+
+// Write implements io.Writer
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil {
+		if s.budget.TryAcquire(int64(len(p))) {
+			s.reserved += int64(len(p))
+			s.size += int64(len(p))
+			return s.buf.Write(p)
+		}
+		if err := s.spill(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// spill moves the buffered data to a temporary file and frees its share of
+// the budget
+func (s *spool) spill() error {
+	file, err := s.scratch.CreateTemp("*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	if _, err := file.Write(s.buf.Bytes()); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.file = file
+	s.buf = bytes.Buffer{}
+	s.budget.Release(s.reserved)
+	s.reserved = 0
+	return nil
+}
+
+// Len returns the number of bytes written
+func (s *spool) Len() int64 {
+	return s.size
+}
+
+// Reader returns the spooled content. Closing it frees the memory reservation
+// or removes the temporary file.
+func (s *spool) Reader() (io.ReadCloser, error) {
+	if s.file == nil {
+		return &spoolReader{Reader: bytes.NewReader(s.buf.Bytes()), s: s}, nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+	return &spoolReader{Reader: s.file, s: s}, nil
+}
+
+// Close releases the spool's memory reservation and temporary file
+func (s *spool) Close() error {
+	s.budget.Release(s.reserved)
+	s.reserved = 0
+	s.buf = bytes.Buffer{}
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+	return err
+}
+
+// spoolReader reads a spool and closes it when done
+type spoolReader struct {
+	io.Reader
+	s *spool
+}
+
+// Close implements io.Closer
+func (r *spoolReader) Close() error {
+	return r.s.Close()
+}
@@ -0,0 +1,194 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"defornicate-epstein-files/internal/budget"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/scratch"
+)
+
+// IP family preferences for Options.IPPreference
+const (
+	IPPreferenceAny  = ""     // system default (dual-stack)
+	IPPreferenceIPv4 = "ipv4" // try IPv4 addresses first, then IPv6
+	IPPreferenceIPv6 = "ipv6" // try IPv6 addresses first, then IPv4
+)
+
+// Options configures how the downloader connects to servers
+type Options struct {
+	// DNSServer is a custom DNS server ("host" or "host:port") used instead of
+	// the system resolver
+	DNSServer string
+	// IPPreference selects which address family is tried first
+	IPPreference string
+	// Permissions for downloaded files and the directories created for them
+	Permissions pathutil.Permissions
+	// SFTPKeyFile is the private key used to authenticate sftp:// downloads
+	SFTPKeyFile string
+	// SFTPKnownHosts is the known_hosts file used to verify SFTP servers
+	// (default: ~/.ssh/known_hosts)
+	SFTPKnownHosts string
+	// UserAgent replaces DefaultUserAgent for HTTP requests
+	UserAgent string
+	// Contact (a URL or email address) is appended to the User-Agent as
+	// "(+contact)" so archive operators can reach whoever runs the crawl
+	Contact string
+	// From, if set, is sent as the From header (an email address)
+	From string
+	// RetryForbidden retries a request refused with 403 Forbidden once with
+	// a browser User-Agent, reporting it in Attempt.FallbackUserAgent
+	RetryForbidden bool
+	// OnAttempt, if set, is called after every fetch, successful or not
+	OnAttempt func(Attempt)
+	// Budget bounds how much downloaded data is held in memory; downloads
+	// that do not fit are spooled to a temporary file (default: unlimited)
+	Budget *budget.Budget
+	// Scratch is where spooled downloads are written (default: the system
+	// temporary directory)
+	Scratch *scratch.Dir
+	// ChecksumManifests are URLs or local paths of checksum manifests
+	// (SHA256SUMS files) every download is verified against; see Store
+	ChecksumManifests []string
+	// Skip lists the URLs and checksums never downloaded; see Skipped
+	Skip *SkipList
+	// Breaker, if set, suspends requests to a host that keeps failing; see
+	// Suspended
+	Breaker *Breaker
+	// Retry decides how fetches that fail transiently are tried again
+	// (default: never)
+	Retry RetryPolicy
+	// OnRetry, if set, is called before waiting to retry url after the
+	// given failed attempt (1 for the first)
+	OnRetry func(url string, attempt int, wait time.Duration, err error)
+	// RequestInterval spaces the starts of requests at least this far
+	// apart, however many downloads are in flight (default: no spacing)
+	RequestInterval time.Duration
+	// KnownChecksum, if set, returns the hex SHA-256 a URL was last
+	// downloaded with ("" if unknown), so content on the skip list is refused
+	// before it is fetched again
+	KnownChecksum func(url string) string
+	// StoredURLs, if set, returns the URLs a stored document was downloaded
+	// from (nil if unknown), so a different document that happens to have
+	// the same file name is stored beside it rather than replacing it
+	StoredURLs func(path string) []string
+}
+
+// dialFunc opens a network connection; see net.Dialer.DialContext
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// newDialer builds the function every connection of the downloader (HTTP,
+// FTP and SFTP alike) is dialed with for the given options
+func newDialer(opts Options) (dialFunc, error) {
+	dialer := &net.Dialer{
+		Timeout:   DefaultTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if opts.DNSServer != "" {
+		server := opts.DNSServer
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	switch opts.IPPreference {
+	case IPPreferenceAny:
+		return dialer.DialContext, nil
+	case IPPreferenceIPv4, IPPreferenceIPv6:
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialPreferring(ctx, dialer.DialContext, opts.IPPreference, network, address)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid IP preference %q (expected %q, %q or empty)", opts.IPPreference, IPPreferenceIPv4, IPPreferenceIPv6)
+	}
+}
+
+// newTransport builds the HTTP transport dialing its connections with dial
+func newTransport(dial dialFunc) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dial
+	return transport
+}
+
+// fallbackDelay is how long a dial waits for the preferred address family
+// before also trying the other, as net.Dialer.FallbackDelay does by default
+var fallbackDelay = 300 * time.Millisecond
+
+// dialPreferring dials address over the preferred address family, racing the
+// other family against it once the preferred one has failed or has not
+// connected within fallbackDelay (Happy Eyeballs, RFC 8305). Within a family,
+// dial shares its timeout between the addresses as net.Dialer does. A network
+// that names a family ("tcp4", "tcp6") is dialed as it is.
+func dialPreferring(ctx context.Context, dial dialFunc, preference, network, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return dial(ctx, network, address)
+	}
+	primary, fallback := "tcp4", "tcp6"
+	if preference == IPPreferenceIPv6 {
+		primary, fallback = fallback, primary
+	}
+
+	// The losing dial is canceled when the winner returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result, 2)
+	start := func(network string, primary bool) {
+		go func() {
+			conn, err := dial(ctx, network, address)
+			results <- result{conn, err, primary}
+		}()
+	}
+	start(primary, true)
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+
+	var primaryErr, fallbackErr error
+	pending, fellBack := 1, false
+	for pending > 0 {
+		select {
+		case <-timer.C:
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					// The other dial may still connect before it is canceled
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if r.primary {
+				primaryErr = r.err
+			} else {
+				fallbackErr = r.err
+			}
+		}
+		if !fellBack {
+			start(fallback, false)
+			pending++
+			fellBack = true
+		}
+	}
+	return nil, errors.Join(primaryErr, fallbackErr)
+}
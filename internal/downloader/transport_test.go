@@ -0,0 +1,163 @@
+package downloader
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dnsServer answers every A question with ipv4 and every AAAA question with
+// ipv6 (no answer when nil) on a local UDP port, and returns its address
+func dnsServer(t *testing.T, ipv4, ipv6 net.IP) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply := dnsReply(buf[:n], ipv4, ipv6); reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// dnsReply builds the response to a DNS query holding a single question
+func dnsReply(query []byte, ipv4, ipv6 net.IP) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	// The question's name runs up to a zero-length label, followed by its
+	// type and class
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	if end+5 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end+1:])
+	var rdata []byte
+	switch {
+	case qtype == 1 && ipv4 != nil:
+		rdata = ipv4.To4()
+	case qtype == 28 && ipv6 != nil:
+		rdata = ipv6.To16()
+	}
+	answers := uint16(0)
+	if rdata != nil {
+		answers = 1
+	}
+
+	reply := append([]byte{}, query[:2]...)         // ID
+	reply = append(reply, 0x81, 0x80)               // response, recursion available, no error
+	reply = binary.BigEndian.AppendUint16(reply, 1) // questions
+	reply = binary.BigEndian.AppendUint16(reply, answers)
+	reply = append(reply, 0, 0, 0, 0)         // no authority or additional records
+	reply = append(reply, query[12:end+5]...) // the question
+	if rdata != nil {
+		reply = append(reply, 0xc0, 12) // the question's name
+		reply = binary.BigEndian.AppendUint16(reply, qtype)
+		reply = binary.BigEndian.AppendUint16(reply, 1) // class IN
+		reply = binary.BigEndian.AppendUint32(reply, 60)
+		reply = binary.BigEndian.AppendUint16(reply, uint16(len(rdata)))
+		reply = append(reply, rdata...)
+	}
+	return reply
+}
+
+func TestDialPreferring(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	// The name resolves to both families, but only IPv4 is listened on
+	dns := dnsServer(t, net.IPv4(127, 0, 0, 1), net.IPv6loopback)
+	address := net.JoinHostPort("docs.test", strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+
+	for _, preference := range []string{IPPreferenceIPv4, IPPreferenceIPv6} {
+		dial, err := newDialer(Options{DNSServer: dns, IPPreference: preference})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		conn, err := dial(ctx, "tcp", address)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s first: dial error = %v", preference, err)
+		}
+		if ip := conn.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+			t.Errorf("%s first: connected to %s, want 127.0.0.1", preference, ip)
+		}
+		conn.Close()
+
+		// A network naming a family keeps to it
+		if conn, err := dial(context.Background(), "tcp6", address); err == nil {
+			conn.Close()
+			t.Errorf("%s first: dial over tcp6 connected to the IPv4 listener", preference)
+		}
+	}
+}
+
+func TestDialPreferringFallsBackWithoutWaitingForTimeout(t *testing.T) {
+	canceled := make(chan struct{})
+	// IPv6 connections never complete, as when a route blackholes them
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network == "tcp6" {
+			<-ctx.Done()
+			close(canceled)
+			return nil, ctx.Err()
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	start := time.Now()
+	conn, err := dialPreferring(context.Background(), dial, IPPreferenceIPv6, "tcp", "docs.test:443")
+	if err != nil {
+		t.Fatalf("dialPreferring() error = %v", err)
+	}
+	conn.Close()
+	if elapsed := time.Since(start); elapsed < fallbackDelay || elapsed > DefaultTimeout/10 {
+		t.Errorf("connected over IPv4 after %v, want just after the %v fallback delay", elapsed, fallbackDelay)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the IPv6 dial was not canceled once IPv4 connected")
+	}
+
+	// Both families failing reports both errors
+	refused := errors.New("connection refused")
+	failing := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: refused}
+	}
+	_, err = dialPreferring(context.Background(), failing, IPPreferenceIPv4, "tcp", "docs.test:443")
+	if !errors.Is(err, refused) || !strings.Contains(err.Error(), "tcp4") || !strings.Contains(err.Error(), "tcp6") {
+		t.Errorf("dialPreferring() error = %v, want the errors of both families", err)
+	}
+}
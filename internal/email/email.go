@@ -0,0 +1,383 @@
+// Package email reads email messages in the Internet message format (RFC
+// 5322): .eml files, and the messages readpst writes out of Outlook PST
+// archives. Besides the sender, recipients and text of a message, it reads
+// the threading metadata that ties a reply to the messages it answers, and
+// its attachments.
+package email
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxPartDepth bounds how deeply nested multipart bodies are read
+const maxPartDepth = 16
+
+// Message is an email message. Its JSON form holds the headers and the names
+// of the attachments, not the body or their content.
+type Message struct {
+	Subject   string   `json:"subject,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        []string `json:"to,omitempty"`
+	Cc        []string `json:"cc,omitempty"`
+	Date      string   `json:"date,omitempty"` // RFC 3339, as the sender's clock had it
+	MessageID string   `json:"message_id,omitempty"`
+	InReplyTo string   `json:"in_reply_to,omitempty"`
+	// References are the Message-IDs of the earlier messages of the thread,
+	// oldest first
+	References []string `json:"references,omitempty"`
+	// Thread is the Message-ID of the message that started the thread, the
+	// message's own for one that starts a thread
+	Thread string `json:"thread,omitempty"`
+	// ThreadTopic is Outlook's Thread-Topic, the subject without Re: and
+	// Fw: prefixes
+	ThreadTopic string `json:"thread_topic,omitempty"`
+	// Conversation identifies the Outlook conversation from the
+	// Thread-Index, in hex; replies share it even when their Message-IDs
+	// were not kept
+	Conversation string   `json:"conversation,omitempty"`
+	Attachments  []string `json:"attachments,omitempty"` // file names, in order
+
+	Body  string       `json:"-"` // text of the message, HTML converted to plain text
+	Files []Attachment `json:"-"` // the attachments themselves
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// IsMessage reports whether path names an email message file (.eml)
+func IsMessage(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".eml"
+}
+
+// Read reads the email message at path
+func Read(path string) (*Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message: %w", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads an email message
+func Parse(r io.Reader) (*Message, error) {
+	// readpst and some mail stores write bare line feeds; net/mail takes
+	// either
+	raw, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	h := raw.Header
+	m := &Message{
+		Subject:     decodeHeader(h.Get("Subject")),
+		From:        decodeHeader(h.Get("From")),
+		To:          addressList(h.Get("To")),
+		Cc:          addressList(h.Get("Cc")),
+		MessageID:   firstID(h.Get("Message-ID")),
+		InReplyTo:   firstID(h.Get("In-Reply-To")),
+		References:  messageIDs(h.Get("References")),
+		ThreadTopic: decodeHeader(h.Get("Thread-Topic")),
+	}
+	if date, err := h.Date(); err == nil {
+		m.Date = date.Format(time.RFC3339)
+	}
+	if index, err := base64.StdEncoding.DecodeString(strings.TrimSpace(h.Get("Thread-Index"))); err == nil && len(index) >= 22 {
+		// The first 22 bytes are the conversation's header; each reply
+		// appends 5 of its own
+		m.Conversation = hex.EncodeToString(index[:22])
+	}
+	switch {
+	case len(m.References) > 0:
+		m.Thread = m.References[0]
+	case m.InReplyTo != "":
+		m.Thread = m.InReplyTo
+	default:
+		m.Thread = m.MessageID
+	}
+
+	var body []string
+	if err := m.readPart(h, raw.Body, 0, &body); err != nil {
+		return nil, err
+	}
+	m.Body = strings.TrimSpace(strings.Join(body, "\n\n"))
+	for _, f := range m.Files {
+		m.Attachments = append(m.Attachments, f.Name)
+	}
+	return m, nil
+}
+
+// Text returns the message as plain text: its headers, then its body
+func (m *Message) Text() string {
+	var b strings.Builder
+	header := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", name, value)
+		}
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Cc", strings.Join(m.Cc, ", "))
+	header("Date", m.Date)
+	header("Subject", m.Subject)
+	header("Attachments", strings.Join(m.Attachments, ", "))
+	if m.Body != "" {
+		b.WriteString("\n")
+		b.WriteString(m.Body)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// partHeader is a message's or a MIME part's header
+type partHeader interface {
+	Get(key string) string
+}
+
+// readPart reads a MIME part with header h, appending its text to body and
+// its attachments to m.Files. Multipart bodies are read part by part: of the
+// alternatives of a multipart/alternative, the plain text is taken if there
+// is one.
+func (m *Message) readPart(h partHeader, r io.Reader, depth int, body *[]string) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := decodeHeader(dparams["filename"])
+	if name == "" {
+		name = decodeHeader(params["name"])
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && depth < maxPartDepth {
+		mr := multipart.NewReader(r, params["boundary"])
+		var alternatives [][]string
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read message part: %w", err)
+			}
+			var text []string
+			err = m.readPart(part.Header, part, depth+1, &text)
+			part.Close()
+			if err != nil {
+				return err
+			}
+			if mediaType == "multipart/alternative" {
+				alternatives = append(alternatives, text)
+				if isPlainText(part.Header.Get("Content-Type")) && len(text) > 0 {
+					alternatives = [][]string{text}
+					break
+				}
+				continue
+			}
+			*body = append(*body, text...)
+		}
+		for _, text := range alternatives {
+			if len(text) > 0 {
+				*body = append(*body, text...)
+				break
+			}
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return fmt.Errorf("failed to read message part: %w", err)
+	}
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	if disposition == "attachment" || name != "" || !isText {
+		if name == "" {
+			name = fmt.Sprintf("attachment-%d%s", len(m.Files)+1, extensionFor(mediaType))
+		}
+		m.Files = append(m.Files, Attachment{Name: name, ContentType: mediaType, Data: data})
+		return nil
+	}
+	text := decodeCharset(data, params["charset"])
+	if mediaType == "text/html" {
+		text = htmlText(text)
+	}
+	if text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n")); text != "" {
+		*body = append(*body, text)
+	}
+	return nil
+}
+
+// isPlainText reports whether a Content-Type header is text/plain
+func isPlainText(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "text/plain"
+}
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding. Parts read
+// through mime/multipart already have quoted-printable undone.
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64Cleaner drops the line breaks and other characters outside the
+// base64 alphabet, which encoders wrap lines with
+type base64Cleaner struct {
+	r io.Reader
+}
+
+// Read implements io.Reader
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '+' || b == '/' || b == '=' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// extensionFor returns an extension for an unnamed attachment of a media
+// type, so it can be told apart and extracted
+func extensionFor(mediaType string) string {
+	switch mediaType {
+	case "message/rfc822":
+		return ".eml"
+	case "application/pdf":
+		return ".pdf"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// decodeCharset converts text in charset to UTF-8. Latin-1 and Windows-1252
+// are converted byte by byte; other charsets are taken as UTF-8, with
+// invalid bytes dropped.
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return strings.ToValidUTF8(string(data), "")
+}
+
+// wordDecoder decodes RFC 2047 encoded words, taking unknown charsets as
+// Latin-1 rather than failing
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(decodeCharset(data, charset)), nil
+	},
+}
+
+// decodeHeader decodes the encoded words of a header value, returning it as
+// is if they are malformed
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(decoded)
+}
+
+// addressList decodes an address header into its addresses, formatted
+// "Name <address>", or split on commas if it does not parse
+func addressList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	list, err := parser.ParseList(value)
+	var addresses []string
+	if err != nil {
+		for _, address := range strings.Split(decodeHeader(value), ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+		return addresses
+	}
+	for _, address := range list {
+		if address.Name == "" {
+			addresses = append(addresses, address.Address)
+		} else {
+			addresses = append(addresses, fmt.Sprintf("%s <%s>", address.Name, address.Address))
+		}
+	}
+	return addresses
+}
+
+// messageIDRe matches a Message-ID in a header
+var messageIDRe = regexp.MustCompile(`<[^<>\s]+>`)
+
+// messageIDs returns the Message-IDs of a header, without angle brackets
+func messageIDs(value string) []string {
+	var ids []string
+	for _, id := range messageIDRe.FindAllString(value, -1) {
+		ids = append(ids, strings.Trim(id, "<>"))
+	}
+	return ids
+}
+
+// firstID returns the first Message-ID of a header, or its trimmed value if
+// it has none in angle brackets
+func firstID(value string) string {
+	if ids := messageIDs(value); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+var (
+	// htmlHiddenRe matches elements whose content is not text
+	htmlHiddenRe = regexp.MustCompile(`(?is)<(?:style|script|head)\b.*?</(?:style|script|head)>`)
+	// htmlBreakRe matches the tags ending a line or block of text
+	htmlBreakRe = regexp.MustCompile(`(?i)</(?:p|div|h[1-6]|li|tr|blockquote)>|<br\s*/?>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+	blankRunRe  = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// htmlText converts an HTML body to plain text
+func htmlText(doc string) string {
+	doc = htmlHiddenRe.ReplaceAllString(doc, "")
+	doc = htmlBreakRe.ReplaceAllString(doc, "\n")
+	doc = html.UnescapeString(htmlTagRe.ReplaceAllString(doc, ""))
+	doc = strings.ReplaceAll(doc, "\u00a0", " ")
+	return blankRunRe.ReplaceAllString(doc, "\n\n")
+}
@@ -0,0 +1,112 @@
+package email
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reply is a reply with an HTML alternative and a base64 PDF attachment, as
+// readpst writes messages out of a PST archive
+const reply = `From: "Maxwell, G." <gm@example.com>
+To: jeffrey@example.com, =?UTF-8?Q?Ren=C3=A9e?= <renee@example.com>
+Cc: counsel@example.com
+Date: Tue, 05 Mar 2002 14:03:00 -0500
+Subject: RE: Flight schedule
+Thread-Topic: Flight schedule
+Thread-Index: AcHEdRGcAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==
+Message-ID: <3@example.com>
+In-Reply-To: <2@example.com>
+References: <1@example.com> <2@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+See the attached manifest =E2=80=94 wheels up at 9.
+
+--inner
+Content-Type: text/html; charset=utf-8
+
+<html><body><p>See the attached manifest</p></body></html>
+--inner--
+
+--outer
+Content-Type: application/pdf; name="manifest.pdf"
+Content-Disposition: attachment; filename="manifest.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+JSVFT0YK
+--outer--
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader(reply))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m.Subject != "RE: Flight schedule" || m.ThreadTopic != "Flight schedule" {
+		t.Errorf("Subject, ThreadTopic = %q, %q", m.Subject, m.ThreadTopic)
+	}
+	if want := []string{"jeffrey@example.com", "Renée <renee@example.com>"}; !reflect.DeepEqual(m.To, want) {
+		t.Errorf("To = %q, want %q", m.To, want)
+	}
+	if m.Date != "2002-03-05T14:03:00-05:00" {
+		t.Errorf("Date = %q", m.Date)
+	}
+	if m.MessageID != "3@example.com" || m.InReplyTo != "2@example.com" {
+		t.Errorf("MessageID, InReplyTo = %q, %q", m.MessageID, m.InReplyTo)
+	}
+	if want := []string{"1@example.com", "2@example.com"}; !reflect.DeepEqual(m.References, want) {
+		t.Errorf("References = %q, want %q", m.References, want)
+	}
+	if m.Thread != "1@example.com" {
+		t.Errorf("Thread = %q, want the first message of the thread", m.Thread)
+	}
+	if len(m.Conversation) != 44 {
+		t.Errorf("Conversation = %q, want the 22-byte Thread-Index header in hex", m.Conversation)
+	}
+	if m.Body != "See the attached manifest — wheels up at 9." {
+		t.Errorf("Body = %q, want the plain text alternative only", m.Body)
+	}
+	if len(m.Files) != 1 || m.Files[0].Name != "manifest.pdf" || string(m.Files[0].Data) != "%PDF-1.4\n%%EOF\n" {
+		t.Fatalf("Files = %+v, want the decoded manifest.pdf", m.Files)
+	}
+	if !reflect.DeepEqual(m.Attachments, []string{"manifest.pdf"}) {
+		t.Errorf("Attachments = %q", m.Attachments)
+	}
+	if text := m.Text(); !strings.HasPrefix(text, `From: "Maxwell, G." <gm@example.com>`) || !strings.Contains(text, "Attachments: manifest.pdf\n\nSee the attached") {
+		t.Errorf("Text() = %q", text)
+	}
+}
+
+func TestParseThreadStart(t *testing.T) {
+	msg := "From: a@example.com\r\nSubject: Hello\r\nMessage-ID: <1@example.com>\r\nContent-Type: text/html; charset=iso-8859-1\r\n\r\n<style>p{}</style><p>Caf\xe9</p><p>Bye</p>\r\n"
+	m, err := Parse(strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m.Thread != "1@example.com" {
+		t.Errorf("Thread = %q, want its own Message-ID", m.Thread)
+	}
+	if m.Body != "Café\nBye" {
+		t.Errorf("Body = %q, want the HTML as Latin-1 plain text", m.Body)
+	}
+	if len(m.Files) != 0 {
+		t.Errorf("Files = %+v, want none", m.Files)
+	}
+}
+
+func TestIsMessage(t *testing.T) {
+	for path, want := range map[string]bool{"a.eml": true, "A.EML": true, "a.msg": false, "a.pst": false} {
+		if got := IsMessage(path); got != want {
+			t.Errorf("IsMessage(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader names the columns written by WriteCSV
+var csvHeader = []string{"entity", "type", "canonical_name", "document", "page", "snippet"}
+
+// WriteCSV writes mentions as a flat CSV table, one row per mention, for
+// loading into a spreadsheet or pivot table
+func WriteCSV(w io.Writer, mentions []Mention) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		row := []string{m.Entity, m.Type, m.Canonical, m.Document, strconv.Itoa(m.Page), m.Snippet}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// quotesCSVHeader names the columns written by WriteQuotesCSV
+var quotesCSVHeader = []string{"speaker", "canonical_name", "verb", "kind", "quote", "sentiment", "document", "page"}
+
+// WriteQuotesCSV writes quotes as a flat CSV table, one row per quote
+func WriteQuotesCSV(w io.Writer, quotes []Quote) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(quotesCSVHeader); err != nil {
+		return err
+	}
+	for _, q := range quotes {
+		sentiment := strconv.FormatFloat(q.Sentiment, 'f', -1, 64)
+		row := []string{q.Speaker, q.Canonical, q.Verb, q.Kind, q.Text, sentiment, q.Document, strconv.Itoa(q.Page)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,128 @@
+// Package entities finds mentions of people, contact details and case
+// identifiers in extracted text, each normalized to a canonical name so
+// mentions can be grouped across documents.
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"defornicate-epstein-files/internal/legal"
+)
+
+// Entity types
+const (
+	TypePerson     = "person"
+	TypeEmail      = "email"
+	TypePhone      = "phone"
+	TypeCaseNumber = "case_number"
+	TypeBates      = "bates"
+)
+
+// Mention is one occurrence of an entity in a document
+type Mention struct {
+	Entity    string // as written in the text
+	Type      string
+	Canonical string // normalized name used to group mentions
+	Document  string
+	Page      int
+	Snippet   string // surrounding text
+}
+
+// snippetRadius is how many bytes of context are kept on each side of a mention
+const snippetRadius = 40
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// North American numbers: (212) 555-0100, 212-555-0100, +1 212.555.0100
+	phoneRe = regexp.MustCompile(`(?:\+?1[\s.-]?)?\(?\b(\d{3})\)?[\s.-]?(\d{3})[\s.-](\d{4})\b`)
+	// A title followed by one to three capitalized names, e.g. "Mr. Jeffrey Epstein"
+	personRe = regexp.MustCompile(`\b(?i:Mr|Mrs|Ms|Miss|Dr|Prof|Judge|Hon|Sen|Rep|Gov|Det|Agent|Officer)\.?\s+((?:[A-Z][a-zA-Z'-]+\.?\s+){0,2}[A-Z][a-zA-Z'-]+)`)
+	// Bates stamps as recognized by legal.FindBates
+	batesRe = regexp.MustCompile(`\b([A-Z]{2,}(?:[-_][A-Z]{2,})*)[-_ ]?(\d{6,10})\b`)
+)
+
+// Find returns the entity mentions in the text of one page, in the order they
+// appear. Document is left empty for the caller to fill in.
+func Find(text string, page int) []Mention {
+	type located struct {
+		start int
+		m     Mention
+	}
+	var found []located
+	add := func(start, end int, typ, canonical string) {
+		found = append(found, located{start, Mention{
+			Entity:    text[start:end],
+			Type:      typ,
+			Canonical: canonical,
+			Page:      page,
+			Snippet:   snippet(text, start, end),
+		}})
+	}
+
+	for _, loc := range personRe.FindAllStringSubmatchIndex(text, -1) {
+		add(loc[0], loc[1], TypePerson, canonicalPerson(text[loc[2]:loc[3]]))
+	}
+	for _, loc := range emailRe.FindAllStringIndex(text, -1) {
+		add(loc[0], loc[1], TypeEmail, strings.ToLower(text[loc[0]:loc[1]]))
+	}
+	for _, loc := range phoneRe.FindAllStringSubmatchIndex(text, -1) {
+		digits := text[loc[2]:loc[3]] + text[loc[4]:loc[5]] + text[loc[6]:loc[7]]
+		add(loc[0], loc[1], TypePhone, "+1"+digits)
+	}
+	searchFrom := 0
+	for _, m := range legal.FindCaseNumbers(text) {
+		if idx := strings.Index(text[searchFrom:], m.Text); idx != -1 {
+			start := searchFrom + idx
+			add(start, start+len(m.Text), TypeCaseNumber, m.Canonical)
+			searchFrom = start + len(m.Text)
+		}
+	}
+	for _, loc := range batesRe.FindAllStringSubmatchIndex(text, -1) {
+		// Canonical form drops the separator: "EFTA 00010724" -> "EFTA00010724"
+		add(loc[0], loc[1], TypeBates, text[loc[2]:loc[3]]+text[loc[4]:loc[5]])
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].start < found[j].start })
+	mentions := make([]Mention, len(found))
+	for i, f := range found {
+		mentions[i] = f.m
+	}
+	return mentions
+}
+
+// canonicalPerson normalizes a name to single-spaced title case
+func canonicalPerson(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		word = strings.TrimSuffix(word, ".")
+		if len(word) > 1 && strings.ToUpper(word) == word {
+			// Names in all caps (common in captions) become title case
+			word = word[:1] + strings.ToLower(word[1:])
+		}
+		words[i] = word
+	}
+	return strings.Join(words, " ")
+}
+
+// snippet returns the text around text[start:end] on a single line
+func snippet(text string, start, end int) string {
+	from := start - snippetRadius
+	if from < 0 {
+		from = 0
+	}
+	to := end + snippetRadius
+	if to > len(text) {
+		to = len(text)
+	}
+	// Don't cut multi-byte characters in half
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return strings.Join(strings.Fields(text[from:to]), " ")
+}
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	text := "Call MR. JEFFREY EPSTEIN at (212) 555-0100 or jeevacation@Gmail.com re Case 1:19-cv-3377-LAP.\nEFTA00010724"
+	got := Find(text, 3)
+
+	want := []struct{ typ, entity, canonical string }{
+		{TypePerson, "MR. JEFFREY EPSTEIN", "Jeffrey Epstein"},
+		{TypePhone, "(212) 555-0100", "+12125550100"},
+		{TypeEmail, "jeevacation@Gmail.com", "jeevacation@gmail.com"},
+		{TypeCaseNumber, "1:19-cv-3377-LAP", "1:19-cv-03377"},
+		{TypeBates, "EFTA00010724", "EFTA00010724"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Find() returned %d mentions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Type != w.typ || got[i].Entity != w.entity || got[i].Canonical != w.canonical {
+			t.Errorf("mention %d = %s %q (%q), want %s %q (%q)", i, got[i].Type, got[i].Entity, got[i].Canonical, w.typ, w.entity, w.canonical)
+		}
+		if got[i].Page != 3 {
+			t.Errorf("mention %d page = %d, want 3", i, got[i].Page)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, []Mention{{
+		Entity: "Dr. Smith", Type: TypePerson, Canonical: "Smith",
+		Document: "pdf/a/a.pdf", Page: 2, Snippet: "saw Dr. Smith, twice",
+	}})
+	if err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	want := "entity,type,canonical_name,document,page,snippet\nDr. Smith,person,Smith,pdf/a/a.pdf,2,\"saw Dr. Smith, twice\"\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
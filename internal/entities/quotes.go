@@ -0,0 +1,218 @@
+package entities
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"defornicate-epstein-files/internal/segment"
+)
+
+// Quote kinds
+const (
+	QuoteDirect   = "direct"   // words in quotation marks: "...," Maxwell said
+	QuoteReported = "reported" // reported speech: Maxwell testified that ...
+)
+
+// Quote is a statement attributed to a named speaker
+type Quote struct {
+	Speaker   string // as written
+	Canonical string // normalized name used to group quotes, as for mentions
+	Verb      string // the verb attributing it: "said", "testified", ...
+	Kind      string
+	Text      string // the words attributed
+	// Sentiment is the tone of the quote from -1 (negative) to 1
+	// (positive), 0 when it has no words of either
+	Sentiment float64
+	Document  string
+	Page      int
+}
+
+// minQuoteWords is the fewest words a quotation must have to be kept;
+// shorter ones are scare quotes and titles
+const minQuoteWords = 3
+
+const (
+	// speakerPattern matches a name, with an optional title and middle
+	// initial: "Maxwell", "Ms. Ghislaine Maxwell", "Jeffrey E. Epstein"
+	speakerPattern = `((?:(?:Mr|Mrs|Ms|Miss|Dr|Prof|Judge|Hon|Sen|Rep|Gov|Det|Agent|Officer)\.?\s+)?[A-Z][a-zA-Z'-]+(?:\s+[A-Z]\.)?(?:\s+[A-Z][a-zA-Z'-]+){0,2})`
+	// verbPattern matches the verbs attributing speech
+	verbPattern = `(said|says|stated|states|testified|testifies|told|wrote|writes|added|explained|claimed|claims|alleged|alleges|admitted|denied|recalled|insisted|maintained|acknowledged|asserted|swore|declared|conceded|replied|answered|asked)`
+)
+
+var (
+	// quotedRe matches text in straight or curly double quotes
+	quotedRe = regexp.MustCompile(`["“]([^"“”]+)["”]`)
+	// afterQuoteRe matches the attribution following a quotation: `," said
+	// Maxwell` or `," Maxwell said`
+	afterQuoteRe = regexp.MustCompile(`^[,.!?]?\s*(?:` + verbPattern + `\s+` + speakerPattern + `|` + speakerPattern + `\s+` + verbPattern + `)\b`)
+	// beforeQuoteRe matches the attribution leading into a quotation:
+	// `Maxwell said, ` or `Epstein told the detective: `
+	beforeQuoteRe = regexp.MustCompile(speakerPattern + `\s+` + verbPattern + `(?:\s+[a-z]+){0,3}\s*[,:]\s*$`)
+	// reportedRe matches reported speech: `Maxwell testified that ...`, `Epstein
+	// later told police that ...`
+	reportedRe = regexp.MustCompile(speakerPattern + `\s+(?:(?:also|later|previously|repeatedly|further|then|never)\s+)?` + verbPattern + `(?:\s+(?:under oath|in (?:a|an|the|her|his|their) [a-z]+)|\s+[A-Za-z]+(?:\s+[A-Za-z]+){0,2})?,?\s+that\s+(.+)$`)
+)
+
+// notNames are capitalized words that start sentences or name roles rather
+// than people; they are dropped from the front of a speaker, and a speaker
+// left with nothing is not one
+var notNames = map[string]bool{
+	"a": true, "an": true, "the": true, "he": true, "she": true, "it": true, "they": true,
+	"we": true, "i": true, "you": true, "his": true, "her": true, "their": true, "this": true,
+	"that": true, "these": true, "those": true, "but": true, "and": true, "or": true, "so": true,
+	"then": true, "later": true, "when": true, "while": true, "also": true, "in": true, "on": true,
+	"at": true, "after": true, "before": true, "today": true, "yesterday": true, "yes": true, "no": true,
+	"q": true, "plaintiff": true, "plaintiffs": true, "defendant": true, "defendants": true,
+	"witness": true, "court": true, "counsel": true, "government": true, "someone": true, "nobody": true,
+	"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true,
+	"saturday": true, "sunday": true,
+}
+
+// FindQuotes returns the quotes in the text of one page attributed to a
+// named speaker, in the order they appear: quotations with the speaker and a
+// verb of speech right before or after them, and reported speech ("Maxwell
+// testified that ..."). Quotes are looked for within paragraphs (see
+// segment.Paragraphs), so an attribution on the next page is missed.
+// Document is left empty for the caller to fill in.
+func FindQuotes(text string, page int) []Quote {
+	var quotes []Quote
+	add := func(speaker, verb, kind, words string) bool {
+		speaker = trimSpeaker(speaker)
+		words = strings.TrimSpace(words)
+		if speaker == "" || len(strings.Fields(words)) < minQuoteWords {
+			return false
+		}
+		quotes = append(quotes, Quote{
+			Speaker:   speaker,
+			Canonical: canonicalPerson(stripTitle(speaker)),
+			Verb:      verb,
+			Kind:      kind,
+			Text:      words,
+			Sentiment: Sentiment(words),
+			Page:      page,
+		})
+		return true
+	}
+
+	for _, paragraph := range segment.Paragraphs(text) {
+		p := paragraph.Text
+		// quoted holds the paragraph's attributed quotations, whose
+		// sentences are not read again as reported speech
+		var quoted []string
+		for _, loc := range quotedRe.FindAllStringSubmatchIndex(p, -1) {
+			var speaker, verb string
+			if m := afterQuoteRe.FindStringSubmatch(p[loc[1]:]); m != nil {
+				speaker, verb = m[2], m[1]
+				if verb == "" {
+					speaker, verb = m[3], m[4]
+				}
+			} else if m := beforeQuoteRe.FindStringSubmatch(p[:loc[0]]); m != nil {
+				speaker, verb = m[1], m[2]
+			}
+			if verb != "" && add(speaker, verb, QuoteDirect, strings.TrimRight(p[loc[2]:loc[3]], ",")) {
+				quoted = append(quoted, p[loc[2]:loc[3]])
+			}
+		}
+		for _, sentence := range paragraph.Sentences {
+			if inQuotation(sentence.Text, quoted) {
+				continue
+			}
+			if m := reportedRe.FindStringSubmatch(sentence.Text); m != nil {
+				add(m[1], m[2], QuoteReported, strings.TrimRight(m[3], ".!?"))
+			}
+		}
+	}
+	return quotes
+}
+
+// inQuotation reports whether a sentence holds one of quoted or is part of
+// one
+func inQuotation(sentence string, quoted []string) bool {
+	core := strings.Trim(sentence, `"“”,.!? `)
+	for _, q := range quoted {
+		if strings.Contains(sentence, q) || (core != "" && strings.Contains(q, core)) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimSpeaker drops the sentence starters and role words notNames lists from
+// the front of a matched speaker, returning "" if nothing is left
+func trimSpeaker(speaker string) string {
+	words := strings.Fields(speaker)
+	for len(words) > 0 && notNames[strings.ToLower(strings.TrimSuffix(words[0], "."))] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// titleRe matches the title in front of a name
+var titleRe = regexp.MustCompile(`^(?i:Mr|Mrs|Ms|Miss|Dr|Prof|Judge|Hon|Sen|Rep|Gov|Det|Agent|Officer)\.?\s+`)
+
+// stripTitle removes the title in front of a name, so "Ms. Maxwell" and
+// "Maxwell" group together
+func stripTitle(name string) string {
+	if stripped := titleRe.ReplaceAllString(name, ""); stripped != "" {
+		return stripped
+	}
+	return name
+}
+
+// sentimentWords scores the words that carry a tone in testimony and
+// correspondence: 1 for positive, -1 for negative
+var sentimentWords = map[string]int{
+	"good": 1, "great": 1, "happy": 1, "glad": 1, "love": 1, "loved": 1, "kind": 1, "friend": 1,
+	"friendly": 1, "thank": 1, "thanks": 1, "grateful": 1, "wonderful": 1, "nice": 1, "helped": 1,
+	"help": 1, "safe": 1, "trust": 1, "trusted": 1, "honest": 1, "innocent": 1, "proud": 1,
+	"generous": 1, "fine": 1, "pleased": 1, "enjoyed": 1, "respect": 1, "fair": 1, "true": 1,
+	"bad": -1, "abuse": -1, "abused": -1, "abusive": -1, "assault": -1, "assaulted": -1, "rape": -1,
+	"raped": -1, "hurt": -1, "harm": -1, "harmed": -1, "afraid": -1, "scared": -1, "fear": -1,
+	"threatened": -1, "threat": -1, "forced": -1, "coerced": -1, "lie": -1, "lied": -1, "lying": -1,
+	"liar": -1, "false": -1, "wrong": -1, "terrible": -1, "horrible": -1, "awful": -1, "angry": -1,
+	"upset": -1, "cried": -1, "crying": -1, "victim": -1, "victims": -1, "guilty": -1, "illegal": -1,
+	"crime": -1, "criminal": -1, "exploited": -1, "trafficked": -1, "trafficking": -1, "hate": -1,
+	"ashamed": -1, "embarrassed": -1, "pain": -1, "painful": -1, "worried": -1, "sad": -1,
+}
+
+// negations flip the tone of the words right after them
+var negations = map[string]bool{
+	"not": true, "never": true, "no": true, "didn't": true, "don't": true, "wasn't": true,
+	"isn't": true, "weren't": true, "won't": true, "nothing": true, "without": true,
+}
+
+// negationReach is how many words after a negation it applies to
+const negationReach = 3
+
+// Sentiment scores the tone of text from -1 (only negative words) to 1
+// (only positive words) with a small lexicon, counting a word after "not",
+// "never" and the like as its opposite; text with no words of either scores
+// 0. It is a rough guide for sorting statements, not a reading of them.
+func Sentiment(text string) float64 {
+	var positive, negative, negated int
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, `.,;:!?"'()“”‘’`)
+		word = strings.ReplaceAll(word, "’", "'")
+		if negations[word] {
+			negated = negationReach
+			continue
+		}
+		score := sentimentWords[word]
+		if negated > 0 {
+			score = -score
+			negated--
+		}
+		switch {
+		case score > 0:
+			positive++
+		case score < 0:
+			negative++
+		}
+	}
+	if positive+negative == 0 {
+		return 0
+	}
+	score := float64(positive-negative) / float64(positive+negative)
+	return math.Round(score*100) / 100
+}
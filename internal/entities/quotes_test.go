@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFindQuotes(t *testing.T) {
+	text := `"I never met any of those girls," Ms. Maxwell said.
+
+Later Epstein told the detective: "They were all of age, I was told."
+
+On Tuesday Juan Alessi testified that he cleaned the massage room after every visit.
+
+The witness stated that she was afraid. He called it "the island" often.
+
+"Thank you for your help with the flights," wrote Jeffrey E. Epstein.`
+	got := FindQuotes(text, 4)
+
+	want := []struct{ speaker, canonical, verb, kind, text string }{
+		{"Ms. Maxwell", "Maxwell", "said", QuoteDirect, "I never met any of those girls"},
+		{"Epstein", "Epstein", "told", QuoteDirect, "They were all of age, I was told."},
+		{"Juan Alessi", "Juan Alessi", "testified", QuoteReported, "he cleaned the massage room after every visit"},
+		{"Jeffrey E. Epstein", "Jeffrey E Epstein", "wrote", QuoteDirect, "Thank you for your help with the flights"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("FindQuotes() returned %d quotes, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		q := got[i]
+		if q.Speaker != w.speaker || q.Canonical != w.canonical || q.Verb != w.verb || q.Kind != w.kind || q.Text != w.text {
+			t.Errorf("quote %d = %q (%q) %s %s %q, want %q (%q) %s %s %q", i, q.Speaker, q.Canonical, q.Verb, q.Kind, q.Text, w.speaker, w.canonical, w.verb, w.kind, w.text)
+		}
+		if q.Page != 4 {
+			t.Errorf("quote %d page = %d, want 4", i, q.Page)
+		}
+	}
+	if got[3].Sentiment != 1 {
+		t.Errorf("thanks sentiment = %v, want 1", got[3].Sentiment)
+	}
+}
+
+func TestSentiment(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"He was kind and generous.", 1},
+		{"She was afraid and he lied.", -1},
+		{"I was not afraid of him.", 1},
+		{"It was a good trip but a terrible week.", 0},
+		{"The flight left at nine.", 0},
+	}
+	for _, tt := range tests {
+		if got := Sentiment(tt.text); got != tt.want {
+			t.Errorf("Sentiment(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWriteQuotesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteQuotesCSV(&buf, []Quote{{
+		Speaker: "Ms. Maxwell", Canonical: "Maxwell", Verb: "said", Kind: QuoteDirect,
+		Text: "I never met them, ever", Sentiment: -0.5, Document: "pdf/a/a.pdf", Page: 2,
+	}})
+	if err != nil {
+		t.Fatalf("WriteQuotesCSV() error = %v", err)
+	}
+	want := "speaker,canonical_name,verb,kind,quote,sentiment,document,page\n" +
+		"Ms. Maxwell,Maxwell,said,direct,\"I never met them, ever\",-0.5,pdf/a/a.pdf,2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteQuotesCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+// Package evidence builds self-contained evidence packages for single
+// documents: a zip holding the original file, its extraction outputs, a
+// checksum report, the provenance recorded in the catalog and a
+// human-readable summary, suitable for attaching to articles or filings.
+package evidence
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// Checksum states reported in the provenance record
+const (
+	ChecksumMatches      = "matches catalog"
+	ChecksumDiffers      = "differs from catalog"
+	ChecksumUncatalogued = "not catalogued"
+)
+
+// Provenance is what is known about where a document came from. It is
+// written to the package as provenance.json.
+type Provenance struct {
+	Document   string                       `json:"document"` // path relative to the documents tree
+	SHA256     string                       `json:"sha256"`   // checksum of the packaged file
+	Checksum   string                       `json:"checksum"` // comparison with the catalog record
+	Catalog    *catalog.Document            `json:"catalog,omitempty"`
+	Attempts   map[string][]catalog.Attempt `json:"attempts,omitempty"` // fetch history by source URL
+	ExportedAt time.Time                    `json:"exported_at"`
+}
+
+// File is one file written to a package
+type File struct {
+	Name   string // path inside the zip
+	SHA256 string
+	Size   int64
+}
+
+// Export writes the evidence package for the document at docPath as a zip
+// to w. entry and attempts come from the catalog and may be empty for
+// documents that were not downloaded by this tool. It returns the files
+// written, in order.
+func Export(w io.Writer, docPath, rel string, entry *catalog.Document, attempts map[string][]catalog.Attempt) ([]File, error) {
+	sum, err := corpus.FileChecksum(docPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", docPath, err)
+	}
+	prov := Provenance{
+		Document:   filepath.ToSlash(rel),
+		SHA256:     fmt.Sprintf("%x", sum),
+		Checksum:   ChecksumUncatalogued,
+		Catalog:    entry,
+		Attempts:   attempts,
+		ExportedAt: time.Now().UTC(),
+	}
+	if entry != nil && entry.SHA256 != "" {
+		prov.Checksum = ChecksumDiffers
+		if entry.SHA256 == prov.SHA256 {
+			prov.Checksum = ChecksumMatches
+		}
+	}
+	outputs, err := corpus.Outputs(docPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extraction outputs: %w", err)
+	}
+
+	// Everything sits under one directory named after the document, so
+	// unpacking several packages side by side does not mix them up
+	base := filepath.Base(docPath)
+	root := strings.TrimSuffix(base, filepath.Ext(base)) + "-evidence/"
+	p := &packager{zw: zip.NewWriter(w), root: root}
+
+	p.addFile("document/"+base, docPath)
+	for _, output := range outputs {
+		p.addFile("extracted/"+filepath.Base(output), output)
+	}
+	provJSON, err := json.MarshalIndent(prov, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	p.addBytes("provenance.json", append(provJSON, '\n'), prov.ExportedAt)
+	p.addBytes("SUMMARY.txt", summary(prov, outputs, p.files), prov.ExportedAt)
+	p.addBytes("SHA256SUMS", checksumReport(p.files), prov.ExportedAt)
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err := p.zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish zip: %w", err)
+	}
+	return p.files, nil
+}
+
+// packager adds files to the zip, remembering the first error
+type packager struct {
+	zw    *zip.Writer
+	root  string
+	files []File
+	err   error
+}
+
+// addFile copies a file on disk into the package
+func (p *packager) addFile(name, path string) {
+	if p.err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		p.err = fmt.Errorf("failed to read %s: %w", path, err)
+		return
+	}
+	modified := time.Now()
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+	p.addBytes(name, data, modified)
+}
+
+// addBytes adds content to the package
+func (p *packager) addBytes(name string, data []byte, modified time.Time) {
+	if p.err != nil {
+		return
+	}
+	fw, err := p.zw.CreateHeader(&zip.FileHeader{Name: p.root + name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		p.err = fmt.Errorf("failed to add %s: %w", name, err)
+		return
+	}
+	if _, err := fw.Write(data); err != nil {
+		p.err = fmt.Errorf("failed to write %s: %w", name, err)
+		return
+	}
+	p.files = append(p.files, File{Name: name, SHA256: fmt.Sprintf("%x", sha256.Sum256(data)), Size: int64(len(data))})
+}
+
+// checksumReport lists every packaged file in the format read by
+// "sha256sum -c"
+func checksumReport(files []File) []byte {
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "%s  %s\n", f.SHA256, f.Name)
+	}
+	return []byte(b.String())
+}
+
+// summary renders the human-readable overview of the package
+func summary(prov Provenance, outputs []string, files []File) []byte {
+	var b strings.Builder
+	line := func(label, value string) {
+		fmt.Fprintf(&b, "%-16s %s\n", label+":", value)
+	}
+
+	fmt.Fprintf(&b, "EVIDENCE PACKAGE: %s\n\n", filepath.Base(prov.Document))
+	line("Document", prov.Document)
+	line("SHA256", prov.SHA256)
+	line("Checksum", prov.Checksum)
+	if prov.Catalog != nil {
+		if prov.Catalog.Pages > 0 {
+			line("Pages", fmt.Sprintf("%d", prov.Catalog.Pages))
+		}
+		for _, url := range prov.Catalog.URLs {
+			line("Source URL", url)
+		}
+		if !prov.Catalog.DownloadedAt.IsZero() {
+			line("Downloaded", prov.Catalog.DownloadedAt.Format(time.RFC3339))
+		}
+	} else {
+		line("Source URL", "unknown (not downloaded by this tool)")
+	}
+	line("Exported", prov.ExportedAt.Format(time.RFC3339))
+
+	// Case details from the JSON extraction output, if there is one
+	for _, output := range outputs {
+		extracted, err := extractor.ReadExtracted(output)
+		if err != nil || extracted.Metadata.Part != nil {
+			continue
+		}
+		line("Pages extracted", fmt.Sprintf("%d", extracted.Metadata.PagesExtracted))
+		if c := extracted.Metadata.Case; c != nil {
+			if c.Caption != "" {
+				line("Caption", c.Caption)
+			}
+			for _, number := range c.CaseNumbers {
+				line("Case number", number)
+			}
+			for _, court := range c.Courts {
+				line("Court", court)
+			}
+		}
+		break
+	}
+
+	var urls []string
+	for url := range prov.Attempts {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		var failed int
+		for _, a := range prov.Attempts[url] {
+			if a.Failed() {
+				failed++
+			}
+		}
+		line("Fetch attempts", fmt.Sprintf("%d (%d failed) for %s", len(prov.Attempts[url]), failed, url))
+	}
+
+	b.WriteString("\nContents:\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "  %-40s %10d bytes\n", f.Name, f.Size)
+	}
+	b.WriteString("  SUMMARY.txt (this file)\n")
+	b.WriteString("  SHA256SUMS (checksums of the files above; verify with \"sha256sum -c SHA256SUMS\")\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,72 @@
+package evidence
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/catalog"
+)
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	docPath := filepath.Join(dir, "a.pdf")
+	if err := os.WriteFile(docPath, []byte("%PDF-1.4 body"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.extracted.txt"), []byte("page text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := &catalog.Document{
+		Path:   "pdf/a/a.pdf",
+		SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("%PDF-1.4 body"))),
+		URLs:   []string{"https://example.com/a.pdf"},
+	}
+
+	var buf bytes.Buffer
+	if _, err := Export(&buf, docPath, "pdf/a/a.pdf", entry, nil); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	contents := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+
+	for _, name := range []string{"document/a.pdf", "extracted/a.extracted.txt", "provenance.json", "SUMMARY.txt", "SHA256SUMS"} {
+		if _, ok := contents["a-evidence/"+name]; !ok {
+			t.Errorf("package is missing %s", name)
+		}
+	}
+
+	// Every line of the checksum report must match the packaged file
+	for _, line := range strings.Split(strings.TrimSpace(string(contents["a-evidence/SHA256SUMS"])), "\n") {
+		sum, name, _ := strings.Cut(line, "  ")
+		if got := fmt.Sprintf("%x", sha256.Sum256(contents["a-evidence/"+name])); got != sum {
+			t.Errorf("SHA256SUMS lists %s for %s, file hashes to %s", sum, name, got)
+		}
+	}
+
+	var prov Provenance
+	if err := json.Unmarshal(contents["a-evidence/provenance.json"], &prov); err != nil {
+		t.Fatalf("provenance.json: %v", err)
+	}
+	if prov.Checksum != ChecksumMatches || prov.Catalog == nil || prov.Catalog.URLs[0] != "https://example.com/a.pdf" {
+		t.Errorf("provenance = %+v", prov)
+	}
+}
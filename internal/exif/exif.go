@@ -0,0 +1,411 @@
+// Package exif reads the EXIF metadata of JPEG and TIFF images: the camera
+// that took a photo, when it was taken and modified, and where, if the
+// camera recorded a GPS position. For photo exhibits this is often the most
+// telling information they carry.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EXIF is the metadata of an image. Times are as the camera recorded them,
+// formatted 2006-01-02T15:04:05, with the UTC offset appended when the image
+// records one.
+type EXIF struct {
+	Make         string `json:"make,omitempty"`
+	Model        string `json:"model,omitempty"`
+	LensModel    string `json:"lens_model,omitempty"`
+	SerialNumber string `json:"serial_number,omitempty"` // of the camera body
+	Software     string `json:"software,omitempty"`
+	Artist       string `json:"artist,omitempty"`
+	Copyright    string `json:"copyright,omitempty"`
+	Description  string `json:"description,omitempty"`
+	Taken        string `json:"taken,omitempty"`     // DateTimeOriginal
+	Digitized    string `json:"digitized,omitempty"` // DateTimeDigitized
+	Modified     string `json:"modified,omitempty"`  // DateTime, set by editing software
+	Orientation  int    `json:"orientation,omitempty"`
+	Width        int    `json:"width,omitempty"` // in pixels
+	Height       int    `json:"height,omitempty"`
+	GPS          *GPS   `json:"gps,omitempty"`
+}
+
+// GPS is the position recorded with an image
+type GPS struct {
+	Latitude  float64  `json:"latitude"`           // degrees, negative south of the equator
+	Longitude float64  `json:"longitude"`          // degrees, negative west of Greenwich
+	Altitude  *float64 `json:"altitude,omitempty"` // meters above sea level
+	Time      string   `json:"time,omitempty"`     // UTC time of the fix
+}
+
+// Tags read from the image (IFD0), Exif and GPS directories
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagImageDescription = 0x010E
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagArtist           = 0x013B
+	tagCopyright        = 0x8298
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+
+	tagDateTimeOriginal    = 0x9003
+	tagDateTimeDigitized   = 0x9004
+	tagOffsetTime          = 0x9010
+	tagOffsetTimeOriginal  = 0x9011
+	tagOffsetTimeDigitized = 0x9012
+	tagPixelXDimension     = 0xA002
+	tagPixelYDimension     = 0xA003
+	tagBodySerialNumber    = 0xA431
+	tagLensModel           = 0xA434
+
+	tagGPSLatitudeRef  = 0x01
+	tagGPSLatitude     = 0x02
+	tagGPSLongitudeRef = 0x03
+	tagGPSLongitude    = 0x04
+	tagGPSAltitudeRef  = 0x05
+	tagGPSAltitude     = 0x06
+	tagGPSTimeStamp    = 0x07
+	tagGPSDateStamp    = 0x1D
+)
+
+// maxEntries and maxValueSize bound what a damaged or hostile directory can
+// make the reader allocate
+const (
+	maxEntries   = 1000
+	maxValueSize = 64 << 10
+)
+
+// errNotTIFF is returned for data without a TIFF header
+var errNotTIFF = errors.New("not TIFF data")
+
+// IsImage reports whether path names a JPEG or TIFF image, by its extension
+func IsImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".tif", ".tiff":
+		return true
+	}
+	return false
+}
+
+// Read reads the EXIF metadata of the JPEG or TIFF image at path. It returns
+// nil if the image carries none.
+func Read(path string) (*EXIF, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(file, magic[:]); err != nil {
+		return nil, nil // too short to be an image
+	}
+	var x *EXIF
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		if _, err := file.Seek(2, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		x, err = readJPEG(file)
+	case string(magic[:]) == "II*\x00" || string(magic[:]) == "MM\x00*":
+		info, statErr := file.Stat()
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, statErr)
+		}
+		x, err = readTIFF(io.NewSectionReader(file, 0, info.Size()))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EXIF of %s: %w", path, err)
+	}
+	if x.isEmpty() {
+		return nil, nil
+	}
+	return x, nil
+}
+
+// readJPEG walks the segments of a JPEG file positioned after its SOI
+// marker, reading the EXIF in its APP1 segment and the pixel dimensions
+// from its frame header
+func readJPEG(r io.ReadSeeker) (*EXIF, error) {
+	x := &EXIF{}
+	width, height := 0, 0
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			break // truncated: keep what was found
+		}
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG segment marker %#x", marker[0])
+		}
+		kind := marker[1]
+		if kind == 0xDA || kind == 0xD9 { // start of scan, end of image
+			break
+		}
+		length := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("invalid JPEG segment length")
+		}
+
+		isFrame := kind >= 0xC0 && kind <= 0xCF && kind != 0xC4 && kind != 0xC8 && kind != 0xCC
+		if kind != 0xE1 && !isFrame {
+			if _, err := r.Seek(int64(length), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			break
+		}
+		switch {
+		case isFrame && len(segment) >= 5:
+			height = int(binary.BigEndian.Uint16(segment[1:]))
+			width = int(binary.BigEndian.Uint16(segment[3:]))
+		case kind == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			found, err := readTIFF(bytes.NewReader(segment[6:]))
+			if err != nil {
+				return nil, err
+			}
+			x = found
+		}
+	}
+	// The frame header holds the real size; EXIF dimensions may describe
+	// the image before it was cropped or scaled
+	if width > 0 && height > 0 {
+		x.Width, x.Height = width, height
+	}
+	return x, nil
+}
+
+// tiff reads the directories of TIFF-structured data
+type tiff struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// entry is one tag of a directory
+type entry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// typeSizes is the byte size of each TIFF field type
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// readTIFF reads the EXIF of TIFF-structured data: a TIFF file, or the
+// payload of a JPEG's EXIF segment
+func readTIFF(r io.ReaderAt) (*EXIF, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, errNotTIFF
+	}
+	t := &tiff{r: r}
+	switch string(header[:4]) {
+	case "II*\x00":
+		t.order = binary.LittleEndian
+	case "MM\x00*":
+		t.order = binary.BigEndian
+	default:
+		return nil, errNotTIFF
+	}
+
+	ifd0, err := t.directory(t.order.Uint32(header[4:]))
+	if err != nil {
+		return nil, err
+	}
+	x := &EXIF{
+		Description: t.text(ifd0[tagImageDescription]),
+		Make:        t.text(ifd0[tagMake]),
+		Model:       t.text(ifd0[tagModel]),
+		Software:    t.text(ifd0[tagSoftware]),
+		Artist:      t.text(ifd0[tagArtist]),
+		Copyright:   t.text(ifd0[tagCopyright]),
+		Orientation: t.integer(ifd0[tagOrientation]),
+		Width:       t.integer(ifd0[tagImageWidth]),
+		Height:      t.integer(ifd0[tagImageLength]),
+	}
+	var offset string
+	if e, ok := ifd0[tagExifIFD]; ok {
+		sub, err := t.directory(uint32(t.integer(e)))
+		if err != nil {
+			return nil, err
+		}
+		offset = t.text(sub[tagOffsetTime])
+		x.Taken = timestamp(t.text(sub[tagDateTimeOriginal]), t.text(sub[tagOffsetTimeOriginal]))
+		x.Digitized = timestamp(t.text(sub[tagDateTimeDigitized]), t.text(sub[tagOffsetTimeDigitized]))
+		x.SerialNumber = t.text(sub[tagBodySerialNumber])
+		x.LensModel = t.text(sub[tagLensModel])
+		if w, h := t.integer(sub[tagPixelXDimension]), t.integer(sub[tagPixelYDimension]); w > 0 && h > 0 {
+			x.Width, x.Height = w, h
+		}
+	}
+	x.Modified = timestamp(t.text(ifd0[tagDateTime]), offset)
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		sub, err := t.directory(uint32(t.integer(e)))
+		if err != nil {
+			return nil, err
+		}
+		x.GPS = t.gps(sub)
+	}
+	return x, nil
+}
+
+// directory reads the entries of the directory at offset, by tag
+func (t *tiff) directory(offset uint32) (map[uint16]entry, error) {
+	var count [2]byte
+	if _, err := t.r.ReadAt(count[:], int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to read directory at %d: %w", offset, err)
+	}
+	n := int(t.order.Uint16(count[:]))
+	if n > maxEntries {
+		return nil, fmt.Errorf("directory at %d has %d entries", offset, n)
+	}
+	raw := make([]byte, 12*n)
+	if _, err := t.r.ReadAt(raw, int64(offset)+2); err != nil {
+		return nil, fmt.Errorf("failed to read directory at %d: %w", offset, err)
+	}
+
+	entries := make(map[uint16]entry, n)
+	for i := 0; i < n; i++ {
+		field := raw[12*i : 12*i+12]
+		tag := t.order.Uint16(field)
+		e := entry{kind: t.order.Uint16(field[2:]), count: t.order.Uint32(field[4:])}
+		size, ok := typeSizes[e.kind]
+		if !ok || uint64(size)*uint64(e.count) > maxValueSize {
+			continue
+		}
+		length := size * int(e.count)
+		if length <= 4 {
+			e.value = field[8 : 8+length]
+		} else {
+			e.value = make([]byte, length)
+			if _, err := t.r.ReadAt(e.value, int64(t.order.Uint32(field[8:]))); err != nil {
+				continue // points outside the data
+			}
+		}
+		entries[tag] = e
+	}
+	return entries, nil
+}
+
+// text returns an ASCII value, trimmed of its terminating NULs and padding
+func (t *tiff) text(e entry) string {
+	if e.kind != 2 && e.kind != 7 {
+		return ""
+	}
+	value := string(e.value)
+	if i := strings.IndexByte(value, 0); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// integer returns the first value of a BYTE, SHORT or LONG entry
+func (t *tiff) integer(e entry) int {
+	switch {
+	case e.kind == 1 && len(e.value) >= 1:
+		return int(e.value[0])
+	case e.kind == 3 && len(e.value) >= 2:
+		return int(t.order.Uint16(e.value))
+	case e.kind == 4 && len(e.value) >= 4:
+		return int(t.order.Uint32(e.value))
+	}
+	return 0
+}
+
+// rationals returns the values of a RATIONAL entry
+func (t *tiff) rationals(e entry) []float64 {
+	if e.kind != 5 {
+		return nil
+	}
+	var values []float64
+	for i := 0; i+8 <= len(e.value); i += 8 {
+		num, den := t.order.Uint32(e.value[i:]), t.order.Uint32(e.value[i+4:])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+// gps reads the position in a GPS directory, or nil if it has none
+func (t *tiff) gps(dir map[uint16]entry) *GPS {
+	lat, lon := t.rationals(dir[tagGPSLatitude]), t.rationals(dir[tagGPSLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+	g := &GPS{Latitude: degrees(lat), Longitude: degrees(lon)}
+	if strings.EqualFold(t.text(dir[tagGPSLatitudeRef]), "S") {
+		g.Latitude = -g.Latitude
+	}
+	if strings.EqualFold(t.text(dir[tagGPSLongitudeRef]), "W") {
+		g.Longitude = -g.Longitude
+	}
+	if alt := t.rationals(dir[tagGPSAltitude]); len(alt) == 1 {
+		altitude := alt[0]
+		if t.integer(dir[tagGPSAltitudeRef]) == 1 {
+			altitude = -altitude // below sea level
+		}
+		g.Altitude = &altitude
+	}
+	if clock := t.rationals(dir[tagGPSTimeStamp]); len(clock) == 3 {
+		if date := t.text(dir[tagGPSDateStamp]); date != "" {
+			stamp := fmt.Sprintf("%s %02d:%02d:%02d", date, int(clock[0]), int(clock[1]), int(clock[2]))
+			g.Time = timestamp(stamp, "+00:00")
+			if g.Time != "" {
+				g.Time = strings.TrimSuffix(g.Time, "+00:00") + "Z"
+			}
+		}
+	}
+	return g
+}
+
+// degrees converts degrees, minutes and seconds to decimal degrees, rounded
+// to the 7 places that locate a point to about a centimeter
+func degrees(dms []float64) float64 {
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+	return math.Round(value*1e7) / 1e7
+}
+
+// timestamp reformats an EXIF time ("2006:01:02 15:04:05") as
+// 2006-01-02T15:04:05, followed by offset ("-05:00") if that is valid. It
+// returns "" for times cameras write when their clock was never set.
+func timestamp(value, offset string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil || t.Year() < 1900 {
+		return ""
+	}
+	formatted := t.Format("2006-01-02T15:04:05")
+	if _, err := time.Parse("-07:00", offset); err == nil {
+		formatted += offset
+	}
+	return formatted
+}
+
+// isEmpty reports whether x holds nothing but the image's size and
+// orientation, which every image has
+func (x *EXIF) isEmpty() bool {
+	if x == nil {
+		return true
+	}
+	rest := *x
+	rest.Orientation, rest.Width, rest.Height = 0, 0, 0
+	return rest == EXIF{}
+}
@@ -0,0 +1,177 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// field is a tag written by buildTIFF
+type field struct {
+	tag   uint16
+	kind  uint16
+	value any // string, uint16, uint32, []uint32 (rationals as pairs) or []field (a sub-directory)
+}
+
+// buildTIFF encodes directories of fields as little-endian TIFF data,
+// storing sub-directories and long values after the directory that
+// points at them
+func buildTIFF(fields []field) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	data := buf.Bytes()
+	return writeDirectory(data, fields)
+}
+
+func writeDirectory(data []byte, fields []field) []byte {
+	le := binary.LittleEndian
+	start := len(data)
+	data = le.AppendUint16(data, uint16(len(fields)))
+	data = append(data, make([]byte, 12*len(fields)+4)...)
+	for i, f := range fields {
+		at := start + 2 + 12*i
+		le.PutUint16(data[at:], f.tag)
+		le.PutUint16(data[at+2:], f.kind)
+		var value []byte
+		count := 0
+		switch v := f.value.(type) {
+		case string:
+			value, count = append([]byte(v), 0), len(v)+1
+		case uint16:
+			value, count = le.AppendUint16(nil, v), 1
+		case uint32:
+			value, count = le.AppendUint32(nil, v), 1
+		case []uint32:
+			for _, n := range v {
+				value = le.AppendUint32(value, n)
+			}
+			count = len(v) / 2
+		case []field:
+			le.PutUint32(data[at+4:], 1)
+			le.PutUint32(data[at+8:], uint32(len(data)))
+			data = writeDirectory(data, v)
+			continue
+		}
+		le.PutUint32(data[at+4:], uint32(count))
+		if len(value) <= 4 {
+			copy(data[at+8:], value)
+		} else {
+			le.PutUint32(data[at+8:], uint32(len(data)))
+			data = append(data, value...)
+		}
+	}
+	return data
+}
+
+// photoFields is the EXIF of a photo taken with GPS on
+var photoFields = []field{
+	{tagMake, 2, "Canon"},
+	{tagModel, 2, "Canon EOS 5D"},
+	{tagDateTime, 2, "2005:03:14 09:30:00"},
+	{tagOrientation, 3, uint16(1)},
+	{tagExifIFD, 4, []field{
+		{tagDateTimeOriginal, 2, "2005:03:12 18:02:41"},
+		{tagOffsetTimeOriginal, 2, "-05:00"},
+		{tagBodySerialNumber, 2, "0420103827"},
+	}},
+	{tagGPSIFD, 4, []field{
+		{tagGPSLatitudeRef, 2, "N"},
+		{tagGPSLatitude, 5, []uint32{18, 1, 18, 1, 1800, 100}},
+		{tagGPSLongitudeRef, 2, "W"},
+		{tagGPSLongitude, 5, []uint32{64, 1, 49, 1, 3000, 100}},
+		{tagGPSAltitude, 5, []uint32{12, 1}},
+		{tagGPSTimeStamp, 5, []uint32{23, 1, 2, 1, 41, 1}},
+		{tagGPSDateStamp, 2, "2005:03:12"},
+	}},
+}
+
+func TestReadJPEG(t *testing.T) {
+	exifData := append([]byte("Exif\x00\x00"), buildTIFF(photoFields)...)
+	var jpeg bytes.Buffer
+	jpeg.Write([]byte{0xFF, 0xD8})
+	jpeg.Write([]byte{0xFF, 0xE1})
+	binary.Write(&jpeg, binary.BigEndian, uint16(len(exifData)+2))
+	jpeg.Write(exifData)
+	jpeg.Write([]byte{0xFF, 0xC0, 0x00, 0x11, 0x08, 0x0B, 0xB8, 0x0F, 0xA0}) // 4000x3000
+	jpeg.Write(make([]byte, 10))
+	jpeg.Write([]byte{0xFF, 0xDA})
+
+	path := filepath.Join(t.TempDir(), "EFTA00031337.jpg")
+	if err := os.WriteFile(path, jpeg.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	x, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	altitude := 12.0
+	want := &EXIF{
+		Make:         "Canon",
+		Model:        "Canon EOS 5D",
+		SerialNumber: "0420103827",
+		Taken:        "2005-03-12T18:02:41-05:00",
+		Modified:     "2005-03-14T09:30:00",
+		Orientation:  1,
+		Width:        4000,
+		Height:       3000,
+		GPS:          &GPS{Latitude: 18.305, Longitude: -64.8250, Altitude: &altitude, Time: "2005-03-12T23:02:41Z"},
+	}
+	if !reflect.DeepEqual(x, want) {
+		t.Errorf("Read() = %+v (GPS %+v), want %+v (GPS %+v)", x, x.GPS, want, want.GPS)
+	}
+}
+
+func TestReadTIFF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.tif")
+	data := buildTIFF([]field{
+		{tagImageWidth, 3, uint16(2550)},
+		{tagImageLength, 3, uint16(3300)},
+		{tagSoftware, 2, "ScanSoft 4.2"},
+		{tagDateTime, 2, "0000:00:00 00:00:00"}, // clock never set
+	})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	x, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if want := (&EXIF{Software: "ScanSoft 4.2", Width: 2550, Height: 3300}); !reflect.DeepEqual(x, want) {
+		t.Errorf("Read() = %+v, want %+v", x, want)
+	}
+
+	// Only the size: nothing worth recording
+	if err := os.WriteFile(path, buildTIFF([]field{{tagImageWidth, 3, uint16(10)}}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if x, err := Read(path); x != nil || err != nil {
+		t.Errorf("Read() of a bare TIFF = %+v, %v; want nil", x, err)
+	}
+}
+
+func TestReadDamaged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.jpg")
+	data := buildTIFF(photoFields)
+	data[8] = 0xFF // directory claims 65k entries
+	data[9] = 0xFF
+	jpeg := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, byte((len(data) + 8) >> 8), byte(len(data) + 8)}, "Exif\x00\x00"...)
+	jpeg = append(jpeg, data...)
+	if err := os.WriteFile(path, jpeg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Error("Read() of a damaged directory succeeded")
+	}
+}
+
+func TestIsImage(t *testing.T) {
+	for path, want := range map[string]bool{"a.JPG": true, "a.jpeg": true, "a.tiff": true, "a.pdf": false, "a": false} {
+		if got := IsImage(path); got != want {
+			t.Errorf("IsImage(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
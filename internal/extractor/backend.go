@@ -0,0 +1,133 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Text extraction backends
+const (
+	BackendNative    = "native"    // the built-in PDF text extractor
+	BackendPDFToText = "pdftotext" // poppler's pdftotext, if installed
+	BackendTesseract = "tesseract" // OCR of image documents and, as a fallback, of rendered PDF pages
+)
+
+// ValidFallback reports whether backend can be used as a fallback
+func ValidFallback(backend string) bool {
+	return backend == BackendPDFToText || backend == BackendTesseract
+}
+
+// Fallback is a backend tried on pages where the text found so far is
+// poor: pages with fewer than MinWords words (1 if unset, i.e. pages with no
+// text at all). The backend's text replaces the page's if it has more words.
+type Fallback struct {
+	Backend  string
+	MinWords int
+}
+
+// applyFallbacks runs each fallback backend over the pages of numbers (the
+// pages being extracted) that still fall below its word threshold. The pages
+// a backend reads are used even if it fails on others; the last error of a
+// backend is returned along with the pages.
+func (e *Extractor) applyFallbacks(filePath string, pages []PageText, numbers []int) ([]PageText, error) {
+	if len(e.fallbacks) == 0 {
+		return pages, nil
+	}
+	byNumber := make(map[int]PageText, len(numbers))
+	for _, page := range pages {
+		byNumber[page.PageNumber] = page
+	}
+
+	var lastErr error
+	for _, fallback := range e.fallbacks {
+		minWords := fallback.MinWords
+		if minWords < 1 {
+			minWords = 1
+		}
+		var wanted []int
+		for _, n := range numbers {
+			if wordCount(byNumber[n].Text) < minWords {
+				wanted = append(wanted, n)
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+
+		texts, err := e.runBackend(fallback.Backend, filePath, wanted)
+		if err != nil {
+			lastErr = err
+		}
+		for _, n := range wanted {
+			read, ok := texts[n]
+			if !ok || wordCount(read.text) <= wordCount(byNumber[n].Text) {
+				continue
+			}
+			// Line positions came from the native extraction and no longer
+			// match the text
+			native := byNumber[n]
+			byNumber[n] = PageText{PageNumber: n, Text: read.text, Rotation: native.Rotation, OCRRotation: read.rotation, Skew: read.skew, Width: native.Width, Height: native.Height, Backend: fallback.Backend}
+		}
+	}
+
+	merged := make([]PageText, 0, len(byNumber))
+	for _, page := range byNumber {
+		if page.Text != "" {
+			merged = append(merged, page)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].PageNumber < merged[j].PageNumber })
+	return merged, lastErr
+}
+
+// runBackend extracts the text of the given pages with a fallback backend
+func (e *Extractor) runBackend(backend, filePath string, pages []int) (map[int]ocrPage, error) {
+	switch backend {
+	case BackendPDFToText:
+		texts, err := pdfToText(filePath, pages)
+		if err != nil {
+			return nil, err
+		}
+		read := make(map[int]ocrPage, len(texts))
+		for n, text := range texts {
+			read[n] = ocrPage{text: text}
+		}
+		return read, nil
+	case BackendTesseract:
+		return ocrPages(filePath, pages, e.scratch)
+	default:
+		return nil, fmt.Errorf("unknown extraction backend %q", backend)
+	}
+}
+
+// pdfToText runs pdftotext over the range covering pages. pdftotext ends
+// every page with a form feed, which is how its output is split back up.
+func pdfToText(filePath string, pages []int) (map[int]string, error) {
+	path, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return nil, fmt.Errorf("pdftotext backend: %w", err)
+	}
+	first, last := pages[0], pages[len(pages)-1]
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "-enc", "UTF-8", "-layout", "-f", fmt.Sprint(first), "-l", fmt.Sprint(last), filePath, "-")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftotext backend: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	texts := make(map[int]string)
+	for i, text := range strings.Split(stdout.String(), "\f") {
+		if n := first + i; n <= last {
+			texts[n] = strings.TrimRight(text, " \n")
+		}
+	}
+	return texts, nil
+}
+
+// wordCount counts whitespace-separated words
+func wordCount(text string) int {
+	return len(strings.Fields(text))
+}
@@ -0,0 +1,188 @@
+package extractor
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePDFToText puts a pdftotext on PATH that prints the given pages, each
+// followed by a form feed, whatever range is asked for
+func fakePDFToText(t *testing.T, output string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pdftotext is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, "pdftotext"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestApplyFallbacks(t *testing.T) {
+	// Asked for pages 2-3: page 2 gains text, page 3 stays thin
+	fakePDFToText(t, `recovered text on page two\fthin\f`)
+	e := NewWithOptions(Options{Fallbacks: []Fallback{{Backend: BackendPDFToText, MinWords: 3}}})
+
+	pages := []PageText{
+		{PageNumber: 1, Text: "plenty of native words here", Backend: BackendNative},
+		{PageNumber: 3, Text: "two words", Backend: BackendNative},
+	}
+	got, err := e.applyFallbacks("doc.pdf", pages, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("applyFallbacks() error = %v", err)
+	}
+	want := []struct {
+		number  int
+		backend string
+		text    string
+	}{
+		{1, BackendNative, "plenty of native words here"},
+		{2, BackendPDFToText, "recovered text on page two"},
+		{3, BackendNative, "two words"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("applyFallbacks() returned %d pages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].PageNumber != w.number || got[i].Backend != w.backend || got[i].Text != w.text {
+			t.Errorf("page %d = %+v, want %s from %s", i, got[i], w.text, w.backend)
+		}
+	}
+}
+
+func TestApplyFallbacksMissingBackend(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	e := NewWithOptions(Options{Fallbacks: []Fallback{{Backend: BackendPDFToText}}})
+	pages := []PageText{{PageNumber: 1, Text: "native", Backend: BackendNative}}
+
+	got, err := e.applyFallbacks("doc.pdf", pages, []int{1, 2})
+	if err == nil {
+		t.Error("applyFallbacks() did not report the missing pdftotext")
+	}
+	if len(got) != 1 || got[0].Text != "native" {
+		t.Errorf("applyFallbacks() = %+v, want the native pages kept", got)
+	}
+}
+
+func TestTesseractFallback(t *testing.T) {
+	// tesseract reads "CHECK NO. 1042" from any image; pdftoppm renders
+	// every page as the same image, under the prefix it is given
+	fakeImageTools(t)
+	dir := t.TempDir()
+	image := filepath.Join(dir, "page.png")
+	writePNG(t, image, 200, 100)
+	pdftoppm := "#!/bin/sh\nfor prefix; do :; done\ncp " + image + " \"$prefix.png\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "pdftoppm"), []byte(pdftoppm), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if err := CheckPageOCR(); err != nil {
+		t.Fatalf("CheckPageOCR() error = %v", err)
+	}
+
+	// A scanned page: an image and no text layer
+	path := filepath.Join(t.TempDir(), "scan.pdf")
+	writePagePDF(t, path, "/MediaBox [0 0 612 792]", "", "q 612 0 0 792 0 0 cm Q")
+	if _, err := New().ExtractText(path); err == nil {
+		t.Fatal("native extraction found text on a page without a text layer")
+	}
+
+	e := NewWithOptions(Options{Fallbacks: []Fallback{{Backend: BackendTesseract}}})
+	pages, text, _, err := e.ExtractTextStructured(path)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	if text != "CHECK NO. 1042" || len(pages) != 1 || pages[0].Backend != BackendTesseract {
+		t.Errorf("ExtractTextStructured() = %+v, %q; want the OCR text from tesseract", pages, text)
+	}
+}
+
+func TestTesseractFallbackTurnsPagesUpright(t *testing.T) {
+	// A page scanned sideways: orientation detection asks for a quarter turn,
+	// and recognition reads a line tilted by about 2 degrees from the image
+	// it is given, which is kept for inspection
+	if runtime.GOOS == "windows" {
+		t.Skip("fake OCR tools are shell scripts")
+	}
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.png")
+	writePNG(t, page, 200, 100)
+	seen := filepath.Join(dir, "seen.png")
+	pdftoppm := "#!/bin/sh\nfor prefix; do :; done\ncp " + page + " \"$prefix.png\"\n"
+	tesseract := "#!/bin/sh\ncase \"$*\" in\n*--psm\\ 0*) printf 'Page number: 0\\nOrientation in degrees: 270\\nRotate: 90\\nOrientation confidence: 6.41\\n' ;;\n" +
+		"*) cp \"$1\" " + seen + "\nprintf '" +
+		"5\\t1\\t1\\t1\\t1\\t1\\t100\\t500\\t100\\t40\\t95\\tFLIGHT\\n" +
+		"5\\t1\\t1\\t1\\t1\\t2\\t300\\t493\\t100\\t40\\t95\\tLOG\\n" +
+		"5\\t1\\t1\\t1\\t1\\t3\\t500\\t486\\t100\\t40\\t95\\tTETERBORO\\n' ;;\nesac\n"
+	for name, script := range map[string]string{"pdftoppm": pdftoppm, "tesseract": tesseract} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	path := filepath.Join(t.TempDir(), "scan.pdf")
+	writePagePDF(t, path, "/MediaBox [0 0 612 792]", "", "q 612 0 0 792 0 0 cm Q")
+	e := NewWithOptions(Options{Fallbacks: []Fallback{{Backend: BackendTesseract}}})
+	pages, text, _, err := e.ExtractTextStructured(path)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	if text != "FLIGHT LOG TETERBORO" || len(pages) != 1 || pages[0].OCRRotation != 90 || pages[0].Skew != 2 {
+		t.Errorf("ExtractTextStructured() = %+v, %q; want the page turned 90 degrees with a skew of 2", pages, text)
+	}
+	file, err := os.Open(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if config, _, err := image.DecodeConfig(file); err != nil || config.Width != 100 || config.Height != 200 {
+		t.Errorf("tesseract read a %dx%d image (%v), want the 200x100 page turned to 100x200", config.Width, config.Height, err)
+	}
+}
+
+func TestParseOSD(t *testing.T) {
+	tests := []struct {
+		osd        string
+		rotate     int
+		confidence float64
+	}{
+		{"Page number: 0\nOrientation in degrees: 180\nRotate: 180\nOrientation confidence: 12.03\nScript: Latin\n", 180, 12.03},
+		{"Rotate: 0\nOrientation confidence: 0.41\n", 0, 0.41},
+		{"Too few characters. Skipping this page\n", 0, 0},
+		{"Rotate: 45\nOrientation confidence: 9\n", 0, 0},
+	}
+	for _, tt := range tests {
+		if rotate, confidence := parseOSD(tt.osd); rotate != tt.rotate || confidence != tt.confidence {
+			t.Errorf("parseOSD(%q) = %d, %v; want %d, %v", tt.osd, rotate, confidence, tt.rotate, tt.confidence)
+		}
+	}
+}
+
+func TestOCRPagesKeepsPagesRead(t *testing.T) {
+	// pdftoppm cannot render page 2; pages 1 and 3 are still read
+	fakeImageTools(t)
+	dir := t.TempDir()
+	image := filepath.Join(dir, "page.png")
+	writePNG(t, image, 200, 100)
+	pdftoppm := "#!/bin/sh\ncase \"$*\" in\n*'-f 2 '*) echo 'Syntax Error: page 2 is damaged' >&2; exit 1 ;;\nesac\n" +
+		"for prefix; do :; done\ncp " + image + " \"$prefix.png\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "pdftoppm"), []byte(pdftoppm), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	texts, err := ocrPages("scan.pdf", []int{1, 2, 3}, nil)
+	if err == nil || !strings.Contains(err.Error(), "page(s) 2 of 3") || !strings.Contains(err.Error(), "page 2 is damaged") {
+		t.Errorf("ocrPages() error = %v, want page 2 reported", err)
+	}
+	if len(texts) != 2 || texts[1].text != "CHECK NO. 1042" || texts[3].text != "CHECK NO. 1042" {
+		t.Errorf("ocrPages() = %+v, want pages 1 and 3", texts)
+	}
+}
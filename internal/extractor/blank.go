@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/legal"
+)
+
+// maxBlankPaintOps is how many path painting operators a blank page may
+// still have: generators and scanners often fill a page-sized background or
+// draw a border
+const maxBlankPaintOps = 2
+
+// maxBlankChars is how many letters and digits a near-blank page may carry
+// once stamps are removed (a stray page number or mark)
+const maxBlankChars = 3
+
+// leftBlankRe matches the "this page intentionally left blank" notice that
+// separator pages carry
+var leftBlankRe = regexp.MustCompile(`(?i)(?:this\s+)?page\s+(?:is\s+)?intentionally\s+(?:left\s+)?blank\.?`)
+
+// paintOperators are the content stream operators that put ink on the page
+// other than text and images
+var paintOperators = map[string]bool{
+	"S": true, "s": true, "f": true, "F": true, "f*": true,
+	"B": true, "B*": true, "b": true, "b*": true, "sh": true,
+}
+
+// textOperators show text, which may not have decoded to any characters
+var textOperators = map[string]bool{"Tj": true, "TJ": true, "'": true, `"`: true}
+
+// negligibleText reports whether text is too slight to be content: nothing
+// but Bates stamps, a page number, a "left blank" notice or a few stray marks
+func negligibleText(text string) bool {
+	text = legal.ReplaceBates(text, func(legal.Bates) string { return "" })
+	text = leftBlankRe.ReplaceAllString(text, "")
+	chars := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			chars++
+		}
+	}
+	return chars <= maxBlankChars
+}
+
+// pageHasInk reports whether a page draws anything beyond its (negligible)
+// decoded text: images, more than a background or border of vector paths,
+// or, when no text decoded at all, text that did not decode
+func pageHasInk(page pdf.Page, decodedText bool) bool {
+	if hasImages(page) {
+		return true
+	}
+	paints := 0
+	for _, op := range bytes.Fields(pageContent(page)) {
+		switch {
+		case paintOperators[string(op)]:
+			paints++
+		case !decodedText && textOperators[string(op)]:
+			return true
+		}
+	}
+	return paints > maxBlankPaintOps
+}
+
+// markBlankPages flags the pages found blank during extraction (blank holds
+// their numbers) whose text is still negligible after fallbacks, and adds an
+// empty entry for those with no text at all so every blank page is recorded
+func markBlankPages(pages []PageText, blank map[int]bool) []PageText {
+	if len(blank) == 0 {
+		return pages
+	}
+	seen := make(map[int]bool, len(pages))
+	for i := range pages {
+		seen[pages[i].PageNumber] = true
+		if blank[pages[i].PageNumber] && pages[i].ImageText == "" && negligibleText(pages[i].Text) {
+			pages[i].Blank = true
+		}
+	}
+	for n := range blank {
+		if !seen[n] {
+			pages = append(pages, PageText{PageNumber: n, Backend: BackendNative, Blank: true})
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].PageNumber < pages[j].PageNumber })
+	return pages
+}
+
+// isBlankText reports whether a page has no text to speak of
+func isBlankText(page PageText) bool {
+	return page.Blank || strings.TrimSpace(page.Text) == ""
+}
@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegligibleText(t *testing.T) {
+	tests := map[string]bool{
+		"":                                   true,
+		"\n  \n":                             true,
+		"EFTA00010724":                       true,
+		"- 12 -\nDOJ-OGR-00000044":           true,
+		"THIS PAGE INTENTIONALLY LEFT BLANK": true,
+		"Page intentionally blank\n":         true,
+		"EXHIBIT A":                          false,
+		"Flight log\nEFTA00010724":           false,
+		"See attached.":                      false,
+	}
+	for text, want := range tests {
+		if got := negligibleText(text); got != want {
+			t.Errorf("negligibleText(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestMarkBlankPages(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "Letter to counsel"},
+		{PageNumber: 2, Text: "EFTA00000102"},
+		{PageNumber: 4, Text: "Memo"},
+		{PageNumber: 5, Text: "EFTA00000105", ImageText: "CHECK NO. 1042"},
+	}
+	// Page 3 had no text at all; page 4 was blank until a fallback found text
+	got := markBlankPages(pages, map[int]bool{2: true, 3: true, 4: true, 5: true})
+
+	var blank []int
+	var numbers []int
+	for _, page := range got {
+		numbers = append(numbers, page.PageNumber)
+		if page.Blank {
+			blank = append(blank, page.PageNumber)
+		}
+	}
+	if !reflect.DeepEqual(numbers, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("pages = %v, want 1-5 in order", numbers)
+	}
+	if !reflect.DeepEqual(blank, []int{2, 3}) {
+		t.Errorf("blank pages = %v, want [2 3]", blank)
+	}
+}
+
+func TestBlankPagesInJSON(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "Letter to counsel"},
+		{PageNumber: 2, Text: "EFTA00000102", Blank: true},
+	}
+	extracted := newExtractedText("doc.pdf", pages, "Letter to counsel")
+	if extracted.Metadata.PagesExtracted != 1 || extracted.Metadata.BlankPages != 1 {
+		t.Errorf("PagesExtracted = %d, BlankPages = %d, want 1 and 1", extracted.Metadata.PagesExtracted, extracted.Metadata.BlankPages)
+	}
+	if page := extracted.Content.Pages[1]; !page.Blank || page.WordCount != 0 || page.TokenCount != 0 {
+		t.Errorf("page 2 = %+v, want blank with no words", page)
+	}
+}
@@ -0,0 +1,182 @@
+package extractor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// boilerplateEdge is how many lines at the top and at the bottom of a
+	// page are looked at for running headers, footers and stamps
+	boilerplateEdge = 3
+	// minBoilerplatePages is how many pages a line must repeat on, and
+	// boilerplateShare what share of the document's non-blank pages, before
+	// it is taken for boilerplate
+	minBoilerplatePages = 3
+	boilerplateShare    = 0.5
+	// minBoilerplateLetters keeps short repeated lines (a lone "Q.", "Page")
+	// in the text
+	minBoilerplateLetters = 4
+)
+
+// Boilerplate is a header, footer or stamp repeated across a document's
+// pages and removed from their text
+type Boilerplate struct {
+	Text  string `json:"text"`
+	Pages []int  `json:"pages"` // pages it was removed from
+}
+
+// stripBoilerplate removes lines repeated at the top or bottom of many pages
+// of a document, such as running headers and "CONFIDENTIAL — SUBJECT TO
+// PROTECTIVE ORDER" stamps, recording them in each page's Boilerplate. Lines
+// only count as the same when they match exactly, apart from case and
+// spacing, so page numbers and Bates numbers stay in the text. Blank pages
+// are left alone.
+func stripBoilerplate(pages []PageText) []PageText {
+	counts := make(map[string]int)
+	nonBlank := 0
+	for _, page := range pages {
+		if page.Blank {
+			continue
+		}
+		nonBlank++
+		seen := make(map[string]bool)
+		texts := pageLineTexts(page)
+		for _, i := range edgeLines(texts) {
+			key := boilerplateKey(texts[i])
+			if key != "" && !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	threshold := max(minBoilerplatePages, int(boilerplateShare*float64(nonBlank)+0.5))
+	repeated := make(map[string]bool)
+	for key, n := range counts {
+		if n >= threshold {
+			repeated[key] = true
+		}
+	}
+	if len(repeated) == 0 {
+		return pages
+	}
+
+	stripped := make([]PageText, len(pages))
+	for i, page := range pages {
+		stripped[i] = page
+		if !page.Blank {
+			stripped[i] = removeLines(page, repeated)
+		}
+	}
+	return stripped
+}
+
+// removeLines removes the edge lines of a page that are repeated
+// boilerplate, rebuilding the page text so line offsets stay exact
+func removeLines(page PageText, repeated map[string]bool) PageText {
+	texts := pageLineTexts(page)
+	drop := make(map[int]bool)
+	for _, i := range edgeLines(texts) {
+		if repeated[boilerplateKey(texts[i])] {
+			drop[i] = true
+			page.Boilerplate = append(page.Boilerplate, strings.TrimSpace(texts[i]))
+		}
+	}
+	if len(drop) == 0 {
+		return page
+	}
+
+	if len(page.Lines) == 0 {
+		var kept []string
+		for i, text := range texts {
+			if !drop[i] {
+				kept = append(kept, text)
+			}
+		}
+		page.Text = strings.Join(kept, "\n")
+		return page
+	}
+	var b strings.Builder
+	var lines []Line
+	for i, line := range page.Lines {
+		if drop[i] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		line.Offset = b.Len()
+		b.WriteString(line.Text)
+		lines = append(lines, line)
+	}
+	page.Text = b.String()
+	page.Lines = lines
+	return page
+}
+
+// pageLineTexts returns the lines of a page: its positioned lines where
+// available, otherwise its text split at line breaks
+func pageLineTexts(page PageText) []string {
+	if len(page.Lines) == 0 {
+		return strings.Split(page.Text, "\n")
+	}
+	texts := make([]string, len(page.Lines))
+	for i, line := range page.Lines {
+		texts[i] = line.Text
+	}
+	return texts
+}
+
+// edgeLines returns the indexes of the first and last non-empty lines of a
+// page, boilerplateEdge of each
+func edgeLines(texts []string) []int {
+	var nonEmpty []int
+	for i, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			nonEmpty = append(nonEmpty, i)
+		}
+	}
+	if len(nonEmpty) <= 2*boilerplateEdge {
+		return nonEmpty
+	}
+	return append(nonEmpty[:boilerplateEdge:boilerplateEdge], nonEmpty[len(nonEmpty)-boilerplateEdge:]...)
+}
+
+// boilerplateKey returns the form of a line compared across pages: lowercased
+// with whitespace collapsed, or "" for lines too short to be boilerplate
+func boilerplateKey(text string) string {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minBoilerplateLetters {
+		return ""
+	}
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// collectBoilerplate gathers the boilerplate removed from each page into one
+// entry per line, the most repeated first
+func collectBoilerplate(pages []PageText) []Boilerplate {
+	var collected []Boilerplate
+	index := make(map[string]int)
+	for _, page := range pages {
+		for _, text := range page.Boilerplate {
+			key := boilerplateKey(text)
+			i, ok := index[key]
+			if !ok {
+				i = len(collected)
+				index[key] = i
+				collected = append(collected, Boilerplate{Text: text})
+			}
+			if pages := collected[i].Pages; len(pages) == 0 || pages[len(pages)-1] != page.PageNumber {
+				collected[i].Pages = append(collected[i].Pages, page.PageNumber)
+			}
+		}
+	}
+	sort.SliceStable(collected, func(i, j int) bool { return len(collected[i].Pages) > len(collected[j].Pages) })
+	return collected
+}
@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// boilerplatePage builds a page with positioned lines from its line texts
+func boilerplatePage(number int, texts ...string) PageText {
+	page := PageText{PageNumber: number, Text: strings.Join(texts, "\n")}
+	offset := 0
+	for _, text := range texts {
+		page.Lines = append(page.Lines, Line{Text: text, Offset: offset})
+		offset += len(text) + 1
+	}
+	return page
+}
+
+func TestStripBoilerplate(t *testing.T) {
+	const stamp = "CONFIDENTIAL — SUBJECT TO PROTECTIVE ORDER"
+	var pages []PageText
+	for i := 1; i <= 4; i++ {
+		pages = append(pages, boilerplatePage(i,
+			stamp,
+			fmt.Sprintf("Flight log entry %d", i),
+			fmt.Sprintf("Passengers on leg %d: J. Epstein, G. Maxwell", i),
+			fmt.Sprintf("Destination %d: Teterboro", i),
+			fmt.Sprintf("Remarks %d: none", i),
+			fmt.Sprintf("EFTA0001072%d", i),
+		))
+	}
+	pages[2].Lines[0].Text = "confidential  —  subject to protective order" // spacing and case differ
+	pages[2].Text = strings.Replace(pages[2].Text, stamp, pages[2].Lines[0].Text, 1)
+	// Without positioned lines, and quoting the stamp mid-page
+	pages = append(pages, PageText{PageNumber: 5, Text: stamp + "\nThe letter reads:\nline two\nline three\n" + stamp + "\nline five\nline six\nline seven"})
+	pages = append(pages, PageText{PageNumber: 6, Blank: true, Text: stamp})
+
+	got := stripBoilerplate(pages)
+	for _, page := range got[:4] {
+		if strings.Contains(strings.ToLower(page.Text), "confidential") {
+			t.Errorf("page %d still has the stamp: %q", page.PageNumber, page.Text)
+		}
+		if !strings.Contains(page.Text, "EFTA0001072") {
+			t.Errorf("page %d lost its Bates number: %q", page.PageNumber, page.Text)
+		}
+		for _, line := range page.Lines {
+			if page.Text[line.Offset:line.Offset+len(line.Text)] != line.Text {
+				t.Errorf("page %d: line %q is not at offset %d", page.PageNumber, line.Text, line.Offset)
+			}
+		}
+	}
+	if want := "The letter reads:\nline two\nline three\n" + stamp + "\nline five\nline six\nline seven"; got[4].Text != want {
+		t.Errorf("page 5 text = %q, want the stamp kept mid-page", got[4].Text)
+	}
+	if got[5].Text != stamp || got[5].Boilerplate != nil {
+		t.Errorf("blank page changed: %+v", got[5])
+	}
+	if pages[0].Boilerplate != nil {
+		t.Error("stripBoilerplate modified the original pages")
+	}
+
+	collected := collectBoilerplate(got)
+	if len(collected) != 1 || collected[0].Text != stamp || !reflect.DeepEqual(collected[0].Pages, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("collectBoilerplate() = %+v, want the stamp on pages 1-5", collected)
+	}
+	if lines := leadingLines(got, 1); lines[0] != stamp {
+		t.Errorf("leadingLines() = %q, want the stamp first for cover sheet parsing", lines)
+	}
+
+	// Too few pages to tell boilerplate from content
+	if short := stripBoilerplate(pages[:2]); short[0].Text != pages[0].Text {
+		t.Errorf("two pages were stripped: %q", short[0].Text)
+	}
+}
@@ -0,0 +1,125 @@
+package extractor
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// DefaultReaderCacheSize is the memory a ReaderCache holds by default
+const DefaultReaderCacheSize = 64 << 20
+
+// ReaderCache keeps recently opened PDFs parsed in memory, so documents that
+// are opened again (extracted, then exported, then split; or previewed
+// repeatedly by the server) are not read and parsed every time. The least
+// recently used documents are dropped once their total size exceeds the
+// cache's budget. A ReaderCache is safe for concurrent use.
+type ReaderCache struct {
+	mu      sync.Mutex
+	max     int64
+	size    int64
+	entries *list.List               // of *cachedReader, most recently used first
+	byPath  map[string]*list.Element // into entries
+	hits    int64
+	misses  int64
+}
+
+// cachedReader is a parsed PDF held in memory, with the file's size and
+// modification time when it was read
+type cachedReader struct {
+	path    string
+	modTime time.Time
+	data    []byte
+	reader  *pdf.Reader
+}
+
+// NewReaderCache creates a ReaderCache holding at most maxBytes of PDFs
+// (DefaultReaderCacheSize if maxBytes is 0 or less)
+func NewReaderCache(maxBytes int64) *ReaderCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultReaderCacheSize
+	}
+	return &ReaderCache{max: maxBytes, entries: list.New(), byPath: make(map[string]*list.Element)}
+}
+
+// Open returns the parsed PDF at path, from the cache if the file has not
+// changed since it was cached. The returned Closer must be called when done
+// with the reader; PDFs too large for the cache are read from the open file.
+func (c *ReaderCache) Open(path string) (*pdf.Reader, io.Closer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reader := c.get(path, info); reader != nil {
+		return reader, io.NopCloser(nil), nil
+	}
+	if info.Size() > c.max {
+		file, reader, err := pdf.Open(path)
+		return reader, file, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	c.put(&cachedReader{path: path, modTime: info.ModTime(), data: data, reader: reader})
+	return reader, io.NopCloser(nil), nil
+}
+
+// Stats returns how many opens were answered from the cache and how many
+// had to parse the file
+func (c *ReaderCache) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// get returns the cached reader for path if it is still current
+func (c *ReaderCache) get(path string, info os.FileInfo) *pdf.Reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.byPath[path]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	entry := elem.Value.(*cachedReader)
+	if !entry.modTime.Equal(info.ModTime()) || int64(len(entry.data)) != info.Size() {
+		c.remove(elem)
+		c.misses++
+		return nil
+	}
+	c.entries.MoveToFront(elem)
+	c.hits++
+	return entry.reader
+}
+
+// put adds an entry, evicting the least recently used ones over the budget
+func (c *ReaderCache) put(entry *cachedReader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.byPath[entry.path]; ok {
+		c.remove(elem)
+	}
+	c.byPath[entry.path] = c.entries.PushFront(entry)
+	c.size += int64(len(entry.data))
+	for c.size > c.max {
+		c.remove(c.entries.Back())
+	}
+}
+
+// remove drops an entry; the caller holds c.mu
+func (c *ReaderCache) remove(elem *list.Element) {
+	entry := c.entries.Remove(elem).(*cachedReader)
+	delete(c.byPath, entry.path)
+	c.size -= int64(len(entry.data))
+}
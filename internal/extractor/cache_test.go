@@ -0,0 +1,102 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTextPDF writes a one-page PDF showing text
+func writeTextPDF(t *testing.T, path, text string) {
+	t.Helper()
+	writePagePDF(t, path, "/MediaBox [0 0 612 792]", "", fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text))
+}
+
+// writePagePDF writes a one-page PDF with the given page attributes, font
+// attributes (added to a Helvetica font dictionary) and content stream
+func writePagePDF(t *testing.T, path, pageAttrs, fontAttrs, content string) {
+	t.Helper()
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R " + pageAttrs + " /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica " + fontAttrs + " >>",
+	}
+	writeObjectsPDF(t, path, objects)
+}
+
+// writeObjectsPDF writes a PDF made of objects, numbered from 1, the first
+// being the catalog
+func writeObjectsPDF(t *testing.T, path string, objects []string) {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, start)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderCache(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.pdf"), filepath.Join(dir, "b.pdf")
+	writeTextPDF(t, a, "Flight log")
+	writeTextPDF(t, b, "Deposition")
+	info, _ := os.Stat(a)
+	cache := NewReaderCache(info.Size() + 10) // room for one document
+	ext := NewWithOptions(Options{Readers: cache})
+
+	for _, path := range []string{a, a, b, a} {
+		if _, err := ext.ExtractText(path); err != nil {
+			t.Fatalf("ExtractText(%s) error = %v", path, err)
+		}
+	}
+	// b evicted a, so only the second open of a was a hit
+	if hits, misses := cache.Stats(); hits != 1 || misses != 3 {
+		t.Errorf("Stats() = %d hits, %d misses, want 1 and 3", hits, misses)
+	}
+
+	// A changed file is parsed again
+	writeTextPDF(t, a, "Message pad")
+	os.Chtimes(a, time.Now(), time.Now().Add(time.Hour))
+	ext.ExtractText(a)
+	text, err := ext.ExtractText(a)
+	if err != nil || !strings.Contains(text, "Message pad") {
+		t.Errorf("ExtractText() after a change = %q, %v", text, err)
+	}
+	if hits, misses := cache.Stats(); hits != 2 || misses != 4 {
+		t.Errorf("Stats() = %d hits, %d misses, want 2 and 4", hits, misses)
+	}
+}
+
+func TestReaderCacheOversized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.pdf")
+	writeTextPDF(t, path, "Flight log")
+	cache := NewReaderCache(10)
+	reader, closer, err := cache.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer closer.Close()
+	if reader.NumPage() != 1 {
+		t.Errorf("NumPage() = %d, want 1", reader.NumPage())
+	}
+	if _, misses := cache.Stats(); misses != 1 || cache.entries.Len() != 0 {
+		t.Errorf("an oversized document was cached")
+	}
+}
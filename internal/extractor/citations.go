@@ -0,0 +1,37 @@
+package extractor
+
+import "defornicate-epstein-files/internal/legal"
+
+// Citation is a legal citation of a document with the pages citing it
+type Citation struct {
+	legal.Citation
+	Pages []int `json:"pages"`
+}
+
+// findCitations finds the legal citations of each page that is not blank
+func findCitations(pages []PageText) {
+	for i := range pages {
+		if !pages[i].Blank {
+			pages[i].Citations = legal.FindCitations(pages[i].Text)
+		}
+	}
+}
+
+// collectCitations merges the citations of the pages into one list, in order
+// of first citation, each with the pages citing it
+func collectCitations(pages []PageText) []Citation {
+	var citations []Citation
+	index := make(map[string]int)
+	for _, page := range pages {
+		for _, c := range page.Citations {
+			i, ok := index[c.Canonical]
+			if !ok {
+				i = len(citations)
+				index[c.Canonical] = i
+				citations = append(citations, Citation{Citation: c})
+			}
+			citations[i].Pages = append(citations[i].Pages, page.PageNumber)
+		}
+	}
+	return citations
+}
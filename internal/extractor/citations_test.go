@@ -0,0 +1,35 @@
+package extractor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindCitationsOption(t *testing.T) {
+	doc := filepath.Join(t.TempDir(), "motion.pdf")
+	writeTextPDF(t, doc, "See Bell Atl. Corp. v. Twombly, 550 U.S. 544; 2019 WL 1234567")
+
+	for _, enabled := range []bool{false, true} {
+		out, err := NewWithOptions(Options{FindCitations: enabled}).Render(doc, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		extracted := out.JSON
+		if extracted == nil {
+			t.Fatal("Render() gave no JSON output")
+		}
+		citations := extracted.Metadata.Citations
+		if !enabled {
+			if len(citations) != 0 {
+				t.Errorf("citations listed without FindCitations: %+v", citations)
+			}
+			continue
+		}
+		if len(citations) != 2 || citations[0].Canonical != "550 U.S. 544" || citations[1].Canonical != "2019 WL 1234567" {
+			t.Fatalf("citations = %+v, want 550 U.S. 544 and 2019 WL 1234567", citations)
+		}
+		if len(citations[0].Pages) != 1 || citations[0].Pages[0] != 1 {
+			t.Errorf("pages = %v, want [1]", citations[0].Pages)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package extractor
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Output compression modes for Options.Compression
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// compressionSuffixes maps compression modes to the suffix appended to
+// extraction output filenames
+var compressionSuffixes = map[string]string{
+	CompressionNone: "",
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// ValidCompression reports whether mode is a supported output compression
+func ValidCompression(mode string) bool {
+	_, ok := compressionSuffixes[mode]
+	return ok
+}
+
+// compressWriter returns a writer compressing what is written to it into w
+// with the given compression mode; closing it flushes the compressed stream
+// but leaves w open
+func compressWriter(w io.Writer, mode string) (io.WriteCloser, error) {
+	switch mode {
+	case CompressionNone:
+		return nopCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", mode)
+	}
+}
+
+// nopCloser is an io.WriteCloser whose Close does nothing
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// OpenOutput opens an extraction output for reading, transparently
+// decompressing .gz and .zst files
+func OpenOutput(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, compressionSuffixes[CompressionGzip]):
+		r, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return &decompressReader{Reader: r, close: func() { r.Close(); file.Close() }}, nil
+	case strings.HasSuffix(path, compressionSuffixes[CompressionZstd]):
+		r, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return &decompressReader{Reader: r, close: func() { r.Close(); file.Close() }}, nil
+	default:
+		return file, nil
+	}
+}
+
+// ReadOutput reads a whole extraction output, decompressing it if needed
+func ReadOutput(path string) ([]byte, error) {
+	r, err := OpenOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// FindOutput returns the path of an existing extraction output for filePath in
+// the extractor's current format, in any compression, or "" if there is none
+func (e *Extractor) FindOutput(filePath string) string {
+	base := strings.TrimSuffix(e.OutputPath(filePath), compressionSuffixes[e.compression])
+	for _, suffix := range []string{"", ".gz", ".zst"} {
+		if latest, _ := LatestVersion(base + suffix); latest != "" {
+			return latest
+		}
+	}
+	return ""
+}
+
+// decompressReader closes both the decompressor and the underlying file
+type decompressReader struct {
+	io.Reader
+	close func()
+}
+
+// Close releases the decompressor and closes the file
+func (r *decompressReader) Close() error {
+	r.close()
+	return nil
+}
@@ -0,0 +1,41 @@
+package extractor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte(`{"content":{"full_text":"UNITED STATES DISTRICT COURT"}}`)
+
+	for _, mode := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(mode, func(t *testing.T) {
+			var compressed bytes.Buffer
+			w, err := compressWriter(&compressed, mode)
+			if err != nil {
+				t.Fatalf("compressWriter() error = %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "doc.extracted.json"+compressionSuffixes[mode])
+			if err := os.WriteFile(path, compressed.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadOutput(path)
+			if err != nil {
+				t.Fatalf("ReadOutput() error = %v", err)
+			}
+			if string(got) != string(data) {
+				t.Errorf("ReadOutput() = %q, want %q", got, data)
+			}
+		})
+	}
+}
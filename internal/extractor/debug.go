@@ -0,0 +1,124 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// DebugDir returns the directory DumpDebug writes to for filePath:
+// {name}.debug/ next to the document
+func DebugDir(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".debug"
+}
+
+// DumpDebug writes the raw (decoded) content stream and font map of every
+// page of a PDF into DebugDir(filePath), to diagnose pages that extract as
+// garbage. It works even when text extraction itself fails.
+func (e *Extractor) DumpDebug(filePath string) (dir string, err error) {
+	if strings.ToLower(filepath.Ext(filePath)) != ".pdf" {
+		return "", fmt.Errorf("debug dump is only supported for PDF files")
+	}
+
+	reader, file, err := e.openPDF(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open document: %w", err)
+	}
+	defer file.Close()
+
+	// The PDF library panics on some malformed objects
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to dump document: %v", r)
+		}
+	}()
+
+	dir = DebugDir(filePath)
+	if err := e.perms.MkdirAll(dir); err != nil {
+		return "", fmt.Errorf("failed to create debug directory: %w", err)
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		prefix := filepath.Join(dir, fmt.Sprintf("page-%04d", i))
+		if err := e.perms.WriteFile(prefix+".content", pageContent(page)); err != nil {
+			return dir, fmt.Errorf("failed to write content stream for page %d: %w", i, err)
+		}
+		if err := e.perms.WriteFile(prefix+".fonts.txt", []byte(fontMap(page))); err != nil {
+			return dir, fmt.Errorf("failed to write font map for page %d: %w", i, err)
+		}
+	}
+	return dir, nil
+}
+
+// pageContent returns the decoded content stream(s) of a page. Pages may
+// split their content over an array of streams, which are concatenated.
+func pageContent(page pdf.Page) []byte {
+	contents := page.V.Key("Contents")
+	var streams []pdf.Value
+	if contents.Kind() == pdf.Array {
+		for i := 0; i < contents.Len(); i++ {
+			streams = append(streams, contents.Index(i))
+		}
+	} else if contents.Kind() == pdf.Stream {
+		streams = append(streams, contents)
+	}
+
+	var out []byte
+	for i, stream := range streams {
+		if i > 0 {
+			out = append(out, '\n')
+		}
+		rc := stream.Reader()
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			out = append(out, fmt.Sprintf("%% error decoding stream %d: %v\n", i, err)...)
+		}
+		out = append(out, data...)
+	}
+	return out
+}
+
+// fontMap describes the fonts a page uses, including the encoding and
+// ToUnicode information that decides whether its text decodes correctly
+func fontMap(page pdf.Page) string {
+	var b strings.Builder
+	fonts := page.Fonts()
+	if len(fonts) == 0 {
+		b.WriteString("no fonts\n")
+	}
+	for _, name := range fonts {
+		font := page.Font(name)
+		fmt.Fprintf(&b, "/%s\n", name)
+		fmt.Fprintf(&b, "  BaseFont:  %s\n", font.BaseFont())
+		fmt.Fprintf(&b, "  Subtype:   %s\n", font.V.Key("Subtype").Name())
+		fmt.Fprintf(&b, "  Encoding:  %s\n", font.V.Key("Encoding"))
+		fmt.Fprintf(&b, "  FirstChar: %d, LastChar: %d, Widths: %d\n", font.FirstChar(), font.LastChar(), len(font.Widths()))
+
+		toUnicode := font.V.Key("ToUnicode")
+		if toUnicode.IsNull() {
+			b.WriteString("  ToUnicode: none\n")
+			continue
+		}
+		rc := toUnicode.Reader()
+		cmap, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			fmt.Fprintf(&b, "  ToUnicode: error decoding: %v\n", err)
+			continue
+		}
+		b.WriteString("  ToUnicode:\n")
+		for _, line := range strings.Split(strings.TrimSpace(string(cmap)), "\n") {
+			b.WriteString("    " + line + "\n")
+		}
+	}
+	return b.String()
+}
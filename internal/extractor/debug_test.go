@@ -0,0 +1,124 @@
+package extractor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugDir(t *testing.T) {
+	if got, want := DebugDir(filepath.Join("docs", "EFTA00010724.pdf")), filepath.Join("docs", "EFTA00010724.debug"); got != want {
+		t.Errorf("DebugDir() = %q, want %q", got, want)
+	}
+}
+
+func TestDumpDebug(t *testing.T) {
+	stream := func(content string) string {
+		return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+	}
+	cmap := "begincmap\n1 beginbfchar\n<41> <0042>\nendbfchar\nendcmap"
+
+	tests := []struct {
+		name    string
+		file    string
+		write   func(t *testing.T, path string)
+		want    map[string][]string // file in the debug directory -> substrings
+		wantErr string
+	}{
+		{
+			name:  "text page",
+			file:  "EFTA00010724.pdf",
+			write: func(t *testing.T, path string) { writeTextPDF(t, path, "Flight log") },
+			want: map[string][]string{
+				"page-0001.content":   {"BT /F1 12 Tf", "(Flight log) Tj"},
+				"page-0001.fonts.txt": {"/F1\n", "BaseFont:  Helvetica", "Subtype:   Type1", "ToUnicode: none"},
+			},
+		},
+		{
+			name: "split content and a ToUnicode map",
+			file: "EFTA00010725.pdf",
+			write: func(t *testing.T, path string) {
+				writeObjectsPDF(t, path, []string{
+					"<< /Type /Catalog /Pages 2 0 R >>",
+					"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+					"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents [4 0 R 5 0 R] /Resources << /Font << /F1 6 0 R >> >> >>",
+					stream("BT /F1 12 Tf"),
+					stream("72 720 Td (A) Tj ET"),
+					"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /ToUnicode 7 0 R >>",
+					stream(cmap),
+				})
+			},
+			want: map[string][]string{
+				"page-0001.content":   {"BT /F1 12 Tf\n72 720 Td (A) Tj ET"},
+				"page-0001.fonts.txt": {"ToUnicode:\n    begincmap", "    <41> <0042>"},
+			},
+		},
+		{
+			name: "page without fonts",
+			file: "EFTA00010726.pdf",
+			write: func(t *testing.T, path string) {
+				writeObjectsPDF(t, path, []string{
+					"<< /Type /Catalog /Pages 2 0 R >>",
+					"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+					"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+					stream("0 0 612 792 re f"),
+				})
+			},
+			want: map[string][]string{
+				"page-0001.content":   {"0 0 612 792 re f"},
+				"page-0001.fonts.txt": {"no fonts"},
+			},
+		},
+		{
+			name: "not a PDF",
+			file: "EFTA00010727.txt",
+			write: func(t *testing.T, path string) {
+				os.WriteFile(path, []byte("Flight log"), 0644)
+			},
+			wantErr: "only supported for PDF files",
+		},
+		{
+			name: "corrupt PDF",
+			file: "EFTA00010728.pdf",
+			write: func(t *testing.T, path string) {
+				os.WriteFile(path, []byte("%PDF-1.4\ngarbage"), 0644)
+			},
+			wantErr: "failed to open document",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			tt.write(t, path)
+			dir, err := New().DumpDebug(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DumpDebug() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(DebugDir(path)); !os.IsNotExist(err) {
+					t.Errorf("DumpDebug() created %s for a document it cannot dump", DebugDir(path))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DumpDebug() error = %v", err)
+			}
+			if dir != DebugDir(path) {
+				t.Errorf("DumpDebug() dir = %q, want %q", dir, DebugDir(path))
+			}
+			for name, substrings := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, s := range substrings {
+					if !strings.Contains(string(data), s) {
+						t.Errorf("%s = %q, want it to contain %q", name, data, s)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Page change statuses of a PageDiff
+const (
+	PageAdded   = "added"
+	PageRemoved = "removed"
+	PageChanged = "changed"
+)
+
+// maxDiffLines bounds the lines of a page compared line by line; longer
+// pages are reported as replaced wholesale
+const maxDiffLines = 5000
+
+// diffContext is how many unchanged lines are shown around each change
+const diffContext = 2
+
+// PageDiff is how the text of one page differs between two extractions
+type PageDiff struct {
+	Page   int
+	Status string     // PageAdded, PageRemoved or PageChanged
+	Lines  []DiffLine // the changed lines with some context
+}
+
+// DiffLine is a line of a page diff
+type DiffLine struct {
+	Op   byte // '-' removed, '+' added, ' ' unchanged context, 0 for a gap between changes
+	Text string
+}
+
+// DiffExtractions compares the page text of two extractions of a document,
+// page by page, and returns the pages whose text changed, in page order
+func DiffExtractions(old, new *ExtractedText) []PageDiff {
+	oldPages := pagesByNumber(old)
+	newPages := pagesByNumber(new)
+	last := 0
+	for number := range oldPages {
+		last = max(last, number)
+	}
+	for number := range newPages {
+		last = max(last, number)
+	}
+
+	var diffs []PageDiff
+	for number := 1; number <= last; number++ {
+		before, hadPage := oldPages[number]
+		after, hasPage := newPages[number]
+		switch {
+		case !hadPage && !hasPage:
+			continue
+		case !hadPage:
+			diffs = append(diffs, PageDiff{Page: number, Status: PageAdded, Lines: prefixed('+', splitLines(after))})
+		case !hasPage:
+			diffs = append(diffs, PageDiff{Page: number, Status: PageRemoved, Lines: prefixed('-', splitLines(before))})
+		case before != after:
+			diffs = append(diffs, PageDiff{Page: number, Status: PageChanged, Lines: diffLines(splitLines(before), splitLines(after))})
+		}
+	}
+	return diffs
+}
+
+// pagesByNumber returns the text of each page of an extraction that has any
+func pagesByNumber(extracted *ExtractedText) map[int]string {
+	pages := make(map[int]string, len(extracted.Content.Pages))
+	for _, page := range extracted.Content.Pages {
+		if text := page.LineText(); strings.TrimSpace(text) != "" {
+			pages[page.PageNumber] = text
+		}
+	}
+	return pages
+}
+
+// splitLines splits page text into lines, ignoring trailing whitespace
+func splitLines(text string) []string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return lines
+}
+
+// prefixed returns lines as diff lines of op
+func prefixed(op byte, lines []string) []DiffLine {
+	out := make([]DiffLine, len(lines))
+	for i, line := range lines {
+		out[i] = DiffLine{Op: op, Text: line}
+	}
+	return out
+}
+
+// diffLines returns the line diff of a and b from their longest common
+// subsequence, keeping diffContext unchanged lines around each change
+func diffLines(a, b []string) []DiffLine {
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		return append(prefixed('-', a), prefixed('+', b)...)
+	}
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var all []DiffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			all = append(all, DiffLine{Op: ' ', Text: a[i]})
+			i++
+			j++
+		// Removals come before the additions replacing them
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			all = append(all, DiffLine{Op: '-', Text: a[i]})
+			i++
+		default:
+			all = append(all, DiffLine{Op: '+', Text: b[j]})
+			j++
+		}
+	}
+
+	// Keep the changes and their context, marking the gaps between them
+	keep := make([]bool, len(all))
+	for k, line := range all {
+		if line.Op != ' ' {
+			for c := max(0, k-diffContext); c <= min(len(all)-1, k+diffContext); c++ {
+				keep[c] = true
+			}
+		}
+	}
+	var out []DiffLine
+	for k, line := range all {
+		if !keep[k] {
+			continue
+		}
+		if len(out) > 0 && !keep[k-1] {
+			out = append(out, DiffLine{})
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// DiffPath returns where the page diff report of the output at path is
+// written: next to it, as name.extracted.diff.md
+func DiffPath(path string) string {
+	trimmed := strings.TrimSuffix(path, compressionSuffix(path))
+	return strings.TrimSuffix(trimmed, ".json") + ".diff.md"
+}
+
+// PageDiffReport compares out with the latest output already written at
+// path and returns a Markdown report of the pages whose text changed, for
+// reviewing a re-extraction. It returns nil when out is not a JSON output,
+// nothing was written at path before, or no page changed.
+func (out *Output) PageDiffReport(path string) ([]byte, error) {
+	if out.JSON == nil || !strings.HasSuffix(strings.TrimSuffix(path, compressionSuffix(path)), ".json") {
+		return nil, nil
+	}
+	previous, n := LatestVersion(path)
+	if n == 0 {
+		return nil, nil
+	}
+	old, err := ReadExtracted(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous extraction %s: %w", previous, err)
+	}
+	diffs := DiffExtractions(old, out.JSON)
+	if len(diffs) == 0 {
+		return nil, nil
+	}
+	return FormatPageDiff(out.JSON.Metadata.Filename, old, out.JSON, diffs), nil
+}
+
+// FormatPageDiff formats page diffs as a Markdown report, one section per
+// changed page holding a diff block
+func FormatPageDiff(filename string, old, new *ExtractedText, diffs []PageDiff) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Extraction changes: %s\n\n", filename)
+	fmt.Fprintf(&b, "Previous extraction: %s  \n", old.Metadata.ExtractedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&b, "New extraction: %s\n\n", new.Metadata.ExtractedAt.Format("2006-01-02 15:04:05 MST"))
+	counts := map[string]int{}
+	for _, d := range diffs {
+		counts[d.Status]++
+	}
+	fmt.Fprintf(&b, "%d page(s) changed, %d added, %d removed.\n", counts[PageChanged], counts[PageAdded], counts[PageRemoved])
+	for _, d := range diffs {
+		fmt.Fprintf(&b, "\n## Page %d (%s)\n\n```diff\n", d.Page, d.Status)
+		for _, line := range d.Lines {
+			if line.Op == 0 {
+				b.WriteString("@@\n")
+				continue
+			}
+			fmt.Fprintf(&b, "%c %s\n", line.Op, line.Text)
+		}
+		b.WriteString("```\n")
+	}
+	return []byte(b.String())
+}
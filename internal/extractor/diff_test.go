@@ -0,0 +1,68 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func extractedPages(texts ...string) *ExtractedText {
+	var extracted ExtractedText
+	for i, text := range texts {
+		extracted.Content.Pages = append(extracted.Content.Pages, Page{PageNumber: i + 1, Text: text})
+	}
+	return &extracted
+}
+
+func TestDiffExtractions(t *testing.T) {
+	old := extractedPages("Flight log\nTeterboro\nPalm Beach\nline 4\nline 5\nline 6\nline 7", "unchanged", "dropped")
+	new := extractedPages("Flight log\nTeterboro\nWest Palm Beach\nline 4\nline 5\nline 6\nline 7", "unchanged", "", "new page")
+
+	diffs := DiffExtractions(old, new)
+	if len(diffs) != 3 {
+		t.Fatalf("got %d page diffs, want 3: %+v", len(diffs), diffs)
+	}
+	want := []DiffLine{
+		{' ', "Flight log"}, {' ', "Teterboro"}, {'-', "Palm Beach"}, {'+', "West Palm Beach"}, {' ', "line 4"}, {' ', "line 5"},
+	}
+	if diffs[0].Page != 1 || diffs[0].Status != PageChanged || !reflect.DeepEqual(diffs[0].Lines, want) {
+		t.Errorf("page 1 diff = %+v, want changed %+v", diffs[0], want)
+	}
+	if diffs[1].Page != 3 || diffs[1].Status != PageRemoved {
+		t.Errorf("page 3 diff = %+v, want removed", diffs[1])
+	}
+	if diffs[2].Page != 4 || diffs[2].Status != PageAdded {
+		t.Errorf("page 4 diff = %+v, want added", diffs[2])
+	}
+}
+
+func TestReextractionWritesPageDiff(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "doc.pdf")
+	ext := New()
+
+	writeTextPDF(t, doc, "Flight log")
+	path, err := ext.SaveExtractedText(doc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(DiffPath(path)); !os.IsNotExist(err) {
+		t.Error("a first extraction wrote a page diff report")
+	}
+
+	writeTextPDF(t, doc, "Flight logs")
+	if path, err = ext.SaveExtractedText(doc, ""); err != nil {
+		t.Fatal(err)
+	}
+	report, err := os.ReadFile(DiffPath(path))
+	if err != nil {
+		t.Fatalf("no page diff report: %v", err)
+	}
+	for _, want := range []string{"## Page 1 (changed)", "- Flight log\n", "+ Flight logs\n"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
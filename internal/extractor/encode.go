@@ -0,0 +1,183 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// EncodeJSON writes extracted to w as JSON, one page at a time, so a
+// thousand-page document is never held as a single encoded string. Indented
+// output is byte for byte what json.MarshalIndent(extracted, "", "  ")
+// gives, and compact output what json.Marshal gives.
+func EncodeJSON(w io.Writer, extracted *ExtractedText, compact bool) error {
+	enc := &jsonWriter{w: bufio.NewWriter(w), compact: compact}
+	enc.raw("{")
+	enc.newline(1)
+	enc.field("metadata", extracted.Metadata, 1)
+	enc.raw(",")
+	enc.newline(1)
+	enc.key("content")
+	enc.raw("{")
+	enc.newline(2)
+	enc.field("full_text", extracted.Content.FullText, 2)
+	enc.raw(",")
+	enc.newline(2)
+	enc.key("pages")
+	switch pages := extracted.Content.Pages; {
+	case pages == nil:
+		enc.raw("null")
+	case len(pages) == 0:
+		enc.raw("[]")
+	default:
+		enc.raw("[")
+		for i := range pages {
+			if i > 0 {
+				enc.raw(",")
+			}
+			enc.newline(3)
+			enc.value(&pages[i], 3)
+		}
+		enc.newline(2)
+		enc.raw("]")
+	}
+	enc.newline(1)
+	enc.raw("}")
+	if len(extracted.Shards) > 0 {
+		enc.raw(",")
+		enc.newline(1)
+		enc.field("shards", extracted.Shards, 1)
+	}
+	enc.newline(0)
+	enc.raw("}")
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+// encodeJSON encodes extracted in memory with EncodeJSON, for callers that
+// need the whole output at once
+func encodeJSON(extracted *ExtractedText, compact bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, extracted, compact); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFormatted writes out uncompressed to w, encoding JSON outputs as it
+// goes. A sharded output is written whole.
+func (out *Output) WriteFormatted(w io.Writer) error {
+	if out.JSON != nil {
+		return EncodeJSON(w, out.JSON, out.compact)
+	}
+	_, err := w.Write(out.Formatted)
+	return err
+}
+
+// WriteContent writes the stored form of out to w: compressed if the
+// extractor compresses outputs, and for a sharded output, the shard index
+func (out *Output) WriteContent(w io.Writer) error {
+	cw, err := compressWriter(w, out.compression)
+	if err != nil {
+		return err
+	}
+	if out.index != nil {
+		err = EncodeJSON(cw, out.index, out.compact)
+	} else {
+		err = out.WriteFormatted(cw)
+	}
+	if err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+// WriteFile streams the stored form of out into a file at path, replacing it
+// only once the whole output is written
+func (out *Output) WriteFile(path string, perms pathutil.Permissions) error {
+	f, err := perms.CreateAtomic(path)
+	if err != nil {
+		return err
+	}
+	if err := out.WriteContent(f); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// size returns the length of out written uncompressed
+func (out *Output) size() (int64, error) {
+	var n byteCounter
+	err := out.WriteFormatted(&n)
+	return int64(n), err
+}
+
+// byteCounter is an io.Writer counting the bytes written to it
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// jsonWriter writes the parts of a JSON document, keeping the first error
+type jsonWriter struct {
+	w       *bufio.Writer
+	compact bool
+	err     error
+}
+
+// raw writes s as is
+func (j *jsonWriter) raw(s string) {
+	if j.err == nil {
+		_, j.err = j.w.WriteString(s)
+	}
+}
+
+// newline starts a new line indented depth levels, unless the output is
+// compact
+func (j *jsonWriter) newline(depth int) {
+	if j.compact {
+		return
+	}
+	j.raw("\n" + strings.Repeat("  ", depth))
+}
+
+// key writes an object key and its colon
+func (j *jsonWriter) key(name string) {
+	if j.compact {
+		j.raw(`"` + name + `":`)
+	} else {
+		j.raw(`"` + name + `": `)
+	}
+}
+
+// field writes an object key and its value, nested depth levels deep
+func (j *jsonWriter) field(name string, v any, depth int) {
+	j.key(name)
+	j.value(v, depth)
+}
+
+// value encodes v nested depth levels deep
+func (j *jsonWriter) value(v any, depth int) {
+	if j.err != nil {
+		return
+	}
+	var encoded []byte
+	if j.compact {
+		encoded, j.err = json.Marshal(v)
+	} else {
+		encoded, j.err = json.MarshalIndent(v, strings.Repeat("  ", depth), "  ")
+	}
+	if j.err == nil {
+		_, j.err = j.w.Write(encoded)
+	}
+}
@@ -0,0 +1,78 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	extracted := newExtractedText("exhibit.pdf", []PageText{
+		{PageNumber: 1, Text: "Case 1:15-cv-07433 <sealed> & \"quoted\""},
+		{PageNumber: 2, Text: "Second page", Lines: []Line{{Text: "Second page", Y: 700, Position: "upper"}}},
+	}, "Case 1:15-cv-07433\n\nSecond page")
+	extracted.Metadata.ExtractedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	extracted.Metadata.Case = &legal.CaseInfo{CaseNumbers: []string{"1:15-cv-07433"}}
+	index := ExtractedText{Metadata: extracted.Metadata, Content: Content{Pages: []Page{}}, Shards: []Shard{{Number: 1, FirstPage: 1, LastPage: 2}}}
+	var empty ExtractedText
+
+	for name, doc := range map[string]*ExtractedText{"document": &extracted, "shard index": &index, "nil pages": &empty} {
+		indented, _ := json.MarshalIndent(doc, "", "  ")
+		compact, _ := json.Marshal(doc)
+		for _, tt := range []struct {
+			compact bool
+			want    []byte
+		}{{false, indented}, {true, compact}} {
+			var buf bytes.Buffer
+			if err := EncodeJSON(&buf, doc, tt.compact); err != nil {
+				t.Fatalf("%s: EncodeJSON() error = %v", name, err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("%s: EncodeJSON(compact=%v) =\n%s\nwant\n%s", name, tt.compact, buf.Bytes(), tt.want)
+			}
+		}
+	}
+}
+
+// formattedOutput returns what out writes uncompressed
+func formattedOutput(t *testing.T, out *Output) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := out.WriteFormatted(&buf); err != nil {
+		t.Fatalf("WriteFormatted() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestOutputWriteFile(t *testing.T) {
+	extracted := newExtractedText("exhibit.pdf", []PageText{{PageNumber: 1, Text: "Flight log"}}, "Flight log")
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		dir := t.TempDir()
+		e := NewWithOptions(Options{Compression: compression, CompactJSON: true})
+		docPath := filepath.Join(dir, "exhibit.pdf")
+		out, err := e.renderJSON(docPath, e.OutputPath(docPath), &extracted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := out.WriteFile(out.Path, pathutil.Permissions{}); err != nil {
+			t.Fatalf("%q: WriteFile() error = %v", compression, err)
+		}
+		stored, err := ReadOutput(out.Path)
+		if err != nil {
+			t.Fatalf("%q: ReadOutput() error = %v", compression, err)
+		}
+		if want, _ := json.Marshal(extracted); !bytes.Equal(stored, want) {
+			t.Errorf("%q: stored %s, want %s", compression, stored, want)
+		}
+		// Nothing but the output is left in the directory
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("%q: %d files written, want 1", compression, len(entries))
+		}
+	}
+}
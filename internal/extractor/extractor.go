@@ -1,19 +1,118 @@
 // Package extractor provides document text extraction functionality.
-// Currently supports PDF files, with plans to support doc, docx, rtf, txt, and other formats.
+// Currently supports PDF files and JPEG and TIFF images, with plans to support doc, docx, rtf, txt, and other formats.
 package extractor
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/email"
+	"defornicate-epstein-files/internal/exif"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/scratch"
 )
 
 // Extractor handles document text extraction
 type Extractor struct {
 	outputFormat string // "json", "markdown", or "plain"
+	compression  string // "", "gzip" or "zstd"
+	perms        pathutil.Permissions
+	fallbacks    []Fallback
+	imageOCR     bool
+	mergeOCR     bool
+	stripNumbers bool
+	boilerplate  bool
+	scratch      *scratch.Dir
+	readers      *ReaderCache
+	tikaURL      string // Tika server for formats without a native extractor, "" for none
+
+	// maxOutputSize is the size above which JSON outputs are sharded, 0 for
+	// no limit
+	maxOutputSize int64
+	// writeOnce writes new versions of existing outputs instead of
+	// replacing them
+	writeOnce bool
+	// reproducible stamps outputs from the document instead of the clock
+	reproducible bool
+	compactJSON  bool // write JSON outputs without indentation
+	citations    bool         // find legal citations in outputs
+	progress     ProgressFunc // called as pages are extracted, nil for none
+	warn         WarnFunc     // called with problems that did not stop an extraction, nil for none
+}
+
+// WarnFunc is called with a problem that did not stop the extraction of a
+// document, such as a fallback backend failing on some of its pages
+type WarnFunc func(filePath string, err error)
+
+// ProgressFunc is called as the pages of a document are extracted, with the
+// number of pages done and the number being extracted. An Extractor used
+// from several goroutines calls it from each of them, so it must be safe for
+// concurrent use; filePath tells the documents apart.
+type ProgressFunc func(filePath string, done, total int)
+
+// Options configures an Extractor
+type Options struct {
+	Format      string               // "json" (default), "markdown" or "plain"
+	Compression string               // "" (none, default), "gzip" or "zstd"
+	Permissions pathutil.Permissions // modes for extraction output files
+	Fallbacks   []Fallback           // backends tried, in order, on pages the native extraction did poorly on
+	ImageOCR    bool                 // OCR images embedded in text pages into PageText.ImageText, and image documents (needs pdfimages and tesseract)
+	// MergeOCR also merges ImageText into the page text line by line,
+	// keeping whichever of the text layer and OCR reads better, and records
+	// each decision in PageText.Merge (needs ImageOCR)
+	MergeOCR bool
+	// StripLineNumbers removes the 1-25 margin line numbers of transcript
+	// pages from the text, recording each on its Line
+	StripLineNumbers bool
+	// StripBoilerplate removes headers, footers and stamps repeated at the
+	// top or bottom of many pages from the page text, recording them in
+	// PageText.Boilerplate
+	StripBoilerplate bool
+	// Scratch holds the images extracted for ImageOCR (default: the system
+	// temporary directory)
+	Scratch *scratch.Dir
+	// Readers keeps parsed PDFs in memory for documents opened more than
+	// once (default: every open parses the file)
+	Readers *ReaderCache
+	// MaxOutputSize splits JSON outputs larger than this many bytes
+	// (uncompressed) into shards of about that size, tied together by an
+	// index at the usual output path (default: no limit)
+	MaxOutputSize int64
+	// WriteOnce never replaces an existing output: a run producing a
+	// different extraction writes it as the next version
+	// (name.extracted.v2.json, ...), and one producing the same extraction
+	// writes nothing. Readers use the latest version.
+	WriteOnce bool
+	// FindCitations lists the legal citations of each page (see
+	// legal.FindCitations), collected with their pages in
+	// metadata.citations of JSON outputs
+	FindCitations bool
+	// Reproducible makes outputs depend only on the document: they are
+	// stamped with $SOURCE_DATE_EPOCH or the document's modification time
+	// instead of the time of extraction, and record its checksum, so
+	// extracting the same file twice gives byte-identical outputs
+	Reproducible bool
+	// CompactJSON writes JSON outputs without indentation or line breaks,
+	// for smaller files (default: indented)
+	CompactJSON bool
+	// TikaURL is the base URL of an Apache Tika server (e.g.
+	// "http://localhost:9998") that extracts the formats without a native
+	// extractor, and PDFs the native extraction gets no text from (default:
+	// none; everything is extracted locally)
+	TikaURL string
+	// Progress, if set, is called after each page of a PDF is extracted, and
+	// once when an image or a document handed to Tika is done, for progress
+	// displays
+	Progress ProgressFunc
+	// Warn, if set, is called when a fallback backend fails on some pages of
+	// a document whose other pages were extracted
+	Warn WarnFunc
 }
 
 // New creates a new Extractor instance with default JSON format
@@ -27,12 +126,49 @@ func New() *Extractor {
 // Valid formats: "json", "markdown", "plain"
 // This function is available for future use when format selection is needed.
 func NewWithFormat(format string) *Extractor {
+	return NewWithOptions(Options{Format: format})
+}
+
+// NewWithOptions creates a new Extractor instance with the given options.
+// An empty or invalid format defaults to JSON, and an invalid compression to
+// uncompressed output.
+func NewWithOptions(opts Options) *Extractor {
 	validFormats := map[string]bool{"json": true, "markdown": true, "plain": true}
+	format := opts.Format
 	if !validFormats[format] {
 		format = "json" // Default to JSON if invalid
 	}
+	compression := opts.Compression
+	if !ValidCompression(compression) {
+		compression = CompressionNone
+	}
 	return &Extractor{
 		outputFormat: format,
+		compression:  compression,
+		perms:        opts.Permissions,
+		fallbacks:    opts.Fallbacks,
+		imageOCR:     opts.ImageOCR,
+		mergeOCR:     opts.ImageOCR && opts.MergeOCR,
+		stripNumbers: opts.StripLineNumbers,
+		boilerplate:  opts.StripBoilerplate,
+		scratch:      opts.Scratch,
+		readers:      opts.Readers,
+		tikaURL:      opts.TikaURL,
+
+		maxOutputSize: opts.MaxOutputSize,
+		writeOnce:     opts.WriteOnce,
+		reproducible:  opts.Reproducible,
+		compactJSON:   opts.CompactJSON,
+		citations:     opts.FindCitations,
+		progress:      opts.Progress,
+		warn:          opts.Warn,
+	}
+}
+
+// reportProgress calls the ProgressFunc, if there is one
+func (e *Extractor) reportProgress(filePath string, done, total int) {
+	if e.progress != nil {
+		e.progress(filePath, done, total)
 	}
 }
 
@@ -52,134 +188,433 @@ func (e *Extractor) ExtractTextStructured(filePath string) ([]PageText, string,
 	// Determine file type and extract accordingly
 	ext := strings.ToLower(filepath.Ext(filePath))
 	
-	// Currently only PDF is supported, but structure is ready for other formats
+	// PDFs and images are extracted natively; Tika, when configured, takes
+	// the other formats and PDFs that yield no text
 	if ext == ".pdf" {
-		return e.extractFromPDF(filePath)
+		pages, fullText, totalPages, err := e.extractFromPDF(filePath)
+		if err != nil && e.tikaURL != "" {
+			tikaPages, tikaText, tikaTotal, tikaErr := e.extractWithTika(filePath)
+			if tikaErr == nil {
+				e.reportProgress(filePath, tikaTotal, tikaTotal)
+				return tikaPages, tikaText, tikaTotal, nil
+			}
+			err = fmt.Errorf("%w; %v", err, tikaErr)
+		}
+		return pages, fullText, totalPages, err
 	}
-	
-	// For other file types, return error (to be implemented)
-	return nil, "", 0, fmt.Errorf("file type %s not yet supported (currently only PDF is supported)", ext)
+	var extract func(string) ([]PageText, string, int, error)
+	switch {
+	case exif.IsImage(filePath):
+		extract = e.extractFromImage
+	case email.IsMessage(filePath):
+		extract = e.extractFromEmail
+	case IsMailbox(filePath):
+		extract = e.extractFromMailbox
+	case e.Supports(filePath):
+		extract = e.extractWithTika
+	}
+	if extract != nil {
+		pages, fullText, totalPages, err := extract(filePath)
+		if err == nil {
+			e.reportProgress(filePath, totalPages, totalPages)
+		}
+		return pages, fullText, totalPages, err
+	}
+
+	return nil, "", 0, fmt.Errorf("file type %s not supported (PDF, JPEG/TIFF images, .eml messages and PST archives are, and other formats with tika_url configured)", ext)
 }
 
 // extractFromPDF extracts text from a PDF file
 func (e *Extractor) extractFromPDF(filePath string) ([]PageText, string, int, error) {
+	pages, totalPages, fallbackErr, err := e.extractPDFPages(filePath, nil)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	fullText := joinFullText(pages)
+	if fullText == "" {
+		if fallbackErr != nil {
+			return nil, "", 0, fmt.Errorf("no text could be extracted from the document (fallback failed: %v)", fallbackErr)
+		}
+		return nil, "", 0, fmt.Errorf("no text could be extracted from the document (document may be encrypted, image-based, or in an unsupported format)")
+	}
+	if fallbackErr != nil && e.warn != nil {
+		e.warn(filePath, fallbackErr)
+	}
+
+	return pages, fullText, totalPages, nil
+}
+
+// extractPDFPages extracts the text of the given pages of a PDF file, or of
+// all of them if numbers is nil, returning the pages with text (and blank
+// ones), the document's page count and the last error of a fallback backend
+func (e *Extractor) extractPDFPages(filePath string, numbers []int) ([]PageText, int, error, error) {
 	// Read PDF file
-	file, reader, err := pdf.Open(filePath)
+	reader, file, err := e.openPDF(filePath)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("failed to open document: %w (document may be encrypted or in an unsupported format)", err)
+		return nil, 0, nil, fmt.Errorf("failed to open document: %w (document may be encrypted or in an unsupported format)", err)
 	}
 	defer file.Close()
 
-	var textBuilder strings.Builder
 	var pages []PageText
+	imagePages := make(map[int]bool) // text pages that also draw images
+	blankPages := make(map[int]bool) // pages with no ink beyond negligible text
 	totalPages := reader.NumPage()
 
 	if totalPages == 0 {
-		return nil, "", 0, fmt.Errorf("document has no pages")
+		return nil, 0, nil, fmt.Errorf("document has no pages")
+	}
+	if numbers == nil {
+		numbers = make([]int, totalPages)
+		for i := range numbers {
+			numbers[i] = i + 1
+		}
 	}
 
 	// Extract text from each page
-	for i := 1; i <= totalPages; i++ {
-		page := reader.Page(i)
-		if page.V.IsNull() {
-			// Skip null pages silently
+	for n, i := range numbers {
+		// Report the pages done before each page (and all of them after the
+		// loop), as pages without text are skipped with continue
+		if n > 0 {
+			e.reportProgress(filePath, n, len(numbers))
+		}
+		page, blank, images, ok := e.nativePage(reader, i)
+		if !ok {
 			continue
 		}
+		if blank {
+			blankPages[i] = true
+		}
+		if page.Text != "" {
+			pages = append(pages, page)
+			if images {
+				imagePages[i] = true
+			}
+		}
+	}
 
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			// Try to continue with other pages
-			continue
+	e.reportProgress(filePath, len(numbers), len(numbers))
+
+	pages, fallbackErr := e.finishPages(filePath, pages, numbers, imagePages, blankPages)
+	if e.boilerplate {
+		pages = stripBoilerplate(pages)
+	}
+	return pages, totalPages, fallbackErr, nil
+}
+
+// nativePage extracts page i of a PDF with the built-in extractor. ok is
+// false for a page that cannot be read; a page without text has no Text.
+// blank reports a page with no ink beyond negligible text, and images a page
+// with text that also draws images to OCR.
+func (e *Extractor) nativePage(reader *pdf.Reader, i int) (pt PageText, blank, images, ok bool) {
+	page := reader.Page(i)
+	if page.V.IsNull() {
+		// Skip null pages silently
+		return PageText{}, false, false, false
+	}
+
+	text, err := page.GetPlainText(nil)
+	if err != nil {
+		// Try to continue with other pages
+		return PageText{}, false, false, false
+	}
+	blank = negligibleText(text) && !pageHasInk(page, text != "")
+	if text == "" {
+		return PageText{}, blank, false, true
+	}
+
+	// Store page text along with where each line sits on the page
+	rotation := pageRotation(page)
+	width, height := pageSize(page, rotation)
+	lines := pageLines(page, text)
+	if needsLayout(rotation, width, height) {
+		// Read rotated and landscape pages as displayed
+		if laidOut, laidOutLines := layoutText(page, rotation); laidOut != "" {
+			text, lines = laidOut, laidOutLines
 		}
+	}
+	pt = PageText{
+		PageNumber: i,
+		Text:       text,
+		Lines:      lines,
+		Rotation:   rotation,
+		Width:      width,
+		Height:     height,
+		Backend:    BackendNative,
+	}
+	return pt, blank, e.imageOCR && hasImages(page), true
+}
 
-		if text != "" {
-			// Add page separator for multi-page documents in plain text
-			if i > 1 {
-				textBuilder.WriteString(fmt.Sprintf("\n\n--- Page %d ---\n\n", i))
-			}
-			textBuilder.WriteString(text)
-			
-			// Store page text
-			pages = append(pages, PageText{
-				PageNumber: i,
-				Text:       text,
-			})
+// finishPages applies what follows the native extraction of the given pages
+// of a PDF, page by page: fallback backends, image OCR, merging and line
+// number stripping, and blank page marking. It returns the pages with text
+// (and blank ones) and the last error of a fallback backend.
+func (e *Extractor) finishPages(filePath string, pages []PageText, numbers []int, imagePages, blankPages map[int]bool) ([]PageText, error) {
+	// Give pages the native extraction did poorly on to the fallback backends
+	pages, fallbackErr := e.applyFallbacks(filePath, pages, numbers)
+
+	// Photocopies and scans pasted into otherwise-text pages carry text the
+	// native extraction cannot see
+	applyImageOCR(filePath, pages, imagePages, e.scratch)
+	if e.mergeOCR {
+		for i := range pages {
+			pages[i] = mergeOCR(pages[i])
 		}
 	}
 
-	fullText := textBuilder.String()
-	if fullText == "" {
-		return nil, "", 0, fmt.Errorf("no text could be extracted from the document (document may be encrypted, image-based, or in an unsupported format)")
+	if e.stripNumbers {
+		for i := range pages {
+			pages[i] = stripLineNumbers(pages[i])
+		}
 	}
 
-	return pages, fullText, totalPages, nil
+	// Releases pad documents with blank separator pages
+	return markBlankPages(pages, blankPages), fallbackErr
 }
 
-// SaveExtractedText saves extracted text to a file next to the document
-func (e *Extractor) SaveExtractedText(filePath string, text string) (string, error) {
-	// Get structured data for formatting
-	pages, fullText, _, err := e.ExtractTextStructured(filePath)
-	if err != nil {
-		// Fall back to plain text if structured extraction fails
-		return e.savePlainText(filePath, text)
+// openPDF parses a PDF, through the reader cache if there is one
+func (e *Extractor) openPDF(filePath string) (*pdf.Reader, io.Closer, error) {
+	if e.readers != nil {
+		return e.readers.Open(filePath)
+	}
+	file, reader, err := pdf.Open(filePath)
+	return reader, file, err
+}
+
+// joinFullText concatenates page text, separating pages after the first
+// with a "--- Page N ---" marker
+func joinFullText(pages []PageText) string {
+	var textBuilder strings.Builder
+	for _, page := range pages {
+		if page.Text == "" {
+			continue // a blank page with nothing to show
+		}
+		// Add page separator for multi-page documents in plain text
+		if page.PageNumber > 1 {
+			textBuilder.WriteString(fmt.Sprintf("\n\n--- Page %d ---\n\n", page.PageNumber))
+		}
+		textBuilder.WriteString(page.Text)
 	}
+	return textBuilder.String()
+}
 
-	// Get the directory and base filename of the document
+// OutputPath returns the path the extraction output for filePath is written to
+// in the extractor's current output format
+func (e *Extractor) OutputPath(filePath string) string {
 	dir := filepath.Dir(filePath)
 	baseName := filepath.Base(filePath)
-	
+
 	// Remove extension (any extension)
 	ext := filepath.Ext(baseName)
 	baseNameNoExt := strings.TrimSuffix(baseName, ext)
 	baseNameNoExt = strings.TrimSuffix(baseNameNoExt, strings.ToUpper(ext))
-	
-	var extractedPath string
-	var content []byte
-	
+
+	suffix := compressionSuffixes[e.compression]
+	switch e.outputFormat {
+	case "json":
+		return filepath.Join(dir, baseNameNoExt+".extracted.json"+suffix)
+	case "markdown":
+		return filepath.Join(dir, baseNameNoExt+".extracted.md"+suffix)
+	default: // plain
+		return filepath.Join(dir, baseNameNoExt+".extracted.txt"+suffix)
+	}
+}
+
+// IsSupported reports whether the extractor can extract text from filePath:
+// PDFs, JPEG and TIFF images, whose EXIF metadata is extracted, .eml email
+// messages and Outlook PST archives (see UnpackMailbox)
+func IsSupported(filePath string) bool {
+	return strings.ToLower(filepath.Ext(filePath)) == ".pdf" || exif.IsImage(filePath) || email.IsMessage(filePath) || IsMailbox(filePath)
+}
+
+// FindDocuments walks root and returns every supported source document
+func FindDocuments(root string) ([]string, error) {
+	return findDocuments(root, IsSupported)
+}
+
+// FindExtractable walks root and returns every source document the
+// extractor supports, including the formats it hands to Tika
+func (e *Extractor) FindExtractable(root string) ([]string, error) {
+	return findDocuments(root, e.Supports)
+}
+
+// findDocuments walks root and returns every source document supported
+// reports true for
+func findDocuments(root string, supported func(string) bool) ([]string, error) {
+	var docs []string
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		// Nothing has been downloaded yet
+		return docs, nil
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir // snapshots, quarantined downloads
+		}
+		if d.IsDir() || !supported(path) {
+			return nil
+		}
+		// Skip our own outputs (e.g. name.extracted.txt next to a .txt source)
+		if strings.Contains(filepath.Base(path), ".extracted.") {
+			return nil
+		}
+		docs = append(docs, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+	}
+	return docs, nil
+}
+
+// FindPending walks root and returns every supported source document that has
+// no extraction output in the extractor's current output format
+func (e *Extractor) FindPending(root string) ([]string, error) {
+	docs, err := e.FindExtractable(root)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, path := range docs {
+		if e.FindOutput(path) == "" {
+			pending = append(pending, path)
+		}
+	}
+	return pending, nil
+}
+
+// Output is a rendered extraction result, ready to be written by a sink
+type Output struct {
+	Document  string         // path of the source document
+	Path      string         // default location next to the document
+	Formatted []byte         // plain text or Markdown output; nil for JSON outputs
+	JSON      *ExtractedText // JSON output, encoded as it is written
+	Fields    map[string]any // the document's curated metadata fields, for naming the output
+	// Shards are the parts of a JSON output larger than the size limit,
+	// stored next to it; the output stores their index, while JSON remains
+	// the whole document
+	Shards []*Output
+	// WriteOnce keeps existing outputs from being replaced; see Destination
+	WriteOnce bool
+
+	index       *ExtractedText // shard index stored in place of JSON
+	compact     bool           // JSON is encoded without indentation
+	compression string         // compression of the stored form
+}
+
+// SaveExtractedText saves extracted text to a file next to the document
+func (e *Extractor) SaveExtractedText(filePath string, text string) (string, error) {
+	out, err := e.Render(filePath, text)
+	if err != nil {
+		return "", err
+	}
+	return e.write(out)
+}
+
+// SavePages is SaveExtractedText for a document already extracted with
+// ExtractTextStructured, saving its pages without extracting it again
+func (e *Extractor) SavePages(filePath string, pages []PageText, fullText string) (string, error) {
+	out, err := e.RenderPages(filePath, pages, fullText)
+	if err != nil {
+		return "", err
+	}
+	return e.write(out)
+}
+
+// write stores an output and its shards at their default locations, the
+// shards first so the index never points at missing files, and returns the
+// path of the output (a new version of it, for write-once outputs). When it
+// replaces an earlier extraction whose page text differs, a page diff report
+// is written next to it.
+func (e *Extractor) write(out *Output) (string, error) {
+	path, changed, err := out.Destination(out.Path)
+	if err != nil || !changed {
+		return path, err
+	}
+	report, err := out.PageDiffReport(out.Path)
+	if err != nil {
+		return "", err
+	}
+	for i, shard := range out.Shards {
+		if err := shard.WriteFile(ShardPath(path, i+1), e.perms); err != nil {
+			return "", fmt.Errorf("failed to write extracted text file: %w", err)
+		}
+	}
+	if err := out.WriteFile(path, e.perms); err != nil {
+		return "", fmt.Errorf("failed to write extracted text file: %w", err)
+	}
+	if report != nil {
+		if err := e.perms.WriteFile(DiffPath(path), report); err != nil {
+			return "", fmt.Errorf("failed to write page diff report: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// Render formats the extraction output for a document without writing it.
+// If structured extraction fails, text is rendered as plain text.
+func (e *Extractor) Render(filePath string, text string) (*Output, error) {
+	// Get structured data for formatting
+	pages, fullText, _, err := e.ExtractTextStructured(filePath)
+	if err != nil {
+		// Fall back to plain text if structured extraction fails
+		return e.render(filePath, NewWithOptions(Options{Format: "plain", Compression: e.compression}).OutputPath(filePath), []byte(text)), nil
+	}
+	return e.RenderPages(filePath, pages, fullText)
+}
+
+// RenderPages is Render for a document already extracted with
+// ExtractTextStructured, formatting its pages without extracting it again
+func (e *Extractor) RenderPages(filePath string, pages []PageText, fullText string) (*Output, error) {
+	if e.citations {
+		findCitations(pages)
+	}
+
+	st, err := e.stamp(filePath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Format based on output format
 	switch e.outputFormat {
 	case "json":
-		extractedPath = filepath.Join(dir, baseNameNoExt+".extracted.json")
-		content, err = FormatAsJSON(filePath, pages, fullText)
+		extracted, err := buildJSON(filePath, pages, fullText, st)
 		if err != nil {
-			return "", fmt.Errorf("failed to format as JSON: %w", err)
+			return nil, fmt.Errorf("failed to format as JSON: %w", err)
 		}
+		return e.renderJSON(filePath, e.OutputPath(filePath), extracted)
 	case "markdown":
-		extractedPath = filepath.Join(dir, baseNameNoExt+".extracted.md")
-		content, err = FormatAsMarkdown(filePath, pages, fullText)
+		content, err := formatMarkdown(filePath, pages, fullText, st)
 		if err != nil {
-			return "", fmt.Errorf("failed to format as Markdown: %w", err)
+			return nil, fmt.Errorf("failed to format as Markdown: %w", err)
 		}
+		return e.render(filePath, e.OutputPath(filePath), content), nil
 	default: // plain
-		extractedPath = filepath.Join(dir, baseNameNoExt+".extracted.txt")
-		content = []byte(fullText)
-	}
-	
-	// Write the formatted content to the file
-	err = os.WriteFile(extractedPath, content, 0644) // Use DefaultFilePerm constant if we had access to it
-	if err != nil {
-		return "", fmt.Errorf("failed to write extracted text file: %w", err)
+		return e.render(filePath, e.OutputPath(filePath), []byte(fullText)), nil
 	}
-	
-	return extractedPath, nil
 }
 
-// savePlainText saves plain text as fallback
-func (e *Extractor) savePlainText(filePath string, text string) (string, error) {
-	dir := filepath.Dir(filePath)
-	baseName := filepath.Base(filePath)
-	
-	// Remove any extension
-	ext := filepath.Ext(baseName)
-	baseNameNoExt := strings.TrimSuffix(baseName, ext)
-	baseNameNoExt = strings.TrimSuffix(baseNameNoExt, strings.ToUpper(ext))
-	extractedPath := filepath.Join(dir, baseNameNoExt+".extracted.txt")
-	
-	err := os.WriteFile(extractedPath, []byte(text), 0644) // Use DefaultFilePerm constant if we had access to it
+// render makes formatted plain text or Markdown content an Output stored at
+// path
+func (e *Extractor) render(filePath, path string, formatted []byte) *Output {
+	return &Output{Document: filePath, Path: path, Formatted: formatted, WriteOnce: e.writeOnce, compression: e.compression}
+}
+
+// renderJSON makes a JSON document an Output stored at path, splitting it
+// into shards when it is larger than the extractor's size limit
+func (e *Extractor) renderJSON(filePath, path string, extracted *ExtractedText) (*Output, error) {
+	out := &Output{Document: filePath, Path: path, JSON: extracted, WriteOnce: e.writeOnce, compact: e.compactJSON, compression: e.compression}
+	if e.maxOutputSize <= 0 {
+		return out, nil
+	}
+	size, err := out.size()
 	if err != nil {
-		return "", fmt.Errorf("failed to write extracted text file: %w", err)
+		return nil, fmt.Errorf("failed to format as JSON: %w", err)
 	}
-	
-	return extractedPath, nil
+	if size <= e.maxOutputSize || len(extracted.Content.Pages) < 2 {
+		return out, nil // a single page cannot be split
+	}
+	return e.shard(out)
 }
 
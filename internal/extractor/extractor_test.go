@@ -0,0 +1,115 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.pdf")
+	writeTextPDF(t, path, "Flight log")
+
+	type call struct {
+		path        string
+		done, total int
+	}
+	var mu sync.Mutex
+	var calls []call
+	ext := NewWithOptions(Options{Progress: func(filePath string, done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call{filePath, done, total})
+	}})
+	if _, err := ext.ExtractText(path); err != nil {
+		t.Fatalf("ExtractText() error = %v", err)
+	}
+	if len(calls) != 1 || calls[0] != (call{path, 1, 1}) {
+		t.Errorf("progress calls = %+v, want one for page 1 of 1", calls)
+	}
+
+	// A failed extraction reports no completion
+	calls = nil
+	if _, err := ext.ExtractText(filepath.Join(t.TempDir(), "missing.pdf")); err == nil {
+		t.Fatal("ExtractText() of a missing file succeeded")
+	}
+	if len(calls) != 0 {
+		t.Errorf("progress calls for a missing file = %+v, want none", calls)
+	}
+}
+
+func TestLocatePages(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "Page"},
+		{PageNumber: 2, Text: "", Blank: true},
+		{PageNumber: 3, Text: "Page"},
+	}
+	fullText := joinFullText(pages)
+	extracted := newExtractedText("a.pdf", pages, fullText)
+	want := [][2]int{{0, 4}, {-1, -1}, {len(fullText) - 4, len(fullText)}}
+	for i, page := range extracted.Content.Pages {
+		if got := [2]int{page.FullTextStart, page.FullTextEnd}; got != want[i] {
+			t.Errorf("page %d at %v, want %v", page.PageNumber, got, want[i])
+		} else if page.FullTextStart >= 0 && fullText[page.FullTextStart:page.FullTextEnd] != page.Text {
+			t.Errorf("page %d offsets do not hold its text", page.PageNumber)
+		}
+	}
+}
+
+func TestFindPending(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{
+		"pdf/extracted/extracted.pdf",
+		"pdf/extracted/extracted.extracted.txt",
+		"pdf/gzipped/gzipped.pdf",
+		"pdf/gzipped/gzipped.extracted.txt.gz",
+		"pdf/zstd/zstd.pdf",
+		"pdf/zstd/zstd.extracted.txt.zst",
+		"pdf/versioned/versioned.pdf",
+		"pdf/versioned/versioned.extracted.v2.txt",
+		"pdf/missing/missing.pdf",
+		"pdf/other-format/other-format.pdf",
+		"pdf/other-format/other-format.extracted.json",
+		".snapshots/1/pdf/missing/missing.pdf",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		// Outputs count whatever their compression, so switching it does
+		// not make every document pending again
+		{"plain", Options{Format: "plain"}, []string{"pdf/missing/missing.pdf", "pdf/other-format/other-format.pdf"}},
+		{"plain gzipped", Options{Format: "plain", Compression: CompressionGzip}, []string{"pdf/missing/missing.pdf", "pdf/other-format/other-format.pdf"}},
+		{"json", Options{Format: "json"}, []string{"pdf/extracted/extracted.pdf", "pdf/gzipped/gzipped.pdf", "pdf/missing/missing.pdf", "pdf/versioned/versioned.pdf", "pdf/zstd/zstd.pdf"}},
+	} {
+		pending, err := NewWithOptions(tt.opts).FindPending(root)
+		if err != nil {
+			t.Fatalf("FindPending() error = %v", err)
+		}
+		var got []string
+		for _, path := range pending {
+			rel, _ := filepath.Rel(root, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FindPending() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Nothing is pending before anything was downloaded
+	if pending, err := NewWithOptions(Options{}).FindPending(filepath.Join(root, "none")); err != nil || len(pending) != 0 {
+		t.Errorf("FindPending() of a missing tree = %v, %v", pending, err)
+	}
+}
@@ -3,24 +3,45 @@ package extractor
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"defornicate-epstein-files/internal/email"
+	"defornicate-epstein-files/internal/exif"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/tokenize"
 )
 
 // ExtractedText represents the structured format for extracted document text
 type ExtractedText struct {
 	Metadata Metadata `json:"metadata"`
 	Content  Content  `json:"content"`
+	Shards   []Shard  `json:"shards,omitempty"` // set on the index of an output split into shards, whose content is empty
 }
 
 // Metadata contains information about the document and extraction
 type Metadata struct {
-	Filename       string    `json:"filename"`
-	ExtractedAt    time.Time `json:"extracted_at"`
-	TotalPages     int       `json:"total_pages"`
-	PagesExtracted int       `json:"pages_extracted"`
-	FormatVersion  string    `json:"format_version"`
+	Filename       string            `json:"filename"`
+	ExtractedAt    time.Time         `json:"extracted_at"`
+	SourceSHA256   string            `json:"source_sha256,omitempty"` // checksum of the document, recorded by reproducible extractions
+	TotalPages     int               `json:"total_pages"`
+	PagesExtracted int               `json:"pages_extracted"`
+	FormatVersion  string            `json:"format_version"`
+	BlankPages     int               `json:"blank_pages,omitempty"`     // blank pages, not counted in pages_extracted
+	SignedPages    []int             `json:"signed_pages,omitempty"`    // pages with a signature or notarization
+	LandscapePages []int             `json:"landscape_pages,omitempty"` // pages displayed wider than tall
+	Case           *legal.CaseInfo   `json:"case,omitempty"`            // docket numbers, court and caption from the first pages
+	CoverSheet     *legal.CoverSheet `json:"cover_sheet,omitempty"`     // producing party, date and designation from the first page
+	Part           *Segment          `json:"part,omitempty"`            // set on the outputs of a split multi-document file
+	Shard          *Shard            `json:"shard,omitempty"`           // set on the shards of an output split by size
+	Boilerplate    []Boilerplate     `json:"boilerplate,omitempty"`     // headers, footers and stamps removed from the page text
+	Citations      []Citation        `json:"citations,omitempty"`       // legal citations, with find_citations
+	Curated        *meta.Meta        `json:"curated,omitempty"`         // from the meta.yaml next to the document
+	EXIF           *exif.EXIF        `json:"exif,omitempty"`            // camera, timestamps and GPS position of image documents
+	Email          *email.Message    `json:"email,omitempty"`           // headers and threading metadata of email messages
 }
 
 // Content contains the extracted text organized by pages
@@ -31,19 +52,102 @@ type Content struct {
 
 // Page represents text from a single page
 type Page struct {
-	PageNumber int    `json:"page_number"`
-	Text       string `json:"text"`
-	WordCount  int    `json:"word_count"`
+	PageNumber  int               `json:"page_number"`
+	Text        string            `json:"text"`
+	WordCount   int               `json:"word_count"`
+	TokenCount  int               `json:"token_count"`            // words by Unicode word boundaries: lone punctuation is not counted, compounds count once
+	Language    string            `json:"language,omitempty"`     // ISO 639-1 code of the language the page is mostly in, if recognized
+	Rotation    int               `json:"rotation,omitempty"`     // clockwise display rotation: 90, 180 or 270
+	OCRRotation int               `json:"ocr_rotation,omitempty"` // clockwise rotation that turned the scanned page upright for OCR: 90, 180 or 270
+	Skew        float64           `json:"skew,omitempty"`         // estimated tilt of the OCRed text lines, in degrees counterclockwise
+	Width       float64           `json:"width,omitempty"`        // width as displayed, in points
+	Height      float64           `json:"height,omitempty"`       // height as displayed, in points
+	Orientation string            `json:"orientation,omitempty"`  // "portrait" or "landscape", as displayed
+	Backend     string            `json:"backend,omitempty"`      // text extraction backend that produced the page
+	ImageText   string            `json:"image_text,omitempty"`   // OCR text of images embedded in the page
+	Blank       bool              `json:"blank,omitempty"`        // blank or near-blank page, counted as having no words
+	Signatures  []legal.Signature `json:"signatures,omitempty"`   // signature blocks, "/s/" signatures and notarizations
+	OCRMerge    []MergeDecision   `json:"ocr_merge,omitempty"`    // how image_text was merged into text, with merge_ocr
+	Lines       []LineSpan        `json:"lines,omitempty"`
+
+	// FullTextStart and FullTextEnd are the byte offsets of Text within
+	// content.full_text, so matches in the full text can be mapped back to
+	// pages; both are -1 if the page's text is not in it (empty pages)
+	FullTextStart int `json:"full_text_start"`
+	FullTextEnd   int `json:"full_text_end"`
+}
+
+// LineText returns the page text with line breaks restored from its
+// positioned lines where available, since the plain page text can run lines
+// together
+func (p Page) LineText() string {
+	if len(p.Lines) == 0 {
+		return p.Text
+	}
+	lines := make([]string, len(p.Lines))
+	for i, line := range p.Lines {
+		lines[i] = line.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LineSpan locates a line of page text on the source page for citations
+type LineSpan struct {
+	Text     string    `json:"text"`
+	Offset   int       `json:"offset"`                // byte offset within the page text, -1 if unknown
+	Y        float64   `json:"y"`                     // baseline from the bottom of the page, in points
+	Position string    `json:"position"`              // "upper", "middle" or "lower" third of the page
+	Number   int       `json:"line_number,omitempty"` // transcript line number from the margin, if stripped
+	Box      []float64 `json:"box,omitempty"`         // x0, y0, x1, y1 of the line on the page, in points from the bottom-left
 }
 
 // FormatVersion is the current format version
-const FormatVersion = "1.0"
+const FormatVersion = "1.1"
+
+// caseInfoPages is how many leading pages are searched for case information
+const caseInfoPages = 2
 
 // FormatAsJSON formats extracted text as structured JSON
 func FormatAsJSON(filePath string, pages []PageText, fullText string) ([]byte, error) {
+	extracted, err := buildJSON(filePath, pages, fullText, stamp{at: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(extracted, false)
+}
+
+// buildJSON builds the structured JSON document of extracted text stamped
+// with st, with the document's curated metadata and EXIF or email headers
+func buildJSON(filePath string, pages []PageText, fullText string, st stamp) (*ExtractedText, error) {
+	extracted := newExtractedText(filePath, pages, fullText)
+	extracted.Metadata.ExtractedAt, extracted.Metadata.SourceSHA256 = st.at, st.sourceSHA256
+	curated, err := meta.Load(filePath)
+	if err != nil {
+		return nil, err
+	}
+	extracted.Metadata.Curated = curated
+	if exif.IsImage(filePath) {
+		// Damaged EXIF is not worth failing the extraction over
+		extracted.Metadata.EXIF, _ = exif.Read(filePath)
+	}
+	if email.IsMessage(filePath) {
+		extracted.Metadata.Email, _ = email.Read(filePath)
+	}
+	return &extracted, nil
+}
+
+// newExtractedText builds the structured JSON document for the given pages
+func newExtractedText(filePath string, pages []PageText, fullText string) ExtractedText {
 	filename := filepath.Base(filePath)
-	pagesExtracted := len(pages)
-	
+	pagesExtracted, blankPages := 0, 0
+	for _, page := range pages {
+		if page.Blank {
+			blankPages++
+		} else {
+			pagesExtracted++
+		}
+	}
+
 	// Count total pages (may include null pages)
 	totalPages := 0
 	for _, page := range pages {
@@ -59,6 +163,7 @@ func FormatAsJSON(filePath string, pages []PageText, fullText string) ([]byte, e
 			TotalPages:     totalPages,
 			PagesExtracted: pagesExtracted,
 			FormatVersion:  FormatVersion,
+			BlankPages:     blankPages,
 		},
 		Content: Content{
 			FullText: fullText,
@@ -66,27 +171,134 @@ func FormatAsJSON(filePath string, pages []PageText, fullText string) ([]byte, e
 		},
 	}
 
+	// Look for docket numbers and the caption on the first pages
+	if caseInfo := legal.ParseCaseInfo(leadingLines(pages, caseInfoPages)); !caseInfo.IsEmpty() {
+		extracted.Metadata.Case = &caseInfo
+	}
+	extracted.Metadata.CoverSheet = FindCoverSheet(pages)
+	extracted.Metadata.Boilerplate = collectBoilerplate(pages)
+	extracted.Metadata.Citations = collectCitations(pages)
+
 	// Convert page text to structured pages
 	for _, pageText := range pages {
 		wordCount := len(strings.Fields(pageText.Text))
-		extracted.Content.Pages = append(extracted.Content.Pages, Page{
-			PageNumber: pageText.PageNumber,
-			Text:       pageText.Text,
-			WordCount:  wordCount,
-		})
+		language := tokenize.Detect(pageText.Text)
+		tokenCount := tokenize.Count(pageText.Text, language)
+		if pageText.Blank {
+			wordCount, tokenCount = 0, 0 // stamps and page numbers are not words of the document
+		}
+		var lines []LineSpan
+		for _, line := range pageText.Lines {
+			lines = append(lines, LineSpan{
+				Text:     line.Text,
+				Offset:   line.Offset,
+				Y:        line.Y,
+				Position: line.Position,
+				Number:   line.Number,
+				Box:      line.Box.Slice(),
+			})
+		}
+		page := Page{
+			PageNumber:  pageText.PageNumber,
+			Text:        pageText.Text,
+			WordCount:   wordCount,
+			TokenCount:  tokenCount,
+			Language:    language,
+			Rotation:    pageText.Rotation,
+			OCRRotation: pageText.OCRRotation,
+			Skew:        pageText.Skew,
+			Width:       math.Round(pageText.Width*100) / 100,
+			Height:      math.Round(pageText.Height*100) / 100,
+			Orientation: orientation(pageText.Width, pageText.Height),
+			Backend:     pageText.Backend,
+			ImageText:   pageText.ImageText,
+			OCRMerge:    pageText.Merge,
+			Blank:       pageText.Blank,
+			Lines:       lines,
+		}
+		if !page.Blank {
+			page.Signatures = legal.FindSignatures(strings.Split(page.LineText(), "\n"))
+		}
+		if page.Orientation == Landscape {
+			extracted.Metadata.LandscapePages = append(extracted.Metadata.LandscapePages, page.PageNumber)
+		}
+		if len(page.Signatures) > 0 {
+			extracted.Metadata.SignedPages = append(extracted.Metadata.SignedPages, page.PageNumber)
+		}
+		extracted.Content.Pages = append(extracted.Content.Pages, page)
 	}
+	locatePages(extracted.Content.Pages, fullText)
 
-	return json.MarshalIndent(extracted, "", "  ")
+	return extracted
+}
+
+// locatePages sets the full text offsets of pages. The text of each is
+// expected right after that of the page before it, or after the
+// "--- Page N ---" separator, and is otherwise looked for further on, so
+// page text that happens to read like a separator is not placed inside one.
+func locatePages(pages []Page, fullText string) {
+	cursor := 0
+	for i := range pages {
+		pages[i].FullTextStart, pages[i].FullTextEnd = -1, -1
+		text := pages[i].Text
+		if text == "" {
+			continue
+		}
+		rest := fullText[cursor:]
+		separator := fmt.Sprintf("\n\n--- Page %d ---\n\n", pages[i].PageNumber)
+		var at int
+		switch {
+		case strings.HasPrefix(rest, separator+text):
+			at = len(separator)
+		case strings.HasPrefix(rest, text):
+			at = 0
+		default:
+			if at = strings.Index(rest, text); at < 0 {
+				continue
+			}
+		}
+		pages[i].FullTextStart = cursor + at
+		pages[i].FullTextEnd = cursor + at + len(text)
+		cursor = pages[i].FullTextEnd
+	}
+}
+
+// ReadExtracted reads and decodes a (possibly compressed) JSON extraction
+// output. The pages of an output split into shards are read from the shards
+// and put back together.
+func ReadExtracted(path string) (*ExtractedText, error) {
+	data, err := ReadOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	var extracted ExtractedText
+	if err := json.Unmarshal(data, &extracted); err != nil {
+		return nil, fmt.Errorf("failed to parse extraction output: %w", err)
+	}
+	if len(extracted.Shards) > 0 {
+		if err := readShards(path, &extracted); err != nil {
+			return nil, err
+		}
+	}
+	return &extracted, nil
 }
 
 // FormatAsMarkdown formats extracted text as Markdown
 func FormatAsMarkdown(filePath string, pages []PageText, fullText string) ([]byte, error) {
+	return formatMarkdown(filePath, pages, fullText, stamp{at: time.Now()})
+}
+
+// formatMarkdown formats extracted text as Markdown stamped with st
+func formatMarkdown(filePath string, pages []PageText, fullText string, st stamp) ([]byte, error) {
 	filename := filepath.Base(filePath)
 	var builder strings.Builder
 
 	// Header
 	builder.WriteString(fmt.Sprintf("# Document Text Extraction: %s\n\n", filename))
-	builder.WriteString(fmt.Sprintf("**Extracted:** %s\n\n", time.Now().Format(time.RFC3339)))
+	builder.WriteString(fmt.Sprintf("**Extracted:** %s\n\n", st.at.Format(time.RFC3339)))
+	if st.sourceSHA256 != "" {
+		builder.WriteString(fmt.Sprintf("**Source SHA256:** %s\n\n", st.sourceSHA256))
+	}
 	builder.WriteString(fmt.Sprintf("**Pages:** %d\n\n", len(pages)))
 	builder.WriteString("---\n\n")
 
@@ -100,6 +312,10 @@ func FormatAsMarkdown(filePath string, pages []PageText, fullText string) ([]byt
 	if len(pages) > 1 {
 		builder.WriteString("## Pages\n\n")
 		for _, page := range pages {
+			if page.Blank {
+				builder.WriteString(fmt.Sprintf("### Page %d\n\n*(blank)*\n\n", page.PageNumber))
+				continue
+			}
 			builder.WriteString(fmt.Sprintf("### Page %d\n\n", page.PageNumber))
 			builder.WriteString("```\n")
 			builder.WriteString(page.Text)
@@ -112,7 +328,52 @@ func FormatAsMarkdown(filePath string, pages []PageText, fullText string) ([]byt
 
 // PageText represents text extracted from a single page
 type PageText struct {
-	PageNumber int
-	Text       string
+	PageNumber  int
+	Text        string
+	Lines       []Line           // line-level provenance, nil if unavailable
+	Rotation    int              // clockwise display rotation in degrees (0, 90, 180, 270)
+	OCRRotation int              // clockwise degrees the page image was turned upright before OCR, by the tesseract backend
+	Skew        float64          // estimated tilt of OCRed text lines in degrees counterclockwise, by the tesseract backend
+	Width       float64          // width as displayed, in points (0 if unknown)
+	Height      float64          // height as displayed, in points (0 if unknown)
+	Backend     string           // backend that produced the text, e.g. "native" or "pdftotext"
+	ImageText   string           // OCR text of images embedded in the page, if enabled
+	Blank       bool             // no ink beyond negligible text (stamps, a page number)
+	Merge       []MergeDecision  // how ImageText was merged into Text, if enabled
+	Boilerplate []string         // repeated header, footer and stamp lines removed from Text, if enabled
+	Citations   []legal.Citation // legal citations in Text, if enabled
+
+	imageLines []ocrLine // lines of ImageText with their OCR confidence
+}
+
+// FindCoverSheet parses the production details of a cover sheet on the first
+// page, returning nil if there are none
+func FindCoverSheet(pages []PageText) *legal.CoverSheet {
+	if len(pages) == 0 || pages[0].Blank {
+		return nil
+	}
+	sheet := legal.ParseCoverSheet(leadingLines(pages, 1))
+	if sheet.IsEmpty() {
+		return nil
+	}
+	return &sheet
 }
 
+// leadingLines returns the text lines of the first n pages, preferring the
+// positioned lines from the PDF over splitting the page text. Boilerplate
+// removed from the pages is included, since stamps such as a confidentiality
+// designation are part of a cover sheet.
+func leadingLines(pages []PageText, n int) []string {
+	var lines []string
+	for i := 0; i < len(pages) && i < n; i++ {
+		lines = append(lines, pages[i].Boilerplate...)
+		if len(pages[i].Lines) > 0 {
+			for _, line := range pages[i].Lines {
+				lines = append(lines, line.Text)
+			}
+			continue
+		}
+		lines = append(lines, strings.Split(pages[i].Text, "\n")...)
+	}
+	return lines
+}
@@ -0,0 +1,283 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png" // pdfimages -png output
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/scratch"
+)
+
+// minOCRImageSide is the smallest width and height, in pixels, of an
+// embedded image worth OCRing; smaller ones are rules, logos and bullets
+const minOCRImageSide = 64
+
+// maxFormDepth bounds how deeply nested form XObjects are searched for images
+const maxFormDepth = 3
+
+// CheckImageOCR reports whether the tools used to OCR embedded images
+// (poppler's pdfimages and tesseract) are installed
+func CheckImageOCR() error {
+	for _, tool := range []string{"pdfimages", "tesseract"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("image OCR needs %s: %w", tool, err)
+		}
+	}
+	return nil
+}
+
+// hasImages reports whether a page draws any image XObjects, directly or
+// inside form XObjects
+func hasImages(page pdf.Page) bool {
+	return resourcesHaveImages(page.Resources(), 0)
+}
+
+// resourcesHaveImages looks for image XObjects in a resource dictionary
+func resourcesHaveImages(resources pdf.Value, depth int) bool {
+	xobjects := resources.Key("XObject")
+	for _, name := range xobjects.Keys() {
+		xobject := xobjects.Key(name)
+		switch xobject.Key("Subtype").Name() {
+		case "Image":
+			return true
+		case "Form":
+			if depth < maxFormDepth && resourcesHaveImages(xobject.Key("Resources"), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyImageOCR OCRs the embedded images of the given text pages and stores
+// the result in their ImageText, extracting the images under dir. Pages whose
+// images cannot be extracted or read are left without image text.
+func applyImageOCR(filePath string, pages []PageText, imagePages map[int]bool, dir *scratch.Dir) {
+	if len(imagePages) == 0 {
+		return
+	}
+	tmpDir, err := dir.MkdirTemp("images-")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for i := range pages {
+		if !imagePages[pages[i].PageNumber] {
+			continue
+		}
+		lines, err := ocrPageImages(filePath, pages[i], tmpDir)
+		if err != nil {
+			continue
+		}
+		pages[i].ImageText = joinOCRLines(lines)
+		pages[i].imageLines = lines
+	}
+}
+
+// extractFromImage extracts a JPEG or TIFF image document as a single page.
+// Its text is read with tesseract when image OCR is enabled; otherwise the
+// page is empty and the image's EXIF metadata is all its output holds.
+func (e *Extractor) extractFromImage(filePath string) ([]PageText, string, int, error) {
+	page := PageText{PageNumber: 1, Backend: BackendNative}
+	if e.imageOCR {
+		tsv, err := toolOutput("tesseract", filePath, "stdout", "tsv")
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to OCR image: %w", err)
+		}
+		page.imageLines = parseTesseractTSV(tsv)
+		page.Text = joinOCRLines(page.imageLines)
+		page.Backend = BackendTesseract
+	}
+	pages := []PageText{page}
+	return pages, joinFullText(pages), 1, nil
+}
+
+// ocrPageImages extracts the images of one page with pdfimages and OCRs each
+// one large enough to hold text, returning their lines in drawing order. An
+// empty line separates paragraphs and images.
+func ocrPageImages(filePath string, page PageText, tmpDir string) ([]ocrLine, error) {
+	prefix := filepath.Join(tmpDir, fmt.Sprintf("page-%d", page.PageNumber))
+	number := fmt.Sprint(page.PageNumber)
+	if err := runTool("pdfimages", "-png", "-f", number, "-l", number, filePath, prefix); err != nil {
+		return nil, err
+	}
+	images, err := filepath.Glob(prefix + "-*.png")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(images) // pdfimages numbers images in drawing order
+
+	var lines []ocrLine
+	for _, img := range images {
+		if !largeEnough(img) {
+			continue
+		}
+		if page.Rotation != 0 {
+			// Images are stored as the unrotated page draws them
+			if err := rotateImage(img, page.Rotation); err != nil {
+				return nil, err
+			}
+		}
+		tsv, err := toolOutput("tesseract", ocrArgs(img, page)...)
+		if err != nil {
+			return nil, err
+		}
+		if imageLines := parseTesseractTSV(tsv); len(imageLines) > 0 {
+			if len(lines) > 0 {
+				lines = append(lines, ocrLine{})
+			}
+			lines = append(lines, imageLines...)
+		}
+	}
+	return lines, nil
+}
+
+// parseTesseractTSV reads tesseract's TSV output into lines of words, with
+// each line's mean word confidence. An empty line separates paragraphs.
+func parseTesseractTSV(tsv string) []ocrLine {
+	var lines []ocrLine
+	var words []string
+	var confidence float64
+	lastLine, lastPar := "", ""
+	flush := func() {
+		if len(words) > 0 {
+			lines = append(lines, ocrLine{Text: strings.Join(words, " "), Confidence: confidence / float64(len(words)) / 100})
+		}
+		words, confidence = nil, 0
+	}
+	for _, row := range strings.Split(tsv, "\n") {
+		// level page block par line word left top width height conf text
+		fields := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(fields[11])
+		conf, err := strconv.ParseFloat(fields[10], 64)
+		if text == "" || err != nil || conf < 0 {
+			continue
+		}
+		par := strings.Join(fields[1:4], ".")
+		if line := par + "." + fields[4]; line != lastLine {
+			flush()
+			if lastPar != "" && par != lastPar {
+				lines = append(lines, ocrLine{})
+			}
+			lastLine, lastPar = line, par
+		}
+		words = append(words, text)
+		confidence += conf
+	}
+	flush()
+	return lines
+}
+
+// joinOCRLines returns the text of OCR lines
+func joinOCRLines(lines []ocrLine) string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// largeEnough reports whether the image at path is at least minOCRImageSide
+// pixels in both dimensions
+func largeEnough(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	return err == nil && config.Width >= minOCRImageSide && config.Height >= minOCRImageSide
+}
+
+// ocrArgs returns tesseract's arguments for an image of page. Images on
+// landscape pages, often printed spreadsheets, are read as a single block of
+// rows (page segmentation mode 6) rather than split into columns read one
+// after the other.
+func ocrArgs(img string, page PageText) []string {
+	args := []string{img, "stdout"}
+	if orientation(page.Width, page.Height) == Landscape {
+		args = append(args, "--psm", "6")
+	}
+	return append(args, "tsv")
+}
+
+// rotateImage turns the PNG image at path clockwise by degrees (90, 180 or
+// 270), so the text on a rotated page is upright for OCR
+func rotateImage(path string, degrees int) error {
+	if degrees != 90 && degrees != 180 && degrees != 270 {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var dst *image.RGBA
+	if degrees == 180 {
+		dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := src.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				dst.Set(h-1-y, x, c)
+			case 180:
+				dst.Set(w-1-x, h-1-y, c)
+			case 270:
+				dst.Set(y, w-1-x, c)
+			}
+		}
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, dst); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	return out.Close()
+}
+
+// runTool runs an external tool, discarding its output
+func runTool(name string, args ...string) error {
+	_, err := toolOutput(name, args...)
+	return err
+}
+
+// toolOutput runs an external tool and returns its standard output
+func toolOutput(name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
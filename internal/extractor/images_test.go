@@ -0,0 +1,137 @@
+package extractor
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeImageTools puts a pdfimages on PATH that "extracts" a large and a tiny
+// image for every page, and a tesseract that reads "CHECK NO. 1042" from
+// any image, as TSV
+func fakeImageTools(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake image tools are shell scripts")
+	}
+	dir := t.TempDir()
+	large := filepath.Join(dir, "large.png")
+	small := filepath.Join(dir, "small.png")
+	writePNG(t, large, 200, 100)
+	writePNG(t, small, 10, 10)
+
+	// The output prefix is the last argument
+	pdfimages := "#!/bin/sh\nfor prefix; do :; done\ncp " + large + " \"$prefix-000.png\"\ncp " + small + " \"$prefix-001.png\"\n"
+	tesseract := "#!/bin/sh\nprintf 'level\\tpage_num\\tblock_num\\tpar_num\\tline_num\\tword_num\\tleft\\ttop\\twidth\\theight\\tconf\\ttext\\n" +
+		"1\\t1\\t0\\t0\\t0\\t0\\t0\\t0\\t200\\t100\\t-1\\t\\n" +
+		"5\\t1\\t1\\t1\\t1\\t1\\t10\\t10\\t50\\t20\\t96\\tCHECK\\n" +
+		"5\\t1\\t1\\t1\\t1\\t2\\t70\\t10\\t30\\t20\\t91\\tNO.\\n" +
+		"5\\t1\\t1\\t1\\t1\\t3\\t110\\t10\\t50\\t20\\t89\\t1042\\n'\n"
+	for name, script := range map[string]string{"pdfimages": pdfimages, "tesseract": tesseract} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func writePNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyImageOCR(t *testing.T) {
+	fakeImageTools(t)
+	if err := CheckImageOCR(); err != nil {
+		t.Fatalf("CheckImageOCR() error = %v", err)
+	}
+
+	pages := []PageText{
+		{PageNumber: 1, Text: "Deposit slip attached"},
+		{PageNumber: 2, Text: "No images here"},
+	}
+	applyImageOCR("doc.pdf", pages, map[int]bool{1: true}, nil)
+
+	if pages[0].ImageText != "CHECK NO. 1042" {
+		t.Errorf("page 1 ImageText = %q, want the OCR text of the large image only", pages[0].ImageText)
+	}
+	if len(pages[0].imageLines) != 1 || pages[0].imageLines[0].Confidence != 0.92 {
+		t.Errorf("page 1 OCR lines = %+v, want one with confidence 0.92", pages[0].imageLines)
+	}
+	if pages[1].ImageText != "" {
+		t.Errorf("page 2 ImageText = %q, want none", pages[1].ImageText)
+	}
+}
+
+func TestImageDocument(t *testing.T) {
+	fakeImageTools(t)
+	// A TIFF whose only tag is its camera make, "Nik"
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00\x0F\x01\x02\x00\x04\x00\x00\x00Nik\x00\x00\x00\x00\x00")
+	path := filepath.Join(t.TempDir(), "EFTA00031337.tif")
+	if err := os.WriteFile(path, tiff, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsSupported(path) {
+		t.Fatal("IsSupported() = false for a TIFF image")
+	}
+
+	e := NewWithOptions(Options{Format: "json", ImageOCR: true})
+	pages, text, total, err := e.ExtractTextStructured(path)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	if total != 1 || len(pages) != 1 || text != "CHECK NO. 1042" || pages[0].Backend != BackendTesseract {
+		t.Errorf("ExtractTextStructured() = %+v, %q, %d; want one OCRed page", pages, text, total)
+	}
+	content, err := FormatAsJSON(path, pages, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var extracted ExtractedText
+	if err := json.Unmarshal(content, &extracted); err != nil {
+		t.Fatal(err)
+	}
+	if x := extracted.Metadata.EXIF; x == nil || x.Make != "Nik" {
+		t.Errorf("metadata EXIF = %+v, want the camera make", x)
+	}
+
+	// Without OCR the page is empty, but the image is still extracted
+	if pages, _, _, err := New().ExtractTextStructured(path); err != nil || len(pages) != 1 || pages[0].Text != "" {
+		t.Errorf("ExtractTextStructured() without OCR = %+v, %v", pages, err)
+	}
+}
+
+func TestCheckImageOCRMissingTools(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if err := CheckImageOCR(); err == nil {
+		t.Error("CheckImageOCR() did not report the missing tools")
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.png")
+	writePNG(t, path, 200, 100)
+	if err := rotateImage(path, 90); err != nil {
+		t.Fatalf("rotateImage() error = %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil || config.Width != 100 || config.Height != 200 {
+		t.Errorf("rotated image is %dx%d (%v), want 100x200", config.Width, config.Height, err)
+	}
+}
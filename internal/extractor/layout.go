@@ -0,0 +1,214 @@
+package extractor
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Page orientations
+const (
+	Portrait  = "portrait"
+	Landscape = "landscape"
+)
+
+const (
+	// rowTolerance is how far apart, in points, the baselines of characters
+	// on the same row may be
+	rowTolerance = 3.0
+	// wordGap and columnGap are the gaps between characters, as multiples of
+	// a typical character width, taken to separate words and table columns
+	wordGap   = 0.3
+	columnGap = 3.0
+)
+
+// glyph is a character drawn on a page, positioned as the page is displayed
+type glyph struct {
+	x, y, w float64
+	size    float64 // font size, 0 if unknown
+	s       string
+}
+
+// pageSize returns the width and height of a page as displayed, in points:
+// its MediaBox, with the sides swapped when the page is turned a quarter
+// turn. Both are 0 if the page has no usable MediaBox.
+func pageSize(page pdf.Page, rotation int) (width, height float64) {
+	box := inheritedKey(page.V, "MediaBox")
+	if box.Len() != 4 {
+		return 0, 0
+	}
+	width = math.Abs(box.Index(2).Float64() - box.Index(0).Float64())
+	height = math.Abs(box.Index(3).Float64() - box.Index(1).Float64())
+	if rotation == 90 || rotation == 270 {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// orientation describes a page of the given displayed size
+func orientation(width, height float64) string {
+	switch {
+	case width <= 0 || height <= 0:
+		return ""
+	case width > height:
+		return Landscape
+	default:
+		return Portrait
+	}
+}
+
+// needsLayout reports whether a page's text should be read off the page by
+// position rather than in drawing order: pages displayed rotated, whose rows
+// are columns of the unrotated page, and landscape pages, such as printed
+// spreadsheets, whose cells are often drawn a column at a time
+func needsLayout(rotation int, width, height float64) bool {
+	return rotation == 90 || rotation == 270 || orientation(width, height) == Landscape
+}
+
+// layoutText rebuilds a page's text row by row from where its characters are
+// drawn, from the top of the page as displayed and left to right. Words are
+// separated by a space and table columns by a tab. The lines are boxed only
+// on unrotated pages, where display and PDF user space coincide.
+func layoutText(page pdf.Page, rotation int) (text string, lines []Line) {
+	// The PDF library panics on some malformed content streams
+	defer func() {
+		if r := recover(); r != nil {
+			text, lines = "", nil
+		}
+	}()
+
+	glyphs := displayGlyphs(page, rotation)
+	if len(glyphs) == 0 {
+		return "", nil
+	}
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].y > glyphs[j].y })
+	var rows [][]glyph
+	for _, g := range glyphs {
+		if n := len(rows); n > 0 && rows[n-1][0].y-g.y <= rowTolerance {
+			rows[n-1] = append(rows[n-1], g)
+		} else {
+			rows = append(rows, []glyph{g})
+		}
+	}
+
+	_, height := pageSize(page, rotation)
+	var b strings.Builder
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool { return row[i].x < row[j].x })
+		rowText := strings.TrimSpace(joinGlyphs(row))
+		if rowText == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		line := Line{Text: rowText, Offset: b.Len(), Y: row[0].y, Position: verticalThird(row[0].y, height)}
+		if rotation == 0 {
+			line.Box = rowBox(row)
+		}
+		b.WriteString(rowText)
+		lines = append(lines, line)
+	}
+	return b.String(), lines
+}
+
+// rowBox returns the box of a row of characters with known font sizes
+func rowBox(row []glyph) Box {
+	box := Box{X0: math.Inf(1), X1: math.Inf(-1)}
+	var size float64
+	runes := 0
+	for _, g := range row {
+		box.X0 = min(box.X0, g.x)
+		box.X1 = max(box.X1, g.x+g.w)
+		size = max(size, g.size)
+		runes += len([]rune(g.s))
+	}
+	if box.X1-box.X0 < size {
+		box.X1 = box.X0 + float64(runes)*size*averageCharWidth // no width metrics
+	}
+	box.Y0 = row[0].y - size*lineDescent
+	box.Y1 = row[0].y + size*lineAscent
+	return box
+}
+
+// displayGlyphs returns the characters drawn on a page, moved from PDF user
+// space to the page as displayed after its clockwise rotation (unrotated
+// pages keep their user space coordinates)
+func displayGlyphs(page pdf.Page, rotation int) []glyph {
+	var x0, y0, w, h float64
+	if box := inheritedKey(page.V, "MediaBox"); box.Len() == 4 {
+		x0, y0 = box.Index(0).Float64(), box.Index(1).Float64()
+		w, h = box.Index(2).Float64()-x0, box.Index(3).Float64()-y0
+	}
+	var glyphs []glyph
+	for _, text := range page.Content().Text {
+		x, y := text.X, text.Y
+		switch rotation {
+		case 90:
+			x, y = y-y0, w-(x-x0)
+		case 180:
+			x, y = w-(x-x0), h-(y-y0)
+		case 270:
+			x, y = h-(y-y0), x-x0
+		}
+		g := glyph{x: x, y: y, w: text.W, size: text.FontSize, s: text.S}
+		if rotation != 0 {
+			// Rotated text reports its size and widths along the unrotated
+			// axis, where they come out negative or zero
+			g.w, g.size = 0, 0
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+// joinGlyphs writes out a row of characters sorted left to right, putting a
+// space between words and a tab between columns. Gaps are measured against
+// the average character width of the font size or, for rotated text, whose
+// characters have no usable size, against the row's median character pitch.
+// Runs of characters drawn without width metrics pile up at their start;
+// those are told apart by a tab.
+func joinGlyphs(row []glyph) string {
+	var advances []float64
+	stacked := 0
+	for i := 1; i < len(row); i++ {
+		if dx := row[i].x - row[i-1].x; dx > 0 {
+			advances = append(advances, dx)
+		} else {
+			stacked++
+		}
+	}
+	pitch := 0.0
+	if len(advances) > stacked {
+		sort.Float64s(advances)
+		pitch = advances[len(advances)/2]
+	} else if len(advances) > 0 {
+		// Without width metrics the characters of a run are all drawn at
+		// its start, so every advance is a gap between runs
+		pitch = 1
+	}
+
+	var b strings.Builder
+	for i, g := range row {
+		if i > 0 && strings.TrimSpace(g.s) != "" && strings.TrimSpace(row[i-1].s) != "" {
+			prev := row[i-1]
+			unit, advance := pitch, pitch
+			switch {
+			case prev.size > 0:
+				unit, advance = prev.size*averageCharWidth, prev.w
+			case len(advances) <= stacked:
+				advance = 0
+			}
+			switch gap := g.x - prev.x - advance; {
+			case gap > columnGap*unit:
+				b.WriteString("\t")
+			case gap > wordGap*unit:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(g.s)
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package extractor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLandscapeSpreadsheetReadsByRow(t *testing.T) {
+	// Cells drawn a column at a time, as spreadsheet printers often do
+	content := "BT /F1 10 Tf 72 500 Td (Name) Tj ET\n" +
+		"BT /F1 10 Tf 72 480 Td (J. Epstein) Tj ET\n" +
+		"BT /F1 10 Tf 300 500 Td (Amount) Tj ET\n" +
+		"BT /F1 10 Tf 300 480 Td (1000) Tj ET"
+	path := filepath.Join(t.TempDir(), "ledger.pdf")
+	writePagePDF(t, path, "/MediaBox [0 0 792 612]", "", content)
+
+	pages, _, _, err := New().ExtractTextStructured(path)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	page := pages[0]
+	if want := "Name\tAmount\nJ. Epstein\t1000"; page.Text != want {
+		t.Errorf("Text = %q, want %q", page.Text, want)
+	}
+	if page.Width != 792 || page.Height != 612 || orientation(page.Width, page.Height) != Landscape {
+		t.Errorf("size = %vx%v, want a 792x612 landscape page", page.Width, page.Height)
+	}
+	if len(page.Lines) != 2 || page.Lines[1].Offset != strings.Index(page.Text, "J. Epstein") || page.Lines[1].Box.IsZero() {
+		t.Errorf("Lines = %+v, want two located and boxed lines", page.Lines)
+	}
+}
+
+func TestRotatedPageReadsAsDisplayed(t *testing.T) {
+	// A portrait page turned a quarter turn for display, its text drawn
+	// running up the page so it reads left to right once turned
+	content := "BT /F1 10 Tf 0 1 -1 0 100 72 Tm (Flight log) Tj ET\n" +
+		"BT /F1 10 Tf 0 1 -1 0 120 72 Tm (Tail N908JE) Tj ET"
+	widths := "/FirstChar 32 /LastChar 126 /Widths [" + strings.Repeat("500 ", 95) + "]"
+	path := filepath.Join(t.TempDir(), "log.pdf")
+	writePagePDF(t, path, "/MediaBox [0 0 612 792] /Rotate 90", widths, content)
+
+	pages, _, _, err := New().ExtractTextStructured(path)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	page := pages[0]
+	if want := "Flight log\nTail N908JE"; page.Text != want {
+		t.Errorf("Text = %q, want %q", page.Text, want)
+	}
+	if page.Width != 792 || page.Height != 612 {
+		t.Errorf("size = %vx%v, want 792x612 as displayed", page.Width, page.Height)
+	}
+	if page.Lines[0].Position != "upper" || !page.Lines[0].Box.IsZero() {
+		t.Errorf("Lines[0] = %+v, want an unboxed line in the upper third", page.Lines[0])
+	}
+}
+
+func TestOCRArgs(t *testing.T) {
+	if args := ocrArgs("a.png", PageText{Width: 612, Height: 792}); strings.Join(args, " ") != "a.png stdout tsv" {
+		t.Errorf("portrait ocrArgs() = %v", args)
+	}
+	if args := ocrArgs("a.png", PageText{Width: 792, Height: 612}); strings.Join(args, " ") != "a.png stdout --psm 6 tsv" {
+		t.Errorf("landscape ocrArgs() = %v", args)
+	}
+}
@@ -0,0 +1,145 @@
+package extractor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Transcript pages number their lines down the left margin, usually 1-25
+// (some courts use up to 28 or 30)
+const (
+	maxTranscriptLine = 30
+	// minNumberedLines is how many numbered lines a page needs before its
+	// leading numbers are taken for margin line numbers
+	minNumberedLines = 5
+)
+
+// stripLineNumbers removes marginal line numbers from a transcript page,
+// recording each number on its line. Pages that do not look like transcript
+// pages (too few numbered lines, or numbers not increasing down the page) are
+// returned unchanged.
+func stripLineNumbers(page PageText) PageText {
+	if len(page.Lines) == 0 {
+		// No positioned lines (e.g. fallback backends): clean the text only
+		lines := strings.Split(page.Text, "\n")
+		numbers, rests, ok := transcriptNumbers(lines)
+		if !ok {
+			return page
+		}
+		var kept []string
+		for i, line := range lines {
+			if numbers[i] == 0 {
+				kept = append(kept, line)
+			} else if strings.TrimSpace(rests[i]) != "" {
+				kept = append(kept, rests[i])
+			}
+		}
+		page.Text = strings.Join(kept, "\n")
+		return page
+	}
+
+	texts := make([]string, len(page.Lines))
+	for i, line := range page.Lines {
+		texts[i] = line.Text
+	}
+	numbers, rests, ok := transcriptNumbers(texts)
+	if !ok {
+		return page
+	}
+
+	// Rebuild the page text from the cleaned lines so offsets stay exact.
+	// Lines holding nothing but a number are blank transcript lines.
+	var b strings.Builder
+	var lines []Line
+	for i, line := range page.Lines {
+		if numbers[i] != 0 {
+			line.Box = trimBox(line.Box, line.Text, rests[i])
+			line.Text = rests[i]
+			line.Number = numbers[i]
+		}
+		if strings.TrimSpace(line.Text) == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		line.Offset = b.Len()
+		b.WriteString(line.Text)
+		lines = append(lines, line)
+	}
+	page.Text = b.String()
+	page.Lines = lines
+	return page
+}
+
+// trimBox narrows the box of a line's text to the part rest (a suffix of
+// text) takes up, assuming characters of even width
+func trimBox(box Box, text, rest string) Box {
+	total := utf8.RuneCountInString(text)
+	if box.IsZero() || total == 0 {
+		return box
+	}
+	removed := total - utf8.RuneCountInString(rest)
+	box.X0 += (box.X1 - box.X0) * float64(removed) / float64(total)
+	return box
+}
+
+// transcriptNumbers finds the margin line number of each line (0 if it has
+// none) and the text after it, and reports whether the numbers look like
+// transcript line numbering: enough numbered lines, at least half of the
+// page, strictly increasing
+func transcriptNumbers(lines []string) (numbers []int, rests []string, ok bool) {
+	numbers = make([]int, len(lines))
+	rests = make([]string, len(lines))
+	numbered, nonEmpty, last := 0, 0, 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonEmpty++
+		n, rest, found := leadingLineNumber(line)
+		if !found {
+			continue
+		}
+		if n <= last {
+			return nil, nil, false
+		}
+		numbers[i], rests[i], last = n, rest, n
+		numbered++
+	}
+	if numbered < minNumberedLines || numbered*2 < nonEmpty {
+		return nil, nil, false
+	}
+	return numbers, rests, true
+}
+
+// leadingLineNumber parses a line number of one or two digits at the start
+// of line. The number must stand alone: followed by the end of the line,
+// whitespace or a letter, not by more digits or number punctuation
+// ("10,000", "12:30", "3.5").
+func leadingLineNumber(line string) (int, string, bool) {
+	s := strings.TrimLeft(line, " \t")
+	digits := 0
+	for digits < len(s) && digits < 3 && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits > 2 {
+		return 0, "", false
+	}
+	n := int(s[0] - '0')
+	if digits == 2 {
+		n = n*10 + int(s[1]-'0')
+	}
+	if n < 1 || n > maxTranscriptLine {
+		return 0, "", false
+	}
+	rest := s[digits:]
+	if rest != "" {
+		next := []rune(rest)[0]
+		if !unicode.IsSpace(next) && !unicode.IsLetter(next) {
+			return 0, "", false
+		}
+	}
+	return n, strings.TrimLeft(rest, " \t"), true
+}
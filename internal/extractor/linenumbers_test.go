@@ -0,0 +1,72 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripLineNumbers(t *testing.T) {
+	texts := []string{
+		"UNITED STATES DISTRICT COURT",
+		"1 Q. Where were you on",
+		"2    March 3rd?",
+		"3",
+		"4 A. At the office.",
+		"5Q. Who else was there?",
+		"6 A. Nobody.",
+		"Page 12",
+	}
+	var lines []Line
+	for i, text := range texts {
+		lines = append(lines, Line{Text: text, Y: float64(700 - 20*i), Position: "upper"})
+	}
+	page := stripLineNumbers(PageText{PageNumber: 12, Text: strings.Join(texts, " "), Lines: lines})
+
+	want := []struct {
+		text   string
+		number int
+	}{
+		{"UNITED STATES DISTRICT COURT", 0},
+		{"Q. Where were you on", 1},
+		{"March 3rd?", 2},
+		{"A. At the office.", 4},
+		{"Q. Who else was there?", 5},
+		{"A. Nobody.", 6},
+		{"Page 12", 0},
+	}
+	if len(page.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(page.Lines), len(want), page.Lines)
+	}
+	for i, w := range want {
+		line := page.Lines[i]
+		if line.Text != w.text || line.Number != w.number {
+			t.Errorf("line %d = %q (number %d), want %q (number %d)", i, line.Text, line.Number, w.text, w.number)
+		}
+		if page.Text[line.Offset:line.Offset+len(line.Text)] != line.Text {
+			t.Errorf("line %d offset %d does not locate %q in the page text", i, line.Offset, line.Text)
+		}
+	}
+}
+
+func TestStripLineNumbersLeavesOtherPages(t *testing.T) {
+	tests := map[string]string{
+		"too few numbered lines":   "1 Introduction\n2 Background\nThe facts are these.",
+		"numbers not increasing":   "3 apples\n1 pear\n5 plums\n2 figs\n4 limes\n6 kiwis",
+		"numbers with punctuation": "10,000 dollars\n12:30 pm\n3.5 hours\n14/2 split\n15-20 days\n16.",
+	}
+	for name, text := range tests {
+		page := stripLineNumbers(PageText{Text: text})
+		if page.Text != text {
+			t.Errorf("%s: text changed to %q", name, page.Text)
+		}
+	}
+}
+
+func TestStripLineNumbersWithoutLines(t *testing.T) {
+	text := "    1   THE COURT: Be seated.\n    2   MR. DUBIN: Thank you.\n    3\n    4   THE COURT: Proceed.\n    5   MR. DUBIN: Your Honor,\n    6   we call the witness."
+	page := stripLineNumbers(PageText{Text: text, Backend: BackendPDFToText})
+	want := "THE COURT: Be seated.\nMR. DUBIN: Thank you.\nTHE COURT: Proceed.\nMR. DUBIN: Your Honor,\nwe call the witness."
+	if page.Text != want {
+		t.Errorf("Text = %q, want %q", page.Text, want)
+	}
+}
@@ -0,0 +1,264 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"defornicate-epstein-files/internal/email"
+)
+
+// BackendEmail is the built-in email message reader
+const BackendEmail = "email"
+
+// IsMailbox reports whether filePath names an Outlook PST archive
+func IsMailbox(filePath string) bool {
+	return strings.ToLower(filepath.Ext(filePath)) == ".pst"
+}
+
+// MailboxDir returns the directory UnpackMailbox writes to for filePath:
+// {name}.mailbox/ next to the archive
+func MailboxDir(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".mailbox"
+}
+
+// AttachmentsDir returns the directory UnpackAttachments writes to for
+// filePath: {name}.attachments/ next to the message
+func AttachmentsDir(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".attachments"
+}
+
+// UnpackDir returns the directory Unpack writes the documents held by
+// filePath to
+func UnpackDir(filePath string) string {
+	switch {
+	case IsMailbox(filePath):
+		return MailboxDir(filePath)
+	case email.IsMessage(filePath):
+		return AttachmentsDir(filePath)
+	}
+	return PortfolioDir(filePath)
+}
+
+// Unpack writes the documents a file holds next to it and returns their
+// paths: the files embedded in a PDF portfolio (see UnpackPortfolio), the
+// messages of a PST archive (UnpackMailbox) and the attachments of an email
+// message (UnpackAttachments). Other files hold none.
+func (e *Extractor) Unpack(filePath string) ([]string, error) {
+	switch {
+	case IsMailbox(filePath):
+		return e.UnpackMailbox(filePath)
+	case email.IsMessage(filePath):
+		return e.UnpackAttachments(filePath)
+	}
+	return e.UnpackPortfolio(filePath)
+}
+
+// UnpackMailbox writes the email messages of the PST archive at filePath
+// into MailboxDir(filePath) as .eml files and returns their paths. It needs
+// readpst, from libpst. The archive's folders become directories, and
+// messages are numbered within their folder as readpst numbers them.
+// Messages already there unchanged are left alone, so their outputs stay
+// current; attachments stay in the messages, for UnpackAttachments.
+func (e *Extractor) UnpackMailbox(filePath string) ([]string, error) {
+	dir := MailboxDir(filePath)
+	if err := e.perms.MkdirAll(filepath.Dir(dir)); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	// readpst writes into a hidden directory next to the mailbox, which
+	// walks of the tree skip
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	// -e: a numbered .eml file per message, -t e: email only, -8: UTF-8
+	// bodies where the archive has them, -b: no RTF copies of the bodies
+	if err := runTool("readpst", "-e", "-t", "e", "-8", "-b", "-q", "-o", tmpDir, filePath); err != nil {
+		return nil, fmt.Errorf("failed to unpack mailbox: %w", err)
+	}
+
+	unpacked, err := listMessages(tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, tmp := range unpacked {
+		data, err := os.ReadFile(tmp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read unpacked message: %w", err)
+		}
+		rel, _ := filepath.Rel(tmpDir, tmp)
+		path := filepath.Join(dir, rel)
+		if err := e.writeUnpacked(path, data); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// listMessages returns the paths of the .eml files under dir, by folder,
+// then by number, so message 10 comes after message 9. Attachments unpacked
+// from the messages are not listed.
+func listMessages(dir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != dir && strings.HasSuffix(d.Name(), ".attachments") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && email.IsMessage(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		di, dj := filepath.Dir(paths[i]), filepath.Dir(paths[j])
+		if di != dj {
+			return di < dj
+		}
+		ni, nj := filepath.Base(paths[i]), filepath.Base(paths[j])
+		if len(ni) != len(nj) {
+			return len(ni) < len(nj)
+		}
+		return ni < nj
+	})
+	return paths, nil
+}
+
+// UnpackAttachments writes the attachments of the email message at filePath
+// into AttachmentsDir(filePath), named as in the message, made unique, and
+// returns their paths in the order the message has them. Attachments already
+// there unchanged are left alone.
+func (e *Extractor) UnpackAttachments(filePath string) ([]string, error) {
+	msg, err := email.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Files) == 0 {
+		return nil, nil
+	}
+	dir := AttachmentsDir(filePath)
+	used := make(map[string]bool)
+	var paths []string
+	for i, f := range msg.Files {
+		path := filepath.Join(dir, uniqueName(embeddedName(f.Name, i+1), used))
+		if err := e.writeUnpacked(path, f.Data); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writeUnpacked writes an unpacked file to path unless it is already there
+// with the same content
+func (e *Extractor) writeUnpacked(path string, data []byte) error {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	if err := e.perms.MkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := e.perms.CreateAtomic(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to unpack %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// extractFromEmail extracts an email message as a single page: its headers,
+// then its text. Its threading metadata goes into metadata.email of JSON
+// outputs.
+func (e *Extractor) extractFromEmail(filePath string) ([]PageText, string, int, error) {
+	msg, err := email.Read(filePath)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	pages := []PageText{{PageNumber: 1, Text: msg.Text(), Backend: BackendEmail}}
+	return pages, joinFullText(pages), 1, nil
+}
+
+// extractFromMailbox extracts a PST archive as an index of its messages,
+// one page per thread: the thread's subject, then a line for each of its
+// messages (date, sender, subject and where it was unpacked), oldest first.
+// The archive is unpacked first if it has not been.
+func (e *Extractor) extractFromMailbox(filePath string) ([]PageText, string, int, error) {
+	var paths []string
+	var err error
+	if _, statErr := os.Stat(MailboxDir(filePath)); statErr == nil {
+		paths, err = listMessages(MailboxDir(filePath))
+	} else {
+		paths, err = e.UnpackMailbox(filePath)
+	}
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if len(paths) == 0 {
+		return nil, "", 0, fmt.Errorf("no text could be extracted from the document (the mailbox holds no messages)")
+	}
+
+	type entry struct {
+		msg  *email.Message
+		path string
+	}
+	threads := make(map[string][]entry)
+	var order []string
+	for _, path := range paths {
+		msg, err := email.Read(path)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		key := msg.Thread
+		if msg.Conversation != "" {
+			key = msg.Conversation
+		}
+		if key == "" {
+			key = path // a message without IDs is a thread of its own
+		}
+		if _, ok := threads[key]; !ok {
+			order = append(order, key)
+		}
+		threads[key] = append(threads[key], entry{msg, path})
+	}
+
+	dir := filepath.Dir(filePath)
+	var pages []PageText
+	for _, key := range order {
+		entries := threads[key]
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].msg.Date < entries[j].msg.Date })
+		topic := entries[0].msg.ThreadTopic
+		if topic == "" {
+			topic = entries[0].msg.Subject
+		}
+		lines := []string{fmt.Sprintf("Thread: %s (%d message(s))", topic, len(entries))}
+		for _, en := range entries {
+			rel, err := filepath.Rel(dir, en.path)
+			if err != nil {
+				rel = en.path
+			}
+			lines = append(lines, fmt.Sprintf("%s\t%s\t%s\t%s", en.msg.Date, en.msg.From, en.msg.Subject, filepath.ToSlash(rel)))
+		}
+		pages = append(pages, PageText{PageNumber: len(pages) + 1, Text: strings.Join(lines, "\n"), Backend: BackendEmail})
+	}
+	return pages, joinFullText(pages), len(pages), nil
+}
@@ -0,0 +1,127 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mailboxMessages are the messages the fake readpst unpacks: a thread of two
+// in the Inbox, the reply carrying an attachment, and one in Sent Items
+var mailboxMessages = map[string]string{
+	"Inbox/1.eml":      "From: a@example.com\nTo: b@example.com\nDate: Mon, 04 Mar 2002 09:00:00 +0000\nSubject: Flights\nMessage-ID: <1@example.com>\n\nWhen do we leave?\n",
+	"Inbox/2.eml":      "From: b@example.com\nTo: a@example.com\nDate: Mon, 04 Mar 2002 10:00:00 +0000\nSubject: RE: Flights\nMessage-ID: <2@example.com>\nIn-Reply-To: <1@example.com>\nReferences: <1@example.com>\nMIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=b\n\n--b\nContent-Type: text/plain\n\nManifest attached.\n--b\nContent-Type: application/pdf\nContent-Disposition: attachment; filename=manifest.pdf\n\n%PDF-1.4\n--b--\n",
+	"Sent Items/1.eml": "From: a@example.com\nTo: c@example.com\nDate: Tue, 05 Mar 2002 09:00:00 +0000\nSubject: Dinner\nMessage-ID: <3@example.com>\n\nSee you at 8.\n",
+}
+
+// fakeReadpst puts a readpst on PATH that unpacks mailboxMessages into the
+// directory following -o
+func fakeReadpst(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake readpst is a shell script")
+	}
+	dir := t.TempDir()
+	messages := filepath.Join(dir, "messages")
+	for rel, msg := range mailboxMessages {
+		path := filepath.Join(messages, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := "#!/bin/sh\nwhile [ \"$1\" != -o ]; do shift; done\ncp -R '" + messages + "'/. \"$2\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "readpst"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestUnpackMailbox(t *testing.T) {
+	fakeReadpst(t)
+	dir := t.TempDir()
+	pst := filepath.Join(dir, "archive.pst")
+	os.WriteFile(pst, []byte("!BDN"), 0644)
+	ext := New()
+
+	paths, err := ext.Unpack(pst)
+	if err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	mailbox := MailboxDir(pst)
+	want := []string{
+		filepath.Join(mailbox, "Inbox", "1.eml"),
+		filepath.Join(mailbox, "Inbox", "2.eml"),
+		filepath.Join(mailbox, "Sent Items", "1.eml"),
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unpack() = %q, want %q", paths, want)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Unpack() left %d entries next to the archive, want the archive and its mailbox", len(entries))
+	}
+
+	// Unpacking again leaves unchanged messages alone
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(want[0], old, old)
+	if _, err := ext.Unpack(pst); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(want[0]); !info.ModTime().Equal(old) {
+		t.Error("Unpack() rewrote an unchanged message")
+	}
+
+	// The archive's text is its index of threads
+	pages, _, _, err := ext.ExtractTextStructured(pst)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("ExtractTextStructured() = %d page(s), want one per thread: %+v", len(pages), pages)
+	}
+	if !strings.HasPrefix(pages[0].Text, "Thread: Flights (2 message(s))\n") || !strings.Contains(pages[0].Text, "RE: Flights\tarchive.mailbox/Inbox/2.eml") {
+		t.Errorf("first thread = %q", pages[0].Text)
+	}
+}
+
+func TestUnpackAttachments(t *testing.T) {
+	dir := t.TempDir()
+	msg := filepath.Join(dir, "2.eml")
+	os.WriteFile(msg, []byte(mailboxMessages["Inbox/2.eml"]), 0644)
+	ext := New()
+
+	paths, err := ext.Unpack(msg)
+	if err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	want := filepath.Join(dir, "2.attachments", "manifest.pdf")
+	if len(paths) != 1 || paths[0] != want {
+		t.Fatalf("Unpack() = %q, want [%q]", paths, want)
+	}
+	if data, _ := os.ReadFile(want); string(data) != "%PDF-1.4" {
+		t.Errorf("attachment = %q", data)
+	}
+	if UnpackDir(msg) != filepath.Join(dir, "2.attachments") {
+		t.Errorf("UnpackDir() = %q", UnpackDir(msg))
+	}
+
+	out, err := ext.Render(msg, "")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	extracted := out.JSON
+	if extracted == nil {
+		t.Fatal("Render() gave no JSON output")
+	}
+	email := extracted.Metadata.Email
+	if email == nil || email.InReplyTo != "1@example.com" || email.Thread != "1@example.com" {
+		t.Fatalf("metadata.email = %+v, want the threading headers", email)
+	}
+	if page := extracted.Content.Pages[0]; page.Backend != BackendEmail || !strings.HasSuffix(page.Text, "Attachments: manifest.pdf\n\nManifest attached.") {
+		t.Errorf("page = %+v", page)
+	}
+}
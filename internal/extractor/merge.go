@@ -0,0 +1,229 @@
+package extractor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// How an OCR line was merged with the page's text layer
+const (
+	MergeNative   = "native"   // the text layer already had the line and was kept
+	MergeReplaced = "replaced" // a garbled text-layer line was replaced by its OCR reading
+	MergeAdded    = "added"    // OCR text missing from the text layer was appended
+	MergeDropped  = "dropped"  // OCR text missing from the text layer was too unsure to add
+)
+
+const (
+	// minMergeSimilarity is the character bigram similarity above which an
+	// OCR line and a text-layer line are taken to be the same region
+	minMergeSimilarity = 0.5
+	// sameTextSimilarity is the similarity above which the lines differ only
+	// by OCR noise, so the text layer is kept whatever its score
+	sameTextSimilarity = 0.9
+	// minAddConfidence is the OCR confidence needed to add a line the text
+	// layer lacks
+	minAddConfidence = 0.6
+)
+
+// Tokens that read as text, for scoring text-layer lines
+var (
+	wordRe       = regexp.MustCompile(`^\p{L}+(?:['’-]\p{L}+)*$`)
+	numberRe     = regexp.MustCompile(`^[$€£]?\d+(?:[.,/:-]\d+)*%?$`)
+	identifierRe = regexp.MustCompile(`^\p{Lu}{1,8}[-_]?\d+$`)
+)
+
+// MergeDecision records what was done with one OCR line when merging OCR
+// text into a page's text layer
+type MergeDecision struct {
+	Action     string  `json:"action"`            // one of the Merge constants
+	OCR        string  `json:"ocr"`               // the OCR line
+	Native     string  `json:"native,omitempty"`  // the text-layer line it matched
+	Confidence float64 `json:"confidence"`        // mean OCR word confidence, 0 to 1
+	Quality    float64 `json:"quality,omitempty"` // share of the text-layer line that reads as words, 0 to 1
+}
+
+// ocrLine is one line of OCR output with its mean word confidence (0 to 1)
+type ocrLine struct {
+	Text       string
+	Confidence float64
+}
+
+// mergeRegion is a line of the text layer and where it sits in the page text
+type mergeRegion struct {
+	text    string
+	offset  int // in the page text, -1 if unknown
+	line    int // index into PageText.Lines, -1 if the page has none
+	matched bool
+}
+
+// mergeOCR merges the OCR lines of a page's embedded images into its text
+// layer region by region, rather than preferring one source wholesale: each
+// OCR line is matched to the text-layer line it reads most like, and the one
+// that scores better is kept. OCR lines with no counterpart are appended if
+// OCR was confident about them. Every decision is recorded on the page.
+func mergeOCR(page PageText) PageText {
+	if len(page.imageLines) == 0 {
+		return page
+	}
+	regions := textRegions(page)
+	var appended []string
+	for _, line := range page.imageLines {
+		if line.Text == "" {
+			continue // between paragraphs
+		}
+		decision := MergeDecision{OCR: line.Text, Confidence: line.Confidence}
+		best, bestScore := -1, 0.0
+		for i, region := range regions {
+			if score := bigramSimilarity(region.text, line.Text); !region.matched && score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		switch {
+		case best >= 0 && bestScore >= minMergeSimilarity:
+			region := &regions[best]
+			region.matched = true
+			decision.Native = region.text
+			decision.Quality = textQuality(region.text)
+			decision.Action = MergeNative
+			if bestScore < sameTextSimilarity && line.Confidence > decision.Quality {
+				decision.Action = MergeReplaced
+				page = replaceRegion(page, *region, line.Text)
+				shiftRegions(regions, region.offset, len(line.Text)-len(region.text))
+				region.text = line.Text
+			}
+		case line.Confidence >= minAddConfidence:
+			decision.Action = MergeAdded
+			appended = append(appended, line.Text)
+		default:
+			decision.Action = MergeDropped
+		}
+		page.Merge = append(page.Merge, decision)
+	}
+
+	for _, text := range appended {
+		if page.Text != "" {
+			page.Text += "\n"
+		}
+		if page.Lines != nil {
+			page.Lines = append(page.Lines, Line{Text: text, Offset: len(page.Text)})
+		}
+		page.Text += text
+	}
+	return page
+}
+
+// textRegions splits a page's text layer into lines: its positioned Lines
+// where known, otherwise the lines of its text
+func textRegions(page PageText) []mergeRegion {
+	var regions []mergeRegion
+	if len(page.Lines) > 0 {
+		for i, line := range page.Lines {
+			regions = append(regions, mergeRegion{text: line.Text, offset: line.Offset, line: i})
+		}
+		return regions
+	}
+	offset := 0
+	for _, text := range strings.Split(page.Text, "\n") {
+		if strings.TrimSpace(text) != "" {
+			regions = append(regions, mergeRegion{text: text, offset: offset, line: -1})
+		}
+		offset += len(text) + 1
+	}
+	return regions
+}
+
+// replaceRegion swaps a region's text for replacement in the page text and
+// its Line, moving the offsets of the lines after it
+func replaceRegion(page PageText, region mergeRegion, replacement string) PageText {
+	if region.offset >= 0 && strings.HasPrefix(page.Text[region.offset:], region.text) {
+		page.Text = page.Text[:region.offset] + replacement + page.Text[region.offset+len(region.text):]
+	}
+	if region.line >= 0 {
+		lines := make([]Line, len(page.Lines))
+		copy(lines, page.Lines)
+		delta := len(replacement) - len(region.text)
+		for i := range lines {
+			if region.offset >= 0 && lines[i].Offset > region.offset {
+				lines[i].Offset += delta
+			}
+		}
+		lines[region.line].Text = replacement
+		page.Lines = lines
+	}
+	return page
+}
+
+// shiftRegions moves the offsets of the regions after offset by delta
+func shiftRegions(regions []mergeRegion, offset, delta int) {
+	if offset < 0 {
+		return
+	}
+	for i := range regions {
+		if regions[i].offset > offset {
+			regions[i].offset += delta
+		}
+	}
+}
+
+// textQuality scores how much of a line reads as words: the share of its
+// non-space characters in tokens made of letters, or of digits and
+// separators. Broken font encodings produce symbol soup and replacement
+// characters that score low.
+func textQuality(text string) float64 {
+	total, clean := 0, 0
+	for _, token := range strings.Fields(text) {
+		n := len([]rune(token))
+		total += n
+		if wordLike(token) {
+			clean += n
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(clean) / float64(total)
+}
+
+// wordLike reports whether a token, less surrounding punctuation, is a word
+// ("don't", "co-counsel"), a number ("1,042.50", "03/03/2005") or an
+// identifier such as a Bates number ("EFTA00010724")
+func wordLike(token string) bool {
+	token = strings.Trim(token, `.,;:!?"'()[]{}`)
+	return token == "" || wordRe.MatchString(token) || numberRe.MatchString(token) || identifierRe.MatchString(token)
+}
+
+// bigramSimilarity is the Dice coefficient of the character bigrams of two
+// strings, ignoring case and spaces: 1 for the same text, near 0 for
+// unrelated text, and tolerant of the odd misread character
+func bigramSimilarity(a, b string) float64 {
+	ba, bb := bigrams(a), bigrams(b)
+	if len(ba) == 0 || len(bb) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(ba))
+	for _, g := range ba {
+		counts[g]++
+	}
+	shared := 0
+	for _, g := range bb {
+		if counts[g] > 0 {
+			counts[g]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ba)+len(bb))
+}
+
+// bigrams returns the overlapping character pairs of s, lower-cased and
+// without whitespace
+func bigrams(s string) []string {
+	runes := []rune(strings.ToLower(strings.Join(strings.Fields(s), "")))
+	if len(runes) < 2 {
+		return nil
+	}
+	grams := make([]string, 0, len(runes)-1)
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
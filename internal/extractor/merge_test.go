@@ -0,0 +1,63 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeOCR(t *testing.T) {
+	text := "Flight log\nPa$$enger: J. Ep$tein\nDestination: Teterboro"
+	page := PageText{
+		PageNumber: 1,
+		Text:       text,
+		Lines: []Line{
+			{Text: "Flight log", Offset: 0},
+			{Text: "Pa$$enger: J. Ep$tein", Offset: 11},
+			{Text: "Destination: Teterboro", Offset: 33},
+		},
+		imageLines: []ocrLine{
+			{Text: "Flight Iog", Confidence: 0.7},
+			{Text: "Passenger: J. Epstein", Confidence: 0.9},
+			{},
+			{Text: "Tail number N908JE", Confidence: 0.85},
+			{Text: "~~ ,. ';", Confidence: 0.2},
+		},
+	}
+
+	got := mergeOCR(page)
+	want := "Flight log\nPassenger: J. Epstein\nDestination: Teterboro\nTail number N908JE"
+	if got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	for _, line := range got.Lines {
+		if got.Text[line.Offset:line.Offset+len(line.Text)] != line.Text {
+			t.Errorf("line %q is not at offset %d", line.Text, line.Offset)
+		}
+	}
+	var actions []string
+	for _, decision := range got.Merge {
+		actions = append(actions, decision.Action)
+	}
+	if wantActions := []string{MergeNative, MergeReplaced, MergeAdded, MergeDropped}; !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("actions = %v, want %v", actions, wantActions)
+	}
+	if page.Lines[1].Text != "Pa$$enger: J. Ep$tein" {
+		t.Error("mergeOCR modified the original page's lines")
+	}
+}
+
+func TestTextQuality(t *testing.T) {
+	for text, want := range map[string]float64{
+		"Deposit of $1,042.50 on 03/03/2005 (EFTA00010724).": 1,
+		"don't co-counsel": 1,
+		"�� ��":            0,
+		"":                 0,
+	} {
+		if got := textQuality(text); got != want {
+			t.Errorf("textQuality(%q) = %v, want %v", text, got, want)
+		}
+	}
+	if q := textQuality("P@ss#ng€r: J. Epstein"); q <= 0 || q >= 1 {
+		t.Errorf("textQuality() of a partly garbled line = %v, want between 0 and 1", q)
+	}
+}
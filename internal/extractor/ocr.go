@@ -0,0 +1,197 @@
+package extractor
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"defornicate-epstein-files/internal/scratch"
+)
+
+// ocrResolution is the resolution, in dots per inch, pages are rendered at
+// for OCR: tesseract reads best at 300 dpi
+const ocrResolution = 300
+
+// minOSDConfidence is the orientation confidence tesseract's orientation
+// detection must report before a rendered page is turned; below it, on pages
+// with little text, the guess is unreliable
+const minOSDConfidence = 2.0
+
+// ocrPage is what OCR read from a rendered page
+type ocrPage struct {
+	text     string
+	rotation int     // clockwise degrees the page was turned to make its text upright
+	skew     float64 // estimated skew of its text lines, in degrees counterclockwise
+}
+
+// CheckPageOCR reports whether the tools used by the tesseract fallback
+// backend to OCR whole pages (poppler's pdftoppm and tesseract) are installed
+func CheckPageOCR() error {
+	for _, tool := range []string{"pdftoppm", "tesseract"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("OCR needs %s: %w", tool, err)
+		}
+	}
+	return nil
+}
+
+// ocrPages renders the given pages of a PDF with pdftoppm, as displayed, and
+// reads each with tesseract, working under dir. This is how scanned pages
+// without a text layer get any text at all. A page scanned sideways or upside
+// down is turned upright first, as tesseract's orientation detection finds
+// it, since it otherwise OCRs to garbage. A page that fails does not stop the
+// others: the pages read are returned along with an error naming those that
+// were not.
+func ocrPages(filePath string, pages []int, dir *scratch.Dir) (map[int]ocrPage, error) {
+	tmpDir, err := dir.MkdirTemp("ocr-")
+	if err != nil {
+		return nil, fmt.Errorf("tesseract backend: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	texts := make(map[int]ocrPage, len(pages))
+	var failed []string
+	var firstErr error
+	for _, n := range pages {
+		page, err := ocrPDFPage(filePath, n, tmpDir)
+		if err != nil {
+			failed = append(failed, fmt.Sprint(n))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		texts[n] = page
+	}
+	if len(failed) > 0 {
+		return texts, fmt.Errorf("tesseract backend failed on page(s) %s of %d: %w", strings.Join(failed, ", "), len(pages), firstErr)
+	}
+	return texts, nil
+}
+
+// ocrPDFPage renders page n of a PDF into tmpDir, turns it upright and reads it
+func ocrPDFPage(filePath string, n int, tmpDir string) (ocrPage, error) {
+	number := fmt.Sprint(n)
+	prefix := filepath.Join(tmpDir, "page-"+number)
+	if err := runTool("pdftoppm", "-r", fmt.Sprint(ocrResolution), "-gray", "-png", "-singlefile", "-f", number, "-l", number, filePath, prefix); err != nil {
+		return ocrPage{}, err
+	}
+	img := prefix + ".png"
+	defer os.Remove(img)
+	rotation := detectRotation(img)
+	if err := rotateImage(img, rotation); err != nil {
+		return ocrPage{}, err
+	}
+	tsv, err := toolOutput("tesseract", img, "stdout", "tsv")
+	if err != nil {
+		return ocrPage{}, err
+	}
+	return ocrPage{text: joinOCRLines(parseTesseractTSV(tsv)), rotation: rotation, skew: estimateSkew(tsv)}, nil
+}
+
+// detectRotation runs tesseract's orientation and script detection (--psm 0)
+// on an image and returns the clockwise rotation, 0, 90, 180 or 270 degrees,
+// that makes its text upright. It returns 0 when detection fails, as it does
+// on pages with too little text, or is not confident.
+func detectRotation(img string) int {
+	osd, err := toolOutput("tesseract", img, "stdout", "--psm", "0")
+	if err != nil {
+		return 0
+	}
+	rotate, confidence := parseOSD(osd)
+	if confidence < minOSDConfidence {
+		return 0
+	}
+	return rotate
+}
+
+// parseOSD reads the rotation to apply and its confidence from tesseract's
+// orientation and script detection output, e.g. "Rotate: 90" and
+// "Orientation confidence: 7.45"
+func parseOSD(osd string) (int, float64) {
+	var rotate int
+	var confidence float64
+	for _, line := range strings.Split(osd, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Rotate":
+			rotate, _ = strconv.Atoi(value)
+		case "Orientation confidence":
+			confidence, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	rotate = ((rotate % 360) + 360) % 360
+	if rotate%90 != 0 {
+		return 0, 0
+	}
+	return rotate, confidence
+}
+
+// estimateSkew estimates how far the text of a page image is tilted, in
+// degrees counterclockwise and to a tenth of a degree, from the word boxes of
+// tesseract's TSV output: the median slope of the baselines of lines of at
+// least three words. It returns 0 if no line has enough words.
+func estimateSkew(tsv string) float64 {
+	type point struct{ x, y float64 }
+	lines := make(map[string][]point)
+	var order []string
+	for _, row := range strings.Split(tsv, "\n") {
+		// level page block par line word left top width height conf text
+		fields := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if len(fields) < 12 || fields[0] != "5" || strings.TrimSpace(fields[11]) == "" {
+			continue
+		}
+		var box [4]float64
+		valid := true
+		for i := range box {
+			var err error
+			if box[i], err = strconv.ParseFloat(fields[6+i], 64); err != nil {
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+		line := strings.Join(fields[1:5], ".")
+		if _, ok := lines[line]; !ok {
+			order = append(order, line)
+		}
+		// The middle of the word's bottom edge, on the baseline
+		lines[line] = append(lines[line], point{box[0] + box[2]/2, box[1] + box[3]})
+	}
+
+	var angles []float64
+	for _, line := range order {
+		points := lines[line]
+		if len(points) < 3 {
+			continue
+		}
+		// Least-squares slope of the baseline; image y grows downwards
+		var sx, sy, sxx, sxy float64
+		for _, p := range points {
+			sx, sy, sxx, sxy = sx+p.x, sy+p.y, sxx+p.x*p.x, sxy+p.x*p.y
+		}
+		n := float64(len(points))
+		if d := n*sxx - sx*sx; d != 0 {
+			angles = append(angles, -math.Atan((n*sxy-sx*sy)/d)*180/math.Pi)
+		}
+	}
+	if len(angles) == 0 {
+		return 0
+	}
+	slices.Sort(angles)
+	median := angles[len(angles)/2]
+	if len(angles)%2 == 0 {
+		median = (angles[len(angles)/2-1] + median) / 2
+	}
+	return math.Round(median*10) / 10
+}
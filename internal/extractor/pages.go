@@ -0,0 +1,99 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pages extracts a document page by page, yielding each page as soon as it is
+// extracted, so a caller processing a 2,000-page PDF holds one page at a
+// time instead of the whole document. Pages come in page order, blank ones
+// included and pages without text left out, as in ExtractTextStructured. An
+// error ends the sequence; stopping early closes the document.
+//
+// PDFs are streamed unless a setting needs the whole document at once:
+// boilerplate stripping compares pages with each other, and the Tika
+// fallback replaces a PDF that yields no text as a whole. Those, and every
+// other format, are extracted in full and then yielded page by page.
+func (e *Extractor) Pages(filePath string) iter.Seq2[PageText, error] {
+	return func(yield func(PageText, error) bool) {
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			yield(PageText{}, fmt.Errorf("file does not exist: %s", filePath))
+			return
+		}
+		if strings.ToLower(filepath.Ext(filePath)) != ".pdf" || e.boilerplate || e.tikaURL != "" {
+			pages, _, _, err := e.ExtractTextStructured(filePath)
+			if err != nil {
+				yield(PageText{}, err)
+				return
+			}
+			for _, page := range pages {
+				if !yield(page, nil) {
+					return
+				}
+			}
+			return
+		}
+		e.streamPDF(filePath, yield)
+	}
+}
+
+// streamPDF extracts a PDF one page at a time for Pages
+func (e *Extractor) streamPDF(filePath string, yield func(PageText, error) bool) {
+	reader, file, err := e.openPDF(filePath)
+	if err != nil {
+		yield(PageText{}, fmt.Errorf("failed to open document: %w (document may be encrypted or in an unsupported format)", err))
+		return
+	}
+	defer file.Close()
+	totalPages := reader.NumPage()
+	if totalPages == 0 {
+		yield(PageText{}, errors.New("document has no pages"))
+		return
+	}
+
+	var withText bool
+	var fallbackErr error
+	for i := 1; i <= totalPages; i++ {
+		e.reportProgress(filePath, i-1, totalPages)
+		page, blank, images, ok := e.nativePage(reader, i)
+		if !ok {
+			continue
+		}
+		var pages []PageText
+		imagePages := make(map[int]bool) // text pages that also draw images
+		blankPages := make(map[int]bool) // pages with no ink beyond negligible text
+		if page.Text != "" {
+			pages = append(pages, page)
+			if images {
+				imagePages[i] = true
+			}
+		}
+		if blank {
+			blankPages[i] = true
+		}
+		pages, err := e.finishPages(filePath, pages, []int{i}, imagePages, blankPages)
+		if err != nil {
+			fallbackErr = err
+		}
+		for _, page := range pages {
+			withText = withText || page.Text != ""
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+	e.reportProgress(filePath, totalPages, totalPages)
+
+	if !withText {
+		if fallbackErr != nil {
+			yield(PageText{}, fmt.Errorf("no text could be extracted from the document (fallback failed: %v)", fallbackErr))
+			return
+		}
+		yield(PageText{}, errors.New("no text could be extracted from the document (document may be encrypted, image-based, or in an unsupported format)"))
+	}
+}
@@ -0,0 +1,62 @@
+package extractor
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPages(t *testing.T) {
+	// Three pages, the second blank
+	contents := []string{
+		"BT /F1 12 Tf 72 720 Td (First page) Tj ET",
+		"",
+		"BT /F1 12 Tf 72 720 Td (Third page) Tj ET",
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 6 0 R 8 0 R] /Count 3 >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	for i, content := range contents {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 3 0 R >> >> >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	path := filepath.Join(t.TempDir(), "a.pdf")
+	writeObjectsPDF(t, path, objects)
+
+	ext := New()
+	want, _, _, err := ext.ExtractTextStructured(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []PageText
+	for page, err := range ext.Pages(path) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, page)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pages() = %+v\nwant what ExtractTextStructured gives: %+v", got, want)
+	}
+	if len(got) != 3 || !got[1].Blank {
+		t.Errorf("got %d pages, want 3 with the second blank", len(got))
+	}
+
+	// Stopping early is fine
+	for page := range ext.Pages(path) {
+		if page.PageNumber != 1 {
+			t.Errorf("first page yielded is %d", page.PageNumber)
+		}
+		break
+	}
+
+	for _, err := range ext.Pages(filepath.Join(t.TempDir(), "missing.pdf")) {
+		if err == nil {
+			t.Error("missing file yielded a page")
+		}
+	}
+}
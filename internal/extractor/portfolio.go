@@ -0,0 +1,158 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxNameTreeDepth bounds the recursion into a portfolio's name tree, which
+// a malformed file can make cyclic
+const maxNameTreeDepth = 32
+
+// PortfolioDir returns the directory UnpackPortfolio writes to for filePath:
+// {name}.portfolio/ next to the document
+func PortfolioDir(filePath string) string {
+	ext := filepath.Ext(filePath)
+	return strings.TrimSuffix(filePath, ext) + ".portfolio"
+}
+
+// embeddedFile is a file embedded in a PDF, as its name tree lists it
+type embeddedFile struct {
+	name   string
+	stream pdf.Value
+}
+
+// UnpackPortfolio writes the files embedded in the PDF portfolio at filePath
+// (a PDF whose catalog has a /Collection, as Acrobat makes them) into
+// PortfolioDir(filePath), returning their paths in the order the portfolio
+// lists them. Files are named as in the portfolio, made unique. A file that is
+// not a PDF portfolio yields none, and its attachments are left alone.
+func (e *Extractor) UnpackPortfolio(filePath string) (paths []string, err error) {
+	if strings.ToLower(filepath.Ext(filePath)) != ".pdf" {
+		return nil, nil
+	}
+	reader, file, err := e.openPDF(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	defer file.Close()
+
+	// The PDF library panics on some malformed objects
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to unpack portfolio: %v", r)
+		}
+	}()
+
+	root := reader.Trailer().Key("Root")
+	if root.Key("Collection").IsNull() {
+		return nil, nil
+	}
+	var files []embeddedFile
+	walkNameTree(root.Key("Names").Key("EmbeddedFiles"), 0, func(key string, spec pdf.Value) {
+		stream := spec.Key("EF").Key("UF")
+		if stream.IsNull() {
+			stream = spec.Key("EF").Key("F")
+		}
+		if stream.IsNull() {
+			return
+		}
+		name := spec.Key("UF").Text()
+		if name == "" {
+			name = spec.Key("F").Text()
+		}
+		if name == "" {
+			name = key
+		}
+		files = append(files, embeddedFile{name: name, stream: stream})
+	})
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	dir := PortfolioDir(filePath)
+	if err := e.perms.MkdirAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	used := make(map[string]bool)
+	for i, f := range files {
+		path := filepath.Join(dir, uniqueName(embeddedName(f.name, i+1), used))
+		if err := e.writeEmbedded(path, f.stream); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// walkNameTree calls visit for every entry of a PDF name tree, in order
+func walkNameTree(node pdf.Value, depth int, visit func(key string, value pdf.Value)) {
+	if node.IsNull() || depth > maxNameTreeDepth {
+		return
+	}
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		visit(names.Index(i).Text(), names.Index(i+1))
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		walkNameTree(kids.Index(i), depth+1, visit)
+	}
+}
+
+// writeEmbedded writes the decoded content of an embedded file stream to path
+func (e *Extractor) writeEmbedded(path string, stream pdf.Value) error {
+	f, err := e.perms.CreateAtomic(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	content := stream.Reader()
+	defer content.Close()
+	if _, err := io.Copy(f, content); err != nil {
+		f.Abort()
+		return fmt.Errorf("failed to unpack %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// embeddedName makes the name of the n-th embedded file safe to write: its
+// last path element, without control characters and leading or trailing dots
+// and spaces (so it is never hidden), or "embedded-n" if nothing is left
+func embeddedName(name string, n int) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == ':' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return fmt.Sprintf("embedded-%d", n)
+	}
+	return name
+}
+
+// uniqueName returns name, or name with "-2", "-3" and so on before its
+// extension if a file of that name (regardless of case) is already in used,
+// and adds it to used
+func uniqueName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	used[strings.ToLower(unique)] = true
+	return unique
+}
@@ -0,0 +1,79 @@
+package extractor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePortfolio writes a PDF portfolio with a cover page, embedding files
+// under the given names in order
+func writePortfolio(t *testing.T, path string, names []string, files [][]byte) {
+	t.Helper()
+	cover := "BT /F1 12 Tf 72 720 Td (This portfolio contains several documents) Tj ET"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R /Collection << /Type /Collection /View /D >> /Names << /EmbeddedFiles 6 0 R >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(cover), cover),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"", // the name tree, once the file specifications are numbered
+	}
+	var entries []string
+	for i, name := range names {
+		spec := len(objects) + 1
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Filespec /F (%s) /UF (%s) /EF << /F %d 0 R >> >>", name, name, spec+1),
+			fmt.Sprintf("<< /Type /EmbeddedFile /Length %d >>\nstream\n%s\nendstream", len(files[i]), files[i]))
+		entries = append(entries, fmt.Sprintf("(%03d) %d 0 R", i, spec))
+	}
+	objects[5] = "<< /Names [" + strings.Join(entries, " ") + "] >>"
+	writeObjectsPDF(t, path, objects)
+}
+
+func TestUnpackPortfolio(t *testing.T) {
+	dir := t.TempDir()
+	child := filepath.Join(dir, "child.pdf")
+	writeTextPDF(t, child, "Flight log")
+	pdfData, err := os.ReadFile(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portfolio := filepath.Join(dir, "bundle.pdf")
+	writePortfolio(t, portfolio,
+		[]string{"Exhibit A.pdf", "notes.txt", "../Exhibit A.PDF"},
+		[][]byte{pdfData, []byte("Call notes"), pdfData})
+	e := New()
+	paths, err := e.UnpackPortfolio(portfolio)
+	if err != nil {
+		t.Fatalf("UnpackPortfolio() error = %v", err)
+	}
+	var names []string
+	for _, path := range paths {
+		if filepath.Dir(path) != PortfolioDir(portfolio) {
+			t.Errorf("UnpackPortfolio() wrote %s outside %s", path, PortfolioDir(portfolio))
+		}
+		names = append(names, filepath.Base(path))
+	}
+	if want := "Exhibit A.pdf notes.txt Exhibit A-2.PDF"; strings.Join(names, " ") != want {
+		t.Errorf("UnpackPortfolio() = %v, want %s", names, want)
+	}
+	if len(paths) == 3 {
+		text, err := e.ExtractText(paths[0])
+		if err != nil || !strings.Contains(text, "Flight log") {
+			t.Errorf("ExtractText(embedded PDF) = %q, %v", text, err)
+		}
+		if data, _ := os.ReadFile(paths[1]); string(data) != "Call notes" {
+			t.Errorf("embedded notes.txt = %q", data)
+		}
+	}
+
+	// A plain PDF is not a portfolio
+	paths, err = e.UnpackPortfolio(child)
+	if err != nil || len(paths) != 0 {
+		t.Errorf("UnpackPortfolio(plain PDF) = %v, %v; want none", paths, err)
+	}
+}
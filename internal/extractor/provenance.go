@@ -0,0 +1,178 @@
+package extractor
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Line is a single line of text on a page with its approximate location, so a
+// quote from the extracted text can be traced back to "page N, lower third"
+type Line struct {
+	Text     string
+	Offset   int     // byte offset of Text within the page text, -1 if not found
+	Y        float64 // baseline distance from the bottom of the page, in points
+	Position string  // "upper", "middle" or "lower" third of the page
+	Number   int     // transcript line number stripped from the margin, 0 if none
+	Box      Box     // where the line's text is drawn, zero if unknown
+}
+
+// Box is a rectangle on a page in PDF user space (points from the bottom-left
+// corner)
+type Box struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// IsZero reports whether the box is unknown
+func (b Box) IsZero() bool {
+	return b == Box{}
+}
+
+// Slice returns the box as [x0, y0, x1, y1], or nil if it is unknown
+func (b Box) Slice() []float64 {
+	if b.IsZero() {
+		return nil
+	}
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return []float64{round(b.X0), round(b.Y0), round(b.X1), round(b.Y1)}
+}
+
+// Approximate ascent and descent of a font, as fractions of its size, for
+// boxing lines without font metrics
+const (
+	lineAscent  = 0.8
+	lineDescent = 0.25
+	// averageCharWidth is a typical character width, as a fraction of the
+	// font size
+	averageCharWidth = 0.5
+)
+
+// pageLines groups the text on a page into lines and locates each one within
+// pageText (the page's plain text) and on the physical page
+func pageLines(page pdf.Page, pageText string) []Line {
+	rows, err := page.GetTextByRow()
+	if err != nil {
+		return nil
+	}
+
+	height := pageHeight(page)
+	boxes := rowBoxes(page)
+	var lines []Line
+	searchFrom := 0
+	for _, row := range rows {
+		var rowBuilder strings.Builder
+		for _, text := range row.Content {
+			rowBuilder.WriteString(text.S)
+		}
+		rowText := rowBuilder.String()
+		if strings.TrimSpace(rowText) == "" {
+			continue
+		}
+
+		// Rows come back top to bottom, which is also the order they appear
+		// in the plain text, so search forward from the previous match
+		offset := -1
+		if idx := strings.Index(pageText[searchFrom:], rowText); idx != -1 {
+			offset = searchFrom + idx
+			searchFrom = offset + len(rowText)
+		} else if idx := strings.Index(pageText, rowText); idx != -1 {
+			offset = idx
+		}
+
+		lines = append(lines, Line{
+			Text:     rowText,
+			Offset:   offset,
+			Y:        float64(row.Position),
+			Position: verticalThird(float64(row.Position), height),
+			Box:      boxes[row.Position],
+		})
+	}
+	return lines
+}
+
+// rowBoxes returns the box of the glyphs on each baseline of a page, keyed
+// like the rows of GetTextByRow (which carry no widths or font sizes)
+func rowBoxes(page pdf.Page) (boxes map[int64]Box) {
+	// The PDF library panics on some malformed content streams; lines are
+	// then left without boxes
+	defer func() {
+		if r := recover(); r != nil {
+			boxes = nil
+		}
+	}()
+
+	boxes = make(map[int64]Box)
+	sizes := make(map[int64]float64)
+	runes := make(map[int64]int)
+	for _, text := range page.Content().Text {
+		if strings.TrimSpace(text.S) == "" {
+			continue
+		}
+		key := int64(text.Y)
+		box, ok := boxes[key]
+		if !ok {
+			box = Box{X0: math.Inf(1), X1: math.Inf(-1)}
+		}
+		box.X0 = min(box.X0, text.X)
+		box.X1 = max(box.X1, text.X+text.W)
+		sizes[key] = max(sizes[key], text.FontSize)
+		runes[key] += utf8.RuneCountInString(text.S)
+		box.Y0 = text.Y - sizes[key]*lineDescent
+		box.Y1 = text.Y + sizes[key]*lineAscent
+		boxes[key] = box
+	}
+	// Fonts without width metrics (unembedded standard fonts) report zero
+	// widths; estimate the line's extent from its length instead
+	for key, box := range boxes {
+		if box.X1-box.X0 < sizes[key] {
+			box.X1 = box.X0 + float64(runes[key])*sizes[key]*averageCharWidth
+			boxes[key] = box
+		}
+	}
+	return boxes
+}
+
+// verticalThird describes where a baseline y falls on a page of the given height
+func verticalThird(y, height float64) string {
+	if height <= 0 {
+		return ""
+	}
+	switch {
+	case y >= height*2/3:
+		return "upper"
+	case y >= height/3:
+		return "middle"
+	default:
+		return "lower"
+	}
+}
+
+// pageHeight returns the height of the page's MediaBox in points, or 0 if the
+// page has no usable MediaBox
+func pageHeight(page pdf.Page) float64 {
+	box := inheritedKey(page.V, "MediaBox")
+	if box.Len() != 4 {
+		return 0
+	}
+	return box.Index(3).Float64() - box.Index(1).Float64()
+}
+
+// pageRotation returns the page's display rotation in degrees clockwise,
+// normalized to 0, 90, 180 or 270
+func pageRotation(page pdf.Page) int {
+	rotate := int(inheritedKey(page.V, "Rotate").Int64())
+	return ((rotate % 360) + 360) % 360
+}
+
+// inheritedKey looks up an inheritable page attribute, walking up the page
+// tree through Parent until it is found
+func inheritedKey(v pdf.Value, key string) pdf.Value {
+	for ; !v.IsNull(); v = v.Key("Parent") {
+		if r := v.Key(key); !r.IsNull() {
+			return r
+		}
+	}
+	return pdf.Value{}
+}
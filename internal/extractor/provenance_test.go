@@ -0,0 +1,149 @@
+package extractor
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// openPage opens the first page of the PDF at path
+func openPage(t *testing.T, path string) pdf.Page {
+	t.Helper()
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return reader.Page(1)
+}
+
+func TestVerticalThird(t *testing.T) {
+	tests := []struct {
+		y, height float64
+		want      string
+	}{
+		{720, 792, "upper"},
+		{528, 792, "upper"}, // exactly two thirds up
+		{527.9, 792, "middle"},
+		{400, 792, "middle"},
+		{264, 792, "middle"}, // exactly one third up
+		{263.9, 792, "lower"},
+		{72, 792, "lower"},
+		{0, 792, "lower"},
+		{500, 612, "upper"}, // landscape
+		{300, 0, ""},        // no MediaBox
+		{300, -792, ""},
+	}
+	for _, tt := range tests {
+		if got := verticalThird(tt.y, tt.height); got != tt.want {
+			t.Errorf("verticalThird(%v, %v) = %q, want %q", tt.y, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestPageLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageAttrs string
+		content   string
+		pageText  string
+		want      []Line // compared by Text, Offset, Y and Position
+	}{
+		{
+			name:      "located in each third",
+			pageAttrs: "/MediaBox [0 0 612 792]",
+			content:   "BT /F1 12 Tf 1 0 0 1 72 720 Tm (Flight log) Tj ET\nBT /F1 12 Tf 1 0 0 1 72 400 Tm (Tail N908JE) Tj ET\nBT /F1 12 Tf 1 0 0 1 72 100 Tm (Page 1) Tj ET",
+			pageText:  "Flight log\nTail N908JE\nPage 1",
+			want: []Line{
+				{Text: "Flight log", Offset: 0, Y: 720, Position: "upper"},
+				{Text: "Tail N908JE", Offset: 11, Y: 400, Position: "middle"},
+				{Text: "Page 1", Offset: 23, Y: 100, Position: "lower"},
+			},
+		},
+		{
+			// A repeated line is found at its own occurrence, not the first
+			name:      "repeated line",
+			pageAttrs: "/MediaBox [0 0 612 792]",
+			content:   "BT /F1 12 Tf 1 0 0 1 72 720 Tm (Redacted) Tj ET\nBT /F1 12 Tf 1 0 0 1 72 700 Tm (Redacted) Tj ET",
+			pageText:  "Redacted\nRedacted",
+			want: []Line{
+				{Text: "Redacted", Offset: 0, Y: 720, Position: "upper"},
+				{Text: "Redacted", Offset: 9, Y: 700, Position: "upper"},
+			},
+		},
+		{
+			name:      "not in the page text",
+			pageAttrs: "/MediaBox [0 0 612 792]",
+			content:   "BT /F1 12 Tf 1 0 0 1 72 720 Tm (Flight log) Tj ET",
+			pageText:  "something else",
+			want:      []Line{{Text: "Flight log", Offset: -1, Y: 720, Position: "upper"}},
+		},
+		{
+			name:     "without a MediaBox",
+			content:  "BT /F1 12 Tf 1 0 0 1 72 720 Tm (Flight log) Tj ET",
+			pageText: "Flight log",
+			want:     []Line{{Text: "Flight log", Offset: 0, Y: 720, Position: ""}},
+		},
+		{
+			// GetTextByRow places rows by the text matrix only; a Td move
+			// yields an empty row, which is skipped
+			name:      "blank rows",
+			pageAttrs: "/MediaBox [0 0 612 792]",
+			content:   "BT /F1 12 Tf 72 720 Td ET\nBT /F1 12 Tf 1 0 0 1 72 400 Tm (   ) Tj ET",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "page.pdf")
+			writePagePDF(t, path, tt.pageAttrs, "", tt.content)
+			lines := pageLines(openPage(t, path), tt.pageText)
+			if len(lines) != len(tt.want) {
+				t.Fatalf("pageLines() = %+v, want %d line(s)", lines, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := lines[i]
+				if got.Text != want.Text || got.Offset != want.Offset || got.Y != want.Y || got.Position != want.Position {
+					t.Errorf("lines[%d] = %+v, want %+v", i, got, want)
+				}
+				if got.Box.IsZero() || got.Box.Y0 >= want.Y || got.Box.Y1 <= want.Y || got.Box.X0 != 72 {
+					t.Errorf("lines[%d].Box = %+v, want a box around the baseline from x=72", i, got.Box)
+				}
+			}
+		})
+	}
+}
+
+func TestPageRotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagesAttrs string // of the Pages node the page inherits from
+		pageAttrs  string
+		want       int
+	}{
+		{name: "unrotated", want: 0},
+		{name: "quarter turn", pageAttrs: "/Rotate 90", want: 90},
+		{name: "upside down", pageAttrs: "/Rotate 180", want: 180},
+		{name: "negative", pageAttrs: "/Rotate -90", want: 270},
+		{name: "more than a turn", pageAttrs: "/Rotate 450", want: 90},
+		{name: "inherited", pagesAttrs: "/Rotate 270", want: 270},
+		{name: "overriding the parent", pagesAttrs: "/Rotate 270", pageAttrs: "/Rotate 0", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "page.pdf")
+			content := "BT /F1 12 Tf 1 0 0 1 72 720 Tm (Flight log) Tj ET"
+			writeObjectsPDF(t, path, []string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 " + tt.pagesAttrs + " >>",
+				"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] " + tt.pageAttrs + " /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+				fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+			})
+			if got := pageRotation(openPage(t, path)); got != tt.want {
+				t.Errorf("pageRotation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
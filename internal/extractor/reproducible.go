@@ -0,0 +1,59 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// stamp is when, and from which content, an output says it was extracted
+type stamp struct {
+	at           time.Time
+	sourceSHA256 string // set in reproducible mode
+}
+
+// stamp returns the stamp of an output of filePath. Normally that is the
+// current time. In reproducible mode it is derived from the document alone,
+// so extracting the same file again produces the same bytes: the time is
+// $SOURCE_DATE_EPOCH if set, or else the file's modification time, and the
+// file's checksum is recorded alongside it.
+func (e *Extractor) stamp(filePath string) (stamp, error) {
+	if !e.reproducible {
+		return stamp{at: time.Now()}, nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return stamp{}, fmt.Errorf("failed to stat document: %w", err)
+	}
+	at := info.ModTime()
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return stamp{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q", epoch)
+		}
+		at = time.Unix(seconds, 0)
+	}
+	sum, err := fileSHA256(filePath)
+	if err != nil {
+		return stamp{}, err
+	}
+	return stamp{at: at.UTC().Truncate(time.Second), sourceSHA256: sum}, nil
+}
+
+// fileSHA256 returns the hex SHA256 of a file's content
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum document: %w", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to checksum document: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
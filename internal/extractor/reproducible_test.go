@@ -0,0 +1,59 @@
+package extractor
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReproducibleOutputs(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "doc.pdf")
+	writeTextPDF(t, doc, "Flight log")
+	modified := time.Date(2019, 8, 10, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(doc, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "markdown"} {
+		ext := NewWithOptions(Options{Format: format, Reproducible: true})
+		first, err := ext.Render(doc, "")
+		if err != nil {
+			t.Fatalf("%s: Render() error = %v", format, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		second, err := ext.Render(doc, "")
+		if err != nil {
+			t.Fatalf("%s: Render() error = %v", format, err)
+		}
+		if a, b := formattedOutput(t, first), formattedOutput(t, second); !bytes.Equal(a, b) {
+			t.Errorf("%s: outputs differ between extractions:\n%s\n%s", format, a, b)
+		}
+	}
+
+	path, err := NewWithOptions(Options{Reproducible: true}).SaveExtractedText(doc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := ReadExtracted(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extracted.Metadata.ExtractedAt.Equal(modified) {
+		t.Errorf("extracted_at = %s, want the modification time %s", extracted.Metadata.ExtractedAt, modified)
+	}
+	if len(extracted.Metadata.SourceSHA256) != 64 {
+		t.Errorf("source_sha256 = %q, want a SHA256", extracted.Metadata.SourceSHA256)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	st, err := NewWithOptions(Options{Reproducible: true}).stamp(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.at.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("stamp = %s, want $SOURCE_DATE_EPOCH", st.at)
+	}
+}
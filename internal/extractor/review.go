@@ -0,0 +1,39 @@
+package extractor
+
+import "regexp"
+
+// redactionRe matches the marks left where text was withheld: bracketed or
+// capitalized REDACTED notices, FOIA exemption codes such as (b)(6) or
+// (b)(7)(C), and runs of block characters drawn over the text
+var redactionRe = regexp.MustCompile(`(?i:[\[<]\s*redacted\s*[\]>])|\bREDACTED\b|\(b\)\s*\(\s*[1-9]\s*\)(?:\s*\(\s*[A-Fa-f]\s*\))?|[█■]{2,}`)
+
+// NeedsOCR returns how many pages carry ink but too little readable text,
+// as scans without a text layer do. Blank pages and pages the tesseract
+// backend already read are not counted.
+func NeedsOCR(pages []PageText) int {
+	n := 0
+	for _, page := range pages {
+		if page.Blank || page.Backend == BackendTesseract {
+			continue
+		}
+		text := page.Text
+		if page.ImageText != "" {
+			text += "\n" + page.ImageText
+		}
+		if wordCount(text) == 0 || textQuality(text) < minSampleQuality {
+			n++
+		}
+	}
+	return n
+}
+
+// CountRedactions returns how many redaction marks the text of the pages
+// carries (see redactionRe). Redactions drawn as boxes without any text
+// are not found.
+func CountRedactions(pages []PageText) int {
+	n := 0
+	for _, page := range pages {
+		n += len(redactionRe.FindAllStringIndex(page.Text, -1))
+	}
+	return n
+}
@@ -0,0 +1,35 @@
+package extractor
+
+import "testing"
+
+func TestNeedsOCR(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "The witness was deposed at the courthouse on the morning of June 5.", Backend: BackendNative},
+		{PageNumber: 2, Backend: BackendNative},                       // a scan without a text layer
+		{PageNumber: 3, Text: "~~ ;;; ## %%", Backend: BackendNative}, // garbage from a broken text layer
+		{PageNumber: 4, Backend: BackendNative, Blank: true},          // blank
+		{PageNumber: 5, Text: "ll ;; ~~", Backend: BackendTesseract},  // OCR already tried
+		{PageNumber: 6, ImageText: "Flight log to Teterboro on the same day", Backend: BackendNative},
+	}
+	if got := NeedsOCR(pages); got != 2 {
+		t.Errorf("NeedsOCR() = %d, want 2 (pages 2 and 3)", got)
+	}
+}
+
+func TestCountRedactions(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Passenger: [REDACTED] and <redacted>", 2},
+		{"Name withheld (b)(6), (b)(7)(C) and (b) (7) (c)", 3},
+		{"Victim ████ met ■■ at the house", 2},
+		{"REDACTED", 1},
+		{"The redacted version was filed under seal (b).", 0},
+	}
+	for _, tt := range tests {
+		if got := CountRedactions([]PageText{{Text: tt.text}}); got != tt.want {
+			t.Errorf("CountRedactions(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
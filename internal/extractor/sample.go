@@ -0,0 +1,148 @@
+package extractor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// minSampleQuality is the text quality (see textQuality) below which a
+// sampled page is taken to need OCR
+const minSampleQuality = 0.7
+
+// SamplePage is how extraction went on one sampled page
+type SamplePage struct {
+	PageNumber int     `json:"page_number"`
+	Words      int     `json:"words"`
+	Quality    float64 `json:"quality"` // share of the text that reads as words, 0 for no text
+	Blank      bool    `json:"blank,omitempty"`
+	Backend    string  `json:"backend,omitempty"`
+	NeedsOCR   bool    `json:"needs_ocr,omitempty"` // no text, or too little of it reads as words
+}
+
+// SampleReport estimates how extracting a whole document will go from a few
+// of its pages
+type SampleReport struct {
+	Document    string       `json:"document"`
+	TotalPages  int          `json:"total_pages"`
+	Pages       []SamplePage `json:"pages"`
+	ElapsedMS   int64        `json:"elapsed_ms"`   // time taken to extract the sample
+	EstimatedMS int64        `json:"estimated_ms"` // extrapolated time to extract every page
+	Quality     float64      `json:"quality"`      // mean quality of the sampled pages that are not blank
+	NeedsOCR    int          `json:"needs_ocr"`    // sampled pages that need OCR
+}
+
+// ScanLikely reports whether at least half of the sampled pages that are not
+// blank need OCR: the document is a scan, or its text layer is garbled
+func (r *SampleReport) ScanLikely() bool {
+	textPages := len(r.Pages) - r.blankPages()
+	return textPages > 0 && 2*r.NeedsOCR >= textPages
+}
+
+// SamplePages returns n page numbers spread evenly over a document of total
+// pages, including the first and last, or every page if it has no more
+// than n
+func SamplePages(total, n int) []int {
+	if n <= 0 || total <= 0 {
+		return nil
+	}
+	if n >= total {
+		n = total
+	}
+	numbers := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		page := 1
+		if n > 1 {
+			page = 1 + i*(total-1)/(n-1)
+		}
+		numbers = append(numbers, page)
+	}
+	return numbers
+}
+
+// Sample extracts n evenly spaced pages of a document, with the extractor's
+// usual fallbacks and OCR, and estimates from them the quality of its text
+// and how long extracting all of it would take. Nothing is written.
+func (e *Extractor) Sample(filePath string, n int) (*SampleReport, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("sample size must be at least 1, got %d", n)
+	}
+	var pages []PageText
+	var total int
+	var numbers []int
+	started := time.Now()
+	if strings.EqualFold(filepath.Ext(filePath), ".pdf") {
+		totalPages, err := e.pageCount(filePath)
+		if err != nil {
+			return nil, err
+		}
+		numbers = SamplePages(totalPages, n)
+		started = time.Now() // only the pages' extraction is extrapolated
+		if pages, total, _, err = e.extractPDFPages(filePath, numbers); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if pages, _, total, err = e.ExtractTextStructured(filePath); err != nil {
+			return nil, err
+		}
+		numbers = SamplePages(total, n)
+	}
+
+	elapsed := time.Since(started)
+	report := &SampleReport{Document: filePath, TotalPages: total, ElapsedMS: elapsed.Milliseconds()}
+	byNumber := make(map[int]PageText, len(pages))
+	for _, page := range pages {
+		byNumber[page.PageNumber] = page
+	}
+	var qualitySum float64
+	for _, number := range numbers {
+		page := byNumber[number]
+		text := page.Text
+		if page.ImageText != "" {
+			text += "\n" + page.ImageText
+		}
+		sampled := SamplePage{PageNumber: number, Blank: page.Blank, Backend: page.Backend}
+		if !page.Blank {
+			sampled.Words = wordCount(text)
+			if sampled.Words > 0 {
+				sampled.Quality = textQuality(text)
+			}
+			sampled.NeedsOCR = sampled.Quality < minSampleQuality
+			qualitySum += sampled.Quality
+			if sampled.NeedsOCR {
+				report.NeedsOCR++
+			}
+		}
+		report.Pages = append(report.Pages, sampled)
+	}
+	if textPages := len(report.Pages) - report.blankPages(); textPages > 0 {
+		report.Quality = qualitySum / float64(textPages)
+	}
+	if len(numbers) > 0 {
+		report.EstimatedMS = (elapsed * time.Duration(total) / time.Duration(len(numbers))).Milliseconds()
+	}
+	return report, nil
+}
+
+// blankPages returns the number of sampled pages that are blank
+func (r *SampleReport) blankPages() int {
+	blank := 0
+	for _, page := range r.Pages {
+		if page.Blank {
+			blank++
+		}
+	}
+	return blank
+}
+
+// pageCount returns the number of pages of a PDF
+func (e *Extractor) pageCount(filePath string) (int, error) {
+	reader, file, err := e.openPDF(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open document: %w (document may be encrypted or in an unsupported format)", err)
+	}
+	defer file.Close()
+	return reader.NumPage(), nil
+}
@@ -0,0 +1,36 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSamplePages(t *testing.T) {
+	for _, tt := range []struct {
+		total, n int
+		want     []int
+	}{
+		{120, 5, []int{1, 30, 60, 90, 120}},
+		{3, 5, []int{1, 2, 3}},
+		{10, 1, []int{1}},
+		{0, 5, nil},
+	} {
+		if got := SamplePages(tt.total, tt.n); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SamplePages(%d, %d) = %v, want %v", tt.total, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSampleReportScanLikely(t *testing.T) {
+	report := &SampleReport{
+		Pages:    []SamplePage{{PageNumber: 1, Words: 300, Quality: 0.98}, {PageNumber: 2, Blank: true}, {PageNumber: 3, NeedsOCR: true}},
+		NeedsOCR: 1,
+	}
+	if !report.ScanLikely() {
+		t.Error("ScanLikely() = false with half the text pages needing OCR")
+	}
+	report.Pages = append(report.Pages, SamplePage{PageNumber: 4, Words: 250, Quality: 0.95})
+	if report.ScanLikely() {
+		t.Error("ScanLikely() = true with one of three text pages needing OCR")
+	}
+}
@@ -0,0 +1,121 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Shard describes one part of a JSON extraction output that was split
+// because it exceeded the size limit. The index output (at the usual output
+// path) lists the shards; each shard is a complete JSON output holding a run
+// of pages, stored next to the index as {output}.shard-NNN.json.
+type Shard struct {
+	Number    int   `json:"shard"`
+	FirstPage int   `json:"first_page"`
+	LastPage  int   `json:"last_page"`
+	Bytes     int64 `json:"bytes"` // size before compression
+}
+
+// ShardPath returns the path of shard n of the JSON output at path:
+// EFTA00010724.extracted.json.gz has EFTA00010724.extracted.shard-001.json.gz
+func ShardPath(path string, n int) string {
+	suffix := ""
+	for _, s := range compressionSuffixes {
+		if s != "" && strings.HasSuffix(path, s) {
+			suffix = s
+		}
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(path, suffix), ".json")
+	return fmt.Sprintf("%s.shard-%03d.json%s", base, n, suffix)
+}
+
+// shard splits the JSON document of out, larger than the extractor's size
+// limit, into shards stored next to it
+func (e *Extractor) shard(out *Output) (*Output, error) {
+	whole := out.JSON
+
+	// Fill each shard with pages up to the limit, less the metadata every
+	// shard repeats; a page larger than that gets a shard of its own
+	metadata, err := json.MarshalIndent(whole.Metadata, "  ", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to shard extraction output: %w", err)
+	}
+	budget := e.maxOutputSize - int64(len(metadata))
+	var groups [][]Page
+	var size int64
+	for _, page := range whole.Content.Pages {
+		encoded, err := json.MarshalIndent(page, "      ", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to shard extraction output: %w", err)
+		}
+		// The page appears in the shard's full text as well as its pages
+		pageSize := int64(len(encoded) + len(page.Text))
+		if n := len(groups); n == 0 || size+pageSize > budget {
+			groups = append(groups, nil)
+			size = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], page)
+		size += pageSize
+	}
+
+	index := ExtractedText{Metadata: whole.Metadata, Content: Content{Pages: []Page{}}}
+	for i, pages := range groups {
+		shard := Shard{Number: i + 1, FirstPage: pages[0].PageNumber, LastPage: pages[len(pages)-1].PageNumber}
+		part := ExtractedText{Metadata: whole.Metadata, Content: Content{FullText: joinPageText(pages), Pages: pages}}
+		locatePages(part.Content.Pages, part.Content.FullText)
+		header := shard // the shard's own copy leaves out its size
+		part.Metadata.Shard = &header
+		shardOut := &Output{Document: out.Document, Path: ShardPath(out.Path, shard.Number), JSON: &part, WriteOnce: out.WriteOnce, compact: out.compact, compression: out.compression}
+		if shard.Bytes, err = shardOut.size(); err != nil {
+			return nil, fmt.Errorf("failed to format shard: %w", err)
+		}
+		index.Shards = append(index.Shards, shard)
+		out.Shards = append(out.Shards, shardOut)
+	}
+	// Destinations that take a whole output (stdout, search indexes) still
+	// get the unsharded document
+	out.index = &index
+	return out, nil
+}
+
+// readShards fills in the pages and full text of a shard index from its
+// shards, stored next to the index at path
+func readShards(path string, index *ExtractedText) error {
+	var full strings.Builder
+	for i, shard := range index.Shards {
+		part, err := ReadExtracted(ShardPath(path, shard.Number))
+		if err != nil {
+			return fmt.Errorf("failed to read shard %d of %s: %w", shard.Number, filepath.Base(path), err)
+		}
+		if i > 0 {
+			full.WriteString(fmt.Sprintf("\n\n--- Page %d ---\n\n", shard.FirstPage))
+		}
+		// Page offsets are within the shard's full text
+		base := full.Len()
+		for _, page := range part.Content.Pages {
+			if page.FullTextStart >= 0 {
+				page.FullTextStart += base
+				page.FullTextEnd += base
+			}
+			index.Content.Pages = append(index.Content.Pages, page)
+		}
+		full.WriteString(part.Content.FullText)
+	}
+	index.Content.FullText = full.String()
+	return nil
+}
+
+// joinPageText concatenates the text of output pages with the separators of
+// a whole-file extraction
+func joinPageText(pages []Page) string {
+	var builder strings.Builder
+	for i, page := range pages {
+		if i > 0 {
+			builder.WriteString(fmt.Sprintf("\n\n--- Page %d ---\n\n", page.PageNumber))
+		}
+		builder.WriteString(page.Text)
+	}
+	return builder.String()
+}
@@ -0,0 +1,85 @@
+package extractor
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShardPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"EFTA00010724.extracted.json":             "EFTA00010724.extracted.shard-001.json",
+		"EFTA00010724.extracted.json.gz":          "EFTA00010724.extracted.shard-001.json.gz",
+		"EFTA00010724.extracted.part-02.json.zst": "EFTA00010724.extracted.part-02.shard-001.json.zst",
+	} {
+		if got := ShardPath(path, 1); got != want {
+			t.Errorf("ShardPath(%q, 1) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestShardedOutput(t *testing.T) {
+	var pages []Page
+	for i := 1; i <= 10; i++ {
+		pages = append(pages, Page{PageNumber: i, Text: strings.Repeat("flight log ", 100)})
+	}
+	whole := ExtractedText{
+		Metadata: Metadata{Filename: "big.pdf", TotalPages: 10, PagesExtracted: 10, FormatVersion: FormatVersion},
+		Content:  Content{FullText: joinPageText(pages), Pages: pages},
+	}
+	locatePages(whole.Content.Pages, whole.Content.FullText)
+	formatted, err := json.MarshalIndent(whole, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		dir := t.TempDir()
+		docPath := filepath.Join(dir, "big.pdf")
+		e := NewWithOptions(Options{Compression: compression, MaxOutputSize: 6000})
+		out, err := e.renderJSON(docPath, e.OutputPath(docPath), &whole)
+		if err != nil {
+			t.Fatalf("renderJSON() error = %v", err)
+		}
+		if len(out.Shards) < 2 {
+			t.Fatalf("%d shard(s), want several", len(out.Shards))
+		}
+		for _, shard := range out.Shards {
+			if size := len(formattedOutput(t, shard)); size > 6000 {
+				t.Errorf("shard %s is %d bytes, over the limit", filepath.Base(shard.Path), size)
+			}
+		}
+		if string(formattedOutput(t, out)) != string(formatted) {
+			t.Error("Formatted is not the whole output")
+		}
+		if _, err := e.write(out); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadExtracted(e.FindOutput(docPath))
+		if err != nil {
+			t.Fatalf("ReadExtracted() error = %v", err)
+		}
+		if len(read.Shards) != len(out.Shards) || len(read.Content.Pages) != 10 {
+			t.Fatalf("read %d shard(s) and %d page(s), want %d and 10", len(read.Shards), len(read.Content.Pages), len(out.Shards))
+		}
+		for i, page := range read.Content.Pages {
+			if page.PageNumber != i+1 {
+				t.Errorf("page %d numbered %d", i+1, page.PageNumber)
+			}
+			if want := whole.Content.Pages[i]; page.FullTextStart != want.FullTextStart || page.FullTextEnd != want.FullTextEnd {
+				t.Errorf("page %d at %d-%d of the full text, want %d-%d", i+1, page.FullTextStart, page.FullTextEnd, want.FullTextStart, want.FullTextEnd)
+			}
+		}
+		if read.Content.FullText != whole.Content.FullText {
+			t.Error("full text of the reassembled output differs from the whole output's")
+		}
+	}
+
+	// Small outputs are left whole
+	e := NewWithOptions(Options{MaxOutputSize: int64(len(formatted))})
+	if out, err := e.renderJSON("big.pdf", "big.extracted.json", &whole); err != nil || len(out.Shards) != 0 {
+		t.Errorf("renderJSON() at the limit = %d shard(s), %v; want none", len(out.Shards), err)
+	}
+}
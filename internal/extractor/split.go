@@ -0,0 +1,237 @@
+package extractor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// Reasons a segment starts where it does
+const (
+	BoundaryStart      = "start"       // first page of the file
+	BoundaryBlankPage  = "blank-page"  // follows one or more blank separator pages
+	BoundaryBatesReset = "bates-reset" // Bates prefix changed or the number went backwards
+	BoundaryCoverPage  = "cover-page"  // an exhibit/attachment slip sheet
+)
+
+// Segment is a logical sub-document within a file that concatenates several
+// distinct documents (e.g. a run of exhibits)
+type Segment struct {
+	Part      int    `json:"part"`       // 1-based position within the file
+	Of        int    `json:"of"`         // number of segments in the file
+	StartPage int    `json:"start_page"` // first page, inclusive
+	EndPage   int    `json:"end_page"`   // last page, inclusive
+	Reason    string `json:"reason"`     // why the segment starts here
+}
+
+// coverPageMaxWords is the most words a page can hold and still be treated as
+// an exhibit slip sheet
+const coverPageMaxWords = 30
+
+// coverLineRe matches a slip-sheet title such as "EXHIBIT A" or "Attachment 3"
+var coverLineRe = regexp.MustCompile(`(?i)^\s*(?:exhibit|attachment|appendix|tab)\s+[A-Z0-9][-A-Z0-9.]*\s*$`)
+
+// DetectSegments splits a file's pages into logical sub-documents using Bates
+// number resets, exhibit cover pages and blank separator pages. Pages missing
+// from pages (no extractable text) or flagged Blank count as blank. A file with no detected
+// boundaries yields a single segment.
+func DetectSegments(pages []PageText) []Segment {
+	var segments []Segment
+	var prevBates legal.Bates
+	var havePrevBates bool
+	prevPage := 0
+
+	for _, page := range pages {
+		if isBlankText(page) {
+			continue
+		}
+		text := lineText(page)
+		bates, hasBates := legal.FindBates(text)
+
+		reason := ""
+		switch {
+		case len(segments) == 0:
+			reason = BoundaryStart
+		case isCoverPage(text):
+			reason = BoundaryCoverPage
+		case hasBates && havePrevBates && !bates.Follows(prevBates):
+			reason = BoundaryBatesReset
+		case page.PageNumber > prevPage+1 && !isCoverSegment(segments[len(segments)-1]):
+			// A blank slip sheet after a cover page belongs to that exhibit
+			reason = BoundaryBlankPage
+		}
+
+		if reason != "" {
+			segments = append(segments, Segment{StartPage: page.PageNumber, Reason: reason})
+		}
+		segments[len(segments)-1].EndPage = page.PageNumber
+		prevPage = page.PageNumber
+		if hasBates {
+			prevBates, havePrevBates = bates, true
+		}
+	}
+
+	for i := range segments {
+		segments[i].Part = i + 1
+		segments[i].Of = len(segments)
+	}
+	return segments
+}
+
+// lineText returns the page text with line breaks restored from its
+// positioned lines where available, since the plain page text can run lines
+// (and so a footer Bates stamp) together
+func lineText(page PageText) string {
+	if len(page.Lines) == 0 {
+		return page.Text
+	}
+	lines := make([]string, len(page.Lines))
+	for i, line := range page.Lines {
+		lines[i] = line.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isCoverPage reports whether text looks like an exhibit slip sheet: a short
+// page whose first line is "EXHIBIT A" or similar
+func isCoverPage(text string) bool {
+	if len(strings.Fields(text)) > coverPageMaxWords {
+		return false
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return coverLineRe.MatchString(line)
+	}
+	return false
+}
+
+// isCoverSegment reports whether a segment so far holds only its cover page
+func isCoverSegment(s Segment) bool {
+	return s.Reason == BoundaryCoverPage && s.StartPage == s.EndPage
+}
+
+// PartOutputPath returns the path the extraction output for one segment of
+// filePath is written to, e.g. name.extracted.part-02.json
+func (e *Extractor) PartOutputPath(filePath string, part int) string {
+	outputPath := e.OutputPath(filePath)
+	dir, base := filepath.Split(outputPath)
+	idx := strings.LastIndex(base, ".extracted.")
+	return filepath.Join(dir, base[:idx]+fmt.Sprintf(".extracted.part-%02d.", part)+base[idx+len(".extracted."):])
+}
+
+// SaveSegments detects logical sub-documents in filePath and, if there is
+// more than one, saves an extraction output for each next to the document.
+// It returns the detected segments and the paths written (nil for a file
+// that holds a single document).
+func (e *Extractor) SaveSegments(filePath string) ([]Segment, []string, error) {
+	pages, _, _, err := e.ExtractTextStructured(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.SavePageSegments(filePath, pages)
+}
+
+// SavePageSegments is SaveSegments for a document already extracted with
+// ExtractTextStructured, splitting its pages without extracting it again
+func (e *Extractor) SavePageSegments(filePath string, pages []PageText) ([]Segment, []string, error) {
+	segments, outputs, err := e.RenderPageSegments(filePath, pages)
+	if err != nil {
+		return nil, nil, err
+	}
+	var written []string
+	for _, out := range outputs {
+		path, err := e.write(out)
+		if err != nil {
+			return nil, nil, err
+		}
+		written = append(written, path)
+	}
+	return segments, written, nil
+}
+
+// RenderSegments is SaveSegments without writing: it returns the detected
+// segments and, for a file holding more than one document, an output per part
+func (e *Extractor) RenderSegments(filePath string) ([]Segment, []*Output, error) {
+	pages, _, _, err := e.ExtractTextStructured(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e.RenderPageSegments(filePath, pages)
+}
+
+// RenderPageSegments is RenderSegments for a document already extracted
+// with ExtractTextStructured, splitting its pages without extracting it again
+func (e *Extractor) RenderPageSegments(filePath string, pages []PageText) ([]Segment, []*Output, error) {
+	if e.citations {
+		findCitations(pages)
+	}
+	segments := DetectSegments(pages)
+	if len(segments) < 2 {
+		return segments, nil, nil
+	}
+
+	st, err := e.stamp(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var outputs []*Output
+	for _, segment := range segments {
+		partPages := pagesInRange(pages, segment.StartPage, segment.EndPage)
+		fullText := joinPages(partPages)
+
+		var out *Output
+		switch e.outputFormat {
+		case "json":
+			extracted := newExtractedText(filePath, partPages, fullText)
+			extracted.Metadata.ExtractedAt, extracted.Metadata.SourceSHA256 = st.at, st.sourceSHA256
+			part := segment
+			extracted.Metadata.Part = &part
+			if extracted.Metadata.Curated, err = meta.Load(filePath); err != nil {
+				return nil, nil, err
+			}
+			if out, err = e.renderJSON(filePath, e.PartOutputPath(filePath, segment.Part), &extracted); err != nil {
+				return nil, nil, err
+			}
+		case "markdown":
+			content, err := formatMarkdown(filePath, partPages, fullText, st)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to format as Markdown: %w", err)
+			}
+			out = e.render(filePath, e.PartOutputPath(filePath, segment.Part), content)
+		default: // plain
+			out = e.render(filePath, e.PartOutputPath(filePath, segment.Part), []byte(fullText))
+		}
+		outputs = append(outputs, out)
+	}
+	return segments, outputs, nil
+}
+
+// pagesInRange returns the pages numbered from start to end inclusive
+func pagesInRange(pages []PageText, start, end int) []PageText {
+	var selected []PageText
+	for _, page := range pages {
+		if page.PageNumber >= start && page.PageNumber <= end {
+			selected = append(selected, page)
+		}
+	}
+	return selected
+}
+
+// joinPages concatenates page text with the same separators as the full text
+// of a whole-file extraction
+func joinPages(pages []PageText) string {
+	var builder strings.Builder
+	for i, page := range pages {
+		if i > 0 {
+			builder.WriteString(fmt.Sprintf("\n\n--- Page %d ---\n\n", page.PageNumber))
+		}
+		builder.WriteString(page.Text)
+	}
+	return builder.String()
+}
@@ -0,0 +1,50 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectSegments(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "Letter to counsel\nEFTA00000101"},
+		{PageNumber: 2, Text: "continued\nEFTA00000102"},
+		// page 3 is a blank separator
+		{PageNumber: 4, Text: "Memo\nEFTA00000104"},
+		{PageNumber: 5, Text: "Flight log\nEFTA00000001"},
+		{PageNumber: 6, Text: "EXHIBIT B"},
+		// page 7 is a blank slip sheet belonging to the exhibit
+		{PageNumber: 8, Text: "Deposition transcript"},
+		{PageNumber: 9, Text: "more testimony"},
+	}
+	got := DetectSegments(pages)
+	want := []Segment{
+		{Part: 1, Of: 4, StartPage: 1, EndPage: 2, Reason: BoundaryStart},
+		{Part: 2, Of: 4, StartPage: 4, EndPage: 4, Reason: BoundaryBlankPage},
+		{Part: 3, Of: 4, StartPage: 5, EndPage: 5, Reason: BoundaryBatesReset},
+		{Part: 4, Of: 4, StartPage: 6, EndPage: 9, Reason: BoundaryCoverPage},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectSegments() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDetectSegmentsSingleDocument(t *testing.T) {
+	pages := []PageText{
+		{PageNumber: 1, Text: "Page one\nEFTA00000010"},
+		{PageNumber: 2, Text: "Page two\nEFTA00000011"},
+	}
+	got := DetectSegments(pages)
+	if len(got) != 1 || got[0].StartPage != 1 || got[0].EndPage != 2 {
+		t.Errorf("DetectSegments() = %+v, want one segment covering pages 1-2", got)
+	}
+}
+
+func TestPartOutputPath(t *testing.T) {
+	e := NewWithOptions(Options{Compression: CompressionGzip})
+	got := e.PartOutputPath("documents/pdf/a/a.pdf", 2)
+	want := "documents/pdf/a/a.extracted.part-02.json.gz"
+	if got != want {
+		t.Errorf("PartOutputPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package extractor
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BackendTika is the Apache Tika server, used as a last resort when
+// configured
+const BackendTika = "tika"
+
+// tikaTimeout bounds one Tika request; large scanned documents take a while
+const tikaTimeout = 10 * time.Minute
+
+// tikaFormats are the extensions of the document formats without a native
+// extractor that are handed to Tika when it is configured
+var tikaFormats = map[string]bool{
+	".doc": true, ".docx": true, ".rtf": true, ".odt": true, ".wpd": true,
+	".xls": true, ".xlsx": true, ".ods": true, ".ppt": true, ".pptx": true,
+	".msg": true, ".htm": true, ".html": true, ".txt": true,
+}
+
+// Supports reports whether the extractor can extract text from filePath:
+// whatever IsSupported accepts, and with a Tika server configured, office
+// documents, email and the other formats Tika reads
+func (e *Extractor) Supports(filePath string) bool {
+	return IsSupported(filePath) || (e.tikaURL != "" && tikaFormats[strings.ToLower(filepath.Ext(filePath))])
+}
+
+var (
+	// tikaPageRe matches the start of a page in Tika's XHTML output
+	tikaPageRe = regexp.MustCompile(`<div class="page">`)
+	// tikaBreakRe matches the tags ending a block of text
+	tikaBreakRe = regexp.MustCompile(`(?i)</(?:p|div|h[1-6]|li|tr)>|<br\s*/?>`)
+	// tikaCellRe matches the tags ending a table cell
+	tikaCellRe = regexp.MustCompile(`(?i)</t[dh]>`)
+	tagRe      = regexp.MustCompile(`<[^>]*>`)
+	blankRunRe = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+)
+
+// extractWithTika sends a document to the Tika server and splits the XHTML
+// it returns into pages. Formats without pages come back as a single page.
+func (e *Extractor) extractWithTika(filePath string) ([]PageText, string, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to open document: %w", err)
+	}
+	defer file.Close()
+
+	req, err := http.NewRequest(http.MethodPut, strings.TrimRight(e.tikaURL, "/")+"/tika", file)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("tika backend: %w", err)
+	}
+	req.Header.Set("Accept", "text/html")
+	// Tika detects the type from the content, helped by the name
+	req.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(filePath)))
+	client := &http.Client{Timeout: tikaTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("tika backend: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("tika backend: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", 0, fmt.Errorf("tika backend: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	pages := parseTikaXHTML(string(body))
+	total := len(pages)
+	var withText []PageText
+	for _, page := range pages {
+		if page.Text != "" {
+			withText = append(withText, page)
+		}
+	}
+	fullText := joinFullText(withText)
+	if fullText == "" {
+		return nil, "", 0, fmt.Errorf("no text could be extracted from the document (tika found none)")
+	}
+	return withText, fullText, total, nil
+}
+
+// parseTikaXHTML turns Tika's XHTML output into pages of plain text, one per
+// <div class="page"> (PDFs and presentations), or one for the whole body
+func parseTikaXHTML(doc string) []PageText {
+	if i := strings.Index(doc, "<body"); i >= 0 {
+		doc = doc[i:]
+	}
+	chunks := tikaPageRe.Split(doc, -1)
+	if len(chunks) > 1 {
+		chunks = chunks[1:] // the body before the first page holds no text
+	}
+	pages := make([]PageText, len(chunks))
+	for i, chunk := range chunks {
+		text := tikaBreakRe.ReplaceAllString(chunk, "\n")
+		text = tikaCellRe.ReplaceAllString(text, "\t")
+		text = html.UnescapeString(tagRe.ReplaceAllString(text, ""))
+		text = blankRunRe.ReplaceAllString(text, "\n\n")
+		pages[i] = PageText{PageNumber: i + 1, Text: strings.TrimSpace(text), Backend: BackendTika}
+	}
+	return pages
+}
@@ -0,0 +1,69 @@
+package extractor
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tikaResponse is XHTML as Tika returns it for a two-page document
+const tikaResponse = `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Memo</title></head>
+<body><div class="page"><p>MEMORANDUM</p>
+<p>Re: flight logs &amp; manifests</p>
+</div><div class="page"><table><tr><td>Date</td><td>Passengers</td></tr></table>
+<p/>
+</div></body></html>`
+
+func TestExtractWithTika(t *testing.T) {
+	var got []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/tika" || r.Header.Get("Accept") != "text/html" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		got, _ = io.ReadAll(r.Body)
+		io.WriteString(w, tikaResponse)
+	}))
+	defer server.Close()
+
+	docPath := filepath.Join(t.TempDir(), "memo.doc")
+	if err := os.WriteFile(docPath, []byte("\xd0\xcf\x11\xe0 word 97"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if New().Supports(docPath) {
+		t.Error("Supports(.doc) = true without a Tika server")
+	}
+	e := NewWithOptions(Options{TikaURL: server.URL + "/"})
+	if !e.Supports(docPath) {
+		t.Fatal("Supports(.doc) = false with a Tika server")
+	}
+	pages, _, total, err := e.ExtractTextStructured(docPath)
+	if err != nil {
+		t.Fatalf("ExtractTextStructured() error = %v", err)
+	}
+	if string(got) != "\xd0\xcf\x11\xe0 word 97" {
+		t.Errorf("Tika received %q", got)
+	}
+	if total != 2 || len(pages) != 2 {
+		t.Fatalf("got %d of %d pages, want 2 of 2", len(pages), total)
+	}
+	if want := "MEMORANDUM\n\nRe: flight logs & manifests"; pages[0].Text != want || pages[0].Backend != BackendTika {
+		t.Errorf("page 1 = %q (%s), want %q from tika", pages[0].Text, pages[0].Backend, want)
+	}
+	if want := "Date\tPassengers"; pages[1].Text != want {
+		t.Errorf("page 2 = %q, want %q", pages[1].Text, want)
+	}
+}
+
+func TestExtractWithoutTika(t *testing.T) {
+	docPath := filepath.Join(t.TempDir(), "memo.doc")
+	if err := os.WriteFile(docPath, []byte("word 97"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := New().ExtractTextStructured(docPath); err == nil {
+		t.Error("ExtractTextStructured(.doc) succeeded without a Tika server")
+	}
+}
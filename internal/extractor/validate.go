@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// trailerWindow is how many bytes at the end of a PDF are searched for %%EOF;
+// writers may append a little whitespace or garbage after it
+const trailerWindow = 1024
+
+// Validate cheaply checks that a downloaded document is intact, without
+// extracting any text, and returns its page count. For PDFs it checks for
+// the end-of-file marker a truncated transfer loses and that the
+// cross-reference table and page tree parse. Other file types are not
+// checked and report 0 pages.
+func Validate(filePath string) (pages int, err error) {
+	if strings.ToLower(filepath.Ext(filePath)) != ".pdf" {
+		return 0, nil
+	}
+
+	if err := checkTrailer(filePath); err != nil {
+		return 0, err
+	}
+
+	file, reader, err := pdf.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("document does not parse as PDF: %w", err)
+	}
+	defer file.Close()
+
+	// The PDF library panics on some malformed objects
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = 0, fmt.Errorf("document does not parse as PDF: %v", r)
+		}
+	}()
+
+	pages = reader.NumPage()
+	if pages == 0 {
+		return 0, fmt.Errorf("document has no pages")
+	}
+	return pages, nil
+}
+
+// checkTrailer verifies that a PDF ends with a %%EOF marker
+func checkTrailer(filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size() - trailerWindow
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return err
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return fmt.Errorf("missing %%%%EOF marker (%d bytes; the transfer was probably truncated)", info.Size())
+	}
+	return nil
+}
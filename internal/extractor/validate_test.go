@@ -0,0 +1,30 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTruncatedPDF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cut.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Validate(path)
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("Validate() error = %v, want truncation error", err)
+	}
+}
+
+func TestValidateSkipsOtherTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("plain text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pages, err := Validate(path)
+	if pages != 0 || err != nil {
+		t.Errorf("Validate() = %d, %v, want 0, nil", pages, err)
+	}
+}
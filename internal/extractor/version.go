@@ -0,0 +1,124 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionPath returns the path of version n of the output at path. Version 1
+// is path itself; later versions of EFTA00010724.extracted.json.gz are
+// EFTA00010724.extracted.v2.json.gz and so on.
+func VersionPath(path string, n int) string {
+	if n <= 1 {
+		return path
+	}
+	suffix := compressionSuffix(path)
+	trimmed := strings.TrimSuffix(path, suffix)
+	ext := filepath.Ext(trimmed)
+	return fmt.Sprintf("%s.v%d%s%s", strings.TrimSuffix(trimmed, ext), n, ext, suffix)
+}
+
+// LatestVersion returns the path and number of the latest version of the
+// output at path, or "" and 0 if it has never been written
+func LatestVersion(path string) (string, int) {
+	latest := 0
+	if _, err := os.Stat(path); err == nil {
+		latest = 1
+	}
+	suffix := compressionSuffix(path)
+	trimmed := strings.TrimSuffix(path, suffix)
+	ext := filepath.Ext(trimmed)
+	stem := filepath.Base(strings.TrimSuffix(trimmed, ext))
+	versionRe := regexp.MustCompile(`^` + regexp.QuoteMeta(stem) + `\.v([0-9]+)` + regexp.QuoteMeta(ext+suffix) + `$`)
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, entry := range entries {
+		if match := versionRe.FindStringSubmatch(entry.Name()); match != nil {
+			if n, err := strconv.Atoi(match[1]); err == nil && n > latest {
+				latest = n
+			}
+		}
+	}
+	if latest == 0 {
+		return "", 0
+	}
+	return VersionPath(path, latest), latest
+}
+
+// compressionSuffix returns the compression suffix path ends with, if any
+func compressionSuffix(path string) string {
+	for _, s := range compressionSuffixes {
+		if s != "" && strings.HasSuffix(path, s) {
+			return s
+		}
+	}
+	return ""
+}
+
+// Destination returns where to write out when its usual location is path.
+// That is path itself unless the output is write-once; then an existing
+// output is never replaced: out goes to the next version of path, or, when
+// the latest version already holds the same extraction (apart from when it
+// was made), nowhere, and Destination returns that version and false.
+func (out *Output) Destination(path string) (string, bool, error) {
+	if !out.WriteOnce {
+		return path, true, nil
+	}
+	latest, n := LatestVersion(path)
+	if n == 0 {
+		return path, true, nil
+	}
+	same, err := sameExtraction(latest, out)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to compare with %s: %w", latest, err)
+	}
+	if same {
+		return latest, false, nil
+	}
+	return VersionPath(path, n+1), true, nil
+}
+
+// markdownExtractedRe matches the extraction time line of Markdown outputs
+var markdownExtractedRe = regexp.MustCompile(`(?m)^\*\*Extracted:\*\* .*\n`)
+
+// sameExtraction reports whether the output at path holds the same
+// extraction as out, disregarding when each was made
+func sameExtraction(path string, out *Output) (bool, error) {
+	switch {
+	case strings.HasSuffix(strings.TrimSuffix(path, compressionSuffix(path)), ".json"):
+		if out.JSON == nil {
+			return false, nil
+		}
+		stored, err := ReadExtracted(path)
+		if err != nil {
+			return false, err
+		}
+		a, _ := json.Marshal(withoutRunDetails(stored))
+		b, _ := json.Marshal(withoutRunDetails(out.JSON))
+		return bytes.Equal(a, b), nil
+	default:
+		stored, err := ReadOutput(path)
+		if err != nil {
+			return false, err
+		}
+		stored = markdownExtractedRe.ReplaceAll(stored, nil)
+		return bytes.Equal(stored, markdownExtractedRe.ReplaceAll(out.Formatted, nil)), nil
+	}
+}
+
+// withoutRunDetails strips what differs between two runs extracting the same
+// text: the extraction time, and how the output was split into shards
+func withoutRunDetails(extracted *ExtractedText) ExtractedText {
+	c := *extracted
+	c.Metadata.ExtractedAt = time.Time{}
+	c.Metadata.Shard = nil
+	c.Shards = nil
+	c.Content.FullText = "" // rebuilt slightly differently from shards; the pages hold the same text
+	return c
+}
@@ -0,0 +1,72 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVersionPath(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		n    int
+		want string
+	}{
+		{"EFTA00010724.extracted.json", 1, "EFTA00010724.extracted.json"},
+		{"EFTA00010724.extracted.json", 2, "EFTA00010724.extracted.v2.json"},
+		{"EFTA00010724.extracted.md.zst", 3, "EFTA00010724.extracted.v3.md.zst"},
+		{"EFTA00010724.extracted.part-02.json.gz", 2, "EFTA00010724.extracted.part-02.v2.json.gz"},
+	} {
+		if got := VersionPath(tt.path, tt.n); got != tt.want {
+			t.Errorf("VersionPath(%q, %d) = %q, want %q", tt.path, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestWriteOnce(t *testing.T) {
+	docPath := filepath.Join(t.TempDir(), "EFTA00010724.pdf")
+	e := NewWithOptions(Options{WriteOnce: true, Compression: CompressionGzip})
+	write := func(text string, at time.Time) string {
+		t.Helper()
+		extracted := ExtractedText{
+			Metadata: Metadata{Filename: "EFTA00010724.pdf", ExtractedAt: at, TotalPages: 1, PagesExtracted: 1, FormatVersion: FormatVersion},
+			Content:  Content{FullText: text, Pages: []Page{{PageNumber: 1, Text: text}}},
+		}
+		out, err := e.renderJSON(docPath, e.OutputPath(docPath), &extracted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := e.write(out)
+		if err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+		return path
+	}
+
+	first := write("Flight log", time.Now())
+	if first != e.OutputPath(docPath) {
+		t.Errorf("first output written to %s, want the usual path", first)
+	}
+	before, _ := os.Stat(first)
+
+	// A rerun extracting the same text leaves the output alone
+	if path := write("Flight log", time.Now().Add(time.Hour)); path != first {
+		t.Errorf("same extraction written to %s, want %s kept", path, first)
+	}
+	if after, _ := os.Stat(first); !after.ModTime().Equal(before.ModTime()) {
+		t.Error("same extraction rewrote the output")
+	}
+
+	// A different extraction becomes version 2, which readers then use
+	second := write("Flight log, corrected", time.Now())
+	if want := VersionPath(first, 2); second != want {
+		t.Fatalf("changed extraction written to %s, want %s", second, want)
+	}
+	if found := e.FindOutput(docPath); found != second {
+		t.Errorf("FindOutput() = %s, want the latest version %s", found, second)
+	}
+	if extracted, err := ReadExtracted(first); err != nil || extracted.Content.FullText != "Flight log" {
+		t.Errorf("version 1 changed: %+v, %v", extracted, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package highlight writes copies of PDFs with highlight annotations over
+// search hits, so reviewers can open the original document with the matches
+// already marked. The annotations are appended to the unchanged original as
+// an incremental update.
+package highlight
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/search"
+)
+
+// Author is recorded as the author of the annotations
+const Author = "defornicator"
+
+// Marks maps page numbers to the regions to highlight on them
+type Marks map[int][]extractor.Box
+
+// Find locates the query's matches on the given pages of an extraction
+// output, using the bounding box stored for each line. Boxes cover whole
+// lines, so a match is placed within its line in proportion to its position
+// in the line's text.
+func Find(extracted *extractor.ExtractedText, pages []int, q *search.Query) Marks {
+	marks := make(Marks)
+	for _, page := range extracted.Content.Pages {
+		if !slices.Contains(pages, page.PageNumber) {
+			continue
+		}
+		for _, line := range page.Lines {
+			if len(line.Box) != 4 {
+				continue // extracted before boxes were recorded, or by a fallback backend
+			}
+			box := extractor.Box{X0: line.Box[0], Y0: line.Box[1], X1: line.Box[2], Y1: line.Box[3]}
+			for _, match := range q.Occurrences(line.Text) {
+				marks[page.PageNumber] = append(marks[page.PageNumber], within(box, line.Text, match[0], match[1]))
+			}
+		}
+	}
+	return marks
+}
+
+// within returns the part of a line's box that text[start:end] takes up,
+// assuming characters of even width
+func within(box extractor.Box, text string, start, end int) extractor.Box {
+	total := utf8.RuneCountInString(text)
+	if total == 0 {
+		return box
+	}
+	width := box.X1 - box.X0
+	before := utf8.RuneCountInString(text[:start])
+	matched := utf8.RuneCountInString(text[start:end])
+	box.X0 += width * float64(before) / float64(total)
+	box.X1 = box.X0 + width*float64(matched)/float64(total)
+	return box
+}
+
+// WriteFile writes a copy of the PDF at srcPath to dstPath with marks
+// highlighted, noting why (e.g. the search query) in each annotation
+func WriteFile(srcPath, dstPath string, marks Marks, note string, perms pathutil.Permissions) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open document: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open document: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := Annotate(src, info.Size(), &out, marks, note); err != nil {
+		return err
+	}
+	if err := perms.WriteFile(dstPath, out.Bytes()); err != nil {
+		return fmt.Errorf("failed to save highlighted copy: %w", err)
+	}
+	return nil
+}
+
+// Annotate copies the PDF in src (size bytes long) to w, followed by an
+// incremental update adding a highlight annotation for each of marks
+func Annotate(src io.ReaderAt, size int64, w io.Writer, marks Marks, note string) (err error) {
+	reader, err := pdf.NewReader(src, size)
+	if err != nil {
+		return fmt.Errorf("failed to open PDF: %w", err)
+	}
+	// The PDF library panics on some malformed objects
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to annotate PDF: %v", r)
+		}
+	}()
+	if !reader.Trailer().Key("Encrypt").IsNull() {
+		return fmt.Errorf("encrypted PDFs cannot be annotated")
+	}
+	prevXref, xrefStream, err := lastXref(src, size)
+	if err != nil {
+		return err
+	}
+
+	u := newUpdate(reader, size)
+	if tail := make([]byte, 1); size > 0 {
+		src.ReadAt(tail, size-1)
+		if tail[0] != '\n' && tail[0] != '\r' {
+			u.buf.WriteByte('\n')
+		}
+	}
+	pages := make([]int, 0, len(marks))
+	for page := range marks {
+		pages = append(pages, page)
+	}
+	slices.Sort(pages)
+	for _, number := range pages {
+		if len(marks[number]) == 0 {
+			continue
+		}
+		if err := u.annotatePage(number, marks[number], note); err != nil {
+			return err
+		}
+	}
+	if xrefStream {
+		u.writeXrefStream(prevXref)
+	} else {
+		u.writeXrefTable(prevXref)
+	}
+
+	if _, err := io.Copy(w, io.NewSectionReader(src, 0, size)); err != nil {
+		return fmt.Errorf("failed to copy PDF: %w", err)
+	}
+	if _, err := w.Write(u.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write annotations: %w", err)
+	}
+	return nil
+}
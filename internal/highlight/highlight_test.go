@@ -0,0 +1,112 @@
+package highlight
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/search"
+)
+
+// buildPDF assembles a one-page PDF whose page already has an annotation,
+// indexed by an xref table or, if xrefStream is set, an xref stream
+func buildPDF(xrefStream bool) []byte {
+	content := "BT /F1 12 Tf 72 720 Td (Flight log) Tj ET"
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> /Annots [6 0 R] >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Annot /Subtype /Text /Rect [0 0 10 10] /Contents (existing) >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	if xrefStream {
+		var data bytes.Buffer
+		data.Write([]byte{0, 0, 0, 0xff, 0xff}) // object 0 is free
+		for _, offset := range offsets {
+			data.Write([]byte{1, byte(offset >> 8), byte(offset), 0, 0})
+		}
+		data.Write([]byte{1, byte(start >> 8), byte(start), 0, 0}) // the stream itself
+		n := len(objects) + 1
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 2 2] /Root 1 0 R /Length %d >>\nstream\n", n, n+1, data.Len())
+		b.Write(data.Bytes())
+		b.WriteString("\nendstream\nendobj\n")
+	} else {
+		fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+		for _, offset := range offsets {
+			fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+		}
+		fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\n", len(objects)+1)
+	}
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+func TestAnnotate(t *testing.T) {
+	for _, xrefStream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("xref stream %v", xrefStream), func(t *testing.T) {
+			original := buildPDF(xrefStream)
+			var out bytes.Buffer
+			marks := Marks{1: {{X0: 72, Y0: 717, X1: 102, Y1: 729.6}}}
+			if err := Annotate(bytes.NewReader(original), int64(len(original)), &out, marks, "flight"); err != nil {
+				t.Fatalf("Annotate() error = %v", err)
+			}
+			if !bytes.HasPrefix(out.Bytes(), original) {
+				t.Fatal("Annotate() changed the original bytes")
+			}
+
+			reader, err := pdf.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+			if err != nil {
+				t.Fatalf("annotated copy does not open: %v", err)
+			}
+			page := reader.Page(1)
+			annots := page.V.Key("Annots")
+			if annots.Len() != 2 {
+				t.Fatalf("page has %d annotations, want the existing one and the highlight", annots.Len())
+			}
+			if got := annots.Index(0).Key("Contents").Text(); got != "existing" {
+				t.Errorf("first annotation Contents = %q, want the existing annotation", got)
+			}
+			hl := annots.Index(1)
+			if hl.Key("Subtype").Name() != "Highlight" || hl.Key("Contents").Text() != "flight" {
+				t.Errorf("highlight = %v", hl)
+			}
+			if rect := hl.Key("Rect"); rect.Index(0).Float64() != 72 || rect.Index(3).Float64() != 729.6 {
+				t.Errorf("highlight Rect = %v", rect)
+			}
+			if page.V.Key("Resources").Key("Font").Key("F1").Key("BaseFont").Name() != "Helvetica" {
+				t.Error("rewritten page lost its resources")
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	extracted := &extractor.ExtractedText{Content: extractor.Content{Pages: []extractor.Page{
+		{PageNumber: 1, Lines: []extractor.LineSpan{
+			{Text: "Flight log of the flight", Box: []float64{100, 700, 340, 712}},
+			{Text: "no box here flight"},
+		}},
+		{PageNumber: 2, Lines: []extractor.LineSpan{{Text: "flight", Box: []float64{0, 0, 60, 12}}}},
+	}}}
+	q, err := search.ParseWithAnalyzer("flight", search.Analyzer{Lowercase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	marks := Find(extracted, []int{1}, q)
+	want := []extractor.Box{{X0: 100, Y0: 700, X1: 160, Y1: 712}, {X0: 280, Y0: 700, X1: 340, Y1: 712}}
+	if len(marks) != 1 || fmt.Sprint(marks[1]) != fmt.Sprint(want) {
+		t.Errorf("Find() = %v, want page 1 only with %v", marks, want)
+	}
+}
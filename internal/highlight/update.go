@@ -0,0 +1,298 @@
+package highlight
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ledongthuc/pdf"
+
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// Appearance of the highlight annotations
+const (
+	highlightColor   = "[1 0.92 0.23]" // yellow, as RGB
+	highlightOpacity = "0.45"
+	annotPrint       = 4 // annotation flag: print with the page
+)
+
+// ref is an indirect object reference
+type ref struct {
+	id  uint32
+	gen uint16
+}
+
+// String formats the reference as "id gen R"
+func (r ref) String() string {
+	return fmt.Sprintf("%d %d R", r.id, r.gen)
+}
+
+// refOf returns the object a value was read from. The PDF library keeps it
+// unexported, but it is the only way to tell an indirect value (one to refer
+// to) from a direct one (one to copy), since both resolve alike.
+func refOf(v pdf.Value) ref {
+	ptr := reflect.ValueOf(v).FieldByName("ptr")
+	return ref{id: uint32(ptr.FieldByName("id").Uint()), gen: uint16(ptr.FieldByName("gen").Uint())}
+}
+
+// update builds an incremental update appended after the original file
+type update struct {
+	reader  *pdf.Reader
+	base    int64 // size of the original file, where the update starts
+	buf     bytes.Buffer
+	nextID  uint32
+	offsets map[ref]int64 // objects written, by their offset in the file
+}
+
+// newUpdate starts an update to the PDF read by reader, size bytes long
+func newUpdate(reader *pdf.Reader, size int64) *update {
+	return &update{
+		reader:  reader,
+		base:    size,
+		nextID:  uint32(reader.Trailer().Key("Size").Int64()),
+		offsets: make(map[ref]int64),
+	}
+}
+
+// annotatePage adds a highlight annotation for each box and rewrites the
+// page to list them after its existing annotations
+func (u *update) annotatePage(number int, boxes []extractor.Box, note string) error {
+	if number < 1 || number > u.reader.NumPage() {
+		return fmt.Errorf("page %d is out of range", number)
+	}
+	page := u.reader.Page(number)
+	pageRef := refOf(page.V)
+	if page.V.IsNull() || pageRef.id == 0 {
+		return fmt.Errorf("page %d is not an indirect object", number)
+	}
+
+	var annots []string
+	existing := page.V.Key("Annots")
+	for i := 0; i < existing.Len(); i++ {
+		var b bytes.Buffer
+		writeValue(&b, existing.Index(i), refOf(existing))
+		annots = append(annots, b.String())
+	}
+	for _, box := range boxes {
+		annotRef := ref{id: u.nextID}
+		u.nextID++
+		u.beginObject(annotRef)
+		x0, y0, x1, y1 := coord(box.X0), coord(box.Y0), coord(box.X1), coord(box.Y1)
+		// QuadPoints run upper-left, upper-right, lower-left, lower-right
+		fmt.Fprintf(&u.buf, "<< /Type /Annot /Subtype /Highlight /Rect [%s %s %s %s] /QuadPoints [%s %s %s %s %s %s %s %s]",
+			x0, y0, x1, y1, x0, y1, x1, y1, x0, y0, x1, y0)
+		fmt.Fprintf(&u.buf, " /C %s /CA %s /F %d /P %s /T %s", highlightColor, highlightOpacity, annotPrint, pageRef, textString(Author))
+		if note != "" {
+			fmt.Fprintf(&u.buf, " /Contents %s", textString(note))
+		}
+		u.buf.WriteString(" >>")
+		u.endObject()
+		annots = append(annots, annotRef.String())
+	}
+
+	// A new revision of the page: the same entries with the longer /Annots
+	u.beginObject(pageRef)
+	u.buf.WriteString("<<")
+	for _, key := range page.V.Keys() {
+		if key == "Annots" {
+			continue
+		}
+		u.buf.WriteString(" ")
+		writeName(&u.buf, key)
+		u.buf.WriteString(" ")
+		writeValue(&u.buf, page.V.Key(key), pageRef)
+	}
+	fmt.Fprintf(&u.buf, " /Annots [%s] >>", strings.Join(annots, " "))
+	u.endObject()
+	return nil
+}
+
+// beginObject starts writing an object, recording its offset
+func (u *update) beginObject(r ref) {
+	u.offsets[r] = u.base + int64(u.buf.Len())
+	fmt.Fprintf(&u.buf, "%d %d obj\n", r.id, r.gen)
+}
+
+// endObject finishes the object begun last
+func (u *update) endObject() {
+	u.buf.WriteString("\nendobj\n")
+}
+
+// sortedRefs returns the objects written, by object number
+func (u *update) sortedRefs() []ref {
+	refs := make([]ref, 0, len(u.offsets))
+	for r := range u.offsets {
+		refs = append(refs, r)
+	}
+	slices.SortFunc(refs, func(a, b ref) int { return int(a.id) - int(b.id) })
+	return refs
+}
+
+// trailerEntries writes the trailer keys carried over from the previous
+// revision, plus /Size and /Prev
+func (u *update) trailerEntries(b *bytes.Buffer, prev int64) {
+	trailer := u.reader.Trailer()
+	fmt.Fprintf(b, " /Size %d /Prev %d", u.nextID, prev)
+	for _, key := range []string{"Root", "Info", "ID"} {
+		if v := trailer.Key(key); !v.IsNull() {
+			b.WriteString(" ")
+			writeName(b, key)
+			b.WriteString(" ")
+			writeValue(b, v, refOf(trailer))
+		}
+	}
+}
+
+// writeXrefTable ends the update with a cross-reference table and trailer,
+// for files whose previous revision used one
+func (u *update) writeXrefTable(prev int64) {
+	start := u.base + int64(u.buf.Len())
+	u.buf.WriteString("xref\n")
+	for _, r := range u.sortedRefs() {
+		fmt.Fprintf(&u.buf, "%d 1\n%010d %05d n \n", r.id, u.offsets[r], r.gen)
+	}
+	u.buf.WriteString("trailer\n<<")
+	u.trailerEntries(&u.buf, prev)
+	fmt.Fprintf(&u.buf, " >>\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+// writeXrefStream ends the update with a cross-reference stream, for files
+// whose previous revision used one (readers do not mix the two kinds)
+func (u *update) writeXrefStream(prev int64) {
+	streamRef := ref{id: u.nextID}
+	u.nextID++
+	start := u.base + int64(u.buf.Len())
+	u.offsets[streamRef] = start
+
+	var index []string
+	var data bytes.Buffer
+	for _, r := range u.sortedRefs() {
+		index = append(index, fmt.Sprintf("%d 1", r.id))
+		data.WriteByte(1) // type 1: uncompressed object at an offset
+		binary.Write(&data, binary.BigEndian, uint64(u.offsets[r]))
+		binary.Write(&data, binary.BigEndian, r.gen)
+	}
+	fmt.Fprintf(&u.buf, "%d 0 obj\n<< /Type /XRef /W [1 8 2] /Index [%s]", streamRef.id, strings.Join(index, " "))
+	u.trailerEntries(&u.buf, prev)
+	fmt.Fprintf(&u.buf, " /Length %d >>\nstream\n", data.Len())
+	u.buf.Write(data.Bytes())
+	fmt.Fprintf(&u.buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+// lastXref returns the offset of the file's newest cross-reference section
+// and whether it is a stream rather than a table
+func lastXref(src io.ReaderAt, size int64) (int64, bool, error) {
+	tailSize := min(size, 1024)
+	tail := make([]byte, tailSize)
+	if _, err := src.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return 0, false, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return 0, false, fmt.Errorf("malformed PDF: missing startxref")
+	}
+	fields := strings.Fields(string(tail[i+len("startxref"):]))
+	if len(fields) == 0 {
+		return 0, false, fmt.Errorf("malformed PDF: missing startxref offset")
+	}
+	offset, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, false, fmt.Errorf("malformed PDF: invalid startxref offset %q", fields[0])
+	}
+	head := make([]byte, 4)
+	src.ReadAt(head, offset)
+	return offset, string(head) != "xref", nil
+}
+
+// writeValue writes v in PDF syntax. Values read from an object other than
+// owner are indirect and written as references; the rest are copied.
+func writeValue(b *bytes.Buffer, v pdf.Value, owner ref) {
+	if r := refOf(v); r != owner && r.id != 0 {
+		b.WriteString(r.String())
+		return
+	}
+	switch v.Kind() {
+	case pdf.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case pdf.Integer:
+		b.WriteString(strconv.FormatInt(v.Int64(), 10))
+	case pdf.Real:
+		b.WriteString(num(v.Float64()))
+	case pdf.String:
+		fmt.Fprintf(b, "<%s>", hex.EncodeToString([]byte(v.RawString())))
+	case pdf.Name:
+		writeName(b, v.Name())
+	case pdf.Dict:
+		b.WriteString("<<")
+		for _, key := range v.Keys() {
+			b.WriteString(" ")
+			writeName(b, key)
+			b.WriteString(" ")
+			writeValue(b, v.Key(key), owner)
+		}
+		b.WriteString(" >>")
+	case pdf.Array:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			writeValue(b, v.Index(i), owner)
+		}
+		b.WriteString("]")
+	default:
+		b.WriteString("null")
+	}
+}
+
+// writeName writes a name object, escaping delimiters and non-printing bytes
+func writeName(b *bytes.Buffer, name string) {
+	b.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(b, "#%02x", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+}
+
+// textString encodes s as a PDF text string: plain ASCII as is, anything
+// else as UTF-16BE with a byte order mark
+func textString(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "<" + hex.EncodeToString([]byte(s)) + ">"
+	}
+	encoded := []byte{0xfe, 0xff}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		encoded = append(encoded, byte(unit>>8), byte(unit))
+	}
+	return "<" + hex.EncodeToString(encoded) + ">"
+}
+
+// coord formats a page coordinate to a hundredth of a point
+func coord(f float64) string {
+	return num(math.Round(f*100) / 100)
+}
+
+// num formats a number for PDF syntax, which has no exponent notation
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
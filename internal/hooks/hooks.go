@@ -0,0 +1,156 @@
+// Package hooks runs user-configured commands on lifecycle events of the
+// pipeline (a download completing, an extraction completing, a document
+// failing), passing each event to the command as JSON on stdin, so runs can
+// be integrated with other tools without changing the code.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/pipeline"
+)
+
+// Events hooks can be configured for
+const (
+	EventDownloadComplete = "download-complete" // a document was fetched and verified
+	EventExtractComplete  = "extract-complete"  // a document's extraction output was saved
+	EventFailure          = "failure"           // a step failed for a document
+)
+
+// DefaultTimeout is how long a hook command may run when its timeout is not
+// configured
+const DefaultTimeout = time.Minute
+
+// Event is the payload a hook command receives on stdin
+type Event struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Input    string    `json:"input"`              // the input as listed: a URL or a path
+	URL      string    `json:"url,omitempty"`      // canonical URL of a remote document
+	Document string    `json:"document,omitempty"` // local path of the document
+	Pages    int       `json:"pages,omitempty"`    // page count, when known
+	Outputs  []string  `json:"outputs,omitempty"`  // extract-complete: where the extraction output was written
+	Step     string    `json:"step,omitempty"`     // failure: the step that failed
+	Error    string    `json:"error,omitempty"`    // failure: what went wrong
+}
+
+// Hook is a command run on an event
+type Hook struct {
+	Event   string
+	Command []string // program and arguments, run without a shell
+	Timeout time.Duration
+}
+
+// Runner runs the hooks configured for each event
+type Runner struct {
+	hooks  []Hook
+	output io.Writer // receives the commands' stdout and stderr
+}
+
+// New creates a Runner for the given hooks, writing their output to w
+func New(hooks []Hook, w io.Writer) *Runner {
+	return &Runner{hooks: hooks, output: w}
+}
+
+// FromConfig builds a Runner from the hooks section of the config, or
+// returns nil if no hooks are configured
+func FromConfig(hooks []config.HookConfig, w io.Writer) (*Runner, error) {
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	var built []Hook
+	for i, hc := range hooks {
+		switch hc.Event {
+		case EventDownloadComplete, EventExtractComplete, EventFailure:
+		default:
+			return nil, fmt.Errorf("hooks[%d]: unknown event %q", i, hc.Event)
+		}
+		if len(hc.Command) == 0 || hc.Command[0] == "" {
+			return nil, fmt.Errorf("hooks[%d]: no command", i)
+		}
+		timeout := DefaultTimeout
+		if hc.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(hc.Timeout); err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("hooks[%d]: invalid timeout %q", i, hc.Timeout)
+			}
+		}
+		built = append(built, Hook{Event: hc.Event, Command: hc.Command, Timeout: timeout})
+	}
+	return New(built, w), nil
+}
+
+// Fire runs every hook configured for the event, one after another, and
+// returns the errors of those that failed or timed out. A nil Runner fires
+// nothing.
+func (r *Runner) Fire(event Event) error {
+	if r == nil {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Event, err)
+	}
+	var errs []error
+	for _, hook := range r.hooks {
+		if hook.Event != event.Event {
+			continue
+		}
+		if err := r.run(hook, payload); err != nil {
+			errs = append(errs, fmt.Errorf("%s hook %s: %w", event.Event, strings.Join(hook.Command, " "), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run runs one hook command with payload on stdin
+func (r *Runner) run(hook Hook, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	cmd.Stdout, cmd.Stderr = r.output, r.output
+	cmd.Env = append(os.Environ(), "DEFORNICATOR_EVENT="+hook.Event)
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+	return err
+}
+
+// AfterHook returns a pipeline hook firing the events of each document as
+// its steps complete: download-complete once a new download is verified,
+// extract-complete once its output is exported, and failure when a step
+// fails. Errors of hook commands are passed to report; they never fail the
+// document.
+func (r *Runner) AfterHook(report func(error)) pipeline.AfterHook {
+	return func(step string, doc *pipeline.Document, err error) {
+		event := Event{Input: doc.Item.Input, URL: doc.Item.URL, Document: doc.Path, Pages: doc.PageCount}
+		switch {
+		case err != nil:
+			event.Event, event.Step, event.Error = EventFailure, step, err.Error()
+		case step == pipeline.StepVerify && doc.Downloaded && !doc.Unchanged:
+			event.Event = EventDownloadComplete
+		case step == pipeline.StepExport:
+			event.Event, event.Outputs = EventExtractComplete, doc.Outputs
+		default:
+			return
+		}
+		if err := r.Fire(event); err != nil {
+			report(err)
+		}
+	}
+}
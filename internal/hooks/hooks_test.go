@@ -0,0 +1,99 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/pipeline"
+	"defornicate-epstein-files/internal/source"
+)
+
+func TestAfterHook(t *testing.T) {
+	dir := t.TempDir()
+	// Each hook appends the event it receives to a file named after it
+	record := func(event string) config.HookConfig {
+		out := filepath.Join(dir, event+".jsonl")
+		return config.HookConfig{Event: event, Command: []string{"sh", "-c", `cat >> "$0"; echo "$DEFORNICATOR_EVENT" >> "$0.env"`, out}}
+	}
+	r, err := FromConfig([]config.HookConfig{record(EventDownloadComplete), record(EventExtractComplete), record(EventFailure)}, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []error
+	after := r.AfterHook(func(err error) { reported = append(reported, err) })
+
+	doc := &pipeline.Document{
+		Item: source.Item{Input: "https://example.org/EFTA00010724.pdf", URL: "https://example.org/EFTA00010724.pdf"},
+		Path: "documents/example.org/EFTA00010724.pdf", Downloaded: true, PageCount: 3,
+	}
+	after(pipeline.StepDownload, doc, nil) // verified downloads only
+	after(pipeline.StepVerify, doc, nil)
+	doc.Outputs = []string{"documents/example.org/EFTA00010724.extracted.json"}
+	after(pipeline.StepExport, doc, nil)
+	after(pipeline.StepExtract, doc, errors.New("no text could be extracted"))
+	if len(reported) > 0 {
+		t.Fatalf("hooks failed: %v", reported)
+	}
+
+	read := func(event string) Event {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, event+".jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 {
+			t.Fatalf("%s hook ran %d times, want once", event, len(lines))
+		}
+		var e Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			t.Fatal(err)
+		}
+		if env, _ := os.ReadFile(filepath.Join(dir, event+".jsonl.env")); strings.TrimSpace(string(env)) != event {
+			t.Errorf("DEFORNICATOR_EVENT = %q, want %q", env, event)
+		}
+		return e
+	}
+	if e := read(EventDownloadComplete); e.URL != doc.Item.URL || e.Document != doc.Path || e.Pages != 3 || e.Time.IsZero() {
+		t.Errorf("download-complete event = %+v", e)
+	}
+	if e := read(EventExtractComplete); len(e.Outputs) != 1 || e.Outputs[0] != doc.Outputs[0] {
+		t.Errorf("extract-complete event = %+v", e)
+	}
+	if e := read(EventFailure); e.Step != pipeline.StepExtract || e.Error != "no text could be extracted" {
+		t.Errorf("failure event = %+v", e)
+	}
+}
+
+func TestFireTimeout(t *testing.T) {
+	var output bytes.Buffer
+	r := New([]Hook{{Event: EventFailure, Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond}}, &output)
+	err := r.Fire(Event{Event: EventFailure})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Fire() error = %v, want a timeout", err)
+	}
+	if err := r.Fire(Event{Event: EventExtractComplete}); err != nil {
+		t.Errorf("Fire() of an event without hooks = %v", err)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if r, err := FromConfig(nil, os.Stderr); r != nil || err != nil {
+		t.Errorf("FromConfig(nil) = %v, %v; want no runner", r, err)
+	}
+	for _, hc := range []config.HookConfig{
+		{Event: "downloaded", Command: []string{"true"}},
+		{Event: EventFailure},
+		{Event: EventFailure, Command: []string{"true"}, Timeout: "soon"},
+	} {
+		if _, err := FromConfig([]config.HookConfig{hc}, os.Stderr); err == nil {
+			t.Errorf("FromConfig(%+v) succeeded", hc)
+		}
+	}
+}
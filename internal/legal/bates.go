@@ -0,0 +1,105 @@
+package legal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Bates is a Bates production number stamped on a page, e.g. EFTA00010724
+type Bates struct {
+	Prefix string // producing party's prefix, e.g. "EFTA" or "DOJ-OGR"
+	Number int
+}
+
+// batesPattern matches an upper-case prefix (optionally hyphenated) followed
+// by a zero-padded sequence number of at least six digits
+const batesPattern = `([A-Z]{2,}(?:[-_][A-Z]{2,})*)[-_ ]?(\d{6,10})`
+
+var (
+	batesRe      = regexp.MustCompile(`\b` + batesPattern + `\b`)
+	wholeBatesRe = regexp.MustCompile(`^` + batesPattern + `$`)
+	// batesRangeRe matches two Bates numbers joined by a hyphen
+	batesRangeRe = regexp.MustCompile(`^` + batesPattern + `-` + batesPattern + `$`)
+)
+
+// FindBates returns the last Bates number in text. Stamps normally sit in the
+// page footer, so the last match is preferred over header references.
+func FindBates(text string) (Bates, bool) {
+	matches := batesRe.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return Bates{}, false
+	}
+	m := matches[len(matches)-1]
+	number, err := strconv.Atoi(m[2])
+	if err != nil {
+		return Bates{}, false
+	}
+	return Bates{Prefix: m[1], Number: number}, true
+}
+
+// Follows reports whether b continues the production sequence started by
+// prev: same prefix and a higher number. Gaps are allowed because withheld
+// pages are often skipped.
+func (b Bates) Follows(prev Bates) bool {
+	return b.Prefix == prev.Prefix && b.Number > prev.Number
+}
+
+// ReplaceBates returns text with every Bates number replaced by what replace
+// returns for it
+func ReplaceBates(text string, replace func(Bates) string) string {
+	return batesRe.ReplaceAllStringFunc(text, func(match string) string {
+		m := batesRe.FindStringSubmatch(match)
+		number, err := strconv.Atoi(m[2])
+		if err != nil {
+			return match
+		}
+		return replace(Bates{Prefix: m[1], Number: number})
+	})
+}
+
+// ParseBates parses s as a single Bates number, such as a document's file
+// name without its extension
+func ParseBates(s string) (Bates, bool) {
+	m := wholeBatesRe.FindStringSubmatch(s)
+	if m == nil {
+		return Bates{}, false
+	}
+	number, err := strconv.Atoi(m[2])
+	if err != nil {
+		return Bates{}, false
+	}
+	return Bates{Prefix: m[1], Number: number}, true
+}
+
+// BatesRange is an inclusive range of one producing party's Bates numbers
+type BatesRange struct {
+	From, To Bates
+}
+
+// ParseBatesRange parses a range written FROM-TO, e.g.
+// EFTA00010000-EFTA00019999
+func ParseBatesRange(s string) (BatesRange, error) {
+	m := batesRangeRe.FindStringSubmatch(s)
+	if m == nil {
+		return BatesRange{}, fmt.Errorf("invalid Bates range %q, want FROM-TO such as EFTA00010000-EFTA00019999", s)
+	}
+	from, errFrom := strconv.Atoi(m[2])
+	to, errTo := strconv.Atoi(m[4])
+	if errFrom != nil || errTo != nil {
+		return BatesRange{}, fmt.Errorf("invalid Bates range %q", s)
+	}
+	r := BatesRange{From: Bates{Prefix: m[1], Number: from}, To: Bates{Prefix: m[3], Number: to}}
+	if r.From.Prefix != r.To.Prefix {
+		return BatesRange{}, fmt.Errorf("invalid Bates range %q: %s and %s are different prefixes", s, r.From.Prefix, r.To.Prefix)
+	}
+	if r.To.Number < r.From.Number {
+		return BatesRange{}, fmt.Errorf("invalid Bates range %q: it ends before it starts", s)
+	}
+	return r, nil
+}
+
+// Contains reports whether b lies within r
+func (r BatesRange) Contains(b Bates) bool {
+	return b.Prefix == r.From.Prefix && b.Number >= r.From.Number && b.Number <= r.To.Number
+}
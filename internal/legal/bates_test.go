@@ -0,0 +1,75 @@
+package legal
+
+import "testing"
+
+func TestFindBates(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   Bates
+		wantOK bool
+	}{
+		{"Page 1 of 3\nEFTA00010724", Bates{Prefix: "EFTA", Number: 10724}, true},
+		{"See DOJ-OGR-00000012 above\nDOJ-OGR-00000044", Bates{Prefix: "DOJ-OGR", Number: 44}, true},
+		{"Case 1:19-cv-03377-LAP Document 1", Bates{}, false},
+		{"Exhibit 12", Bates{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := FindBates(tt.text)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("FindBates(%q) = %+v, %v, want %+v, %v", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseBates(t *testing.T) {
+	tests := []struct {
+		s      string
+		want   Bates
+		wantOK bool
+	}{
+		{"EFTA00010724", Bates{Prefix: "EFTA", Number: 10724}, true},
+		{"DOJ-OGR-00000044", Bates{Prefix: "DOJ-OGR", Number: 44}, true},
+		{"EFTA00010724-redacted", Bates{}, false},
+		{"flight-log", Bates{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseBates(tt.s)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("ParseBates(%q) = %+v, %v, want %+v, %v", tt.s, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseBatesRange(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    BatesRange
+		wantErr bool
+	}{
+		{"EFTA00010000-EFTA00019999", BatesRange{From: Bates{"EFTA", 10000}, To: Bates{"EFTA", 19999}}, false},
+		{"DOJ-OGR-00000001-DOJ-OGR-00000100", BatesRange{From: Bates{"DOJ-OGR", 1}, To: Bates{"DOJ-OGR", 100}}, false},
+		{"EFTA00010000-EFTA00010000", BatesRange{From: Bates{"EFTA", 10000}, To: Bates{"EFTA", 10000}}, false},
+		{"EFTA00010000-DOJ-OGR-00000100", BatesRange{}, true},
+		{"EFTA00019999-EFTA00010000", BatesRange{}, true},
+		{"EFTA00010000", BatesRange{}, true},
+		{"10000-19999", BatesRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBatesRange(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBatesRange(%q) = %+v, %v, want %+v (error %v)", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	r := BatesRange{From: Bates{"EFTA", 10000}, To: Bates{"EFTA", 19999}}
+	for b, want := range map[Bates]bool{
+		{"EFTA", 10000}:    true,
+		{"EFTA", 19999}:    true,
+		{"EFTA", 20000}:    false,
+		{"DOJ-OGR", 10724}: false,
+	} {
+		if got := r.Contains(b); got != want {
+			t.Errorf("Contains(%+v) = %v, want %v", b, got, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package legal provides heuristics for recognizing court-filing structure in
+// extracted document text, such as docket numbers and case captions.
+package legal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CaseInfo holds case identification found on the first pages of a filing
+type CaseInfo struct {
+	CaseNumbers []string `json:"case_numbers,omitempty"` // canonical docket numbers, e.g. "1:19-cv-03377"
+	Courts      []string `json:"courts,omitempty"`       // e.g. "UNITED STATES DISTRICT COURT SOUTHERN DISTRICT OF NEW YORK"
+	Caption     string   `json:"caption,omitempty"`      // e.g. "Jane Doe v. Jeffrey Epstein"
+}
+
+var (
+	// Federal docket numbers: [office:]YY-type-NNNNN[-JUDGE...], e.g. 1:19-cv-03377-LAP
+	federalCaseRe = regexp.MustCompile(`(?i)\b(?:(\d{1,2}):)?(\d{2})-(cv|cr|mc|mj|md|bk|ap|civ)-(\d{2,6})((?:-[A-Z]{1,5})*)`)
+	// Southern District of Florida style: 08-80736-CIV-MARRA
+	floridaCaseRe = regexp.MustCompile(`\b(\d{2})-(\d{4,6})-(CIV|CR|Civ|Cr)(?:-[A-Za-z]+)*`)
+
+	courtRe         = regexp.MustCompile(`(?i)^\s*((?:UNITED STATES|U\.S\.) (?:DISTRICT|BANKRUPTCY) COURT|UNITED STATES COURT OF APPEALS|SUPREME COURT OF [A-Z ]+|(?:IN THE )?CIRCUIT COURT OF [A-Z0-9 ,]+|[A-Z ]*SUPERIOR COURT OF [A-Z ,]+)`)
+	courtDistrictRe = regexp.MustCompile(`(?i)^\s*((?:FOR THE )?(?:(?:NORTHERN|SOUTHERN|EASTERN|WESTERN|MIDDLE|CENTRAL) )?DISTRICT OF [A-Z ]+|FOR THE [A-Z]+ CIRCUIT|IN AND FOR [A-Z ,]+)`)
+
+	inlineCaptionRe = regexp.MustCompile(`^\s*(.{2,80}?),?\s+(?:v\.|vs\.)\s+(.{2,80}?)[,.]?\s*$`)
+	versusLineRe    = regexp.MustCompile(`(?i)^\s*(?:v\.?|vs\.?|versus|-\s*against\s*-|against)\s*$`)
+	partyRoleRe     = regexp.MustCompile(`(?i)^\s*(?:plaintiffs?|defendants?|petitioners?|respondents?|appellants?|appellees?|debtors?|movants?|intervenors?)[,.]?\s*$|^\s*[)\-_:]+\s*$`)
+	roleSuffixRe    = regexp.MustCompile(`(?i)[,\s]+(?:et al\.?\s*,?\s*)?(?:plaintiffs?|defendants?|petitioners?|respondents?)?[,.]?\s*$`)
+)
+
+// ParseCaseInfo looks for docket numbers, court names and the case caption in
+// the given lines of text (normally the first page or two of a document)
+func ParseCaseInfo(lines []string) CaseInfo {
+	var info CaseInfo
+	seenNumbers := make(map[string]bool)
+	seenCourts := make(map[string]bool)
+
+	for i, line := range lines {
+		for _, number := range findCaseNumbers(line) {
+			if !seenNumbers[number] {
+				seenNumbers[number] = true
+				info.CaseNumbers = append(info.CaseNumbers, number)
+			}
+		}
+
+		if m := courtRe.FindStringSubmatch(line); m != nil {
+			court := strings.TrimSpace(line)
+			// The district or circuit is usually on the following line
+			if i+1 < len(lines) && courtDistrictRe.MatchString(lines[i+1]) && !courtDistrictRe.MatchString(line) {
+				court += " " + strings.TrimSpace(lines[i+1])
+			}
+			court = strings.Join(strings.Fields(court), " ")
+			if !seenCourts[court] {
+				seenCourts[court] = true
+				info.Courts = append(info.Courts, court)
+			}
+		}
+	}
+
+	info.Caption = findCaption(lines)
+	return info
+}
+
+// IsEmpty reports whether nothing case-related was found
+func (c CaseInfo) IsEmpty() bool {
+	return len(c.CaseNumbers) == 0 && len(c.Courts) == 0 && c.Caption == ""
+}
+
+// CaseNumberMatch is a docket number found in text
+type CaseNumberMatch struct {
+	Text      string // as written, e.g. "1:19-cv-3377-LAP"
+	Canonical string // e.g. "1:19-cv-03377"
+}
+
+// FindCaseNumbers returns the docket numbers in text along with their
+// canonical form
+func FindCaseNumbers(text string) []CaseNumberMatch {
+	var matches []CaseNumberMatch
+	for _, m := range federalCaseRe.FindAllStringSubmatch(text, -1) {
+		caseType := strings.ToLower(m[3])
+		if caseType == "civ" {
+			caseType = "cv"
+		}
+		number := m[2] + "-" + caseType + "-" + padLeft(m[4], 5)
+		if m[1] != "" {
+			number = m[1] + ":" + number
+		}
+		matches = append(matches, CaseNumberMatch{Text: m[0], Canonical: number})
+	}
+	for _, m := range floridaCaseRe.FindAllStringSubmatch(text, -1) {
+		matches = append(matches, CaseNumberMatch{Text: m[0], Canonical: m[1] + "-" + m[2] + "-" + strings.ToUpper(m[3])})
+	}
+	return matches
+}
+
+// findCaseNumbers returns the canonical docket numbers found in a line. Judge
+// initials are dropped so filings in the same case group together.
+func findCaseNumbers(line string) []string {
+	var numbers []string
+	for _, m := range FindCaseNumbers(line) {
+		numbers = append(numbers, m.Canonical)
+	}
+	return numbers
+}
+
+// findCaption finds "A v. B" either on one line or spread over the classic
+// multi-line caption block (party, role, "v.", party, role)
+func findCaption(lines []string) string {
+	for i, line := range lines {
+		if versusLineRe.MatchString(line) {
+			before := nearestParty(lines, i, -1)
+			after := nearestParty(lines, i, 1)
+			if before != "" && after != "" {
+				return before + " v. " + after
+			}
+			continue
+		}
+		if m := inlineCaptionRe.FindStringSubmatch(line); m != nil {
+			before, after := cleanParty(m[1]), cleanParty(m[2])
+			if before != "" && after != "" {
+				return before + " v. " + after
+			}
+		}
+	}
+	return ""
+}
+
+// nearestParty walks from lines[from] in direction step, skipping blank lines
+// and bare role labels, and returns the first party name found
+func nearestParty(lines []string, from, step int) string {
+	for j, checked := from+step, 0; j >= 0 && j < len(lines) && checked < 4; j += step {
+		line := strings.TrimSpace(lines[j])
+		if line == "" || partyRoleRe.MatchString(line) {
+			continue
+		}
+		checked++
+		if party := cleanParty(line); party != "" {
+			return party
+		}
+	}
+	return ""
+}
+
+// cleanParty strips trailing role labels and punctuation from a party line
+func cleanParty(s string) string {
+	s = roleSuffixRe.ReplaceAllString(strings.TrimSpace(s), "")
+	s = strings.Trim(s, " ,.;:)(")
+	if len(s) < 2 || federalCaseRe.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// padLeft zero-pads a docket sequence number to width digits
+func padLeft(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
@@ -0,0 +1,68 @@
+package legal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCaseInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  CaseInfo
+	}{
+		{
+			name: "federal caption block",
+			lines: []string{
+				"UNITED STATES DISTRICT COURT",
+				"SOUTHERN DISTRICT OF NEW YORK",
+				"JANE DOE,",
+				"Plaintiff,",
+				"v.",
+				"JEFFREY EPSTEIN,",
+				"Defendant.",
+				"Case 1:19-cv-3377-LAP Document 1 Filed 04/16/19",
+			},
+			want: CaseInfo{
+				CaseNumbers: []string{"1:19-cv-03377"},
+				Courts:      []string{"UNITED STATES DISTRICT COURT SOUTHERN DISTRICT OF NEW YORK"},
+				Caption:     "JANE DOE v. JEFFREY EPSTEIN",
+			},
+		},
+		{
+			name: "inline caption and florida docket",
+			lines: []string{
+				"Case No. 08-80736-CIV-MARRA/JOHNSON",
+				"Jane Doe #1 and Jane Doe #2 v. United States",
+			},
+			want: CaseInfo{
+				CaseNumbers: []string{"08-80736-CIV"},
+				Caption:     "Jane Doe #1 and Jane Doe #2 v. United States",
+			},
+		},
+		{
+			name: "repeated docket numbers are deduplicated",
+			lines: []string{
+				"Case 1:15-cv-07433-LAP Document 1320-12",
+				"Case 1:15-cv-07433-RWS Document 1320-12",
+			},
+			want: CaseInfo{
+				CaseNumbers: []string{"1:15-cv-07433"},
+			},
+		},
+		{
+			name:  "no case information",
+			lines: []string{"Flight log", "Page 1 of 3"},
+			want:  CaseInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseCaseInfo(tt.lines)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCaseInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
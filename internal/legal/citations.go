@@ -0,0 +1,201 @@
+package legal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of citation
+const (
+	CitationCase    = "case"    // a reported decision: volume, reporter and page
+	CitationWL      = "westlaw" // an unreported decision on Westlaw: "2019 WL 1234567"
+	CitationLexis   = "lexis"   // an unreported decision on Lexis: "2019 U.S. Dist. LEXIS 12345"
+	CitationStatute = "statute" // a section of the U.S. Code or the CFR
+)
+
+// Citation is a citation to legal authority found in text, normalized so the
+// same authority cited differently (or OCRed with stray spaces) compares
+// equal and can be looked up in case law databases
+type Citation struct {
+	Kind      string `json:"kind"`               // CitationCase, CitationWL, CitationLexis or CitationStatute
+	Canonical string `json:"canonical"`          // normalized form, e.g. "123 F. Supp. 2d 456"
+	Source    string `json:"source"`             // expanded reporter, database or code, e.g. "Federal Supplement, Second Series"
+	Text      string `json:"text"`               // as written
+	Volume    string `json:"volume,omitempty"`   // reporter volume, or title of the code
+	Page      string `json:"page,omitempty"`     // first page, document number or section
+	Year      string `json:"year,omitempty"`     // year of a Westlaw or Lexis citation
+	Reporter  string `json:"reporter,omitempty"` // canonical reporter abbreviation of a case citation
+}
+
+// reporters expands the abbreviations of the reporters most cited in federal
+// and state filings, by canonical abbreviation
+var reporters = map[string]string{
+	"U.S.":           "United States Reports",
+	"S. Ct.":         "Supreme Court Reporter",
+	"L. Ed.":         "Lawyers' Edition",
+	"L. Ed. 2d":      "Lawyers' Edition, Second Series",
+	"F.":             "Federal Reporter",
+	"F.2d":           "Federal Reporter, Second Series",
+	"F.3d":           "Federal Reporter, Third Series",
+	"F.4th":          "Federal Reporter, Fourth Series",
+	"F. Supp.":       "Federal Supplement",
+	"F. Supp. 2d":    "Federal Supplement, Second Series",
+	"F. Supp. 3d":    "Federal Supplement, Third Series",
+	"F. App'x":       "Federal Appendix",
+	"F.R.D.":         "Federal Rules Decisions",
+	"B.R.":           "Bankruptcy Reporter",
+	"So.":            "Southern Reporter",
+	"So. 2d":         "Southern Reporter, Second Series",
+	"So. 3d":         "Southern Reporter, Third Series",
+	"A.2d":           "Atlantic Reporter, Second Series",
+	"A.3d":           "Atlantic Reporter, Third Series",
+	"N.E.2d":         "North Eastern Reporter, Second Series",
+	"N.E.3d":         "North Eastern Reporter, Third Series",
+	"N.W.2d":         "North Western Reporter, Second Series",
+	"P.2d":           "Pacific Reporter, Second Series",
+	"P.3d":           "Pacific Reporter, Third Series",
+	"S.E.2d":         "South Eastern Reporter, Second Series",
+	"S.W.3d":         "South Western Reporter, Third Series",
+	"N.Y.S.2d":       "New York Supplement, Second Series",
+	"N.Y.S.3d":       "New York Supplement, Third Series",
+	"N.Y.2d":         "New York Reports, Second Series",
+	"N.Y.3d":         "New York Reports, Third Series",
+	"A.D.2d":         "Appellate Division Reports, Second Series",
+	"A.D.3d":         "Appellate Division Reports, Third Series",
+	"Misc. 3d":       "New York Miscellaneous Reports, Third Series",
+	"Cal. Rptr. 3d":  "California Reporter, Third Series",
+	"Fla. L. Weekly": "Florida Law Weekly",
+}
+
+// codes expands the abbreviations of the codes cited by section
+var codes = map[string]string{
+	"U.S.C.": "United States Code",
+	"C.F.R.": "Code of Federal Regulations",
+}
+
+var (
+	// caseCitationRe matches volume, reporter and first page; built from
+	// reporters by init
+	caseCitationRe *regexp.Regexp
+	// reporterKeys maps reporter abbreviations stripped of spaces and
+	// apostrophe variants to their canonical form
+	reporterKeys = make(map[string]string, len(reporters))
+
+	// westlawRe matches "2019 WL 1234567"
+	westlawRe = regexp.MustCompile(`\b((?:19|20)\d{2})\s+W\.?\s?L\.?\s+(\d{3,8})\b`)
+	// lexisRe matches "2019 U.S. Dist. LEXIS 12345" and "2019 U.S. App. LEXIS 12345"
+	lexisRe = regexp.MustCompile(`\b((?:19|20)\d{2})\s+U\.\s?S\.\s?(Dist|App)\.\s?LEXIS\s+(\d{1,7})\b`)
+	// statuteRe matches "18 U.S.C. § 1591(a)" and "28 C.F.R. § 16.21"
+	statuteRe = regexp.MustCompile(`\b(\d{1,2})\s+(U\.\s?S\.\s?C|C\.\s?F\.\s?R)\.?\s*(?:§{1,2}|[Ss]ec(?:tion|\.)?)\s*(\d+[A-Za-z]?(?:\.\d+)?(?:-\d+)?(?:\([0-9A-Za-z]{1,4}\))*)`)
+)
+
+func init() {
+	// Longest first, so "F. Supp. 2d" is preferred over "F."
+	abbreviations := make([]string, 0, len(reporters))
+	for abbr := range reporters {
+		abbreviations = append(abbreviations, abbr)
+		reporterKeys[reporterKey(abbr)] = abbr
+	}
+	sort.Slice(abbreviations, func(i, j int) bool {
+		if len(abbreviations[i]) != len(abbreviations[j]) {
+			return len(abbreviations[i]) > len(abbreviations[j])
+		}
+		return abbreviations[i] < abbreviations[j]
+	})
+	alternatives := make([]string, len(abbreviations))
+	for i, abbr := range abbreviations {
+		alternatives[i] = abbreviationPattern(abbr)
+	}
+	caseCitationRe = regexp.MustCompile(`\b(\d{1,4})\s+(` + strings.Join(alternatives, "|") + `)\s*(\d{1,5})\b`)
+}
+
+// abbreviationPattern returns a pattern matching abbr written with or without
+// the spaces between its parts, and with either apostrophe
+func abbreviationPattern(abbr string) string {
+	var b strings.Builder
+	for i, part := range strings.Fields(abbr) {
+		if i > 0 {
+			b.WriteString(`\s?`)
+		}
+		b.WriteString(strings.ReplaceAll(regexp.QuoteMeta(part), "'", "['’]"))
+	}
+	// "F." and "So." must not swallow the start of a longer word
+	return b.String() + `(?:\s|$)`
+}
+
+// reporterKey strips what varies in how a reporter abbreviation is written
+func reporterKey(abbr string) string {
+	return strings.NewReplacer(" ", "", "\t", "", "’", "'").Replace(abbr)
+}
+
+// FindCitations returns the case, Westlaw, Lexis and statute citations in
+// text, in the order they appear, each canonical citation once
+func FindCitations(text string) []Citation {
+	type found struct {
+		at       int
+		citation Citation
+	}
+	var all []found
+	for _, m := range caseCitationRe.FindAllStringSubmatchIndex(text, -1) {
+		reporter, ok := reporterKeys[reporterKey(strings.TrimSpace(text[m[4]:m[5]]))]
+		if !ok {
+			continue
+		}
+		volume, page := text[m[2]:m[3]], text[m[6]:m[7]]
+		all = append(all, found{m[0], Citation{
+			Kind:      CitationCase,
+			Canonical: volume + " " + reporter + " " + page,
+			Source:    reporters[reporter],
+			Text:      text[m[0]:m[1]],
+			Volume:    volume,
+			Page:      page,
+			Reporter:  reporter,
+		}})
+	}
+	for _, m := range westlawRe.FindAllStringSubmatchIndex(text, -1) {
+		year, number := text[m[2]:m[3]], text[m[4]:m[5]]
+		all = append(all, found{m[0], Citation{
+			Kind:      CitationWL,
+			Canonical: year + " WL " + number,
+			Source:    "Westlaw",
+			Text:      text[m[0]:m[1]],
+			Page:      number,
+			Year:      year,
+		}})
+	}
+	for _, m := range lexisRe.FindAllStringSubmatchIndex(text, -1) {
+		year, court, number := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]]
+		all = append(all, found{m[0], Citation{
+			Kind:      CitationLexis,
+			Canonical: year + " U.S. " + court + ". LEXIS " + number,
+			Source:    "Lexis",
+			Text:      text[m[0]:m[1]],
+			Page:      number,
+			Year:      year,
+		}})
+	}
+	for _, m := range statuteRe.FindAllStringSubmatchIndex(text, -1) {
+		title, section := text[m[2]:m[3]], text[m[6]:m[7]]
+		code := strings.ReplaceAll(text[m[4]:m[5]], " ", "") + "."
+		all = append(all, found{m[0], Citation{
+			Kind:      CitationStatute,
+			Canonical: title + " " + code + " § " + section,
+			Source:    codes[code],
+			Text:      strings.TrimSpace(text[m[0]:m[1]]),
+			Volume:    title,
+			Page:      section,
+		}})
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].at < all[j].at })
+	var citations []Citation
+	seen := make(map[string]bool, len(all))
+	for _, f := range all {
+		if !seen[f.citation.Canonical] {
+			seen[f.citation.Canonical] = true
+			citations = append(citations, f.citation)
+		}
+	}
+	return citations
+}
@@ -0,0 +1,29 @@
+package legal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCitations(t *testing.T) {
+	text := "See Doe v. United States, 123 F.Supp.2d 456, 460 (S.D. Fla. 2000); " +
+		"Twombly, 550 U.S. 544 (2007); Doe v. Epstein, 2019 WL 1234567 (S.D.N.Y.); " +
+		"2009 U.S. Dist. LEXIS 12345; in violation of 18 U.S.C. § 1591(a) and 28 C.F.R. § 16.21. " +
+		"Again 123 F. Supp. 2d 456, and United States v. Smith, 45 F. App’x 678. Exhibit 12 Frank 3."
+	var got []string
+	for _, c := range FindCitations(text) {
+		got = append(got, c.Kind+": "+c.Canonical+" ("+c.Source+")")
+	}
+	want := []string{
+		"case: 123 F. Supp. 2d 456 (Federal Supplement, Second Series)",
+		"case: 550 U.S. 544 (United States Reports)",
+		"westlaw: 2019 WL 1234567 (Westlaw)",
+		"lexis: 2009 U.S. Dist. LEXIS 12345 (Lexis)",
+		"statute: 18 U.S.C. § 1591(a) (United States Code)",
+		"statute: 28 C.F.R. § 16.21 (Code of Federal Regulations)",
+		"case: 45 F. App'x 678 (Federal Appendix)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindCitations() =\n%q\nwant\n%q", got, want)
+	}
+}
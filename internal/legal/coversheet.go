@@ -0,0 +1,115 @@
+package legal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Confidentiality designations, from most to least restrictive
+const (
+	DesignationHighlyConfidentialAEO = "HIGHLY CONFIDENTIAL - ATTORNEYS' EYES ONLY"
+	DesignationAEO                   = "ATTORNEYS' EYES ONLY"
+	DesignationHighlyConfidential    = "HIGHLY CONFIDENTIAL"
+	DesignationConfidential          = "CONFIDENTIAL"
+)
+
+// CoverSheet holds the production details printed on a document's cover sheet
+type CoverSheet struct {
+	ProducingParty string `json:"producing_party,omitempty"` // e.g. "Federal Bureau of Investigation"
+	Date           string `json:"date,omitempty"`            // production date as written, e.g. "March 3, 2021"
+	Designation    string `json:"designation,omitempty"`     // one of the Designation constants, or the sheet's own wording in upper case
+}
+
+// maxCoverValue is the longest value taken from a labeled field; longer text
+// after a label is prose, not a form entry
+const maxCoverValue = 100
+
+var (
+	// Labels introducing each field, with the value after a colon or on the
+	// next line
+	producingPartyLabelRe = regexp.MustCompile(`(?i)^\s*(?:producing (?:party|entity|agency)|produced by|production by|produced on behalf of)\s*(?::\s*(.*))?$`)
+	productionDateLabelRe = regexp.MustCompile(`(?i)^\s*(?:production date|date of production|date produced|produced on|date)\s*(?::\s*(.*))?$`)
+	designationLabelRe    = regexp.MustCompile(`(?i)^\s*(?:confidentiality(?: designation)?|designation|classification)\s*(?::\s*(.*))?$`)
+
+	highlyConfidentialAEORe = regexp.MustCompile(`(?i)highly\s+confidential\W+(?:(?:outside\s+)?attorneys?['’]?s?\W+eyes\s+only|aeo)\b`)
+	aeoRe                   = regexp.MustCompile(`(?i)\b(?:outside\s+)?(?:attorneys?|counsel)['’]?s?\W+eyes\s+only\b`)
+	highlyConfidentialRe    = regexp.MustCompile(`(?i)\bhighly\s+confidential\b`)
+	confidentialRe          = regexp.MustCompile(`(?i)\bconfidential\b`)
+	notConfidentialRe       = regexp.MustCompile(`(?i)\b(?:non|not)\W*confidential\b`)
+	// designationStampRe matches a line that is only a designation stamp,
+	// possibly with a protective order reference
+	designationStampRe = regexp.MustCompile(`^[\s\W]*(?:HIGHLY\s+)?CONFIDENTIAL(?:\W+(?:OUTSIDE\s+)?(?:ATTORNEYS?|COUNSEL)['’]?S?\W+EYES\s+ONLY|\W+AEO)?(?:\W+SUBJECT\s+TO\s+(?:A\s+)?PROTECTIVE\s+ORDER)?[\s\W]*$|^[\s\W]*(?:OUTSIDE\s+)?(?:ATTORNEYS?|COUNSEL)['’]?S?\W+EYES\s+ONLY[\s\W]*$`)
+)
+
+// ParseCoverSheet reads the producing party, production date and
+// confidentiality designation from the lines of a cover sheet (normally the
+// first page). Labeled fields ("Producing Party: ...") are preferred; a
+// designation may also be a stamp on a line of its own.
+func ParseCoverSheet(lines []string) CoverSheet {
+	var sheet CoverSheet
+	for i, line := range lines {
+		if m := producingPartyLabelRe.FindStringSubmatch(line); m != nil && sheet.ProducingParty == "" {
+			sheet.ProducingParty = labeledValue(lines, i, m[1])
+			continue
+		}
+		if m := productionDateLabelRe.FindStringSubmatch(line); m != nil && sheet.Date == "" {
+			if value := labeledValue(lines, i, m[1]); strings.ContainsAny(value, "0123456789") {
+				sheet.Date = value
+			}
+			continue
+		}
+		if m := designationLabelRe.FindStringSubmatch(line); m != nil && sheet.Designation == "" {
+			if value := labeledValue(lines, i, m[1]); value != "" {
+				sheet.Designation = CanonicalDesignation(value)
+			}
+			continue
+		}
+		if sheet.Designation == "" && designationStampRe.MatchString(line) {
+			sheet.Designation = CanonicalDesignation(line)
+		}
+	}
+	return sheet
+}
+
+// IsEmpty reports whether no cover sheet field was found
+func (c CoverSheet) IsEmpty() bool {
+	return c.ProducingParty == "" && c.Date == "" && c.Designation == ""
+}
+
+// labeledValue returns the value of the label on lines[i]: inline, the text
+// after its colon, or else the next non-blank line
+func labeledValue(lines []string, i int, inline string) string {
+	value := strings.TrimSpace(inline)
+	if value == "" && i+1 < len(lines) {
+		for _, next := range lines[i+1:] {
+			if value = strings.TrimSpace(next); value != "" {
+				break
+			}
+		}
+		if strings.Contains(value, ":") {
+			return "" // the next label, not a value
+		}
+	}
+	value = strings.Join(strings.Fields(strings.TrimRight(value, ".,;")), " ")
+	if len(value) > maxCoverValue {
+		return ""
+	}
+	return value
+}
+
+// CanonicalDesignation maps the wording of a designation to one of the
+// Designation constants, keeping unrecognized wording (e.g. "PUBLIC") in upper
+// case
+func CanonicalDesignation(s string) string {
+	switch {
+	case highlyConfidentialAEORe.MatchString(s):
+		return DesignationHighlyConfidentialAEO
+	case aeoRe.MatchString(s):
+		return DesignationAEO
+	case highlyConfidentialRe.MatchString(s):
+		return DesignationHighlyConfidential
+	case confidentialRe.MatchString(s) && !notConfidentialRe.MatchString(s):
+		return DesignationConfidential
+	}
+	return strings.ToUpper(strings.Join(strings.Fields(strings.Trim(s, " \t-–—*")), " "))
+}
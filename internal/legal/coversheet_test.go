@@ -0,0 +1,60 @@
+package legal
+
+import "testing"
+
+func TestParseCoverSheet(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  CoverSheet
+	}{
+		{
+			name: "labeled fields",
+			lines: []string{
+				"DOCUMENT PRODUCTION",
+				"Producing Party: Federal Bureau of Investigation",
+				"Production Date: March 3, 2021",
+				"Confidentiality Designation: Highly Confidential - Attorneys' Eyes Only",
+				"Bates Range: EFTA00001234 - EFTA00001300",
+			},
+			want: CoverSheet{ProducingParty: "Federal Bureau of Investigation", Date: "March 3, 2021", Designation: DesignationHighlyConfidentialAEO},
+		},
+		{
+			name: "values on the next line and a stamp",
+			lines: []string{
+				"PRODUCED BY:",
+				"",
+				"Metropolitan Correctional Center.",
+				"DATE PRODUCED:",
+				"06/14/2019",
+				"CONFIDENTIAL — SUBJECT TO PROTECTIVE ORDER",
+			},
+			want: CoverSheet{ProducingParty: "Metropolitan Correctional Center", Date: "06/14/2019", Designation: DesignationConfidential},
+		},
+		{
+			name:  "label without a value",
+			lines: []string{"Producing Party:", "Production Date: 2021-03-03", "Designation: Not Confidential"},
+			want:  CoverSheet{Date: "2021-03-03", Designation: "NOT CONFIDENTIAL"},
+		},
+		{
+			name: "prose mentioning confidentiality",
+			lines: []string{
+				"The parties agreed that the settlement would remain confidential.",
+				"Date: to be determined",
+			},
+			want: CoverSheet{},
+		},
+		{
+			name:  "attorneys' eyes only stamp",
+			lines: []string{"OUTSIDE COUNSEL'S EYES ONLY", "Page 1 of 3"},
+			want:  CoverSheet{Designation: DesignationAEO},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseCoverSheet(tt.lines); got != tt.want {
+				t.Errorf("ParseCoverSheet() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package legal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ExhibitMatch is a reference to an exhibit found in text
+type ExhibitMatch struct {
+	Text      string // as written, e.g. "Ex. 012" or "Government Exhibit 101"
+	Canonical string // e.g. "Exhibit 12" or "GX 101"
+}
+
+// exhibitNumber matches an exhibit number or letter: "12", "12A", "101-3",
+// "A", "A-1"
+const exhibitNumber = `(\d+[A-Z]?(?:[-.]\d+)?|[A-Z](?:-\d+)?)\b`
+
+var (
+	// partyExhibitRe matches a party's exhibit by its trial abbreviation
+	// ("GX-101", "DX 5", "PX5") or spelled out ("Government Exhibit 101",
+	// "Gov't Ex. 101", "Defendant's Exhibit 5", "Plaintiff's Exhibit 3")
+	partyExhibitRe = regexp.MustCompile(`\b(?:([GDP])X[-–\s]?|(Government|Gov['’]?t|Defen[sd]e|Defendant['’]?s|Plaintiff['’]?s)\s+(?:Exhibit|Exh?\.)\s*(?:No\.\s*)?)` + `(\d+[A-Z]?(?:[-.]\d+)?)\b`)
+	// exhibitRe matches a plain exhibit reference: "Exhibit 12", "Exhibits
+	// 12 and 13" (the first), "EXHIBIT A", "Ex. 4", "Exh. No. 7"
+	exhibitRe = regexp.MustCompile(`\b(?:[Ee]xhibits?|EXHIBITS?|Exh?\.|EXH?\.)\s*(?:No\.\s*)?` + exhibitNumber)
+)
+
+// FindExhibits returns the exhibit references in text, in the order they
+// appear, along with their canonical form. Exhibits of a party ("GX 101",
+// "DX 5", "PX 3") are kept apart from plain ones ("Exhibit 12"); numbers lose
+// their leading zeros.
+func FindExhibits(text string) []ExhibitMatch {
+	type located struct {
+		ExhibitMatch
+		at int
+	}
+	var found []located
+	var taken [][]int
+	for _, loc := range partyExhibitRe.FindAllStringSubmatchIndex(text, -1) {
+		// G, D or P, abbreviated or the first letter of the party spelled out
+		var party string
+		if loc[2] >= 0 {
+			party = text[loc[2]:loc[3]]
+		} else {
+			party = strings.ToUpper(text[loc[4] : loc[4]+1])
+		}
+		canonical := party + "X " + trimZeros(text[loc[6]:loc[7]])
+		found = append(found, located{ExhibitMatch{Text: text[loc[0]:loc[1]], Canonical: canonical}, loc[0]})
+		taken = append(taken, loc[:2])
+	}
+	for _, loc := range exhibitRe.FindAllStringSubmatchIndex(text, -1) {
+		if overlaps(taken, loc[0], loc[1]) {
+			continue // "Government Exhibit 101" is not also "Exhibit 101"
+		}
+		canonical := "Exhibit " + trimZeros(text[loc[2]:loc[3]])
+		found = append(found, located{ExhibitMatch{Text: text[loc[0]:loc[1]], Canonical: canonical}, loc[0]})
+	}
+	// Put the two kinds back in text order
+	sort.SliceStable(found, func(i, j int) bool { return found[i].at < found[j].at })
+	matches := make([]ExhibitMatch, len(found))
+	for i, f := range found {
+		matches[i] = f.ExhibitMatch
+	}
+	return matches
+}
+
+// ExhibitLabel returns the canonical exhibit a name or title designates when
+// it starts with one, such as the file name "GX-101" or "Exhibit 12 - Flight
+// logs" or the slip sheet title "EXHIBIT A", or "" if it does not
+func ExhibitLabel(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "_", " "))
+	if matches := FindExhibits(name); len(matches) > 0 && strings.HasPrefix(name, matches[0].Text) {
+		return matches[0].Canonical
+	}
+	return ""
+}
+
+// trimZeros drops the leading zeros of an exhibit number: "012" is "12"
+func trimZeros(number string) string {
+	if trimmed := strings.TrimLeft(number, "0"); trimmed != "" && trimmed[0] >= '1' && trimmed[0] <= '9' {
+		return trimmed
+	}
+	return number
+}
+
+// overlaps reports whether [start, end) overlaps any of spans
+func overlaps(spans [][]int, start, end int) bool {
+	for _, span := range spans {
+		if start < span[1] && span[0] < end {
+			return true
+		}
+	}
+	return false
+}
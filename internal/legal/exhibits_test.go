@@ -0,0 +1,44 @@
+package legal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindExhibits(t *testing.T) {
+	text := "As shown in Exhibit 12 and Ex. 012, see also EXHIBIT A-1. At trial, Government Exhibit 101 " +
+		"(GX-101) contradicted DX 5 and Plaintiff's Exhibit 3. Exhibits 14 and 15 were excluded. " +
+		"The exhibit a witness saw, an exhibition, and EXHIBIT TO DECLARATION are not references."
+	var got []string
+	for _, m := range FindExhibits(text) {
+		got = append(got, m.Text+" => "+m.Canonical)
+	}
+	want := []string{
+		"Exhibit 12 => Exhibit 12",
+		"Ex. 012 => Exhibit 12",
+		"EXHIBIT A-1 => Exhibit A-1",
+		"Government Exhibit 101 => GX 101",
+		"GX-101 => GX 101",
+		"DX 5 => DX 5",
+		"Plaintiff's Exhibit 3 => PX 3",
+		"Exhibits 14 => Exhibit 14",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindExhibits() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestExhibitLabel(t *testing.T) {
+	for name, want := range map[string]string{
+		"Exhibit_12":                  "Exhibit 12",
+		"GX-101":                      "GX 101",
+		"Exhibit 12 - Flight logs":    "Exhibit 12",
+		"EXHIBIT A":                   "Exhibit A",
+		"Motion to compel, Exhibit 4": "",
+		"EFTA00010724":                "",
+	} {
+		if got := ExhibitLabel(name); got != want {
+			t.Errorf("ExhibitLabel(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package legal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of signature found on a page
+const (
+	SignatureElectronic = "electronic" // "/s/ Jane Doe"
+	SignatureBlock      = "block"      // a signature line or closing followed by the signer's name
+	SignatureNotary     = "notary"     // notarization or jurat language
+)
+
+// Signature is a signature or attestation found on a page
+type Signature struct {
+	Kind string `json:"kind"`           // SignatureElectronic, SignatureBlock or SignatureNotary
+	Name string `json:"name,omitempty"` // the signer, where it can be read
+	Line string `json:"line"`           // the line it was found on
+}
+
+// signatureNameLines is how many lines below a signature line or closing the
+// signer's name is looked for
+const signatureNameLines = 3
+
+var (
+	// electronicSignatureRe matches "/s/ Name", capturing the name
+	electronicSignatureRe = regexp.MustCompile(`/[sS]/\s*([A-Z][A-Za-z.'’-]*(?:\s+[A-Z][A-Za-z.'’-]*){0,4})`)
+	// signatureLineRe matches a blank to sign on, alone or after "By:" or
+	// "Signature:"
+	signatureLineRe = regexp.MustCompile(`(?i)^\s*(?:(?:by|signed|signature|sign here)\s*:?\s*_{3,}|_{8,})\s*$`)
+	// closingRe matches the closing a signature follows
+	closingRe = regexp.MustCompile(`(?i)^\s*(?:respectfully submitted|sincerely(?: yours)?|very truly yours|yours truly|in witness whereof.*)[,.:]?\s*$`)
+	// notaryRe matches notarization, jurat and acknowledgment language
+	notaryRe = regexp.MustCompile(`(?i)notary public|(?:sworn (?:to )?and subscribed|subscribed and sworn)|my commission expires|personally appeared before me|acknowledged before me|under penalty of perjury`)
+	// signerRe matches a line holding only a person's name, optionally
+	// followed by a suffix ("Esq.", "Jr.")
+	signerRe = regexp.MustCompile(`^\s*([A-Z][A-Za-z.'’-]*(?:\s+[A-Z][A-Za-z.'’-]*){1,4})(?:,?\s+(?:Esq|Jr|Sr|II|III|P\.A)\.?)?\s*,?\s*$`)
+)
+
+// FindSignatures returns the electronic signatures, signature blocks and
+// notarization language in the lines of a page, each kind and signer once
+func FindSignatures(lines []string) []Signature {
+	var found []Signature
+	seen := make(map[Signature]bool)
+	add := func(sig Signature) {
+		key := Signature{Kind: sig.Kind, Name: sig.Name}
+		if sig.Kind == SignatureNotary {
+			key.Name = "" // one notarization per page is enough to flag it
+		}
+		if !seen[key] {
+			seen[key] = true
+			found = append(found, sig)
+		}
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		for _, m := range electronicSignatureRe.FindAllStringSubmatch(line, -1) {
+			add(Signature{Kind: SignatureElectronic, Name: strings.TrimSpace(m[1]), Line: trimmed})
+		}
+		if signatureLineRe.MatchString(line) || closingRe.MatchString(line) {
+			if name, ok := signerBelow(lines, i); ok {
+				add(Signature{Kind: SignatureBlock, Name: name, Line: trimmed})
+			}
+		}
+		if notaryRe.MatchString(line) {
+			add(Signature{Kind: SignatureNotary, Line: trimmed})
+		}
+	}
+	return found
+}
+
+// signerBelow looks for the signer's name in the lines after lines[i],
+// skipping blanks, signature lines and "/s/" signatures (already reported)
+func signerBelow(lines []string, i int) (string, bool) {
+	for j, seen := i+1, 0; j < len(lines) && seen < signatureNameLines; j++ {
+		line := lines[j]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		seen++
+		if signatureLineRe.MatchString(line) || electronicSignatureRe.MatchString(line) {
+			continue
+		}
+		if m := signerRe.FindStringSubmatch(line); m != nil {
+			return m[1], true
+		}
+		return "", false
+	}
+	return "", false
+}
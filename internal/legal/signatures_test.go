@@ -0,0 +1,43 @@
+package legal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindSignatures(t *testing.T) {
+	lines := []string{
+		"Dated: April 16, 2019",
+		"Respectfully submitted,",
+		"/s/ Sigrid S. McCawley",
+		"Sigrid S. McCawley",
+		"BOIES SCHILLER FLEXNER LLP",
+		"",
+		"By: ______________________",
+		"Jeffrey E. Epstein",
+		"Sworn to and subscribed before me this 3rd day of March, 2005.",
+		"NOTARY PUBLIC, State of Florida",
+		"My commission expires: 06/01/2008",
+	}
+	want := []Signature{
+		{Kind: SignatureBlock, Name: "Sigrid S. McCawley", Line: "Respectfully submitted,"},
+		{Kind: SignatureElectronic, Name: "Sigrid S. McCawley", Line: "/s/ Sigrid S. McCawley"},
+		{Kind: SignatureBlock, Name: "Jeffrey E. Epstein", Line: "By: ______________________"},
+		{Kind: SignatureNotary, Line: "Sworn to and subscribed before me this 3rd day of March, 2005."},
+	}
+	if got := FindSignatures(lines); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSignatures() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestFindSignaturesIgnoresOrdinaryText(t *testing.T) {
+	lines := []string{
+		"The witness was asked whether she had signed the agreement.",
+		"Sincerely,",
+		"the undersigned parties agree as follows:",
+		"Name: ____________",
+	}
+	if got := FindSignatures(lines); len(got) != 0 {
+		t.Errorf("FindSignatures() = %+v, want none", got)
+	}
+}
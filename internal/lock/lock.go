@@ -0,0 +1,88 @@
+// Package lock provides the advisory lock that keeps two runs from writing to
+// the same documents tree (and its catalog) at once.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// FileName is the lock file kept in the root of a documents tree. It holds
+// the PID of the run holding the lock.
+const FileName = ".lock"
+
+// errLocked is returned by tryLock when another process holds the lock
+var errLocked = errors.New("locked")
+
+// Lock is a held lock on a documents tree
+type Lock struct {
+	file *os.File
+}
+
+// LockedError reports a documents tree locked by another run
+type LockedError struct {
+	Root string
+	PID  int // 0 if the holder did not record its PID
+}
+
+// Error implements error
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("documents tree %s is already locked by another run", e.Root)
+	}
+	return fmt.Sprintf("documents tree %s is already locked by PID %d", e.Root, e.PID)
+}
+
+// Acquire locks the documents tree at root, creating it if needed. It fails
+// immediately with a *LockedError if another run holds the lock. The lock is
+// advisory and also ends when the process exits, so a crashed run never
+// leaves the tree locked.
+func Acquire(root string, perms pathutil.Permissions) (*Lock, error) {
+	if err := perms.MkdirAll(root); err != nil {
+		return nil, fmt.Errorf("failed to create documents directory: %w", err)
+	}
+	path := filepath.Join(root, FileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, perms.FileMode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := tryLock(file); err != nil {
+		pid := readPID(file)
+		file.Close()
+		if errors.Is(err, errLocked) {
+			return nil, &LockedError{Root: root, PID: pid}
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// Record who holds the lock for the error message of the next run
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: file}, nil
+}
+
+// Release unlocks the tree. The lock file itself is left in place: removing
+// it could let two runs lock different files of the same name.
+func (l *Lock) Release() error {
+	l.file.Truncate(0)
+	unlock(l.file)
+	return l.file.Close()
+}
+
+// readPID returns the PID recorded in a lock file, or 0
+func readPID(file *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := file.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
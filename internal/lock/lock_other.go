@@ -0,0 +1,16 @@
+//go:build !(unix && !solaris && !aix) && !windows
+
+package lock
+
+import "os"
+
+// tryLock always succeeds on platforms without flock or LockFileEx; the PID
+// is still recorded
+func tryLock(file *os.File) error {
+	return nil
+}
+
+// unlock is a no-op where tryLock is
+func unlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestAcquireExcludesSecondRun(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "documents")
+	first, err := Acquire(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err = Acquire(root, pathutil.Permissions{})
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second Acquire() error = %v, want a *LockedError", err)
+	}
+	if locked.PID != os.Getpid() {
+		t.Errorf("LockedError.PID = %d, want %d", locked.PID, os.Getpid())
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	again, err := Acquire(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	again.Release()
+}
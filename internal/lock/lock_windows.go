@@ -0,0 +1,31 @@
+//go:build windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockRange is the region of the lock file that is locked: one byte far past
+// the recorded PID, so other processes can still read the PID (Windows locks
+// are mandatory for the bytes they cover)
+var lockRange = windows.Overlapped{OffsetHigh: 1}
+
+// tryLock takes an exclusive lock on file without waiting
+func tryLock(file *os.File) error {
+	overlapped := lockRange
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLocked
+	}
+	return err
+}
+
+// unlock releases the lock on file
+func unlock(file *os.File) error {
+	overlapped := lockRange
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &overlapped)
+}
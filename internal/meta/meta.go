@@ -0,0 +1,152 @@
+// Package meta reads the hand-curated metadata sidecar (meta.yaml) kept in a
+// document's directory, where people working on the tree record notes,
+// corrected titles, tags and any fields of their own.
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// FileName is the name of the sidecar in a document's {type}/{name}/
+// directory
+const FileName = "meta.yaml"
+
+// Meta is the curated metadata of a document
+type Meta struct {
+	Title  string         `yaml:"title,omitempty" json:"title,omitempty"` // corrected document title
+	Notes  string         `yaml:"notes,omitempty" json:"notes,omitempty"`
+	Tags   []string       `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Fields map[string]any `yaml:",inline" json:"fields,omitempty"` // any other keys, as written
+}
+
+// Path returns where the sidecar of the document at docPath is kept
+func Path(docPath string) string {
+	return filepath.Join(filepath.Dir(docPath), FileName)
+}
+
+// Load reads the sidecar of the document at docPath. It returns nil if the
+// document has none (or it is empty).
+func Load(docPath string) (*Meta, error) {
+	path := Path(docPath)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var m Meta
+	if err := yaml.NewDecoder(file).Decode(&m); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if m.IsEmpty() {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// Save writes m as the sidecar of the document at docPath
+func Save(docPath string, m *Meta, perms pathutil.Permissions) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", FileName, err)
+	}
+	if err := perms.WriteFile(Path(docPath), data); err != nil {
+		return fmt.Errorf("failed to save %s: %w", Path(docPath), err)
+	}
+	return nil
+}
+
+// IsEmpty reports whether nothing was curated
+func (m *Meta) IsEmpty() bool {
+	return m == nil || (m.Title == "" && m.Notes == "" && len(m.Tags) == 0 && len(m.Fields) == 0)
+}
+
+// HasTag reports whether the document is tagged tag, ignoring case
+func (m *Meta) HasTag(tag string) bool {
+	if m == nil {
+		return false
+	}
+	return slices.ContainsFunc(m.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// Class returns the document class a curator recorded under "class", such
+// as "deposition", or "" if none is. Documents are not classified
+// automatically.
+func (m *Meta) Class() string {
+	if m == nil {
+		return ""
+	}
+	class, _ := m.Fields["class"].(string)
+	return strings.TrimSpace(class)
+}
+
+// HasClass reports whether the document's class is class, ignoring case
+func (m *Meta) HasClass(class string) bool {
+	return m.Class() != "" && strings.EqualFold(m.Class(), class)
+}
+
+// Text returns every curated value (title, notes, tags and the values of
+// custom fields, in key order) as one block of text for searching
+func (m *Meta) Text() string {
+	if m == nil {
+		return ""
+	}
+	var parts []string
+	for _, s := range []string{m.Title, m.Notes} {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	parts = append(parts, m.Tags...)
+	parts = appendValues(parts, m.Fields)
+	return strings.Join(parts, "\n")
+}
+
+// appendValues appends the scalar values found in v, descending into lists
+// and maps (in key order)
+func appendValues(parts []string, v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return parts
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts = appendValues(parts, v[key])
+		}
+		return parts
+	case []any:
+		for _, item := range v {
+			parts = appendValues(parts, item)
+		}
+		return parts
+	case time.Time: // unquoted dates are decoded as timestamps
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return append(parts, v.Format(time.DateOnly))
+		}
+		return append(parts, v.Format(time.RFC3339))
+	default:
+		return append(parts, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMeta(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "EFTA00010724.pdf")
+}
+
+func TestLoad(t *testing.T) {
+	docPath := writeMeta(t, `title: Flight log, 1997-2005
+notes: |
+  Pages 3-4 are duplicates of EFTA00010720.
+tags: [flight-logs, Reviewed]
+custodian: FBI
+class: Deposition
+reviewers:
+  - name: Lena
+    date: 2026-02-01
+`)
+	m, err := Load(docPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if m.Title != "Flight log, 1997-2005" {
+		t.Errorf("Title = %q", m.Title)
+	}
+	if len(m.Tags) != 2 || !m.HasTag("reviewed") || m.HasTag("court") {
+		t.Errorf("Tags = %v", m.Tags)
+	}
+	if m.Fields["custodian"] != "FBI" {
+		t.Errorf("Fields = %v, want custom keys kept", m.Fields)
+	}
+	if m.Class() != "Deposition" || !m.HasClass("deposition") || m.HasClass("exhibit") {
+		t.Errorf("Class() = %q, want Deposition", m.Class())
+	}
+	if _, ok := m.Fields["title"]; ok {
+		t.Error("known keys must not be repeated in Fields")
+	}
+
+	want := "Flight log, 1997-2005\nPages 3-4 are duplicates of EFTA00010720.\nflight-logs\nReviewed\nDeposition\nFBI\n2026-02-01\nLena"
+	if got := m.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadWithoutSidecar(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), "doc.pdf"))
+	if m != nil || err != nil {
+		t.Errorf("Load() = %v, %v, want nil, nil", m, err)
+	}
+
+	m, err = Load(writeMeta(t, "# nothing yet\n"))
+	if m != nil || err != nil {
+		t.Errorf("Load() of an empty sidecar = %v, %v, want nil, nil", m, err)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := Load(writeMeta(t, "tags: {not: a list}\n")); err == nil {
+		t.Error("Load() accepted tags that are not a list")
+	}
+	if _, err := Load(writeMeta(t, "title: [unterminated\n")); err == nil {
+		t.Error("Load() accepted malformed YAML")
+	}
+}
@@ -0,0 +1,223 @@
+// Package notify sends the report of a verification run, by webhook and
+// email, so silent corruption of an archived tree (bit rot, truncated or
+// deleted files) reaches someone without anyone reading the output of
+// scheduled runs.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/corpus"
+)
+
+// webhookTimeout is how long a webhook may take to accept a report
+const webhookTimeout = 30 * time.Second
+
+// Problem is a document that failed verification
+type Problem struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`             // corpus.VerifyMismatch, VerifyMissing or VerifyError
+	Expected string `json:"expected,omitempty"` // recorded checksum
+	Actual   string `json:"actual,omitempty"`   // computed checksum
+	Error    string `json:"error,omitempty"`    // why the file could not be read
+}
+
+// Report sums up a verification run of a documents tree
+type Report struct {
+	Root     string         `json:"root"`
+	Host     string         `json:"host,omitempty"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Bytes    int64          `json:"bytes"`    // bytes checksummed
+	Counts   map[string]int `json:"counts"`   // documents by verification status
+	Problems []Problem      `json:"problems"` // mismatched, missing and unreadable documents, by path
+}
+
+// NewReport starts the report of a run verifying root
+func NewReport(root string) *Report {
+	host, _ := os.Hostname()
+	return &Report{Root: root, Host: host, Started: time.Now().UTC(), Counts: make(map[string]int), Problems: []Problem{}}
+}
+
+// Add records the result of one document
+func (r *Report) Add(check corpus.Check) {
+	r.Counts[check.Status]++
+	r.Bytes += check.Bytes
+	switch check.Status {
+	case corpus.VerifyMismatch, corpus.VerifyMissing, corpus.VerifyError:
+		problem := Problem{Path: check.Path, Status: check.Status, Expected: check.Expected, Actual: check.Actual}
+		if check.Err != nil {
+			problem.Error = check.Err.Error()
+		}
+		r.Problems = append(r.Problems, problem)
+	}
+}
+
+// Finish marks the run as complete, sorting the problems by path
+func (r *Report) Finish() {
+	r.Finished = time.Now().UTC()
+	sort.Slice(r.Problems, func(i, j int) bool { return r.Problems[i].Path < r.Problems[j].Path })
+}
+
+// Clean reports whether every catalogued document was found intact
+func (r *Report) Clean() bool {
+	return len(r.Problems) == 0
+}
+
+// Subject returns a one-line summary of the report
+func (r *Report) Subject() string {
+	if r.Clean() {
+		return fmt.Sprintf("Verification of %s: all %d document(s) intact", r.Root, r.Counts[corpus.VerifyOK])
+	}
+	return fmt.Sprintf("Verification of %s: %d problem(s) found", r.Root, len(r.Problems))
+}
+
+// Text returns the report as plain text, for email
+func (r *Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", r.Subject())
+	fmt.Fprintf(&b, "Tree:     %s\n", r.Root)
+	if r.Host != "" {
+		fmt.Fprintf(&b, "Host:     %s\n", r.Host)
+	}
+	fmt.Fprintf(&b, "Started:  %s\n", r.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Finished: %s\n", r.Finished.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Verified: %d byte(s)\n\n", r.Bytes)
+	for _, status := range []string{corpus.VerifyOK, corpus.VerifyMismatch, corpus.VerifyMissing, corpus.VerifyUnrecorded, corpus.VerifyError} {
+		if r.Counts[status] > 0 {
+			fmt.Fprintf(&b, "%s: %d\n", status, r.Counts[status])
+		}
+	}
+	if len(r.Problems) > 0 {
+		b.WriteString("\nProblems:\n")
+	}
+	for _, p := range r.Problems {
+		switch p.Status {
+		case corpus.VerifyMismatch:
+			fmt.Fprintf(&b, "  %-8s %s (catalog %s, file %s)\n", p.Status, p.Path, p.Expected, p.Actual)
+		case corpus.VerifyError:
+			fmt.Fprintf(&b, "  %-8s %s: %s\n", p.Status, p.Path, p.Error)
+		default:
+			fmt.Fprintf(&b, "  %-8s %s\n", p.Status, p.Path)
+		}
+	}
+	return b.String()
+}
+
+// Mail is how report emails are sent
+type Mail struct {
+	Server   string // SMTP server as host:port
+	Username string // login, "" to send without authenticating
+	Password string
+	From     string
+	To       []string
+}
+
+// Notifier delivers reports to a webhook and by email
+type Notifier struct {
+	webhook string
+	mail    *Mail
+	always  bool
+	client  *http.Client
+}
+
+// New creates a Notifier posting reports to webhook and mailing them with
+// mail, either of which may be left out. Reports of clean runs are only sent
+// when always is set.
+func New(webhook string, mail *Mail, always bool) *Notifier {
+	return &Notifier{webhook: webhook, mail: mail, always: always, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// FromConfig builds a Notifier from the notify section of the config, or
+// returns nil if neither a webhook nor email recipients are configured
+func FromConfig(cfg *config.Config) (*Notifier, error) {
+	nc := cfg.Notify
+	if nc.Webhook == "" && len(nc.To) == 0 {
+		return nil, nil
+	}
+	var mail *Mail
+	if len(nc.To) > 0 {
+		if nc.SMTP == "" || nc.From == "" {
+			return nil, errors.New("notify: sending email needs \"smtp\" and \"from\"")
+		}
+		mail = &Mail{Server: nc.SMTP, Username: nc.Username, Password: cfg.SMTPPassword(), From: nc.From, To: nc.To}
+	}
+	return New(nc.Webhook, mail, nc.Always), nil
+}
+
+// Send delivers the report to every configured destination, unless the run
+// was clean and clean runs are not reported, and returns the errors of
+// those that failed. A nil Notifier sends nothing.
+func (n *Notifier) Send(r *Report) error {
+	if n == nil || (r.Clean() && !n.always) {
+		return nil
+	}
+	var errs []error
+	if n.webhook != "" {
+		if err := n.post(r); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post report to webhook: %w", err))
+		}
+	}
+	if n.mail != nil {
+		if err := n.send(r); err != nil {
+			errs = append(errs, fmt.Errorf("failed to email report: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// post sends the report to the webhook as JSON
+func (n *Notifier) post(r *Report) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.webhook, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// send emails the report as plain text
+func (n *Notifier) send(r *Report) error {
+	var auth smtp.Auth
+	if n.mail.Username != "" {
+		host, _, err := net.SplitHostPort(n.mail.Server)
+		if err != nil {
+			return fmt.Errorf("invalid server %q: %w", n.mail.Server, err)
+		}
+		auth = smtp.PlainAuth("", n.mail.Username, n.mail.Password, host)
+	}
+	return smtp.SendMail(n.mail.Server, auth, n.mail.From, n.mail.To, n.message(r))
+}
+
+// message builds the email carrying the report
+func (n *Notifier) message(r *Report) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.mail.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.mail.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", r.Subject()))
+	fmt.Fprintf(&b, "Date: %s\r\n", r.Finished.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(r.Text(), "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/corpus"
+)
+
+func TestReport(t *testing.T) {
+	r := NewReport("documents")
+	r.Add(corpus.Check{Path: "pdf/b.pdf", Status: corpus.VerifyOK, Bytes: 100})
+	r.Add(corpus.Check{Path: "pdf/c.pdf", Status: corpus.VerifyMismatch, Expected: "aa", Actual: "bb", Bytes: 50})
+	r.Add(corpus.Check{Path: "pdf/a.pdf", Status: corpus.VerifyMissing, Expected: "cc"})
+	r.Add(corpus.Check{Path: "pdf/d.pdf", Status: corpus.VerifyUnrecorded, Bytes: 10})
+	r.Finish()
+
+	if r.Clean() || r.Bytes != 160 || r.Counts[corpus.VerifyOK] != 1 {
+		t.Fatalf("report = %+v", r)
+	}
+	if len(r.Problems) != 2 || r.Problems[0].Path != "pdf/a.pdf" || r.Problems[1].Status != corpus.VerifyMismatch {
+		t.Errorf("problems = %+v, want pdf/a.pdf missing and pdf/c.pdf mismatched", r.Problems)
+	}
+	if got, want := r.Subject(), "Verification of documents: 2 problem(s) found"; got != want {
+		t.Errorf("Subject() = %q, want %q", got, want)
+	}
+	if text := r.Text(); !strings.Contains(text, "mismatch pdf/c.pdf (catalog aa, file bb)") || strings.Contains(text, "d.pdf") {
+		t.Errorf("Text() = %q", text)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var posted []Report
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var r Report
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			t.Errorf("webhook payload: %v", err)
+		}
+		posted = append(posted, r)
+	}))
+	defer webhook.Close()
+	server, mails := smtpServer(t)
+
+	n := New(webhook.URL, &Mail{Server: server, From: "verify@example.org", To: []string{"curator@example.org"}}, false)
+	clean := NewReport("documents")
+	clean.Add(corpus.Check{Path: "pdf/a.pdf", Status: corpus.VerifyOK})
+	clean.Finish()
+	if err := n.Send(clean); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("clean report was posted")
+	}
+
+	corrupt := NewReport("documents")
+	corrupt.Add(corpus.Check{Path: "pdf/a.pdf", Status: corpus.VerifyMismatch, Expected: "aa", Actual: "bb"})
+	corrupt.Finish()
+	if err := n.Send(corrupt); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || len(posted[0].Problems) != 1 || posted[0].Problems[0].Path != "pdf/a.pdf" {
+		t.Errorf("posted = %+v, want the mismatch of pdf/a.pdf", posted)
+	}
+	mail := <-mails
+	if !strings.Contains(mail, "To: curator@example.org\r\n") || !strings.Contains(mail, "Subject: Verification of documents: 1 problem(s) found\r\n") ||
+		!strings.Contains(mail, "mismatch pdf/a.pdf") {
+		t.Errorf("mail = %q", mail)
+	}
+
+	failing := New("http://127.0.0.1:1/hook", nil, true)
+	if err := failing.Send(clean); err == nil || !strings.Contains(err.Error(), "webhook") {
+		t.Errorf("Send() to an unreachable webhook = %v, want an error", err)
+	}
+	var nilNotifier *Notifier
+	if err := nilNotifier.Send(corrupt); err != nil {
+		t.Errorf("nil Notifier: %v", err)
+	}
+}
+
+// smtpServer accepts one message on a local port, without authentication,
+// and sends its data on the returned channel
+func smtpServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(line string) {
+			rw.WriteString(line + "\r\n")
+			rw.Flush()
+		}
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := rw.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				mails <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+func TestFromConfig(t *testing.T) {
+	n, err := FromConfig(&config.Config{})
+	if n != nil || err != nil {
+		t.Errorf("FromConfig() without destinations = %v, %v, want nil", n, err)
+	}
+	cfg := &config.Config{Notify: config.NotifyConfig{To: []string{"curator@example.org"}}}
+	if _, err := FromConfig(cfg); err == nil {
+		t.Error("FromConfig() with recipients but no smtp server: want an error")
+	}
+	cfg.Notify.SMTP, cfg.Notify.From = "smtp.example.org:587", "verify@example.org"
+	t.Setenv("DEFORNICATOR_SMTP_PASSWORD", "secret")
+	if n, err := FromConfig(cfg); err != nil || n.mail.Password != "secret" {
+		t.Errorf("FromConfig() = %+v, %v, want the password from the environment", n, err)
+	}
+}
@@ -10,6 +10,10 @@ import (
 const (
 	// DefaultDocumentsDir is the default parent directory for documents
 	DefaultDocumentsDir = "documents"
+	// DefaultFilePerm is the default file permission (0644), filtered by the umask
+	DefaultFilePerm = 0644
+	// DefaultDirPerm is the default directory permission (0755), filtered by the umask
+	DefaultDirPerm = 0755
 )
 
 // GetFileType determines the file type from a filename
@@ -27,6 +31,8 @@ func GetFileType(filename string) string {
 		".rtf":  "rtf",
 		".txt":  "txt",
 		".odt":  "odt",
+		".eml":  "eml",
+		".pst":  "pst",
 	}
 	
 	if fileType, ok := extMap[ext]; ok {
@@ -39,6 +45,12 @@ func GetFileType(filename string) string {
 
 // ResolveDocumentPath resolves a document file path, checking the documents directory if it's just a filename
 func ResolveDocumentPath(input string) string {
+	return ResolveDocumentPathIn(DefaultDocumentsDir, input)
+}
+
+// ResolveDocumentPathIn resolves a document file path like
+// ResolveDocumentPath, looking bare filenames up in the documents tree at root
+func ResolveDocumentPathIn(root, input string) string {
 	// If it's already an absolute path or contains directory separators, use as-is
 	if filepath.IsAbs(input) || strings.Contains(input, string(filepath.Separator)) {
 		return input
@@ -53,7 +65,7 @@ func ResolveDocumentPath(input string) string {
 	baseName = strings.TrimSuffix(baseName, strings.ToUpper(ext))
 	
 	// Check in documents/{type}/{basename}/{filename} first (new structure)
-	typeDir := filepath.Join(DefaultDocumentsDir, fileType)
+	typeDir := filepath.Join(root, fileType)
 	docSubDir := filepath.Join(typeDir, baseName)
 	docPath := filepath.Join(docSubDir, input)
 	if _, err := os.Stat(docPath); err == nil {
@@ -90,3 +102,66 @@ func ResolvePDFPath(input string) string {
 	return ResolveDocumentPath(input)
 }
 
+
+// WriteFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so an interrupted write never leaves a truncated file.
+// perm is applied as given; callers wanting umask filtering use Permissions.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// AtomicFile is a temporary file that replaces its target path on Commit,
+// for content that is streamed rather than held in memory
+type AtomicFile struct {
+	*os.File
+	path string
+	perm os.FileMode
+}
+
+// CreateAtomic creates a temporary file next to path that becomes path, with
+// mode perm, when committed
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: tmp, path: path, perm: perm}, nil
+}
+
+// Commit closes the temporary file and renames it over the target path
+func (f *AtomicFile) Commit() error {
+	return f.CommitTo(f.path)
+}
+
+// CommitTo closes the temporary file and renames it over path instead of the
+// target path, which is left untouched
+func (f *AtomicFile) CommitTo(path string) error {
+	tmpPath := f.Name()
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, f.perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temporary file, leaving the target path untouched
+func (f *AtomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,60 @@
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Permissions controls the modes of files and directories written to the
+// documents tree. A zero mode means the default (0644/0755) filtered by the
+// process umask; an explicit mode is applied exactly, so shared trees can be
+// made group-writable (0664/0775) regardless of the user's umask.
+type Permissions struct {
+	File os.FileMode
+	Dir  os.FileMode
+}
+
+// FileMode returns the mode new files are created with
+func (p Permissions) FileMode() os.FileMode {
+	if p.File != 0 {
+		return p.File
+	}
+	return DefaultFilePerm &^ umask()
+}
+
+// WriteFile atomically writes data to path with the configured file mode
+func (p Permissions) WriteFile(path string, data []byte) error {
+	return WriteFileAtomic(path, data, p.FileMode())
+}
+
+// CreateAtomic creates a temporary file that replaces path, with the
+// configured file mode, when committed
+func (p Permissions) CreateAtomic(path string) (*AtomicFile, error) {
+	return CreateAtomic(path, p.FileMode())
+}
+
+// MkdirAll creates path and any missing parents with the configured
+// directory mode
+func (p Permissions) MkdirAll(path string) error {
+	if p.Dir == 0 {
+		return os.MkdirAll(path, DefaultDirPerm)
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil
+	}
+
+	// Create missing parents first, then set the mode explicitly since
+	// Mkdir is filtered by the umask
+	if parent := filepath.Dir(path); parent != path {
+		if err := p.MkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(path, p.Dir); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.Chmod(path, p.Dir)
+}
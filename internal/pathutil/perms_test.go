@@ -0,0 +1,58 @@
+//go:build unix
+
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		perms    Permissions
+		umask    int // set while creating files; -1 keeps the process umask
+		wantFile os.FileMode
+		wantDir  os.FileMode
+	}{
+		{"default", Permissions{}, -1, DefaultFilePerm &^ umask(), DefaultDirPerm &^ umask()},
+		// Explicit modes are applied whatever the umask
+		{"group-writable", Permissions{File: 0664, Dir: 0775}, 0077, 0664, 0775},
+		{"private", Permissions{File: 0600, Dir: 0700}, 0022, 0600, 0700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.perms.FileMode(); got != tt.wantFile {
+				t.Errorf("FileMode() = %o, want %o", got, tt.wantFile)
+			}
+
+			root := t.TempDir()
+			if tt.umask >= 0 {
+				defer syscall.Umask(syscall.Umask(tt.umask))
+			}
+			dir := filepath.Join(root, "pdf", "EFTA00010724")
+			if err := tt.perms.MkdirAll(dir); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			// Every directory created gets the mode, not just the last
+			for _, d := range []string{filepath.Join(root, "pdf"), dir} {
+				if info, err := os.Stat(d); err != nil || info.Mode().Perm() != tt.wantDir {
+					t.Errorf("%s: mode %v, %v; want %o", d, info.Mode().Perm(), err, tt.wantDir)
+				}
+			}
+			if err := tt.perms.MkdirAll(dir); err != nil {
+				t.Errorf("MkdirAll() of an existing directory error = %v", err)
+			}
+
+			path := filepath.Join(dir, "meta.yaml")
+			if err := tt.perms.WriteFile(path, []byte("tags: [flight-logs]\n")); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != tt.wantFile {
+				t.Errorf("WriteFile() created mode %v, %v; want %o", info.Mode().Perm(), err, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package pathutil
+
+import "os"
+
+// umask returns 0 on platforms without a process umask
+func umask() os.FileMode {
+	return 0
+}
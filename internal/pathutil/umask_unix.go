@@ -0,0 +1,24 @@
+//go:build unix
+
+package pathutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// processUmask is the umask the process started with. It is read once, at
+// startup, since the only way to read it is to set it, which briefly affects
+// every goroutine creating files; no other goroutine runs yet during init.
+var processUmask os.FileMode
+
+func init() {
+	mask := syscall.Umask(0)
+	syscall.Umask(mask)
+	processUmask = os.FileMode(mask)
+}
+
+// umask returns the process umask
+func umask() os.FileMode {
+	return processUmask
+}
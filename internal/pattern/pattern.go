@@ -6,23 +6,69 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
+var (
+	// groupRe matches a brace group, optionally named: {00010724-00010730},
+	// {efta=00010724-00010730} or {dataset=8}
+	groupRe = regexp.MustCompile(`\{(?:([A-Za-z_][A-Za-z0-9_]*)=)?([^{}=]*)\}`)
+	// rangeRe matches the body of a range group
+	rangeRe = regexp.MustCompile(`^(\d+)[-:](\d+)$`)
+	// prefixRe matches the letters just before a group, e.g. "EFTA"
+	prefixRe = regexp.MustCompile(`[A-Za-z]+$`)
+)
+
+// Expansion is one URL or filename a pattern expands to, with the variables
+// captured while generating it
+type Expansion struct {
+	Value string
+	Vars  map[string]any // the generated number (an int) and any named literal groups
+}
+
 // ExpandPattern expands a sequential pattern into a list of URLs/filenames
 // Pattern format: {start-end} or {start:end}
 // Example: "EFTA{00010724-00010730}.pdf" expands to EFTA00010724.pdf through EFTA00010730.pdf
 func ExpandPattern(pattern string) ([]string, error) {
-	// Pattern: {start-end} or {start:end}
-	re := regexp.MustCompile(`\{(\d+)[-:](\d+)\}`)
-	matches := re.FindStringSubmatch(pattern)
+	expansions, err := ExpandPatternVars(pattern)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]string, len(expansions))
+	for i, expansion := range expansions {
+		results[i] = expansion.Value
+	}
+	return results, nil
+}
+
+// ExpandPatternVars expands a pattern like ExpandPattern and also captures
+// variables for each result. The generated number is stored, unpadded, as
+// the range's name ({efta=00010724-00010730}) or, for an unnamed range, the
+// lowercased letters before it followed by "_number" ("efta_number"), or
+// just "number". Named literal groups such as {dataset=8} are written out
+// as their text and captured as strings.
+func ExpandPatternVars(pattern string) ([]Expansion, error) {
+	var rangeName, startStr, endStr string
+	literals := make(map[string]any)
+	for _, m := range groupRe.FindAllStringSubmatchIndex(pattern, -1) {
+		name, body := submatch(pattern, m, 1), submatch(pattern, m, 2)
+		if r := rangeRe.FindStringSubmatch(body); r != nil {
+			if startStr == "" {
+				rangeName, startStr, endStr = name, r[1], r[2]
+				if rangeName == "" {
+					rangeName = defaultName(pattern[:m[0]])
+				}
+			}
+		} else if name != "" {
+			literals[name] = body
+		}
+	}
 
-	if len(matches) != 3 {
-		// No pattern found, return as single item
-		return []string{pattern}, nil
+	if startStr == "" {
+		// No range found, return as single item
+		return []Expansion{{Value: expand(pattern, ""), Vars: nilIfEmpty(literals)}}, nil
 	}
 
-	startStr := matches[1]
-	endStr := matches[2]
 	start, err1 := strconv.Atoi(startStr)
 	end, err2 := strconv.Atoi(endStr)
 	if err1 != nil || err2 != nil {
@@ -38,15 +84,58 @@ func ExpandPattern(pattern string) ([]string, error) {
 		paddingLen = len(endStr)
 	}
 
-	var results []string
+	var results []Expansion
 	for i := start; i <= end; i++ {
 		// Format number with same padding as the pattern
 		numStr := fmt.Sprintf("%0*d", paddingLen, i)
-		// Replace pattern with the number
-		expanded := re.ReplaceAllString(pattern, numStr)
-		results = append(results, expanded)
+		vars := make(map[string]any, len(literals)+1)
+		for name, value := range literals {
+			vars[name] = value
+		}
+		vars[rangeName] = i
+		results = append(results, Expansion{Value: expand(pattern, numStr), Vars: vars})
 	}
 
 	return results, nil
 }
 
+// expand replaces the range groups of pattern with number and its named
+// literal groups with their text, leaving other braces alone
+func expand(pattern, number string) string {
+	return groupRe.ReplaceAllStringFunc(pattern, func(group string) string {
+		m := groupRe.FindStringSubmatch(group)
+		switch {
+		case rangeRe.MatchString(m[2]):
+			return number
+		case m[1] != "":
+			return m[2]
+		default:
+			return group
+		}
+	})
+}
+
+// defaultName names an unnamed range after the letters before it
+func defaultName(before string) string {
+	if prefix := prefixRe.FindString(before); prefix != "" {
+		return strings.ToLower(prefix) + "_number"
+	}
+	return "number"
+}
+
+// submatch returns the text of group n of a match, or "" if it did not take
+// part
+func submatch(s string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return ""
+	}
+	return s[m[2*n]:m[2*n+1]]
+}
+
+// nilIfEmpty returns vars, or nil if there are none
+func nilIfEmpty(vars map[string]any) map[string]any {
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
@@ -64,3 +64,42 @@ func TestExpandPattern(t *testing.T) {
 	}
 }
 
+
+func TestExpandPatternVars(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []Expansion
+	}{
+		{
+			pattern: "EFTA{00010724-00010725}.pdf",
+			want: []Expansion{
+				{Value: "EFTA00010724.pdf", Vars: map[string]any{"efta_number": 10724}},
+				{Value: "EFTA00010725.pdf", Vars: map[string]any{"efta_number": 10725}},
+			},
+		},
+		{
+			pattern: "https://example.com/DataSet%20{dataset=8}/EFTA{bates=00010724-00010724}.pdf",
+			want: []Expansion{
+				{Value: "https://example.com/DataSet%208/EFTA00010724.pdf", Vars: map[string]any{"dataset": "8", "bates": 10724}},
+			},
+		},
+		{
+			pattern: "{1-1}.pdf",
+			want:    []Expansion{{Value: "1.pdf", Vars: map[string]any{"number": 1}}},
+		},
+		{
+			pattern: "notes{draft}.pdf",
+			want:    []Expansion{{Value: "notes{draft}.pdf"}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandPatternVars(tt.pattern)
+		if err != nil {
+			t.Fatalf("ExpandPatternVars(%q) error = %v", tt.pattern, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandPatternVars(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
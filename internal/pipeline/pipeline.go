@@ -0,0 +1,139 @@
+// Package pipeline runs each document through a declarative sequence of
+// processing steps (download, verify, extract, analyze, export) with hooks
+// before and after every step, so new capabilities can be added as steps or
+// hooks rather than by growing the main loop.
+package pipeline
+
+import (
+	"fmt"
+
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/source"
+)
+
+// Standard step names
+const (
+	StepDownload = "download"
+	StepVerify   = "verify"
+	StepUnpack   = "unpack"
+	StepCurate   = "curate"
+	StepExtract  = "extract"
+	StepAnalyze  = "analyze"
+	StepExport   = "export"
+	StepSplit    = "split"
+)
+
+// Document carries one input through the pipeline. Steps fill in fields as
+// they complete.
+type Document struct {
+	Item       source.Item          // the input, as resolved by its source
+	Path       string               // local path of the document once downloaded or resolved
+	Downloaded bool                 // the document was fetched during this run
+	Unchanged  bool                 // the download matched the existing file's checksum
+	Cached     bool                 // the URL was downloaded by an earlier run, so no fetch was made
+	Skipped    string               // why the input was deliberately not processed (e.g. it is on the skip list); later steps do not run
+	PageCount  int                  // page count found by the verify step (0 if unknown)
+	Meta       *meta.Meta           // curated metadata from the document's meta.yaml, if any
+	Text       string               // extracted plain text
+	Pages      []extractor.PageText // extracted pages
+	OutputPath string               // where the extraction output was saved (the first sink's location)
+	Outputs    []string             // where each sink wrote the extraction output
+	Parts      []string             // outputs of the logical sub-documents, if split
+	Embedded   []string             // extractable documents unpacked from a PDF portfolio, PST archive or email message, to process after this one
+
+	// Prefetched is the outcome of downloading the item ahead of the
+	// pipeline in a concurrent batch (see downloader.DownloadAll), used by
+	// the download step instead of fetching it again; nil if it was not
+	Prefetched *downloader.Result
+}
+
+// fields returns the document's curated metadata fields, nil if it has none
+func (d *Document) fields() map[string]any {
+	if d.Meta == nil {
+		return nil
+	}
+	return d.Meta.Fields
+}
+
+// Step is a named stage of the pipeline
+type Step struct {
+	Name string
+	Run  func(doc *Document) error
+}
+
+// BeforeHook is called before a step runs
+type BeforeHook func(step string, doc *Document)
+
+// AfterHook is called after a step runs, with the step's error (nil on success)
+type AfterHook func(step string, doc *Document, err error)
+
+// StepError reports which step failed for a document
+type StepError struct {
+	Step string
+	Err  error
+}
+
+// Error implements error
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline runs a sequence of steps for each document
+type Pipeline struct {
+	steps  []Step
+	before []BeforeHook
+	after  []AfterHook
+}
+
+// New creates a pipeline running the given steps in order
+func New(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Steps returns the names of the pipeline's steps in order
+func (p *Pipeline) Steps() []string {
+	names := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+// Before registers a hook called before every step
+func (p *Pipeline) Before(hook BeforeHook) {
+	p.before = append(p.before, hook)
+}
+
+// After registers a hook called after every step
+func (p *Pipeline) After(hook AfterHook) {
+	p.after = append(p.after, hook)
+}
+
+// Run processes one document through every step, stopping at the first
+// failing step or at a step that skipped the document. The returned error is
+// a *StepError.
+func (p *Pipeline) Run(doc *Document) error {
+	for _, step := range p.steps {
+		for _, hook := range p.before {
+			hook(step.Name, doc)
+		}
+		err := step.Run(doc)
+		for _, hook := range p.after {
+			hook(step.Name, doc, err)
+		}
+		if err != nil {
+			return &StepError{Step: step.Name, Err: err}
+		}
+		if doc.Skipped != "" {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/source"
+)
+
+func TestRunCallsHooksInOrder(t *testing.T) {
+	var calls []string
+	p := New(
+		Step{Name: "a", Run: func(doc *Document) error { calls = append(calls, "run a"); return nil }},
+		Step{Name: "b", Run: func(doc *Document) error { calls = append(calls, "run b"); return nil }},
+	)
+	p.Before(func(step string, doc *Document) { calls = append(calls, "before "+step) })
+	p.After(func(step string, doc *Document, err error) { calls = append(calls, "after "+step) })
+
+	if err := p.Run(&Document{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := []string{"before a", "run a", "after a", "before b", "run b", "after b"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRunStopsAtFirstError(t *testing.T) {
+	failure := errors.New("boom")
+	var ran []string
+	p := New(
+		Step{Name: "a", Run: func(doc *Document) error { ran = append(ran, "a"); return failure }},
+		Step{Name: "b", Run: func(doc *Document) error { ran = append(ran, "b"); return nil }},
+	)
+	var afterErr error
+	p.After(func(step string, doc *Document, err error) { afterErr = err })
+
+	err := p.Run(&Document{})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "a" {
+		t.Fatalf("Run() error = %v, want *StepError for step a", err)
+	}
+	if !errors.Is(err, failure) {
+		t.Errorf("Run() error does not wrap the step error")
+	}
+	if afterErr != failure {
+		t.Errorf("after hook got %v, want %v", afterErr, failure)
+	}
+	if !reflect.DeepEqual(ran, []string{"a"}) {
+		t.Errorf("ran = %v, want [a]", ran)
+	}
+}
+
+func TestCurateStepRecordsMeta(t *testing.T) {
+	root := t.TempDir()
+	docPath := filepath.Join(root, "pdf", "a", "a.pdf")
+	if err := os.MkdirAll(filepath.Dir(docPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(docPath, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(meta.Path(docPath), []byte("title: Corrected title\ntags: [reviewed]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cat, err := catalog.Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cat.RecordDownload("https://example.com/a.pdf", docPath, [32]byte{}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := &Document{Path: docPath}
+	if err := New(CurateStep(cat, pathutil.Permissions{})).Run(doc); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if doc.Meta == nil || doc.Meta.Title != "Corrected title" {
+		t.Errorf("doc.Meta = %+v, want the curated title", doc.Meta)
+	}
+	if entry, _ := cat.Get(docPath); entry.Meta == nil || !entry.Meta.HasTag("reviewed") {
+		t.Errorf("catalog entry Meta = %+v, want the curated tags", entry.Meta)
+	}
+
+	// A malformed sidecar fails the document
+	if err := os.WriteFile(meta.Path(docPath), []byte("tags: [unterminated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(CurateStep(cat, pathutil.Permissions{})).Run(&Document{Path: docPath}); err == nil {
+		t.Error("Run() accepted a malformed meta.yaml")
+	}
+}
+
+func TestCurateStepSavesSourceMeta(t *testing.T) {
+	docPath := filepath.Join(t.TempDir(), "pdf", "a", "a.pdf")
+	if err := os.MkdirAll(filepath.Dir(docPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	docket := &meta.Meta{Title: "COMPLAINT", Fields: map[string]any{"docket_number": "1:15-cv-07433"}}
+	doc := &Document{Item: source.Item{Meta: docket}, Path: docPath}
+	if err := New(CurateStep(nil, pathutil.Permissions{})).Run(doc); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	saved, err := meta.Load(docPath)
+	if err != nil || saved == nil || saved.Title != "COMPLAINT" || saved.Fields["docket_number"] != "1:15-cv-07433" {
+		t.Fatalf("meta.yaml = %+v, %v, want the docket metadata", saved, err)
+	}
+
+	// A curator's edits are not overwritten by the source
+	if err := os.WriteFile(meta.Path(docPath), []byte("title: Complaint (corrected)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc = &Document{Item: source.Item{Meta: docket}, Path: docPath}
+	if err := New(CurateStep(nil, pathutil.Permissions{})).Run(doc); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if doc.Meta.Title != "Complaint (corrected)" {
+		t.Errorf("doc.Meta.Title = %q, want the curated title", doc.Meta.Title)
+	}
+}
+
+func TestExportAndSplitReuseExtractedPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flights.eml")
+	msg := "From: a@example.com\nTo: b@example.com\nDate: Mon, 04 Mar 2002 09:00:00 +0000\nSubject: Flights\n\nWhen do we leave?\n"
+	if err := os.WriteFile(path, []byte(msg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	extractions := 0
+	ext := extractor.NewWithOptions(extractor.Options{Progress: func(filePath string, done, total int) {
+		if done == total {
+			extractions++
+		}
+	}})
+	p := New(ExtractStep(ext), ExportStep(ext, nil), SplitStep(ext, nil))
+	doc := &Document{Path: path}
+	if err := p.Run(doc); err != nil {
+		t.Fatal(err)
+	}
+	if extractions != 1 {
+		t.Errorf("document extracted %d times, want once", extractions)
+	}
+	if data, err := os.ReadFile(doc.OutputPath); err != nil || !strings.Contains(string(data), "When do we leave?") {
+		t.Errorf("output = %q, %v; want the message text", data, err)
+	}
+}
@@ -0,0 +1,368 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/exif"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/sink"
+)
+
+// DownloadStep fetches remote items into the documents tree; local items
+// already have a path. A download whose checksum matches the existing file
+// is not an error. When cat is not nil, URLs it already maps to a stored
+// document are not fetched again, and new downloads are recorded in it.
+// Items on the downloader's skip list are skipped, even if already stored.
+// An item with a Prefetched result takes it instead of being fetched.
+func DownloadStep(dl *downloader.Downloader, cat *catalog.Catalog) Step {
+	return Step{
+		Name: StepDownload,
+		Run: func(doc *Document) error {
+			if doc.Path != "" {
+				return nil // already resolved by the caller
+			}
+			item := doc.Item
+			if !item.Remote() {
+				doc.Path = item.Path
+				return nil
+			}
+
+			if err := dl.Skipped(item.Input); err != nil {
+				return skipped(doc, err)
+			}
+			if cat != nil {
+				if _, path, ok := cat.Lookup(item.URL); ok {
+					doc.Path = path
+					doc.Cached = true
+					return nil
+				}
+			}
+
+			// A concurrent batch may already have fetched and stored it
+			var filePath string
+			var err error
+			if doc.Prefetched != nil {
+				filePath, err = doc.Prefetched.Path, doc.Prefetched.Err
+			} else {
+				body, fetchErr := item.Fetch()
+				if fetchErr != nil {
+					return skipped(doc, fetchErr)
+				}
+				defer body.Close()
+				filePath, err = dl.Store(item.Input, body)
+			}
+			if err == downloader.ErrFileExists {
+				doc.Path = filePath
+				doc.Unchanged = true
+			} else if err != nil {
+				return skipped(doc, err)
+			} else {
+				doc.Path = filePath
+				doc.Downloaded = true
+			}
+			return recordDownload(cat, item.URL, doc.Path)
+		},
+	}
+}
+
+// skipped marks doc as skipped if err is a *downloader.SkipError, which is
+// then not a failure, and returns any other error
+func skipped(doc *Document, err error) error {
+	var skip *downloader.SkipError
+	if errors.As(err, &skip) {
+		doc.Skipped = skip.Error()
+		return nil
+	}
+	return err
+}
+
+// recordDownload records in cat (if not nil) that url is stored at path
+func recordDownload(cat *catalog.Catalog, url, path string) error {
+	if cat == nil {
+		return nil
+	}
+	sum, err := corpus.FileChecksum(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	return cat.RecordDownload(url, path, sum)
+}
+
+// VerifyStep checks that the document exists, is a non-empty file and is not
+// truncated or corrupt, recording its page count. When cat is not nil the
+// page count is stored in it, and a document that fails the check is dropped
+// from it so its URL is fetched again next time.
+func VerifyStep(cat *catalog.Catalog) Step {
+	return Step{
+		Name: StepVerify,
+		Run: func(doc *Document) error {
+			info, err := os.Stat(doc.Path)
+			if os.IsNotExist(err) {
+				return fmt.Errorf("file does not exist: %s", doc.Path)
+			}
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return fmt.Errorf("not a file: %s", doc.Path)
+			}
+			if info.Size() == 0 {
+				return fmt.Errorf("file is empty: %s", doc.Path)
+			}
+
+			pages, err := extractor.Validate(doc.Path)
+			if err != nil {
+				if cat != nil {
+					cat.Forget(doc.Path)
+				}
+				return fmt.Errorf("%s is damaged: %w", doc.Path, err)
+			}
+			doc.PageCount = pages
+			if cat != nil && pages > 0 {
+				cat.RecordPages(doc.Path, pages)
+			}
+			return nil
+		},
+	}
+}
+
+// UnpackStep writes the documents a PDF portfolio, PST archive or email
+// message holds next to it (see extractor.Unpack), listing those the
+// extractor supports in doc.Embedded so the caller processes each as a
+// document of its own. The container itself goes on to be extracted like any
+// document. When cat is not nil and the container is catalogued, every
+// unpacked file is recorded in it, linked to the container.
+func UnpackStep(ext *extractor.Extractor, cat *catalog.Catalog) Step {
+	return Step{
+		Name: StepUnpack,
+		Run: func(doc *Document) error {
+			paths, err := ext.Unpack(doc.Path)
+			if err != nil {
+				return err
+			}
+			var catalogued bool
+			if cat != nil {
+				_, catalogued = cat.Get(doc.Path)
+			}
+			for _, path := range paths {
+				if ext.Supports(path) {
+					doc.Embedded = append(doc.Embedded, path)
+				}
+				if !catalogued {
+					continue
+				}
+				sum, err := corpus.FileChecksum(path)
+				if err != nil {
+					return fmt.Errorf("failed to checksum %s: %w", path, err)
+				}
+				if err := cat.RecordEmbedded(doc.Path, path, sum); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// CurateStep reads the curated metadata kept in the document's meta.yaml,
+// storing it in cat when cat is not nil. A document without one gets the
+// metadata its source provided (e.g. docket details), saved as its meta.yaml
+// for curators to build on. A malformed meta.yaml fails the document so the
+// mistake is noticed.
+func CurateStep(cat *catalog.Catalog, perms pathutil.Permissions) Step {
+	return Step{
+		Name: StepCurate,
+		Run: func(doc *Document) error {
+			m, err := meta.Load(doc.Path)
+			if err != nil {
+				return err
+			}
+			if m == nil && !doc.Item.Meta.IsEmpty() {
+				if _, err := os.Stat(meta.Path(doc.Path)); os.IsNotExist(err) {
+					if err := meta.Save(doc.Path, doc.Item.Meta, perms); err != nil {
+						return err
+					}
+					m = doc.Item.Meta
+				}
+			}
+			doc.Meta = m
+			if cat != nil {
+				cat.RecordMeta(doc.Path, m)
+			}
+			return nil
+		},
+	}
+}
+
+// ExtractStep extracts the document's text
+func ExtractStep(ext *extractor.Extractor) Step {
+	return Step{
+		Name: StepExtract,
+		Run: func(doc *Document) error {
+			pages, text, _, err := ext.ExtractTextStructured(doc.Path)
+			if err != nil {
+				return err
+			}
+			doc.Pages = pages
+			doc.Text = text
+			return nil
+		},
+	}
+}
+
+// Analyzer inspects a document after extraction
+type Analyzer func(doc *Document) error
+
+// AnalyzeStep runs analyzers over the extracted document in order, stopping
+// at the first failure
+func AnalyzeStep(analyzers ...Analyzer) Step {
+	return Step{
+		Name: StepAnalyze,
+		Run: func(doc *Document) error {
+			for _, analyze := range analyzers {
+				if err := analyze(doc); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// CoverSheetAnalyzer records the production details on the document's cover
+// sheet (producing party, date, confidentiality designation) in cat, so the
+// catalog can be filtered by them
+func CoverSheetAnalyzer(cat *catalog.Catalog) Analyzer {
+	return func(doc *Document) error {
+		if cat != nil {
+			cat.RecordCover(doc.Path, extractor.FindCoverSheet(doc.Pages))
+		}
+		return nil
+	}
+}
+
+// ReviewAnalyzer records in cat how many pages of the document need OCR and
+// how many redaction marks its text carries, so the catalog can be filtered
+// by them
+func ReviewAnalyzer(cat *catalog.Catalog) Analyzer {
+	return func(doc *Document) error {
+		if cat != nil {
+			cat.RecordReview(doc.Path, extractor.NeedsOCR(doc.Pages), extractor.CountRedactions(doc.Pages))
+		}
+		return nil
+	}
+}
+
+// EXIFAnalyzer records the EXIF metadata of image documents (camera,
+// timestamps, GPS position) in cat. Damaged EXIF is recorded as none rather
+// than failing the document.
+func EXIFAnalyzer(cat *catalog.Catalog) Analyzer {
+	return func(doc *Document) error {
+		if cat != nil && exif.IsImage(doc.Path) {
+			x, _ := exif.Read(doc.Path)
+			cat.RecordEXIF(doc.Path, x)
+		}
+		return nil
+	}
+}
+
+// ExportStep writes the extraction output to each sink, or next to the
+// document when no sinks are given. When cat is not nil, the first output
+// (the latest version, for write-once outputs) is recorded in it.
+func ExportStep(ext *extractor.Extractor, cat *catalog.Catalog, sinks ...sink.Sink) Step {
+	return Step{
+		Name: StepExport,
+		Run: func(doc *Document) error {
+			if len(sinks) == 0 {
+				outputPath, err := ext.SavePages(doc.Path, doc.Pages, doc.Text)
+				if err != nil {
+					return err
+				}
+				doc.OutputPath = outputPath
+				doc.Outputs = []string{outputPath}
+			} else {
+				out, err := ext.RenderPages(doc.Path, doc.Pages, doc.Text)
+				if err != nil {
+					return err
+				}
+				out.Fields = doc.fields()
+				doc.Outputs, err = writeAll(sinks, out)
+				if err != nil {
+					return err
+				}
+				doc.OutputPath = doc.Outputs[0]
+			}
+			if cat != nil {
+				cat.RecordOutput(doc.Path, doc.OutputPath)
+			}
+			return nil
+		},
+	}
+}
+
+// SplitStep detects logical sub-documents in a file that concatenates several
+// (e.g. a run of exhibits) and writes an extraction output for each to the
+// sinks (next to the document when none are given). When cat is not nil and
+// the document is in the tree, the parts are recorded in it.
+func SplitStep(ext *extractor.Extractor, cat *catalog.Catalog, sinks ...sink.Sink) Step {
+	return Step{
+		Name: StepSplit,
+		Run: func(doc *Document) error {
+			var segments []extractor.Segment
+			var outputs []string
+			var err error
+			if len(sinks) == 0 {
+				segments, outputs, err = ext.SavePageSegments(doc.Path, doc.Pages)
+				if err != nil {
+					return err
+				}
+			} else {
+				var rendered []*extractor.Output
+				segments, rendered, err = ext.RenderPageSegments(doc.Path, doc.Pages)
+				if err != nil {
+					return err
+				}
+				for _, out := range rendered {
+					out.Fields = doc.fields()
+					locations, err := writeAll(sinks, out)
+					if err != nil {
+						return err
+					}
+					outputs = append(outputs, locations[0])
+				}
+			}
+			doc.Parts = outputs
+			if cat == nil || len(outputs) == 0 {
+				return nil
+			}
+			if _, ok := cat.Get(doc.Path); !ok {
+				return nil // not a catalogued download
+			}
+			parts := make([]catalog.Part, len(outputs))
+			for i, output := range outputs {
+				parts[i] = catalog.Part{Output: output, StartPage: segments[i].StartPage, EndPage: segments[i].EndPage}
+			}
+			return cat.RecordParts(doc.Path, parts)
+		},
+	}
+}
+
+// writeAll writes out to every sink and returns where each wrote it
+func writeAll(sinks []sink.Sink, out *extractor.Output) ([]string, error) {
+	locations := make([]string, 0, len(sinks))
+	for _, s := range sinks {
+		location, err := s.Write(out)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
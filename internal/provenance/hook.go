@@ -0,0 +1,63 @@
+package provenance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/pipeline"
+)
+
+// AfterHook returns a pipeline hook recording each document of the tree at
+// root as its steps complete: a download entry once a new download is
+// verified, and an extract entry once its outputs are exported. Errors
+// recording an entry are passed to report; they never fail the document.
+func (l *Log) AfterHook(root string, report func(error)) pipeline.AfterHook {
+	return func(step string, doc *pipeline.Document, err error) {
+		if err != nil {
+			return
+		}
+		var entry Entry
+		switch {
+		case step == pipeline.StepVerify && doc.Downloaded && !doc.Unchanged:
+			entry = Entry{Event: EventDownload, URL: doc.Item.URL}
+		case step == pipeline.StepExport && len(doc.Outputs) > 0:
+			entry = Entry{Event: EventExtract, Outputs: make(map[string]string)}
+			for _, output := range doc.Outputs {
+				// Outputs sent elsewhere than to a file, such as to a search
+				// index, have nothing to checksum
+				if info, err := os.Stat(output); err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				sum, err := corpus.FileChecksum(output)
+				if err != nil {
+					report(fmt.Errorf("provenance: failed to checksum %s: %w", output, err))
+					return
+				}
+				entry.Outputs[relative(root, output)] = fmt.Sprintf("%x", sum)
+			}
+		default:
+			return
+		}
+		sum, err := corpus.FileChecksum(doc.Path)
+		if err != nil {
+			report(fmt.Errorf("provenance: failed to checksum %s: %w", doc.Path, err))
+			return
+		}
+		entry.Document, entry.SHA256 = relative(root, doc.Path), fmt.Sprintf("%x", sum)
+		if err := l.Record(entry); err != nil {
+			report(fmt.Errorf("provenance: %w", err))
+		}
+	}
+}
+
+// relative returns path relative to root with forward slashes, or as it is
+// if it lies outside root
+func relative(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
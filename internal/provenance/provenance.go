@@ -0,0 +1,277 @@
+// Package provenance keeps the provenance log of a documents tree: an
+// append-only record of every document downloaded into it and every
+// extraction output written from one, with their checksums. Each entry
+// carries the SHA-256 of the entry before it, so removing, reordering or
+// editing an entry breaks the chain, and can be signed with an ed25519 key
+// so the log cannot be rewritten as a whole without the key either.
+package provenance
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// FileName is the filename of the log at the root of the documents tree.
+// Being hidden, it is not scanned for documents.
+const FileName = ".provenance.jsonl"
+
+// Events recorded in the log
+const (
+	EventDownload = "download" // a document was downloaded and verified
+	EventExtract  = "extract"  // a document's extraction outputs were written
+)
+
+// Entry is one line of the log
+type Entry struct {
+	Seq      int               `json:"seq"` // position in the log, from 1
+	Time     time.Time         `json:"time"`
+	Event    string            `json:"event"`
+	Document string            `json:"document"`          // relative to the tree, with forward slashes
+	SHA256   string            `json:"sha256"`            // hex checksum of the document
+	URL      string            `json:"url,omitempty"`     // download: where it was fetched from
+	Outputs  map[string]string `json:"outputs,omitempty"` // extract: hex checksum of each output, by path relative to the tree
+	// Prev is the hex SHA-256 of the previous line of the log, "" for the
+	// first
+	Prev string `json:"prev"`
+	// Signature is the base64 ed25519 signature of the entry encoded
+	// without it, when the log is signed
+	Signature string `json:"sig,omitempty"`
+}
+
+// Log appends entries to the provenance log of a tree
+type Log struct {
+	path  string
+	key   ed25519.PrivateKey // nil when entries are not signed
+	perms pathutil.Permissions
+
+	mu   sync.Mutex
+	seq  int    // of the last entry
+	prev string // hash of the last line
+}
+
+// Path returns where the provenance log of the tree at root is kept
+func Path(root string) string {
+	return filepath.Join(root, FileName)
+}
+
+// Open opens the provenance log of the tree at root for appending, signing
+// new entries with key if it is not nil. A last line left incomplete by a
+// crash is removed; the rest of the log is not checked (see Verify).
+func Open(root string, key ed25519.PrivateKey, perms pathutil.Permissions) (*Log, error) {
+	l := &Log{path: Path(root), key: key, perms: perms}
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance log: %w", err)
+	}
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+	if len(complete) < len(data) {
+		if err := os.Truncate(l.path, int64(len(complete))); err != nil {
+			return nil, fmt.Errorf("failed to drop the incomplete last line of the provenance log: %w", err)
+		}
+	}
+	lines := bytes.Split(bytes.TrimSuffix(complete, []byte("\n")), []byte("\n"))
+	if last := lines[len(lines)-1]; len(last) > 0 {
+		var entry Entry
+		if err := json.Unmarshal(last, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse the last line of %s: %w", l.path, err)
+		}
+		l.seq, l.prev = entry.Seq, lineHash(last)
+	}
+	return l, nil
+}
+
+// Record appends an entry, filling in its sequence number, time, link to the
+// previous entry and signature. The line is synced to disk before Record
+// returns. A nil Log records nothing.
+func (l *Log) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.Seq, entry.Prev, entry.Signature = l.seq+1, l.prev, ""
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance entry: %w", err)
+	}
+	if l.key != nil {
+		entry.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, line))
+		if line, err = json.Marshal(entry); err != nil {
+			return fmt.Errorf("failed to encode provenance entry: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, l.perms.FileMode())
+	if err != nil {
+		return fmt.Errorf("failed to open provenance log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to append to provenance log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync provenance log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to append to provenance log: %w", err)
+	}
+	l.seq, l.prev = entry.Seq, lineHash(line)
+	return nil
+}
+
+// lineHash returns the hex SHA-256 of a line of the log, without its newline
+func lineHash(line []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(line))
+}
+
+// Summary is the outcome of verifying a log
+type Summary struct {
+	Entries int // entries checked
+	Signed  int // entries whose signature was checked
+}
+
+// Verify checks the provenance log of the tree at root from its first line:
+// that entries are numbered in order and each links to the hash of the line
+// before it, and, if pub is not nil, that every entry is signed by its key.
+// It returns the first problem found, naming its line. A tree without a log
+// verifies with no entries.
+func Verify(root string, pub ed25519.PublicKey) (Summary, error) {
+	var summary Summary
+	file, err := os.Open(Path(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return summary, nil
+	}
+	if err != nil {
+		return summary, fmt.Errorf("failed to read provenance log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var prev string
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return summary, nil // a torn or empty last line
+		}
+		if err != nil {
+			return summary, fmt.Errorf("failed to read provenance log: %w", err)
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return summary, fmt.Errorf("line %d: %w", number, err)
+		}
+		switch {
+		case entry.Seq != number:
+			return summary, fmt.Errorf("line %d: entry %d out of sequence (entries were removed or reordered)", number, entry.Seq)
+		case entry.Prev != prev:
+			return summary, fmt.Errorf("line %d: does not link to the line before it (the log was altered)", number)
+		}
+		if pub != nil {
+			if err := checkSignature(entry, pub); err != nil {
+				return summary, fmt.Errorf("line %d: %w", number, err)
+			}
+			summary.Signed++
+		}
+		summary.Entries++
+		prev = lineHash(line)
+	}
+}
+
+// checkSignature checks the signature of an entry against pub
+func checkSignature(entry Entry, pub ed25519.PublicKey) error {
+	if entry.Signature == "" {
+		return errors.New("entry is not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(entry.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	entry.Signature = ""
+	signed, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, signed, sig) {
+		return errors.New("signature does not match (the entry was altered or signed with another key)")
+	}
+	return nil
+}
+
+// LoadPrivateKey reads an ed25519 private key from a PEM file in PKCS #8
+// form, as written by "openssl genpkey -algorithm ed25519"
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM file, either a public
+// key as written by "openssl pkey -pubout" or the private key itself
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "PRIVATE KEY" {
+		private, err := LoadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return private.Public().(ed25519.PublicKey), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", path)
+	}
+	return public, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM key", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,162 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/pipeline"
+	"defornicate-epstein-files/internal/source"
+)
+
+// writeLog records a download and an extraction of one document in a new
+// tree, signing the entries with key if it is not nil
+func writeLog(t *testing.T, key ed25519.PrivateKey) string {
+	t.Helper()
+	root := t.TempDir()
+	doc := filepath.Join(root, "pdf", "a", "a.pdf")
+	output := filepath.Join(root, "pdf", "a", "a.extracted.json")
+	if err := os.MkdirAll(filepath.Dir(doc), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(doc, []byte("%PDF-1.4 a"), 0o644)
+	os.WriteFile(output, []byte(`{"content":{}}`), 0o644)
+
+	l, err := Open(root, key, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := l.AfterHook(root, func(err error) { t.Errorf("hook: %v", err) })
+	d := &pipeline.Document{Item: source.Item{URL: "https://example.org/a.pdf"}, Path: doc, Downloaded: true}
+	after(pipeline.StepVerify, d, nil)
+	d.Outputs = []string{output, "http://localhost:9200/docs/_doc/a"}
+	after(pipeline.StepExport, d, nil)
+	after(pipeline.StepExtract, d, nil) // not recorded
+	return root
+}
+
+func TestLog(t *testing.T) {
+	root := writeLog(t, nil)
+	data, err := os.ReadFile(Path(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"event":"download"`) || !strings.Contains(lines[0], `"url":"https://example.org/a.pdf"`) ||
+		!strings.Contains(lines[1], `"outputs":{"pdf/a/a.extracted.json":`) || !strings.Contains(lines[1], `"document":"pdf/a/a.pdf"`) {
+		t.Fatalf("log = %s", data)
+	}
+	if summary, err := Verify(root, nil); err != nil || summary.Entries != 2 {
+		t.Errorf("Verify() = %+v, %v, want 2 entries", summary, err)
+	}
+
+	// Reopening continues the chain
+	l, err := Open(root, nil, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(Entry{Event: EventExtract, Document: "pdf/a/a.pdf"}); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := Verify(root, nil); err != nil || summary.Entries != 3 {
+		t.Errorf("Verify() after reopening = %+v, %v, want 3 entries", summary, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	tests := []struct {
+		name   string
+		pub    ed25519.PublicKey // nil checks the chain alone
+		tamper func(lines [][]byte) [][]byte
+		want   string
+	}{
+		{"removed", nil, func(lines [][]byte) [][]byte { return lines[1:] }, "line 1: entry 2 out of sequence"},
+		{"edited", nil, func(lines [][]byte) [][]byte {
+			lines[0] = bytes.Replace(lines[0], []byte("example.org"), []byte("example.com"), 1)
+			return lines
+		}, "line 2: does not link"},
+		{"edited signed", pub, func(lines [][]byte) [][]byte {
+			lines[0] = bytes.Replace(lines[0], []byte("example.org"), []byte("example.com"), 1)
+			return lines
+		}, "line 1: signature does not match"},
+		{"edited last", pub, func(lines [][]byte) [][]byte {
+			lines[1] = bytes.Replace(lines[1], []byte("pdf/a/a.pdf"), []byte("pdf/b/b.pdf"), 1)
+			return lines
+		}, "line 2: signature does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeLog(t, key)
+			if summary, err := Verify(root, pub); err != nil || summary.Signed != 2 {
+				t.Fatalf("Verify() before tampering = %+v, %v", summary, err)
+			}
+			data, _ := os.ReadFile(Path(root))
+			lines := tt.tamper(bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")))
+			os.WriteFile(Path(root), append(bytes.Join(lines, []byte("\n")), '\n'), 0o644)
+			if _, err := Verify(root, tt.pub); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A log signed with another key, or not signed, fails with a key given
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := Verify(writeLog(t, key), other); err == nil {
+		t.Error("Verify() with another key: want an error")
+	}
+	if _, err := Verify(writeLog(t, nil), pub); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("Verify() of an unsigned log = %v, want not signed", err)
+	}
+}
+
+func TestOpenDropsTornLine(t *testing.T) {
+	root := writeLog(t, nil)
+	file, _ := os.OpenFile(Path(root), os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"seq":3,"ev`)
+	file.Close()
+	l, err := Open(root, nil, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(Entry{Event: EventExtract, Document: "pdf/a/a.pdf"}); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := Verify(root, nil); err != nil || summary.Entries != 3 {
+		t.Errorf("Verify() = %+v, %v, want 3 entries", summary, err)
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(rand.Reader)
+	dir := t.TempDir()
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	privatePath := filepath.Join(dir, "key.pem")
+	os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	publicPath := filepath.Join(dir, "key.pub.pem")
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644)
+
+	if loaded, err := LoadPrivateKey(privatePath); err != nil || !loaded.Equal(key) {
+		t.Errorf("LoadPrivateKey() = %v", err)
+	}
+	for _, path := range []string{publicPath, privatePath} {
+		if loaded, err := LoadPublicKey(path); err != nil || !loaded.Equal(pub) {
+			t.Errorf("LoadPublicKey(%s) = %v", filepath.Base(path), err)
+		}
+	}
+	if _, err := LoadPrivateKey(publicPath); err == nil {
+		t.Error("LoadPrivateKey() of a public key: want an error")
+	}
+}
@@ -0,0 +1,130 @@
+// Package scratch manages the temporary files of a run (spooled downloads,
+// images pulled out for OCR) in a directory of their own, so they can live on
+// a fast local disk and are removed together when the run ends. Directories
+// left behind by a run that crashed or was killed are swept up by the next.
+package scratch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// Prefix starts the name of every run's scratch directory
+const Prefix = "defornicator-"
+
+// staleAge is how old an unlocked scratch directory must be before Sweep
+// removes it. A run creates its directory before locking it, so a young one
+// without a lock may still be claimed.
+const staleAge = time.Hour
+
+// Dir is a run's scratch directory. A nil *Dir uses the system temporary
+// directory directly, without cleanup.
+type Dir struct {
+	path    string
+	lock    *lock.Lock
+	removed sync.Once
+	err     error // from removing the directory
+}
+
+// New creates a scratch directory under parent (the system temporary
+// directory, i.e. $TMPDIR, if empty), first removing any left there by runs
+// that are no longer alive. The directory is locked for as long as the run
+// holds it.
+func New(parent string, perms pathutil.Permissions) (*Dir, error) {
+	if parent == "" {
+		parent = os.TempDir()
+	}
+	if err := perms.MkdirAll(parent); err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	Sweep(parent, perms)
+
+	path, err := os.MkdirTemp(parent, Prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	held, err := lock.Acquire(path, perms)
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("failed to lock scratch directory: %w", err)
+	}
+	return &Dir{path: path, lock: held}, nil
+}
+
+// Path returns the directory's path
+func (d *Dir) Path() string {
+	if d == nil {
+		return os.TempDir()
+	}
+	return d.path
+}
+
+// CreateTemp creates a temporary file in the directory, as os.CreateTemp
+func (d *Dir) CreateTemp(pattern string) (*os.File, error) {
+	if d == nil {
+		return os.CreateTemp("", Prefix+pattern)
+	}
+	return os.CreateTemp(d.path, pattern)
+}
+
+// MkdirTemp creates a temporary directory in the directory, as os.MkdirTemp
+func (d *Dir) MkdirTemp(pattern string) (string, error) {
+	if d == nil {
+		return os.MkdirTemp("", Prefix+pattern)
+	}
+	return os.MkdirTemp(d.path, pattern)
+}
+
+// Remove deletes the directory and everything left in it. It is safe to call
+// more than once, and from several goroutines.
+func (d *Dir) Remove() error {
+	if d == nil {
+		return nil
+	}
+	d.removed.Do(func() {
+		d.lock.Release()
+		if err := os.RemoveAll(d.path); err != nil {
+			d.err = fmt.Errorf("failed to remove scratch directory: %w", err)
+		}
+	})
+	return d.err
+}
+
+// Sweep removes the scratch directories under parent whose runs have ended
+// without removing them, returning their paths. Directories still locked by a
+// live run are left alone.
+func Sweep(parent string, perms pathutil.Permissions) []string {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return nil
+	}
+	var removed []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), Prefix) {
+			continue
+		}
+		path := filepath.Join(parent, entry.Name())
+		if _, err := os.Stat(filepath.Join(path, lock.FileName)); err != nil {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < staleAge {
+				continue // possibly being claimed right now
+			}
+		}
+		held, err := lock.Acquire(path, perms)
+		if err != nil {
+			continue // still held by a live run
+		}
+		held.Release()
+		if os.RemoveAll(path) == nil {
+			removed = append(removed, path)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,84 @@
+package scratch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+func TestDirRemove(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "scratch")
+	dir, err := New(parent, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if filepath.Dir(dir.Path()) != parent {
+		t.Fatalf("Path() = %s, want a directory under %s", dir.Path(), parent)
+	}
+	file, err := dir.CreateTemp("*.part")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	file.Close()
+	if filepath.Dir(file.Name()) != dir.Path() {
+		t.Errorf("CreateTemp() made %s outside the scratch directory", file.Name())
+	}
+
+	if err := dir.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := dir.Remove(); err != nil {
+		t.Errorf("second Remove() error = %v", err)
+	}
+	if _, err := os.Stat(dir.Path()); !os.IsNotExist(err) {
+		t.Errorf("scratch directory still exists after Remove()")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	parent := t.TempDir()
+	perms := pathutil.Permissions{}
+	live, err := New(parent, perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Remove()
+
+	// A run that died: its lock file is left, but no one holds it
+	dead := filepath.Join(parent, Prefix+"dead")
+	held, err := lock.Acquire(dead, perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held.Release()
+	os.WriteFile(filepath.Join(dead, "x.part"), []byte("partial"), 0644)
+
+	// A run that died before locking its directory, long ago
+	abandoned := filepath.Join(parent, Prefix+"abandoned")
+	os.Mkdir(abandoned, 0755)
+	old := time.Now().Add(-2 * staleAge)
+	os.Chtimes(abandoned, old, old)
+
+	// A run that is just starting
+	starting := filepath.Join(parent, Prefix+"starting")
+	os.Mkdir(starting, 0755)
+	// Someone else's files
+	other := filepath.Join(parent, "other")
+	os.Mkdir(other, 0755)
+
+	removed := Sweep(parent, perms)
+	slices.Sort(removed)
+	if want := []string{abandoned, dead}; !slices.Equal(removed, want) {
+		t.Errorf("Sweep() removed %v, want %v", removed, want)
+	}
+	for _, path := range []string{starting, live.Path(), other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Sweep() removed %s", path)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyzer normalizes words before they are compared, applied alike to page
+// text and query terms. The zero Analyzer matches words exactly.
+type Analyzer struct {
+	Lowercase bool // "DUBIN" matches "Dubin"
+	Fold      bool // fold Latin diacritics to ASCII: "Médecin" matches "Medecin"
+	Stem      bool // light English stemming: "flights" and "flight" match
+}
+
+// Normalize returns the form of word the analyzer compares
+func (a Analyzer) Normalize(word string) string {
+	if a.Fold {
+		word = foldASCII(word)
+	}
+	if a.Lowercase {
+		word = strings.ToLower(word)
+	}
+	if a.Stem {
+		word = stem(word)
+	}
+	return word
+}
+
+// terms tokenizes text and normalizes every word
+func (a Analyzer) terms(text string) []string {
+	words := tokenize(text)
+	for i, word := range words {
+		words[i] = a.Normalize(word)
+	}
+	return words
+}
+
+// foldTable maps Latin letters with diacritics (and ligatures) to ASCII
+var foldTable = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Ā': "A", 'Ă': "A", 'Ą': "A",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'Æ': "AE", 'æ': "ae", 'Œ': "OE", 'œ': "oe", 'ß': "ss",
+	'Ç': "C", 'Ć': "C", 'Č': "C", 'ç': "c", 'ć': "c", 'č': "c",
+	'Ď': "D", 'Đ': "D", 'Ð': "D", 'ď': "d", 'đ': "d", 'ð': "d",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E", 'Ē': "E", 'Ė': "E", 'Ę': "E", 'Ě': "E",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'Ğ': "G", 'ğ': "g",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ī': "I", 'İ': "I", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'Ł': "L", 'Ľ': "L", 'ł': "l", 'ľ': "l",
+	'Ñ': "N", 'Ń': "N", 'Ň': "N", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ō': "O", 'Ő': "O",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'Ř': "R", 'ř': "r",
+	'Ś': "S", 'Ş': "S", 'Š': "S", 'ś': "s", 'ş': "s", 'š': "s",
+	'Ť': "T", 'Ţ': "T", 'ť': "t", 'ţ': "t", 'Þ': "TH", 'þ': "th",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ū': "U", 'Ů': "U", 'Ű': "U",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'Ý': "Y", 'Ÿ': "Y", 'ý': "y", 'ÿ': "y",
+	'Ź': "Z", 'Ż': "Z", 'Ž': "Z", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// foldASCII replaces Latin letters with diacritics by their ASCII base
+// letters and drops combining marks (from decomposed text)
+func foldASCII(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		if r < 0x80 {
+			b.WriteRune(r)
+		} else if folded, ok := foldTable[r]; ok {
+			b.WriteString(folded)
+		} else if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// minStem is the shortest stem a suffix is stripped down to, so names like
+// "Ted" or "Ross" survive stemming
+const minStem = 3
+
+// stem strips common English inflections (plurals, -ed, -ing). It is a light
+// stemmer: it only conflates forms of the same word, at the cost of missing
+// irregular ones.
+func stem(word string) string {
+	lower := strings.ToLower(word)
+	strip := func(suffix, replacement string) (string, bool) {
+		if !strings.HasSuffix(lower, suffix) || len(word)-len(suffix) < minStem {
+			return "", false
+		}
+		return word[:len(word)-len(suffix)] + replacement, true
+	}
+	switch {
+	case strings.HasSuffix(lower, "ies"):
+		if s, ok := strip("ies", "y"); ok {
+			return s
+		}
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "shes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "xes"):
+		if s, ok := strip("es", ""); ok {
+			return s
+		}
+	case strings.HasSuffix(lower, "ss"), strings.HasSuffix(lower, "us"), strings.HasSuffix(lower, "is"):
+		return word
+	case strings.HasSuffix(lower, "s"):
+		if s, ok := strip("s", ""); ok {
+			return s
+		}
+	case strings.HasSuffix(lower, "ing"):
+		if s, ok := strip("ing", ""); ok {
+			return undouble(s)
+		}
+	case strings.HasSuffix(lower, "ed"):
+		if s, ok := strip("ed", ""); ok {
+			return undouble(s)
+		}
+	}
+	return word
+}
+
+// undouble removes a doubled final consonant left by stripping a suffix
+// ("stopped" → "stopp" → "stop"), except l, s and z ("billed", "passed")
+func undouble(s string) string {
+	n := len(s)
+	if n >= 2 && s[n-1] == s[n-2] && !strings.ContainsRune("aeioulsz", rune(s[n-1]|0x20)) {
+		return s[:n-1]
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// January 5, 2005 / Jan. 5, 2005 / Sept 5 2005
+	monthDayYearRe = regexp.MustCompile(`(?i)\b(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`)
+	// 5 January 2005
+	dayMonthYearRe = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(jan|feb|mar|apr|may|jun|jul|aug|sep|sept|oct|nov|dec)[a-z]*\.?,?\s+(\d{4})\b`)
+	// 01/05/2005 or 1-5-2005 (US month/day order)
+	numericDateRe = regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})[/-](\d{4})\b`)
+	// 2005-01-05
+	isoDateRe = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+)
+
+var monthNumbers = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "sept": time.September, "oct": time.October,
+	"nov": time.November, "dec": time.December,
+}
+
+// FindDates returns the calendar dates written in text, in the common US
+// legal formats ("January 5, 2005", "5 January 2005", "01/05/2005",
+// "2005-01-05"). Impossible dates are ignored.
+func FindDates(text string) []time.Time {
+	dates := []time.Time{}
+	add := func(year, month, day string) {
+		y, _ := strconv.Atoi(year)
+		m, _ := strconv.Atoi(month)
+		d, _ := strconv.Atoi(day)
+		if date, ok := validDate(y, time.Month(m), d); ok {
+			dates = append(dates, date)
+		}
+	}
+	for _, m := range monthDayYearRe.FindAllStringSubmatch(text, -1) {
+		add(m[3], strconv.Itoa(int(monthNumbers[strings.ToLower(m[1])])), m[2])
+	}
+	for _, m := range dayMonthYearRe.FindAllStringSubmatch(text, -1) {
+		add(m[3], strconv.Itoa(int(monthNumbers[strings.ToLower(m[2])])), m[1])
+	}
+	for _, m := range numericDateRe.FindAllStringSubmatch(text, -1) {
+		add(m[3], m[1], m[2])
+	}
+	for _, m := range isoDateRe.FindAllStringSubmatch(text, -1) {
+		add(m[1], m[2], m[3])
+	}
+	return dates
+}
+
+// validDate builds a date, rejecting out-of-range parts (time.Date would
+// silently normalize February 30 to March 2)
+func validDate(year int, month time.Month, day int) (time.Time, bool) {
+	if year < 1900 || year > 2100 || month < time.January || month > time.December || day < 1 {
+		return time.Time{}, false
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != month {
+		return time.Time{}, false
+	}
+	return date, true
+}
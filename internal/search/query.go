@@ -0,0 +1,558 @@
+// Package search finds pages in the extraction outputs of a documents tree
+// that match a query. Queries support AND/OR/NOT (and parentheses), exact
+// phrases in double quotes, and field filters:
+//
+//	filename:EFTA0001*         document filename glob
+//	page:3  page:10-20         page number or range
+//	date:2005..2008-06         pages mentioning a date in the range
+//	class:deposition           document class recorded in its meta.yaml
+//	meta:"flight log"          words in the document's curated meta.yaml
+//	tag:reviewed               tag listed in the document's meta.yaml
+//	signed:notary              pages with a signature of a kind (electronic,
+//	                           block, notary) or of any kind (signed:any)
+//
+// Terms next to each other are ANDed; a leading "-" is shorthand for NOT.
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// Query is a parsed search query
+type Query struct {
+	root     node
+	analyzer Analyzer
+}
+
+// Page is one page of an extracted document, as seen by a query
+type Page struct {
+	Document   string // path of the document, relative to the tree
+	Number     int
+	Text       string
+	Meta       *meta.Meta        // curated metadata of the document, if any
+	Signatures []string          // kinds of signature found on the page
+	Cover      *legal.CoverSheet // cover sheet details of the document, if any
+
+	tokens      []string    // lazily tokenized and analyzed Text
+	metaTokens  []string    // lazily tokenized and analyzed Meta text
+	partyTokens []string    // lazily tokenized and analyzed producing party
+	dates       []time.Time // lazily extracted dates
+}
+
+// node is an element of the query syntax tree
+type node interface {
+	match(p *Page) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ operand node }
+
+// termNode matches a single word; phraseNode a run of consecutive words
+type termNode struct{ word string }
+type phraseNode struct{ words []string }
+
+type filenameNode struct{ glob string }
+type pageNode struct{ from, to int }
+type dateNode struct{ from, to time.Time } // inclusive; zero means unbounded
+type metaNode struct{ words []string }
+type tagNode struct{ tag string }
+type classNode struct{ class string }
+type signedNode struct{ kind string } // empty matches any kind
+type partyNode struct{ words []string }
+type designationNode struct{ designation string } // empty matches any designation
+
+func (n andNode) match(p *Page) bool { return n.left.match(p) && n.right.match(p) }
+func (n orNode) match(p *Page) bool  { return n.left.match(p) || n.right.match(p) }
+func (n notNode) match(p *Page) bool { return !n.operand.match(p) }
+
+func (n termNode) match(p *Page) bool {
+	for _, token := range p.wordTokens() {
+		if token == n.word {
+			return true
+		}
+	}
+	return false
+}
+
+func (n phraseNode) match(p *Page) bool { return containsRun(p.wordTokens(), n.words) }
+
+// containsRun reports whether words occur consecutively in tokens
+func containsRun(tokens, words []string) bool {
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		matched := true
+		for j, word := range words {
+			if tokens[i+j] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (n filenameNode) match(p *Page) bool {
+	ok, _ := filepath.Match(n.glob, filepath.Base(p.Document))
+	return ok
+}
+
+func (n pageNode) match(p *Page) bool { return p.Number >= n.from && p.Number <= n.to }
+
+func (n dateNode) match(p *Page) bool {
+	for _, date := range p.mentionedDates() {
+		if (n.from.IsZero() || !date.Before(n.from)) && (n.to.IsZero() || !date.After(n.to)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n metaNode) match(p *Page) bool { return containsRun(p.metaTokens, n.words) }
+
+func (n tagNode) match(p *Page) bool { return p.Meta.HasTag(n.tag) }
+
+func (n classNode) match(p *Page) bool { return p.Meta.HasClass(n.class) }
+
+func (n signedNode) match(p *Page) bool {
+	if n.kind == "" {
+		return len(p.Signatures) > 0
+	}
+	return slices.Contains(p.Signatures, n.kind)
+}
+
+func (n partyNode) match(p *Page) bool { return containsRun(p.partyTokens, n.words) }
+
+func (n designationNode) match(p *Page) bool {
+	if p.Cover == nil || p.Cover.Designation == "" {
+		return false
+	}
+	return n.designation == "" || p.Cover.Designation == n.designation
+}
+
+// Match reports whether the page matches the query
+func (q *Query) Match(p *Page) bool {
+	if p.tokens == nil {
+		p.tokens = q.analyzer.terms(p.Text)
+	}
+	if p.metaTokens == nil && p.Meta != nil {
+		p.metaTokens = q.analyzer.terms(p.Meta.Text())
+	}
+	if p.partyTokens == nil && p.Cover != nil {
+		p.partyTokens = q.analyzer.terms(p.Cover.ProducingParty)
+	}
+	return q.root.match(p)
+}
+
+// Analyzer returns the analyzer the query's terms were normalized with
+func (q *Query) Analyzer() Analyzer {
+	return q.analyzer
+}
+
+// Terms returns the words and phrases the query looks for (ignoring negated
+// ones), for highlighting matches in snippets
+func (q *Query) Terms() []string {
+	var terms []string
+	var walk func(n node, negated bool)
+	walk = func(n node, negated bool) {
+		switch n := n.(type) {
+		case andNode:
+			walk(n.left, negated)
+			walk(n.right, negated)
+		case orNode:
+			walk(n.left, negated)
+			walk(n.right, negated)
+		case notNode:
+			walk(n.operand, !negated)
+		case termNode:
+			if !negated {
+				terms = append(terms, n.word)
+			}
+		case phraseNode:
+			if !negated {
+				terms = append(terms, strings.Join(n.words, " "))
+			}
+		}
+	}
+	walk(q.root, false)
+	return terms
+}
+
+func (p *Page) wordTokens() []string {
+	if p.tokens == nil {
+		p.tokens = tokenize(p.Text)
+	}
+	return p.tokens
+}
+
+func (p *Page) mentionedDates() []time.Time {
+	if p.dates == nil {
+		p.dates = FindDates(p.Text)
+	}
+	return p.dates
+}
+
+// tokenize splits text into words at anything that is not a letter or digit
+func tokenize(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Parse parses a query string whose words match page text exactly
+func Parse(query string) (*Query, error) {
+	return ParseWithAnalyzer(query, Analyzer{})
+}
+
+// ParseWithAnalyzer parses a query string whose words, like the text of the
+// pages it is matched against, are normalized by analyzer
+func ParseWithAnalyzer(query string, analyzer Analyzer) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &parser{tokens: tokens, analyzer: analyzer}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Query{root: root, analyzer: analyzer}, nil
+}
+
+// token kinds produced by lex
+const (
+	tokenWord = iota
+	tokenPhrase
+	tokenField
+	tokenLParen
+	tokenRParen
+	tokenMinus
+)
+
+type token struct {
+	kind  int
+	text  string // word, phrase text, or field value
+	field string // for tokenField
+}
+
+// lex splits a query into tokens
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenMinus, text: "-"})
+			i++
+		case r == '"':
+			text, next, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, text: text})
+			i = next
+		default:
+			start := i
+			for i < len(runes) && !strings.ContainsRune(" \t\n()\"", runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			if field, value, ok := strings.Cut(word, ":"); ok && isField(field) {
+				// A quoted value may follow the colon: filename:"a b"
+				if value == "" && i < len(runes) && runes[i] == '"' {
+					text, next, err := lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					value, i = text, next
+				}
+				tokens = append(tokens, token{kind: tokenField, field: strings.ToLower(field), text: value})
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: word})
+		}
+	}
+	return tokens, nil
+}
+
+// lexQuoted reads a double-quoted string starting at runes[start] and returns
+// its contents and the index after the closing quote
+func lexQuoted(runes []rune, start int) (string, int, error) {
+	end := start + 1
+	for end < len(runes) && runes[end] != '"' {
+		end++
+	}
+	if end == len(runes) {
+		return "", 0, fmt.Errorf("unterminated quote")
+	}
+	return string(runes[start+1 : end]), end + 1, nil
+}
+
+// isField reports whether name is a supported field filter
+func isField(name string) bool {
+	switch strings.ToLower(name) {
+	case "filename", "page", "date", "class", "meta", "tag", "signed", "party", "designation":
+		return true
+	}
+	return false
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	analyzer Analyzer
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// isOperator reports whether t is the bare word op (operators are upper case
+// so "and"/"or"/"not" can still be searched for)
+func isOperator(t *token, op string) bool {
+	return t != nil && t.kind == tokenWord && t.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isOperator(p.peek(), "OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokenRParen || isOperator(t, "OR") {
+			return left, nil
+		}
+		if isOperator(t, "AND") {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if isOperator(t, "NOT") || (t != nil && t.kind == tokenMinus) {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	p.pos++
+	switch t.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokenRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return inner, nil
+	case tokenRParen:
+		return nil, fmt.Errorf("unexpected \")\"")
+	case tokenPhrase:
+		words := p.analyzer.terms(t.text)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("empty phrase")
+		}
+		return phraseNode{words}, nil
+	case tokenField:
+		return parseField(t.field, t.text, p.analyzer)
+	default:
+		if isOperator(t, "AND") || isOperator(t, "OR") {
+			return nil, fmt.Errorf("%s is missing an operand", t.text)
+		}
+		words := p.analyzer.terms(t.text)
+		switch len(words) {
+		case 0:
+			return nil, fmt.Errorf("%q contains no searchable characters", t.text)
+		case 1:
+			return termNode{words[0]}, nil
+		default:
+			// e.g. "1:19-cv-03377" is searched as the phrase of its parts
+			return phraseNode{words}, nil
+		}
+	}
+}
+
+// parseField builds the filter for field:value, normalizing the words of
+// meta: like page text
+func parseField(field, value string, analyzer Analyzer) (node, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s: needs a value", field)
+	}
+	switch field {
+	case "filename":
+		if _, err := filepath.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("filename: invalid glob %q: %w", value, err)
+		}
+		if !strings.ContainsAny(value, "*?[") {
+			value = "*" + value + "*" // plain text matches anywhere in the name
+		}
+		return filenameNode{value}, nil
+	case "page":
+		return parsePageRange(value)
+	case "date":
+		return parseDateRange(value)
+	case "class":
+		return classNode{value}, nil
+	case "meta":
+		words := analyzer.terms(value)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("meta: %q contains no searchable characters", value)
+		}
+		return metaNode{words}, nil
+	case "tag":
+		return tagNode{value}, nil
+	case "signed":
+		switch kind := strings.ToLower(value); kind {
+		case "any":
+			return signedNode{}, nil
+		case legal.SignatureElectronic, legal.SignatureBlock, legal.SignatureNotary:
+			return signedNode{kind}, nil
+		}
+		return nil, fmt.Errorf("signed: unknown kind %q (expected any, %s, %s or %s)", value,
+			legal.SignatureElectronic, legal.SignatureBlock, legal.SignatureNotary)
+	case "party":
+		words := analyzer.terms(value)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("party: %q contains no searchable characters", value)
+		}
+		return partyNode{words}, nil
+	case "designation":
+		if strings.EqualFold(value, "any") {
+			return designationNode{}, nil
+		}
+		return designationNode{legal.CanonicalDesignation(value)}, nil
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+// parsePageRange parses "N" or "N-M"
+func parsePageRange(value string) (node, error) {
+	fromStr, toStr, isRange := strings.Cut(value, "-")
+	from, err := strconv.Atoi(fromStr)
+	if err != nil || from < 1 {
+		return nil, fmt.Errorf("page: invalid page number %q", value)
+	}
+	to := from
+	if isRange {
+		to, err = strconv.Atoi(toStr)
+		if err != nil || to < from {
+			return nil, fmt.Errorf("page: invalid page range %q", value)
+		}
+	}
+	return pageNode{from, to}, nil
+}
+
+// parseDateRange parses "FROM..TO" (either end optional) or a single date,
+// where each date is YYYY, YYYY-MM or YYYY-MM-DD
+func parseDateRange(value string) (node, error) {
+	fromStr, toStr, isRange := strings.Cut(value, "..")
+	if !isRange {
+		toStr = fromStr
+	}
+	var n dateNode
+	var err error
+	if fromStr != "" {
+		if n.from, _, err = parseQueryDate(fromStr); err != nil {
+			return nil, err
+		}
+	}
+	if toStr != "" {
+		var end time.Time
+		if _, end, err = parseQueryDate(toStr); err != nil {
+			return nil, err
+		}
+		n.to = end
+	}
+	if n.from.IsZero() && n.to.IsZero() {
+		return nil, fmt.Errorf("date: needs at least one end of the range")
+	}
+	if !n.from.IsZero() && !n.to.IsZero() && n.to.Before(n.from) {
+		return nil, fmt.Errorf("date: range %q ends before it starts", value)
+	}
+	return n, nil
+}
+
+// parseQueryDate parses a possibly partial date and returns the first and
+// last day it covers
+func parseQueryDate(s string) (time.Time, time.Time, error) {
+	for _, layout := range []struct {
+		format string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if start, err := time.Parse(layout.format, s); err == nil {
+			end := start.AddDate(layout.years, layout.months, layout.days).AddDate(0, 0, -1)
+			return start, end, nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("date: invalid date %q (expected YYYY, YYYY-MM or YYYY-MM-DD)", s)
+}
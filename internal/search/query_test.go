@@ -0,0 +1,235 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+)
+
+func TestQueryMatch(t *testing.T) {
+	page := func() *Page {
+		return &Page{
+			Document: "pdf/EFTA00010724/EFTA00010724.pdf",
+			Number:   12,
+			Text:     "Deposition of Jane Doe taken on March 3, 2005\nbefore Judge Marra, Case 1:19-cv-03377",
+		}
+	}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"Deposition", true},
+		{"deposition", false}, // matching is case-sensitive
+		{"Jane Doe", true},
+		{"Jane AND Smith", false},
+		{"Jane OR Smith", true},
+		{"Jane NOT Marra", false},
+		{"Jane -Smith", true},
+		{`"Jane Doe"`, true},
+		{`"Doe Jane"`, false},
+		{"(Smith OR Doe) AND Marra", true},
+		{"1:19-cv-03377", true},
+		{"filename:EFTA0001*", true},
+		{"filename:10724 Doe", true},
+		{"filename:EFTA9*", false},
+		{"page:12", true},
+		{"page:1-11", false},
+		{"page:10-20 Jane", true},
+		{"date:2005", true},
+		{"date:2005-03-01..2005-03-31", true},
+		{"date:..2004", false},
+		{"date:2005-04..", false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.query, err)
+			continue
+		}
+		if got := q.Match(page()); got != tt.want {
+			t.Errorf("Parse(%q).Match() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatchCurated(t *testing.T) {
+	curated := &meta.Meta{
+		Title:  "Palm Beach police report",
+		Notes:  "Duplicate of EFTA00010720",
+		Tags:   []string{"Reviewed"},
+		Fields: map[string]any{"custodian": "FBI", "class": "Police report"},
+	}
+	tests := []struct {
+		query string
+		meta  *meta.Meta
+		want  bool
+	}{
+		{`meta:"police report"`, curated, true},
+		{"meta:FBI", curated, true},
+		{"meta:Deposition", curated, false}, // page text is not curated text
+		{"tag:reviewed", curated, true},
+		{"tag:court", curated, false},
+		{`class:"police report"`, curated, true},
+		{"class:deposition", curated, false},
+		{"meta:FBI", nil, false},
+		{"-tag:reviewed", nil, true},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.query, err)
+			continue
+		}
+		p := &Page{Text: "Deposition of Jane Doe", Meta: tt.meta}
+		if got := q.Match(p); got != tt.want {
+			t.Errorf("Parse(%q).Match() = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatchSigned(t *testing.T) {
+	p := &Page{Text: "Sworn to before me", Signatures: []string{"notary"}}
+	for query, want := range map[string]bool{
+		"signed:any":        true,
+		"signed:Notary":     true,
+		"signed:electronic": false,
+		"-signed:block":     true,
+	} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", query, err)
+			continue
+		}
+		if got := q.Match(p); got != want {
+			t.Errorf("Parse(%q).Match() = %v, want %v", query, got, want)
+		}
+	}
+	if _, err := Parse("signed:wet"); err == nil {
+		t.Error("Parse(signed:wet) succeeded, want an unknown kind error")
+	}
+}
+
+func TestQueryMatchCoverSheet(t *testing.T) {
+	p := &Page{Text: "Exhibit list", Cover: &legal.CoverSheet{
+		ProducingParty: "Federal Bureau of Investigation",
+		Designation:    legal.DesignationHighlyConfidential,
+	}}
+	for query, want := range map[string]bool{
+		`party:"Bureau of Investigation"`:       true,
+		"party:FBI":                             false,
+		`designation:"Highly Confidential"`:     true,
+		"designation:confidential":              false,
+		"designation:any":                       true,
+		"party:Federal designation:any Exhibit": true,
+	} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", query, err)
+			continue
+		}
+		if got := q.Match(p); got != want {
+			t.Errorf("Parse(%q).Match() = %v, want %v", query, got, want)
+		}
+	}
+	if q, _ := Parse("designation:any"); q.Match(&Page{Text: "Exhibit list"}) {
+		t.Error("designation:any matched a page without a cover sheet")
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	page := func() *Page {
+		return &Page{Text: "MR. DUBIN: The flights to Paris were booked by Sébastien's office."}
+	}
+	tests := []struct {
+		query    string
+		analyzer Analyzer
+		want     bool
+	}{
+		{"Dubin", Analyzer{}, false},
+		{"Dubin", Analyzer{Lowercase: true}, true},
+		{"Sebastien", Analyzer{Lowercase: true}, false},
+		{"sebastien", Analyzer{Lowercase: true, Fold: true}, true},
+		{"flight", Analyzer{}, false},
+		{"flight", Analyzer{Stem: true}, true},
+		{`"flight to Paris"`, Analyzer{Stem: true}, true},
+		{"book", Analyzer{Lowercase: true, Stem: true}, true},
+	}
+	for _, tt := range tests {
+		q, err := ParseWithAnalyzer(tt.query, tt.analyzer)
+		if err != nil {
+			t.Errorf("ParseWithAnalyzer(%q) error = %v", tt.query, err)
+			continue
+		}
+		if got := q.Match(page()); got != tt.want {
+			t.Errorf("ParseWithAnalyzer(%q, %+v).Match() = %v, want %v", tt.query, tt.analyzer, got, tt.want)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, want := range map[string]string{
+		"flights":   "flight",
+		"parties":   "party",
+		"witnesses": "witness",
+		"stopped":   "stop",
+		"billed":    "bill",
+		"booking":   "book",
+		"Ross":      "Ross",
+		"Ted":       "Ted",
+		"bus":       "bus",
+	} {
+		if got := stem(word); got != want {
+			t.Errorf("stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestSnippetUsesAnalyzer(t *testing.T) {
+	got := snippet("THE WITNESS: I never met MR. DUBIN.", []string{"dubin"}, Analyzer{Lowercase: true})
+	if got != "THE WITNESS: I never met MR. DUBIN." {
+		t.Errorf("snippet() = %q", got)
+	}
+	if start, end := firstOccurrence("a b DUBIN c", []string{"dubin"}, Analyzer{Lowercase: true}); start != 4 || end != 9 {
+		t.Errorf("firstOccurrence() = %d, %d, want 4, 9", start, end)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"(Jane",
+		"Jane)",
+		`"unterminated`,
+		"Jane OR",
+		"page:x",
+		"page:5-2",
+		"date:2005-13",
+		"date:2006..2005",
+		"meta:--",
+	} {
+		if _, err := Parse(query); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", query)
+		}
+	}
+}
+
+func TestFindDates(t *testing.T) {
+	text := "Sent Jan. 5, 2005; received 7 February 2005, logged 03/09/2005 and 2005-04-01. Not a date: 02/30/2005"
+	want := []time.Time{
+		time.Date(2005, time.January, 5, 0, 0, 0, 0, time.UTC),
+		time.Date(2005, time.February, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2005, time.March, 9, 0, 0, 0, 0, time.UTC),
+		time.Date(2005, time.April, 1, 0, 0, 0, 0, time.UTC),
+	}
+	got := FindDates(text)
+	if len(got) != len(want) {
+		t.Fatalf("FindDates() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("FindDates()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report formats, chosen by the extension of the report file
+const (
+	ReportCSV      = "csv"
+	ReportMarkdown = "markdown"
+)
+
+// ReportHit is a hit with a link to its page
+type ReportHit struct {
+	Hit
+	Link string // the document's source URL, or its path, with #page=N for PDFs
+}
+
+// Report is the hits of a search written up for sharing
+type Report struct {
+	Query     string
+	Root      string // documents tree searched
+	Generated time.Time
+	Searched  int // documents searched
+	Hits      []ReportHit
+}
+
+// ReportFormat returns the format of a report written to path: CSV for
+// .csv files, Markdown for .md and .markdown
+func ReportFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReportCSV, nil
+	case ".md", ".markdown":
+		return ReportMarkdown, nil
+	}
+	return "", fmt.Errorf("can't tell the report format of %s (expected a .md or .csv file)", path)
+}
+
+// reportHeader names the columns written by WriteReportCSV
+var reportHeader = []string{"document", "page", "snippet", "link"}
+
+// WriteReportCSV writes the hits of a report as a CSV table, one row per
+// hit, for spreadsheets
+func WriteReportCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportHeader); err != nil {
+		return err
+	}
+	for _, hit := range r.Hits {
+		if err := cw.Write([]string{hit.Document, strconv.Itoa(hit.Page), hit.Snippet, hit.Link}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteReportMarkdown writes a report as Markdown for memos: the query and
+// what was searched, then the hits grouped by document, each page linked
+func WriteReportMarkdown(w io.Writer, r *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Search: %s\n\n", markdownEscape(r.Query))
+	documents := 0
+	for i, hit := range r.Hits {
+		if i == 0 || hit.Document != r.Hits[i-1].Document {
+			documents++
+		}
+	}
+	fmt.Fprintf(&b, "%d matching page(s) in %d of %d document(s) searched under `%s`, %s.\n",
+		len(r.Hits), documents, r.Searched, r.Root, r.Generated.UTC().Format("2006-01-02 15:04 UTC"))
+
+	// Hits come in document order, so each document's pages are adjacent
+	for i, hit := range r.Hits {
+		if i == 0 || hit.Document != r.Hits[i-1].Document {
+			fmt.Fprintf(&b, "\n## %s\n\n", markdownEscape(hit.Document))
+		}
+		page := fmt.Sprintf("Page %d", hit.Page)
+		if hit.Link != "" {
+			page = fmt.Sprintf("[%s](%s)", page, markdownLink(hit.Link))
+		}
+		fmt.Fprintf(&b, "- **%s**: %s\n", page, markdownEscape(hit.Snippet))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownSpecial lists the characters escaped in Markdown text
+const markdownSpecial = "\\`*_[]<>#|"
+
+// markdownEscape escapes text so Markdown renders it literally
+func markdownEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownSpecial, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// markdownLink makes a URL or path safe as a Markdown link destination
+func markdownLink(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(link)
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() *Report {
+	return &Report{
+		Query:     `"flight log"`,
+		Root:      "documents",
+		Generated: time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC),
+		Searched:  12,
+		Hits: []ReportHit{
+			{Hit: Hit{Document: "pdf/a.pdf", Page: 2, Snippet: "the flight log for *March*"}, Link: "https://example.gov/a.pdf#page=2"},
+			{Hit: Hit{Document: "pdf/a.pdf", Page: 5, Snippet: "second flight log"}, Link: "https://example.gov/a.pdf#page=5"},
+			{Hit: Hit{Document: "pdf/b file.pdf", Page: 1, Snippet: "flight log, \"copy\""}, Link: "../pdf/b file.pdf#page=1"},
+		},
+	}
+}
+
+func TestWriteReportMarkdown(t *testing.T) {
+	var b strings.Builder
+	if err := WriteReportMarkdown(&b, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"# Search: \"flight log\"\n",
+		"3 matching page(s) in 2 of 12 document(s) searched under `documents`, 2026-03-01 14:30 UTC.",
+		"\n## pdf/a.pdf\n\n- **[Page 2](https://example.gov/a.pdf#page=2)**: the flight log for \\*March\\*\n- **[Page 5](",
+		"\n## pdf/b file.pdf\n\n- **[Page 1](../pdf/b%20file.pdf#page=1)**: ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteReportCSV(t *testing.T) {
+	var b strings.Builder
+	if err := WriteReportCSV(&b, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 || lines[0] != "document,page,snippet,link" {
+		t.Fatalf("unexpected CSV:\n%s", b.String())
+	}
+	if want := `pdf/b file.pdf,1,"flight log, ""copy""",../pdf/b file.pdf#page=1`; lines[3] != want {
+		t.Errorf("row = %s, want %s", lines[3], want)
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	for path, want := range map[string]string{"memo.md": ReportMarkdown, "Memo.MARKDOWN": ReportMarkdown, "hits.csv": ReportCSV} {
+		if got, err := ReportFormat(path); err != nil || got != want {
+			t.Errorf("ReportFormat(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if _, err := ReportFormat("hits.txt"); err == nil {
+		t.Error("ReportFormat accepted a .txt file")
+	}
+}
+
+func TestHitLinks(t *testing.T) {
+	hit := Hit{Document: "pdf/b file/b file.pdf", Page: 3}
+	if got, want := hit.Anchor(), "pdf/b file/b file.pdf#page=3"; got != want {
+		t.Errorf("Anchor() = %q, want %q", got, want)
+	}
+	if got, want := hit.URL("http://localhost:8080/"), "http://localhost:8080/api/file/pdf/b%20file/b%20file.pdf#page=3"; got != want {
+		t.Errorf("URL() = %q, want %q", got, want)
+	}
+	if got, want := hit.URL(""), "/api/file/pdf/b%20file/b%20file.pdf#page=3"; got != want {
+		t.Errorf("URL(\"\") = %q, want %q", got, want)
+	}
+}
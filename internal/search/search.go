@@ -0,0 +1,182 @@
+package search
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// Hit is a page that matched a query
+type Hit struct {
+	Document string // path of the document, relative to the tree
+	Page     int
+	Snippet  string // text around the first match, on one line
+}
+
+// Anchor returns a stable reference to the hit's page: the document's path
+// with a #page=N fragment, at which PDF viewers open the document
+func (h Hit) Anchor() string {
+	return fmt.Sprintf("%s#page=%d", h.Document, h.Page)
+}
+
+// URL returns the address of the hit's page on a defornicate-server serving
+// the tree from base, e.g. "http://localhost:8080", or only its path on the
+// server if base is empty
+func (h Hit) URL(base string) string {
+	segments := strings.Split(h.Document, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("%s/api/file/%s#page=%d", strings.TrimSuffix(base, "/"), strings.Join(segments, "/"), h.Page)
+}
+
+// snippetRadius is how many bytes of context are kept on each side of a match
+const snippetRadius = 60
+
+// Result holds the hits of a search and what was searched
+type Result struct {
+	Hits     []Hit
+	Searched int // documents with a JSON extraction output
+	Skipped  int // documents without one
+}
+
+// Tree searches the JSON extraction outputs of every document under root,
+// returning matching pages in document and page order
+func Tree(root string, q *Query) (*Result, error) {
+	docs, err := extractor.FindDocuments(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Page numbers are only recorded in the JSON output
+	jsonExt := extractor.New()
+	result := &Result{}
+	for _, docPath := range docs {
+		outputPath := jsonExt.FindOutput(docPath)
+		if outputPath == "" {
+			result.Skipped++
+			continue
+		}
+		extracted, err := extractor.ReadExtracted(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", outputPath, err)
+		}
+		curated, err := meta.Load(docPath)
+		if err != nil {
+			return nil, err
+		}
+		result.Searched++
+
+		rel, err := filepath.Rel(root, docPath)
+		if err != nil {
+			rel = docPath
+		}
+		rel = filepath.ToSlash(rel)
+		for _, page := range extracted.Content.Pages {
+			if page.Blank {
+				continue // separator pages carry stamps, not content
+			}
+			p := &Page{Document: rel, Number: page.PageNumber, Text: page.LineText(), Meta: curated, Cover: extracted.Metadata.CoverSheet}
+			for _, sig := range page.Signatures {
+				p.Signatures = append(p.Signatures, sig.Kind)
+			}
+			if q.Match(p) {
+				result.Hits = append(result.Hits, Hit{
+					Document: rel,
+					Page:     page.PageNumber,
+					Snippet:  snippet(p.Text, q.Terms(), q.Analyzer()),
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// snippet returns the text around the first occurrence of any term (words
+// compared after analysis), or the start of the text if none occurs (e.g. a
+// filter-only query)
+func snippet(text string, terms []string, analyzer Analyzer) string {
+	start, end := firstOccurrence(text, terms, analyzer)
+	from := max(start-snippetRadius, 0)
+	to := min(end+snippetRadius, len(text))
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	s := strings.Join(strings.Fields(text[from:to]), " ")
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(text) {
+		s += "…"
+	}
+	return s
+}
+
+// firstOccurrence returns the byte range of the first run of words in text
+// that matches one of terms (each a space-separated run of analyzed words),
+// or 0, 0 if there is none
+func firstOccurrence(text string, terms []string, analyzer Analyzer) (int, int) {
+	if found := occurrences(text, terms, analyzer, 1); len(found) > 0 {
+		return found[0][0], found[0][1]
+	}
+	return 0, 0
+}
+
+// Occurrences returns the byte ranges of every run of words in text that
+// matches one of the query's terms, in order, for highlighting
+func (q *Query) Occurrences(text string) [][2]int {
+	return occurrences(text, q.Terms(), q.analyzer, -1)
+}
+
+// occurrences returns the byte ranges of up to limit (all if negative) runs
+// of words in text that match one of terms
+func occurrences(text string, terms []string, analyzer Analyzer, limit int) [][2]int {
+	spans := wordSpans(text)
+	words := make([]string, len(spans))
+	for i, span := range spans {
+		words[i] = analyzer.Normalize(text[span[0]:span[1]])
+	}
+	var found [][2]int
+	for i := 0; i < len(words) && len(found) != limit; i++ {
+		for _, term := range terms {
+			termWords := strings.Split(term, " ")
+			if i+len(termWords) <= len(words) && slices.Equal(words[i:i+len(termWords)], termWords) {
+				found = append(found, [2]int{spans[i][0], spans[i+len(termWords)-1][1]})
+				i += len(termWords) - 1
+				break
+			}
+		}
+	}
+	return found
+}
+
+// wordSpans returns the byte ranges of the words tokenize would split text
+// into
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
@@ -0,0 +1,259 @@
+// Package segment splits the text of a page into paragraphs and sentences,
+// each with its byte offsets in the page text, so features working on prose
+// (reading aloud, chunking, entity and quote analysis) share one
+// segmentation. Page furniture and line noise are left out, lines are
+// rejoined into paragraphs, and words broken across lines are put back
+// together.
+package segment
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// pageNumberRe matches a line holding nothing but a page number, e.g.
+	// "12", "- 12 -", "Page 12" or "Page 12 of 40"
+	pageNumberRe = regexp.MustCompile(`(?i)^\s*(?:page\s+)?[-–—]?\s*\d{1,4}\s*[-–—]?\s*(?:of\s+\d{1,4})?\s*$`)
+	// leaderRe matches runs of dot leaders, rules and other repeated marks
+	leaderRe = regexp.MustCompile(`(?:[._=*~]\s?){3,}|[-–—]{3,}`)
+	// qaRe matches a transcript question or answer marker
+	qaRe = regexp.MustCompile(`^[QA][.:]\s+`)
+)
+
+// paragraphBreakRatio is how much shorter than the page's longest line a line
+// ending a sentence must be to end its paragraph
+const paragraphBreakRatio = 0.75
+
+// abbreviations end with a period that does not end a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "jr": true, "sr": true, "st": true,
+	"no": true, "nos": true, "v": true, "vs": true, "inc": true, "corp": true, "co": true,
+	"ltd": true, "esq": true, "hon": true, "gen": true, "e.g": true, "i.e": true, "etc": true,
+	"u.s": true, "u.s.c": true, "f.supp": true, "cir": true, "ex": true, "p": true, "pp": true,
+}
+
+// Span is a piece of page text. Text is cleaned: whitespace runs are single
+// spaces, lines are joined and hyphenation across lines is undone, so it may
+// differ from the page text between Start and End.
+type Span struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"` // byte offset of the first character in the page text
+	End   int    `json:"end"`   // byte offset just past the last character in the page text
+}
+
+// Paragraph is a paragraph of page text and its sentences
+type Paragraph struct {
+	Span
+	Sentences []Span `json:"sentences"`
+}
+
+// piece is cleaned text with the page text offset of each of its bytes
+type piece struct {
+	text   strings.Builder
+	origin []int
+}
+
+// add appends s, one page text offset per byte
+func (p *piece) add(s string, origin ...int) {
+	p.text.WriteString(s)
+	p.origin = append(p.origin, origin...)
+}
+
+// span returns the span of bytes [from, to) of the cleaned text
+func (p *piece) span(from, to int) Span {
+	text := p.text.String()
+	for from < to && text[from] == ' ' {
+		from++
+	}
+	for to > from && text[to-1] == ' ' {
+		to--
+	}
+	if from == to {
+		return Span{}
+	}
+	return Span{Text: text[from:to], Start: p.origin[from], End: p.origin[to-1] + 1}
+}
+
+// line is a cleaned line of page text
+type line struct {
+	text   string
+	origin []int
+}
+
+// Paragraphs splits page text into paragraphs, each split into sentences.
+// Page numbers and lines without a letter or digit are dropped, breaking
+// the paragraph they interrupt. A paragraph ends at a blank line, before a
+// transcript question or answer ("Q." / "A."), and after a line that ends a
+// sentence well short of the page's longest line.
+func Paragraphs(text string) []Paragraph {
+	var lines []line
+	longest := 0
+	offset := 0
+	for _, raw := range strings.Split(text, "\n") {
+		l := cleanLine(raw, offset)
+		if isNoise(l.text) {
+			l = line{} // keeps the paragraph break a dropped line may stand for
+		}
+		lines = append(lines, l)
+		longest = max(longest, len(l.text))
+		offset += len(raw) + 1
+	}
+
+	var paragraphs []Paragraph
+	current := &piece{}
+	flush := func() {
+		if current.text.Len() > 0 {
+			paragraph := Paragraph{Span: current.span(0, current.text.Len())}
+			paragraph.Sentences = sentences(current)
+			paragraphs = append(paragraphs, paragraph)
+			current = &piece{}
+		}
+	}
+	for i, l := range lines {
+		if l.text == "" {
+			flush()
+			continue
+		}
+		if qaRe.MatchString(l.text) {
+			flush()
+		}
+		switch s := current.text.String(); {
+		case s == "":
+		case hyphenated(s, l.text):
+			// Drop the hyphen
+			rest := s[:len(s)-1]
+			origin := current.origin[:len(current.origin)-1]
+			current = &piece{origin: origin}
+			current.text.WriteString(rest)
+		default:
+			current.add(" ", current.origin[len(current.origin)-1]+1)
+		}
+		current.add(l.text, l.origin...)
+
+		if endsSentence(l.text) && float64(len(l.text)) < paragraphBreakRatio*float64(longest) && i+1 < len(lines) {
+			flush()
+		}
+	}
+	flush()
+	return paragraphs
+}
+
+// Sentences splits page text into sentences, across paragraphs
+func Sentences(text string) []Span {
+	var spans []Span
+	for _, paragraph := range Paragraphs(text) {
+		spans = append(spans, paragraph.Sentences...)
+	}
+	return spans
+}
+
+// sentences splits a paragraph into sentences: a sentence ends at ".", "?"
+// or "!" (and any closing quotes or brackets) followed by a space and a
+// capital letter, digit or opening quote, unless the period belongs to an
+// abbreviation ("Mr.", "No.", "U.S.") or an initial ("J. Epstein")
+func sentences(p *piece) []Span {
+	text := p.text.String()
+	var spans []Span
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '.' && c != '?' && c != '!' {
+			continue
+		}
+		end := i + 1
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(`"')]”’`, r) {
+				break
+			}
+			end += size
+		}
+		if end >= len(text) || text[end] != ' ' || end+1 >= len(text) {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(text[end+1:])
+		if !unicode.IsUpper(next) && !unicode.IsDigit(next) && !strings.ContainsRune(`"'(“‘`, next) {
+			continue
+		}
+		if c == '.' && !endsWithWord(text[start:i]) {
+			continue
+		}
+		if span := p.span(start, end); span.Text != "" {
+			spans = append(spans, span)
+		}
+		start = end + 1
+	}
+	if span := p.span(start, len(text)); span.Text != "" {
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// endsWithWord reports whether a period after text ends a sentence rather
+// than an abbreviation or an initial
+func endsWithWord(text string) bool {
+	word := text[strings.LastIndexAny(text, " (\"“")+1:]
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return !unicode.IsUpper(r) // "J." is an initial
+	}
+	return !abbreviations[strings.ToLower(word)]
+}
+
+// cleanLine replaces leaders and rules with spaces, collapses whitespace and
+// trims the line, recording the page text offset of each byte kept. offset
+// is where the line starts in the page text.
+func cleanLine(raw string, offset int) line {
+	blank := make([]bool, len(raw))
+	for _, m := range leaderRe.FindAllStringIndex(raw, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			blank[i] = true
+		}
+	}
+	var b strings.Builder
+	var origin []int
+	space := false
+	for i := 0; i < len(raw); i++ {
+		if blank[i] || raw[i] == ' ' || raw[i] == '\t' || raw[i] == '\r' {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			origin = append(origin, offset+i-1)
+			space = false
+		}
+		b.WriteByte(raw[i])
+		origin = append(origin, offset+i)
+	}
+	return line{text: b.String(), origin: origin}
+}
+
+// isNoise reports whether a line carries no prose: page numbers and lines
+// without a single letter or digit (rules, stray marks)
+func isNoise(text string) bool {
+	if pageNumberRe.MatchString(text) {
+		return true
+	}
+	return !strings.ContainsFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) })
+}
+
+// hyphenated reports whether prev ends with a word broken across the line
+// break that next completes ("docu-" + "ment")
+func hyphenated(prev, next string) bool {
+	if len(prev) < 2 || !strings.HasSuffix(prev, "-") {
+		return false
+	}
+	before := []rune(prev[:len(prev)-1])
+	first := []rune(next)[0]
+	return unicode.IsLetter(before[len(before)-1]) && unicode.IsLower(first)
+}
+
+// endsSentence reports whether a line ends with sentence punctuation
+func endsSentence(text string) bool {
+	text = strings.TrimRight(text, `"')”’`)
+	return strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, ":")
+}
@@ -0,0 +1,67 @@
+package segment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParagraphs(t *testing.T) {
+	text := strings.Join([]string{
+		"- 3 -",
+		"The witness stated that the flight records and other docu-",
+		"ments were kept there. Mr. Smith agreed.",
+		"That ended it.",
+		"____________________",
+		"Q. Were they?",
+		"A. Yes.",
+		"Page 3 of 40",
+	}, "\n")
+	paragraphs := Paragraphs(text)
+
+	var got []string
+	for _, p := range paragraphs {
+		got = append(got, p.Text)
+	}
+	want := []string{
+		"The witness stated that the flight records and other documents were kept there. Mr. Smith agreed.",
+		"That ended it.",
+		"Q. Were they?",
+		"A. Yes.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Paragraphs() =\n%q\nwant\n%q", got, want)
+	}
+
+	first := paragraphs[0]
+	if first.Start != strings.Index(text, "The witness") || first.End != strings.Index(text, "agreed.")+len("agreed.") {
+		t.Errorf("paragraph 1 spans [%d, %d), want the two lines it was joined from", first.Start, first.End)
+	}
+	if len(first.Sentences) != 2 || first.Sentences[1].Text != "Mr. Smith agreed." {
+		t.Fatalf("paragraph 1 sentences = %+v, want two, the second about Mr. Smith", first.Sentences)
+	}
+	if s := first.Sentences[1]; text[s.Start:s.End] != s.Text {
+		t.Errorf("sentence spans %q in the page text, want %q", text[s.Start:s.End], s.Text)
+	}
+}
+
+func TestSentences(t *testing.T) {
+	text := `J. Epstein flew to the U.S. Virgin Islands. "Who paid?" he asked. See Ex. 4 at 12. It was 3.5 hours! Done`
+	var got []string
+	for _, s := range Sentences(text) {
+		got = append(got, s.Text)
+		if text[s.Start:s.End] != s.Text {
+			t.Errorf("sentence %q spans %q", s.Text, text[s.Start:s.End])
+		}
+	}
+	want := []string{
+		"J. Epstein flew to the U.S. Virgin Islands.",
+		`"Who paid?" he asked.`,
+		"See Ex. 4 at 12.",
+		"It was 3.5 hours!",
+		"Done",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sentences() =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limits of keys that don't set their own
+const (
+	DefaultRate  = 5.0 // requests per second, sustained
+	DefaultBurst = 20  // requests allowed at once after a pause
+)
+
+// APIKey is a key allowed to use the API, with its own rate limit so no
+// member of a team can monopolize a shared server
+type APIKey struct {
+	Name  string  `json:"name"` // who the key belongs to
+	Key   string  `json:"key"`
+	Rate  float64 `json:"rate,omitempty"`  // requests per second; DefaultRate when 0
+	Burst int     `json:"burst,omitempty"` // requests allowed at once; DefaultBurst when 0
+}
+
+// LoadAPIKeys reads API keys from a JSON file holding a list of keys
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		switch {
+		case key.Key == "":
+			return nil, fmt.Errorf("API key %d in %s has no key", i+1, path)
+		case seen[key.Key]:
+			return nil, fmt.Errorf("API key %d in %s repeats an earlier key", i+1, path)
+		case key.Rate < 0 || key.Burst < 0:
+			return nil, fmt.Errorf("API key %d in %s has a negative rate or burst", i+1, path)
+		}
+		seen[key.Key] = true
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no API keys in %s", path)
+	}
+	return keys, nil
+}
+
+// bucket is the token bucket rate limiting one key: it holds up to burst
+// tokens, refills at rate tokens per second, and each request takes one
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// take takes a token if there is one, or returns how long until there is
+func (b *bucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// limiter authenticates API requests by key and rate limits each key
+type limiter struct {
+	keys []APIKey
+	now  func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket // by key
+}
+
+// newLimiter creates a limiter for keys, starting every bucket full
+func newLimiter(keys []APIKey, now func() time.Time) *limiter {
+	l := &limiter{keys: keys, now: now, buckets: make(map[string]*bucket, len(keys))}
+	for _, key := range keys {
+		rate, burst := key.Rate, key.Burst
+		if rate == 0 {
+			rate = DefaultRate
+		}
+		if burst == 0 {
+			burst = DefaultBurst
+		}
+		l.buckets[key.Key] = &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now()}
+	}
+	return l
+}
+
+// requestKey returns the key a request presents: an Authorization bearer
+// token, an X-API-Key header, or a ?key= parameter (for links opened from the
+// browser UI)
+func requestKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// lookup returns the configured key matching presented, comparing in
+// constant time
+func (l *limiter) lookup(presented string) (APIKey, bool) {
+	var found APIKey
+	ok := false
+	for _, key := range l.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Key), []byte(presented)) == 1 {
+			found, ok = key, true
+		}
+	}
+	return found, ok
+}
+
+// serve passes a request to next if it presents a valid key within its rate
+// limit. Requests without a valid key are refused with 401, and requests
+// beyond their key's rate with 429 and a Retry-After header.
+func (l *limiter) serve(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	presented := requestKey(r)
+	key, ok := l.lookup(presented)
+	if presented == "" || !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="defornicate"`)
+		writeError(w, http.StatusUnauthorized, "a valid API key is required")
+		return
+	}
+	l.mu.Lock()
+	allowed, wait := l.buckets[key.Key].take(l.now())
+	l.mu.Unlock()
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("rate limit of key %q exceeded", key.Name))
+		return
+	}
+	next(w, r)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// flight is an extraction in progress, shared by every request for the same
+// document that arrives before it completes
+type flight struct {
+	done   chan struct{} // closed once output and err are set
+	output string
+	err    error
+}
+
+// failure is a failed extraction, remembered so that requests for a document
+// that cannot be extracted don't each extract it again
+type failure struct {
+	modTime time.Time // modification time of the document when it failed
+	err     error
+}
+
+// extract extracts the document at path and saves its output next to it,
+// returning the output's path. Concurrent requests for the same document wait
+// for a single extraction. A failure is returned again without retrying until
+// the document changes on disk, unless it failed because the tree was
+// locked.
+func (s *Server) extract(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract document: %w", err)
+	}
+
+	s.flightMu.Lock()
+	if f, ok := s.failed[path]; ok && f.modTime.Equal(info.ModTime()) {
+		s.flightMu.Unlock()
+		return "", f.err
+	}
+	if f, ok := s.flights[path]; ok {
+		s.flightMu.Unlock()
+		<-f.done
+		return f.output, f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	s.flights[path] = f
+	s.flightMu.Unlock()
+
+	// Another server or an extraction run may have written the output since
+	// the request looked for it
+	if f.output = s.ext.FindOutput(path); f.output == "" {
+		f.output, f.err = s.extractAndSave(path)
+	}
+
+	s.flightMu.Lock()
+	delete(s.flights, path)
+	var locked *lock.LockedError
+	if f.err != nil && !errors.As(f.err, &locked) {
+		s.failed[path] = failure{modTime: info.ModTime(), err: f.err}
+	} else {
+		delete(s.failed, path)
+	}
+	s.flightMu.Unlock()
+	close(f.done)
+	return f.output, f.err
+}
+
+// extractAndSave extracts a document and writes its output as an extraction
+// run would, holding the lock of the documents tree while it writes. If a run
+// holds the lock, the output is not written and a *lock.LockedError is
+// returned.
+func (s *Server) extractAndSave(path string) (string, error) {
+	text, err := s.ext.ExtractText(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract document: %w", err)
+	}
+	// The lock also excludes the server's other extractions, which would
+	// otherwise find it held by this process
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	l, err := lock.Acquire(s.root, pathutil.Permissions{})
+	if err != nil {
+		return "", err
+	}
+	defer l.Release()
+	output, err := s.ext.SaveExtractedText(path, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to save extracted text: %w", err)
+	}
+	return output, nil
+}
@@ -0,0 +1,477 @@
+// Package server provides the read-only REST API over a documents tree used
+// by the defornicate-server binary, and the browser UI built on it. The only
+// writes it can make are the extraction outputs of documents extracted on
+// demand, when that is turned on.
+package server
+
+import (
+	"cmp"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/corpus"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/search"
+	"defornicate-epstein-files/internal/xref"
+)
+
+// ui holds the browser UI served at /
+//
+//go:embed ui
+var ui embed.FS
+
+// defaultSearchLimit caps the hits of a search without ?limit=
+const defaultSearchLimit = 100
+
+// Pages of the document listing
+const (
+	defaultPageSize = 100  // documents per page without ?per_page=
+	maxPageSize     = 1000 // largest ?per_page= accepted
+)
+
+// Server answers API requests about the documents under root
+type Server struct {
+	root    string
+	ext     *extractor.Extractor
+	mux     *http.ServeMux
+	limiter *limiter // nil when the API is open
+
+	mu       sync.Mutex
+	cat      *catalog.Catalog
+	catSaved time.Time // modification time of the catalog file when cat was loaded
+
+	extractOnDemand bool
+	flightMu        sync.Mutex
+	flights         map[string]*flight // extractions in progress, by document path
+	failed          map[string]failure // extractions that failed, by document path
+	saveMu          sync.Mutex         // held while an extraction output is written
+}
+
+// Options configures a Server
+type Options struct {
+	// ExtractOnDemand extracts a document that has no extraction output yet
+	// when its text is requested, saving the output next to it so later
+	// requests are served from disk
+	ExtractOnDemand bool
+	// APIKeys, when set, are required on every API request, each key rate
+	// limited on its own. The browser UI itself is served without a key.
+	APIKeys []APIKey
+}
+
+// SearchHit is a page matching a search in API responses
+type SearchHit struct {
+	Document string `json:"document"` // relative to the documents directory, with forward slashes
+	Page     int    `json:"page"`
+	Snippet  string `json:"snippet"`
+	Anchor   string `json:"anchor"` // the document's path with #page=N
+	URL      string `json:"url"`    // where the server serves the document, opening at the page
+}
+
+// DocumentInfo describes one document in API responses
+type DocumentInfo struct {
+	Path         string    `json:"path"` // relative to the documents directory, with forward slashes
+	Pages        int       `json:"pages,omitempty"`
+	URLs         []string  `json:"urls,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at,omitzero"`
+	Extracted    bool      `json:"extracted"`
+
+	Cover      *legal.CoverSheet `json:"cover,omitempty"`      // production details from the cover sheet
+	NeedsOCR   int               `json:"needs_ocr,omitempty"`  // pages with ink but too little readable text
+	Redactions int               `json:"redactions,omitempty"` // redaction marks in the extracted text
+}
+
+// New creates a Server for the documents tree at root, described by cat, that
+// finds extraction outputs with ext
+func New(root string, cat *catalog.Catalog, ext *extractor.Extractor) *Server {
+	return NewWithOptions(root, cat, ext, Options{})
+}
+
+// NewWithOptions creates a Server like New, configured by opts. Documents
+// extracted on demand are extracted with ext.
+func NewWithOptions(root string, cat *catalog.Catalog, ext *extractor.Extractor, opts Options) *Server {
+	s := &Server{
+		root:            root,
+		cat:             cat,
+		ext:             ext,
+		mux:             http.NewServeMux(),
+		extractOnDemand: opts.ExtractOnDemand,
+		flights:         make(map[string]*flight),
+		failed:          make(map[string]failure),
+	}
+	if len(opts.APIKeys) > 0 {
+		s.limiter = newLimiter(opts.APIKeys, time.Now)
+	}
+	if info, err := os.Stat(cat.Path()); err == nil {
+		s.catSaved = info.ModTime()
+	}
+	assets, err := fs.Sub(ui, "ui")
+	if err != nil {
+		panic(err) // the directory is embedded above
+	}
+	s.mux.Handle("GET /api/documents", s.api(s.handleList))
+	s.mux.Handle("GET /api/documents/{path...}", s.api(s.handleDocument))
+	s.mux.Handle("GET /api/text/{path...}", s.api(s.handleText))
+	s.mux.Handle("GET /api/file/{path...}", s.api(s.handleFile))
+	s.mux.Handle("GET /api/search", s.api(s.handleSearch))
+	s.mux.Handle("GET /api/xrefs/{path...}", s.api(s.handleXrefs))
+	s.mux.Handle("GET /", http.FileServerFS(assets))
+	return s
+}
+
+// api wraps an API handler in the key check and rate limit, if keys are
+// configured
+func (s *Server) api(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			handler(w, r)
+			return
+		}
+		s.limiter.serve(w, r, handler)
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleList lists the documents of the tree a page at a time (?page=,
+// from 1, of ?per_page= documents), sorted by ?sort= (name, date or pages;
+// ?order=desc reverses it). Filters keep the documents whose cover sheet
+// names a producing party (?party=, matched case-insensitively as a
+// substring) or carries a designation (?designation=), those whose
+// meta.yaml lists a tag (?tag=) or records a class (?class=), and, with
+// ?needs_ocr= and ?has_redactions= set to true or false, those that have or
+// lack pages needing OCR or redaction marks. A page past the last lists no
+// documents.
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	page, perPage := 1, defaultPageSize
+	for name, value := range map[string]*int{"page": &page, "per_page": &perPage} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || (name == "per_page" && n > maxPageSize) {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*value = n
+		}
+	}
+	less, ok := documentOrder[cmp.Or(params.Get("sort"), "name")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid sort (expected name, date or pages)")
+		return
+	}
+	order := params.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeError(w, http.StatusBadRequest, "invalid order (expected asc or desc)")
+		return
+	}
+	party := strings.ToLower(strings.TrimSpace(params.Get("party")))
+	designation := strings.TrimSpace(params.Get("designation"))
+	if designation != "" {
+		designation = legal.CanonicalDesignation(designation)
+	}
+	tag, class := strings.TrimSpace(params.Get("tag")), strings.TrimSpace(params.Get("class"))
+	flags := map[string]func(DocumentInfo) bool{
+		"needs_ocr":      func(info DocumentInfo) bool { return info.NeedsOCR > 0 },
+		"has_redactions": func(info DocumentInfo) bool { return info.Redactions > 0 },
+	}
+	wanted := make(map[string]bool)
+	for name := range flags {
+		if v := params.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name+" (expected true or false)")
+				return
+			}
+			wanted[name] = b
+		}
+	}
+
+	rels, err := corpus.Documents(s.root)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	docs := make([]DocumentInfo, 0, len(rels))
+	for _, rel := range rels {
+		info := s.info(rel)
+		if party != "" && (info.Cover == nil || !strings.Contains(strings.ToLower(info.Cover.ProducingParty), party)) {
+			continue
+		}
+		if designation != "" && (info.Cover == nil || info.Cover.Designation != designation) {
+			continue
+		}
+		if !matchesFlags(info, flags, wanted) {
+			continue
+		}
+		if tag != "" || class != "" {
+			curated, _ := meta.Load(filepath.Join(s.root, rel))
+			if (tag != "" && !curated.HasTag(tag)) || (class != "" && !curated.HasClass(class)) {
+				continue
+			}
+		}
+		docs = append(docs, info)
+	}
+	slices.SortStableFunc(docs, func(a, b DocumentInfo) int {
+		if c := less(a, b); c != 0 {
+			if order == "desc" {
+				return -c
+			}
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	// Compared before multiplying, so a huge ?page= cannot overflow
+	start := len(docs)
+	if page-1 <= len(docs)/perPage {
+		start = min((page-1)*perPage, len(docs))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"documents": docs[start:min(start+perPage, len(docs))],
+		"total":     len(docs),
+		"page":      page,
+		"per_page":  perPage,
+	})
+}
+
+// matchesFlags reports whether a document has each flag of wanted set as
+// wanted
+func matchesFlags(info DocumentInfo, flags map[string]func(DocumentInfo) bool, wanted map[string]bool) bool {
+	for name, want := range wanted {
+		if flags[name](info) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// documentOrder compares documents for each ?sort= of the listing
+var documentOrder = map[string]func(a, b DocumentInfo) int{
+	"name":  func(a, b DocumentInfo) int { return strings.Compare(a.Path, b.Path) },
+	"date":  func(a, b DocumentInfo) int { return a.DownloadedAt.Compare(b.DownloadedAt) },
+	"pages": func(a, b DocumentInfo) int { return cmp.Compare(a.Pages, b.Pages) },
+}
+
+// handleSearch searches the extracted pages with the query language of the
+// search command (?q=), returning at most ?limit= hits. ?ignore_case=,
+// ?fold= and ?stem= turn on the matching analyzer options.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	limit := defaultSearchLimit
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	analyzer := search.Analyzer{
+		Lowercase: params.Get("ignore_case") == "true",
+		Fold:      params.Get("fold") == "true",
+		Stem:      params.Get("stem") == "true",
+	}
+	q, err := search.ParseWithAnalyzer(params.Get("q"), analyzer)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := search.Tree(s.root, q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	hits := make([]SearchHit, 0, min(len(result.Hits), limit))
+	for _, hit := range result.Hits[:min(len(result.Hits), limit)] {
+		hits = append(hits, SearchHit{Document: hit.Document, Page: hit.Page, Snippet: hit.Snippet, Anchor: hit.Anchor(), URL: hit.URL("")})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"hits":      hits,
+		"total":     len(result.Hits),
+		"searched":  result.Searched,
+		"truncated": len(result.Hits) > limit,
+	})
+}
+
+// handleXrefs lists the exhibits a document mentions that are documents of
+// the tree (references), and the documents mentioning it as an exhibit
+// (referenced_by)
+func (s *Server) handleXrefs(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.document(w, r)
+	if !ok {
+		return
+	}
+	result, err := xref.Tree(s.root)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	references, referencedBy := []xref.Link{}, []xref.Link{}
+	for _, link := range result.Links {
+		if link.Document == rel {
+			references = append(references, link)
+		}
+		if link.Target == rel {
+			referencedBy = append(referencedBy, link)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"references": references, "referenced_by": referencedBy})
+}
+
+// handleDocument describes one document
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.document(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.info(rel))
+}
+
+// handleFile serves the document itself, so links to it with a #page=N
+// fragment open a PDF at that page in the browser
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.document(w, r)
+	if !ok {
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(s.root, rel))
+}
+
+// handleText returns a document's extraction output as stored, extracting
+// the document first if it has none and extraction on demand is turned on
+func (s *Server) handleText(w http.ResponseWriter, r *http.Request) {
+	rel, ok := s.document(w, r)
+	if !ok {
+		return
+	}
+	path := filepath.Join(s.root, rel)
+	output := s.ext.FindOutput(path)
+	if output == "" && s.extractOnDemand && s.ext.Supports(path) {
+		var err error
+		if output, err = s.extract(path); err != nil {
+			status := http.StatusUnprocessableEntity
+			var locked *lock.LockedError
+			if errors.As(err, &locked) {
+				// A run is writing to the tree; the client may try again later
+				status = http.StatusConflict
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+	}
+	if output == "" {
+		writeError(w, http.StatusNotFound, "document has not been extracted")
+		return
+	}
+	data, err := extractor.ReadOutput(output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType(output))
+	w.Write(data)
+}
+
+// document resolves the {path} of a request to an existing document, writing
+// an error response and returning false if there is none
+func (s *Server) document(w http.ResponseWriter, r *http.Request) (string, bool) {
+	rel := filepath.FromSlash(r.PathValue("path"))
+	if !filepath.IsLocal(rel) {
+		writeError(w, http.StatusBadRequest, "invalid document path")
+		return "", false
+	}
+	if !corpus.IsDocument(filepath.Base(rel)) || catalog.IsCatalogFile(filepath.ToSlash(rel)) {
+		writeError(w, http.StatusNotFound, "no such document")
+		return "", false
+	}
+	info, err := os.Stat(filepath.Join(s.root, rel))
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.IsDir()) {
+		writeError(w, http.StatusNotFound, "no such document")
+		return "", false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return "", false
+	}
+	return rel, true
+}
+
+// info builds the API description of the document at rel
+func (s *Server) info(rel string) DocumentInfo {
+	info := DocumentInfo{
+		Path:      filepath.ToSlash(rel),
+		Extracted: s.ext.FindOutput(filepath.Join(s.root, rel)) != "",
+	}
+	if entry, ok := s.catalog().Get(filepath.Join(s.root, rel)); ok {
+		info.Pages = entry.Pages
+		info.URLs = entry.URLs
+		info.DownloadedAt = entry.DownloadedAt
+		info.Cover = entry.Cover
+		info.NeedsOCR, info.Redactions = entry.NeedsOCR, entry.Redactions
+	}
+	return info
+}
+
+// catalog returns the catalog of the tree, reloaded if it has been saved
+// since it was loaded, so documents downloaded by a run alongside the server
+// show up without a restart. The catalog already loaded is kept if the file
+// cannot be read.
+func (s *Server) catalog() *catalog.Catalog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(s.cat.Path())
+	if err != nil || !info.ModTime().After(s.catSaved) {
+		return s.cat
+	}
+	if cat, err := catalog.Load(s.root, pathutil.Permissions{}); err == nil {
+		s.cat, s.catSaved = cat, info.ModTime()
+	}
+	return s.cat
+}
+
+// contentType returns the media type of an extraction output by its format.
+// Compressed outputs are served decompressed.
+func contentType(output string) string {
+	output = strings.TrimSuffix(strings.TrimSuffix(output, ".gz"), ".zst")
+	switch filepath.Ext(output) {
+	case ".json":
+		return "application/json"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,485 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"defornicate-epstein-files/internal/catalog"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/lock"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// newTestServer builds a tree with one extracted and one unextracted document
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"pdf/a/a.pdf":            "%PDF-1.4 a",
+		"pdf/a/a.extracted.json": `{"metadata":{"filename":"a.pdf"},"content":{"pages":[{"page_number":1,"text":"Flight log to Teterboro"},{"page_number":2,"text":"Passenger manifest"}]}}`,
+		"pdf/b/b.pdf":            "%PDF-1.4 b",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cat, err := catalog.Load(root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatalf("catalog.Load() error = %v", err)
+	}
+	return New(root, cat, extractor.New())
+}
+
+func get(s *Server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestListDocuments(t *testing.T) {
+	rec := get(newTestServer(t), "/api/documents")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct{ Documents []DocumentInfo }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := []DocumentInfo{{Path: "pdf/a/a.pdf", Extracted: true}, {Path: "pdf/b/b.pdf"}}
+	if len(body.Documents) != len(want) {
+		t.Fatalf("documents = %+v, want %+v", body.Documents, want)
+	}
+	for i := range want {
+		if body.Documents[i].Path != want[i].Path || body.Documents[i].Extracted != want[i].Extracted {
+			t.Errorf("document %d = %+v, want %+v", i, body.Documents[i], want[i])
+		}
+	}
+}
+
+func TestListDocumentsByCoverSheet(t *testing.T) {
+	s := newTestServer(t)
+	path := filepath.Join(s.root, "pdf", "a", "a.pdf")
+	if err := s.cat.RecordDownload("https://example.com/a.pdf", path, [32]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	s.cat.RecordCover(path, &legal.CoverSheet{
+		ProducingParty: "Federal Bureau of Investigation",
+		Designation:    legal.DesignationConfidential,
+	})
+	for query, want := range map[string]int{
+		"?party=bureau":                          1,
+		"?party=bureau&designation=Confidential": 1,
+		"?designation=highly+confidential":       0,
+		"?party=court":                           0,
+	} {
+		var body struct{ Documents []DocumentInfo }
+		if err := json.Unmarshal(get(s, "/api/documents"+query).Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if len(body.Documents) != want {
+			t.Errorf("%s: documents = %+v, want %d", query, body.Documents, want)
+		}
+	}
+}
+
+func TestListDocumentsPaged(t *testing.T) {
+	s := newTestServer(t)
+	for _, doc := range []struct {
+		name  string
+		pages int
+		meta  string
+	}{
+		{"c", 40, "tags: [needs-ocr]\nclass: Deposition\n"},
+		{"d", 2, "tags: [reviewed]\n"},
+		{"e", 7, "class: deposition\n"},
+	} {
+		path := filepath.Join(s.root, "pdf", doc.name, doc.name+".pdf")
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("%PDF-1.4 "+doc.name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(filepath.Dir(path), "meta.yaml"), []byte(doc.meta), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.cat.RecordDownload("https://example.com/"+doc.name+".pdf", path, [32]byte{}); err != nil {
+			t.Fatal(err)
+		}
+		s.cat.RecordPages(path, doc.pages)
+	}
+	s.cat.RecordReview(filepath.Join(s.root, "pdf", "c", "c.pdf"), 3, 0)
+	s.cat.RecordReview(filepath.Join(s.root, "pdf", "d", "d.pdf"), 0, 2)
+
+	list := func(query string) (paths []string, total int) {
+		t.Helper()
+		rec := get(s, "/api/documents"+query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", query, rec.Code, rec.Body)
+		}
+		var body struct {
+			Documents []DocumentInfo
+			Total     int
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		for _, doc := range body.Documents {
+			paths = append(paths, strings.TrimSuffix(filepath.Base(doc.Path), ".pdf"))
+		}
+		return paths, body.Total
+	}
+	tests := []struct {
+		query string
+		want  string
+		total int
+	}{
+		{"", "a b c d e", 5},
+		{"?per_page=2", "a b", 5},
+		{"?per_page=2&page=3", "e", 5},
+		{"?per_page=2&page=4", "", 5},
+		{"?sort=pages&order=desc", "c e d a b", 5},
+		{"?sort=pages&per_page=2&page=2", "d e", 5},
+		{"?tag=NEEDS-OCR", "c", 1},
+		{"?class=deposition", "c e", 2},
+		{"?class=deposition&tag=reviewed", "", 0},
+		{"?needs_ocr=true", "c", 1},
+		{"?has_redactions=1", "d", 1},
+		{"?needs_ocr=false&has_redactions=false", "a b e", 3},
+		{"?page=100000000000000000", "", 5},
+		{"?per_page=1000&page=9223372036854775807", "", 5},
+	}
+	for _, tt := range tests {
+		paths, total := list(tt.query)
+		if got := strings.Join(paths, " "); got != tt.want || total != tt.total {
+			t.Errorf("%s: documents = %q (total %d), want %q (total %d)", tt.query, got, total, tt.want, tt.total)
+		}
+	}
+	for _, query := range []string{"?page=0", "?per_page=5000", "?sort=size", "?order=up", "?needs_ocr=maybe"} {
+		if rec := get(s, "/api/documents"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/text/pdf/a/a.pdf", http.StatusOK},
+		{"/api/text/pdf/b/b.pdf", http.StatusNotFound},
+		{"/api/text/pdf/c/c.pdf", http.StatusNotFound},
+		{"/api/text/pdf/a/a.extracted.json", http.StatusNotFound},
+		{"/api/file/pdf/b/b.pdf", http.StatusOK},
+		{"/api/file/pdf/c/c.pdf", http.StatusNotFound},
+		{"/api/file/pdf/a/a.extracted.json", http.StatusNotFound},
+		{"/api/documents/pdf/a/a.pdf", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := get(s, tt.path); rec.Code != tt.status {
+			t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+	}
+	if rec := get(s, "/api/text/pdf/a/a.pdf"); rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestServer(t)
+	rec := get(s, "/api/search?q=teterboro&ignore_case=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Hits     []SearchHit
+		Total    int
+		Searched int
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Total != 1 || body.Searched != 1 || len(body.Hits) != 1 {
+		t.Fatalf("body = %+v, want one hit in one searched document", body)
+	}
+	hit := body.Hits[0]
+	if hit.Document != "pdf/a/a.pdf" || hit.Page != 1 || hit.Anchor != "pdf/a/a.pdf#page=1" || hit.URL != "/api/file/pdf/a/a.pdf#page=1" {
+		t.Errorf("hit = %+v, want pdf/a/a.pdf page 1", hit)
+	}
+	if rec := get(s, strings.TrimSuffix(hit.URL, "#page=1")); rec.Code != http.StatusOK || rec.Body.String() != "%PDF-1.4 a" {
+		t.Errorf("GET %s = %d %q, want the document", hit.URL, rec.Code, rec.Body)
+	}
+
+	if rec := get(s, "/api/search?q=teterboro"); !strings.Contains(rec.Body.String(), `"total": 0`) {
+		t.Errorf("case-sensitive search matched: %s", rec.Body)
+	}
+	if rec := get(s, "/api/search?q=log+OR+manifest&limit=1"); !strings.Contains(rec.Body.String(), `"truncated": true`) {
+		t.Errorf("limit=1 with two matching pages not truncated: %s", rec.Body)
+	}
+	for _, query := range []string{"", "q=(flight", "q=log&limit=0"} {
+		if rec := get(s, "/api/search?"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/search?%s status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestXrefs(t *testing.T) {
+	s := newTestServer(t)
+	for path, content := range map[string]string{
+		"pdf/a/a.extracted.json": `{"metadata":{"filename":"a.pdf"},"content":{"pages":[{"page_number":1,"text":"Flight log"},{"page_number":2,"text":"See GX-7."}]}}`,
+		"pdf/GX-7/GX-7.pdf":      "%PDF-1.4 gx",
+	} {
+		full := filepath.Join(s.root, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var body struct {
+		References []struct {
+			Page            int
+			Exhibit, Target string
+		}
+		ReferencedBy []struct{ Document string } `json:"referenced_by"`
+	}
+	rec := get(s, "/api/xrefs/pdf/a/a.pdf")
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.References) != 1 || body.References[0].Target != "pdf/GX-7/GX-7.pdf" || body.References[0].Page != 2 || len(body.ReferencedBy) != 0 {
+		t.Errorf("xrefs of the mentioning document = %s", rec.Body)
+	}
+	rec = get(s, "/api/xrefs/pdf/GX-7/GX-7.pdf")
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(body.ReferencedBy) != 1 || body.ReferencedBy[0].Document != "pdf/a/a.pdf" {
+		t.Errorf("xrefs of the exhibit = %s", rec.Body)
+	}
+	if rec := get(s, "/api/xrefs/pdf/c/c.pdf"); rec.Code != http.StatusNotFound {
+		t.Errorf("xrefs of a missing document status = %d, want 404", rec.Code)
+	}
+}
+
+func TestUI(t *testing.T) {
+	s := newTestServer(t)
+	for path, want := range map[string]string{
+		"/":          "text/html",
+		"/app.js":    "javascript",
+		"/style.css": "text/css",
+	} {
+		rec := get(s, path)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Type"), want) {
+			t.Errorf("GET %s = %d %q, want 200 %s", path, rec.Code, rec.Header().Get("Content-Type"), want)
+		}
+	}
+}
+
+func TestCatalogReload(t *testing.T) {
+	s := newTestServer(t)
+	// A sync running alongside the server saves the catalog
+	other, err := catalog.Load(s.root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(s.root, "pdf", "b", "b.pdf")
+	if err := other.RecordDownload("https://example.com/b.pdf", path, [32]byte{}); err != nil {
+		t.Fatal(err)
+	}
+	other.RecordPages(path, 3)
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute) // past the coarse mtime of some filesystems
+	if err := os.Chtimes(other.Path(), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	var info DocumentInfo
+	if err := json.Unmarshal(get(s, "/api/documents/pdf/b/b.pdf").Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if info.Pages != 3 {
+		t.Errorf("Pages = %d after the catalog was saved, want 3", info.Pages)
+	}
+}
+
+// textPDF returns a one-page PDF showing text
+func textPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, start)
+	return b.Bytes()
+}
+
+func TestExtractOnDemand(t *testing.T) {
+	s := newTestServer(t)
+	s = NewWithOptions(s.root, s.cat, s.ext, Options{ExtractOnDemand: true})
+	doc := filepath.Join(s.root, "pdf", "c", "c.pdf")
+	if err := os.MkdirAll(filepath.Dir(doc), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(doc, textPDF("Message pad"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent requests share one extraction and all get the text
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, 4)
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = get(s, "/api/text/pdf/c/c.pdf")
+		}()
+	}
+	wg.Wait()
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Message pad") {
+			t.Fatalf("status = %d, body = %s; want the extracted text", rec.Code, rec.Body)
+		}
+	}
+	if s.ext.FindOutput(doc) == "" {
+		t.Error("the extraction output was not saved")
+	}
+	if rec := get(s, "/api/documents/pdf/c/c.pdf"); !strings.Contains(rec.Body.String(), `"extracted": true`) {
+		t.Errorf("document not reported as extracted: %s", rec.Body)
+	}
+
+	// While a run holds the tree's lock nothing is written, and the request
+	// succeeds once the lock is released
+	doc = filepath.Join(s.root, "pdf", "d", "d.pdf")
+	os.MkdirAll(filepath.Dir(doc), 0o755)
+	if err := os.WriteFile(doc, textPDF("Phone book"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := lock.Acquire(s.root, pathutil.Permissions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(s, "/api/text/pdf/d/d.pdf"); rec.Code != http.StatusConflict {
+		t.Errorf("status while the tree is locked = %d, want 409", rec.Code)
+	}
+	if s.ext.FindOutput(doc) != "" {
+		t.Error("an extraction output was written while the tree was locked")
+	}
+	l.Release()
+	if rec := get(s, "/api/text/pdf/d/d.pdf"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Phone book") {
+		t.Errorf("status after the lock was released = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	// b.pdf is not a valid PDF; its failure is remembered
+	for range 2 {
+		if rec := get(s, "/api/text/pdf/b/b.pdf"); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("status = %d, want 422", rec.Code)
+		}
+	}
+	if _, ok := s.failed[filepath.Join(s.root, "pdf", "b", "b.pdf")]; !ok {
+		t.Error("the failed extraction was not remembered")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := newTestServer(t)
+	keys := []APIKey{{Name: "alice", Key: "alice-key", Rate: 1, Burst: 2}, {Name: "bob", Key: "bob-key"}}
+	s = NewWithOptions(s.root, s.cat, s.ext, Options{APIKeys: keys})
+	now := time.Unix(1_700_000_000, 0)
+	s.limiter = newLimiter(keys, func() time.Time { return now })
+
+	request := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("/api/documents"); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("no key: status = %d, want 401 with WWW-Authenticate", rec.Code)
+	}
+	if rec := request("/api/documents", "X-API-Key", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", rec.Code)
+	}
+	if rec := request("/"); rec.Code != http.StatusOK {
+		t.Errorf("UI: status = %d, want 200 without a key", rec.Code)
+	}
+
+	// alice's burst of 2 is spent by two requests, each way of passing the key
+	if rec := request("/api/documents", "Authorization", "Bearer alice-key"); rec.Code != http.StatusOK {
+		t.Errorf("bearer key: status = %d, want 200", rec.Code)
+	}
+	if rec := request("/api/text/pdf/a/a.pdf?key=alice-key"); rec.Code != http.StatusOK {
+		t.Errorf("key parameter: status = %d, want 200", rec.Code)
+	}
+	rec := request("/api/documents", "X-API-Key", "alice-key")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("over the limit: status = %d, Retry-After = %q; want 429 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// bob has a bucket of his own
+	if rec := request("/api/documents", "X-API-Key", "bob-key"); rec.Code != http.StatusOK {
+		t.Errorf("other key: status = %d, want 200", rec.Code)
+	}
+	// a second later alice has a token again
+	now = now.Add(time.Second)
+	if rec := request("/api/documents", "X-API-Key", "alice-key"); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	for name, tt := range map[string]struct {
+		content string
+		wantErr bool
+	}{
+		"valid":     {`[{"name": "alice", "key": "k1", "rate": 2}, {"name": "bob", "key": "k2"}]`, false},
+		"empty":     {`[]`, true},
+		"no key":    {`[{"name": "alice"}]`, true},
+		"duplicate": {`[{"key": "k1"}, {"key": "k1"}]`, true},
+		"negative":  {`[{"key": "k1", "rate": -1}]`, true},
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "-")+".json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadAPIKeys(path); (err != nil) != tt.wantErr {
+			t.Errorf("%s: LoadAPIKeys() error = %v, want error %v", name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Browser UI for defornicate-server: lists the documents tree, searches the
+// extracted pages and follows exhibit cross-references through the REST API.
+"use strict";
+
+function el(tag, text, attrs) {
+  const node = document.createElement(tag);
+  if (text !== undefined) node.textContent = text;
+  Object.assign(node, attrs || {});
+  return node;
+}
+
+// The API key, when the server requires one, is asked for once and kept in
+// the browser's storage
+const keyStorage = "defornicate-api-key";
+
+// link points at an API URL, passing the key in the query since following a
+// link sends no headers; a #fragment is kept last
+function link(url, label) {
+  const [path, fragment] = url.split("#");
+  let href = path.replace(/^\//, "");
+  const key = localStorage.getItem(keyStorage);
+  if (key) href += "?key=" + encodeURIComponent(key);
+  if (fragment) href += "#" + fragment;
+  return el("a", label, { href });
+}
+
+function textLink(path, label) {
+  return link("api/text/" + path.split("/").map(encodeURIComponent).join("/"), label);
+}
+
+async function getJSON(url) {
+  const key = localStorage.getItem(keyStorage);
+  let resp = await fetch(url, { headers: key ? { "X-API-Key": key } : {} });
+  if (resp.status === 401) {
+    const entered = prompt("This server requires an API key:");
+    if (entered) {
+      localStorage.setItem(keyStorage, entered.trim());
+      resp = await fetch(url, { headers: { "X-API-Key": entered.trim() } });
+    }
+  }
+  const body = await resp.json();
+  if (!resp.ok) throw new Error(body.error || resp.statusText);
+  return body;
+}
+
+// The listing is fetched a page at a time, in the order picked above it
+const listing = { page: 1, perPage: 100 };
+
+async function loadDocuments() {
+  const tbody = document.getElementById("documents");
+  const title = document.getElementById("documents-title");
+  const [sort, order] = document.getElementById("sort").value.split(":");
+  const params = new URLSearchParams({ page: listing.page, per_page: listing.perPage, sort, order });
+  if (document.getElementById("needs-ocr").checked) params.set("needs_ocr", "true");
+  if (document.getElementById("has-redactions").checked) params.set("has_redactions", "true");
+  try {
+    const { documents, total } = await getJSON("api/documents?" + params);
+    tbody.replaceChildren();
+    for (const doc of documents) {
+      const row = el("tr");
+      row.append(
+        el("td", doc.path),
+        el("td", doc.pages ? String(doc.pages) : ""),
+        el("td", doc.downloaded_at ? new Date(doc.downloaded_at).toLocaleString() : ""),
+      );
+      const text = el("td");
+      text.append(doc.extracted ? textLink(doc.path, "view") : el("span", "not extracted", { className: "muted" }));
+      row.append(text);
+      const xrefs = el("td");
+      xrefs.append(el("button", "links", { type: "button", onclick: () => showXrefs(doc.path) }));
+      row.append(xrefs);
+      tbody.append(row);
+    }
+    const pages = Math.max(1, Math.ceil(total / listing.perPage));
+    title.textContent = `Documents (${total})`;
+    document.getElementById("page").textContent = `page ${listing.page} of ${pages}`;
+    document.getElementById("prev").disabled = listing.page <= 1;
+    document.getElementById("next").disabled = listing.page >= pages;
+  } catch (err) {
+    title.textContent = `Documents: ${err.message}`;
+  }
+}
+
+async function runSearch(event) {
+  event.preventDefault();
+  const q = document.getElementById("query").value.trim();
+  const section = document.getElementById("results");
+  const title = document.getElementById("results-title");
+  const list = document.getElementById("hits");
+  if (!q) {
+    section.hidden = true;
+    return;
+  }
+  const params = new URLSearchParams({ q });
+  if (document.getElementById("ignore-case").checked) params.set("ignore_case", "true");
+  section.hidden = false;
+  list.replaceChildren();
+  title.textContent = "Searching…";
+  try {
+    const result = await getJSON("api/search?" + params);
+    title.textContent = `${result.total} matching page(s) in ${result.searched} extracted document(s)` +
+      (result.truncated ? `, showing the first ${result.hits.length}` : "");
+    for (const hit of result.hits) {
+      const item = el("li");
+      // The document itself opens at the page; its text is a link away
+      item.append(link(hit.url, `${hit.document}, page ${hit.page}`), " (", textLink(hit.document, "text"), ")", el("p", hit.snippet));
+      list.append(item);
+    }
+  } catch (err) {
+    title.textContent = `Search failed: ${err.message}`;
+  }
+}
+
+// showXrefs lists the exhibits a document mentions that are in the tree, and
+// the documents mentioning it as an exhibit
+async function showXrefs(path) {
+  const section = document.getElementById("xrefs");
+  const title = document.getElementById("xrefs-title");
+  const references = document.getElementById("references");
+  const referencedBy = document.getElementById("referenced-by");
+  section.hidden = false;
+  references.replaceChildren();
+  referencedBy.replaceChildren();
+  title.textContent = `Cross-references of ${path}…`;
+  try {
+    const result = await getJSON("api/xrefs/" + path.split("/").map(encodeURIComponent).join("/"));
+    title.textContent = `Cross-references of ${path}`;
+    for (const link of result.references) {
+      const item = el("li", `page ${link.page}: "${link.reference}" → `);
+      item.append(textLink(link.target, link.target));
+      references.append(item);
+    }
+    for (const link of result.referenced_by) {
+      const item = el("li");
+      item.append(textLink(link.document, `${link.document}, page ${link.page}`), ` ("${link.reference}")`);
+      referencedBy.append(item);
+    }
+    if (!result.references.length) references.append(el("li", "none", { className: "muted" }));
+    if (!result.referenced_by.length) referencedBy.append(el("li", "none", { className: "muted" }));
+  } catch (err) {
+    title.textContent = `Cross-references failed: ${err.message}`;
+  }
+}
+
+document.getElementById("search").addEventListener("submit", runSearch);
+for (const id of ["sort", "needs-ocr", "has-redactions"]) {
+  document.getElementById(id).addEventListener("change", () => { listing.page = 1; loadDocuments(); });
+}
+document.getElementById("prev").addEventListener("click", () => { listing.page--; loadDocuments(); });
+document.getElementById("next").addEventListener("click", () => { listing.page++; loadDocuments(); });
+loadDocuments();
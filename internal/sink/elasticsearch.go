@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// elasticsearch indexes outputs through the Elasticsearch document API
+type elasticsearch struct {
+	client  *http.Client
+	baseURL string
+	index   string
+}
+
+// Elasticsearch returns a sink indexing each output as a document of index,
+// keyed by its output filename so re-extraction replaces the old document
+func Elasticsearch(baseURL, index string) (Sink, error) {
+	if baseURL == "" || index == "" {
+		return nil, fmt.Errorf("elasticsearch sink needs a url and an index")
+	}
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch url: %w", err)
+	}
+	return &elasticsearch{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimRight(baseURL, "/"),
+		index:   index,
+	}, nil
+}
+
+func (s *elasticsearch) Write(out *extractor.Output) (string, error) {
+	docURL := fmt.Sprintf("%s/%s/_doc/%s", s.baseURL, url.PathEscape(s.index), url.PathEscape(filepath.Base(out.Path)))
+	req, err := http.NewRequest(http.MethodPut, docURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	// JSON outputs are indexed as they are, streamed into the request; other
+	// formats are wrapped
+	if out.JSON != nil {
+		pr, pw := io.Pipe()
+		go func() { pw.CloseWithError(out.WriteFormatted(pw)) }()
+		req.Body = pr
+	} else {
+		wrapped, err := json.Marshal(map[string]string{
+			"filename": filepath.Base(out.Document),
+			"text":     string(out.Formatted),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode document: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(wrapped))
+		req.ContentLength = int64(len(wrapped))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to index document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("failed to index document: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return docURL, nil
+}
@@ -0,0 +1,220 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"defornicate-epstein-files/internal/extractor"
+)
+
+// Credentials sign requests to an object store: an AWS access key, or a
+// Google Cloud Storage HMAC key
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // temporary AWS credentials only
+}
+
+// credentialsFromEnv returns the credentials of a sink of type sinkType from
+// the environment, so they stay out of the config file: the usual AWS_*
+// variables for S3, and the GCS_HMAC_* variables for an HMAC key of Google
+// Cloud Storage
+func credentialsFromEnv(sinkType string) Credentials {
+	if sinkType == TypeGCS {
+		return Credentials{AccessKeyID: os.Getenv("GCS_HMAC_ACCESS_ID"), SecretAccessKey: os.Getenv("GCS_HMAC_SECRET")}
+	}
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// objectStore uploads outputs through the S3 API, which Google Cloud Storage
+// also serves
+type objectStore struct {
+	client   *http.Client
+	scheme   string // of the locations reported, "s3" or "gs"
+	endpoint string
+	region   string
+	bucket   string
+	prefix   string
+	creds    Credentials
+	now      func() time.Time
+}
+
+// S3 returns a sink uploading each output to bucket, under prefix, keyed by
+// its output filename like the files a filesystem sink writes. endpoint
+// defaults to AWS's endpoint for region; set it for other S3-compatible
+// stores.
+func S3(endpoint, region, bucket, prefix string, creds Credentials) (Sink, error) {
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return newObjectStore("s3", endpoint, region, bucket, prefix, creds)
+}
+
+// GCS returns a sink uploading each output to a Google Cloud Storage bucket
+// through its S3-compatible XML API, signed with an HMAC key
+func GCS(bucket, prefix string, creds Credentials) (Sink, error) {
+	return newObjectStore("gs", "https://storage.googleapis.com", "auto", bucket, prefix, creds)
+}
+
+func newObjectStore(scheme, endpoint, region, bucket, prefix string, creds Credentials) (*objectStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("%s sink needs a bucket", scheme)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s sink needs an access key and its secret", scheme)
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s endpoint %q", scheme, endpoint)
+	}
+	return &objectStore{
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		scheme:   scheme,
+		endpoint: strings.TrimRight(endpoint, "/"),
+		region:   region,
+		bucket:   bucket,
+		prefix:   strings.Trim(prefix, "/"),
+		creds:    creds,
+		now:      time.Now,
+	}, nil
+}
+
+func (s *objectStore) Write(out *extractor.Output) (string, error) {
+	key := path.Join(s.prefix, filepath.Base(out.Path))
+	// Shards go first so their index never points at missing objects
+	for i, shard := range out.Shards {
+		if err := s.put(path.Join(s.prefix, filepath.Base(extractor.ShardPath(out.Path, i+1))), shard); err != nil {
+			return "", err
+		}
+	}
+	if err := s.put(key, out); err != nil {
+		return "", err
+	}
+	return s.scheme + "://" + s.bucket + "/" + key, nil
+}
+
+// put uploads the stored form of out as the object key
+func (s *objectStore) put(key string, out *extractor.Output) error {
+	// The payload is signed by its hash, so it is encoded before sending
+	var body bytes.Buffer
+	if err := out.WriteContent(&body); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	objectURL := s.endpoint + "/" + uriEncode(s.bucket, false) + "/" + uriEncode(key, true)
+	req, err := http.NewRequest(http.MethodPut, objectURL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	payloadHash := sha256.Sum256(body.Bytes())
+	signV4(req, hex.EncodeToString(payloadHash[:]), s.creds, s.region, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to upload %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// signV4 signs req for the S3 service with AWS Signature Version 4, covering
+// its host and every header already set on it
+func signV4(req *http.Request, payloadHash string, creds Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns query sorted and encoded as Signature Version 4
+// expects
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, uriEncode(name, false)+"="+uriEncode(v, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters
+// (and slashes, when keepSlash is set), as Signature Version 4 expects
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,196 @@
+// Package sink routes extraction outputs to their destinations. The
+// filesystem is the default; other destinations (stdout, Elasticsearch, S3
+// and Google Cloud Storage) are configured declaratively with "sinks" in the config file.
+package sink
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/pathutil"
+)
+
+// Sink types accepted in the config file
+const (
+	TypeFilesystem    = "filesystem"
+	TypeStdout        = "stdout"
+	TypeElasticsearch = "elasticsearch"
+	TypeS3            = "s3"
+	TypeGCS           = "gcs"
+)
+
+// Sink writes extraction outputs to a destination
+type Sink interface {
+	// Write stores one output and returns where it was written (a path or
+	// URL) for progress messages and the catalog
+	Write(out *extractor.Output) (string, error)
+}
+
+// fieldRe matches a {field} placeholder of a name template
+var fieldRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// filesystem writes outputs as files
+type filesystem struct {
+	dir   string
+	name  string
+	perms pathutil.Permissions
+}
+
+// Filesystem returns a sink writing outputs next to their documents, or into
+// dir when it is not empty. A name template such as "EFTA-{efta_number}"
+// replaces the document's name in output filenames: {name} is the document's
+// name and any other {field} is a field of its curated metadata. Outputs of
+// documents lacking a field keep their usual name.
+func Filesystem(dir, name string, perms pathutil.Permissions) Sink {
+	return &filesystem{dir: dir, name: name, perms: perms}
+}
+
+func (s *filesystem) Write(out *extractor.Output) (string, error) {
+	if s.dir != "" {
+		if err := s.perms.MkdirAll(s.dir); err != nil {
+			return "", fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	path, changed, err := out.Destination(s.outputPath(out, out.Path))
+	if err != nil || !changed {
+		return path, err
+	}
+	report, err := out.PageDiffReport(s.outputPath(out, out.Path))
+	if err != nil {
+		return "", err
+	}
+	// Shards go first so their index never points at missing files
+	for i, shard := range out.Shards {
+		if err := shard.WriteFile(extractor.ShardPath(path, i+1), s.perms); err != nil {
+			return "", fmt.Errorf("failed to write extracted text file: %w", err)
+		}
+	}
+	if err := out.WriteFile(path, s.perms); err != nil {
+		return "", fmt.Errorf("failed to write extracted text file: %w", err)
+	}
+	if report != nil {
+		if err := s.perms.WriteFile(extractor.DiffPath(path), report); err != nil {
+			return "", fmt.Errorf("failed to write page diff report: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// outputPath returns where to write the file of out (its own or one of its
+// shards) whose default location is path
+func (s *filesystem) outputPath(out *extractor.Output, path string) string {
+	name := s.outputName(out, filepath.Base(path))
+	if s.dir != "" {
+		return filepath.Join(s.dir, name)
+	}
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+// outputName returns the filename base of a file of out, with the document's
+// name replaced by the sink's name template when it has one
+func (s *filesystem) outputName(out *extractor.Output, base string) string {
+	if s.name == "" {
+		return base
+	}
+	doc := filepath.Base(out.Document)
+	stem := strings.TrimSuffix(doc, filepath.Ext(doc))
+	suffix, ok := strings.CutPrefix(base, stem) // ".extracted.json", ".extracted.part-01.json", ...
+	if !ok {
+		return base
+	}
+	missing := false
+	name := fieldRe.ReplaceAllStringFunc(s.name, func(placeholder string) string {
+		field := placeholder[1 : len(placeholder)-1]
+		if field == "name" {
+			return stem
+		}
+		value, ok := out.Fields[field]
+		if !ok {
+			missing = true
+			return ""
+		}
+		return strings.NewReplacer("/", "-", `\`, "-").Replace(fmt.Sprint(value))
+	})
+	if missing || name == "" {
+		return base
+	}
+	return name + suffix
+}
+
+// stdout writes uncompressed outputs to a stream
+type stdout struct {
+	mu sync.Mutex // keeps outputs from parallel workers apart
+	w  io.Writer
+}
+
+// Stdout returns a sink writing each output, uncompressed, to w
+func Stdout(w io.Writer) Sink {
+	return &stdout{w: w}
+}
+
+func (s *stdout) Write(out *extractor.Output) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := out.WriteFormatted(s.w); err != nil {
+		return "", fmt.Errorf("failed to write extracted text: %w", err)
+	}
+	if _, err := io.WriteString(s.w, "\n"); err != nil {
+		return "", fmt.Errorf("failed to write extracted text: %w", err)
+	}
+	return "stdout", nil
+}
+
+// FromConfig builds the sinks listed in the config file, with stdout sinks
+// writing to w. With none listed, outputs are written next to their
+// documents.
+func FromConfig(sinks []config.SinkConfig, perms pathutil.Permissions, w io.Writer) ([]Sink, error) {
+	if len(sinks) == 0 {
+		return []Sink{Filesystem("", "", perms)}, nil
+	}
+	var built []Sink
+	for i, sc := range sinks {
+		switch sc.Type {
+		case TypeFilesystem:
+			built = append(built, Filesystem(sc.Dir, sc.Name, perms))
+		case TypeStdout:
+			built = append(built, Stdout(w))
+		case TypeElasticsearch:
+			es, err := Elasticsearch(sc.URL, sc.Index)
+			if err != nil {
+				return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+			}
+			built = append(built, es)
+		case TypeS3:
+			s3, err := S3(sc.URL, sc.Region, sc.Bucket, sc.Prefix, credentialsFromEnv(sc.Type))
+			if err != nil {
+				return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+			}
+			built = append(built, s3)
+		case TypeGCS:
+			gcs, err := GCS(sc.Bucket, sc.Prefix, credentialsFromEnv(sc.Type))
+			if err != nil {
+				return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+			}
+			built = append(built, gcs)
+		default:
+			return nil, fmt.Errorf("sinks[%d]: unknown sink type %q", i, sc.Type)
+		}
+	}
+	return built, nil
+}
+
+// HasStdout reports whether any of the configured sinks writes to stdout
+func HasStdout(sinks []config.SinkConfig) bool {
+	for _, sc := range sinks {
+		if sc.Type == TypeStdout {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/meta"
+)
+
+const (
+	// DefaultCourtListenerURL is the CourtListener REST API
+	DefaultCourtListenerURL = "https://www.courtlistener.com/api/rest/v4/"
+	// DefaultRECAPStorageURL is where RECAP documents' filepath_local is
+	// relative to
+	DefaultRECAPStorageURL = "https://storage.courtlistener.com/"
+)
+
+// CourtListenerOptions configures the CourtListener/RECAP source
+type CourtListenerOptions struct {
+	Dockets    []string // docket numbers, e.g. "1:15-cv-07433"
+	Court      string   // CourtListener court ID, e.g. "nysd" (default: any court)
+	Token      string   // API token, sent as "Authorization: Token ..."
+	APIURL     string   // default: DefaultCourtListenerURL
+	StorageURL string   // default: DefaultRECAPStorageURL
+	UserAgent  string   // default: downloader.DefaultUserAgent
+}
+
+// courtListenerSource provides the RECAP documents filed on dockets
+type courtListenerSource struct {
+	dl     *downloader.Downloader
+	opts   CourtListenerOptions
+	client *http.Client
+}
+
+// CourtListener returns a source for the PDFs of the given dockets that the
+// RECAP archive holds. Each item carries the docket's metadata (case name,
+// court, entry number, description, filing date) as its Meta.
+func CourtListener(dl *downloader.Downloader, opts CourtListenerOptions) Source {
+	if opts.APIURL == "" {
+		opts.APIURL = DefaultCourtListenerURL
+	}
+	if opts.StorageURL == "" {
+		opts.StorageURL = DefaultRECAPStorageURL
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = downloader.DefaultUserAgent
+	}
+	return &courtListenerSource{dl: dl, opts: opts, client: &http.Client{Timeout: downloader.DefaultTimeout}}
+}
+
+// clDocket is a docket as returned by the dockets endpoint
+type clDocket struct {
+	ID           int    `json:"id"`
+	CaseName     string `json:"case_name"`
+	DocketNumber string `json:"docket_number"`
+	CourtID      string `json:"court_id"`
+	AbsoluteURL  string `json:"absolute_url"`
+}
+
+// clEntry is a docket entry with its RECAP documents
+type clEntry struct {
+	EntryNumber    *int         `json:"entry_number"`
+	DateFiled      string       `json:"date_filed"`
+	Description    string       `json:"description"`
+	RECAPDocuments []clDocument `json:"recap_documents"`
+}
+
+// clDocument is a main document or attachment of a docket entry
+type clDocument struct {
+	DocumentNumber   string `json:"document_number"`
+	AttachmentNumber *int   `json:"attachment_number"`
+	Description      string `json:"description"`
+	FilepathLocal    string `json:"filepath_local"`
+	IsAvailable      bool   `json:"is_available"`
+	PageCount        *int   `json:"page_count"`
+}
+
+// clPage is one page of a paginated API response
+type clPage[T any] struct {
+	Next    string `json:"next"`
+	Results []T    `json:"results"`
+}
+
+func (s *courtListenerSource) Resolve() ([]Item, error) {
+	var items []Item
+	for _, number := range s.opts.Dockets {
+		query := url.Values{"docket_number": {number}}
+		if s.opts.Court != "" {
+			query.Set("court", s.opts.Court)
+		}
+		dockets, err := getAll[clDocket](s, "dockets/?"+query.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up docket %s: %w", number, err)
+		}
+		if len(dockets) == 0 {
+			return nil, fmt.Errorf("docket %s not found on CourtListener", number)
+		}
+		for _, docket := range dockets {
+			entries, err := getAll[clEntry](s, fmt.Sprintf("docket-entries/?docket=%d&order_by=entry_number", docket.ID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list entries of docket %s: %w", number, err)
+			}
+			for _, entry := range entries {
+				for _, doc := range entry.RECAPDocuments {
+					if !doc.IsAvailable || doc.FilepathLocal == "" {
+						continue // not purchased into RECAP yet
+					}
+					docURL, err := resolveURL(s.opts.StorageURL, doc.FilepathLocal)
+					if err != nil {
+						return nil, fmt.Errorf("invalid RECAP file path %q: %w", doc.FilepathLocal, err)
+					}
+					items = append(items, Item{
+						Input: docURL,
+						URL:   downloader.CanonicalURL(docURL),
+						Meta:  s.docketMeta(docket, entry, doc),
+						src:   s,
+					})
+				}
+			}
+		}
+	}
+	return items, nil
+}
+
+func (s *courtListenerSource) Fetch(item Item) (io.ReadCloser, error) {
+	return s.dl.Open(item.Input)
+}
+
+// docketMeta describes a RECAP document for its meta.yaml
+func (s *courtListenerSource) docketMeta(docket clDocket, entry clEntry, doc clDocument) *meta.Meta {
+	fields := map[string]any{
+		"source":        "courtlistener",
+		"case_name":     docket.CaseName,
+		"docket_number": docket.DocketNumber,
+		"court":         docket.CourtID,
+	}
+	if entry.EntryNumber != nil {
+		fields["entry_number"] = *entry.EntryNumber
+	}
+	if doc.DocumentNumber != "" {
+		fields["document_number"] = doc.DocumentNumber
+	}
+	if doc.AttachmentNumber != nil {
+		fields["attachment_number"] = *doc.AttachmentNumber
+	}
+	if entry.DateFiled != "" {
+		fields["date_filed"] = entry.DateFiled
+	}
+	if doc.PageCount != nil {
+		fields["page_count"] = *doc.PageCount
+	}
+	if docket.AbsoluteURL != "" {
+		if docketURL, err := resolveURL(s.opts.APIURL, docket.AbsoluteURL); err == nil {
+			fields["docket_url"] = docketURL
+		}
+	}
+	title := strings.TrimSpace(doc.Description)
+	if title == "" {
+		title = strings.TrimSpace(entry.Description)
+	}
+	return &meta.Meta{Title: title, Fields: fields}
+}
+
+// getAll fetches every page of an API listing, following "next" links
+func getAll[T any](s *courtListenerSource, path string) ([]T, error) {
+	next, err := resolveURL(s.opts.APIURL, path)
+	if err != nil {
+		return nil, err
+	}
+	var all []T
+	for next != "" {
+		var page clPage[T]
+		if err := s.getJSON(next, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Results...)
+		next = page.Next
+	}
+	return all, nil
+}
+
+// getJSON fetches an API URL and decodes its JSON body into v
+func (s *courtListenerSource) getJSON(apiURL string, v any) error {
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", s.opts.UserAgent)
+	if s.opts.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.opts.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query CourtListener: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("CourtListener refused the request (%s); set courtlistener.token or COURTLISTENER_TOKEN", resp.Status)
+		}
+		return &downloader.StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse CourtListener response: %w", err)
+	}
+	return nil
+}
+
+// resolveURL resolves ref (a path or absolute URL) against base
+func resolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
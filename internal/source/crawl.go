@@ -0,0 +1,95 @@
+package source
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// maxIndexPageSize caps the index pages read for links
+const maxIndexPageSize = 16 << 20
+
+// linkRe matches the target of an href attribute, quoted or not
+var linkRe = regexp.MustCompile(`(?i)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// crawlSource provides the documents linked from index pages
+type crawlSource struct {
+	dl         *downloader.Downloader
+	pages      []string
+	extensions []string // of the documents taken; nil for every supported document
+}
+
+// Crawl returns a source for the documents that the given index pages link
+// to, such as a court's or agency's listing of released files. Pages are
+// fetched through the downloader and their links followed one level deep:
+// only links to files with one of extensions (every supported document if
+// nil) are taken, in the order they appear.
+func Crawl(dl *downloader.Downloader, pages []string, extensions []string) Source {
+	return &crawlSource{dl: dl, pages: pages, extensions: extensions}
+}
+
+func (s *crawlSource) Resolve() ([]Item, error) {
+	var items []Item
+	seen := make(map[string]bool)
+	for _, page := range s.pages {
+		links, err := s.links(page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to crawl %s: %w", page, err)
+		}
+		for _, link := range links {
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			items = append(items, Item{
+				Input: link,
+				URL:   downloader.CanonicalURL(link),
+				Meta:  &meta.Meta{Fields: map[string]any{"source": "crawl", "index_page": page}},
+				src:   s,
+			})
+		}
+	}
+	return items, nil
+}
+
+func (s *crawlSource) Fetch(item Item) (io.ReadCloser, error) {
+	return s.dl.Open(item.Input)
+}
+
+// links returns the absolute URLs of the documents page links to
+func (s *crawlSource) links(page string) ([]string, error) {
+	base, err := url.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.dl.Open(page)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(io.LimitReader(body, maxIndexPageSize))
+	if err != nil {
+		return nil, err
+	}
+	var links []string
+	for _, m := range linkRe.FindAllStringSubmatch(string(content), -1) {
+		ref, err := url.Parse(strings.TrimSpace(html.UnescapeString(m[1] + m[2] + m[3])))
+		if err != nil {
+			continue
+		}
+		link := base.ResolveReference(ref)
+		link.Fragment = ""
+		if !downloader.IsURL(link.String()) || !isDocument(path.Base(link.Path), s.extensions) {
+			continue
+		}
+		links = append(links, link.String())
+	}
+	return links, nil
+}
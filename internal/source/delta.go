@@ -0,0 +1,58 @@
+package source
+
+// Delta splits a resolved input list into the documents already known and
+// those that have newly appeared, such as the IDs added when a release range
+// is extended
+type Delta struct {
+	New   []Item
+	Known []Item
+}
+
+// Run is a stretch of consecutive new items in the input list, by input
+type Run struct {
+	First string
+	Last  string
+	Count int
+}
+
+// Diff splits items by whether known reports them as already in the corpus,
+// keeping their order
+func Diff(items []Item, known func(Item) bool) Delta {
+	var d Delta
+	for _, item := range items {
+		if known(item) {
+			d.Known = append(d.Known, item)
+		} else {
+			d.New = append(d.New, item)
+		}
+	}
+	return d
+}
+
+// Runs groups the new items into stretches that are consecutive in the input
+// list, so the new IDs of an extended pattern range read as one run rather
+// than a list of every ID. Inputs are compared by their position in items,
+// the list Diff was given.
+func (d Delta) Runs(items []Item) []Run {
+	isNew := make(map[string]bool, len(d.New))
+	for _, item := range d.New {
+		isNew[item.Key()] = true
+	}
+	var runs []Run
+	open := false
+	for _, item := range items {
+		if !isNew[item.Key()] {
+			open = false
+			continue
+		}
+		if open {
+			last := &runs[len(runs)-1]
+			last.Last = item.Input
+			last.Count++
+			continue
+		}
+		runs = append(runs, Run{First: item.Input, Last: item.Input, Count: 1})
+		open = true
+	}
+	return runs
+}
@@ -0,0 +1,58 @@
+package source
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard is one of Count disjoint slices of an input list, numbered from 1, so
+// several machines can work through the same list without coordinating
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parses "i/n", e.g. "2/8" for the second of eight shards
+func ParseShard(s string) (Shard, error) {
+	indexStr, countStr, ok := strings.Cut(s, "/")
+	index, indexErr := strconv.Atoi(indexStr)
+	count, countErr := strconv.Atoi(countStr)
+	if !ok || indexErr != nil || countErr != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q (expected i/n, e.g. 2/8)", s)
+	}
+	if count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("invalid shard %q: i must be between 1 and n", s)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// String formats the shard as "i/n"
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// Contains reports whether the document identified by key (see Item.Key)
+// belongs to the shard. Membership depends only on the key, not on the order
+// or length of the list, so every machine assigns an input to the same shard
+// even when their lists were expanded differently.
+func (s Shard) Contains(key string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int(h.Sum64()%uint64(s.Count)) == s.Index-1
+}
+
+// Items returns the items that belong to the shard, in their original order
+func (s Shard) Items(items []Item) []Item {
+	var kept []Item
+	for _, item := range items {
+		if s.Contains(item.Key()) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,294 @@
+// Package source defines where documents come from. Every input backend
+// (remote URLs, local files, patterns, presets, CourtListener dockets,
+// torrents and crawled index pages) implements Source, so callers
+// resolve and fetch items without special-casing URL schemes or paths.
+package source
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"defornicate-epstein-files/internal/config"
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+	"defornicate-epstein-files/internal/pathutil"
+	"defornicate-epstein-files/internal/pattern"
+)
+
+// Source lists documents and fetches their content
+type Source interface {
+	// Resolve lists the documents the source provides
+	Resolve() ([]Item, error)
+	// Fetch opens the content of one of the source's items
+	Fetch(item Item) (io.ReadCloser, error)
+}
+
+// Item is one document provided by a source
+type Item struct {
+	Input string     // as listed by the user or expanded from a pattern
+	URL   string     // canonical URL of a remote document, "" for local files
+	Path  string     // resolved path of a local document, "" for remote ones
+	Meta  *meta.Meta // metadata the source knows about the document, if any
+
+	src Source // the source that fetches the item
+}
+
+// Remote reports whether the item has to be downloaded
+func (i Item) Remote() bool {
+	return i.URL != ""
+}
+
+// Key identifies the document an item refers to, so the same document
+// listed twice can be recognized
+func (i Item) Key() string {
+	if i.Remote() {
+		return i.URL
+	}
+	return filepath.Clean(i.Path)
+}
+
+// Fetch opens the item's content through the source that provided it
+func (i Item) Fetch() (io.ReadCloser, error) {
+	if i.src == nil {
+		return nil, fmt.Errorf("%s has no source to fetch it from", i.Input)
+	}
+	return i.src.Fetch(i)
+}
+
+// remoteSource provides documents fetched by the downloader (http, https,
+// ftp, sftp, s3, ia)
+type remoteSource struct {
+	dl   *downloader.Downloader
+	urls []string
+}
+
+// Remote returns a source for the given URLs
+func Remote(dl *downloader.Downloader, urls ...string) Source {
+	return &remoteSource{dl: dl, urls: urls}
+}
+
+func (s *remoteSource) Resolve() ([]Item, error) {
+	items := make([]Item, len(s.urls))
+	for i, url := range s.urls {
+		items[i] = Item{Input: url, URL: downloader.CanonicalURL(url), src: s}
+	}
+	return items, nil
+}
+
+func (s *remoteSource) Fetch(item Item) (io.ReadCloser, error) {
+	return s.dl.Open(item.Input)
+}
+
+// localSource provides documents already on disk
+type localSource struct {
+	root       string
+	paths      []string
+	extensions []string // of the files taken from directories; nil for every supported document
+}
+
+// Local returns a source for local files. Bare filenames are also looked up
+// in the default documents tree.
+func Local(paths ...string) Source {
+	return LocalIn(pathutil.DefaultDocumentsDir, paths...)
+}
+
+// LocalIn returns a source for local files whose bare filenames are looked
+// up in the documents tree at root. Directories stand for every supported
+// document under them.
+func LocalIn(root string, paths ...string) Source {
+	return &localSource{root: root, paths: paths}
+}
+
+func (s *localSource) Resolve() ([]Item, error) {
+	items := make([]Item, 0, len(s.paths))
+	for _, path := range s.paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			found, err := s.walk(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, doc := range found {
+				items = append(items, Item{Input: doc, Path: doc, src: s})
+			}
+			continue
+		}
+		items = append(items, Item{Input: path, Path: pathutil.ResolveDocumentPathIn(s.root, path), src: s})
+	}
+	return items, nil
+}
+
+// walk returns the documents under dir, in lexical order: the files with one
+// of the source's extensions, or that the extractor supports. Hidden
+// directories (snapshots, quarantined downloads, version control) and
+// extraction outputs are skipped.
+func (s *localSource) walk(dir string) ([]string, error) {
+	var docs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || strings.Contains(name, ".extracted.") || !s.accepts(name) {
+			return nil
+		}
+		docs = append(docs, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search directory %s: %w", dir, err)
+	}
+	return docs, nil
+}
+
+// accepts reports whether a file found under a directory input is a document
+func (s *localSource) accepts(name string) bool {
+	return isDocument(name, s.extensions)
+}
+
+// isDocument reports whether the file name has one of extensions, or is a
+// document the extractor supports if extensions is nil
+func isDocument(name string, extensions []string) bool {
+	if extensions == nil {
+		return extractor.IsSupported(name)
+	}
+	ext := filepath.Ext(name)
+	for _, want := range extensions {
+		if strings.EqualFold(ext, "."+strings.TrimPrefix(want, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *localSource) Fetch(item Item) (io.ReadCloser, error) {
+	return os.Open(item.Path)
+}
+
+// Inputs returns a source for a mixed list of URLs and local paths, such as
+// command-line arguments or the urls of the config file. Bare filenames are
+// looked up in the downloader's documents tree, directories are searched
+// recursively for supported documents, and .torrent files stand for the
+// documents of their torrent.
+func Inputs(dl *downloader.Downloader, inputs []string) Source {
+	return InputsWithExtensions(dl, inputs, nil)
+}
+
+// InputsWithExtensions is like Inputs, taking only the files with one of the
+// given extensions (such as ".pdf") from directories, or every supported
+// document if extensions is nil
+func InputsWithExtensions(dl *downloader.Downloader, inputs []string, extensions []string) Source {
+	root := pathutil.DefaultDocumentsDir
+	if dl != nil {
+		root = dl.DocumentsDir()
+	}
+	var sources multi
+	for _, input := range inputs {
+		if IsTorrent(input) {
+			sources = append(sources, Torrent(dl, input))
+		} else if downloader.IsURL(input) {
+			sources = append(sources, Remote(dl, input))
+		} else {
+			sources = append(sources, &localSource{root: root, paths: []string{input}, extensions: extensions})
+		}
+	}
+	return sources
+}
+
+// patternSource provides the documents named by a sequential pattern
+type patternSource struct {
+	dl      *downloader.Downloader
+	pattern string
+}
+
+// Pattern returns a source for the URLs or filenames a pattern such as
+// "EFTA{00010724-00010730}.pdf" expands to
+func Pattern(dl *downloader.Downloader, p string) Source {
+	return &patternSource{dl: dl, pattern: p}
+}
+
+// Resolve expands the pattern. The variables captured for each document (its
+// number, e.g. efta_number, and any named groups) become the fields of its
+// metadata, so they are saved in its meta.yaml and the catalog.
+func (s *patternSource) Resolve() ([]Item, error) {
+	expansions, err := pattern.ExpandPatternVars(s.pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand pattern: %w", err)
+	}
+	expanded := make([]string, len(expansions))
+	for i, expansion := range expansions {
+		expanded[i] = expansion.Value
+	}
+	items, err := Inputs(s.dl, expanded).Resolve()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if vars := expansions[i].Vars; len(vars) > 0 {
+			items[i].Meta = &meta.Meta{Fields: vars}
+		}
+	}
+	return items, nil
+}
+
+func (s *patternSource) Fetch(item Item) (io.ReadCloser, error) {
+	return item.Fetch() // items are resolved with their own source
+}
+
+// Preset returns a source for the documents of a built-in preset
+func Preset(dl *downloader.Downloader, preset config.Preset) Source {
+	var sources multi
+	for _, p := range preset.Patterns {
+		sources = append(sources, Pattern(dl, p))
+	}
+	return sources
+}
+
+// multi concatenates sources
+type multi []Source
+
+// Multi returns a source providing the items of each source in turn
+func Multi(sources ...Source) Source {
+	return multi(sources)
+}
+
+func (m multi) Resolve() ([]Item, error) {
+	var items []Item
+	for _, s := range m {
+		resolved, err := s.Resolve()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, resolved...)
+	}
+	return items, nil
+}
+
+func (m multi) Fetch(item Item) (io.ReadCloser, error) {
+	return item.Fetch()
+}
+
+// Dedupe removes items referring to a document already listed, keeping the
+// first occurrence, and returns how many were removed
+func Dedupe(items []Item) ([]Item, int) {
+	seen := make(map[string]bool, len(items))
+	var unique []Item
+	for _, item := range items {
+		if seen[item.Key()] {
+			continue
+		}
+		seen[item.Key()] = true
+		unique = append(unique, item)
+	}
+	return unique, len(items) - len(unique)
+}
@@ -0,0 +1,432 @@
+package source
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/downloader"
+)
+
+func TestInputsClassifiesURLsAndPaths(t *testing.T) {
+	items, err := Inputs(nil, []string{"HTTPS://Example.com/a.pdf", "docs/b.pdf"}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Resolve() returned %d items, want 2", len(items))
+	}
+	if !items[0].Remote() || items[0].URL != "https://example.com/a.pdf" {
+		t.Errorf("items[0] = %+v, want remote item with canonical URL", items[0])
+	}
+	if items[1].Remote() || items[1].Path == "" {
+		t.Errorf("items[1] = %+v, want local item with a path", items[1])
+	}
+}
+
+func TestPatternResolve(t *testing.T) {
+	items, err := Pattern(nil, "https://example.com/EFTA{00000001-00000003}.pdf").Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Resolve() returned %d items, want 3", len(items))
+	}
+	if items[2].Input != "https://example.com/EFTA00000003.pdf" {
+		t.Errorf("items[2].Input = %q", items[2].Input)
+	}
+	if items[2].Meta == nil || items[2].Meta.Fields["efta_number"] != 3 {
+		t.Errorf("items[2].Meta = %+v, want efta_number 3", items[2].Meta)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	items, _ := Inputs(nil, []string{
+		"https://example.com/a.pdf",
+		"https://EXAMPLE.com/a.pdf",
+		"docs/b.pdf",
+		"docs/./b.pdf",
+		"docs/c.pdf",
+	}).Resolve()
+
+	unique, removed := Dedupe(items)
+	if removed != 2 || len(unique) != 3 {
+		t.Fatalf("Dedupe() kept %d and removed %d, want 3 and 2", len(unique), removed)
+	}
+	if unique[0].Input != "https://example.com/a.pdf" {
+		t.Errorf("Dedupe() kept %q, want the first occurrence", unique[0].Input)
+	}
+}
+
+func TestLocalFetch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := Local(path).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	r, err := items[0].Fetch()
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	if string(data) != "%PDF-1.4" {
+		t.Errorf("Fetch() read %q", data)
+	}
+}
+
+func TestLocalInLooksUpBareNamesUnderRoot(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "pdf", "EFTA00010724", "EFTA00010724.pdf")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, err := LocalIn(root, "EFTA00010724.pdf").Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if items[0].Path != path {
+		t.Errorf("Path = %q, want %q", items[0].Path, path)
+	}
+}
+
+func TestInputsSearchesDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"DataSet 8/EFTA00010724.pdf",
+		"DataSet 8/EFTA00010724.extracted.json",
+		"DataSet 8/meta.yaml",
+		"DataSet 9/photos/IMG_0001.JPG",
+		".snapshots/before/EFTA00010724.pdf",
+		"notes.txt",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := Inputs(nil, []string{dir, "https://example.org/a.pdf"}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Key())
+	}
+	want := []string{
+		filepath.Join(dir, "DataSet 8", "EFTA00010724.pdf"),
+		filepath.Join(dir, "DataSet 9", "photos", "IMG_0001.JPG"),
+		"https://example.org/a.pdf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %q, want %q", got, want)
+	}
+
+	items, err = InputsWithExtensions(nil, []string{dir}, []string{"jpg"}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(items) != 1 || filepath.Base(items[0].Path) != "IMG_0001.JPG" {
+		t.Errorf("items with extension filter = %+v, want the photo only", items)
+	}
+}
+
+func TestShardPartitionsItems(t *testing.T) {
+	items, err := Pattern(nil, "https://example.com/EFTA{00000001-00000100}.pdf").Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	for i := 1; i <= 4; i++ {
+		shard, err := ParseShard(fmt.Sprintf("%d/4", i))
+		if err != nil {
+			t.Fatalf("ParseShard() error = %v", err)
+		}
+		part := shard.Items(items)
+		if len(part) == 0 || len(part) == len(items) {
+			t.Errorf("shard %s has %d of %d items, want a share", shard, len(part), len(items))
+		}
+		for _, item := range part {
+			seen[item.Key()]++
+		}
+		// Membership must not depend on the rest of the list
+		if got := shard.Items(items[50:]); len(got) > len(part) || (len(got) > 0 && !shard.Contains(got[0].Key())) {
+			t.Errorf("shard %s changed membership for a shorter list", shard)
+		}
+	}
+	if len(seen) != len(items) {
+		t.Errorf("shards cover %d of %d items", len(seen), len(items))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("%s is in %d shards, want exactly one", key, n)
+		}
+	}
+}
+
+func TestParseShardErrors(t *testing.T) {
+	for _, s := range []string{"", "1", "0/4", "5/4", "1/0", "a/b", "1/4/2"} {
+		if _, err := ParseShard(s); err == nil {
+			t.Errorf("ParseShard(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestCourtListenerResolve(t *testing.T) {
+	var server *httptest.Server
+	var token string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		switch {
+		case r.URL.Path == "/api/dockets/" && r.URL.Query().Get("docket_number") == "1:15-cv-07433":
+			fmt.Fprint(w, `{"next": null, "results": [{"id": 4355835, "case_name": "Giuffre v. Maxwell",
+				"docket_number": "1:15-cv-07433", "court_id": "nysd", "absolute_url": "/docket/4355835/giuffre-v-maxwell/"}]}`)
+		case r.URL.Path == "/api/dockets/":
+			fmt.Fprint(w, `{"next": null, "results": []}`)
+		case r.URL.Path == "/api/docket-entries/" && r.URL.Query().Get("page") == "":
+			fmt.Fprintf(w, `{"next": "%s/api/docket-entries/?docket=4355835&page=2", "results": [{"entry_number": 1,
+				"date_filed": "2015-09-21", "description": "COMPLAINT", "recap_documents": [
+				{"document_number": "1", "attachment_number": null, "description": "", "filepath_local": "recap/gov.uscourts.nysd.447706.1.0.pdf", "is_available": true, "page_count": 12},
+				{"document_number": "1", "attachment_number": 1, "description": "Exhibit A", "filepath_local": "", "is_available": false}]}]}`, server.URL)
+		case r.URL.Path == "/api/docket-entries/":
+			fmt.Fprint(w, `{"next": null, "results": [{"entry_number": 2, "date_filed": "2015-09-22",
+				"description": "ORDER", "recap_documents": [{"document_number": "2", "attachment_number": 1,
+				"description": "Exhibit 1", "filepath_local": "recap/gov.uscourts.nysd.447706.2.1.pdf", "is_available": true}]}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	items, err := CourtListener(nil, CourtListenerOptions{
+		Dockets:    []string{"1:15-cv-07433"},
+		Token:      "secret",
+		APIURL:     server.URL + "/api/",
+		StorageURL: "https://storage.example.com/",
+	}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if token != "Token secret" {
+		t.Errorf("sent Authorization %q", token)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Resolve() returned %d items, want the 2 available documents", len(items))
+	}
+	if items[0].URL != "https://storage.example.com/recap/gov.uscourts.nysd.447706.1.0.pdf" {
+		t.Errorf("items[0].URL = %q", items[0].URL)
+	}
+	first, second := items[0].Meta, items[1].Meta
+	if first.Title != "COMPLAINT" || first.Fields["docket_number"] != "1:15-cv-07433" || first.Fields["entry_number"] != 1 || first.Fields["page_count"] != 12 {
+		t.Errorf("items[0].Meta = %+v", first)
+	}
+	if second.Title != "Exhibit 1" || second.Fields["attachment_number"] != 1 || second.Fields["date_filed"] != "2015-09-22" {
+		t.Errorf("items[1].Meta = %+v", second)
+	}
+
+	if _, err := CourtListener(nil, CourtListenerOptions{Dockets: []string{"9:99-cv-00000"}, APIURL: server.URL + "/api/"}).Resolve(); err == nil {
+		t.Error("Resolve() of an unknown docket succeeded")
+	}
+}
+
+// bencode encodes strings, ints, lists and dictionaries as a .torrent holds
+// them
+func bencode(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%d:%s", len(v), v)
+	case int:
+		return fmt.Sprintf("i%de", v)
+	case []any:
+		var b strings.Builder
+		for _, e := range v {
+			b.WriteString(bencode(e))
+		}
+		return "l" + b.String() + "e"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		for _, k := range keys {
+			b.WriteString(bencode(k) + bencode(v[k]))
+		}
+		return "d" + b.String() + "e"
+	}
+	panic(fmt.Sprintf("cannot bencode %T", v))
+}
+
+func TestTorrentResolve(t *testing.T) {
+	dir := t.TempDir()
+	writeTorrent := func(name string, torrent map[string]any) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(bencode(torrent)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	info := map[string]any{
+		"name":         "DataSet 8",
+		"piece length": 16384,
+		"files": []any{
+			map[string]any{"length": 10, "path": []any{"VOL00008", "EFTA00010724.pdf"}},
+			map[string]any{"length": 5, "path": []any{"README.txt"}},
+		},
+	}
+	multi := writeTorrent("dataset-8.torrent", map[string]any{
+		"announce": "udp://tracker.example.org:1337",
+		"url-list": []any{"https://archive.example.org/download/"},
+		"info":     info,
+	})
+	hash := sha1.Sum([]byte(bencode(info)))
+
+	// Torrents listed among other inputs stand for their documents
+	items, err := Inputs(nil, []string{multi}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("Resolve() returned %d items, want the torrent's one PDF", len(items))
+	}
+	if want := "https://archive.example.org/download/DataSet%208/VOL00008/EFTA00010724.pdf"; items[0].URL != want {
+		t.Errorf("items[0].URL = %q, want %q", items[0].URL, want)
+	}
+	if fields := items[0].Meta.Fields; fields["info_hash"] != hex.EncodeToString(hash[:]) || fields["torrent"] != "DataSet 8" {
+		t.Errorf("items[0].Meta = %+v", items[0].Meta)
+	}
+
+	single := writeTorrent("single.torrent", map[string]any{
+		"url-list": "https://mirror.example.org/EFTA00010725.pdf",
+		"info":     map[string]any{"name": "EFTA00010725.pdf", "length": 10, "piece length": 16384},
+	})
+	items, err = Torrent(nil, single).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(items) != 1 || items[0].URL != "https://mirror.example.org/EFTA00010725.pdf" {
+		t.Errorf("Resolve() of a single-file torrent = %+v", items)
+	}
+
+	for name, input := range map[string]string{
+		"no web seeds": writeTorrent("peers-only.torrent", map[string]any{"info": info}),
+		"escaping path": writeTorrent("escape.torrent", map[string]any{"url-list": "https://mirror.example.org/", "info": map[string]any{
+			"name": "docs", "files": []any{map[string]any{"length": 1, "path": []any{"..", "secret.pdf"}}},
+		}}),
+		"missing": filepath.Join(dir, "missing.torrent"),
+		"magnet":  "magnet:?xt=urn:btih:" + hex.EncodeToString(hash[:]),
+	} {
+		if _, err := Torrent(nil, input).Resolve(); err == nil {
+			t.Errorf("%s: Resolve() succeeded", name)
+		}
+	}
+}
+
+func TestParseTorrentRejectsMalformedData(t *testing.T) {
+	for _, data := range []string{"", "d4:infod4:name1:a", "li1e", "d4:infoi1ee", "d4:info" + "d4:name99:ae" + "e", "d4:infod4:namei1eee"} {
+		if _, err := parseTorrent([]byte(data)); err == nil {
+			t.Errorf("parseTorrent(%q) succeeded", data)
+		}
+	}
+}
+
+func TestIsTorrent(t *testing.T) {
+	for input, want := range map[string]bool{
+		"dataset-8.torrent":                                            true,
+		"https://archive.org/download/x/x.torrent?v=1":                 true,
+		"magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a": true,
+		"EFTA00010724.pdf":                                             false,
+		"https://example.com/torrent/report.pdf":                       false,
+	} {
+		if got := IsTorrent(input); got != want {
+			t.Errorf("IsTorrent(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestCrawlResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>
+			<a href="DataSet%208/EFTA00010724.pdf">EFTA00010724</a>
+			<a href='/files/EFTA00010725.PDF#page=2'>EFTA00010725</a>
+			<a href=https://mirror.example.org/EFTA00010726.pdf>mirror</a>
+			<a href="DataSet%208/EFTA00010724.pdf">again</a>
+			<a href="about.html">About</a>
+			<a href="mailto:press@example.org">Press</a>
+			<a href="notes.pdf&amp;x=1">escaped</a>
+		</body></html>`)
+	}))
+	defer server.Close()
+
+	dl := downloader.New(t.TempDir())
+	items, err := Crawl(dl, []string{server.URL + "/epstein/files/"}, nil).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Input)
+	}
+	want := []string{
+		server.URL + "/epstein/files/DataSet%208/EFTA00010724.pdf",
+		server.URL + "/files/EFTA00010725.PDF",
+		"https://mirror.example.org/EFTA00010726.pdf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+	if items[0].Meta.Fields["index_page"] != server.URL+"/epstein/files/" {
+		t.Errorf("items[0].Meta = %+v", items[0].Meta)
+	}
+
+	// Only the listed extensions are taken when some are
+	items, err = Crawl(dl, []string{server.URL + "/epstein/files/"}, []string{"html"}).Resolve()
+	if err != nil || len(items) != 1 || !strings.HasSuffix(items[0].Input, "/about.html") {
+		t.Errorf("Resolve() with extensions = %+v, %v", items, err)
+	}
+	if _, err := Crawl(dl, []string{"http://127.0.0.1:1/"}, nil).Resolve(); err == nil {
+		t.Error("Resolve() of an unreachable index page succeeded")
+	}
+}
+
+func TestDiffReportsNewRuns(t *testing.T) {
+	items, err := Pattern(nil, "https://example.com/EFTA{00000001-00000008}.pdf").Resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{}
+	for _, i := range []int{0, 1, 2, 5} {
+		known[items[i].URL] = true
+	}
+	delta := Diff(items, func(item Item) bool { return known[item.URL] })
+	if len(delta.Known) != 4 || len(delta.New) != 4 {
+		t.Fatalf("got %d known, %d new; want 4 and 4", len(delta.Known), len(delta.New))
+	}
+	want := []Run{
+		{First: "https://example.com/EFTA00000004.pdf", Last: "https://example.com/EFTA00000005.pdf", Count: 2},
+		{First: "https://example.com/EFTA00000007.pdf", Last: "https://example.com/EFTA00000008.pdf", Count: 2},
+	}
+	if got := delta.Runs(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("Runs = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,288 @@
+package source
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"defornicate-epstein-files/internal/downloader"
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// maxTorrentSize caps the .torrent files read, which only hold metadata
+const maxTorrentSize = 16 << 20
+
+// torrentSource provides the documents of a torrent through its web seeds
+// (BEP 19), the HTTP mirrors a torrent such as the Internet Archive's lists
+// alongside its trackers. Peers are never contacted.
+type torrentSource struct {
+	dl    *downloader.Downloader
+	input string
+}
+
+// Torrent returns a source for the documents of the .torrent file at input,
+// a local path or URL. Its files are downloaded from the torrent's first web
+// seed; a torrent without web seeds, or a magnet link, cannot be resolved.
+func Torrent(dl *downloader.Downloader, input string) Source {
+	return &torrentSource{dl: dl, input: input}
+}
+
+// IsTorrent reports whether input names a torrent rather than a document
+func IsTorrent(input string) bool {
+	lower := strings.ToLower(input)
+	if strings.HasPrefix(lower, "magnet:") {
+		return true
+	}
+	if u, err := url.Parse(input); err == nil && downloader.IsURL(input) {
+		lower = strings.ToLower(u.Path)
+	}
+	return strings.HasSuffix(lower, ".torrent")
+}
+
+// torrent is the metadata of a .torrent file used to find its documents
+type torrent struct {
+	name     string
+	infoHash string
+	files    [][]string // paths below the name; nil for a single-file torrent
+	webSeeds []string
+}
+
+func (s *torrentSource) Resolve() ([]Item, error) {
+	if strings.HasPrefix(strings.ToLower(s.input), "magnet:") {
+		return nil, fmt.Errorf("magnet links are not supported, only .torrent files with web seeds: %s", s.input)
+	}
+	data, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read torrent %s: %w", s.input, err)
+	}
+	t, err := parseTorrent(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent %s: %w", s.input, err)
+	}
+	if len(t.webSeeds) == 0 {
+		return nil, fmt.Errorf("torrent %s has no web seeds to download from (peer-to-peer transfer is not supported)", s.input)
+	}
+	fields := map[string]any{"source": "torrent", "torrent": t.name, "info_hash": t.infoHash}
+	var items []Item
+	add := func(path []string) {
+		if !extractor.IsSupported(path[len(path)-1]) {
+			return
+		}
+		docURL := t.fileURL(t.webSeeds[0], path)
+		items = append(items, Item{Input: docURL, URL: downloader.CanonicalURL(docURL), Meta: &meta.Meta{Fields: maps.Clone(fields)}, src: s})
+	}
+	if t.files == nil {
+		add([]string{t.name})
+	}
+	for _, path := range t.files {
+		add(path)
+	}
+	return items, nil
+}
+
+func (s *torrentSource) Fetch(item Item) (io.ReadCloser, error) {
+	return s.dl.Open(item.Input)
+}
+
+// read returns the content of the .torrent file
+func (s *torrentSource) read() ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	if downloader.IsURL(s.input) {
+		r, err = s.dl.Open(s.input)
+	} else {
+		r, err = os.Open(s.input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxTorrentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTorrentSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxTorrentSize)
+	}
+	return data, nil
+}
+
+// fileURL returns where seed serves the file at path. As BEP 19 describes, a
+// seed ending in a slash is a directory holding the torrent's name; a
+// multi-file torrent's seed is always one.
+func (t *torrent) fileURL(seed string, path []string) string {
+	if t.files == nil && !strings.HasSuffix(seed, "/") {
+		return seed
+	}
+	if t.files != nil {
+		path = append([]string{t.name}, path...)
+	}
+	escaped := make([]string, len(path))
+	for i, segment := range path {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return strings.TrimSuffix(seed, "/") + "/" + strings.Join(escaped, "/")
+}
+
+// parseTorrent reads the metadata of a .torrent file
+func parseTorrent(data []byte) (*torrent, error) {
+	d := &bdecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("not a dictionary")
+	}
+	info, ok := root["info"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("no info dictionary")
+	}
+	t := &torrent{}
+	hash := sha1.Sum(data[d.info[0]:d.info[1]])
+	t.infoHash = hex.EncodeToString(hash[:])
+	if t.name, ok = info["name"].(string); !ok || !safePathSegment(t.name) {
+		return nil, fmt.Errorf("invalid name %q", info["name"])
+	}
+	if files, ok := info["files"].([]any); ok {
+		t.files = [][]string{}
+		for _, f := range files {
+			file, _ := f.(map[string]any)
+			parts, _ := file["path"].([]any)
+			var path []string
+			for _, p := range parts {
+				segment, ok := p.(string)
+				if !ok || !safePathSegment(segment) {
+					return nil, fmt.Errorf("invalid file path %v", parts)
+				}
+				path = append(path, segment)
+			}
+			if len(path) == 0 {
+				return nil, fmt.Errorf("file without a path")
+			}
+			t.files = append(t.files, path)
+		}
+	}
+	switch seeds := root["url-list"].(type) {
+	case string:
+		t.webSeeds = []string{seeds}
+	case []any:
+		for _, seed := range seeds {
+			if s, ok := seed.(string); ok {
+				t.webSeeds = append(t.webSeeds, s)
+			}
+		}
+	}
+	return t, nil
+}
+
+// safePathSegment reports whether a name or path element of a torrent can be
+// appended to a URL without leaving the seed's directory
+func safePathSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
+
+// bdecoder decodes bencoded data: integers as int64, strings as string,
+// lists as []any and dictionaries as map[string]any
+type bdecoder struct {
+	data []byte
+	pos  int
+	info [2]int // bounds of the top-level "info" value, hashed for the info hash
+}
+
+func (d *bdecoder) value(depth int) (any, error) {
+	if depth > 64 {
+		return nil, fmt.Errorf("nested too deeply")
+	}
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := d.index('e', d.pos+1)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated integer at %d", d.pos)
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:end]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer at %d", d.pos)
+		}
+		d.pos = end + 1
+		return n, nil
+	case c == 'l':
+		d.pos++
+		list := []any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if d.pos >= len(d.data) {
+			return nil, fmt.Errorf("unterminated list")
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		dict := map[string]any{}
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			start := d.pos
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.info = [2]int{start, d.pos}
+			}
+			dict[key] = v
+		}
+		if d.pos >= len(d.data) {
+			return nil, fmt.Errorf("unterminated dictionary")
+		}
+		d.pos++
+		return dict, nil
+	case '0' <= c && c <= '9':
+		return d.str()
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", c, d.pos)
+	}
+}
+
+// str decodes a length-prefixed string
+func (d *bdecoder) str() (string, error) {
+	colon := d.index(':', d.pos)
+	if colon < 0 {
+		return "", fmt.Errorf("invalid string at %d", d.pos)
+	}
+	n, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || n < 0 || n > len(d.data)-colon-1 {
+		return "", fmt.Errorf("invalid string length at %d", d.pos)
+	}
+	s := string(d.data[colon+1 : colon+1+n])
+	d.pos = colon + 1 + n
+	return s, nil
+}
+
+// index returns the position of the first c at or after from, or -1
+func (d *bdecoder) index(c byte, from int) int {
+	for i := from; i < len(d.data); i++ {
+		if d.data[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,118 @@
+// Package speech renders extraction outputs as plain text meant to be listened
+// to through text-to-speech engines and screen readers: page furniture and
+// line noise are dropped, lines are rejoined into paragraphs, and Bates
+// numbers and legal shorthand are written the way they should be read.
+package speech
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/segment"
+)
+
+var (
+	// questionRe and answerRe match transcript question/answer markers
+	questionRe = regexp.MustCompile(`^Q[.:]\s+`)
+	answerRe   = regexp.MustCompile(`^A[.:]\s+`)
+	// numberAbbrevRe matches "No." before a number, e.g. "Exhibit No. 4"
+	numberAbbrevRe = regexp.MustCompile(`\b(?:No|NO|Nos|NOS)\.\s*(\d)`)
+	// versusRe matches "v." or "vs." between two parties
+	versusRe = regexp.MustCompile(`(\S)\s+(?:v|vs|V|VS)\.\s+(\S)`)
+	spacesRe = regexp.MustCompile(`[ \t]+`)
+)
+
+// digitWords spells out the digits of a Bates number
+var digitWords = [...]string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+// Format renders an extraction output as speech-friendly text: a short
+// header (the curated title if there is one), then every page announced as
+// "Page N of M." followed by its cleaned paragraphs and the text of its
+// images
+func Format(extracted *extractor.ExtractedText) []byte {
+	var b strings.Builder
+	title := strings.TrimSuffix(extracted.Metadata.Filename, filepath.Ext(extracted.Metadata.Filename))
+	if curated := extracted.Metadata.Curated; curated != nil && curated.Title != "" {
+		title = curated.Title
+	}
+	total := extracted.Metadata.TotalPages
+	fmt.Fprintf(&b, "%s.\n%d %s.\n", sentence(Normalize(title)), total, plural(total, "page", "pages"))
+
+	for _, page := range extracted.Content.Pages {
+		fmt.Fprintf(&b, "\nPage %d of %d.\n", page.PageNumber, total)
+		if page.Blank {
+			b.WriteString("\nThis page is blank.\n")
+			continue
+		}
+		paragraphs := Paragraphs(page.LineText())
+		if len(paragraphs) == 0 {
+			b.WriteString("\nThis page has no text.\n")
+		}
+		for _, paragraph := range paragraphs {
+			b.WriteString("\n" + paragraph + "\n")
+		}
+		// Merged image text is already part of the page text
+		if images := Paragraphs(page.ImageText); len(images) > 0 && len(page.OCRMerge) == 0 {
+			b.WriteString("\nText in images on this page.\n")
+			for _, paragraph := range images {
+				b.WriteString("\n" + paragraph + "\n")
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// Paragraphs cleans the text of one page and returns its paragraphs, each
+// normalized for reading aloud on a single line
+func Paragraphs(text string) []string {
+	var paragraphs []string
+	for _, paragraph := range segment.Paragraphs(text) {
+		paragraphs = append(paragraphs, Normalize(paragraph.Text))
+	}
+	return paragraphs
+}
+
+// Normalize rewrites text the way it should be read aloud: Bates numbers
+// spelled out, section and paragraph marks, "No.", "v." and transcript
+// question/answer markers written as words
+func Normalize(text string) string {
+	text = legal.ReplaceBates(text, spokenBates)
+	text = questionRe.ReplaceAllString(text, "Question. ")
+	text = answerRe.ReplaceAllString(text, "Answer. ")
+	text = numberAbbrevRe.ReplaceAllString(text, "number $1")
+	text = versusRe.ReplaceAllString(text, "$1 versus $2")
+	text = strings.NewReplacer("§§", "sections ", "§", "section ", "¶¶", "paragraphs ", "¶", "paragraph ", "&", " and ").Replace(text)
+	return strings.TrimSpace(spacesRe.ReplaceAllString(text, " "))
+}
+
+// spokenBates spells out a Bates number letter by letter and digit by digit,
+// without the zero padding: EFTA00010724 is "E F T A one zero seven two four"
+func spokenBates(b legal.Bates) string {
+	var words []string
+	for _, r := range b.Prefix {
+		if unicode.IsLetter(r) {
+			words = append(words, string(r))
+		}
+	}
+	for _, d := range fmt.Sprint(b.Number) {
+		words = append(words, digitWords[d-'0'])
+	}
+	return strings.Join(words, " ")
+}
+
+// sentence strips trailing punctuation so a period can follow
+func sentence(s string) string {
+	return strings.TrimRight(s, ".!?:; ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,70 @@
+package speech
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/meta"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"See EFTA00010724 and DOJ-OGR-00000044.": "See E F T A one zero seven two four and D O J O G R four four.",
+		"Doe v. Epstein, Case No. 08-80736":      "Doe versus Epstein, Case number 08-80736",
+		"under 18 U.S.C. § 1591 ¶ 4":             "under 18 U.S.C. section 1591 paragraph 4",
+		"Q. And who was there?":                  "Question. And who was there?",
+		"Smith & Wesson":                         "Smith and Wesson",
+	}
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	text := strings.Join([]string{
+		"- 3 -",
+		"The witness stated that the flight docu-",
+		"ments were kept there.",
+		"That ended it.",
+		"____________________",
+		"Q. Were they?",
+		"A. Yes.",
+		"Table of contents ........... 4",
+		"Page 3 of 40",
+	}, "\n")
+	want := []string{
+		"The witness stated that the flight documents were kept there.",
+		"That ended it.",
+		"Question. Were they?",
+		"Answer. Yes.",
+		"Table of contents 4",
+	}
+	if got := Paragraphs(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("Paragraphs() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	extracted := &extractor.ExtractedText{
+		Metadata: extractor.Metadata{Filename: "EFTA00010724.pdf", TotalPages: 2},
+		Content: extractor.Content{Pages: []extractor.Page{
+			{PageNumber: 1, Text: "Flight log", ImageText: "CHECK NO. 1042"},
+			{PageNumber: 2, Text: "2"},
+		}},
+	}
+	want := "E F T A one zero seven two four.\n2 pages.\n\n" +
+		"Page 1 of 2.\n\nFlight log\n\nText in images on this page.\n\nCHECK number 1042\n\n" +
+		"Page 2 of 2.\n\nThis page has no text.\n"
+	if got := string(Format(extracted)); got != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+
+	extracted.Metadata.Curated = &meta.Meta{Title: "Flight log, 1997-2005."}
+	if got := string(Format(extracted)); !strings.HasPrefix(got, "Flight log, 1997-2005.\n") {
+		t.Errorf("Format() header = %q, want the curated title", strings.SplitN(got, "\n", 2)[0])
+	}
+}
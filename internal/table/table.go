@@ -0,0 +1,171 @@
+// Package table renders aligned, optionally colored tables for the
+// human-facing output of the CLI.
+package table
+
+import (
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style is a terminal text style
+type Style int
+
+// Styles a cell or string can be painted with
+const (
+	Plain Style = iota
+	Bold
+	Dim
+	Red
+	Green
+	Yellow
+	Cyan
+)
+
+// sgr holds the ANSI select-graphic-rendition code of each style
+var sgr = map[Style]string{
+	Bold:   "1",
+	Dim:    "2",
+	Red:    "31",
+	Green:  "32",
+	Yellow: "33",
+	Cyan:   "36",
+}
+
+// ColorEnabled reports whether output to f should be colored: only when f is
+// a terminal, NO_COLOR (https://no-color.org) is unset or empty, and TERM is
+// not "dumb"
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Painter applies styles when color is enabled and leaves text alone
+// otherwise
+type Painter struct {
+	Color bool
+}
+
+// Paint returns s in the given style
+func (p Painter) Paint(s string, style Style) string {
+	code, ok := sgr[style]
+	if !p.Color || !ok || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// Cell is a table cell with its style
+type Cell struct {
+	Text  string
+	Style Style
+}
+
+// Table collects rows and writes them with aligned columns
+type Table struct {
+	header []string
+	rows   [][]Cell
+	right  map[int]bool
+	styles map[int]Style
+}
+
+// New creates a Table with the given column headings
+func New(header ...string) *Table {
+	return &Table{header: header, right: make(map[int]bool), styles: make(map[int]Style)}
+}
+
+// AlignRight right-aligns the given columns, e.g. counts and sizes
+func (t *Table) AlignRight(columns ...int) *Table {
+	for _, c := range columns {
+		t.right[c] = true
+	}
+	return t
+}
+
+// StyleColumn paints every cell of a column that has no style of its own
+func (t *Table) StyleColumn(column int, style Style) *Table {
+	t.styles[column] = style
+	return t
+}
+
+// Add appends a row of plain cells
+func (t *Table) Add(cells ...string) {
+	row := make([]Cell, len(cells))
+	for i, text := range cells {
+		row[i] = Cell{Text: text}
+	}
+	t.rows = append(t.rows, row)
+}
+
+// AddCells appends a row of styled cells
+func (t *Table) AddCells(cells ...Cell) {
+	t.rows = append(t.rows, cells)
+}
+
+// Len returns the number of rows added
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the header and rows to w, two spaces between columns. The
+// last column is not padded, so long text such as snippets runs on without
+// trailing blanks.
+func (t *Table) Render(w io.Writer, p Painter) error {
+	widths := make([]int, len(t.header))
+	measure := func(i int, text string) {
+		if i >= len(widths) {
+			widths = append(widths, make([]int, i+1-len(widths))...)
+		}
+		widths[i] = max(widths[i], utf8.RuneCountInString(text))
+	}
+	for i, h := range t.header {
+		measure(i, h)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			measure(i, cell.Text)
+		}
+	}
+
+	var b strings.Builder
+	line := func(cells []Cell, header bool) {
+		for i, cell := range cells {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.Text))
+			style := cell.Style
+			if header {
+				style = Bold
+			} else if style == Plain {
+				style = t.styles[i]
+			}
+			text := p.Paint(cell.Text, style)
+			switch {
+			case t.right[i]:
+				b.WriteString(pad + text)
+			case i < len(cells)-1:
+				b.WriteString(text + pad)
+			default:
+				b.WriteString(text)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(t.header) > 0 {
+		cells := make([]Cell, len(t.header))
+		for i, h := range t.header {
+			cells[i] = Cell{Text: h}
+		}
+		line(cells, true)
+	}
+	for _, row := range t.rows {
+		line(row, false)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
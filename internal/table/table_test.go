@@ -0,0 +1,40 @@
+package table
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tb := New("DOCUMENT", "PAGE", "SNIPPET").AlignRight(1)
+	tb.Add("pdf/a/a.pdf", "7", "Flight log")
+	tb.Add("pdf/médecin/b.pdf", "12", "Deposition")
+	var b strings.Builder
+	if err := tb.Render(&b, Painter{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "DOCUMENT           PAGE  SNIPPET\n" +
+		"pdf/a/a.pdf           7  Flight log\n" +
+		"pdf/médecin/b.pdf    12  Deposition\n"
+	if b.String() != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	tb := New("NAME", "RESULT").StyleColumn(0, Cyan)
+	tb.AddCells(Cell{Text: "a"}, Cell{Text: "failed", Style: Red})
+	var b strings.Builder
+	tb.Render(&b, Painter{Color: true})
+	want := "\x1b[1mNAME\x1b[0m  \x1b[1mRESULT\x1b[0m\n\x1b[36ma\x1b[0m     \x1b[31mfailed\x1b[0m\n"
+	if b.String() != want {
+		t.Errorf("Render() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if ColorEnabled(nil) {
+		t.Error("ColorEnabled() with NO_COLOR set = true")
+	}
+}
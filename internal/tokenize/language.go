@@ -0,0 +1,112 @@
+package tokenize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scripts are the writing systems Detect tells apart, and the language each
+// one is taken to be written in. Latin script text is told apart by its
+// function words.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// functionWords are frequent words of each Latin script language Detect
+// recognizes, chosen not to be frequent in the others
+var functionWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "that", "is", "was", "for", "with", "this", "be", "not", "have", "from", "by"},
+	"fr": {"le", "les", "des", "et", "est", "une", "du", "dans", "pour", "qui", "pas", "sur", "au", "avec", "cette"},
+	"es": {"el", "los", "las", "del", "y", "que", "por", "con", "una", "para", "es", "se", "lo", "al", "fue"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "mit", "den", "von", "zu", "ein", "eine", "dem", "auch", "sich"},
+	"it": {"il", "di", "che", "della", "per", "sono", "non", "gli", "alla", "nel", "è", "questo", "anche", "delle", "degli"},
+	"pt": {"os", "da", "do", "das", "dos", "em", "não", "uma", "com", "ao", "foi", "mais", "seu", "sua", "pelo"},
+}
+
+// minFunctionWords is how many function words of a language Latin script
+// text must hold to be taken as written in it
+const minFunctionWords = 2
+
+// Detect returns the ISO 639-1 code of the language text is mostly written
+// in, or "" if it can't tell. Chinese and Japanese are told apart by kana,
+// other non-Latin languages by their script alone, and Latin script
+// languages (English, French, Spanish, German, Italian and Portuguese) by
+// their most frequent words.
+func Detect(text string) string {
+	counts := make(map[string]int)
+	latin, total := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["ja"]++
+		case unicode.Is(unicode.Han, r):
+			counts["zh"]++
+		default:
+			for _, s := range scripts {
+				if unicode.Is(s.table, r) {
+					counts[s.lang]++
+					break
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	// Japanese mixes kanji with kana
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	best, bestCount := "", latin
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return detectLatin(text)
+}
+
+// detectLatin tells the Latin script language of text by its function
+// words, or returns "" if too few are found or two languages tie
+func detectLatin(text string) string {
+	words := make(map[string]int)
+	for _, token := range segment(strings.ToLower(text)) {
+		words[token]++
+	}
+	best, bestCount, tied := "", 0, false
+	for lang, list := range functionWords {
+		n := 0
+		for _, w := range list {
+			n += words[w]
+		}
+		switch {
+		case n > bestCount:
+			best, bestCount, tied = lang, n, false
+		case n == bestCount:
+			tied = true
+		}
+	}
+	if bestCount < minFunctionWords || tied {
+		return ""
+	}
+	return best
+}
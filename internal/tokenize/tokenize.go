@@ -0,0 +1,192 @@
+// Package tokenize splits text into words the way a reader would count them,
+// after the word boundary rules of Unicode text segmentation (UAX #29):
+// punctuation, dashes and symbols standing alone are not words, hyphenated
+// compounds, abbreviations such as "U.S." and numbers such as "1,250.00" are
+// one word each, and scripts written without spaces are split per character
+// or per run. Counts of legal and non-English text differ markedly from
+// counts of whitespace-separated fields.
+package tokenize
+
+import (
+	"strings"
+	"unicode"
+)
+
+// class is what a rune is to word segmentation
+type class int
+
+const (
+	other      class = iota // separates words
+	letter                  // alphabetic, including Hangul and scripts without spaces such as Thai
+	digit                   // decimal digit
+	ideograph               // Han ideograph: a word by itself
+	hiragana                // runs are words
+	katakana                // runs are words
+	extend                  // combining mark, part of the word before it
+	midLetter               // joins letters: middle dots and the like
+	midNum                  // joins digits: commas, colons, slashes
+	midLetNum               // joins letters and digits alike: periods, apostrophes
+	hyphen                  // joins the parts of compounds and identifiers
+	prolonged               // katakana-hiragana prolonged sound mark, part of a kana run
+	underscore              // joins anything, as in identifiers
+)
+
+// classify returns the class of r
+func classify(r rune) class {
+	switch r {
+	case '\'', '’', '.':
+		return midLetNum
+	case '\u00B7', '\u0387', '\u05F4', '\u2027': // middle dots, Hebrew gershayim, hyphenation point
+		return midLetter
+	case ',', ';', ':', '/', '٬', '⁄':
+		return midNum
+	case '-', '\u2010', '\u2011': // hyphen-minus, hyphen, non-breaking hyphen
+		return hyphen
+	case '_':
+		return underscore
+	case '\u30FC': // ー
+		return prolonged
+	}
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return ideograph
+	case unicode.Is(unicode.Hiragana, r):
+		return hiragana
+	case unicode.Is(unicode.Katakana, r):
+		return katakana
+	case unicode.IsLetter(r):
+		return letter
+	case unicode.IsDigit(r):
+		return digit
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me):
+		return extend
+	}
+	return other
+}
+
+// joins reports whether a rune of class mid between runes of classes before
+// and after keeps them in one word
+func joins(before, mid, after class) bool {
+	alnum := func(c class) bool { return c == letter || c == digit }
+	switch mid {
+	case midLetter:
+		return before == letter && after == letter
+	case midNum:
+		return before == digit && after == digit
+	case midLetNum:
+		return before == after && alnum(before)
+	case hyphen, underscore:
+		return alnum(before) && alnum(after)
+	}
+	return false
+}
+
+// Tokens splits text into words. Languages change the result only where
+// they write words differently: in French and Italian ("fr", "it"), elided
+// articles and pronouns ("l'", "qu'") are words of their own. An empty
+// language is detected with Detect.
+func Tokens(text, lang string) []string {
+	if lang == "" {
+		lang = Detect(text)
+	}
+	var tokens []string
+	for _, token := range segment(text) {
+		if lang == "fr" || lang == "it" {
+			if head, rest, ok := splitElision(token); ok {
+				tokens = append(tokens, head)
+				token = rest
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// Count returns the number of words in text, as split by Tokens
+func Count(text, lang string) int {
+	return len(Tokens(text, lang))
+}
+
+// segment splits text into words with the language-independent rules
+func segment(text string) []string {
+	runes := []rune(text)
+	classes := make([]class, len(runes))
+	for i, r := range runes {
+		classes[i] = classify(r)
+	}
+	// Combining marks take the class of what they combine with, so that
+	// "é" written as e + U+0301 is one letter; so does the prolonged sound
+	// mark, which is mostly written in katakana
+	for i, c := range classes {
+		switch {
+		case c == extend && i > 0:
+			classes[i] = classes[i-1]
+		case c == prolonged:
+			classes[i] = katakana
+			if i > 0 && classes[i-1] == hiragana {
+				classes[i] = hiragana
+			}
+		}
+	}
+
+	var tokens []string
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, string(runes[start:end]))
+			start = -1
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		c := classes[i]
+		switch c {
+		case ideograph:
+			flush(i)
+			tokens = append(tokens, string(runes[i]))
+		case hiragana, katakana:
+			if start >= 0 && classes[i-1] != c {
+				flush(i)
+			}
+			if start < 0 {
+				start = i
+			}
+		case letter, digit:
+			if start >= 0 && (classes[i-1] == hiragana || classes[i-1] == katakana) {
+				flush(i)
+			}
+			if start < 0 {
+				start = i
+			}
+		case midLetter, midNum, midLetNum, hyphen, underscore:
+			if start >= 0 && i+1 < len(runes) && joins(classes[i-1], c, classes[i+1]) {
+				continue
+			}
+			flush(i)
+		default:
+			flush(i)
+		}
+	}
+	flush(len(runes))
+	return tokens
+}
+
+// elisions are the French and Italian words elided before a vowel
+var elisions = map[string]bool{
+	"c": true, "d": true, "j": true, "l": true, "m": true, "n": true, "s": true, "t": true,
+	"qu": true, "jusqu": true, "lorsqu": true, "puisqu": true, "quoiqu": true,
+	"dell": true, "dall": true, "nell": true, "sull": true, "all": true, "un": true, "quell": true,
+}
+
+// splitElision splits an elided article or pronoun off token: "l'avocat"
+// becomes "l'" and "avocat"
+func splitElision(token string) (string, string, bool) {
+	i := strings.IndexAny(token, "'’")
+	if i <= 0 {
+		return "", "", false
+	}
+	width := len(string([]rune(token[i:])[0]))
+	if i+width == len(token) || !elisions[strings.ToLower(token[:i])] {
+		return "", "", false
+	}
+	return token[:i+width], token[i+width:], true
+}
@@ -0,0 +1,46 @@
+package tokenize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		lang string
+		want []string
+	}{
+		{"Plaintiff-Appellant — Jane Doe", "en", []string{"Plaintiff-Appellant", "Jane", "Doe"}},
+		{"pursuant to 18 U.S.C. § 1591(a)", "en", []string{"pursuant", "to", "18", "U.S.C", "1591", "a"}},
+		{"paid $1,250.00 on 03/14/2005.", "en", []string{"paid", "1,250.00", "on", "03/14/2005"}},
+		{"Case 1:08-cv-80736-KAM, EFTA00010724", "en", []string{"Case", "1:08-cv-80736-KAM", "EFTA00010724"}},
+		{"the defendant's counsel didn't object", "en", []string{"the", "defendant's", "counsel", "didn't", "object"}},
+		{"l'avocat qu'il a nommé", "fr", []string{"l'", "avocat", "qu'", "il", "a", "nommé"}},
+		{"l'avocat", "en", []string{"l'avocat"}},
+		{"résumé --- ...", "en", []string{"résumé"}},
+		{"東京地方裁判所", "zh", []string{"東", "京", "地", "方", "裁", "判", "所"}},
+		{"ファイルを確認した", "ja", []string{"ファイル", "を", "確", "認", "した"}},
+	} {
+		if got := Tokens(tt.text, tt.lang); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokens(%q, %q) = %q, want %q", tt.text, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for text, want := range map[string]string{
+		"The witness was shown the flight log for the month of March.":          "en",
+		"Le témoin a reconnu les documents et la signature de l'avocat.":        "fr",
+		"El testigo declaró que los documentos fueron firmados por el abogado.": "es",
+		"Der Zeuge hat die Dokumente nicht mit dem Anwalt unterschrieben.":      "de",
+		"Свидетель подтвердил подпись":                                          "ru",
+		"ファイルを確認した":                                                             "ja",
+		"EFTA00010724":                                                          "",
+		"":                                                                      "",
+	} {
+		if got := Detect(text); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
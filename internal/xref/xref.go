@@ -0,0 +1,231 @@
+// Package xref links the exhibits a document mentions ("Exhibit 12",
+// "GX-101") to the documents of the tree that are those exhibits, for
+// navigating from a filing to what it cites.
+package xref
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+)
+
+// Link is a mention of an exhibit on a page, resolved to a document
+type Link struct {
+	Document  string `json:"document"`  // mentioning document, relative to the tree with forward slashes
+	Page      int    `json:"page"`      // page of the mention
+	Reference string `json:"reference"` // as written, e.g. "Ex. 12"
+	Exhibit   string `json:"exhibit"`   // canonical label, e.g. "Exhibit 12"
+	Target    string `json:"target"`    // the exhibit document, relative to the tree
+}
+
+// Mention is an exhibit referenced on a page
+type Mention struct {
+	Page int
+	legal.ExhibitMatch
+}
+
+// Document is what linking needs to know about one document
+type Document struct {
+	Path     string   // relative to the tree, with forward slashes
+	Labels   []string // the exhibits the document is (see Labels)
+	Cases    []string // canonical docket numbers of the case it belongs to
+	Mentions []Mention
+}
+
+// Result holds the links found in a tree and what was read
+type Result struct {
+	Links      []Link
+	Unresolved int // mentions of exhibits no document is labeled as
+	Scanned    int // documents with a JSON extraction output
+	Skipped    int // documents without one, which can still be linked to
+}
+
+// slipSheetMaxWords is the most words a first page can hold and still be an
+// exhibit slip sheet whose title labels the document
+const slipSheetMaxWords = 30
+
+// Labels returns the exhibits a document is labeled as, from its file name
+// ("Exhibit 12.pdf", "GX-101.pdf"), the title or "exhibit" field of its
+// meta.yaml, and the title of a slip sheet on its first page ("EXHIBIT A").
+// extracted and curated may be nil.
+func Labels(docPath string, extracted *extractor.ExtractedText, curated *meta.Meta) []string {
+	name := strings.TrimSuffix(filepath.Base(docPath), filepath.Ext(docPath))
+	candidates := []string{name}
+	if curated != nil {
+		candidates = append(candidates, curated.Title)
+		if field, ok := curated.Fields["exhibit"].(string); ok {
+			candidates = append(candidates, field)
+		}
+	}
+	if extracted != nil && len(extracted.Content.Pages) > 0 {
+		if first := extracted.Content.Pages[0]; first.WordCount <= slipSheetMaxWords {
+			for _, line := range strings.Split(first.LineText(), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					candidates = append(candidates, line)
+					break
+				}
+			}
+		}
+	}
+	var labels []string
+	for _, candidate := range candidates {
+		if label := legal.ExhibitLabel(candidate); label != "" && !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// Resolve links the exhibit mentions of docs to the documents labeled as
+// those exhibits. A document never links to itself, and its mentions of
+// itself are not counted as unresolved. When several documents
+// carry the label, the ones sharing a docket number with the mentioning
+// document are preferred; if none does, each is linked. Links are in
+// document, page and target order.
+func Resolve(docs []Document) *Result {
+	byLabel := make(map[string][]*Document)
+	for i := range docs {
+		for _, label := range docs[i].Labels {
+			byLabel[label] = append(byLabel[label], &docs[i])
+		}
+	}
+
+	result := &Result{}
+	for _, doc := range docs {
+		for _, mention := range doc.Mentions {
+			var targets, sameCase []*Document
+			self := false
+			for _, target := range byLabel[mention.Canonical] {
+				if target.Path == doc.Path {
+					self = true // an exhibit's own slip sheet or caption
+					continue
+				}
+				targets = append(targets, target)
+				if sharesCase(doc.Cases, target.Cases) {
+					sameCase = append(sameCase, target)
+				}
+			}
+			if len(targets) == 0 {
+				if !self {
+					result.Unresolved++
+				}
+				continue
+			}
+			if len(sameCase) > 0 {
+				targets = sameCase
+			}
+			for _, target := range targets {
+				result.Links = append(result.Links, Link{
+					Document:  doc.Path,
+					Page:      mention.Page,
+					Reference: mention.Text,
+					Exhibit:   mention.Canonical,
+					Target:    target.Path,
+				})
+			}
+		}
+	}
+	sort.SliceStable(result.Links, func(i, j int) bool {
+		a, b := result.Links[i], result.Links[j]
+		if a.Document != b.Document {
+			return a.Document < b.Document
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.Target < b.Target
+	})
+	return result
+}
+
+// sharesCase reports whether two documents have a docket number in common
+func sharesCase(a, b []string) bool {
+	for _, number := range a {
+		if slices.Contains(b, number) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tree links the exhibit mentions in the JSON extraction outputs of the
+// documents under root to the documents labeled as those exhibits. Every
+// document can be linked to, extracted or not; only extracted ones are
+// searched for mentions.
+func Tree(root string) (*Result, error) {
+	paths, err := extractor.FindDocuments(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Page numbers are only recorded in the JSON output
+	jsonExt := extractor.New()
+	var docs []Document
+	var scanned, skipped int
+	for _, docPath := range paths {
+		rel, err := filepath.Rel(root, docPath)
+		if err != nil {
+			rel = docPath
+		}
+		curated, err := meta.Load(docPath)
+		if err != nil {
+			return nil, err
+		}
+		doc := Document{Path: filepath.ToSlash(rel)}
+
+		var extracted *extractor.ExtractedText
+		if outputPath := jsonExt.FindOutput(docPath); outputPath == "" {
+			skipped++
+		} else {
+			if extracted, err = extractor.ReadExtracted(outputPath); err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", outputPath, err)
+			}
+			scanned++
+			if extracted.Metadata.Case != nil {
+				doc.Cases = extracted.Metadata.Case.CaseNumbers
+			}
+			for _, page := range extracted.Content.Pages {
+				if page.Blank {
+					continue
+				}
+				for _, m := range legal.FindExhibits(page.LineText()) {
+					doc.Mentions = append(doc.Mentions, Mention{Page: page.PageNumber, ExhibitMatch: m})
+				}
+			}
+		}
+		doc.Labels = Labels(docPath, extracted, curated)
+		docs = append(docs, doc)
+	}
+
+	result := Resolve(docs)
+	result.Scanned, result.Skipped = scanned, skipped
+	return result, nil
+}
+
+// csvHeader names the columns written by WriteCSV
+var csvHeader = []string{"document", "page", "reference", "exhibit", "target"}
+
+// WriteCSV writes links as a flat CSV table, one row per link
+func WriteCSV(w io.Writer, links []Link) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, l := range links {
+		row := []string{l.Document, strconv.Itoa(l.Page), l.Reference, l.Exhibit, l.Target}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
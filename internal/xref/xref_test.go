@@ -0,0 +1,83 @@
+package xref
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"defornicate-epstein-files/internal/extractor"
+	"defornicate-epstein-files/internal/legal"
+	"defornicate-epstein-files/internal/meta"
+)
+
+func TestLabels(t *testing.T) {
+	slip := &extractor.ExtractedText{Content: extractor.Content{Pages: []extractor.Page{{PageNumber: 1, Text: "\nEXHIBIT A\n", WordCount: 2}}}}
+	curated := &meta.Meta{Fields: map[string]any{"exhibit": "GX-101"}}
+	if got, want := Labels("pdf/GX-101/GX-101.pdf", slip, curated), []string{"GX 101", "Exhibit A"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Labels() = %q, want %q", got, want)
+	}
+	if got := Labels("pdf/EFTA00010724/EFTA00010724.pdf", nil, nil); got != nil {
+		t.Errorf("Labels() of an unlabeled document = %q, want none", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mention := func(page int, text string) Mention {
+		return Mention{Page: page, ExhibitMatch: legal.FindExhibits(text)[0]}
+	}
+	docs := []Document{
+		{Path: "motion.pdf", Cases: []string{"1:15-cv-07433"}, Mentions: []Mention{mention(3, "Ex. 12"), mention(2, "Exhibit 4"), mention(2, "GX-101")}},
+		{Path: "a/Exhibit 12.pdf", Labels: []string{"Exhibit 12"}, Cases: []string{"1:15-cv-07433"}},
+		{Path: "b/Exhibit 12.pdf", Labels: []string{"Exhibit 12"}, Cases: []string{"1:19-cr-00490"}},
+		{Path: "gx101.pdf", Labels: []string{"GX 101"}, Mentions: []Mention{mention(1, "GX 101")}},
+	}
+	result := Resolve(docs)
+	want := []Link{
+		{Document: "motion.pdf", Page: 2, Reference: "GX-101", Exhibit: "GX 101", Target: "gx101.pdf"},
+		{Document: "motion.pdf", Page: 3, Reference: "Ex. 12", Exhibit: "Exhibit 12", Target: "a/Exhibit 12.pdf"},
+	}
+	if !reflect.DeepEqual(result.Links, want) {
+		t.Errorf("Resolve() links = %+v, want %+v", result.Links, want)
+	}
+	// Exhibit 4 is nowhere, and the exhibit's own slip sheet is no link
+	if result.Unresolved != 1 {
+		t.Errorf("Unresolved = %d, want 1", result.Unresolved)
+	}
+}
+
+func TestTree(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pdf/motion/motion.pdf", "%PDF-1.4")
+	formatted, err := extractor.FormatAsJSON("motion.pdf", []extractor.PageText{{PageNumber: 1, Text: "As Exhibit 12 shows, the flights continued."}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("pdf/motion/motion.extracted.json", string(formatted))
+	write("pdf/Exhibit 12/Exhibit 12.pdf", "%PDF-1.4")
+
+	result, err := Tree(root)
+	if err != nil {
+		t.Fatalf("Tree() error = %v", err)
+	}
+	if result.Scanned != 1 || result.Skipped != 1 || len(result.Links) != 1 || result.Links[0].Target != "pdf/Exhibit 12/Exhibit 12.pdf" {
+		t.Fatalf("Tree() = %+v, want one link from the motion to the unextracted exhibit", result)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, result.Links); err != nil {
+		t.Fatal(err)
+	}
+	want := "document,page,reference,exhibit,target\npdf/motion/motion.pdf,1,Exhibit 12,Exhibit 12,pdf/Exhibit 12/Exhibit 12.pdf\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}